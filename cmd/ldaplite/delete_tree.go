@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/smarzola/ldaplite/internal/store"
+	"github.com/smarzola/ldaplite/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+type deleteTreeOptions struct {
+	dn     string
+	dryRun bool
+}
+
+func newDeleteTreeCommand() *cobra.Command {
+	options := &deleteTreeOptions{}
+	cmd := &cobra.Command{
+		Use:   "delete-tree",
+		Short: "Delete an entry with its whole subtree",
+		Long: "Delete an entry with every entry below it in a single transaction. Members of groups " +
+			"outside the subtree that point at a deleted entry are removed from those groups.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDeleteTree(cmd, options)
+		},
+	}
+	cmd.Flags().StringVar(&options.dn, "dn", "", "DN of the subtree root to delete")
+	cmd.Flags().BoolVar(&options.dryRun, "dry-run", false, "List what would be deleted without deleting it")
+	return cmd
+}
+
+func runDeleteTree(cmd *cobra.Command, options *deleteTreeOptions) error {
+	dn := strings.TrimSpace(options.dn)
+	if dn == "" {
+		return fmt.Errorf("--dn is required")
+	}
+
+	cfg, err := config.LoadFromEnv()
+	if err != nil {
+		return err
+	}
+
+	st := store.NewSQLiteStore(cfg)
+	if err := st.Initialize(cmd.Context()); err != nil {
+		return fmt.Errorf("failed to initialize store: %w", err)
+	}
+	defer st.Close()
+
+	result, err := st.DeleteSubtree(cmd.Context(), dn, store.SubtreeDeleteOptions{DryRun: options.dryRun})
+	if err != nil {
+		return fmt.Errorf("failed to delete subtree %s: %w", dn, err)
+	}
+
+	out := cmd.OutOrStdout()
+	for _, deleted := range result.DNs {
+		fmt.Fprintf(out, "delete: %s\n", deleted)
+	}
+	for _, group := range result.Groups {
+		fmt.Fprintf(out, "remove members from: %s\n", group)
+	}
+	if options.dryRun {
+		fmt.Fprintf(out, "Subtree delete dry-run successful: entries=%d groups=%d\n", len(result.DNs), len(result.Groups))
+		return nil
+	}
+	fmt.Fprintf(out, "Subtree delete successful: entries=%d groups=%d\n", len(result.DNs), len(result.Groups))
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const deleteTreeImportLDIF = `dn: ou=contractors,dc=example,dc=com
+objectClass: organizationalUnit
+ou: contractors
+
+dn: uid=carol,ou=contractors,dc=example,dc=com
+objectClass: inetOrgPerson
+uid: carol
+cn: Carol King
+sn: King
+userPassword: ChangeMe123!
+
+dn: cn=crew,ou=groups,dc=example,dc=com
+objectClass: groupOfNames
+cn: crew
+member: uid=carol,ou=contractors,dc=example,dc=com
+member: uid=admin,ou=users,dc=example,dc=com`
+
+func TestDeleteTreeDryRunListsEntriesWithoutDeleting(t *testing.T) {
+	dbPath := setupImportCommandEnv(t)
+	importCmd := newImportCommand()
+	importCmd.SetArgs([]string{"ldif", "--file", writeImportFixture(t, deleteTreeImportLDIF)})
+	require.NoError(t, importCmd.Execute())
+
+	var out bytes.Buffer
+	cmd := newDeleteTreeCommand()
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"--dn", "ou=contractors,dc=example,dc=com", "--dry-run"})
+
+	require.NoError(t, cmd.Execute())
+	output := out.String()
+	assert.Contains(t, output, "delete: uid=carol,ou=contractors,dc=example,dc=com\ndelete: ou=contractors,dc=example,dc=com\n")
+	assert.Contains(t, output, "remove members from: cn=crew,ou=groups,dc=example,dc=com")
+	assert.Contains(t, output, "Subtree delete dry-run successful: entries=2 groups=1")
+
+	st := openTestStore(t, dbPath)
+	defer st.Close()
+	exists, err := st.EntryExists(context.Background(), "uid=carol,ou=contractors,dc=example,dc=com")
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func TestDeleteTreeDeletesSubtreeAndMemberships(t *testing.T) {
+	dbPath := setupImportCommandEnv(t)
+	importCmd := newImportCommand()
+	importCmd.SetArgs([]string{"ldif", "--file", writeImportFixture(t, deleteTreeImportLDIF)})
+	require.NoError(t, importCmd.Execute())
+
+	var out bytes.Buffer
+	cmd := newDeleteTreeCommand()
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"--dn", "ou=contractors,dc=example,dc=com"})
+
+	require.NoError(t, cmd.Execute())
+	assert.Contains(t, out.String(), "Subtree delete successful: entries=2 groups=1")
+
+	st := openTestStore(t, dbPath)
+	defer st.Close()
+	ctx := context.Background()
+	exists, err := st.EntryExists(ctx, "ou=contractors,dc=example,dc=com")
+	require.NoError(t, err)
+	assert.False(t, exists)
+	crew, err := st.GetEntry(ctx, "cn=crew,ou=groups,dc=example,dc=com")
+	require.NoError(t, err)
+	require.NotNil(t, crew)
+	assert.Equal(t, []string{"uid=admin,ou=users,dc=example,dc=com"}, crew.GetAttributes("member"))
+}
@@ -70,7 +70,7 @@ func runImportLDIF(cmd *cobra.Command, options *importLDIFOptions) error {
 
 	plan, err := ldif.PlanImport(cmd.Context(), st, records, ldif.ImportPlanOptions{
 		BaseDN:                  cfg.LDAP.BaseDN,
-		Hasher:                  crypto.NewPasswordHasher(cfg.Security.Argon2Config),
+		Hasher:                  crypto.NewPasswordHasher(cfg.Security.Argon2Config).WithLegacySchemes(cfg.Security.AllowLegacyPasswordHashes),
 		ReplaceExisting:         options.replaceExisting,
 		AllowGeneratedPasswords: options.allowGeneratedPasswords,
 	})
@@ -13,11 +13,14 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/smarzola/ldaplite/internal/authz"
 	"github.com/smarzola/ldaplite/internal/server"
 	"github.com/smarzola/ldaplite/internal/store"
 	"github.com/smarzola/ldaplite/internal/telemetry"
+	"github.com/smarzola/ldaplite/internal/throttle"
 	"github.com/smarzola/ldaplite/internal/web"
 	"github.com/smarzola/ldaplite/pkg/config"
+	"github.com/smarzola/ldaplite/pkg/crypto"
 	"github.com/spf13/cobra"
 	_ "modernc.org/sqlite"
 )
@@ -54,6 +57,8 @@ func init() {
 	rootCmd.AddCommand(healthcheckCmd)
 	rootCmd.AddCommand(newImportCommand())
 	rootCmd.AddCommand(newExportCommand())
+	rootCmd.AddCommand(newPasswordsCommand())
+	rootCmd.AddCommand(newDeleteTreeCommand())
 }
 
 func startServer() error {
@@ -62,6 +67,12 @@ func startServer() error {
 	if err != nil {
 		return err
 	}
+	if err := authz.ValidateAccessRules(cfg.Authz.AccessRules); err != nil {
+		return fmt.Errorf("LDAP_ACCESS_RULES_FILE: %w", err)
+	}
+	if err := authz.ValidateRoles(cfg.Authz.Roles); err != nil {
+		return fmt.Errorf("LDAP_ROLES_FILE: %w", err)
+	}
 	cfg.Print()
 
 	// Initialize structured logging (slog only, no unstructured logs)
@@ -84,6 +95,14 @@ func startServer() error {
 		}
 	}()
 
+	// Bound concurrent password hashing across LDAP binds, the Web UI and SCIM
+	if cfg.Security.PasswordHashWorkers > 0 {
+		hashPool := crypto.NewHashPool(cfg.Security.PasswordHashWorkers, time.Duration(cfg.Security.PasswordHashQueueTimeout)*time.Second)
+		hashPool.SetWaitObserver(telemetry.RecordPasswordHashWait)
+		telemetry.RegisterPasswordHashPoolStatsProvider(hashPool.Stats)
+		crypto.SetHashPool(hashPool)
+	}
+
 	// Initialize SQLite store
 	st := store.NewSQLiteStore(cfg)
 	if err := st.Initialize(ctx); err != nil {
@@ -93,8 +112,21 @@ func startServer() error {
 
 	slog.Info("Database initialized successfully")
 
+	// Failed binds and Web UI sign-ins share one throttle
+	throttler := throttle.New(cfg.Security.BindThrottle)
+	if stateFile := cfg.Security.BindThrottle.StateFile; stateFile != "" {
+		if err := throttler.Load(stateFile); err != nil {
+			slog.Warn("Failed to restore bind throttle state", "path", stateFile, "error", err)
+		}
+		defer func() {
+			if err := throttler.Save(stateFile); err != nil {
+				slog.Error("Failed to save bind throttle state", "path", stateFile, "error", err)
+			}
+		}()
+	}
+
 	// Create and start LDAP server
-	srv := server.NewServer(cfg, st, version)
+	srv := server.NewServer(cfg, st, version, throttler)
 	if err := srv.Start(); err != nil {
 		return fmt.Errorf("failed to start server: %w", err)
 	}
@@ -105,7 +137,7 @@ func startServer() error {
 	var webSrv *web.Server
 	if cfg.WebUI.Enabled {
 		var err error
-		webSrv, err = web.NewServer(cfg, st)
+		webSrv, err = web.NewServer(cfg, st, throttler)
 		if err != nil {
 			return fmt.Errorf("failed to create web server: %w", err)
 		}
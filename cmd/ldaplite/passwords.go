@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/smarzola/ldaplite/internal/store"
+	"github.com/smarzola/ldaplite/pkg/config"
+	"github.com/smarzola/ldaplite/pkg/crypto"
+	"github.com/spf13/cobra"
+)
+
+const hashStatusOutdatedArgon2 = "{ARGON2ID} outdated parameters"
+
+type passwordReportOptions struct {
+	list bool
+}
+
+func newPasswordsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "passwords",
+		Short: "Inspect stored password hashes",
+	}
+	cmd.AddCommand(newPasswordsReportCommand())
+	return cmd
+}
+
+func newPasswordsReportCommand() *cobra.Command {
+	options := &passwordReportOptions{}
+	cmd := &cobra.Command{
+		Use:   "report",
+		Short: "Count accounts whose password hash needs a rehash",
+		Long: "Count accounts whose password is stored in a legacy scheme or with Argon2id parameters " +
+			"other than the configured ones. They are rehashed on their next successful bind.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runPasswordsReport(cmd, options)
+		},
+	}
+	cmd.Flags().BoolVar(&options.list, "list", false, "List the DN of every account that needs a rehash")
+	return cmd
+}
+
+func runPasswordsReport(cmd *cobra.Command, options *passwordReportOptions) error {
+	cfg, err := config.LoadFromEnv()
+	if err != nil {
+		return err
+	}
+
+	st := store.NewSQLiteStore(cfg)
+	if err := st.Initialize(cmd.Context()); err != nil {
+		return fmt.Errorf("failed to initialize store: %w", err)
+	}
+	defer st.Close()
+
+	hashes, err := st.ListUserPasswordHashes(cmd.Context())
+	if err != nil {
+		return fmt.Errorf("failed to read password hashes: %w", err)
+	}
+
+	hasher := crypto.NewPasswordHasher(cfg.Security.Argon2Config)
+	counts := map[string]int{}
+	var stale []store.UserPasswordHash
+	withoutPassword := 0
+	for _, hash := range hashes {
+		if hash.PasswordHash == "" {
+			withoutPassword++
+			continue
+		}
+		status := passwordHashStatus(hasher, hash.PasswordHash)
+		if status == "" {
+			continue
+		}
+		counts[status]++
+		stale = append(stale, hash)
+	}
+
+	out := cmd.OutOrStdout()
+	fmt.Fprintf(out, "Password hashes: users=%d current=%d needs-rehash=%d without-password=%d\n",
+		len(hashes), len(hashes)-len(stale)-withoutPassword, len(stale), withoutPassword)
+	statuses := make([]string, 0, len(counts))
+	for status := range counts {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	for _, status := range statuses {
+		fmt.Fprintf(out, "  %s: %d\n", status, counts[status])
+	}
+	if options.list {
+		for _, hash := range stale {
+			fmt.Fprintf(out, "%s\t%s\n", hash.DN, passwordHashStatus(hasher, hash.PasswordHash))
+		}
+	}
+	return nil
+}
+
+// passwordHashStatus describes why a stored hash needs a rehash, or returns
+// "" for an Argon2id hash with the configured parameters.
+func passwordHashStatus(hasher *crypto.PasswordHasher, passwordHash string) string {
+	switch {
+	case !hasher.NeedsRehash(passwordHash):
+		return ""
+	case strings.HasPrefix(passwordHash, crypto.SchemeArgon2ID):
+		return hashStatusOutdatedArgon2
+	}
+	scheme, _, found := strings.Cut(passwordHash, "}")
+	if !strings.HasPrefix(scheme, "{") || !found {
+		return "unknown scheme"
+	}
+	return strings.ToUpper(scheme) + "}"
+}
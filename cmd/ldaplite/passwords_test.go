@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const legacyCommandImportLDIF = `dn: uid=legacy,ou=users,dc=example,dc=com
+objectClass: inetOrgPerson
+uid: legacy
+cn: Legacy User
+sn: User
+userPassword: {SSHA}1G904nLkTkGWjKNnQuB/hpWXC/hzYWx0c2FsdA==`
+
+func TestImportLDIFRejectsLegacyPasswordHashByDefault(t *testing.T) {
+	setupImportCommandEnv(t)
+	cmd := newImportCommand()
+	cmd.SetArgs([]string{"ldif", "--file", writeImportFixture(t, legacyCommandImportLDIF)})
+
+	err := cmd.Execute()
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported password scheme")
+}
+
+func TestPasswordsReportCountsLegacyAndOutdatedHashes(t *testing.T) {
+	setupImportCommandEnv(t)
+	t.Setenv("LDAP_ALLOW_LEGACY_PASSWORD_HASHES", "true")
+	importCmd := newImportCommand()
+	importCmd.SetArgs([]string{"ldif", "--file", writeImportFixture(t, legacyCommandImportLDIF)})
+	require.NoError(t, importCmd.Execute())
+
+	// The admin password was hashed with the parameters in the environment;
+	// changing them makes it outdated.
+	t.Setenv("LDAP_ARGON2_ITERATIONS", "2")
+	var out bytes.Buffer
+	cmd := newPasswordsCommand()
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"report", "--list"})
+
+	require.NoError(t, cmd.Execute())
+	output := out.String()
+	assert.Contains(t, output, "Password hashes: users=2 current=0 needs-rehash=2 without-password=0")
+	assert.Contains(t, output, "  {ARGON2ID} outdated parameters: 1")
+	assert.Contains(t, output, "  {SSHA}: 1")
+	assert.Contains(t, output, "uid=legacy,ou=users,dc=example,dc=com\t{SSHA}")
+}
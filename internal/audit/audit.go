@@ -22,6 +22,12 @@ const (
 	EventWebAuthorizationDeny = "web.authorization_denied"
 	EventWebSameOriginDeny    = "web.same_origin_denied"
 	EventWebWrite             = "web.write"
+
+	// EventAuthBlocked is logged when failed authentications cross a bind
+	// throttle threshold and EventAuthThrottled for every attempt rejected
+	// while the block lasts.
+	EventAuthBlocked   = "auth.blocked"
+	EventAuthThrottled = "auth.throttled"
 )
 
 var nextRequestID atomic.Uint64
@@ -45,14 +51,17 @@ type LDAPEvent struct {
 	MessageID    int
 	RemoteAddr   string
 	ActorDN      string
-	TargetDN     string
-	BaseDN       string
-	OID          string
-	Scope        string
-	ResultCode   int
-	ResultCount  *int
-	Duration     time.Duration
-	Error        error
+	// BindDN is the identity bound to the connection when the operation
+	// acted as ActorDN through proxied authorization.
+	BindDN      string
+	TargetDN    string
+	BaseDN      string
+	OID         string
+	Scope       string
+	ResultCode  int
+	ResultCount *int
+	Duration    time.Duration
+	Error       error
 }
 
 type WebEvent struct {
@@ -71,6 +80,20 @@ type WebEvent struct {
 	Error      error
 }
 
+// ThrottleEvent describes a bind throttle decision for an account or a
+// client address.
+type ThrottleEvent struct {
+	Event      string
+	Component  string
+	RequestID  string
+	RemoteAddr string
+	Account    string
+	// Scope is "account" or "address", whichever crossed its threshold.
+	Scope      string
+	Failures   int
+	RetryAfter time.Duration
+}
+
 func NewWebRequestID() string {
 	return "http-" + strconv.FormatUint(nextRequestID.Add(1), 10)
 }
@@ -114,6 +137,7 @@ func LogLDAP(ctx context.Context, event LDAPEvent) {
 	}
 	addStringAttr(&attrs, "remote_addr", event.RemoteAddr)
 	addStringAttr(&attrs, "actor_dn", event.ActorDN)
+	addStringAttr(&attrs, "bind_dn", event.BindDN)
 	addStringAttr(&attrs, "target_dn", event.TargetDN)
 	addStringAttr(&attrs, "base_dn", event.BaseDN)
 	addStringAttr(&attrs, "oid", event.OID)
@@ -173,6 +197,29 @@ func LogWeb(ctx context.Context, event WebEvent) {
 	slog.LogAttrs(ctx, webLogLevel(event), "Web audit event", attrs...)
 }
 
+func LogThrottle(ctx context.Context, event ThrottleEvent) {
+	if event.RequestID == "" {
+		event.RequestID = RequestIDFromContext(ctx)
+	}
+
+	attrs := []slog.Attr{
+		slog.String("event", event.Event),
+		slog.String("component", event.Component),
+	}
+	addStringAttr(&attrs, "request_id", event.RequestID)
+	addStringAttr(&attrs, "remote_addr", event.RemoteAddr)
+	addStringAttr(&attrs, "account", event.Account)
+	addStringAttr(&attrs, "scope", event.Scope)
+	if event.Failures > 0 {
+		attrs = append(attrs, slog.Int("failures", event.Failures))
+	}
+	if event.RetryAfter > 0 {
+		attrs = append(attrs, slog.Int64("retry_after_seconds", int64(event.RetryAfter.Seconds())))
+	}
+
+	slog.LogAttrs(ctx, slog.LevelWarn, "Authentication throttle event", attrs...)
+}
+
 func RequestID(connectionID string, messageID int) string {
 	if connectionID == "" || messageID == 0 {
 		return connectionID
@@ -0,0 +1,467 @@
+package authz
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/smarzola/ldaplite/internal/ldapdn"
+	"github.com/smarzola/ldaplite/internal/models"
+	"github.com/smarzola/ldaplite/internal/schema"
+	"github.com/smarzola/ldaplite/pkg/config"
+)
+
+// Permission is an operation on an entry or one of its attributes that
+// access rules allow or deny.
+type Permission string
+
+const (
+	PermissionRead    Permission = "read"    // see attribute values
+	PermissionSearch  Permission = "search"  // find entries and filter on attributes
+	PermissionCompare Permission = "compare" // compare attribute values
+	PermissionWrite   Permission = "write"   // modify attribute values
+	PermissionAdd     Permission = "add"     // create entries
+	PermissionDelete  Permission = "delete"  // delete entries
+)
+
+// readsDirectory reports whether the permission only reads. Reads are
+// allowed by default to anyone the surface already let in; writes need
+// DirectoryWrite.
+func (p Permission) readsDirectory() bool {
+	return p == PermissionRead || p == PermissionSearch || p == PermissionCompare
+}
+
+var permissions = []Permission{
+	PermissionRead,
+	PermissionSearch,
+	PermissionCompare,
+	PermissionWrite,
+	PermissionAdd,
+	PermissionDelete,
+}
+
+// Access rule effects and subjects, as written in config.AccessRule.
+const (
+	EffectAllow = "allow"
+	EffectDeny  = "deny"
+
+	SubjectSelf   = "self"
+	SubjectUsers  = "users"
+	SubjectAnyone = "anyone"
+
+	subjectDNPrefix    = "dn:"
+	subjectGroupPrefix = "group:"
+)
+
+// Reasons recorded in a Decision.
+const (
+	ReasonAdmin   = "admin"   // the admin group is not subject to access rules
+	ReasonRule    = "rule"    // an access rule matched
+	ReasonOwner   = "owner"   // group owners may change members
+	ReasonDefault = "default" // no access rule matched
+)
+
+// Decision is the outcome of an access check.
+type Decision struct {
+	Allowed bool
+	Reason  string
+	// Rule names the deciding access rule when Reason is ReasonRule.
+	Rule string
+}
+
+type accessRule struct {
+	name        string
+	deny        bool
+	subject     string
+	subjectDN   string
+	subtree     string
+	filter      *schema.Filter
+	attributes  map[string]bool
+	permissions map[Permission]bool
+}
+
+// ValidateAccessRules reports the first access rule that cannot be used.
+func ValidateAccessRules(rules []config.AccessRule) error {
+	_, err := compileAccessRules(rules)
+	return err
+}
+
+func compileAccessRules(rules []config.AccessRule) ([]accessRule, error) {
+	compiled := make([]accessRule, 0, len(rules))
+	for i, rule := range rules {
+		compiledRule, err := compileAccessRule(rule)
+		if err != nil {
+			name := rule.Name
+			if name == "" {
+				name = fmt.Sprintf("#%d", i+1)
+			}
+			return nil, fmt.Errorf("access rule %s: %w", name, err)
+		}
+		compiled = append(compiled, compiledRule)
+	}
+	return compiled, nil
+}
+
+func compileAccessRule(rule config.AccessRule) (accessRule, error) {
+	compiled := accessRule{
+		name:    rule.Name,
+		subtree: strings.TrimSpace(rule.Subtree),
+	}
+
+	switch strings.ToLower(strings.TrimSpace(rule.Effect)) {
+	case "", EffectAllow:
+	case EffectDeny:
+		compiled.deny = true
+	default:
+		return accessRule{}, fmt.Errorf("effect must be %q or %q", EffectAllow, EffectDeny)
+	}
+
+	subject := strings.TrimSpace(rule.Subject)
+	switch lower := strings.ToLower(subject); {
+	case lower == SubjectSelf, lower == SubjectUsers, lower == SubjectAnyone:
+		compiled.subject = lower
+	case strings.HasPrefix(lower, subjectDNPrefix), strings.HasPrefix(lower, subjectGroupPrefix):
+		prefix, dn, _ := strings.Cut(subject, ":")
+		if strings.TrimSpace(dn) == "" {
+			return accessRule{}, fmt.Errorf("subject %q has no DN", subject)
+		}
+		compiled.subject = strings.ToLower(prefix) + ":"
+		compiled.subjectDN = strings.TrimSpace(dn)
+	default:
+		return accessRule{}, fmt.Errorf("subject must be dn:<dn>, group:<dn>, %s, %s or %s", SubjectSelf, SubjectUsers, SubjectAnyone)
+	}
+
+	if rule.Filter != "" {
+		filter, err := schema.ParseFilter(rule.Filter)
+		if err != nil {
+			return accessRule{}, fmt.Errorf("invalid filter: %w", err)
+		}
+		// Entries are not always loaded with their memberOf values, so a
+		// rule could silently stop matching. Group subjects cover this.
+		if schema.FilterUsesComputedAttributes(filter) || usesInChain(filter) {
+			return accessRule{}, fmt.Errorf("filter cannot test memberOf or group membership; use a group subject")
+		}
+		compiled.filter = filter
+	}
+
+	if len(rule.Attributes) > 0 {
+		compiled.attributes = make(map[string]bool, len(rule.Attributes))
+		for _, name := range rule.Attributes {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "" {
+				return accessRule{}, fmt.Errorf("attribute names cannot be empty")
+			}
+			compiled.attributes[name] = true
+		}
+	}
+
+	if len(rule.Permissions) == 0 {
+		return accessRule{}, fmt.Errorf("at least one permission is required")
+	}
+	compiled.permissions = make(map[Permission]bool, len(rule.Permissions))
+	for _, name := range rule.Permissions {
+		permission := Permission(strings.ToLower(strings.TrimSpace(name)))
+		if !slices.Contains(permissions, permission) {
+			return accessRule{}, fmt.Errorf("unknown permission %q", name)
+		}
+		compiled.permissions[permission] = true
+	}
+	return compiled, nil
+}
+
+func usesInChain(filter *schema.Filter) bool {
+	if filter == nil {
+		return false
+	}
+	if filter.IsInChain() {
+		return true
+	}
+	return slices.ContainsFunc(filter.Filters, usesInChain)
+}
+
+// HasAccessRules reports whether any access rules are configured. Without
+// them every check gives the built-in answer, which callers may use to skip
+// per-entry work.
+func (a *Authorizer) HasAccessRules() bool {
+	return a != nil && (len(a.rules) > 0 || a.rulesErr != nil)
+}
+
+// Check decides whether actor has permission on entry, or on one of its
+// attributes when attribute is not empty. Members of the admin group are
+// allowed everything. Otherwise the first matching access rule decides and,
+// when none matches, reads are allowed, owners of a group may change its
+// members and other writes need DirectoryWrite, or UsersCreate to add a
+// user, on an entry inside the actor's administrative scope.
+func (a *Authorizer) Check(ctx context.Context, actor Actor, entry *models.Entry, attribute string, permission Permission) (Decision, error) {
+	if a.rulesErr != nil {
+		return Decision{}, a.rulesErr
+	}
+	if len(a.rules) == 0 && permission.readsDirectory() {
+		return Decision{Allowed: true, Reason: ReasonDefault}, nil
+	}
+	// Anonymous binds never write, whatever the rules say.
+	if !permission.readsDirectory() && !actor.authenticated() {
+		return Decision{Reason: ReasonDefault}, nil
+	}
+
+	if actor.authenticated() {
+		isAdmin, err := a.IsAdmin(ctx, actor.DN)
+		if err != nil {
+			return Decision{}, err
+		}
+		if isAdmin {
+			return Decision{Allowed: true, Reason: ReasonAdmin}, nil
+		}
+	}
+
+	for _, rule := range a.rules {
+		matched, err := a.matches(ctx, rule, actor, entry, attribute, permission)
+		if err != nil {
+			return Decision{}, err
+		}
+		if matched {
+			return Decision{Allowed: !rule.deny, Reason: ReasonRule, Rule: rule.name}, nil
+		}
+	}
+
+	if permission.readsDirectory() {
+		return Decision{Allowed: true, Reason: ReasonDefault}, nil
+	}
+	owner, err := a.ownerMayWrite(ctx, actor, entry, attribute, permission)
+	if err != nil {
+		return Decision{}, err
+	}
+	if owner {
+		return Decision{Allowed: true, Reason: ReasonOwner}, nil
+	}
+	capability := DirectoryWrite
+	if permission == PermissionAdd && entry.IsUser() {
+		capability = UsersCreate
+	}
+	capabilities, err := a.Capabilities(ctx, actor)
+	if err != nil {
+		return Decision{}, err
+	}
+	if !capabilities.Has(capability) {
+		return Decision{Reason: ReasonDefault}, nil
+	}
+	allowed, err := a.Allows(ctx, actor, capability, entry.DN)
+	if err != nil {
+		return Decision{}, err
+	}
+	return Decision{Allowed: allowed, Reason: ReasonDefault}, nil
+}
+
+// Allowed is Check without the explanation.
+func (a *Authorizer) Allowed(ctx context.Context, actor Actor, entry *models.Entry, attribute string, permission Permission) (bool, error) {
+	decision, err := a.Check(ctx, actor, entry, attribute, permission)
+	return decision.Allowed, err
+}
+
+// CanSearch reports whether a search by actor may return entry. It needs the
+// search permission on the entry and on every attribute the filter tests, so
+// a filter cannot probe values the actor is not allowed to search on.
+func (a *Authorizer) CanSearch(ctx context.Context, actor Actor, entry *models.Entry, filter *schema.Filter) (bool, error) {
+	allowed, err := a.Allowed(ctx, actor, entry, "", PermissionSearch)
+	if err != nil || !allowed {
+		return false, err
+	}
+	if filter == nil {
+		return true, nil
+	}
+	for _, name := range filter.Attributes() {
+		allowed, err := a.Allowed(ctx, actor, entry, name, PermissionSearch)
+		if err != nil || !allowed {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// VisibleEntries returns the entries a search by actor with filter may
+// return, with only the attributes actor may read. An empty filter matches
+// every entry.
+func (a *Authorizer) VisibleEntries(ctx context.Context, actor Actor, entries []*models.Entry, filter string) ([]*models.Entry, error) {
+	if !a.HasAccessRules() {
+		return entries, nil
+	}
+	parsed, err := schema.ParseFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	visible := make([]*models.Entry, 0, len(entries))
+	for _, entry := range entries {
+		allowed, err := a.CanSearch(ctx, actor, entry, parsed)
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			continue
+		}
+		redacted, err := a.Redact(ctx, actor, entry)
+		if err != nil {
+			return nil, err
+		}
+		visible = append(visible, redacted)
+	}
+	return visible, nil
+}
+
+// Redact returns entry with only the attributes actor may read. Without
+// access rules it returns entry itself.
+func (a *Authorizer) Redact(ctx context.Context, actor Actor, entry *models.Entry) (*models.Entry, error) {
+	if !a.HasAccessRules() {
+		return entry, nil
+	}
+	redacted := *entry
+	var err error
+	if redacted.Attributes, err = a.readableAttributes(ctx, actor, entry, entry.Attributes); err != nil {
+		return nil, err
+	}
+	if redacted.ComputedAttributes, err = a.readableAttributes(ctx, actor, entry, entry.ComputedAttributes); err != nil {
+		return nil, err
+	}
+	return &redacted, nil
+}
+
+func (a *Authorizer) readableAttributes(ctx context.Context, actor Actor, entry *models.Entry, attributes map[string][]string) (map[string][]string, error) {
+	if attributes == nil {
+		return nil, nil
+	}
+	readable := make(map[string][]string, len(attributes))
+	for name, values := range attributes {
+		allowed, err := a.Allowed(ctx, actor, entry, name, PermissionRead)
+		if err != nil {
+			return nil, err
+		}
+		if allowed {
+			readable[name] = values
+		}
+	}
+	return readable, nil
+}
+
+func (a *Authorizer) matches(ctx context.Context, rule accessRule, actor Actor, entry *models.Entry, attribute string, permission Permission) (bool, error) {
+	if !rule.permissions[permission] || !rule.coversAttribute(attribute) {
+		return false, nil
+	}
+	if rule.subtree != "" && !ldapdn.WithinBase(entry.DN, rule.subtree) {
+		return false, nil
+	}
+	if rule.filter != nil && !rule.filter.Matches(entry) {
+		return false, nil
+	}
+
+	switch rule.subject {
+	case SubjectAnyone:
+		return true, nil
+	case SubjectUsers:
+		return actor.authenticated(), nil
+	case SubjectSelf:
+		return actor.authenticated() && ldapdn.Equal(actor.DN, entry.DN), nil
+	case subjectDNPrefix:
+		return actor.authenticated() && ldapdn.Equal(actor.DN, rule.subjectDN), nil
+	case subjectGroupPrefix:
+		if !actor.authenticated() {
+			return false, nil
+		}
+		return a.isMember(ctx, actor.DN, rule.subjectDN)
+	}
+	return false, nil
+}
+
+// coversAttribute reports whether the rule applies to checks on attribute,
+// or on the entry as a whole when attribute is empty. Rules limited to
+// attributes do not cover the entry as a whole.
+func (rule accessRule) coversAttribute(attribute string) bool {
+	if attribute == "" {
+		return rule.attributes == nil
+	}
+	return rule.attributes == nil || rule.attributes[strings.ToLower(attribute)]
+}
+
+// SearchRestrictions tells a search which entries access rules may hide
+// from its actor, so the store can drop them instead of the caller checking
+// every entry.
+type SearchRestrictions struct {
+	// HiddenSubtrees are subtrees in which actor may find no entry.
+	HiddenSubtrees []string
+	// CheckEntries is set when entries outside HiddenSubtrees may be hidden
+	// too, so each one still needs CanSearch.
+	CheckEntries bool
+}
+
+// SearchRestrictions works out from the rules alone where CanSearch may deny
+// actor a search with filter. Deny rules without a filter that apply to
+// actor before any rule that may allow the search become HiddenSubtrees;
+// any other deny rule that may apply sets CheckEntries.
+func (a *Authorizer) SearchRestrictions(ctx context.Context, actor Actor, filter *schema.Filter) (SearchRestrictions, error) {
+	var restrictions SearchRestrictions
+	if !a.HasAccessRules() {
+		return restrictions, nil
+	}
+	if a.rulesErr != nil {
+		return restrictions, a.rulesErr
+	}
+	if actor.authenticated() {
+		isAdmin, err := a.IsAdmin(ctx, actor.DN)
+		if err != nil || isAdmin {
+			return restrictions, err
+		}
+	}
+
+	attributes := []string{""}
+	if filter != nil {
+		attributes = append(attributes, filter.Attributes()...)
+	}
+	hiding := true
+	for _, rule := range a.rules {
+		if !rule.permissions[PermissionSearch] || !slices.ContainsFunc(attributes, rule.coversAttribute) {
+			continue
+		}
+		applies, perEntry, err := a.subjectApplies(ctx, rule, actor)
+		if err != nil {
+			return SearchRestrictions{}, err
+		}
+		if !applies {
+			continue
+		}
+		if !rule.deny {
+			// Later rules no longer decide alone for the entries this one
+			// matches.
+			hiding = false
+			continue
+		}
+		if hiding && rule.filter == nil && !perEntry {
+			restrictions.HiddenSubtrees = append(restrictions.HiddenSubtrees, cmp.Or(rule.subtree, a.baseDN))
+			continue
+		}
+		restrictions.CheckEntries = true
+		return restrictions, nil
+	}
+	return restrictions, nil
+}
+
+// subjectApplies reports whether the subject of rule may match actor.
+// perEntry is set when that also depends on the entry, as for self.
+func (a *Authorizer) subjectApplies(ctx context.Context, rule accessRule, actor Actor) (applies, perEntry bool, err error) {
+	switch rule.subject {
+	case SubjectAnyone:
+		return true, false, nil
+	case SubjectUsers:
+		return actor.authenticated(), false, nil
+	case SubjectSelf:
+		return actor.authenticated(), true, nil
+	case subjectDNPrefix:
+		return actor.authenticated() && ldapdn.Equal(actor.DN, rule.subjectDN), false, nil
+	case subjectGroupPrefix:
+		if !actor.authenticated() {
+			return false, false, nil
+		}
+		isMember, err := a.isMember(ctx, actor.DN, rule.subjectDN)
+		return isMember, false, err
+	}
+	return false, false, nil
+}
@@ -0,0 +1,375 @@
+package authz
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/smarzola/ldaplite/internal/models"
+	"github.com/smarzola/ldaplite/internal/schema"
+	"github.com/smarzola/ldaplite/pkg/config"
+)
+
+const testHelpdeskGroupDN = "cn=helpdesk,ou=groups,dc=example,dc=com"
+
+func testAccessRules() []config.AccessRule {
+	return []config.AccessRule{
+		{
+			Name:        "own-contact-details",
+			Subject:     "self",
+			Attributes:  []string{"mobile", "homePostalAddress"},
+			Permissions: []string{"read", "search", "compare", "write"},
+		},
+		{
+			Name:        "helpdesk-contact-details",
+			Subject:     "group:" + testHelpdeskGroupDN,
+			Subtree:     "ou=users,dc=example,dc=com",
+			Attributes:  []string{"mobile"},
+			Permissions: []string{"read", "write"},
+		},
+		{
+			Name:        "private-contact-details",
+			Effect:      "deny",
+			Subject:     "anyone",
+			Attributes:  []string{"mobile", "homePostalAddress"},
+			Permissions: []string{"read", "search", "compare"},
+		},
+		{
+			Name:        "hidden-service-accounts",
+			Effect:      "deny",
+			Subject:     "users",
+			Filter:      "(employeeType=service)",
+			Permissions: []string{"search"},
+		},
+		{
+			Name:        "open-descriptions",
+			Subject:     "anyone",
+			Attributes:  []string{"description"},
+			Permissions: []string{"write"},
+		},
+		{
+			Name:        "contractor-onboarding",
+			Subject:     "dn:" + testUserDN,
+			Subtree:     "ou=contractors,dc=example,dc=com",
+			Permissions: []string{"add", "delete"},
+		},
+	}
+}
+
+func testAccessAuthorizer(t *testing.T, store *membershipStore) *Authorizer {
+	t.Helper()
+	cfg := &config.Config{
+		LDAP: config.LDAPConfig{BaseDN: testBaseDN},
+		Authz: config.AuthzConfig{
+			AccessRules: testAccessRules(),
+			DelegatedAdmins: []config.DelegatedAdmin{{
+				GroupDN:  testEMEAAdminsDN,
+				Subtrees: []string{"ou=contractors,dc=example,dc=com"},
+			}},
+		},
+	}
+	authorizer := FromConfig(cfg, store)
+	if authorizer.rulesErr != nil {
+		t.Fatalf("FromConfig() rules error = %v", authorizer.rulesErr)
+	}
+	return authorizer
+}
+
+func testEntry(dn string, attributes map[string][]string) *models.Entry {
+	entry := models.NewEntry(dn, string(models.ObjectClassInetOrgPerson))
+	for name, values := range attributes {
+		entry.SetAttributes(name, values)
+	}
+	return entry
+}
+
+func TestCheckAccessRules(t *testing.T) {
+	jane := testEntry(testUserDN, map[string][]string{"mobile": {"555-0100"}})
+	bob := testEntry("uid=bob,ou=users,dc=example,dc=com", map[string][]string{"mobile": {"555-0101"}})
+	service := testEntry("uid=sync,ou=services,dc=example,dc=com", map[string][]string{"employeeType": {"service"}})
+	contractor := testEntry("uid=temp,ou=contractors,dc=example,dc=com", nil)
+
+	tests := []struct {
+		name       string
+		actor      Actor
+		groups     map[string]map[string]bool
+		entry      *models.Entry
+		attribute  string
+		permission Permission
+		want       Decision
+	}{
+		{
+			name:       "self reads own mobile",
+			actor:      BoundUser(testUserDN),
+			entry:      jane,
+			attribute:  "Mobile",
+			permission: PermissionRead,
+			want:       Decision{Allowed: true, Reason: ReasonRule, Rule: "own-contact-details"},
+		},
+		{
+			name:       "other users cannot read mobile",
+			actor:      BoundUser(testUserDN),
+			entry:      bob,
+			attribute:  "mobile",
+			permission: PermissionRead,
+			want:       Decision{Reason: ReasonRule, Rule: "private-contact-details"},
+		},
+		{
+			name:       "anonymous cannot read mobile",
+			actor:      Actor{Bound: true},
+			entry:      bob,
+			attribute:  "mobile",
+			permission: PermissionRead,
+			want:       Decision{Reason: ReasonRule, Rule: "private-contact-details"},
+		},
+		{
+			name:  "helpdesk group writes mobile in subtree",
+			actor: BoundUser(testReadOnlyDN),
+			groups: membershipMap(testReadOnlyDN, map[string]bool{
+				testHelpdeskGroupDN: true,
+			}),
+			entry:      bob,
+			attribute:  "mobile",
+			permission: PermissionWrite,
+			want:       Decision{Allowed: true, Reason: ReasonRule, Rule: "helpdesk-contact-details"},
+		},
+		{
+			name:       "unlisted attributes use defaults",
+			actor:      BoundUser(testUserDN),
+			entry:      bob,
+			attribute:  "cn",
+			permission: PermissionRead,
+			want:       Decision{Allowed: true, Reason: ReasonDefault},
+		},
+		{
+			name:       "writes default to denied",
+			actor:      BoundUser(testUserDN),
+			entry:      bob,
+			attribute:  "cn",
+			permission: PermissionWrite,
+			want:       Decision{Reason: ReasonDefault},
+		},
+		{
+			name:       "filter hides matching entries",
+			actor:      BoundUser(testUserDN),
+			entry:      service,
+			permission: PermissionSearch,
+			want:       Decision{Reason: ReasonRule, Rule: "hidden-service-accounts"},
+		},
+		{
+			name:       "attribute rules do not cover the entry",
+			actor:      BoundUser(testUserDN),
+			entry:      bob,
+			permission: PermissionSearch,
+			want:       Decision{Allowed: true, Reason: ReasonDefault},
+		},
+		{
+			name:       "dn subject adds in subtree",
+			actor:      BoundUser(testUserDN),
+			entry:      contractor,
+			permission: PermissionAdd,
+			want:       Decision{Allowed: true, Reason: ReasonRule, Rule: "contractor-onboarding"},
+		},
+		{
+			name:       "dn subject cannot add outside subtree",
+			actor:      BoundUser(testUserDN),
+			entry:      bob,
+			permission: PermissionAdd,
+			want:       Decision{Reason: ReasonDefault},
+		},
+		{
+			name:       "anonymous binds never write",
+			actor:      Actor{Bound: true},
+			entry:      bob,
+			attribute:  "description",
+			permission: PermissionWrite,
+			want:       Decision{Reason: ReasonDefault},
+		},
+		{
+			name:  "delegated admin writes inside subtree",
+			actor: BoundUser(testReadOnlyDN),
+			groups: membershipMap(testReadOnlyDN, map[string]bool{
+				testEMEAAdminsDN: true,
+			}),
+			entry:      contractor,
+			attribute:  "cn",
+			permission: PermissionWrite,
+			want:       Decision{Allowed: true, Reason: ReasonDefault},
+		},
+		{
+			name:  "delegated admin cannot write outside subtree",
+			actor: BoundUser(testReadOnlyDN),
+			groups: membershipMap(testReadOnlyDN, map[string]bool{
+				testEMEAAdminsDN: true,
+			}),
+			entry:      bob,
+			attribute:  "cn",
+			permission: PermissionWrite,
+			want:       Decision{Reason: ReasonDefault},
+		},
+		{
+			name:  "admin bypasses rules",
+			actor: BoundUser(testAdminDN),
+			groups: membershipMap(testAdminDN, map[string]bool{
+				"cn=ldaplite.admin,ou=groups,dc=example,dc=com": true,
+			}),
+			entry:      bob,
+			attribute:  "mobile",
+			permission: PermissionRead,
+			want:       Decision{Allowed: true, Reason: ReasonAdmin},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authorizer := testAccessAuthorizer(t, &membershipStore{groups: tt.groups})
+
+			got, err := authorizer.Check(context.Background(), tt.actor, tt.entry, tt.attribute, tt.permission)
+			if err != nil {
+				t.Fatalf("Check() error = %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("Check() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckWithoutRulesSkipsMembershipForReads(t *testing.T) {
+	store := &membershipStore{}
+	authorizer := FromConfig(&config.Config{LDAP: config.LDAPConfig{BaseDN: testBaseDN}}, store)
+	entry := testEntry(testUserDN, nil)
+
+	allowed, err := authorizer.Allowed(context.Background(), BoundUser(testUserDN), entry, "mobile", PermissionRead)
+	if err != nil || !allowed {
+		t.Fatalf("Allowed() = %v, %v; want true", allowed, err)
+	}
+	if store.checks != 0 {
+		t.Fatalf("membership checks = %d, want 0", store.checks)
+	}
+	redacted, err := authorizer.Redact(context.Background(), BoundUser(testUserDN), entry)
+	if err != nil || redacted != entry {
+		t.Fatalf("Redact() = %p, %v; want the entry itself", redacted, err)
+	}
+}
+
+func TestCanSearchChecksFilterAttributes(t *testing.T) {
+	authorizer := testAccessAuthorizer(t, &membershipStore{})
+	bob := testEntry("uid=bob,ou=users,dc=example,dc=com", map[string][]string{"mobile": {"555-0101"}})
+	ctx := context.Background()
+
+	byUID, _ := schema.ParseFilter("(uid=bob)")
+	allowed, err := authorizer.CanSearch(ctx, BoundUser(testUserDN), bob, byUID)
+	if err != nil || !allowed {
+		t.Fatalf("CanSearch(uid) = %v, %v; want true", allowed, err)
+	}
+
+	byMobile, _ := schema.ParseFilter("(&(uid=bob)(mobile=555*))")
+	allowed, err = authorizer.CanSearch(ctx, BoundUser(testUserDN), bob, byMobile)
+	if err != nil || allowed {
+		t.Fatalf("CanSearch(mobile) = %v, %v; want false", allowed, err)
+	}
+}
+
+func TestSearchRestrictions(t *testing.T) {
+	hideArchive := config.AccessRule{Name: "hide-archive", Effect: "deny", Subject: "users", Subtree: "ou=archive,dc=example,dc=com", Permissions: []string{"search"}}
+	hideService := config.AccessRule{Name: "hide-service", Effect: "deny", Subject: "users", Filter: "(employeeType=service)", Permissions: []string{"search"}}
+	helpdeskSearch := config.AccessRule{Name: "helpdesk-search", Subject: "group:" + testHelpdeskGroupDN, Permissions: []string{"search"}}
+	hideMobile := config.AccessRule{Name: "hide-mobile", Effect: "deny", Subject: "anyone", Attributes: []string{"mobile"}, Permissions: []string{"search"}}
+	hideAll := config.AccessRule{Name: "hide-all", Effect: "deny", Subject: "anyone", Permissions: []string{"search"}}
+
+	tests := []struct {
+		name   string
+		rules  []config.AccessRule
+		groups map[string]bool
+		filter string
+		want   SearchRestrictions
+	}{
+		{name: "leading deny hides its subtree", rules: []config.AccessRule{hideArchive}, filter: "(uid=*)", want: SearchRestrictions{HiddenSubtrees: []string{"ou=archive,dc=example,dc=com"}}},
+		{name: "deny without subtree hides the directory", rules: []config.AccessRule{hideAll}, filter: "(uid=*)", want: SearchRestrictions{HiddenSubtrees: []string{testBaseDN}}},
+		{name: "deny with a filter needs entry checks", rules: []config.AccessRule{hideArchive, hideService}, filter: "(uid=*)", want: SearchRestrictions{HiddenSubtrees: []string{"ou=archive,dc=example,dc=com"}, CheckEntries: true}},
+		{name: "deny after an allow needs entry checks", rules: []config.AccessRule{helpdeskSearch, hideArchive}, groups: map[string]bool{testHelpdeskGroupDN: true}, filter: "(uid=*)", want: SearchRestrictions{CheckEntries: true}},
+		{name: "allow for someone else does not shield", rules: []config.AccessRule{helpdeskSearch, hideArchive}, filter: "(uid=*)", want: SearchRestrictions{HiddenSubtrees: []string{"ou=archive,dc=example,dc=com"}}},
+		{name: "attribute deny outside the filter is ignored", rules: []config.AccessRule{hideMobile}, filter: "(uid=*)", want: SearchRestrictions{}},
+		{name: "attribute deny on a filter attribute hides everything", rules: []config.AccessRule{hideMobile}, filter: "(mobile=555*)", want: SearchRestrictions{HiddenSubtrees: []string{testBaseDN}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				LDAP:  config.LDAPConfig{BaseDN: testBaseDN},
+				Authz: config.AuthzConfig{AccessRules: tt.rules},
+			}
+			authorizer := FromConfig(cfg, &membershipStore{groups: membershipMap(testUserDN, tt.groups)})
+			filter, err := schema.ParseFilter(tt.filter)
+			if err != nil {
+				t.Fatalf("ParseFilter() error = %v", err)
+			}
+			got, err := authorizer.SearchRestrictions(context.Background(), BoundUser(testUserDN), filter)
+			if err != nil {
+				t.Fatalf("SearchRestrictions() error = %v", err)
+			}
+			if strings.Join(got.HiddenSubtrees, ";") != strings.Join(tt.want.HiddenSubtrees, ";") || got.CheckEntries != tt.want.CheckEntries {
+				t.Fatalf("SearchRestrictions() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactRemovesUnreadableAttributes(t *testing.T) {
+	authorizer := testAccessAuthorizer(t, &membershipStore{})
+	bob := testEntry("uid=bob,ou=users,dc=example,dc=com", map[string][]string{
+		"cn":     {"Bob"},
+		"mobile": {"555-0101"},
+	})
+	bob.SetComputedAttributes("memberOf", []string{testHelpdeskGroupDN})
+
+	redacted, err := authorizer.Redact(context.Background(), BoundUser(testUserDN), bob)
+	if err != nil {
+		t.Fatalf("Redact() error = %v", err)
+	}
+	if redacted.HasAttribute("mobile") || !redacted.HasAttribute("cn") || !redacted.HasAttribute("memberOf") {
+		t.Fatalf("Redact() attributes = %v %v", redacted.Attributes, redacted.ComputedAttributes)
+	}
+	if !bob.HasAttribute("mobile") {
+		t.Fatal("Redact() modified the original entry")
+	}
+}
+
+func TestValidateAccessRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    config.AccessRule
+		wantErr string
+	}{
+		{name: "unknown effect", rule: config.AccessRule{Effect: "maybe", Subject: "self", Permissions: []string{"read"}}, wantErr: "effect"},
+		{name: "unknown subject", rule: config.AccessRule{Subject: "everyone", Permissions: []string{"read"}}, wantErr: "subject"},
+		{name: "subject without dn", rule: config.AccessRule{Subject: "group: ", Permissions: []string{"read"}}, wantErr: "no DN"},
+		{name: "invalid filter", rule: config.AccessRule{Subject: "self", Filter: "uid=jane", Permissions: []string{"read"}}, wantErr: "invalid filter"},
+		{name: "memberOf filter", rule: config.AccessRule{Subject: "self", Filter: "(memberOf=cn=a,dc=example,dc=com)", Permissions: []string{"read"}}, wantErr: "group subject"},
+		{name: "no permissions", rule: config.AccessRule{Subject: "self"}, wantErr: "permission is required"},
+		{name: "unknown permission", rule: config.AccessRule{Subject: "self", Permissions: []string{"rename"}}, wantErr: "unknown permission"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateAccessRules([]config.AccessRule{tt.rule})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("ValidateAccessRules() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	if err := ValidateAccessRules(testAccessRules()); err != nil {
+		t.Fatalf("ValidateAccessRules() error = %v", err)
+	}
+}
+
+func TestInvalidRulesFailChecks(t *testing.T) {
+	cfg := &config.Config{Authz: config.AuthzConfig{AccessRules: []config.AccessRule{{Subject: "nobody"}}}}
+	authorizer := FromConfig(cfg, &membershipStore{})
+
+	if _, err := authorizer.Check(context.Background(), BoundUser(testUserDN), testEntry(testUserDN, nil), "", PermissionSearch); err == nil {
+		t.Fatal("Check() error = nil, want the rule error")
+	}
+}
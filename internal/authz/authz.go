@@ -3,6 +3,10 @@ package authz
 import (
 	"context"
 	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/smarzola/ldaplite/pkg/config"
 )
 
 // Capability is a coarse LDAPLite permission used across LDAP, Web UI, and
@@ -17,6 +21,11 @@ const (
 	PasswordResetAny      Capability = "password.resetAny"
 	UIRead                Capability = "ui.read"
 	UIAdmin               Capability = "ui.admin"
+	SCIMWrite             Capability = "scim.write"
+	UsersCreate           Capability = "users.create"
+	// DirectoryProxy lets a bound service act as another identity with the
+	// proxied authorization control (RFC 4370).
+	DirectoryProxy Capability = "directory.proxy"
 )
 
 type Set map[Capability]struct{}
@@ -49,22 +58,60 @@ func BoundUser(dn string) Actor {
 	return Actor{DN: dn, Bound: true}
 }
 
+// authenticated reports whether the actor bound as a user rather than
+// anonymously.
+func (a Actor) authenticated() bool {
+	return a.Bound && a.DN != ""
+}
+
 type MembershipStore interface {
 	IsUserInGroup(ctx context.Context, userDN, groupDN string) (bool, error)
 }
 
+// Authorizer answers authorization questions for one request. It remembers
+// group membership lookups, so it should not outlive the request.
 type Authorizer struct {
-	baseDN string
-	store  MembershipStore
+	baseDN   string
+	store    MembershipStore
+	rules    []accessRule
+	rulesErr error
+	roles    []role
+	rolesErr error
+
+	mu          sync.Mutex
+	memberships map[membershipKey]bool
+}
+
+type membershipKey struct {
+	userDN  string
+	groupDN string
 }
 
 func New(baseDN string, store MembershipStore) *Authorizer {
 	return &Authorizer{
-		baseDN: baseDN,
-		store:  store,
+		baseDN:      baseDN,
+		store:       store,
+		memberships: make(map[membershipKey]bool),
 	}
 }
 
+// FromConfig returns an Authorizer for the configured base DN, roles and
+// access rules. Roles and rules are validated at startup; if they are
+// invalid anyway, every check that needs them fails.
+func FromConfig(cfg *config.Config, store MembershipStore) *Authorizer {
+	if cfg == nil {
+		return New("", store)
+	}
+	a := New(cfg.LDAP.BaseDN, store)
+	a.rules, a.rulesErr = compileAccessRules(cfg.Authz.AccessRules)
+	a.roles, a.rolesErr = compileRoles(cfg.Authz.Roles, cfg.Authz.DelegatedAdmins)
+	return a
+}
+
+// Capabilities returns everything actor may do somewhere in the directory:
+// all capabilities for members of the admin group, otherwise the built-in
+// capabilities of their group plus those of every role they hold. Allows
+// tells whether a capability applies to a given entry.
 func (a *Authorizer) Capabilities(ctx context.Context, actor Actor) (Set, error) {
 	if !actor.Bound || actor.DN == "" {
 		return NewSet(), nil
@@ -75,26 +122,28 @@ func (a *Authorizer) Capabilities(ctx context.Context, actor Actor) (Set, error)
 		return nil, err
 	}
 	if isAdmin {
-		return NewSet(
-			DirectoryRead,
-			DirectoryWrite,
-			DirectoryManageGroups,
-			PasswordChangeSelf,
-			PasswordResetAny,
-			UIRead,
-			UIAdmin,
-		), nil
+		return adminCapabilities(), nil
+	}
+
+	roles, err := a.heldRoles(ctx, actor.DN)
+	if err != nil {
+		return nil, err
 	}
 
 	isPasswordOnly, err := a.isMember(ctx, actor.DN, a.PasswordGroupDN())
 	if err != nil {
 		return nil, err
 	}
-	if isPasswordOnly {
-		return NewSet(PasswordChangeSelf, UIRead), nil
+	capabilities := NewSet(PasswordChangeSelf, UIRead)
+	if !isPasswordOnly {
+		capabilities.Add(DirectoryRead)
 	}
-
-	return NewSet(DirectoryRead, PasswordChangeSelf, UIRead), nil
+	for _, r := range roles {
+		for capability := range r.capabilities {
+			capabilities.Add(capability)
+		}
+	}
+	return capabilities, nil
 }
 
 func (a *Authorizer) IsAdmin(ctx context.Context, userDN string) (bool, error) {
@@ -105,12 +154,129 @@ func (a *Authorizer) IsExplicitReadOnly(ctx context.Context, userDN string) (boo
 	return a.isMember(ctx, userDN, a.ReadOnlyGroupDN())
 }
 
-func (a *Authorizer) CanWrite(ctx context.Context, actor Actor) (bool, error) {
-	capabilities, err := a.Capabilities(ctx, actor)
+func adminCapabilities() Set {
+	return NewSet(
+		DirectoryRead,
+		DirectoryWrite,
+		DirectoryManageGroups,
+		PasswordChangeSelf,
+		PasswordResetAny,
+		UIRead,
+		UIAdmin,
+		SCIMWrite,
+		UsersCreate,
+		DirectoryProxy,
+	)
+}
+
+// delegatedAdminCapabilities are the capabilities of delegated administrators
+// inside their subtrees: those of admins without DirectoryProxy, which only an
+// explicit role grants.
+func delegatedAdminCapabilities() Set {
+	capabilities := adminCapabilities()
+	delete(capabilities, DirectoryProxy)
+	return capabilities
+}
+
+// Allows reports whether actor holds capability for the entry at targetDN:
+// members of the admin group hold every capability everywhere, role holders
+// inside the role's subtrees. It is meant for the capabilities roles grant
+// over entries; the built-in capabilities of ordinary users do not count.
+func (a *Authorizer) Allows(ctx context.Context, actor Actor, capability Capability, targetDN string) (bool, error) {
+	if !actor.authenticated() {
+		return false, nil
+	}
+	isAdmin, err := a.IsAdmin(ctx, actor.DN)
+	if err != nil || isAdmin {
+		return isAdmin, err
+	}
+	roles, err := a.heldRoles(ctx, actor.DN)
 	if err != nil {
 		return false, err
 	}
-	return capabilities.Has(DirectoryWrite), nil
+	for _, r := range roles {
+		if r.capabilities.Has(capability) && r.covers(targetDN) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Covers reports whether actor holds every capability other holds, over at
+// least the entries other holds it for, so acting as other cannot widen what
+// actor may do.
+func (a *Authorizer) Covers(ctx context.Context, actor, other Actor) (bool, error) {
+	actorCapabilities, err := a.Capabilities(ctx, actor)
+	if err != nil {
+		return false, err
+	}
+	otherCapabilities, err := a.Capabilities(ctx, other)
+	if err != nil {
+		return false, err
+	}
+	for capability := range otherCapabilities {
+		if !actorCapabilities.Has(capability) {
+			return false, nil
+		}
+	}
+
+	roles, err := a.heldRoles(ctx, other.DN)
+	if err != nil {
+		return false, err
+	}
+	for _, r := range roles {
+		subtrees := r.subtrees
+		if subtrees == nil {
+			subtrees = []string{a.baseDN}
+		}
+		for capability := range r.capabilities {
+			for _, subtree := range subtrees {
+				allowed, err := a.Allows(ctx, actor, capability, subtree)
+				if err != nil || !allowed {
+					return false, err
+				}
+			}
+		}
+	}
+	return true, nil
+}
+
+// CanWrite reports whether actor may administer the entry at targetDN.
+// Members of the admin group may write anywhere, delegated administrators
+// and other roles with DirectoryWrite only inside their subtrees.
+func (a *Authorizer) CanWrite(ctx context.Context, actor Actor, targetDN string) (bool, error) {
+	return a.Allows(ctx, actor, DirectoryWrite, targetDN)
+}
+
+// AdminScopes returns the subtrees actor may administer: the base DN for
+// members of the admin group and for roles with DirectoryWrite over the
+// whole directory, the role subtrees otherwise and none for everyone else.
+func (a *Authorizer) AdminScopes(ctx context.Context, actor Actor) ([]string, error) {
+	if !actor.authenticated() {
+		return nil, nil
+	}
+	isAdmin, err := a.IsAdmin(ctx, actor.DN)
+	if err != nil {
+		return nil, err
+	}
+	if isAdmin {
+		return []string{a.baseDN}, nil
+	}
+	roles, err := a.heldRoles(ctx, actor.DN)
+	if err != nil {
+		return nil, err
+	}
+	var scopes []string
+	for _, r := range roles {
+		if !r.capabilities.Has(DirectoryWrite) {
+			continue
+		}
+		if r.subtrees == nil {
+			return []string{a.baseDN}, nil
+		}
+		scopes = append(scopes, r.subtrees...)
+	}
+	return scopes, nil
 }
 
 func (a *Authorizer) AdminGroupDN() string {
@@ -136,5 +302,19 @@ func (a *Authorizer) isMember(ctx context.Context, userDN, groupDN string) (bool
 	if a == nil || a.store == nil || userDN == "" || groupDN == "" {
 		return false, nil
 	}
-	return a.store.IsUserInGroup(ctx, userDN, groupDN)
+	key := membershipKey{userDN: strings.ToLower(userDN), groupDN: strings.ToLower(groupDN)}
+	a.mu.Lock()
+	isMember, ok := a.memberships[key]
+	a.mu.Unlock()
+	if ok {
+		return isMember, nil
+	}
+	isMember, err := a.store.IsUserInGroup(ctx, userDN, groupDN)
+	if err != nil {
+		return false, err
+	}
+	a.mu.Lock()
+	a.memberships[key] = isMember
+	a.mu.Unlock()
+	return isMember, nil
 }
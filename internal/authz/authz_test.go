@@ -3,7 +3,10 @@ package authz
 import (
 	"context"
 	"errors"
+	"slices"
 	"testing"
+
+	"github.com/smarzola/ldaplite/pkg/config"
 )
 
 const (
@@ -150,26 +153,70 @@ func TestGroupDNs(t *testing.T) {
 	}
 }
 
+const testEMEAAdminsDN = "cn=emea-admins,ou=groups,dc=example,dc=com"
+
+func testDelegatedAuthorizer(store *membershipStore) *Authorizer {
+	return FromConfig(&config.Config{
+		LDAP: config.LDAPConfig{BaseDN: testBaseDN},
+		Authz: config.AuthzConfig{DelegatedAdmins: []config.DelegatedAdmin{{
+			GroupDN:  testEMEAAdminsDN,
+			Subtrees: []string{"ou=emea,dc=example,dc=com"},
+		}}},
+	}, store)
+}
+
 func TestCanWrite(t *testing.T) {
+	const emeaUserDN = "uid=ana,ou=people,ou=emea,dc=example,dc=com"
+
 	tests := []struct {
 		name       string
 		actor      Actor
-		admin      bool
+		groups     map[string]bool
+		target     string
 		storeErr   error
 		want       bool
 		wantErr    bool
 		wantChecks int
 	}{
-		{name: "unbound denied", want: false},
-		{name: "anonymous denied", actor: Actor{Bound: true}, want: false},
-		{name: "authenticated non admin denied", actor: BoundUser(testUserDN), want: false, wantChecks: 2},
-		{name: "admin allowed", actor: BoundUser(testAdminDN), admin: true, want: true, wantChecks: 1},
-		{name: "read only denied", actor: BoundUser(testReadOnlyDN), want: false, wantChecks: 2},
+		{name: "unbound denied", target: emeaUserDN},
+		{name: "anonymous denied", actor: Actor{Bound: true}, target: emeaUserDN},
+		{name: "authenticated non admin denied", actor: BoundUser(testUserDN), target: emeaUserDN, wantChecks: 2},
+		{
+			name:       "admin allowed anywhere",
+			actor:      BoundUser(testAdminDN),
+			groups:     map[string]bool{"cn=ldaplite.admin,ou=groups,dc=example,dc=com": true},
+			target:     testUserDN,
+			want:       true,
+			wantChecks: 1,
+		},
+		{
+			name:       "delegated admin allowed inside subtree",
+			actor:      BoundUser(testReadOnlyDN),
+			groups:     map[string]bool{testEMEAAdminsDN: true},
+			target:     emeaUserDN,
+			want:       true,
+			wantChecks: 2,
+		},
+		{
+			name:       "delegated admin allowed on subtree root",
+			actor:      BoundUser(testReadOnlyDN),
+			groups:     map[string]bool{testEMEAAdminsDN: true},
+			target:     "OU=EMEA,DC=EXAMPLE,DC=COM",
+			want:       true,
+			wantChecks: 2,
+		},
+		{
+			name:       "delegated admin denied outside subtree",
+			actor:      BoundUser(testReadOnlyDN),
+			groups:     map[string]bool{testEMEAAdminsDN: true},
+			target:     testUserDN,
+			wantChecks: 2,
+		},
 		{
 			name:       "membership error returned",
 			actor:      BoundUser(testUserDN),
+			target:     emeaUserDN,
 			storeErr:   errors.New("membership failed"),
-			want:       false,
 			wantErr:    true,
 			wantChecks: 1,
 		},
@@ -177,15 +224,9 @@ func TestCanWrite(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			store := &membershipStore{}
-			if tt.actor.DN != "" {
-				store.groups = membershipMap(tt.actor.DN, map[string]bool{
-					"cn=ldaplite.admin,ou=groups,dc=example,dc=com": tt.admin,
-				})
-			}
-			store.err = tt.storeErr
+			store := &membershipStore{groups: membershipMap(tt.actor.DN, tt.groups), err: tt.storeErr}
 
-			got, err := New(testBaseDN, store).CanWrite(context.Background(), tt.actor)
+			got, err := testDelegatedAuthorizer(store).CanWrite(context.Background(), tt.actor, tt.target)
 			if (err != nil) != tt.wantErr {
 				t.Fatalf("CanWrite() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -199,6 +240,65 @@ func TestCanWrite(t *testing.T) {
 	}
 }
 
+func TestAdminScopes(t *testing.T) {
+	tests := []struct {
+		name   string
+		actor  Actor
+		groups map[string]bool
+		want   []string
+	}{
+		{name: "anonymous has no scopes", actor: Actor{Bound: true}},
+		{name: "user has no scopes", actor: BoundUser(testUserDN)},
+		{
+			name:   "admin administers the base DN",
+			actor:  BoundUser(testAdminDN),
+			groups: map[string]bool{"cn=ldaplite.admin,ou=groups,dc=example,dc=com": true},
+			want:   []string{testBaseDN},
+		},
+		{
+			name:   "delegated admin administers its subtrees",
+			actor:  BoundUser(testReadOnlyDN),
+			groups: map[string]bool{testEMEAAdminsDN: true},
+			want:   []string{"ou=emea,dc=example,dc=com"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &membershipStore{groups: membershipMap(tt.actor.DN, tt.groups)}
+
+			got, err := testDelegatedAuthorizer(store).AdminScopes(context.Background(), tt.actor)
+			if err != nil {
+				t.Fatalf("AdminScopes() error = %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("AdminScopes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDelegatedAdminCapabilities(t *testing.T) {
+	store := &membershipStore{groups: membershipMap(testReadOnlyDN, map[string]bool{testEMEAAdminsDN: true})}
+
+	got, err := testDelegatedAuthorizer(store).Capabilities(context.Background(), BoundUser(testReadOnlyDN))
+	if err != nil {
+		t.Fatalf("Capabilities() error = %v", err)
+	}
+	for _, capability := range allCapabilities() {
+		if capability == DirectoryProxy {
+			continue
+		}
+		if !got.Has(capability) {
+			t.Fatalf("Capabilities() missing %s", capability)
+		}
+	}
+	// Proxying needs an explicit role.
+	if got.Has(DirectoryProxy) {
+		t.Fatalf("Capabilities() = %v, want no %s for delegated admins", got, DirectoryProxy)
+	}
+}
+
 func allCapabilities() []Capability {
 	return []Capability{
 		DirectoryRead,
@@ -208,6 +308,9 @@ func allCapabilities() []Capability {
 		PasswordResetAny,
 		UIRead,
 		UIAdmin,
+		SCIMWrite,
+		UsersCreate,
+		DirectoryProxy,
 	}
 }
 
@@ -0,0 +1,56 @@
+package authz
+
+import (
+	"context"
+
+	"github.com/smarzola/ldaplite/internal/models"
+)
+
+// attributePermissions are the permissions that apply to single attributes.
+// Adding and deleting apply to whole entries only.
+var attributePermissions = []Permission{
+	PermissionRead,
+	PermissionSearch,
+	PermissionCompare,
+	PermissionWrite,
+}
+
+// PermissionDecision is the decision for one permission.
+type PermissionDecision struct {
+	Permission Permission
+	Decision
+}
+
+// Explanation lists the decisions for each permission on an entry or one of
+// its attributes.
+type Explanation []PermissionDecision
+
+// Allowed reports whether the explanation allows permission. Permissions it
+// does not list are denied.
+func (e Explanation) Allowed(permission Permission) bool {
+	for _, d := range e {
+		if d.Permission == permission {
+			return d.Allowed
+		}
+	}
+	return false
+}
+
+// Explain checks every permission actor may hold on entry, or on one of its
+// attributes when attribute is not empty, and returns the decisions in a
+// fixed order.
+func (a *Authorizer) Explain(ctx context.Context, actor Actor, entry *models.Entry, attribute string) (Explanation, error) {
+	checked := permissions
+	if attribute != "" {
+		checked = attributePermissions
+	}
+	decisions := make(Explanation, 0, len(checked))
+	for _, permission := range checked {
+		decision, err := a.Check(ctx, actor, entry, attribute, permission)
+		if err != nil {
+			return nil, err
+		}
+		decisions = append(decisions, PermissionDecision{Permission: permission, Decision: decision})
+	}
+	return decisions, nil
+}
@@ -0,0 +1,43 @@
+package authz
+
+import (
+	"context"
+	"testing"
+)
+
+func TestExplainNamesDecidingRule(t *testing.T) {
+	authorizer := testAccessAuthorizer(t, &membershipStore{groups: membershipMap(testUserDN, nil)})
+	actor := BoundUser(testUserDN)
+	bob := testEntry("uid=bob,ou=users,dc=example,dc=com", map[string][]string{"mobile": {"555-0101"}})
+
+	got, err := authorizer.Explain(context.Background(), actor, bob, "mobile")
+	if err != nil {
+		t.Fatalf("Explain() error = %v", err)
+	}
+	want := Explanation{
+		{Permission: PermissionRead, Decision: Decision{Reason: ReasonRule, Rule: "private-contact-details"}},
+		{Permission: PermissionSearch, Decision: Decision{Reason: ReasonRule, Rule: "private-contact-details"}},
+		{Permission: PermissionCompare, Decision: Decision{Reason: ReasonRule, Rule: "private-contact-details"}},
+		{Permission: PermissionWrite, Decision: Decision{Reason: ReasonDefault}},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Explain() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Explain()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	contractor := testEntry("uid=temp,ou=contractors,dc=example,dc=com", nil)
+	entryRights, err := authorizer.Explain(context.Background(), actor, contractor, "")
+	if err != nil {
+		t.Fatalf("Explain(entry) error = %v", err)
+	}
+	if len(entryRights) != len(permissions) {
+		t.Fatalf("Explain(entry) returned %d decisions, want %d", len(entryRights), len(permissions))
+	}
+	if !entryRights.Allowed(PermissionAdd) || !entryRights.Allowed(PermissionDelete) || entryRights.Allowed(PermissionWrite) {
+		t.Fatalf("Explain(entry) = %+v, want add and delete but not write", entryRights)
+	}
+}
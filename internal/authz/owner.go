@@ -0,0 +1,43 @@
+package authz
+
+import (
+	"context"
+	"strings"
+
+	"github.com/smarzola/ldaplite/internal/ldapdn"
+	"github.com/smarzola/ldaplite/internal/models"
+)
+
+// MemberAttribute is the only attribute group owners may change.
+const MemberAttribute = "member"
+
+// OwnsGroup reports whether actor is an owner of group: listed in its owner
+// attribute, or a member, directly or through nested groups, of a group
+// listed there.
+func (a *Authorizer) OwnsGroup(ctx context.Context, actor Actor, group *models.Entry) (bool, error) {
+	if !actor.authenticated() || group == nil || !group.IsGroup() {
+		return false, nil
+	}
+	owners := group.GetAttributes("owner")
+	for _, owner := range owners {
+		if ldapdn.Equal(actor.DN, owner) {
+			return true, nil
+		}
+	}
+	for _, owner := range owners {
+		isMember, err := a.isMember(ctx, actor.DN, strings.TrimSpace(owner))
+		if err != nil || isMember {
+			return isMember, err
+		}
+	}
+	return false, nil
+}
+
+// ownerMayWrite reports whether the check is a change to the members of a
+// group actor owns.
+func (a *Authorizer) ownerMayWrite(ctx context.Context, actor Actor, entry *models.Entry, attribute string, permission Permission) (bool, error) {
+	if permission != PermissionWrite || !strings.EqualFold(attribute, MemberAttribute) {
+		return false, nil
+	}
+	return a.OwnsGroup(ctx, actor, entry)
+}
@@ -0,0 +1,72 @@
+package authz
+
+import (
+	"context"
+	"testing"
+
+	"github.com/smarzola/ldaplite/internal/models"
+)
+
+const testTeamOwnersDN = "cn=team-owners,ou=groups,dc=example,dc=com"
+
+func testOwnedGroup() *models.Entry {
+	group := models.NewEntry("cn=team,ou=groups,dc=example,dc=com", string(models.ObjectClassGroupOfNames))
+	group.SetAttribute("member", testReadOnlyDN)
+	group.SetAttributes("owner", []string{"UID=JANE,OU=USERS,DC=EXAMPLE,DC=COM", testTeamOwnersDN})
+	return group
+}
+
+func TestOwnsGroup(t *testing.T) {
+	tests := []struct {
+		name   string
+		actor  Actor
+		groups map[string]map[string]bool
+		entry  *models.Entry
+		want   bool
+	}{
+		{name: "listed owner", actor: BoundUser(testUserDN), entry: testOwnedGroup(), want: true},
+		{
+			name:   "member of owner group",
+			actor:  BoundUser(testPasswordDN),
+			groups: membershipMap(testPasswordDN, map[string]bool{testTeamOwnersDN: true}),
+			entry:  testOwnedGroup(),
+			want:   true,
+		},
+		{name: "group member is not owner", actor: BoundUser(testReadOnlyDN), entry: testOwnedGroup()},
+		{name: "anonymous is not owner", actor: Actor{Bound: true}, entry: testOwnedGroup()},
+		{name: "only groups have owners", actor: BoundUser(testUserDN), entry: testEntry(testAdminDN, map[string][]string{"owner": {testUserDN}})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &membershipStore{groups: tt.groups}
+
+			got, err := New(testBaseDN, store).OwnsGroup(context.Background(), tt.actor, tt.entry)
+			if err != nil {
+				t.Fatalf("OwnsGroup() error = %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("OwnsGroup() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckLetsOwnersWriteOnlyMembers(t *testing.T) {
+	authorizer := New(testBaseDN, &membershipStore{})
+	actor := BoundUser(testUserDN)
+
+	decision, err := authorizer.Check(context.Background(), actor, testOwnedGroup(), "Member", PermissionWrite)
+	if err != nil {
+		t.Fatalf("Check(member) error = %v", err)
+	}
+	if decision != (Decision{Allowed: true, Reason: ReasonOwner}) {
+		t.Fatalf("Check(member) = %+v, want allowed by owner", decision)
+	}
+	for _, attribute := range []string{"description", "owner", ""} {
+		allowed, err := authorizer.Allowed(context.Background(), actor, testOwnedGroup(), attribute, PermissionWrite)
+		if err != nil || allowed {
+			t.Fatalf("Allowed(%q) = %v, %v; want false", attribute, allowed, err)
+		}
+	}
+}
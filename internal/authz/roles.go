@@ -0,0 +1,140 @@
+package authz
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/smarzola/ldaplite/internal/ldapdn"
+	"github.com/smarzola/ldaplite/pkg/config"
+)
+
+// knownCapabilities lists every capability a role may grant.
+var knownCapabilities = []Capability{
+	DirectoryRead,
+	DirectoryWrite,
+	DirectoryManageGroups,
+	PasswordChangeSelf,
+	PasswordResetAny,
+	UIRead,
+	UIAdmin,
+	SCIMWrite,
+	UsersCreate,
+	DirectoryProxy,
+}
+
+type role struct {
+	name         string
+	capabilities Set
+	groups       []string
+	// subtrees limits where the role's capabilities apply to entries; nil
+	// means the whole directory.
+	subtrees []string
+}
+
+// ValidateRoles reports the first role that cannot be used.
+func ValidateRoles(roles []config.Role) error {
+	_, err := compileRoles(roles, nil)
+	return err
+}
+
+// compileRoles returns the configured roles followed by one role for each
+// delegated administrator group.
+func compileRoles(roles []config.Role, delegatedAdmins []config.DelegatedAdmin) ([]role, error) {
+	compiled := make([]role, 0, len(roles)+len(delegatedAdmins))
+	names := make(map[string]bool, len(roles))
+	for i, r := range roles {
+		name := strings.TrimSpace(r.Name)
+		if name == "" {
+			return nil, fmt.Errorf("role #%d: name is required", i+1)
+		}
+		if names[strings.ToLower(name)] {
+			return nil, fmt.Errorf("role %s: defined more than once", name)
+		}
+		names[strings.ToLower(name)] = true
+		compiledRole, err := compileRole(name, r)
+		if err != nil {
+			return nil, fmt.Errorf("role %s: %w", name, err)
+		}
+		compiled = append(compiled, compiledRole)
+	}
+	for _, delegated := range delegatedAdmins {
+		compiled = append(compiled, role{
+			name:         "delegated admins " + delegated.GroupDN,
+			capabilities: delegatedAdminCapabilities(),
+			groups:       []string{delegated.GroupDN},
+			subtrees:     delegated.Subtrees,
+		})
+	}
+	return compiled, nil
+}
+
+func compileRole(name string, r config.Role) (role, error) {
+	compiled := role{name: name, capabilities: NewSet()}
+	if len(r.Capabilities) == 0 {
+		return role{}, fmt.Errorf("at least one capability is required")
+	}
+	for _, value := range r.Capabilities {
+		capability := Capability(strings.TrimSpace(value))
+		if !slices.Contains(knownCapabilities, capability) {
+			return role{}, fmt.Errorf("unknown capability %q", value)
+		}
+		compiled.capabilities.Add(capability)
+	}
+	// Whoever may write entries may also create users.
+	if compiled.capabilities.Has(DirectoryWrite) {
+		compiled.capabilities.Add(UsersCreate)
+	}
+
+	if len(r.Groups) == 0 {
+		return role{}, fmt.Errorf("at least one group is required")
+	}
+	for _, group := range r.Groups {
+		group = strings.TrimSpace(group)
+		if group == "" {
+			return role{}, fmt.Errorf("group DNs cannot be empty")
+		}
+		compiled.groups = append(compiled.groups, group)
+	}
+	for _, subtree := range r.Subtrees {
+		subtree = strings.TrimSpace(subtree)
+		if subtree == "" {
+			return role{}, fmt.Errorf("subtrees cannot be empty")
+		}
+		compiled.subtrees = append(compiled.subtrees, subtree)
+	}
+	return compiled, nil
+}
+
+// covers reports whether the role's capabilities apply to the entry at dn.
+func (r role) covers(dn string) bool {
+	if r.subtrees == nil {
+		return true
+	}
+	return slices.ContainsFunc(r.subtrees, func(subtree string) bool {
+		return ldapdn.WithinBase(dn, subtree)
+	})
+}
+
+// heldRoles returns the roles userDN holds through any of their groups,
+// directly or through nested groups.
+func (a *Authorizer) heldRoles(ctx context.Context, userDN string) ([]role, error) {
+	if a.rolesErr != nil {
+		return nil, a.rolesErr
+	}
+	var held []role
+	for _, r := range a.roles {
+		for _, group := range r.groups {
+			isMember, err := a.isMember(ctx, userDN, group)
+			if err != nil {
+				return nil, err
+			}
+			if isMember {
+				held = append(held, r)
+				break
+			}
+		}
+	}
+	return held, nil
+}
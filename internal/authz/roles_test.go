@@ -0,0 +1,205 @@
+package authz
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/smarzola/ldaplite/internal/models"
+	"github.com/smarzola/ldaplite/pkg/config"
+)
+
+const (
+	testProvisioningGroupDN = "cn=idp,ou=groups,dc=example,dc=com"
+	testAuditorsGroupDN     = "cn=auditors,ou=groups,dc=example,dc=com"
+	testEMEAEditorsGroupDN  = "cn=emea-editors,ou=groups,dc=example,dc=com"
+)
+
+func testRoles() []config.Role {
+	return []config.Role{
+		{
+			Name:         "provisioning",
+			Capabilities: []string{"scim.write", "users.create"},
+			Groups:       []string{testProvisioningGroupDN},
+			Subtrees:     []string{"ou=people,dc=example,dc=com"},
+		},
+		{
+			Name:         "helpdesk",
+			Capabilities: []string{"password.resetAny", "ui.admin"},
+			Groups:       []string{testHelpdeskGroupDN, testAuditorsGroupDN},
+		},
+		{
+			Name:         "emea-editors",
+			Capabilities: []string{"directory.write"},
+			Groups:       []string{testEMEAEditorsGroupDN},
+			Subtrees:     []string{"ou=emea,dc=example,dc=com"},
+		},
+	}
+}
+
+func testRoleAuthorizer(t *testing.T, store *membershipStore) *Authorizer {
+	t.Helper()
+	authorizer := FromConfig(&config.Config{
+		LDAP:  config.LDAPConfig{BaseDN: testBaseDN},
+		Authz: config.AuthzConfig{Roles: testRoles()},
+	}, store)
+	if authorizer.rolesErr != nil {
+		t.Fatalf("FromConfig() roles error = %v", authorizer.rolesErr)
+	}
+	return authorizer
+}
+
+func TestCapabilitiesUnionRoles(t *testing.T) {
+	tests := []struct {
+		name    string
+		groups  map[string]bool
+		want    []Capability
+		wantNot []Capability
+	}{
+		{
+			name:    "no roles keeps built-in capabilities",
+			want:    []Capability{DirectoryRead, PasswordChangeSelf, UIRead},
+			wantNot: []Capability{SCIMWrite, UsersCreate, PasswordResetAny, UIAdmin},
+		},
+		{
+			name:    "one role adds its capabilities",
+			groups:  map[string]bool{testProvisioningGroupDN: true},
+			want:    []Capability{DirectoryRead, PasswordChangeSelf, UIRead, SCIMWrite, UsersCreate},
+			wantNot: []Capability{DirectoryWrite, PasswordResetAny, UIAdmin},
+		},
+		{
+			name:   "several roles are combined",
+			groups: map[string]bool{testProvisioningGroupDN: true, testAuditorsGroupDN: true},
+			want:   []Capability{DirectoryRead, SCIMWrite, UsersCreate, PasswordResetAny, UIAdmin},
+		},
+		{
+			name:    "password only user keeps roles but not directory read",
+			groups:  map[string]bool{"cn=ldaplite.password,ou=groups,dc=example,dc=com": true, testHelpdeskGroupDN: true},
+			want:    []Capability{PasswordChangeSelf, UIRead, PasswordResetAny, UIAdmin},
+			wantNot: []Capability{DirectoryRead},
+		},
+		{
+			name:    "directory write implies users create",
+			groups:  map[string]bool{testEMEAEditorsGroupDN: true},
+			want:    []Capability{DirectoryWrite, UsersCreate},
+			wantNot: []Capability{SCIMWrite},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &membershipStore{groups: membershipMap(testUserDN, tt.groups)}
+
+			got, err := testRoleAuthorizer(t, store).Capabilities(context.Background(), BoundUser(testUserDN))
+			if err != nil {
+				t.Fatalf("Capabilities() error = %v", err)
+			}
+			for _, capability := range tt.want {
+				if !got.Has(capability) {
+					t.Fatalf("Capabilities() missing %s", capability)
+				}
+			}
+			for _, capability := range tt.wantNot {
+				if got.Has(capability) {
+					t.Fatalf("Capabilities() unexpectedly has %s", capability)
+				}
+			}
+		})
+	}
+}
+
+func TestAllowsLimitsRolesToSubtrees(t *testing.T) {
+	store := &membershipStore{groups: membershipMap(testUserDN, map[string]bool{
+		testProvisioningGroupDN: true,
+		testHelpdeskGroupDN:     true,
+	})}
+	authorizer := testRoleAuthorizer(t, store)
+	actor := BoundUser(testUserDN)
+
+	tests := []struct {
+		capability Capability
+		target     string
+		want       bool
+	}{
+		{capability: SCIMWrite, target: "uid=ana,ou=people,dc=example,dc=com", want: true},
+		{capability: SCIMWrite, target: "uid=bob,ou=users,dc=example,dc=com"},
+		{capability: PasswordResetAny, target: "uid=bob,ou=users,dc=example,dc=com", want: true},
+		{capability: DirectoryWrite, target: "uid=ana,ou=people,dc=example,dc=com"},
+	}
+	for _, tt := range tests {
+		got, err := authorizer.Allows(context.Background(), actor, tt.capability, tt.target)
+		if err != nil {
+			t.Fatalf("Allows(%s, %s) error = %v", tt.capability, tt.target, err)
+		}
+		if got != tt.want {
+			t.Fatalf("Allows(%s, %s) = %v, want %v", tt.capability, tt.target, got, tt.want)
+		}
+	}
+
+	scopes, err := authorizer.AdminScopes(context.Background(), actor)
+	if err != nil || scopes != nil {
+		t.Fatalf("AdminScopes() = %v, %v; want no scopes without directory.write", scopes, err)
+	}
+}
+
+func TestUsersCreateRoleOnlyAddsUsers(t *testing.T) {
+	store := &membershipStore{groups: membershipMap(testUserDN, map[string]bool{testProvisioningGroupDN: true})}
+	authorizer := testRoleAuthorizer(t, store)
+	actor := BoundUser(testUserDN)
+
+	user := testEntry("uid=ana,ou=people,dc=example,dc=com", nil)
+	ou := models.NewEntry("ou=new,ou=people,dc=example,dc=com", string(models.ObjectClassOrganizationalUnit))
+	tests := []struct {
+		name       string
+		entry      *models.Entry
+		permission Permission
+		want       bool
+	}{
+		{name: "add user", entry: user, permission: PermissionAdd, want: true},
+		{name: "delete user", entry: user, permission: PermissionDelete},
+		{name: "add ou", entry: ou, permission: PermissionAdd},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := authorizer.Allowed(context.Background(), actor, tt.entry, "", tt.permission)
+			if err != nil {
+				t.Fatalf("Allowed() error = %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("Allowed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateRoles(t *testing.T) {
+	if err := ValidateRoles(testRoles()); err != nil {
+		t.Fatalf("ValidateRoles() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		role config.Role
+		want string
+	}{
+		{name: "missing name", role: config.Role{Capabilities: []string{"ui.read"}, Groups: []string{testHelpdeskGroupDN}}, want: "name is required"},
+		{name: "unknown capability", role: config.Role{Name: "x", Capabilities: []string{"audit.write"}, Groups: []string{testHelpdeskGroupDN}}, want: `unknown capability "audit.write"`},
+		{name: "no capabilities", role: config.Role{Name: "x", Groups: []string{testHelpdeskGroupDN}}, want: "at least one capability"},
+		{name: "no groups", role: config.Role{Name: "x", Capabilities: []string{"ui.read"}}, want: "at least one group"},
+		{name: "empty subtree", role: config.Role{Name: "x", Capabilities: []string{"ui.read"}, Groups: []string{testHelpdeskGroupDN}, Subtrees: []string{" "}}, want: "subtrees cannot be empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateRoles([]config.Role{tt.role})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("ValidateRoles() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	duplicate := slices.Concat(testRoles(), []config.Role{testRoles()[0]})
+	if err := ValidateRoles(duplicate); err == nil || !strings.Contains(err.Error(), "defined more than once") {
+		t.Fatalf("ValidateRoles(duplicate) error = %v", err)
+	}
+}
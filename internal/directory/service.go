@@ -4,10 +4,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
+	"time"
 
+	"github.com/smarzola/ldaplite/internal/authz"
 	"github.com/smarzola/ldaplite/internal/ldapdn"
 	"github.com/smarzola/ldaplite/internal/models"
+	"github.com/smarzola/ldaplite/internal/ppolicy"
 	"github.com/smarzola/ldaplite/internal/store"
 	"github.com/smarzola/ldaplite/pkg/config"
 	"github.com/smarzola/ldaplite/pkg/crypto"
@@ -18,12 +22,14 @@ var (
 	ErrProtectedAttribute  = errors.New("protected attribute")
 	ErrUnsupportedObject   = errors.New("unsupported object class")
 	ErrPasswordNotProvided = errors.New("password is required")
+	ErrAccessDenied        = errors.New("insufficient access rights")
 )
 
 type Service struct {
 	store  store.Store
 	cfg    *config.Config
 	hasher *crypto.PasswordHasher
+	policy *ppolicy.Policy
 }
 
 type UserInput struct {
@@ -36,6 +42,12 @@ type UserInput struct {
 	Mail       string              `json:"mail"`
 	Password   string              `json:"password"`
 	Attributes map[string][]string `json:"attributes"`
+
+	// Disabled and ExpirationTime change the account state when set.
+	// ExpirationTime is an RFC 3339 or generalized time timestamp; an empty
+	// string removes the expiry.
+	Disabled       *bool   `json:"disabled,omitempty"`
+	ExpirationTime *string `json:"expirationTime,omitempty"`
 }
 
 type GroupInput struct {
@@ -56,14 +68,41 @@ type OUInput struct {
 }
 
 func NewService(st store.Store, cfg *config.Config) *Service {
+	hasher := crypto.NewPasswordHasher(cfg.Security.Argon2Config)
 	return &Service{
 		store:  st,
 		cfg:    cfg,
-		hasher: crypto.NewPasswordHasher(cfg.Security.Argon2Config),
+		hasher: hasher,
+		policy: ppolicy.New(cfg.Security.PasswordPolicy, st, hasher),
+	}
+}
+
+// CheckWrite returns ErrAccessDenied unless actor holds capability for the
+// entry at dn. Delegated administrators and other roles may only write
+// inside their subtrees.
+func CheckWrite(ctx context.Context, st store.Store, cfg *config.Config, actor authz.Actor, capability authz.Capability, dn string) error {
+	allowed, err := authz.FromConfig(cfg, st).Allows(ctx, actor, capability, strings.TrimSpace(dn))
+	if err != nil {
+		return err
 	}
+	if !allowed {
+		return fmt.Errorf("%w: %s is outside your administrative scope", ErrAccessDenied, dn)
+	}
+	return nil
+}
+
+// CheckWrite is the package CheckWrite for the service's store.
+func (s *Service) CheckWrite(ctx context.Context, actor authz.Actor, capability authz.Capability, dn string) error {
+	return CheckWrite(ctx, s.store, s.cfg, actor, capability, dn)
+}
+
+// NewEntryDN returns the DN the Create methods give an entry named
+// attribute=value under parentDN.
+func NewEntryDN(attribute, value, parentDN string) string {
+	return fmt.Sprintf("%s=%s,%s", attribute, strings.TrimSpace(value), strings.TrimSpace(parentDN))
 }
 
-func (s *Service) CreateUser(ctx context.Context, input UserInput) (*models.Entry, error) {
+func (s *Service) CreateUser(ctx context.Context, actor authz.Actor, input UserInput) (*models.Entry, error) {
 	parentDN := strings.TrimSpace(input.ParentDN)
 	uid := strings.TrimSpace(input.UID)
 	cn := strings.TrimSpace(input.CN)
@@ -74,6 +113,18 @@ func (s *Service) CreateUser(ctx context.Context, input UserInput) (*models.Entr
 	if strings.TrimSpace(input.Password) == "" {
 		return nil, ErrPasswordNotProvided
 	}
+	if crypto.IsHashedPassword(input.Password) {
+		if err := s.checkPreHashedPassword(ctx, actor, NewEntryDN("uid", uid, parentDN)); err != nil {
+			return nil, err
+		}
+	} else if err := s.policy.CheckQuality(input.Password); err != nil {
+		return nil, err
+	}
+
+	status, err := accountStatusFromInput(models.AccountStatus{}, input)
+	if err != nil {
+		return nil, err
+	}
 
 	user := models.NewUser(parentDN, uid, cn, sn, strings.TrimSpace(input.Mail))
 	setOptional(user.Entry, "givenName", input.GivenName)
@@ -83,6 +134,7 @@ func (s *Service) CreateUser(ctx context.Context, input UserInput) (*models.Entr
 	if err := applyExtraAttributes(user.Entry, input.Attributes, userPreservedAttributes); err != nil {
 		return nil, err
 	}
+	user.SetAccountStatus(status)
 
 	if err := s.store.CreateEntry(ctx, user.Entry); err != nil {
 		return nil, err
@@ -90,7 +142,7 @@ func (s *Service) CreateUser(ctx context.Context, input UserInput) (*models.Entr
 	return s.store.GetEntry(ctx, user.DN)
 }
 
-func (s *Service) UpdateUser(ctx context.Context, dn string, input UserInput) (*models.Entry, error) {
+func (s *Service) UpdateUser(ctx context.Context, actor authz.Actor, dn string, input UserInput) (*models.Entry, error) {
 	entry, err := s.requireEntry(ctx, dn, models.ObjectClassInetOrgPerson)
 	if err != nil {
 		return nil, err
@@ -99,11 +151,18 @@ func (s *Service) UpdateUser(ctx context.Context, dn string, input UserInput) (*
 	if strings.TrimSpace(input.CN) == "" || strings.TrimSpace(input.SN) == "" {
 		return nil, fmt.Errorf("%w: cn and sn are required", ErrInvalidRequest)
 	}
+	status, err := accountStatusFromInput(entry.AccountStatus(), input)
+	if err != nil {
+		return nil, err
+	}
 	entry.SetAttribute("cn", strings.TrimSpace(input.CN))
 	entry.SetAttribute("sn", strings.TrimSpace(input.SN))
 	setOptional(entry, "givenName", input.GivenName)
 	setOptional(entry, "mail", input.Mail)
 	if strings.TrimSpace(input.Password) != "" {
+		if err := s.checkNewPassword(ctx, actor, entry.DN, input.Password); err != nil {
+			return nil, err
+		}
 		if err := setProcessedPassword(s.hasher, entry, input.Password); err != nil {
 			return nil, err
 		}
@@ -112,7 +171,7 @@ func (s *Service) UpdateUser(ctx context.Context, dn string, input UserInput) (*
 		return nil, err
 	}
 
-	if err := s.store.UpdateEntry(ctx, entry); err != nil {
+	if err := s.store.UpdateEntryWithAccountStatus(ctx, entry, status); err != nil {
 		return nil, err
 	}
 	return s.store.GetEntry(ctx, entry.DN)
@@ -165,6 +224,90 @@ func (s *Service) UpdateGroup(ctx context.Context, dn string, input GroupInput)
 	return s.store.GetEntry(ctx, entry.DN)
 }
 
+// MembershipChange lists the members to add to and remove from a group.
+type MembershipChange struct {
+	Add    []string `json:"add"`
+	Remove []string `json:"remove"`
+}
+
+// ChangeMembers adds and removes members of the group at groupDN. Actor needs
+// capability on the group or must own it; owners may change nothing else.
+// Members already present are not added again and absent members are not
+// removed.
+func (s *Service) ChangeMembers(ctx context.Context, actor authz.Actor, capability authz.Capability, groupDN string, change MembershipChange) (*models.Entry, error) {
+	entry, err := s.requireEntry(ctx, groupDN, models.ObjectClassGroupOfNames)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkMembershipWrite(ctx, actor, capability, entry); err != nil {
+		return nil, err
+	}
+
+	// Apply the change to the group as stored when the write starts, so
+	// concurrent membership changes do not overwrite each other.
+	_, err = s.store.ModifyEntry(ctx, entry.DN, store.WriteOptions{}, func(group *models.Entry) error {
+		members := group.GetAttributes(authz.MemberAttribute)
+		for _, member := range cleanNonEmpty(change.Add) {
+			if !slices.ContainsFunc(members, func(existing string) bool { return EqualDN(existing, member) }) {
+				members = append(members, member)
+			}
+		}
+		for _, member := range cleanNonEmpty(change.Remove) {
+			members = slices.DeleteFunc(members, func(existing string) bool { return EqualDN(existing, member) })
+		}
+		if len(members) == 0 {
+			return fmt.Errorf("%w: at least one group member is required", ErrInvalidRequest)
+		}
+		group.SetAttributes(authz.MemberAttribute, members)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.store.GetEntry(ctx, entry.DN)
+}
+
+func (s *Service) checkMembershipWrite(ctx context.Context, actor authz.Actor, capability authz.Capability, group *models.Entry) error {
+	access := authz.FromConfig(s.cfg, s.store)
+	allowed, err := access.Allows(ctx, actor, capability, group.DN)
+	if err != nil || allowed {
+		return err
+	}
+	owner, err := access.OwnsGroup(ctx, actor, group)
+	if err != nil {
+		return err
+	}
+	if !owner {
+		return fmt.Errorf("%w: you do not own %s", ErrAccessDenied, group.DN)
+	}
+	return nil
+}
+
+// OwnedGroups returns the groups actor owns, directly or through a group.
+func (s *Service) OwnedGroups(ctx context.Context, actor authz.Actor) ([]*models.Entry, error) {
+	entries, err := s.store.SearchEntriesWithOptions(ctx, store.SearchOptions{
+		BaseDN:          s.cfg.LDAP.BaseDN,
+		Filter:          "(&(objectClass=groupOfNames)(owner=*))",
+		Scope:           store.SearchScopeWholeSubtree,
+		IncludeMemberOf: false,
+	})
+	if err != nil {
+		return nil, err
+	}
+	access := authz.FromConfig(s.cfg, s.store)
+	owned := make([]*models.Entry, 0, len(entries))
+	for _, entry := range entries {
+		owner, err := access.OwnsGroup(ctx, actor, entry)
+		if err != nil {
+			return nil, err
+		}
+		if owner {
+			owned = append(owned, entry)
+		}
+	}
+	return owned, nil
+}
+
 func (s *Service) CreateOU(ctx context.Context, input OUInput) (*models.Entry, error) {
 	parentDN := strings.TrimSpace(input.ParentDN)
 	ou := strings.TrimSpace(input.OU)
@@ -206,18 +349,40 @@ func (s *Service) DeleteEntry(ctx context.Context, dn string) error {
 	return s.store.DeleteEntry(ctx, dn)
 }
 
+// DeleteSubtree deletes the entry at dn with all of its descendants, or with
+// dryRun reports what the delete would remove. The delete skips the access
+// checks of each descendant, so actor must be an administrator.
+func (s *Service) DeleteSubtree(ctx context.Context, actor authz.Actor, dn string, dryRun bool) (store.SubtreeDeleteResult, error) {
+	dn = strings.TrimSpace(dn)
+	if dn == "" {
+		return store.SubtreeDeleteResult{}, fmt.Errorf("%w: dn is required", ErrInvalidRequest)
+	}
+	isAdmin, err := authz.FromConfig(s.cfg, s.store).IsAdmin(ctx, actor.DN)
+	if err != nil {
+		return store.SubtreeDeleteResult{}, err
+	}
+	if !isAdmin {
+		return store.SubtreeDeleteResult{}, fmt.Errorf("%w: only administrators may delete subtrees", ErrAccessDenied)
+	}
+	return s.store.DeleteSubtree(ctx, dn, store.SubtreeDeleteOptions{DryRun: dryRun})
+}
+
 func (s *Service) ChangeOwnPassword(ctx context.Context, userDN, password string) error {
 	if strings.TrimSpace(userDN) == "" {
 		return fmt.Errorf("%w: authenticated user DN is required", ErrInvalidRequest)
 	}
-	return s.setUserPassword(ctx, userDN, password)
+	// Self-service changes always go through the password policy.
+	if crypto.IsHashedPassword(password) {
+		return ppolicy.ErrPreHashedPassword
+	}
+	return s.setUserPassword(ctx, authz.BoundUser(userDN), userDN, password)
 }
 
-func (s *Service) ResetPassword(ctx context.Context, targetDN, password string) error {
-	return s.setUserPassword(ctx, targetDN, password)
+func (s *Service) ResetPassword(ctx context.Context, actor authz.Actor, targetDN, password string) error {
+	return s.setUserPassword(ctx, actor, targetDN, password)
 }
 
-func (s *Service) setUserPassword(ctx context.Context, dn, password string) error {
+func (s *Service) setUserPassword(ctx context.Context, actor authz.Actor, dn, password string) error {
 	if strings.TrimSpace(password) == "" {
 		return ErrPasswordNotProvided
 	}
@@ -225,6 +390,9 @@ func (s *Service) setUserPassword(ctx context.Context, dn, password string) erro
 	if err != nil {
 		return err
 	}
+	if err := s.checkNewPassword(ctx, actor, entry.DN, password); err != nil {
+		return err
+	}
 	if err := setProcessedPassword(s.hasher, entry, password); err != nil {
 		return err
 	}
@@ -249,12 +417,66 @@ func (s *Service) requireEntry(ctx context.Context, dn string, objectClass model
 	return entry, nil
 }
 
+// checkNewPassword applies the password policy to a cleartext password.
+// Pre-hashed passwords are stored as given, for password administrators only.
+func (s *Service) checkNewPassword(ctx context.Context, actor authz.Actor, dn, password string) error {
+	if crypto.IsHashedPassword(password) {
+		return s.checkPreHashedPassword(ctx, actor, dn)
+	}
+	return s.policy.CheckNewPassword(ctx, dn, password)
+}
+
+// checkPreHashedPassword returns ppolicy.ErrPreHashedPassword unless actor may
+// reset any password at dn.
+func (s *Service) checkPreHashedPassword(ctx context.Context, actor authz.Actor, dn string) error {
+	allowed, err := authz.FromConfig(s.cfg, s.store).Allows(ctx, actor, authz.PasswordResetAny, dn)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return ppolicy.ErrPreHashedPassword
+	}
+	return nil
+}
+
+// accountStatusFromInput applies the account state fields of input to the
+// current status.
+func accountStatusFromInput(status models.AccountStatus, input UserInput) (models.AccountStatus, error) {
+	if input.Disabled != nil {
+		status.Disabled = *input.Disabled
+	}
+	if input.ExpirationTime != nil {
+		expires, err := parseExpirationTime(*input.ExpirationTime)
+		if err != nil {
+			return status, err
+		}
+		status.ExpirationTime = expires
+	}
+	return status, nil
+}
+
+func parseExpirationTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if expires, err := time.Parse(time.RFC3339, value); err == nil {
+		// Generalized time is stored to the second.
+		return expires.UTC().Truncate(time.Second), nil
+	}
+	expires, err := models.ParseLDAPTimestamp(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: expirationTime must be an RFC 3339 or generalized time timestamp", ErrInvalidRequest)
+	}
+	return expires, nil
+}
+
 func setProcessedPassword(hasher *crypto.PasswordHasher, entry *models.Entry, password string) error {
-	processed, err := hasher.ProcessPassword(password)
+	processed, err := hasher.ProcessPasswordValues(password)
 	if err != nil {
 		return err
 	}
-	entry.SetAttribute("userPassword", processed)
+	entry.SetAttributes("userPassword", processed)
 	return nil
 }
 
@@ -293,7 +515,8 @@ func replaceExtraAttributes(entry *models.Entry, attrs map[string][]string, pres
 
 func isProtectedAttribute(name string) bool {
 	switch strings.ToLower(name) {
-	case "objectclass", "userpassword", "createtimestamp", "modifytimestamp", "memberof", "entryuuid", "uuid":
+	case "objectclass", "userpassword", "createtimestamp", "modifytimestamp", "memberof", "entryuuid", "uuid",
+		"pwdchangedtime", "pwdfailuretime", "pwdaccountlockedtime", "accountdisabled", "accountexpirationtime":
 		return true
 	default:
 		return false
@@ -69,6 +69,47 @@ func WithinBase(dn, baseDN string) bool {
 	return false
 }
 
+// Rebase replaces the oldBase suffix of dn with newBase. It reports false when
+// dn is neither oldBase nor one of its descendants.
+func Rebase(dn, oldBase, newBase string) (string, bool) {
+	newBase = strings.TrimSpace(newBase)
+	if Equal(dn, oldBase) {
+		return newBase, true
+	}
+	var rdns []string
+	for rest := dn; ; {
+		rdn, parent := Split(rest)
+		if parent == "" {
+			return "", false
+		}
+		rdns = append(rdns, rdn)
+		if Equal(parent, oldBase) {
+			return strings.Join(append(rdns, newBase), ","), true
+		}
+		rest = parent
+	}
+}
+
+// EscapeValue escapes an attribute value for use in an RDN (RFC 4514
+// section 2.4).
+func EscapeValue(value string) string {
+	var escaped strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == 0:
+			escaped.WriteString(`\00`)
+			continue
+		case strings.IndexByte(`"+,;<>\`, c) >= 0,
+			i == 0 && (c == ' ' || c == '#'),
+			i == len(value)-1 && c == ' ':
+			escaped.WriteByte('\\')
+		}
+		escaped.WriteByte(c)
+	}
+	return escaped.String()
+}
+
 func firstUnescapedComma(dn string) int {
 	return firstUnescaped(dn, ',')
 }
@@ -149,3 +149,78 @@ func TestFirstRDNValue(t *testing.T) {
 		})
 	}
 }
+
+func TestEscapeValue(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"jane", "jane"},
+		{"Doe, Jane", `Doe\, Jane`},
+		{`a+b"c;d<e>f\g`, `a\+b\"c\;d\<e\>f\\g`},
+		{" #lead", `\ #lead`},
+		{"#hash", `\#hash`},
+		{"trail ", `trail\ `},
+		{"nul\x00", `nul\00`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := EscapeValue(tt.value); got != tt.want {
+				t.Fatalf("EscapeValue(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRebase(t *testing.T) {
+	tests := []struct {
+		name    string
+		dn      string
+		oldBase string
+		newBase string
+		want    string
+		wantOK  bool
+	}{
+		{
+			name:    "base itself",
+			dn:      "ou=people,dc=example,dc=com",
+			oldBase: "ou=people,dc=example,dc=com",
+			newBase: "ou=staff,dc=example,dc=com",
+			want:    "ou=staff,dc=example,dc=com",
+			wantOK:  true,
+		},
+		{
+			name:    "nested descendant",
+			dn:      "uid=jane,ou=eng,ou=people,dc=example,dc=com",
+			oldBase: "OU=People,DC=Example,DC=Com",
+			newBase: "ou=staff,dc=example,dc=com",
+			want:    "uid=jane,ou=eng,ou=staff,dc=example,dc=com",
+			wantOK:  true,
+		},
+		{
+			name:    "escaped comma is kept",
+			dn:      `cn=Doe\, Jane,ou=people,dc=example,dc=com`,
+			oldBase: "ou=people,dc=example,dc=com",
+			newBase: "ou=staff,dc=example,dc=com",
+			want:    `cn=Doe\, Jane,ou=staff,dc=example,dc=com`,
+			wantOK:  true,
+		},
+		{
+			name:    "outside base",
+			dn:      "uid=jane,ou=users,dc=example,dc=com",
+			oldBase: "ou=people,dc=example,dc=com",
+			newBase: "ou=staff,dc=example,dc=com",
+			wantOK:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Rebase(tt.dn, tt.oldBase, tt.newBase)
+			if ok != tt.wantOK || got != tt.want {
+				t.Fatalf("Rebase() = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -98,7 +98,7 @@ func exportableAttribute(name string, includeOperational bool) bool {
 	switch strings.ToLower(name) {
 	case "objectclass", "userpassword", "memberof", "uuid":
 		return false
-	case "entryuuid", "createtimestamp", "modifytimestamp":
+	case "entryuuid", "createtimestamp", "modifytimestamp", "accountdisabled", "accountexpirationtime":
 		return includeOperational
 	default:
 		return true
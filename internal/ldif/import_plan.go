@@ -155,11 +155,11 @@ func entryFromRecord(record Record, baseDN string, options ImportPlanOptions) (*
 		if len(passwords) > 1 {
 			return nil, nil, &ImportPlanError{DN: record.DN, Msg: "userPassword must be single-valued"}
 		}
-		processed, err := options.Hasher.ProcessPassword(passwords[0])
+		processed, err := options.Hasher.ProcessPasswordValues(passwords[0])
 		if err != nil {
 			return nil, nil, &ImportPlanError{DN: record.DN, Msg: err.Error()}
 		}
-		entry.SetAttribute("userPassword", processed)
+		entry.SetAttributes("userPassword", processed)
 	} else if len(record.Values("userPassword")) > 0 {
 		return nil, nil, &ImportPlanError{DN: record.DN, Msg: "userPassword is only supported on inetOrgPerson entries"}
 	}
@@ -212,7 +212,7 @@ func primaryObjectClass(record Record, baseDN string) (string, error) {
 func rejectProtectedAttributes(record Record) error {
 	for _, attr := range record.Attributes {
 		switch strings.ToLower(attr.Name) {
-		case "entryuuid", "uuid", "createtimestamp", "modifytimestamp", "memberof":
+		case "entryuuid", "uuid", "createtimestamp", "modifytimestamp", "memberof", "accountdisabled", "accountexpirationtime":
 			return &ImportPlanError{DN: record.DN, Msg: fmt.Sprintf("protected attribute %s is not importable", attr.Name)}
 		}
 	}
@@ -180,6 +180,25 @@ sn: User`)
 	assert.NotContains(t, plan.Entries[0].GetAttribute("userPassword"), plan.GeneratedPasswords[0].Password)
 }
 
+func TestPlanImportKeepsLegacyPasswordHashesWhenAllowed(t *testing.T) {
+	const legacyHash = "{SSHA}1G904nLkTkGWjKNnQuB/hpWXC/hzYWx0c2FsdA=="
+	records, err := Parse(`dn: uid=migrated,ou=users,dc=example,dc=com
+objectClass: inetOrgPerson
+uid: migrated
+cn: Migrated User
+sn: User
+userPassword: ` + legacyHash)
+	require.NoError(t, err)
+
+	plan, err := PlanImport(context.Background(), fakeLookupWith("ou=users,dc=example,dc=com"), records, ImportPlanOptions{
+		BaseDN: "dc=example,dc=com",
+		Hasher: testHasher().WithLegacySchemes(true),
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{legacyHash}, plan.Entries[0].GetAttributes("userPassword"))
+}
+
 func TestPlanImportOrdersGroupAfterBatchMembers(t *testing.T) {
 	records, err := Parse(`dn: cn=early-group,ou=groups,dc=example,dc=com
 objectClass: groupOfNames
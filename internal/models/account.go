@@ -0,0 +1,77 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// Account state attributes of user entries. They are server-managed
+// operational attributes: ordinary writes cannot change them.
+const (
+	AttrAccountDisabled       = "accountDisabled"
+	AttrAccountExpirationTime = "accountExpirationTime"
+)
+
+var (
+	ErrAccountDisabled = errors.New("account is disabled")
+	ErrAccountExpired  = errors.New("account has expired")
+)
+
+// AccountStatus is the disabled state and expiry of a user account.
+type AccountStatus struct {
+	Disabled       bool
+	ExpirationTime time.Time // zero when the account never expires
+}
+
+// AccountStatus reads the account state attributes of the entry.
+func (e *Entry) AccountStatus() AccountStatus {
+	status := AccountStatus{
+		Disabled: strings.EqualFold(e.GetAttribute(AttrAccountDisabled), "TRUE"),
+	}
+	if value := e.GetAttribute(AttrAccountExpirationTime); value != "" {
+		if expires, err := ParseLDAPTimestamp(value); err == nil {
+			status.ExpirationTime = expires
+		}
+	}
+	return status
+}
+
+// SetAccountStatus replaces the account state attributes of the entry.
+func (e *Entry) SetAccountStatus(status AccountStatus) {
+	e.RemoveAttribute(AttrAccountDisabled)
+	e.RemoveAttribute(AttrAccountExpirationTime)
+	for name, values := range status.Attributes() {
+		e.SetAttributes(name, values)
+	}
+}
+
+// Attributes returns the stored form of the status. Enabled accounts without
+// an expiry have no account state attributes.
+func (s AccountStatus) Attributes() map[string][]string {
+	attrs := make(map[string][]string)
+	if s.Disabled {
+		attrs[strings.ToLower(AttrAccountDisabled)] = []string{"TRUE"}
+	}
+	if !s.ExpirationTime.IsZero() {
+		attrs[strings.ToLower(AttrAccountExpirationTime)] = []string{FormatLDAPTimestamp(s.ExpirationTime)}
+	}
+	return attrs
+}
+
+// Check returns ErrAccountDisabled or ErrAccountExpired when the account may
+// not authenticate at now.
+func (s AccountStatus) Check(now time.Time) error {
+	if s.Disabled {
+		return ErrAccountDisabled
+	}
+	if !s.ExpirationTime.IsZero() && !now.Before(s.ExpirationTime) {
+		return ErrAccountExpired
+	}
+	return nil
+}
+
+// IsAccountStatusAttribute reports whether name is an account state attribute.
+func IsAccountStatusAttribute(name string) bool {
+	return strings.EqualFold(name, AttrAccountDisabled) || strings.EqualFold(name, AttrAccountExpirationTime)
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccountStatusRoundTrip(t *testing.T) {
+	entry := NewEntry("uid=jane,ou=users,dc=example,dc=com", string(ObjectClassInetOrgPerson))
+	assert.Equal(t, AccountStatus{}, entry.AccountStatus())
+
+	expires := time.Date(2026, 12, 31, 18, 0, 0, 0, time.UTC)
+	entry.SetAccountStatus(AccountStatus{Disabled: true, ExpirationTime: expires})
+	assert.Equal(t, "TRUE", entry.GetAttribute("accountDisabled"))
+	assert.Equal(t, "20261231180000Z", entry.GetAttribute("accountExpirationTime"))
+	assert.Equal(t, AccountStatus{Disabled: true, ExpirationTime: expires}, entry.AccountStatus())
+
+	entry.SetAccountStatus(AccountStatus{})
+	assert.False(t, entry.HasAttribute("accountDisabled"))
+	assert.False(t, entry.HasAttribute("accountExpirationTime"))
+}
+
+func TestAccountStatusCheck(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	assert.NoError(t, AccountStatus{}.Check(now))
+	assert.NoError(t, AccountStatus{ExpirationTime: now.Add(time.Hour)}.Check(now))
+	assert.ErrorIs(t, AccountStatus{Disabled: true}.Check(now), ErrAccountDisabled)
+	assert.ErrorIs(t, AccountStatus{ExpirationTime: now}.Check(now), ErrAccountExpired)
+}
+
+func TestParseLDAPTimestamp(t *testing.T) {
+	parsed, err := ParseLDAPTimestamp("20250125193045Z")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 1, 25, 19, 30, 45, 0, time.UTC), parsed)
+
+	parsed, err = ParseLDAPTimestamp("20250125143045-0500")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 1, 25, 19, 30, 45, 0, time.UTC), parsed)
+
+	_, err = ParseLDAPTimestamp("2025-01-25")
+	assert.Error(t, err)
+}
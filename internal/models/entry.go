@@ -140,6 +140,25 @@ func (e *Entry) RemoveAttributeValue(name, value string) error {
 	return fmt.Errorf("value %s not found in attribute %s", value, name)
 }
 
+// Clone returns a copy of the entry that shares no attribute values with it.
+func (e *Entry) Clone() *Entry {
+	clone := *e
+	clone.Attributes = cloneAttributes(e.Attributes)
+	clone.ComputedAttributes = cloneAttributes(e.ComputedAttributes)
+	return &clone
+}
+
+func cloneAttributes(attrs map[string][]string) map[string][]string {
+	if attrs == nil {
+		return nil
+	}
+	clone := make(map[string][]string, len(attrs))
+	for name, values := range attrs {
+		clone[name] = append([]string(nil), values...)
+	}
+	return clone
+}
+
 // IsOrganizationalUnit checks if entry is an OU
 func (e *Entry) IsOrganizationalUnit() bool {
 	return e.ObjectClass == string(ObjectClassOrganizationalUnit)
@@ -195,3 +214,13 @@ func (e *Entry) ToLDIF() string {
 func FormatLDAPTimestamp(t time.Time) string {
 	return t.UTC().Format("20060102150405Z")
 }
+
+// ParseLDAPTimestamp parses an LDAP Generalized Time value such as
+// 20250125143045Z or 20250125143045+0100.
+func ParseLDAPTimestamp(value string) (time.Time, error) {
+	t, err := time.Parse("20060102150405Z0700", strings.TrimSpace(value))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid generalized time %q", value)
+	}
+	return t.UTC(), nil
+}
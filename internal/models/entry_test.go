@@ -42,6 +42,21 @@ func TestSetAttributesCopiesValuesAndUpdatesTimestamp(t *testing.T) {
 	assert.True(t, entry.UpdatedAt.After(time.Unix(0, 0)))
 }
 
+func TestCloneSharesNoValues(t *testing.T) {
+	entry := NewEntry("cn=test,dc=example,dc=com", "groupOfNames")
+	entry.SetAttributes("member", []string{"uid=a,dc=example,dc=com"})
+	entry.SetComputedAttributes("memberOf", []string{"cn=parent,dc=example,dc=com"})
+
+	clone := entry.Clone()
+	clone.Attributes["member"][0] = "uid=b,dc=example,dc=com"
+	clone.AddAttribute("member", "uid=c,dc=example,dc=com")
+	clone.ComputedAttributes["memberof"][0] = "cn=other,dc=example,dc=com"
+
+	assert.Equal(t, []string{"uid=a,dc=example,dc=com"}, entry.GetAttributes("member"))
+	assert.Equal(t, []string{"cn=parent,dc=example,dc=com"}, entry.GetAttributes("memberOf"))
+	assert.Equal(t, entry.DN, clone.DN)
+}
+
 func TestAddAttribute(t *testing.T) {
 	entry := NewEntry("cn=test,dc=example,dc=com", "inetOrgPerson")
 	entry.AddAttribute("mail", "test@example.com")
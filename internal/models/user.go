@@ -31,10 +31,11 @@ func NewUser(parentDN, uid, cn, sn, mail string) *User {
 	}
 }
 
-// SetPassword sets the hashed password for the user
-func (u *User) SetPassword(hashedPassword string) {
+// SetPassword sets the hashed password for the user. Further values carry
+// other stored forms of the same password, such as SCRAM keys.
+func (u *User) SetPassword(hashedPassword string, otherForms ...string) {
 	u.Password = hashedPassword
-	u.Entry.SetAttribute("userPassword", hashedPassword)
+	u.Entry.SetAttributes("userPassword", append([]string{hashedPassword}, otherForms...))
 }
 
 // ValidateUser validates that a user has all required attributes
@@ -0,0 +1,334 @@
+// Package ppolicy enforces the password policy: password quality and
+// history on every password change, and expiry and lockout on every bind.
+// Error codes follow draft-behera-ldap-password-policy so LDAP clients can
+// report them through the password policy response control.
+package ppolicy
+
+import (
+	"context"
+	crand "crypto/rand"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/smarzola/ldaplite/internal/store"
+	"github.com/smarzola/ldaplite/pkg/config"
+	"github.com/smarzola/ldaplite/pkg/crypto"
+)
+
+// ErrorCode is a PasswordPolicyResponseValue error value.
+type ErrorCode int
+
+const (
+	PasswordExpired             ErrorCode = 0
+	AccountLocked               ErrorCode = 1
+	ChangeAfterReset            ErrorCode = 2
+	PasswordModNotAllowed       ErrorCode = 3
+	MustSupplyOldPassword       ErrorCode = 4
+	InsufficientPasswordQuality ErrorCode = 5
+	PasswordTooShort            ErrorCode = 6
+	PasswordTooYoung            ErrorCode = 7
+	PasswordInHistory           ErrorCode = 8
+)
+
+var (
+	// ErrPolicyViolation matches every *Error.
+	ErrPolicyViolation = errors.New("password policy violation")
+	// ErrInvalidCredentials reports a bind whose password did not verify.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrPreHashedPassword rejects a pre-hashed password from an identity
+	// that may not reset passwords. Pre-hashed passwords skip the quality and
+	// history checks, so only password administrators and LDIF imports may
+	// store them.
+	ErrPreHashedPassword error = &Error{Code: InsufficientPasswordQuality, Msg: "pre-hashed passwords may only be set by password administrators"}
+)
+
+// Error is a password policy violation.
+type Error struct {
+	Code ErrorCode
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return e.Msg
+}
+
+func (e *Error) Unwrap() error {
+	return ErrPolicyViolation
+}
+
+// Store is the part of store.Store the policy needs.
+type Store interface {
+	GetUserPasswordHashByDN(ctx context.Context, dn string) (passwordHash string, canonicalDN string, err error)
+	GetPasswordPolicyState(ctx context.Context, dn string) (*store.PasswordPolicyState, error)
+	RecordPasswordFailure(ctx context.Context, dn string, failureTime, expireBefore time.Time, maxFailures int) (bool, error)
+	ResetPasswordFailures(ctx context.Context, dn string) error
+	RecordGraceLogin(ctx context.Context, dn string) error
+	GetPasswordHistory(ctx context.Context, dn string) ([]string, error)
+}
+
+// Policy applies a PasswordPolicyConfig to the users of a store.
+type Policy struct {
+	cfg    config.PasswordPolicyConfig
+	store  Store
+	hasher *crypto.PasswordHasher
+	now    func() time.Time
+}
+
+// BindResult carries the warnings of a successful bind. Negative values mean
+// there is nothing to report.
+type BindResult struct {
+	TimeBeforeExpiration int // seconds until the password expires
+	GraceAuthNsRemaining int // grace logins left after an expired password
+}
+
+func New(cfg config.PasswordPolicyConfig, st Store, hasher *crypto.PasswordHasher) *Policy {
+	return &Policy{
+		cfg:    cfg,
+		store:  st,
+		hasher: hasher,
+		now:    time.Now,
+	}
+}
+
+// CheckQuality checks a new cleartext password against the length and
+// character class rules. Pre-hashed passwords cannot be inspected, so callers
+// accept them (see crypto.IsHashedPassword) only from password administrators
+// and return ErrPreHashedPassword to everyone else.
+func (p *Policy) CheckQuality(password string) error {
+	if p.cfg.MinLength > 0 && utf8.RuneCountInString(password) < p.cfg.MinLength {
+		return &Error{Code: PasswordTooShort, Msg: fmt.Sprintf("password must be at least %d characters", p.cfg.MinLength)}
+	}
+	if p.cfg.MinCharClasses > 0 && charClasses(password) < p.cfg.MinCharClasses {
+		return &Error{
+			Code: InsufficientPasswordQuality,
+			Msg:  fmt.Sprintf("password must mix at least %d of lowercase, uppercase, digits and other characters", p.cfg.MinCharClasses),
+		}
+	}
+	return nil
+}
+
+// generatedPasswordLength is the length of generated passwords when the
+// policy asks for shorter ones.
+const generatedPasswordLength = 24
+
+// passwordAlphabets holds one alphabet per character class counted by
+// CheckQuality. Generated passwords use all of them.
+var passwordAlphabets = []string{
+	"abcdefghijklmnopqrstuvwxyz",
+	"ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	"0123456789",
+	"!#%+-.:=?@_",
+}
+
+// GeneratePassword returns a random password that passes CheckQuality: it
+// uses every character class and is at least MinLength characters long.
+func (p *Policy) GeneratePassword() (string, error) {
+	length := max(generatedPasswordLength, p.cfg.MinLength)
+	password := make([]byte, 0, length)
+	for _, alphabet := range passwordAlphabets {
+		c, err := randomChar(alphabet)
+		if err != nil {
+			return "", err
+		}
+		password = append(password, c)
+	}
+	all := strings.Join(passwordAlphabets, "")
+	for len(password) < length {
+		c, err := randomChar(all)
+		if err != nil {
+			return "", err
+		}
+		password = append(password, c)
+	}
+	// Shuffle so the class of the first characters is not predictable.
+	for i := len(password) - 1; i > 0; i-- {
+		j, err := randomIndex(i + 1)
+		if err != nil {
+			return "", err
+		}
+		password[i], password[j] = password[j], password[i]
+	}
+	return string(password), p.CheckQuality(string(password))
+}
+
+func randomChar(alphabet string) (byte, error) {
+	i, err := randomIndex(len(alphabet))
+	if err != nil {
+		return 0, err
+	}
+	return alphabet[i], nil
+}
+
+func randomIndex(n int) (int, error) {
+	i, err := crand.Int(crand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
+	}
+	return int(i.Int64()), nil
+}
+
+// CheckNewPassword checks a cleartext password about to replace the password
+// of the user at dn: quality, then the current password and the history.
+func (p *Policy) CheckNewPassword(ctx context.Context, dn string, password string) error {
+	if err := p.CheckQuality(password); err != nil {
+		return err
+	}
+	if p.cfg.HistoryCount == 0 {
+		return nil
+	}
+
+	current, _, err := p.store.GetUserPasswordHashByDN(ctx, dn)
+	if err != nil {
+		return err
+	}
+	history, err := p.store.GetPasswordHistory(ctx, dn)
+	if err != nil {
+		return err
+	}
+	if len(history) > p.cfg.HistoryCount {
+		history = history[:p.cfg.HistoryCount]
+	}
+	for _, hash := range append([]string{current}, history...) {
+		if hash == "" {
+			continue
+		}
+		if match, err := p.hasher.Verify(password, hash); err == nil && match {
+			return &Error{Code: PasswordInHistory, Msg: "password was used recently"}
+		}
+	}
+	return nil
+}
+
+// Authenticate runs a bind for the user at dn under the policy. verify checks
+// the supplied credentials and is not called while the account is locked.
+// It returns ErrInvalidCredentials when verify fails and an *Error when the
+// account is locked or the password has expired. An error from verify itself,
+// such as a busy password hasher, is returned as is and is not counted as a
+// failure.
+func (p *Policy) Authenticate(ctx context.Context, dn string, verify func() (bool, error)) (BindResult, error) {
+	result := BindResult{TimeBeforeExpiration: -1, GraceAuthNsRemaining: -1}
+	if dn == "" || (p.cfg.MaxFailures == 0 && p.cfg.MaxAge == 0) {
+		return result, verifyCredentials(verify)
+	}
+
+	state, err := p.store.GetPasswordPolicyState(ctx, dn)
+	if err != nil {
+		return result, err
+	}
+	if state == nil {
+		return result, verifyCredentials(verify)
+	}
+	now := p.now()
+
+	if p.cfg.MaxFailures > 0 && !state.AccountLockedTime.IsZero() {
+		if p.cfg.LockoutDuration == 0 || now.Before(state.AccountLockedTime.Add(seconds(p.cfg.LockoutDuration))) {
+			slog.Info("Bind rejected for locked account", "dn", dn)
+			return result, &Error{Code: AccountLocked, Msg: "account is locked"}
+		}
+		if err := p.store.ResetPasswordFailures(ctx, dn); err != nil {
+			return result, err
+		}
+		state.FailureTimes = nil
+	}
+
+	if err := verifyCredentials(verify); err != nil {
+		if !errors.Is(err, ErrInvalidCredentials) {
+			return result, err
+		}
+		if p.cfg.MaxFailures > 0 {
+			if err := p.recordFailure(ctx, dn, now); err != nil {
+				return result, err
+			}
+		}
+		return result, ErrInvalidCredentials
+	}
+	if p.cfg.MaxFailures > 0 && len(state.FailureTimes) > 0 {
+		if err := p.store.ResetPasswordFailures(ctx, dn); err != nil {
+			return result, err
+		}
+	}
+
+	if p.cfg.MaxAge == 0 || state.ChangedTime.IsZero() {
+		return result, nil
+	}
+	expires := state.ChangedTime.Add(seconds(p.cfg.MaxAge))
+	if now.Before(expires) {
+		if remaining := expires.Sub(now); p.cfg.ExpireWarning > 0 && remaining <= seconds(p.cfg.ExpireWarning) {
+			result.TimeBeforeExpiration = int(remaining / time.Second)
+		}
+		return result, nil
+	}
+	if state.GraceUseCount >= p.cfg.GraceLogins {
+		slog.Info("Bind rejected for expired password", "dn", dn)
+		return result, &Error{Code: PasswordExpired, Msg: "password has expired"}
+	}
+	if err := p.store.RecordGraceLogin(ctx, dn); err != nil {
+		return result, err
+	}
+	result.GraceAuthNsRemaining = p.cfg.GraceLogins - state.GraceUseCount - 1
+	return result, nil
+}
+
+// recordFailure stores a failed bind. The store locks the account once the
+// failures within the counting interval reach MaxFailures, counting them in
+// the same transaction so concurrent binds see each other's failures.
+func (p *Policy) recordFailure(ctx context.Context, dn string, now time.Time) error {
+	var expireBefore time.Time
+	if p.cfg.FailureCountInterval > 0 {
+		expireBefore = now.Add(-seconds(p.cfg.FailureCountInterval))
+	}
+	locked, err := p.store.RecordPasswordFailure(ctx, dn, now, expireBefore, p.cfg.MaxFailures)
+	if err != nil {
+		return err
+	}
+	if locked {
+		slog.Warn("Account locked after repeated bind failures", "dn", dn, "maxFailures", p.cfg.MaxFailures)
+	}
+	return nil
+}
+
+// verifyCredentials runs verify, reporting a mismatch as ErrInvalidCredentials.
+func verifyCredentials(verify func() (bool, error)) error {
+	valid, err := verify()
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return ErrInvalidCredentials
+	}
+	return nil
+}
+
+func seconds(n int) time.Duration {
+	return time.Duration(n) * time.Second
+}
+
+// charClasses counts the character classes used in a password.
+func charClasses(password string) int {
+	var lower, upper, digit, other bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+	count := 0
+	for _, used := range []bool{lower, upper, digit, other} {
+		if used {
+			count++
+		}
+	}
+	return count
+}
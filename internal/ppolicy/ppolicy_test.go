@@ -0,0 +1,295 @@
+package ppolicy
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/smarzola/ldaplite/internal/models"
+	"github.com/smarzola/ldaplite/internal/store"
+	"github.com/smarzola/ldaplite/pkg/config"
+	"github.com/smarzola/ldaplite/pkg/crypto"
+)
+
+const janeDN = "uid=jane,ou=users,dc=example,dc=com"
+
+type fakeStore struct {
+	hash    string
+	history []string
+	state   store.PasswordPolicyState
+}
+
+func (s *fakeStore) GetUserPasswordHashByDN(ctx context.Context, dn string) (string, string, error) {
+	return s.hash, dn, nil
+}
+
+func (s *fakeStore) GetPasswordPolicyState(ctx context.Context, dn string) (*store.PasswordPolicyState, error) {
+	state := s.state
+	state.FailureTimes = append([]time.Time(nil), s.state.FailureTimes...)
+	return &state, nil
+}
+
+func (s *fakeStore) RecordPasswordFailure(ctx context.Context, dn string, failureTime, expireBefore time.Time, maxFailures int) (bool, error) {
+	var kept []time.Time
+	for _, t := range s.state.FailureTimes {
+		if !t.Before(expireBefore) {
+			kept = append(kept, t)
+		}
+	}
+	s.state.FailureTimes = append(kept, failureTime)
+	if maxFailures > 0 && len(s.state.FailureTimes) >= maxFailures && s.state.AccountLockedTime.IsZero() {
+		s.state.AccountLockedTime = failureTime
+		return true, nil
+	}
+	return false, nil
+}
+
+func (s *fakeStore) ResetPasswordFailures(ctx context.Context, dn string) error {
+	s.state.FailureTimes = nil
+	s.state.AccountLockedTime = time.Time{}
+	return nil
+}
+
+func (s *fakeStore) RecordGraceLogin(ctx context.Context, dn string) error {
+	s.state.GraceUseCount++
+	return nil
+}
+
+func (s *fakeStore) GetPasswordHistory(ctx context.Context, dn string) ([]string, error) {
+	return s.history, nil
+}
+
+func newTestPolicy(cfg config.PasswordPolicyConfig, st *fakeStore, now *time.Time) *Policy {
+	policy := New(cfg, st, crypto.NewPasswordHasher(config.Argon2Config{
+		Memory:      64,
+		Iterations:  1,
+		Parallelism: 1,
+		SaltLength:  16,
+		KeyLength:   32,
+	}))
+	policy.now = func() time.Time { return *now }
+	return policy
+}
+
+func policyErrorCode(t *testing.T, err error) ErrorCode {
+	t.Helper()
+	var policyErr *Error
+	if !errors.As(err, &policyErr) {
+		t.Fatalf("error = %v, want a password policy error", err)
+	}
+	return policyErr.Code
+}
+
+func TestCheckQuality(t *testing.T) {
+	policy := newTestPolicy(config.PasswordPolicyConfig{MinLength: 8, MinCharClasses: 3}, &fakeStore{}, new(time.Time))
+
+	if code := policyErrorCode(t, policy.CheckQuality("Ab1!")); code != PasswordTooShort {
+		t.Fatalf("short password code = %d, want passwordTooShort", code)
+	}
+	if code := policyErrorCode(t, policy.CheckQuality("abcdefgh1")); code != InsufficientPasswordQuality {
+		t.Fatalf("two-class password code = %d, want insufficientPasswordQuality", code)
+	}
+	if err := policy.CheckQuality("Password123"); err != nil {
+		t.Fatalf("CheckQuality() = %v, want nil", err)
+	}
+	if !errors.Is(policy.CheckQuality("short"), ErrPolicyViolation) {
+		t.Fatal("policy errors should match ErrPolicyViolation")
+	}
+}
+
+func TestGeneratePasswordPassesQuality(t *testing.T) {
+	for _, cfg := range []config.PasswordPolicyConfig{
+		{},
+		{MinLength: 12, MinCharClasses: 4},
+		{MinLength: 40, MinCharClasses: 4},
+	} {
+		policy := newTestPolicy(cfg, &fakeStore{}, new(time.Time))
+		for range 50 {
+			password, err := policy.GeneratePassword()
+			if err != nil {
+				t.Fatalf("GeneratePassword() failed: %v", err)
+			}
+			if len(password) < max(cfg.MinLength, 24) || charClasses(password) != 4 {
+				t.Fatalf("GeneratePassword() = %q, want all four classes and at least %d characters", password, max(cfg.MinLength, 24))
+			}
+		}
+	}
+}
+
+func TestCheckNewPasswordRejectsCurrentAndHistory(t *testing.T) {
+	st := &fakeStore{}
+	policy := newTestPolicy(config.PasswordPolicyConfig{HistoryCount: 1}, st, new(time.Time))
+	for _, password := range []string{"current", "previous", "older"} {
+		hash, err := policy.hasher.Hash(password)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st.hash == "" {
+			st.hash = hash
+		} else {
+			st.history = append(st.history, hash)
+		}
+	}
+
+	for _, password := range []string{"current", "previous"} {
+		if code := policyErrorCode(t, policy.CheckNewPassword(context.Background(), janeDN, password)); code != PasswordInHistory {
+			t.Fatalf("CheckNewPassword(%q) code = %d, want passwordInHistory", password, code)
+		}
+	}
+	// Only HistoryCount previous passwords are remembered.
+	if err := policy.CheckNewPassword(context.Background(), janeDN, "older"); err != nil {
+		t.Fatalf("CheckNewPassword(older) = %v, want nil", err)
+	}
+}
+
+func TestAuthenticateLocksAfterMaxFailures(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	st := &fakeStore{}
+	policy := newTestPolicy(config.PasswordPolicyConfig{MaxFailures: 3, LockoutDuration: 60}, st, &now)
+	ctx := context.Background()
+	fail := func() (bool, error) { return false, nil }
+	succeed := func() (bool, error) { return true, nil }
+
+	for i := 0; i < 3; i++ {
+		if _, err := policy.Authenticate(ctx, janeDN, fail); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("failed bind %d = %v, want ErrInvalidCredentials", i+1, err)
+		}
+	}
+	if st.state.AccountLockedTime.IsZero() {
+		t.Fatal("account not locked after MaxFailures failures")
+	}
+
+	verified := false
+	_, err := policy.Authenticate(ctx, janeDN, func() (bool, error) { verified = true; return true, nil })
+	if code := policyErrorCode(t, err); code != AccountLocked || verified {
+		t.Fatalf("bind while locked: code %d, verified %v; want accountLocked without verifying", code, verified)
+	}
+
+	now = now.Add(61 * time.Second)
+	if _, err := policy.Authenticate(ctx, janeDN, succeed); err != nil {
+		t.Fatalf("bind after lockout duration = %v, want success", err)
+	}
+	if !st.state.AccountLockedTime.IsZero() || len(st.state.FailureTimes) != 0 {
+		t.Fatalf("state after successful bind = %+v, want unlocked without failures", st.state)
+	}
+}
+
+func TestAuthenticateDoesNotCountVerifyErrors(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	st := &fakeStore{}
+	policy := newTestPolicy(config.PasswordPolicyConfig{MaxFailures: 1}, st, &now)
+
+	_, err := policy.Authenticate(context.Background(), janeDN, func() (bool, error) { return false, crypto.ErrHashPoolBusy })
+	if !errors.Is(err, crypto.ErrHashPoolBusy) {
+		t.Fatalf("Authenticate() = %v, want the verify error", err)
+	}
+	if len(st.state.FailureTimes) != 0 || !st.state.AccountLockedTime.IsZero() {
+		t.Fatalf("state after verify error = %+v, want no failure recorded", st.state)
+	}
+}
+
+func TestAuthenticateForgetsFailuresOutsideInterval(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	st := &fakeStore{}
+	policy := newTestPolicy(config.PasswordPolicyConfig{MaxFailures: 2, FailureCountInterval: 30}, st, &now)
+	fail := func() (bool, error) { return false, nil }
+
+	_, _ = policy.Authenticate(context.Background(), janeDN, fail)
+	now = now.Add(time.Minute)
+	_, _ = policy.Authenticate(context.Background(), janeDN, fail)
+
+	if !st.state.AccountLockedTime.IsZero() {
+		t.Fatal("account locked by failures outside the counting interval")
+	}
+	if len(st.state.FailureTimes) != 1 {
+		t.Fatalf("failure times = %v, want only the recent failure", st.state.FailureTimes)
+	}
+}
+
+func TestAuthenticateExpiry(t *testing.T) {
+	changed := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := changed.Add(50 * time.Second)
+	st := &fakeStore{state: store.PasswordPolicyState{ChangedTime: changed}}
+	policy := newTestPolicy(config.PasswordPolicyConfig{MaxAge: 60, ExpireWarning: 20, GraceLogins: 1}, st, &now)
+	ctx := context.Background()
+	succeed := func() (bool, error) { return true, nil }
+
+	result, err := policy.Authenticate(ctx, janeDN, succeed)
+	if err != nil || result.TimeBeforeExpiration != 10 || result.GraceAuthNsRemaining != -1 {
+		t.Fatalf("bind before expiry = %+v, %v; want a 10 second warning", result, err)
+	}
+
+	now = changed.Add(2 * time.Minute)
+	result, err = policy.Authenticate(ctx, janeDN, succeed)
+	if err != nil || result.GraceAuthNsRemaining != 0 || result.TimeBeforeExpiration != -1 {
+		t.Fatalf("grace bind = %+v, %v; want success with no grace logins left", result, err)
+	}
+
+	_, err = policy.Authenticate(ctx, janeDN, succeed)
+	if code := policyErrorCode(t, err); code != PasswordExpired {
+		t.Fatalf("bind after grace logins code = %d, want passwordExpired", code)
+	}
+}
+
+func TestConcurrentFailedBindsLockAccount(t *testing.T) {
+	cfg := &config.Config{
+		Database: config.DatabaseConfig{Path: t.TempDir() + "/test.db"},
+		LDAP:     config.LDAPConfig{BaseDN: "dc=test,dc=com"},
+		Security: config.SecurityConfig{
+			Argon2Config: config.Argon2Config{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32},
+		},
+	}
+	t.Setenv("LDAP_ADMIN_PASSWORD", "test_admin_password")
+	st := store.NewSQLiteStore(cfg)
+	ctx := context.Background()
+	if err := st.Initialize(ctx); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+	defer st.Close()
+	user := models.NewUser("ou=users,dc=test,dc=com", "jane", "Jane", "Doe", "jane@example.com")
+	user.SetPassword("{ARGON2ID}$argon2id$v=19$m=64,t=1,p=1$c2FsdA$aGFzaA")
+	if err := st.CreateEntry(ctx, user.Entry); err != nil {
+		t.Fatalf("CreateEntry() error = %v", err)
+	}
+	policy := New(config.PasswordPolicyConfig{MaxFailures: 3}, st, crypto.NewPasswordHasher(cfg.Security.Argon2Config))
+
+	// Every bind reads the unlocked state before any of them records its
+	// failure, so only the store can see that the limit was reached.
+	const binds = 8
+	var verifying, done sync.WaitGroup
+	verifying.Add(binds)
+	errs := make(chan error, binds)
+	for range binds {
+		done.Add(1)
+		go func() {
+			defer done.Done()
+			_, err := policy.Authenticate(ctx, user.DN, func() (bool, error) {
+				verifying.Done()
+				verifying.Wait()
+				return false, nil
+			})
+			errs <- err
+		}()
+	}
+	done.Wait()
+	close(errs)
+	for err := range errs {
+		if !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("failed bind = %v, want ErrInvalidCredentials", err)
+		}
+	}
+
+	state, err := st.GetPasswordPolicyState(ctx, user.DN)
+	if err != nil {
+		t.Fatalf("GetPasswordPolicyState() error = %v", err)
+	}
+	if len(state.FailureTimes) != binds || state.AccountLockedTime.IsZero() {
+		t.Fatalf("state after concurrent failures = %+v, want %d failures and a lock", state, binds)
+	}
+	_, err = policy.Authenticate(ctx, user.DN, func() (bool, error) { return true, nil })
+	if code := policyErrorCode(t, err); code != AccountLocked {
+		t.Fatalf("bind after concurrent failures: code %d, want accountLocked", code)
+	}
+}
@@ -11,6 +11,12 @@ type Packet struct {
 }
 
 func ReadPacket(data []byte) (Packet, int, error) {
+	return ReadPacketLimit(data, MaxPacketSize)
+}
+
+// ReadPacketLimit reads a packet like ReadPacket but rejects one whose
+// declared length exceeds maxSize, before looking at its content.
+func ReadPacketLimit(data []byte, maxSize int) (Packet, int, error) {
 	if len(data) < 2 {
 		return Packet{}, 0, fmt.Errorf("BER packet too short")
 	}
@@ -20,8 +26,8 @@ func ReadPacket(data []byte) (Packet, int, error) {
 	if err != nil {
 		return Packet{}, 0, err
 	}
-	if length > MaxPacketSize {
-		return Packet{}, 0, fmt.Errorf("BER packet length %d exceeds limit", length)
+	if length > maxSize {
+		return Packet{}, 0, fmt.Errorf("BER packet length %d exceeds limit of %d bytes", length, maxSize)
 	}
 	totalLen := headerLen + length
 	if totalLen > len(data) {
@@ -44,6 +44,11 @@ func Enumerated(value int) []byte {
 	return TLV(ClassUniversal|TagEnumerated, signedIntegerBytes(value))
 }
 
+// TaggedInteger encodes an INTEGER or ENUMERATED value under an implicit tag.
+func TaggedInteger(tag byte, value int) []byte {
+	return TLV(tag, signedIntegerBytes(value))
+}
+
 func Boolean(value bool) []byte {
 	if value {
 		return TLV(ClassUniversal|TagBoolean, []byte{0xff})
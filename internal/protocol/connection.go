@@ -3,6 +3,7 @@ package protocol
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"io"
@@ -11,6 +12,7 @@ import (
 	"strconv"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/smarzola/ldaplite/internal/audit"
 	"github.com/smarzola/ldaplite/internal/protocol/ldapmsg"
@@ -19,6 +21,10 @@ import (
 
 var nextConnectionID atomic.Uint64
 
+// maxConcurrentOperations bounds the operations one connection can have in
+// flight. The read loop stops accepting requests until one of them finishes.
+const maxConcurrentOperations = 32
+
 // Connection represents an LDAP client connection
 type Connection struct {
 	conn     net.Conn
@@ -28,7 +34,32 @@ type Connection struct {
 	bound    bool
 	boundDN  string
 	tls      bool
+	sasl     any
 	handlers OperationHandlers
+	limits   ConnectionLimits
+
+	opsMu   sync.Mutex
+	ops     map[ldapmsg.MessageID]*operation
+	opsWG   sync.WaitGroup
+	opSlots chan struct{}
+}
+
+// ConnectionLimits bounds how long a client may take to send a request or
+// accept a response, how long it may stay idle between requests, and how
+// large a request may be. Zero values disable a limit; the message size then
+// falls back to the BER decoder's own limit.
+type ConnectionLimits struct {
+	ReadTimeout    time.Duration
+	WriteTimeout   time.Duration
+	IdleTimeout    time.Duration
+	MaxMessageSize int
+}
+
+// operation is a request running concurrently with later requests on the
+// same connection. Cancelling ctx abandons it.
+type operation struct {
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 // OperationHandlers defines callbacks for LDAP operations
@@ -38,9 +69,15 @@ type OperationHandlers struct {
 	OnAdd      func(context.Context, *Connection, *ldapmsg.Message) error
 	OnModify   func(context.Context, *Connection, *ldapmsg.Message) error
 	OnDelete   func(context.Context, *Connection, *ldapmsg.Message) error
+	OnModifyDN func(context.Context, *Connection, *ldapmsg.Message) error
 	OnCompare  func(context.Context, *Connection, *ldapmsg.Message) error
 	OnExtended func(context.Context, *Connection, *ldapmsg.Message) error
 	OnUnbind   func(context.Context, *Connection, *ldapmsg.Message) error
+
+	// SupportsControl reports whether a request control OID is implemented for
+	// an operation. Critical controls it does not accept are rejected with
+	// unavailableCriticalExtension before the operation handler runs.
+	SupportsControl func(ldapmsg.Operation, string) bool
 }
 
 // NewConnection creates a new LDAP connection wrapper
@@ -49,12 +86,25 @@ func NewConnection(conn net.Conn, handlers OperationHandlers) *Connection {
 		conn:     conn,
 		id:       "ldap-" + strconv.FormatUint(nextConnectionID.Add(1), 10),
 		handlers: handlers,
+		ops:      make(map[ldapmsg.MessageID]*operation),
+		opSlots:  make(chan struct{}, maxConcurrentOperations),
 	}
 }
 
-// Handle processes incoming LDAP messages in a loop
+// SetLimits applies timeouts and the message size limit. Call it before
+// Handle.
+func (c *Connection) SetLimits(limits ConnectionLimits) {
+	c.limits = limits
+}
+
+// Handle processes incoming LDAP messages in a loop. Operations run
+// concurrently, so a slow search does not hold up requests pipelined after
+// it; see serve for the exceptions.
 func (c *Connection) Handle(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
 	defer c.Close()
+	defer c.opsWG.Wait()
+	defer cancel()
 
 	for {
 		select {
@@ -64,13 +114,26 @@ func (c *Connection) Handle(ctx context.Context) error {
 		}
 
 		// Read LDAP message from connection
-		msg, err := ReadLDAPMessage(c.conn)
+		msg, err := readLDAPMessage(c.transport(), c.limits)
 		if err != nil {
 			// EOF is normal when client disconnects
 			if errors.Is(err, io.EOF) {
 				slog.Debug("Client disconnected", "remote", c.conn.RemoteAddr())
 				return nil
 			}
+			if errors.Is(err, errIdleTimeout) {
+				// A client waiting on a slow operation is not idle.
+				if c.inFlight() {
+					continue
+				}
+				slog.Debug("Closing idle LDAP connection", "remote", c.conn.RemoteAddr(), "idle_timeout", c.limits.IdleTimeout)
+				telemetry.RecordLDAPConnectionServerClosed(ctx, "idle_timeout")
+				return nil
+			}
+			if errors.Is(err, ErrMessageTooLarge) {
+				c.noticeOfDisconnection(ldapmsg.ResultCodeProtocolError, "request exceeds maximum message size")
+				telemetry.RecordLDAPConnectionServerClosed(ctx, "message_too_large")
+			}
 			slog.Error("Failed to read LDAP message", "error", err, "remote", c.conn.RemoteAddr())
 			audit.LogLDAP(ctx, audit.LDAPEvent{
 				Event:        audit.EventLDAPReadError,
@@ -85,27 +148,157 @@ func (c *Connection) Handle(ctx context.Context) error {
 			return err
 		}
 
-		if err := c.dispatch(ctx, msg); err != nil {
-			slog.Error("Failed to handle LDAP operation", "error", err, "operation", fmt.Sprintf("%T", msg.Op))
-			audit.LogLDAP(ctx, audit.LDAPEvent{
-				Event:        audit.EventLDAPHandlerError,
-				Operation:    OperationName(msg.Op),
-				RequestID:    audit.RequestID(c.ID(), int(msg.ID)),
-				ConnectionID: c.ID(),
-				MessageID:    int(msg.ID),
-				RemoteAddr:   c.remoteAddrString(),
-				ActorDN:      c.GetBoundDN(),
-				ResultCode:   int(ldapmsg.ResultCodeOperationsError),
-				Error:        err,
-			})
-			telemetry.RecordLDAPHandlerError(ctx, OperationName(msg.Op))
-			// Continue processing other messages even if one fails
+		c.serve(ctx, msg)
+		if _, ok := msg.Op.(ldapmsg.UnbindRequest); ok {
+			return nil
+		}
+	}
+}
+
+// serve starts processing a message. Most operations run in their own
+// goroutine with a context that Abandon cancels. Bind, Extended and Unbind
+// run inline once every outstanding operation has finished: a bind changes
+// the identity later operations run as, StartTLS replaces the transport the
+// read loop uses, and unbind ends the connection. Abandon itself is handled
+// inline and has no response.
+func (c *Connection) serve(ctx context.Context, msg *ldapmsg.Message) {
+	switch req := msg.Op.(type) {
+	case ldapmsg.AbandonRequest:
+		c.abandon(ctx, msg, req.MessageID)
+		return
+	case ldapmsg.UnbindRequest:
+		c.cancelOperations()
+		c.opsWG.Wait()
+		c.run(ctx, msg)
+		return
+	case ldapmsg.BindRequest, ldapmsg.ExtendedRequest:
+		c.opsWG.Wait()
+		c.run(ctx, msg)
+		return
+	}
+
+	c.opSlots <- struct{}{}
+	op, ok := c.startOperation(ctx, msg.ID)
+	if !ok {
+		<-c.opSlots
+		slog.Warn("Duplicate in-flight LDAP message ID", "messageID", msg.ID, "remote", c.conn.RemoteAddr())
+		if resp, ok := NewResultResponse(msg.Op, ldapmsg.ResultCodeProtocolError); ok {
+			if err := c.WriteResponse(msg.ID, resp); err != nil {
+				slog.Debug("Failed to reject duplicate message ID", "error", err)
+			}
 		}
+		return
+	}
+
+	c.opsWG.Add(1)
+	go func() {
+		defer c.opsWG.Done()
+		defer func() { <-c.opSlots }()
+		defer c.finishOperation(msg.ID)
+		c.run(op.ctx, msg)
+	}()
+}
+
+// run dispatches a message and records handler failures.
+func (c *Connection) run(ctx context.Context, msg *ldapmsg.Message) {
+	if err := c.dispatch(ctx, msg); err != nil {
+		slog.Error("Failed to handle LDAP operation", "error", err, "operation", fmt.Sprintf("%T", msg.Op))
+		audit.LogLDAP(ctx, audit.LDAPEvent{
+			Event:        audit.EventLDAPHandlerError,
+			Operation:    OperationName(msg.Op),
+			RequestID:    audit.RequestID(c.ID(), int(msg.ID)),
+			ConnectionID: c.ID(),
+			MessageID:    int(msg.ID),
+			RemoteAddr:   c.remoteAddrString(),
+			ActorDN:      c.GetBoundDN(),
+			ResultCode:   int(ldapmsg.ResultCodeOperationsError),
+			Error:        err,
+		})
+		telemetry.RecordLDAPHandlerError(ctx, OperationName(msg.Op))
+		// Continue processing other messages even if one fails
+	}
+}
+
+// startOperation registers an in-flight operation. It fails when the client
+// reuses the message ID of an operation that has not finished.
+func (c *Connection) startOperation(ctx context.Context, id ldapmsg.MessageID) (*operation, bool) {
+	c.opsMu.Lock()
+	defer c.opsMu.Unlock()
+
+	if _, exists := c.ops[id]; exists {
+		return nil, false
+	}
+	opCtx, cancel := context.WithCancel(ctx)
+	op := &operation{ctx: opCtx, cancel: cancel}
+	c.ops[id] = op
+	return op, true
+}
+
+func (c *Connection) finishOperation(id ldapmsg.MessageID) {
+	c.opsMu.Lock()
+	defer c.opsMu.Unlock()
+
+	if op, ok := c.ops[id]; ok {
+		op.cancel()
+		delete(c.ops, id)
+	}
+}
+
+// inFlight reports whether any operation is still running.
+func (c *Connection) inFlight() bool {
+	c.opsMu.Lock()
+	defer c.opsMu.Unlock()
+	return len(c.ops) > 0
+}
+
+// cancelOperations abandons every in-flight operation.
+func (c *Connection) cancelOperations() {
+	c.opsMu.Lock()
+	defer c.opsMu.Unlock()
+
+	for _, op := range c.ops {
+		op.cancel()
 	}
 }
 
+// abandon cancels the in-flight operation with the given message ID (RFC 4511
+// section 4.11). Unknown or finished IDs are ignored.
+func (c *Connection) abandon(ctx context.Context, msg *ldapmsg.Message, id ldapmsg.MessageID) {
+	c.opsMu.Lock()
+	op, ok := c.ops[id]
+	if ok {
+		op.cancel()
+	}
+	c.opsMu.Unlock()
+
+	slog.Debug("Abandon request", "abandonedMessageID", id, "inFlight", ok)
+	audit.LogLDAP(ctx, audit.LDAPEvent{
+		Operation:    OperationName(msg.Op),
+		RequestID:    audit.RequestID(c.ID(), int(msg.ID)),
+		ConnectionID: c.ID(),
+		MessageID:    int(msg.ID),
+		RemoteAddr:   c.remoteAddrString(),
+		ActorDN:      c.GetBoundDN(),
+		ResultCode:   int(ldapmsg.ResultCodeSuccess),
+	})
+}
+
+// abandoned reports whether the operation with the given message ID was
+// cancelled. Its responses are no longer sent.
+func (c *Connection) abandoned(id ldapmsg.MessageID) bool {
+	c.opsMu.Lock()
+	defer c.opsMu.Unlock()
+
+	op, ok := c.ops[id]
+	return ok && op.ctx.Err() != nil
+}
+
 // dispatch routes the message to the appropriate handler
 func (c *Connection) dispatch(ctx context.Context, msg *ldapmsg.Message) error {
+	if control, ok := c.unsupportedCriticalControl(msg); ok {
+		return c.rejectCriticalControl(ctx, msg, control)
+	}
+
 	switch msg.Op.(type) {
 	case ldapmsg.BindRequest:
 		if c.handlers.OnBind != nil {
@@ -132,6 +325,11 @@ func (c *Connection) dispatch(ctx context.Context, msg *ldapmsg.Message) error {
 			return c.handlers.OnDelete(ctx, c, msg)
 		}
 
+	case ldapmsg.ModifyDNRequest:
+		if c.handlers.OnModifyDN != nil {
+			return c.handlers.OnModifyDN(ctx, c, msg)
+		}
+
 	case ldapmsg.CompareRequest:
 		if c.handlers.OnCompare != nil {
 			return c.handlers.OnCompare(ctx, c, msg)
@@ -165,6 +363,41 @@ func (c *Connection) dispatch(ctx context.Context, msg *ldapmsg.Message) error {
 	return nil
 }
 
+func (c *Connection) unsupportedCriticalControl(msg *ldapmsg.Message) (ldapmsg.Control, bool) {
+	for _, control := range msg.Controls {
+		if !control.Criticality {
+			continue
+		}
+		if c.handlers.SupportsControl == nil || !c.handlers.SupportsControl(msg.Op, control.OID) {
+			return control, true
+		}
+	}
+	return ldapmsg.Control{}, false
+}
+
+// rejectCriticalControl answers a request carrying an unsupported critical
+// control with unavailableCriticalExtension (RFC 4511 section 4.1.11).
+func (c *Connection) rejectCriticalControl(ctx context.Context, msg *ldapmsg.Message, control ldapmsg.Control) error {
+	slog.Debug("Unsupported critical control", "operation", OperationName(msg.Op), "oid", control.OID)
+	audit.LogLDAP(ctx, audit.LDAPEvent{
+		Operation:    OperationName(msg.Op),
+		RequestID:    audit.RequestID(c.ID(), int(msg.ID)),
+		ConnectionID: c.ID(),
+		MessageID:    int(msg.ID),
+		RemoteAddr:   c.remoteAddrString(),
+		ActorDN:      c.GetBoundDN(),
+		OID:          control.OID,
+		ResultCode:   int(ldapmsg.ResultCodeUnavailableCriticalExtension),
+	})
+
+	resp, ok := NewResultResponse(msg.Op, ldapmsg.ResultCodeUnavailableCriticalExtension)
+	if !ok {
+		// Unbind has no response; the request is simply not acted upon.
+		return nil
+	}
+	return c.WriteResponse(msg.ID, resp)
+}
+
 // OperationName returns a stable lowercase operation name for an LDAP message operation.
 func OperationName(op ldapmsg.Operation) string {
 	switch op.(type) {
@@ -178,19 +411,34 @@ func OperationName(op ldapmsg.Operation) string {
 		return "modify"
 	case ldapmsg.DeleteRequest:
 		return "delete"
+	case ldapmsg.ModifyDNRequest:
+		return "modifydn"
 	case ldapmsg.CompareRequest:
 		return "compare"
 	case ldapmsg.ExtendedRequest:
 		return "extended"
 	case ldapmsg.UnbindRequest:
 		return "unbind"
+	case ldapmsg.AbandonRequest:
+		return "abandon"
 	default:
 		return "unsupported"
 	}
 }
 
-// WriteResponse writes an LDAP response message
-func (c *Connection) WriteResponse(messageID ldapmsg.MessageID, response ldapmsg.Operation) error {
+// WriteResponse writes an LDAP response message with optional response controls
+func (c *Connection) WriteResponse(messageID ldapmsg.MessageID, response ldapmsg.Operation, controls ...ldapmsg.Control) error {
+	// An abandoned operation gets no further responses (RFC 4511 section
+	// 4.11); its handler stops once it notices the cancelled context.
+	if c.abandoned(messageID) {
+		return nil
+	}
+
+	data, err := EncodeLDAPResponse(messageID, response, controls...)
+	if err != nil {
+		return err
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -198,7 +446,33 @@ func (c *Connection) WriteResponse(messageID ldapmsg.MessageID, response ldapmsg
 		return fmt.Errorf("connection closed")
 	}
 
-	return WriteLDAPResponse(c.conn, messageID, response)
+	if err := c.conn.SetWriteDeadline(deadline(c.limits.WriteTimeout)); err != nil {
+		return fmt.Errorf("failed to set write deadline: %w", err)
+	}
+	if _, err := c.conn.Write(data); err != nil {
+		// A partly written response leaves the stream unusable, and a client
+		// that stopped reading should not hold the connection open.
+		c.closed = true
+		_ = c.conn.Close()
+		return fmt.Errorf("failed to write to connection: %w", err)
+	}
+	return nil
+}
+
+// noticeOfDisconnection tells the client the server is closing the
+// connection (RFC 4511 section 4.4.1). Delivery is best effort.
+func (c *Connection) noticeOfDisconnection(resultCode ldapmsg.ResultCode, diagnosticMessage string) {
+	resp := NewNoticeOfDisconnection(resultCode, diagnosticMessage)
+	if err := c.WriteResponse(0, resp); err != nil {
+		slog.Debug("Failed to send notice of disconnection", "error", err)
+	}
+}
+
+// transport returns the current net.Conn, which StartTLS replaces.
+func (c *Connection) transport() net.Conn {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn
 }
 
 // WriteError writes an error response
@@ -229,6 +503,21 @@ func (c *Connection) MarkTLS() {
 	c.tls = true
 }
 
+// VerifiedClientCertificate returns the client certificate verified during
+// the TLS handshake, or nil when the client presented none or the connection
+// does not use TLS.
+func (c *Connection) VerifiedClientCertificate() *x509.Certificate {
+	tlsConn, ok := c.transport().(*tls.Conn)
+	if !ok {
+		return nil
+	}
+	state := tlsConn.ConnectionState()
+	if len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return state.VerifiedChains[0][0]
+}
+
 // StartTLS upgrades the existing LDAP connection after the StartTLS success
 // response has been sent.
 func (c *Connection) StartTLS(cfg *tls.Config) error {
@@ -246,11 +535,15 @@ func (c *Connection) StartTLS(cfg *tls.Config) error {
 	c.tls = true
 	c.mu.Unlock()
 
+	if err := tlsConn.SetDeadline(deadline(c.limits.ReadTimeout)); err != nil {
+		_ = tlsConn.Close()
+		return fmt.Errorf("failed to set TLS handshake deadline: %w", err)
+	}
 	if err := tlsConn.Handshake(); err != nil {
 		_ = tlsConn.Close()
 		return fmt.Errorf("TLS handshake failed: %w", err)
 	}
-	return nil
+	return tlsConn.SetDeadline(time.Time{})
 }
 
 // RemoteAddrString returns the remote address string, or an empty string when
@@ -281,6 +574,25 @@ func (c *Connection) ClearBoundDN() {
 	c.boundDN = ""
 }
 
+// SetSASLState keeps the server side of a multi-step SASL bind until the
+// client's next bind request.
+func (c *Connection) SetSASLState(state any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sasl = state
+}
+
+// TakeSASLState returns and clears the state saved by SetSASLState. Every
+// bind request takes it, so any other bind abandons an exchange in progress
+// (RFC 4513 section 5.2.1.2).
+func (c *Connection) TakeSASLState() any {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	state := c.sasl
+	c.sasl = nil
+	return state
+}
+
 // IsBound reports whether this connection has completed a successful bind.
 func (c *Connection) IsBound() bool {
 	c.mu.Lock()
@@ -1,7 +1,10 @@
 package protocol
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"io"
 	"net"
 	"testing"
 	"time"
@@ -44,6 +47,18 @@ func TestConnectionBoundState(t *testing.T) {
 	}
 }
 
+func TestConnectionTakeSASLStateClearsIt(t *testing.T) {
+	conn := NewConnection(nil, OperationHandlers{})
+
+	conn.SetSASLState("step one")
+	if got := conn.TakeSASLState(); got != "step one" {
+		t.Fatalf("TakeSASLState() = %v, want saved state", got)
+	}
+	if got := conn.TakeSASLState(); got != nil {
+		t.Fatalf("second TakeSASLState() = %v, want nil", got)
+	}
+}
+
 func TestHandleReturnsNilWhenClientDisconnects(t *testing.T) {
 	serverConn, clientConn := net.Pipe()
 	conn := NewConnection(serverConn, OperationHandlers{})
@@ -67,6 +82,63 @@ func TestHandleReturnsNilWhenClientDisconnects(t *testing.T) {
 	}
 }
 
+func TestHandleClosesIdleConnection(t *testing.T) {
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+	conn := NewConnection(serverConn, OperationHandlers{})
+	conn.SetLimits(ConnectionLimits{IdleTimeout: 20 * time.Millisecond})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- conn.Handle(context.Background())
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Handle() error = %v, want nil", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Handle() did not return after the idle timeout")
+	}
+}
+
+func TestHandleSendsNoticeOfDisconnectionForOversizedMessage(t *testing.T) {
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+	conn := NewConnection(serverConn, OperationHandlers{})
+	conn.SetLimits(ConnectionLimits{MaxMessageSize: 16})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- conn.Handle(context.Background())
+	}()
+
+	if _, err := clientConn.Write([]byte{0x30, 0x20}); err != nil {
+		t.Fatalf("client write: %v", err)
+	}
+	want, err := EncodeLDAPResponse(0, NewNoticeOfDisconnection(ldapmsg.ResultCodeProtocolError, "request exceeds maximum message size"))
+	if err != nil {
+		t.Fatalf("EncodeLDAPResponse() failed: %v", err)
+	}
+	got := make([]byte, len(want))
+	if _, err := io.ReadFull(clientConn, got); err != nil {
+		t.Fatalf("read notice of disconnection: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("notice of disconnection = %x, want %x", got, want)
+	}
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrMessageTooLarge) {
+			t.Fatalf("Handle() error = %v, want ErrMessageTooLarge", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Handle() did not return after an oversized message")
+	}
+}
+
 func TestDispatchPassesContextToHandler(t *testing.T) {
 	type contextKey string
 	key := contextKey("request-id")
@@ -88,3 +160,141 @@ func TestDispatchPassesContextToHandler(t *testing.T) {
 		t.Fatalf("handler context value = %q, want abc123", got)
 	}
 }
+
+func TestDispatchRejectsUnsupportedCriticalControl(t *testing.T) {
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+
+	called := false
+	conn := NewConnection(serverConn, OperationHandlers{
+		OnDelete: func(ctx context.Context, conn *Connection, msg *ldapmsg.Message) error {
+			called = true
+			return nil
+		},
+		SupportsControl: func(op ldapmsg.Operation, oid string) bool {
+			return oid == PagedResultsControlOID
+		},
+	})
+	defer conn.Close()
+
+	msg := &ldapmsg.Message{
+		ID: 3,
+		Op: ldapmsg.DeleteRequest{DN: "uid=jane,ou=users,dc=example,dc=com"},
+		Controls: []ldapmsg.Control{
+			{OID: PagedResultsControlOID, Criticality: true},
+			{OID: "1.2.3.4"},
+			{OID: "1.2.3.5", Criticality: true},
+		},
+	}
+
+	respCh := make(chan []byte, 1)
+	go func() {
+		buf := make([]byte, 64)
+		n, _ := clientConn.Read(buf)
+		respCh <- buf[:n]
+	}()
+
+	if err := conn.dispatch(context.Background(), msg); err != nil {
+		t.Fatalf("dispatch() failed: %v", err)
+	}
+	if called {
+		t.Fatal("delete handler ran despite unsupported critical control")
+	}
+
+	want, err := EncodeLDAPResponse(3, NewDelResponse(ldapmsg.ResultCodeUnavailableCriticalExtension))
+	if err != nil {
+		t.Fatalf("EncodeLDAPResponse() failed: %v", err)
+	}
+	select {
+	case got := <-respCh:
+		if !bytes.Equal(got, want) {
+			t.Fatalf("response = %x, want %x", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no response written")
+	}
+}
+
+func TestHandleRunsPipelinedOperationsConcurrentlyAndAbandons(t *testing.T) {
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+
+	searchStarted := make(chan struct{})
+	searchStopped := make(chan struct{})
+	conn := NewConnection(serverConn, OperationHandlers{
+		OnSearch: func(ctx context.Context, conn *Connection, msg *ldapmsg.Message) error {
+			close(searchStarted)
+			<-ctx.Done()
+			// Abandoned operations must not reach the client.
+			err := conn.WriteResponse(msg.ID, NewSearchResultDone(ldapmsg.ResultCodeSuccess))
+			close(searchStopped)
+			return err
+		},
+		OnDelete: func(ctx context.Context, conn *Connection, msg *ldapmsg.Message) error {
+			return conn.WriteResponse(msg.ID, NewDelResponse(ldapmsg.ResultCodeSuccess))
+		},
+	})
+	go conn.Handle(context.Background())
+
+	search := []byte{
+		0x30, 0x25,
+		0x02, 0x01, 0x01,
+		0x63, 0x20,
+		0x04, 0x00,
+		0x0a, 0x01, 0x02,
+		0x0a, 0x01, 0x00,
+		0x02, 0x01, 0x00,
+		0x02, 0x01, 0x00,
+		0x01, 0x01, 0x00,
+		0x87, 0x0b, 'o', 'b', 'j', 'e', 'c', 't', 'C', 'l', 'a', 's', 's',
+		0x30, 0x00,
+	}
+	deleteRequest := func(id byte) []byte {
+		return []byte{0x30, 0x06, 0x02, 0x01, id, 0x4a, 0x01, 'x'}
+	}
+	abandon := []byte{0x30, 0x06, 0x02, 0x01, 0x03, 0x50, 0x01, 0x01}
+
+	write := func(wire []byte) {
+		t.Helper()
+		if _, err := clientConn.Write(wire); err != nil {
+			t.Fatalf("client write: %v", err)
+		}
+	}
+	readResponse := func(id ldapmsg.MessageID) {
+		t.Helper()
+		want, err := EncodeLDAPResponse(id, NewDelResponse(ldapmsg.ResultCodeSuccess))
+		if err != nil {
+			t.Fatalf("EncodeLDAPResponse() failed: %v", err)
+		}
+		_ = clientConn.SetReadDeadline(time.Now().Add(time.Second))
+		got := make([]byte, 64)
+		n, err := clientConn.Read(got)
+		if err != nil {
+			t.Fatalf("client read: %v", err)
+		}
+		if !bytes.Equal(got[:n], want) {
+			t.Fatalf("response = %x, want delete response %x", got[:n], want)
+		}
+	}
+	waitFor := func(ch chan struct{}, what string) {
+		t.Helper()
+		select {
+		case <-ch:
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+
+	write(search)
+	waitFor(searchStarted, "search to start")
+
+	// The delete is answered while the search is still running.
+	write(deleteRequest(2))
+	readResponse(2)
+
+	write(abandon)
+	waitFor(searchStopped, "abandoned search to stop")
+
+	write(deleteRequest(4))
+	readResponse(4)
+}
@@ -0,0 +1,372 @@
+package protocol
+
+import (
+	"fmt"
+
+	"github.com/smarzola/ldaplite/internal/protocol/ber"
+	"github.com/smarzola/ldaplite/internal/protocol/ldapmsg"
+)
+
+const (
+	PagedResultsControlOID = "1.2.840.113556.1.4.319"
+	SortRequestControlOID  = "1.2.840.113556.1.4.473"
+	SortResponseControlOID = "1.2.840.113556.1.4.474"
+
+	VirtualListViewRequestControlOID  = "2.16.840.1.113730.3.4.9"
+	VirtualListViewResponseControlOID = "2.16.840.1.113730.3.4.10"
+
+	// PasswordPolicyControlOID is used by both the password policy request
+	// control and its response (draft-behera-ldap-password-policy).
+	PasswordPolicyControlOID = "1.3.6.1.4.1.42.2.27.8.5.1"
+
+	// GetEffectiveRightsControlOID requests the rights of an authorization
+	// identity on each entry a search returns (draft-ietf-ldapext-acl-model).
+	GetEffectiveRightsControlOID = "1.3.6.1.4.1.42.2.27.9.5.2"
+
+	// ProxiedAuthorizationControlOID runs an operation as another
+	// authorization identity (RFC 4370). Its value is the authzId itself.
+	ProxiedAuthorizationControlOID = "2.16.840.1.113730.3.4.18"
+
+	// AssertionControlOID makes a write conditional on a filter matching its
+	// target entry (RFC 4528). Its value is the filter.
+	AssertionControlOID = "1.3.6.1.1.12"
+
+	// PreReadControlOID and PostReadControlOID return the target entry of a
+	// write as it was before or is after the write (RFC 4527).
+	PreReadControlOID  = "1.3.6.1.1.13.1"
+	PostReadControlOID = "1.3.6.1.1.13.2"
+
+	// TreeDeleteControlOID makes a delete remove the target entry with its
+	// whole subtree (draft-armijo-ldap-treedelete). It has no value.
+	TreeDeleteControlOID = "1.2.840.113556.1.4.805"
+)
+
+// PagedResults is the value of a simple paged results control (RFC 2696).
+// In requests Size is the requested page size; in responses it is the
+// server's estimate of the total result count, or zero when unknown.
+type PagedResults struct {
+	Size   int
+	Cookie string
+}
+
+// DecodePagedResultsControl decodes the value of a paged results request control.
+func DecodePagedResultsControl(control ldapmsg.Control) (PagedResults, error) {
+	packet, err := controlValueSequence("paged results", control)
+	if err != nil {
+		return PagedResults{}, err
+	}
+	if len(packet.Children) != 2 {
+		return PagedResults{}, fmt.Errorf("paged results control has %d fields, want 2", len(packet.Children))
+	}
+	if err := packet.Children[0].RequireTag(ber.ClassUniversal | ber.TagInteger); err != nil {
+		return PagedResults{}, fmt.Errorf("paged results size: %w", err)
+	}
+	size, err := packet.Children[0].Int()
+	if err != nil {
+		return PagedResults{}, fmt.Errorf("paged results size: %w", err)
+	}
+	if err := packet.Children[1].RequireTag(ber.ClassUniversal | ber.TagOctet); err != nil {
+		return PagedResults{}, fmt.Errorf("paged results cookie: %w", err)
+	}
+	return PagedResults{Size: size, Cookie: packet.Children[1].String()}, nil
+}
+
+// NewPagedResultsControl creates a paged results response control.
+func NewPagedResultsControl(size int, cookie string) ldapmsg.Control {
+	value := string(ber.Sequence(
+		ber.Integer(size),
+		ber.OctetString(cookie),
+	))
+	return ldapmsg.Control{OID: PagedResultsControlOID, Value: &value}
+}
+
+// SortKey is one key of a server-side sort request control (RFC 2891).
+type SortKey struct {
+	AttributeType string
+	OrderingRule  string
+	ReverseOrder  bool
+}
+
+// DecodeSortRequestControl decodes the value of a server-side sort request
+// control into its sort keys.
+func DecodeSortRequestControl(control ldapmsg.Control) ([]SortKey, error) {
+	packet, err := controlValueSequence("sort request", control)
+	if err != nil {
+		return nil, err
+	}
+	if len(packet.Children) == 0 {
+		return nil, fmt.Errorf("sort request control has no sort keys")
+	}
+	keys := make([]SortKey, 0, len(packet.Children))
+	for i, child := range packet.Children {
+		key, err := decodeSortKey(child)
+		if err != nil {
+			return nil, fmt.Errorf("sort key %d: %w", i, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func decodeSortKey(packet ber.Packet) (SortKey, error) {
+	if err := packet.RequireTag(ber.ClassUniversal | ber.Constructed | ber.TagSequence); err != nil {
+		return SortKey{}, err
+	}
+	if len(packet.Children) == 0 || len(packet.Children) > 3 {
+		return SortKey{}, fmt.Errorf("sort key has %d fields, want 1 to 3", len(packet.Children))
+	}
+	if err := packet.Children[0].RequireTag(ber.ClassUniversal | ber.TagOctet); err != nil {
+		return SortKey{}, fmt.Errorf("attribute type: %w", err)
+	}
+	key := SortKey{AttributeType: packet.Children[0].String()}
+	if key.AttributeType == "" {
+		return SortKey{}, fmt.Errorf("attribute type is empty")
+	}
+
+	rest := packet.Children[1:]
+	if len(rest) > 0 && rest[0].Tag == ber.ClassContextSpecific {
+		key.OrderingRule = rest[0].String()
+		rest = rest[1:]
+	}
+	if len(rest) > 0 && rest[0].Tag == ber.ClassContextSpecific|1 {
+		reverse, err := rest[0].Bool()
+		if err != nil {
+			return SortKey{}, fmt.Errorf("reverse order: %w", err)
+		}
+		key.ReverseOrder = reverse
+		rest = rest[1:]
+	}
+	if len(rest) > 0 {
+		return SortKey{}, fmt.Errorf("unexpected sort key field tag 0x%02x", rest[0].Tag)
+	}
+	return key, nil
+}
+
+// NewSortResponseControl creates a server-side sort response control.
+// attributeType names the sort key that caused a failure and is omitted when
+// empty.
+func NewSortResponseControl(result ldapmsg.ResultCode, attributeType string) ldapmsg.Control {
+	fields := [][]byte{ber.Enumerated(int(result))}
+	if attributeType != "" {
+		fields = append(fields, ber.TLV(ber.ClassContextSpecific, []byte(attributeType)))
+	}
+	value := string(ber.Sequence(fields...))
+	return ldapmsg.Control{OID: SortResponseControlOID, Value: &value}
+}
+
+// VirtualListView is the value of a virtual list view request control
+// (draft-ietf-ldapext-ldapv3-vlv). The target entry is located by Offset and
+// ContentCount, or by GreaterThanOrEqual when it is set.
+type VirtualListView struct {
+	BeforeCount        int
+	AfterCount         int
+	Offset             int
+	ContentCount       int
+	GreaterThanOrEqual *string
+	ContextID          string
+}
+
+// DecodeVirtualListViewControl decodes the value of a virtual list view
+// request control.
+func DecodeVirtualListViewControl(control ldapmsg.Control) (VirtualListView, error) {
+	packet, err := controlValueSequence("virtual list view", control)
+	if err != nil {
+		return VirtualListView{}, err
+	}
+	if len(packet.Children) < 3 || len(packet.Children) > 4 {
+		return VirtualListView{}, fmt.Errorf("virtual list view control has %d fields, want 3 or 4", len(packet.Children))
+	}
+
+	var vlv VirtualListView
+	if vlv.BeforeCount, err = vlvInteger(packet.Children[0], "beforeCount"); err != nil {
+		return VirtualListView{}, err
+	}
+	if vlv.AfterCount, err = vlvInteger(packet.Children[1], "afterCount"); err != nil {
+		return VirtualListView{}, err
+	}
+
+	target := packet.Children[2]
+	switch target.Tag {
+	case ber.ClassContextSpecific | ber.Constructed:
+		if len(target.Children) != 2 {
+			return VirtualListView{}, fmt.Errorf("virtual list view byOffset has %d fields, want 2", len(target.Children))
+		}
+		if vlv.Offset, err = vlvInteger(target.Children[0], "offset"); err != nil {
+			return VirtualListView{}, err
+		}
+		if vlv.ContentCount, err = vlvInteger(target.Children[1], "contentCount"); err != nil {
+			return VirtualListView{}, err
+		}
+	case ber.ClassContextSpecific | 1:
+		value := target.String()
+		vlv.GreaterThanOrEqual = &value
+	default:
+		return VirtualListView{}, fmt.Errorf("virtual list view target tag 0x%02x", target.Tag)
+	}
+
+	if len(packet.Children) == 4 {
+		if err := packet.Children[3].RequireTag(ber.ClassUniversal | ber.TagOctet); err != nil {
+			return VirtualListView{}, fmt.Errorf("virtual list view contextID: %w", err)
+		}
+		vlv.ContextID = packet.Children[3].String()
+	}
+	return vlv, nil
+}
+
+// vlvInteger decodes an INTEGER (0..maxInt) field of a virtual list view
+// request.
+func vlvInteger(packet ber.Packet, name string) (int, error) {
+	value, err := decodeSearchLimit(packet)
+	if err != nil {
+		return 0, fmt.Errorf("virtual list view %s: %w", name, err)
+	}
+	return value, nil
+}
+
+// NewVirtualListViewResponseControl creates a virtual list view response
+// control. contextID is omitted when empty.
+func NewVirtualListViewResponseControl(targetPosition, contentCount int, result ldapmsg.ResultCode, contextID string) ldapmsg.Control {
+	fields := [][]byte{
+		ber.Integer(targetPosition),
+		ber.Integer(contentCount),
+		ber.Enumerated(int(result)),
+	}
+	if contextID != "" {
+		fields = append(fields, ber.OctetString(contextID))
+	}
+	value := string(ber.Sequence(fields...))
+	return ldapmsg.Control{OID: VirtualListViewResponseControlOID, Value: &value}
+}
+
+// NewPasswordPolicyResponseControl creates a password policy response
+// control. Negative arguments are omitted; the warning carries
+// timeBeforeExpiration when both warnings are given.
+func NewPasswordPolicyResponseControl(timeBeforeExpiration, graceAuthNsRemaining, errorCode int) ldapmsg.Control {
+	var fields [][]byte
+	switch {
+	case timeBeforeExpiration >= 0:
+		fields = append(fields, ber.TLV(ber.ClassContextSpecific|ber.Constructed,
+			ber.TaggedInteger(ber.ClassContextSpecific, timeBeforeExpiration)))
+	case graceAuthNsRemaining >= 0:
+		fields = append(fields, ber.TLV(ber.ClassContextSpecific|ber.Constructed,
+			ber.TaggedInteger(ber.ClassContextSpecific|1, graceAuthNsRemaining)))
+	}
+	if errorCode >= 0 {
+		fields = append(fields, ber.TaggedInteger(ber.ClassContextSpecific|1, errorCode))
+	}
+	value := string(ber.Sequence(fields...))
+	return ldapmsg.Control{OID: PasswordPolicyControlOID, Value: &value}
+}
+
+// GetEffectiveRights is the value of a get effective rights request
+// control. AuthzID is an authorization identity (RFC 4513 section 5.2.1.8);
+// Attributes names attributes to report even when entries lack them.
+type GetEffectiveRights struct {
+	AuthzID    string
+	Attributes []string
+}
+
+// DecodeGetEffectiveRightsControl decodes the value of a get effective rights
+// request control. The value is either the draft's SEQUENCE of authzId and
+// attribute types, or a bare authzId OCTET STRING as sent by ldapsearch for
+// 389 Directory Server. A control without a value asks about the requester.
+func DecodeGetEffectiveRightsControl(control ldapmsg.Control) (GetEffectiveRights, error) {
+	if control.Value == nil {
+		return GetEffectiveRights{}, nil
+	}
+	packet, n, err := ber.ReadPacket([]byte(*control.Value))
+	if err != nil {
+		return GetEffectiveRights{}, fmt.Errorf("get effective rights control: %w", err)
+	}
+	if n != len(*control.Value) {
+		return GetEffectiveRights{}, fmt.Errorf("get effective rights control has %d trailing bytes", len(*control.Value)-n)
+	}
+	if packet.Tag == ber.ClassUniversal|ber.TagOctet {
+		return GetEffectiveRights{AuthzID: packet.String()}, nil
+	}
+	if err := packet.RequireTag(ber.ClassUniversal | ber.Constructed | ber.TagSequence); err != nil {
+		return GetEffectiveRights{}, fmt.Errorf("get effective rights control: %w", err)
+	}
+	if len(packet.Children) == 0 || len(packet.Children) > 2 {
+		return GetEffectiveRights{}, fmt.Errorf("get effective rights control has %d fields, want 1 or 2", len(packet.Children))
+	}
+	if err := packet.Children[0].RequireTag(ber.ClassUniversal | ber.TagOctet); err != nil {
+		return GetEffectiveRights{}, fmt.Errorf("get effective rights authzId: %w", err)
+	}
+	rights := GetEffectiveRights{AuthzID: packet.Children[0].String()}
+	if len(packet.Children) == 2 {
+		attributes := packet.Children[1]
+		if err := attributes.RequireTag(ber.ClassUniversal | ber.Constructed | ber.TagSequence); err != nil {
+			return GetEffectiveRights{}, fmt.Errorf("get effective rights attributes: %w", err)
+		}
+		for i, attribute := range attributes.Children {
+			if err := attribute.RequireTag(ber.ClassUniversal | ber.TagOctet); err != nil {
+				return GetEffectiveRights{}, fmt.Errorf("get effective rights attribute %d: %w", i, err)
+			}
+			rights.Attributes = append(rights.Attributes, attribute.String())
+		}
+	}
+	return rights, nil
+}
+
+// DecodeAssertionControl decodes the filter of an assertion control.
+func DecodeAssertionControl(control ldapmsg.Control) (ldapmsg.Filter, error) {
+	if control.Value == nil {
+		return nil, fmt.Errorf("assertion control has no value")
+	}
+	packet, n, err := ber.ReadPacket([]byte(*control.Value))
+	if err != nil {
+		return nil, fmt.Errorf("assertion control: %w", err)
+	}
+	if n != len(*control.Value) {
+		return nil, fmt.Errorf("assertion control has %d trailing bytes", len(*control.Value)-n)
+	}
+	filter, err := decodeFilter(packet)
+	if err != nil {
+		return nil, fmt.Errorf("assertion control: %w", err)
+	}
+	return filter, nil
+}
+
+// DecodeReadEntryControl decodes the attribute selection of a pre-read or
+// post-read request control.
+func DecodeReadEntryControl(control ldapmsg.Control) ([]string, error) {
+	packet, err := controlValueSequence("read entry", control)
+	if err != nil {
+		return nil, err
+	}
+	attributes := make([]string, 0, len(packet.Children))
+	for i, child := range packet.Children {
+		if err := child.RequireTag(ber.ClassUniversal | ber.TagOctet); err != nil {
+			return nil, fmt.Errorf("read entry attribute %d: %w", i, err)
+		}
+		attributes = append(attributes, child.String())
+	}
+	return attributes, nil
+}
+
+// NewReadEntryControl creates a pre-read or post-read response control
+// holding entry.
+func NewReadEntryControl(oid string, entry ldapmsg.SearchResultEntry) ldapmsg.Control {
+	value := string(encodeSearchResultEntry(entry))
+	return ldapmsg.Control{OID: oid, Value: &value}
+}
+
+// controlValueSequence parses a control value that must hold exactly one BER
+// SEQUENCE.
+func controlValueSequence(name string, control ldapmsg.Control) (ber.Packet, error) {
+	if control.Value == nil {
+		return ber.Packet{}, fmt.Errorf("%s control has no value", name)
+	}
+	packet, n, err := ber.ReadPacket([]byte(*control.Value))
+	if err != nil {
+		return ber.Packet{}, fmt.Errorf("%s control: %w", name, err)
+	}
+	if n != len(*control.Value) {
+		return ber.Packet{}, fmt.Errorf("%s control has %d trailing bytes", name, len(*control.Value)-n)
+	}
+	if err := packet.RequireTag(ber.ClassUniversal | ber.Constructed | ber.TagSequence); err != nil {
+		return ber.Packet{}, fmt.Errorf("%s control: %w", name, err)
+	}
+	return packet, nil
+}
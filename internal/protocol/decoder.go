@@ -13,13 +13,18 @@ const (
 	tagModifyRequest   byte = 0x66
 	tagAddRequest      byte = 0x68
 	tagDelRequest      byte = 0x4a
+	tagModifyDNRequest byte = 0x6c
 	tagCompareRequest  byte = 0x6e
 	tagUnbindRequest   byte = 0x42
 	tagExtendedRequest byte = 0x77
+	tagAbandonRequest  byte = 0x50
 
 	tagSimpleAuth           byte = 0x80
+	tagSASLAuth             byte = 0xa3
 	tagExtendedRequestName  byte = 0x80
 	tagExtendedRequestValue byte = 0x81
+	tagModifyDNNewSuperior  byte = 0x80
+	tagControls             byte = 0xa0
 
 	tagFilterAnd            byte = 0xa0
 	tagFilterOr             byte = 0xa1
@@ -30,14 +35,24 @@ const (
 	tagFilterLessOrEqual    byte = 0xa6
 	tagFilterPresent        byte = 0x87
 	tagFilterApproxMatch    byte = 0xa8
+	tagFilterExtensible     byte = 0xa9
 
 	tagSubstringInitial byte = 0x80
 	tagSubstringAny     byte = 0x81
 	tagSubstringFinal   byte = 0x82
+
+	tagMatchingRule      byte = 0x81
+	tagMatchingType      byte = 0x82
+	tagMatchValue        byte = 0x83
+	tagMatchDNAttributes byte = 0x84
 )
 
 func DecodeLDAPMessage(data []byte) (*ldapmsg.Message, error) {
-	packet, n, err := ber.ReadPacket(data)
+	return decodeLDAPMessageLimit(data, ber.MaxPacketSize)
+}
+
+func decodeLDAPMessageLimit(data []byte, maxSize int) (*ldapmsg.Message, error) {
+	packet, n, err := ber.ReadPacketLimit(data, maxSize)
 	if err != nil {
 		return nil, err
 	}
@@ -47,8 +62,8 @@ func DecodeLDAPMessage(data []byte) (*ldapmsg.Message, error) {
 	if err := packet.RequireTag(ber.ClassUniversal | ber.Constructed | ber.TagSequence); err != nil {
 		return nil, fmt.Errorf("LDAP message: %w", err)
 	}
-	if len(packet.Children) < 2 {
-		return nil, fmt.Errorf("LDAP message has %d fields, want at least 2", len(packet.Children))
+	if len(packet.Children) != 2 && len(packet.Children) != 3 {
+		return nil, fmt.Errorf("LDAP message has %d fields, want 2 or 3", len(packet.Children))
 	}
 	if err := packet.Children[0].RequireTag(ber.ClassUniversal | ber.TagInteger); err != nil {
 		return nil, fmt.Errorf("LDAP messageID: %w", err)
@@ -63,7 +78,64 @@ func DecodeLDAPMessage(data []byte) (*ldapmsg.Message, error) {
 		return nil, err
 	}
 
-	return &ldapmsg.Message{ID: ldapmsg.MessageID(messageID), Op: op}, nil
+	var controls []ldapmsg.Control
+	if len(packet.Children) == 3 {
+		controls, err = decodeControls(packet.Children[2])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &ldapmsg.Message{ID: ldapmsg.MessageID(messageID), Op: op, Controls: controls}, nil
+}
+
+func decodeControls(packet ber.Packet) ([]ldapmsg.Control, error) {
+	if err := packet.RequireTag(tagControls); err != nil {
+		return nil, fmt.Errorf("LDAP controls: %w", err)
+	}
+	controls := make([]ldapmsg.Control, 0, len(packet.Children))
+	for _, child := range packet.Children {
+		control, err := decodeControl(child)
+		if err != nil {
+			return nil, err
+		}
+		controls = append(controls, control)
+	}
+	return controls, nil
+}
+
+func decodeControl(packet ber.Packet) (ldapmsg.Control, error) {
+	if err := packet.RequireTag(ber.ClassUniversal | ber.Constructed | ber.TagSequence); err != nil {
+		return ldapmsg.Control{}, fmt.Errorf("LDAP control: %w", err)
+	}
+	if len(packet.Children) == 0 || len(packet.Children) > 3 {
+		return ldapmsg.Control{}, fmt.Errorf("LDAP control has %d fields, want 1 to 3", len(packet.Children))
+	}
+	if err := packet.Children[0].RequireTag(ber.ClassUniversal | ber.TagOctet); err != nil {
+		return ldapmsg.Control{}, fmt.Errorf("LDAP control type: %w", err)
+	}
+	control := ldapmsg.Control{OID: packet.Children[0].String()}
+	fields := packet.Children[1:]
+	if len(fields) > 0 && fields[0].Tag == ber.ClassUniversal|ber.TagBoolean {
+		criticality, err := fields[0].Bool()
+		if err != nil {
+			return ldapmsg.Control{}, fmt.Errorf("LDAP control criticality: %w", err)
+		}
+		control.Criticality = criticality
+		fields = fields[1:]
+	}
+	if len(fields) > 0 {
+		if err := fields[0].RequireTag(ber.ClassUniversal | ber.TagOctet); err != nil {
+			return ldapmsg.Control{}, fmt.Errorf("LDAP control value: %w", err)
+		}
+		value := fields[0].String()
+		control.Value = &value
+		fields = fields[1:]
+	}
+	if len(fields) > 0 {
+		return ldapmsg.Control{}, fmt.Errorf("LDAP control %s has unexpected fields", control.OID)
+	}
+	return control, nil
 }
 
 func decodeProtocolOp(packet ber.Packet) (ldapmsg.Operation, error) {
@@ -78,6 +150,8 @@ func decodeProtocolOp(packet ber.Packet) (ldapmsg.Operation, error) {
 		return decodeModifyRequest(packet)
 	case tagDelRequest:
 		return ldapmsg.DeleteRequest{DN: packet.String()}, nil
+	case tagModifyDNRequest:
+		return decodeModifyDNRequest(packet)
 	case tagCompareRequest:
 		return decodeCompareRequest(packet)
 	case tagExtendedRequest:
@@ -87,11 +161,30 @@ func decodeProtocolOp(packet ber.Packet) (ldapmsg.Operation, error) {
 			return nil, fmt.Errorf("unbind request value length %d, want 0", len(packet.Value))
 		}
 		return ldapmsg.UnbindRequest{}, nil
+	case tagAbandonRequest:
+		messageID, err := decodeMessageIDValue(packet)
+		if err != nil {
+			return nil, fmt.Errorf("abandon messageID: %w", err)
+		}
+		return ldapmsg.AbandonRequest{MessageID: messageID}, nil
 	default:
 		return nil, fmt.Errorf("unsupported LDAP protocol op tag 0x%02x", packet.Tag)
 	}
 }
 
+// decodeMessageIDValue decodes a MessageID (INTEGER 0..maxInt) held by an
+// implicitly tagged packet.
+func decodeMessageIDValue(packet ber.Packet) (ldapmsg.MessageID, error) {
+	if len(packet.Value) > 4 || (len(packet.Value) > 0 && packet.Value[0]&0x80 != 0) {
+		return 0, fmt.Errorf("message ID out of range")
+	}
+	id, err := packet.Int()
+	if err != nil {
+		return 0, err
+	}
+	return ldapmsg.MessageID(id), nil
+}
+
 func decodeBindRequest(packet ber.Packet) (ldapmsg.BindRequest, error) {
 	if len(packet.Children) != 3 {
 		return ldapmsg.BindRequest{}, fmt.Errorf("bind request has %d fields, want 3", len(packet.Children))
@@ -102,13 +195,37 @@ func decodeBindRequest(packet ber.Packet) (ldapmsg.BindRequest, error) {
 	if err := packet.Children[1].RequireTag(ber.ClassUniversal | ber.TagOctet); err != nil {
 		return ldapmsg.BindRequest{}, fmt.Errorf("bind name: %w", err)
 	}
-	if err := packet.Children[2].RequireTag(tagSimpleAuth); err != nil {
-		return ldapmsg.BindRequest{}, fmt.Errorf("bind simple auth: %w", err)
+	req := ldapmsg.BindRequest{Name: packet.Children[1].String()}
+	switch auth := packet.Children[2]; auth.Tag {
+	case tagSimpleAuth:
+		req.Password = auth.String()
+	case tagSASLAuth:
+		sasl, err := decodeSASLCredentials(auth)
+		if err != nil {
+			return ldapmsg.BindRequest{}, err
+		}
+		req.SASL = &sasl
+	default:
+		return ldapmsg.BindRequest{}, fmt.Errorf("unsupported bind authentication tag 0x%02x", auth.Tag)
 	}
-	return ldapmsg.BindRequest{
-		Name:     packet.Children[1].String(),
-		Password: packet.Children[2].String(),
-	}, nil
+	return req, nil
+}
+
+func decodeSASLCredentials(packet ber.Packet) (ldapmsg.SASLCredentials, error) {
+	if len(packet.Children) < 1 || len(packet.Children) > 2 {
+		return ldapmsg.SASLCredentials{}, fmt.Errorf("sasl credentials have %d fields, want 1 or 2", len(packet.Children))
+	}
+	for _, child := range packet.Children {
+		if err := child.RequireTag(ber.ClassUniversal | ber.TagOctet); err != nil {
+			return ldapmsg.SASLCredentials{}, fmt.Errorf("sasl credentials: %w", err)
+		}
+	}
+	sasl := ldapmsg.SASLCredentials{Mechanism: packet.Children[0].String()}
+	if len(packet.Children) == 2 {
+		credentials := packet.Children[1].String()
+		sasl.Credentials = &credentials
+	}
+	return sasl, nil
 }
 
 func decodeSearchRequest(packet ber.Packet) (ldapmsg.SearchRequest, error) {
@@ -125,6 +242,24 @@ func decodeSearchRequest(packet ber.Packet) (ldapmsg.SearchRequest, error) {
 	if err != nil {
 		return ldapmsg.SearchRequest{}, fmt.Errorf("search scope: %w", err)
 	}
+	if err := packet.Children[2].RequireTag(ber.ClassUniversal | ber.TagEnumerated); err != nil {
+		return ldapmsg.SearchRequest{}, fmt.Errorf("search derefAliases: %w", err)
+	}
+	derefAliases, err := packet.Children[2].Int()
+	if err != nil {
+		return ldapmsg.SearchRequest{}, fmt.Errorf("search derefAliases: %w", err)
+	}
+	if derefAliases > int(ldapmsg.DerefAlways) {
+		return ldapmsg.SearchRequest{}, fmt.Errorf("search derefAliases %d out of range", derefAliases)
+	}
+	sizeLimit, err := decodeSearchLimit(packet.Children[3])
+	if err != nil {
+		return ldapmsg.SearchRequest{}, fmt.Errorf("search sizeLimit: %w", err)
+	}
+	timeLimit, err := decodeSearchLimit(packet.Children[4])
+	if err != nil {
+		return ldapmsg.SearchRequest{}, fmt.Errorf("search timeLimit: %w", err)
+	}
 	if err := packet.Children[5].RequireTag(ber.ClassUniversal | ber.TagBoolean); err != nil {
 		return ldapmsg.SearchRequest{}, fmt.Errorf("search typesOnly: %w", err)
 	}
@@ -141,14 +276,28 @@ func decodeSearchRequest(packet ber.Packet) (ldapmsg.SearchRequest, error) {
 		return ldapmsg.SearchRequest{}, fmt.Errorf("search attributes: %w", err)
 	}
 	return ldapmsg.SearchRequest{
-		BaseObject: packet.Children[0].String(),
-		Scope:      ldapmsg.SearchScope(scope),
-		TypesOnly:  typesOnly,
-		Filter:     filter,
-		Attributes: attrs,
+		BaseObject:   packet.Children[0].String(),
+		Scope:        ldapmsg.SearchScope(scope),
+		DerefAliases: ldapmsg.DerefAliases(derefAliases),
+		SizeLimit:    sizeLimit,
+		TimeLimit:    timeLimit,
+		TypesOnly:    typesOnly,
+		Filter:       filter,
+		Attributes:   attrs,
 	}, nil
 }
 
+// decodeSearchLimit decodes a sizeLimit or timeLimit, INTEGER (0 .. maxInt).
+func decodeSearchLimit(packet ber.Packet) (int, error) {
+	if err := packet.RequireTag(ber.ClassUniversal | ber.TagInteger); err != nil {
+		return 0, err
+	}
+	if len(packet.Value) > 4 || (len(packet.Value) > 0 && packet.Value[0]&0x80 != 0) {
+		return 0, fmt.Errorf("limit out of range")
+	}
+	return packet.Int()
+}
+
 func decodeAddRequest(packet ber.Packet) (ldapmsg.AddRequest, error) {
 	if len(packet.Children) != 2 {
 		return ldapmsg.AddRequest{}, fmt.Errorf("add request has %d fields, want 2", len(packet.Children))
@@ -163,6 +312,10 @@ func decodeAddRequest(packet ber.Packet) (ldapmsg.AddRequest, error) {
 	return ldapmsg.AddRequest{Entry: packet.Children[0].String(), Attributes: attrs}, nil
 }
 
+// ModifyIncrementFeatureOID is advertised in supportedFeatures when modify
+// requests may use the increment operation (RFC 4525).
+const ModifyIncrementFeatureOID = "1.3.6.1.1.14"
+
 func decodeModifyRequest(packet ber.Packet) (ldapmsg.ModifyRequest, error) {
 	if len(packet.Children) != 2 {
 		return ldapmsg.ModifyRequest{}, fmt.Errorf("modify request has %d fields, want 2", len(packet.Children))
@@ -185,6 +338,9 @@ func decodeModifyRequest(packet ber.Packet) (ldapmsg.ModifyRequest, error) {
 		if err != nil {
 			return ldapmsg.ModifyRequest{}, fmt.Errorf("modify operation: %w", err)
 		}
+		if op > int(ldapmsg.ModifyOperationIncrement) {
+			return ldapmsg.ModifyRequest{}, fmt.Errorf("modify operation %d out of range", op)
+		}
 		attr, err := decodeAttribute(child.Children[1])
 		if err != nil {
 			return ldapmsg.ModifyRequest{}, err
@@ -197,6 +353,39 @@ func decodeModifyRequest(packet ber.Packet) (ldapmsg.ModifyRequest, error) {
 	return ldapmsg.ModifyRequest{Object: packet.Children[0].String(), Changes: changes}, nil
 }
 
+func decodeModifyDNRequest(packet ber.Packet) (ldapmsg.ModifyDNRequest, error) {
+	if len(packet.Children) != 3 && len(packet.Children) != 4 {
+		return ldapmsg.ModifyDNRequest{}, fmt.Errorf("modify DN request has %d fields, want 3 or 4", len(packet.Children))
+	}
+	if err := packet.Children[0].RequireTag(ber.ClassUniversal | ber.TagOctet); err != nil {
+		return ldapmsg.ModifyDNRequest{}, fmt.Errorf("modify DN entry: %w", err)
+	}
+	if err := packet.Children[1].RequireTag(ber.ClassUniversal | ber.TagOctet); err != nil {
+		return ldapmsg.ModifyDNRequest{}, fmt.Errorf("modify DN newrdn: %w", err)
+	}
+	if err := packet.Children[2].RequireTag(ber.ClassUniversal | ber.TagBoolean); err != nil {
+		return ldapmsg.ModifyDNRequest{}, fmt.Errorf("modify DN deleteoldrdn: %w", err)
+	}
+	deleteOldRDN, err := packet.Children[2].Bool()
+	if err != nil {
+		return ldapmsg.ModifyDNRequest{}, fmt.Errorf("modify DN deleteoldrdn: %w", err)
+	}
+	var newSuperior *string
+	if len(packet.Children) == 4 {
+		if err := packet.Children[3].RequireTag(tagModifyDNNewSuperior); err != nil {
+			return ldapmsg.ModifyDNRequest{}, fmt.Errorf("modify DN newSuperior: %w", err)
+		}
+		v := packet.Children[3].String()
+		newSuperior = &v
+	}
+	return ldapmsg.ModifyDNRequest{
+		Entry:        packet.Children[0].String(),
+		NewRDN:       packet.Children[1].String(),
+		DeleteOldRDN: deleteOldRDN,
+		NewSuperior:  newSuperior,
+	}, nil
+}
+
 func decodeCompareRequest(packet ber.Packet) (ldapmsg.CompareRequest, error) {
 	if len(packet.Children) != 2 {
 		return ldapmsg.CompareRequest{}, fmt.Errorf("compare request has %d fields, want 2", len(packet.Children))
@@ -364,6 +553,8 @@ func decodeFilter(packet ber.Packet) (ldapmsg.Filter, error) {
 			return nil, err
 		}
 		return ldapmsg.ApproxMatchFilter{Attribute: ava.Attribute, Value: ava.Value}, nil
+	case tagFilterExtensible:
+		return decodeExtensibleMatchFilter(packet)
 	default:
 		return nil, fmt.Errorf("unsupported filter tag 0x%02x", packet.Tag)
 	}
@@ -381,6 +572,43 @@ func decodeFilterList(children []ber.Packet) ([]ldapmsg.Filter, error) {
 	return filters, nil
 }
 
+func decodeExtensibleMatchFilter(packet ber.Packet) (ldapmsg.ExtensibleMatchFilter, error) {
+	var filter ldapmsg.ExtensibleMatchFilter
+	hasValue := false
+	last := byte(0)
+	for _, child := range packet.Children {
+		if child.Tag <= last {
+			return filter, fmt.Errorf("extensible match field 0x%02x out of order", child.Tag)
+		}
+		last = child.Tag
+
+		switch child.Tag {
+		case tagMatchingRule:
+			filter.MatchingRule = child.String()
+		case tagMatchingType:
+			filter.Attribute = child.String()
+		case tagMatchValue:
+			filter.Value = child.String()
+			hasValue = true
+		case tagMatchDNAttributes:
+			dnAttributes, err := child.Bool()
+			if err != nil {
+				return filter, fmt.Errorf("extensible match dnAttributes: %w", err)
+			}
+			filter.DNAttributes = dnAttributes
+		default:
+			return filter, fmt.Errorf("unsupported extensible match tag 0x%02x", child.Tag)
+		}
+	}
+	if !hasValue {
+		return filter, fmt.Errorf("extensible match has no matchValue")
+	}
+	if filter.MatchingRule == "" && filter.Attribute == "" {
+		return filter, fmt.Errorf("extensible match needs a matchingRule or type")
+	}
+	return filter, nil
+}
+
 func decodeSubstringsFilter(packet ber.Packet) (ldapmsg.SubstringsFilter, error) {
 	if len(packet.Children) != 2 {
 		return ldapmsg.SubstringsFilter{}, fmt.Errorf("substring filter has %d fields, want 2", len(packet.Children))
@@ -1,6 +1,7 @@
 package protocol
 
 import (
+	"errors"
 	"net"
 	"testing"
 	"time"
@@ -26,7 +27,121 @@ func TestDecodeLDAPMessageRejectsMalformedBER(t *testing.T) {
 			wire: []byte{
 				0x30, 0x05,
 				0x02, 0x01, 0x01,
-				0x50, 0x00,
+				0x51, 0x00,
+			},
+		},
+		{
+			name: "abandon negative message ID",
+			wire: []byte{
+				0x30, 0x06,
+				0x02, 0x01, 0x02,
+				0x50, 0x01, 0xff,
+			},
+		},
+		{
+			name: "modify DN missing deleteoldrdn",
+			wire: []byte{
+				0x30, 0x0d,
+				0x02, 0x01, 0x03,
+				0x6c, 0x08,
+				0x04, 0x02, 'c', 'n',
+				0x04, 0x02, 'c', 'n',
+			},
+		},
+		{
+			name: "modify unknown operation",
+			wire: []byte{
+				0x30, 0x26,
+				0x02, 0x01, 0x05,
+				0x66, 0x21,
+				0x04, 0x06, 'c', 'n', '=', 'i', 'd', 's',
+				0x30, 0x17,
+				0x30, 0x15,
+				0x0a, 0x01, 0x04,
+				0x30, 0x10,
+				0x04, 0x09, 'u', 'i', 'd', 'N', 'u', 'm', 'b', 'e', 'r',
+				0x31, 0x03, 0x04, 0x01, '1',
+			},
+		},
+		{
+			name: "controls with wrong tag",
+			wire: []byte{
+				0x30, 0x0b,
+				0x02, 0x01, 0x01,
+				0x42, 0x00,
+				0xa1, 0x04,
+				0x30, 0x02, 0x04, 0x00,
+			},
+		},
+		{
+			name: "control value with wrong tag",
+			wire: []byte{
+				0x30, 0x10,
+				0x02, 0x01, 0x01,
+				0x42, 0x00,
+				0xa0, 0x09,
+				0x30, 0x07,
+				0x04, 0x02, '1', '.',
+				0x02, 0x01, 0x00,
+			},
+		},
+		{
+			name: "search derefAliases out of range",
+			wire: []byte{
+				0x30, 0x1c,
+				0x02, 0x01, 0x02,
+				0x63, 0x17,
+				0x04, 0x00,
+				0x0a, 0x01, 0x02,
+				0x0a, 0x01, 0x04,
+				0x02, 0x01, 0x00,
+				0x02, 0x01, 0x00,
+				0x01, 0x01, 0x00,
+				0x87, 0x02, 'c', 'n',
+				0x30, 0x00,
+			},
+		},
+		{
+			name: "search negative sizeLimit",
+			wire: []byte{
+				0x30, 0x1c,
+				0x02, 0x01, 0x02,
+				0x63, 0x17,
+				0x04, 0x00,
+				0x0a, 0x01, 0x02,
+				0x0a, 0x01, 0x00,
+				0x02, 0x01, 0xff,
+				0x02, 0x01, 0x00,
+				0x01, 0x01, 0x00,
+				0x87, 0x02, 'c', 'n',
+				0x30, 0x00,
+			},
+		},
+		{
+			name: "extensible match without matchValue",
+			wire: []byte{
+				0x30, 0x1c,
+				0x02, 0x01, 0x02,
+				0x63, 0x17,
+				0x04, 0x00,
+				0x0a, 0x01, 0x02,
+				0x0a, 0x01, 0x00,
+				0x02, 0x01, 0x00,
+				0x02, 0x01, 0x00,
+				0x01, 0x01, 0x00,
+				0xa9, 0x02, 0x81, 0x00,
+				0x30, 0x00,
+			},
+		},
+		{
+			name: "bind sasl without mechanism",
+			wire: []byte{
+				0x30, 0x0c,
+				0x02, 0x01, 0x01,
+				0x60, 0x07,
+				0x02, 0x01, 0x03,
+				0x04, 0x00,
+				0xa3, 0x00,
 			},
 		},
 		{
@@ -94,3 +209,64 @@ func TestReadLDAPMessageReadsPartialTCPMessage(t *testing.T) {
 		t.Fatal("fixture writer did not finish")
 	}
 }
+
+func TestReadLDAPMessageLeavesPipelinedMessages(t *testing.T) {
+	wire := []byte{
+		0x30, 0x06, 0x02, 0x01, 0x01, 0x4a, 0x01, 'a',
+		0x30, 0x06, 0x02, 0x01, 0x02, 0x4a, 0x01, 'b',
+	}
+
+	serverConn, clientConn := net.Pipe()
+	defer serverConn.Close()
+	go func() {
+		_, _ = clientConn.Write(wire)
+		_ = clientConn.Close()
+	}()
+
+	for _, want := range []ldapmsg.DeleteRequest{{DN: "a"}, {DN: "b"}} {
+		msg, err := ReadLDAPMessage(serverConn)
+		if err != nil {
+			t.Fatalf("ReadLDAPMessage() failed: %v", err)
+		}
+		if got, ok := msg.Op.(ldapmsg.DeleteRequest); !ok || got != want {
+			t.Fatalf("Op = %+v, want %+v", msg.Op, want)
+		}
+	}
+}
+
+func TestReadLDAPMessageRejectsOversizedMessageBeforeReadingIt(t *testing.T) {
+	serverConn, clientConn := net.Pipe()
+	defer serverConn.Close()
+	defer clientConn.Close()
+
+	// Only the header is written: a 64 KiB body must be rejected from its
+	// declared length alone.
+	go func() {
+		_, _ = clientConn.Write([]byte{0x30, 0x83, 0x01, 0x00, 0x00})
+	}()
+
+	_, err := readLDAPMessage(serverConn, ConnectionLimits{MaxMessageSize: 1024})
+	if !errors.Is(err, ErrMessageTooLarge) {
+		t.Fatalf("readLDAPMessage() error = %v, want ErrMessageTooLarge", err)
+	}
+}
+
+func TestReadLDAPMessageTimesOut(t *testing.T) {
+	serverConn, clientConn := net.Pipe()
+	defer serverConn.Close()
+	defer clientConn.Close()
+
+	limits := ConnectionLimits{IdleTimeout: 20 * time.Millisecond, ReadTimeout: 20 * time.Millisecond}
+	if _, err := readLDAPMessage(serverConn, limits); !errors.Is(err, errIdleTimeout) {
+		t.Fatalf("idle readLDAPMessage() error = %v, want errIdleTimeout", err)
+	}
+
+	// A client that starts a request and stalls hits the read timeout.
+	go func() {
+		_, _ = clientConn.Write([]byte{0x30, 0x0c, 0x02})
+	}()
+	_, err := readLDAPMessage(serverConn, limits)
+	if err == nil || errors.Is(err, errIdleTimeout) || !isTimeout(err) {
+		t.Fatalf("stalled readLDAPMessage() error = %v, want read timeout", err)
+	}
+}
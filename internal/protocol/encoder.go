@@ -10,19 +10,22 @@ import (
 
 const (
 	tagBindResponse      byte = 0x61
+	tagServerSASLCreds   byte = 0x87
 	tagSearchResultEntry byte = 0x64
 	tagSearchResultDone  byte = 0x65
 	tagModifyResponse    byte = 0x67
 	tagAddResponse       byte = 0x69
 	tagDelResponse       byte = 0x6b
+	tagModifyDNResponse  byte = 0x6d
 	tagCompareResponse   byte = 0x6f
 	tagExtendedResponse  byte = 0x78
 	tagResponseName      byte = 0x8a
 	tagResponseValue     byte = 0x8b
+	tagResponseControls  byte = 0xa0
 )
 
-func WriteLDAPResponse(conn net.Conn, messageID ldapmsg.MessageID, op ldapmsg.Operation) error {
-	data, err := EncodeLDAPResponse(messageID, op)
+func WriteLDAPResponse(conn net.Conn, messageID ldapmsg.MessageID, op ldapmsg.Operation, controls ...ldapmsg.Control) error {
+	data, err := EncodeLDAPResponse(messageID, op, controls...)
 	if err != nil {
 		return err
 	}
@@ -33,21 +36,43 @@ func WriteLDAPResponse(conn net.Conn, messageID ldapmsg.MessageID, op ldapmsg.Op
 	return nil
 }
 
-func EncodeLDAPResponse(messageID ldapmsg.MessageID, op ldapmsg.Operation) ([]byte, error) {
+func EncodeLDAPResponse(messageID ldapmsg.MessageID, op ldapmsg.Operation, controls ...ldapmsg.Control) ([]byte, error) {
 	protocolOp, err := encodeResponseProtocolOp(op)
 	if err != nil {
 		return nil, err
 	}
+	if len(controls) == 0 {
+		return ber.Sequence(
+			ber.Integer(int(messageID)),
+			protocolOp,
+		), nil
+	}
 	return ber.Sequence(
 		ber.Integer(int(messageID)),
 		protocolOp,
+		encodeControls(controls),
 	), nil
 }
 
+func encodeControls(controls []ldapmsg.Control) []byte {
+	encoded := make([][]byte, 0, len(controls))
+	for _, control := range controls {
+		fields := [][]byte{ber.OctetString(control.OID)}
+		if control.Criticality {
+			fields = append(fields, ber.Boolean(true))
+		}
+		if control.Value != nil {
+			fields = append(fields, ber.OctetString(*control.Value))
+		}
+		encoded = append(encoded, ber.Sequence(fields...))
+	}
+	return ber.TLV(tagResponseControls, concatBER(encoded...))
+}
+
 func encodeResponseProtocolOp(op ldapmsg.Operation) ([]byte, error) {
 	switch resp := op.(type) {
 	case ldapmsg.BindResponse:
-		return encodeLDAPResult(tagBindResponse, resp.LDAPResult), nil
+		return encodeBindResponse(resp), nil
 	case ldapmsg.SearchResultEntry:
 		return encodeSearchResultEntry(resp), nil
 	case ldapmsg.SearchResultDone:
@@ -58,6 +83,8 @@ func encodeResponseProtocolOp(op ldapmsg.Operation) ([]byte, error) {
 		return encodeLDAPResult(tagModifyResponse, resp.LDAPResult), nil
 	case ldapmsg.DeleteResponse:
 		return encodeLDAPResult(tagDelResponse, resp.LDAPResult), nil
+	case ldapmsg.ModifyDNResponse:
+		return encodeLDAPResult(tagModifyDNResponse, resp.LDAPResult), nil
 	case ldapmsg.CompareResponse:
 		return encodeLDAPResult(tagCompareResponse, resp.LDAPResult), nil
 	case ldapmsg.ExtendedResponse:
@@ -75,6 +102,18 @@ func encodeLDAPResult(tag byte, result ldapmsg.LDAPResult) []byte {
 	))
 }
 
+func encodeBindResponse(resp ldapmsg.BindResponse) []byte {
+	if resp.ServerSASLCreds == nil {
+		return encodeLDAPResult(tagBindResponse, resp.LDAPResult)
+	}
+	return ber.TLV(tagBindResponse, concatBER(
+		ber.Enumerated(int(resp.ResultCode)),
+		ber.OctetString(resp.MatchedDN),
+		ber.OctetString(resp.DiagnosticMessage),
+		ber.TLV(tagServerSASLCreds, []byte(*resp.ServerSASLCreds)),
+	))
+}
+
 func encodeSearchResultEntry(entry ldapmsg.SearchResultEntry) []byte {
 	attrs := make([][]byte, 0, len(entry.Attributes))
 	for _, attr := range entry.Attributes {
@@ -4,6 +4,7 @@ import "github.com/smarzola/ldaplite/internal/protocol/ldapmsg"
 
 const WhoAmIOID = "1.3.6.1.4.1.4203.1.11.3"
 const StartTLSOID = "1.3.6.1.4.1.1466.20037"
+const NoticeOfDisconnectionOID = "1.3.6.1.4.1.1466.20036"
 
 func NewWhoAmIResponse(authzID string) ldapmsg.ExtendedResponse {
 	return ldapmsg.ExtendedResponse{
@@ -12,3 +13,12 @@ func NewWhoAmIResponse(authzID string) ldapmsg.ExtendedResponse {
 		ResponseValue: &authzID,
 	}
 }
+
+// NewNoticeOfDisconnection creates the unsolicited notification sent with
+// message ID 0 before the server closes a connection.
+func NewNoticeOfDisconnection(resultCode ldapmsg.ResultCode, diagnosticMessage string) ldapmsg.ExtendedResponse {
+	return ldapmsg.ExtendedResponse{
+		LDAPResult:   ldapmsg.LDAPResult{ResultCode: resultCode, DiagnosticMessage: diagnosticMessage},
+		ResponseName: NoticeOfDisconnectionOID,
+	}
+}
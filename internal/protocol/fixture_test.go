@@ -3,6 +3,7 @@ package protocol
 import (
 	"bytes"
 	"net"
+	"slices"
 	"testing"
 	"time"
 
@@ -15,6 +16,24 @@ func TestReadLDAPMessageDecodesRepresentativeRequestFixtures(t *testing.T) {
 		wire      []byte
 		assertion func(*testing.T, *ldapmsg.Message)
 	}{
+		{
+			name: "abandon",
+			wire: []byte{
+				0x30, 0x06,
+				0x02, 0x01, 0x07,
+				0x50, 0x01, 0x05,
+			},
+			assertion: func(t *testing.T, msg *ldapmsg.Message) {
+				t.Helper()
+				req, ok := msg.Op.(ldapmsg.AbandonRequest)
+				if !ok {
+					t.Fatalf("Op = %T, want ldapmsg.AbandonRequest", msg.Op)
+				}
+				if req.MessageID != 5 {
+					t.Fatalf("abandoned MessageID = %d, want 5", req.MessageID)
+				}
+			},
+		},
 		{
 			name: "bind simple empty credentials",
 			wire: []byte{
@@ -42,6 +61,35 @@ func TestReadLDAPMessageDecodesRepresentativeRequestFixtures(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "bind sasl external with empty credentials",
+			wire: []byte{
+				0x30, 0x18,
+				0x02, 0x01, 0x01,
+				0x60, 0x13,
+				0x02, 0x01, 0x03,
+				0x04, 0x00,
+				0xa3, 0x0c,
+				0x04, 0x08, 'E', 'X', 'T', 'E', 'R', 'N', 'A', 'L',
+				0x04, 0x00,
+			},
+			assertion: func(t *testing.T, msg *ldapmsg.Message) {
+				t.Helper()
+				req, ok := msg.Op.(ldapmsg.BindRequest)
+				if !ok {
+					t.Fatalf("Op = %T, want ldapmsg.BindRequest", msg.Op)
+				}
+				if req.SASL == nil {
+					t.Fatal("SASL = nil, want EXTERNAL credentials")
+				}
+				if got := req.SASL.Mechanism; got != "EXTERNAL" {
+					t.Fatalf("Mechanism = %q, want EXTERNAL", got)
+				}
+				if req.SASL.Credentials == nil || *req.SASL.Credentials != "" {
+					t.Fatalf("Credentials = %v, want empty", req.SASL.Credentials)
+				}
+			},
+		},
 		{
 			name: "search subtree present objectClass",
 			wire: []byte{
@@ -85,6 +133,74 @@ func TestReadLDAPMessageDecodesRepresentativeRequestFixtures(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "search extensible match with dnAttributes",
+			wire: []byte{
+				0x30, 0x31,
+				0x02, 0x01, 0x09,
+				0x63, 0x2c,
+				0x04, 0x00,
+				0x0a, 0x01, 0x02,
+				0x0a, 0x01, 0x00,
+				0x02, 0x01, 0x00,
+				0x02, 0x01, 0x00,
+				0x01, 0x01, 0x00,
+				0xa9, 0x17,
+				0x81, 0x05, '1', '.', '2', '.', '3',
+				0x82, 0x08, 'm', 'e', 'm', 'b', 'e', 'r', 'O', 'f',
+				0x83, 0x01, 'g',
+				0x84, 0x01, 0xff,
+				0x30, 0x00,
+			},
+			assertion: func(t *testing.T, msg *ldapmsg.Message) {
+				t.Helper()
+				req, ok := msg.Op.(ldapmsg.SearchRequest)
+				if !ok {
+					t.Fatalf("Op = %T, want ldapmsg.SearchRequest", msg.Op)
+				}
+				want := ldapmsg.ExtensibleMatchFilter{MatchingRule: "1.2.3", Attribute: "memberOf", Value: "g", DNAttributes: true}
+				if req.Filter != want {
+					t.Fatalf("Filter = %+v, want %+v", req.Filter, want)
+				}
+			},
+		},
+		{
+			name: "search one level with limits and derefAliases",
+			wire: []byte{
+				0x30, 0x37,
+				0x02, 0x01, 0x08,
+				0x63, 0x32,
+				0x04, 0x11,
+				'd', 'c', '=', 'e', 'x', 'a', 'm', 'p', 'l', 'e', ',', 'd', 'c', '=', 'c', 'o', 'm',
+				0x0a, 0x01, 0x01,
+				0x0a, 0x01, 0x03,
+				0x02, 0x02, 0x01, 0xf4,
+				0x02, 0x01, 0x1e,
+				0x01, 0x01, 0x00,
+				0x87, 0x0b,
+				'o', 'b', 'j', 'e', 'c', 't', 'C', 'l', 'a', 's', 's',
+				0x30, 0x00,
+			},
+			assertion: func(t *testing.T, msg *ldapmsg.Message) {
+				t.Helper()
+				req, ok := msg.Op.(ldapmsg.SearchRequest)
+				if !ok {
+					t.Fatalf("Op = %T, want ldapmsg.SearchRequest", msg.Op)
+				}
+				if got := req.Scope; got != ldapmsg.SearchScopeSingleLevel {
+					t.Fatalf("Scope = %d, want single level", got)
+				}
+				if got := req.DerefAliases; got != ldapmsg.DerefAlways {
+					t.Fatalf("DerefAliases = %d, want always", got)
+				}
+				if got := req.SizeLimit; got != 500 {
+					t.Fatalf("SizeLimit = %d, want 500", got)
+				}
+				if got := req.TimeLimit; got != 30 {
+					t.Fatalf("TimeLimit = %d, want 30", got)
+				}
+			},
+		},
 		{
 			name: "add single objectClass attribute",
 			wire: []byte{
@@ -143,6 +259,120 @@ func TestReadLDAPMessageDecodesRepresentativeRequestFixtures(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "modify DN jane with new superior",
+			wire: []byte{
+				0x30, 0x55,
+				0x02, 0x01, 0x06,
+				0x6c, 0x50,
+				0x04, 0x23,
+				'u', 'i', 'd', '=', 'j', 'a', 'n', 'e', ',', 'o', 'u', '=', 'u', 's', 'e', 'r', 's',
+				',', 'd', 'c', '=', 'e', 'x', 'a', 'm', 'p', 'l', 'e', ',', 'd', 'c', '=', 'c', 'o', 'm',
+				0x04, 0x09,
+				'u', 'i', 'd', '=', 'j', 'a', 'n', 'e', 't',
+				0x01, 0x01, 0xff,
+				0x80, 0x1b,
+				'o', 'u', '=', 'p', 'e', 'o', 'p', 'l', 'e',
+				',', 'd', 'c', '=', 'e', 'x', 'a', 'm', 'p', 'l', 'e', ',', 'd', 'c', '=', 'c', 'o', 'm',
+			},
+			assertion: func(t *testing.T, msg *ldapmsg.Message) {
+				t.Helper()
+				req, ok := msg.Op.(ldapmsg.ModifyDNRequest)
+				if !ok {
+					t.Fatalf("Op = %T, want ldapmsg.ModifyDNRequest", msg.Op)
+				}
+				if got := req.Entry; got != "uid=jane,ou=users,dc=example,dc=com" {
+					t.Fatalf("Entry = %q, want jane DN", got)
+				}
+				if got := req.NewRDN; got != "uid=janet" {
+					t.Fatalf("NewRDN = %q, want uid=janet", got)
+				}
+				if !req.DeleteOldRDN {
+					t.Fatalf("DeleteOldRDN = false, want true")
+				}
+				if req.NewSuperior == nil || *req.NewSuperior != "ou=people,dc=example,dc=com" {
+					t.Fatalf("NewSuperior = %v, want ou=people,dc=example,dc=com", req.NewSuperior)
+				}
+			},
+		},
+		{
+			name: "delete jane with request controls",
+			wire: []byte{
+				0x30, 0x5b,
+				0x02, 0x01, 0x07,
+				0x4a, 0x23,
+				'u', 'i', 'd', '=', 'j', 'a', 'n', 'e', ',', 'o', 'u', '=', 'u', 's', 'e', 'r', 's',
+				',', 'd', 'c', '=', 'e', 'x', 'a', 'm', 'p', 'l', 'e', ',', 'd', 'c', '=', 'c', 'o', 'm',
+				0xa0, 0x31,
+				0x30, 0x24,
+				0x04, 0x16,
+				'1', '.', '2', '.', '8', '4', '0', '.', '1', '1', '3', '5', '5', '6', '.', '1', '.', '4', '.', '3', '1', '9',
+				0x01, 0x01, 0xff,
+				0x04, 0x07,
+				0x30, 0x05, 0x02, 0x01, 0x02, 0x04, 0x00,
+				0x30, 0x09,
+				0x04, 0x07,
+				'1', '.', '2', '.', '3', '.', '4',
+			},
+			assertion: func(t *testing.T, msg *ldapmsg.Message) {
+				t.Helper()
+				if _, ok := msg.Op.(ldapmsg.DeleteRequest); !ok {
+					t.Fatalf("Op = %T, want ldapmsg.DeleteRequest", msg.Op)
+				}
+				if got := len(msg.Controls); got != 2 {
+					t.Fatalf("len(Controls) = %d, want 2", got)
+				}
+				paged, ok := msg.Control(PagedResultsControlOID)
+				if !ok || !paged.Criticality || paged.Value == nil {
+					t.Fatalf("paged results control = %+v, %v, want critical control with value", paged, ok)
+				}
+				paging, err := DecodePagedResultsControl(paged)
+				if err != nil {
+					t.Fatalf("DecodePagedResultsControl() failed: %v", err)
+				}
+				if paging.Size != 2 || paging.Cookie != "" {
+					t.Fatalf("paged results = %+v, want size 2 and empty cookie", paging)
+				}
+				if other := msg.Controls[1]; other.OID != "1.2.3.4" || other.Criticality || other.Value != nil {
+					t.Fatalf("second control = %+v, want non-critical 1.2.3.4 without value", other)
+				}
+			},
+		},
+		{
+			name: "modify increment uidNumber",
+			wire: []byte{
+				0x30, 0x26,
+				0x02, 0x01, 0x05,
+				0x66, 0x21,
+				0x04, 0x06, 'c', 'n', '=', 'i', 'd', 's',
+				0x30, 0x17,
+				0x30, 0x15,
+				0x0a, 0x01, 0x03,
+				0x30, 0x10,
+				0x04, 0x09, 'u', 'i', 'd', 'N', 'u', 'm', 'b', 'e', 'r',
+				0x31, 0x03, 0x04, 0x01, '1',
+			},
+			assertion: func(t *testing.T, msg *ldapmsg.Message) {
+				t.Helper()
+				req, ok := msg.Op.(ldapmsg.ModifyRequest)
+				if !ok {
+					t.Fatalf("Op = %T, want ldapmsg.ModifyRequest", msg.Op)
+				}
+				if got := req.Object; got != "cn=ids" {
+					t.Fatalf("Object = %q, want cn=ids", got)
+				}
+				if len(req.Changes) != 1 {
+					t.Fatalf("len(Changes) = %d, want 1", len(req.Changes))
+				}
+				change := req.Changes[0]
+				if change.Operation != ldapmsg.ModifyOperationIncrement {
+					t.Fatalf("Operation = %d, want increment", change.Operation)
+				}
+				if change.Modification.Name != "uidNumber" || len(change.Modification.Values) != 1 || change.Modification.Values[0] != "1" {
+					t.Fatalf("Modification = %+v, want uidNumber: 1", change.Modification)
+				}
+			},
+		},
 		{
 			name: "compare uid jane",
 			wire: []byte{
@@ -191,6 +421,19 @@ func TestResponseHelpersEncodeExactBERFixtures(t *testing.T) {
 				0x04, 0x00,
 			},
 		},
+		{
+			name:     "sasl bind in progress",
+			response: NewSASLBindResponse(ldapmsg.ResultCodeSaslBindInProgress, "abc"),
+			want: []byte{
+				0x30, 0x11,
+				0x02, 0x01, 0x01,
+				0x61, 0x0c,
+				0x0a, 0x01, 0x0e,
+				0x04, 0x00,
+				0x04, 0x00,
+				0x87, 0x03, 'a', 'b', 'c',
+			},
+		},
 		{
 			name:     "search done success",
 			response: NewSearchResultDone(ldapmsg.ResultCodeSuccess),
@@ -239,6 +482,18 @@ func TestResponseHelpersEncodeExactBERFixtures(t *testing.T) {
 				0x04, 0x00,
 			},
 		},
+		{
+			name:     "modify DN success",
+			response: NewModifyDNResponse(ldapmsg.ResultCodeSuccess),
+			want: []byte{
+				0x30, 0x0c,
+				0x02, 0x01, 0x01,
+				0x6d, 0x07,
+				0x0a, 0x01, 0x00,
+				0x04, 0x00,
+				0x04, 0x00,
+			},
+		},
 		{
 			name:     "compare false",
 			response: NewCompareResponse(ldapmsg.ResultCodeCompareFalse),
@@ -286,6 +541,31 @@ func TestWhoAmIResponseEncodesExactBERFixture(t *testing.T) {
 	}
 }
 
+func TestEncodeLDAPResponseAppendsResponseControls(t *testing.T) {
+	got, err := EncodeLDAPResponse(1, NewSearchResultDone(ldapmsg.ResultCodeSuccess), NewPagedResultsControl(0, "abc"))
+	if err != nil {
+		t.Fatalf("EncodeLDAPResponse() failed: %v", err)
+	}
+
+	want := []byte{
+		0x30, 0x34,
+		0x02, 0x01, 0x01,
+		0x65, 0x07,
+		0x0a, 0x01, 0x00,
+		0x04, 0x00,
+		0x04, 0x00,
+		0xa0, 0x26,
+		0x30, 0x24,
+		0x04, 0x16,
+		'1', '.', '2', '.', '8', '4', '0', '.', '1', '1', '3', '5', '5', '6', '.', '1', '.', '4', '.', '3', '1', '9',
+		0x04, 0x0a,
+		0x30, 0x08, 0x02, 0x01, 0x00, 0x04, 0x03, 'a', 'b', 'c',
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("encoded BER = %x, want %x", got, want)
+	}
+}
+
 func readLDAPFixture(t *testing.T, wire []byte) *ldapmsg.Message {
 	t.Helper()
 
@@ -366,3 +646,449 @@ func encodeProtocolOpFixture(t *testing.T, op ldapmsg.Operation) []byte {
 
 	panic("unreachable")
 }
+
+func TestDecodeSortRequestControl(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   []byte
+		want    []SortKey
+		wantErr bool
+	}{
+		{
+			name: "attribute only",
+			value: []byte{
+				0x30, 0x06,
+				0x30, 0x04, 0x04, 0x02, 's', 'n',
+			},
+			want: []SortKey{{AttributeType: "sn"}},
+		},
+		{
+			name: "ordering rule and reverse order",
+			value: []byte{
+				0x30, 0x20,
+				0x30, 0x11,
+				0x04, 0x02, 's', 'n',
+				0x80, 0x08, '2', '.', '5', '.', '1', '3', '.', '3',
+				0x81, 0x01, 0xff,
+				0x30, 0x0b,
+				0x04, 0x09, 'g', 'i', 'v', 'e', 'n', 'N', 'a', 'm', 'e',
+			},
+			want: []SortKey{
+				{AttributeType: "sn", OrderingRule: "2.5.13.3", ReverseOrder: true},
+				{AttributeType: "givenName"},
+			},
+		},
+		{
+			name:    "no sort keys",
+			value:   []byte{0x30, 0x00},
+			wantErr: true,
+		},
+		{
+			name: "empty attribute type",
+			value: []byte{
+				0x30, 0x04,
+				0x30, 0x02, 0x04, 0x00,
+			},
+			wantErr: true,
+		},
+		{
+			name: "fields out of order",
+			value: []byte{
+				0x30, 0x0d,
+				0x30, 0x0b,
+				0x04, 0x02, 's', 'n',
+				0x81, 0x01, 0xff,
+				0x80, 0x02, 'x', 'y',
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value := string(tt.value)
+			got, err := DecodeSortRequestControl(ldapmsg.Control{OID: SortRequestControlOID, Value: &value})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("DecodeSortRequestControl() = %+v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DecodeSortRequestControl() failed: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("DecodeSortRequestControl() = %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("sort key %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestNewSortResponseControl(t *testing.T) {
+	control := NewSortResponseControl(ldapmsg.ResultCodeInappropriateMatching, "sn")
+	if control.OID != SortResponseControlOID || control.Criticality || control.Value == nil {
+		t.Fatalf("sort response control = %+v, want non-critical control with value", control)
+	}
+	want := []byte{0x30, 0x07, 0x0a, 0x01, 0x12, 0x80, 0x02, 's', 'n'}
+	if got := []byte(*control.Value); !bytes.Equal(got, want) {
+		t.Fatalf("sort response value = %x, want %x", got, want)
+	}
+
+	success := NewSortResponseControl(ldapmsg.ResultCodeSuccess, "")
+	if got, want := []byte(*success.Value), []byte{0x30, 0x03, 0x0a, 0x01, 0x00}; !bytes.Equal(got, want) {
+		t.Fatalf("sort response value = %x, want %x", got, want)
+	}
+}
+
+func TestNewPasswordPolicyResponseControl(t *testing.T) {
+	tests := []struct {
+		name    string
+		control ldapmsg.Control
+		want    []byte
+	}{
+		{
+			name:    "time before expiration",
+			control: NewPasswordPolicyResponseControl(300, -1, -1),
+			want:    []byte{0x30, 0x06, 0xa0, 0x04, 0x80, 0x02, 0x01, 0x2c},
+		},
+		{
+			name:    "grace logins",
+			control: NewPasswordPolicyResponseControl(-1, 2, -1),
+			want:    []byte{0x30, 0x05, 0xa0, 0x03, 0x81, 0x01, 0x02},
+		},
+		{
+			name:    "account locked",
+			control: NewPasswordPolicyResponseControl(-1, -1, 1),
+			want:    []byte{0x30, 0x03, 0x81, 0x01, 0x01},
+		},
+		{
+			name:    "empty",
+			control: NewPasswordPolicyResponseControl(-1, -1, -1),
+			want:    []byte{0x30, 0x00},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.control.OID != PasswordPolicyControlOID || tt.control.Value == nil {
+				t.Fatalf("password policy control = %+v, want control with value", tt.control)
+			}
+			if got := []byte(*tt.control.Value); !bytes.Equal(got, tt.want) {
+				t.Fatalf("password policy response value = %x, want %x", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeVirtualListViewControl(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   []byte
+		want    VirtualListView
+		wantGTE string
+		wantErr bool
+	}{
+		{
+			name: "by offset",
+			value: []byte{
+				0x30, 0x0e,
+				0x02, 0x01, 0x01,
+				0x02, 0x01, 0x13,
+				0xa0, 0x06, 0x02, 0x01, 0x05, 0x02, 0x01, 0x00,
+			},
+			want: VirtualListView{BeforeCount: 1, AfterCount: 19, Offset: 5},
+		},
+		{
+			name: "greater than or equal with context ID",
+			value: []byte{
+				0x30, 0x0f,
+				0x02, 0x01, 0x00,
+				0x02, 0x01, 0x02,
+				0x81, 0x03, 'S', 'm', 'i',
+				0x04, 0x02, 'c', '1',
+			},
+			want:    VirtualListView{AfterCount: 2, ContextID: "c1"},
+			wantGTE: "Smi",
+		},
+		{
+			name: "negative afterCount",
+			value: []byte{
+				0x30, 0x0e,
+				0x02, 0x01, 0x00,
+				0x02, 0x01, 0xff,
+				0xa0, 0x06, 0x02, 0x01, 0x01, 0x02, 0x01, 0x00,
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown target",
+			value: []byte{
+				0x30, 0x09,
+				0x02, 0x01, 0x00,
+				0x02, 0x01, 0x00,
+				0x82, 0x01, 'x',
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value := string(tt.value)
+			got, err := DecodeVirtualListViewControl(ldapmsg.Control{OID: VirtualListViewRequestControlOID, Value: &value})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("DecodeVirtualListViewControl() = %+v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DecodeVirtualListViewControl() failed: %v", err)
+			}
+			gte := got.GreaterThanOrEqual
+			got.GreaterThanOrEqual = nil
+			if got != tt.want {
+				t.Fatalf("DecodeVirtualListViewControl() = %+v, want %+v", got, tt.want)
+			}
+			if (gte == nil) != (tt.wantGTE == "") || (gte != nil && *gte != tt.wantGTE) {
+				t.Fatalf("GreaterThanOrEqual = %v, want %q", gte, tt.wantGTE)
+			}
+		})
+	}
+}
+
+func TestDecodeGetEffectiveRightsControl(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   []byte
+		want    GetEffectiveRights
+		wantErr bool
+	}{
+		{
+			name:  "bare authzId",
+			value: append([]byte{0x04, 0x0b}, "dn:uid=jane"...),
+			want:  GetEffectiveRights{AuthzID: "dn:uid=jane"},
+		},
+		{
+			name: "sequence with attributes",
+			value: append(append([]byte{
+				0x30, 0x17,
+				0x04, 0x0b,
+			}, "dn:uid=jane"...),
+				0x30, 0x08,
+				0x04, 0x04, 'm', 'a', 'i', 'l',
+				0x04, 0x00,
+			),
+			want: GetEffectiveRights{AuthzID: "dn:uid=jane", Attributes: []string{"mail", ""}},
+		},
+		{
+			name:    "integer authzId",
+			value:   []byte{0x30, 0x03, 0x02, 0x01, 0x01},
+			wantErr: true,
+		},
+		{
+			name:    "trailing bytes",
+			value:   []byte{0x04, 0x00, 0x00},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value := string(tt.value)
+			got, err := DecodeGetEffectiveRightsControl(ldapmsg.Control{OID: GetEffectiveRightsControlOID, Value: &value})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("DecodeGetEffectiveRightsControl() = %+v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DecodeGetEffectiveRightsControl() failed: %v", err)
+			}
+			if got.AuthzID != tt.want.AuthzID || !slices.Equal(got.Attributes, tt.want.Attributes) {
+				t.Fatalf("DecodeGetEffectiveRightsControl() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	got, err := DecodeGetEffectiveRightsControl(ldapmsg.Control{OID: GetEffectiveRightsControlOID})
+	if err != nil || got.AuthzID != "" || got.Attributes != nil {
+		t.Fatalf("DecodeGetEffectiveRightsControl(no value) = %+v, %v; want empty", got, err)
+	}
+}
+
+func TestDecodeAssertionControl(t *testing.T) {
+	value := string(append(append([]byte{0xa3, 0x0f, 0x04, 0x06}, "member"...), append([]byte{0x04, 0x05}, "uid=a"...)...))
+	got, err := DecodeAssertionControl(ldapmsg.Control{OID: AssertionControlOID, Value: &value})
+	if err != nil {
+		t.Fatalf("DecodeAssertionControl() failed: %v", err)
+	}
+	want := ldapmsg.EqualityMatchFilter{Attribute: "member", Value: "uid=a"}
+	if got != want {
+		t.Fatalf("DecodeAssertionControl() = %#v, want %#v", got, want)
+	}
+
+	for name, value := range map[string]string{
+		"trailing bytes": string(append([]byte{0x87, 0x04}, "mail\x00"...)),
+		"not a filter":   string([]byte{0x04, 0x00}),
+	} {
+		if got, err := DecodeAssertionControl(ldapmsg.Control{OID: AssertionControlOID, Value: &value}); err == nil {
+			t.Fatalf("DecodeAssertionControl(%s) = %#v, want error", name, got)
+		}
+	}
+	if _, err := DecodeAssertionControl(ldapmsg.Control{OID: AssertionControlOID}); err == nil {
+		t.Fatal("DecodeAssertionControl(no value) succeeded, want error")
+	}
+}
+
+func TestDecodeReadEntryControl(t *testing.T) {
+	value := string(append(append([]byte{0x30, 0x08, 0x04, 0x03}, "uid"...), 0x04, 0x01, '+'))
+	got, err := DecodeReadEntryControl(ldapmsg.Control{OID: PostReadControlOID, Value: &value})
+	if err != nil {
+		t.Fatalf("DecodeReadEntryControl() failed: %v", err)
+	}
+	if want := []string{"uid", "+"}; !slices.Equal(got, want) {
+		t.Fatalf("DecodeReadEntryControl() = %q, want %q", got, want)
+	}
+
+	value = string([]byte{0x30, 0x03, 0x02, 0x01, 0x01})
+	if got, err := DecodeReadEntryControl(ldapmsg.Control{OID: PreReadControlOID, Value: &value}); err == nil {
+		t.Fatalf("DecodeReadEntryControl(integer attribute) = %q, want error", got)
+	}
+}
+
+func TestNewReadEntryControl(t *testing.T) {
+	entry := NewSearchResultEntry("uid=a")
+	AddAttribute(&entry, "uid", "a")
+	control := NewReadEntryControl(PostReadControlOID, entry)
+	if control.OID != PostReadControlOID || control.Value == nil {
+		t.Fatalf("read entry control = %+v, want post-read control with value", control)
+	}
+	want := append(append([]byte{0x64, 0x15, 0x04, 0x05}, "uid=a"...),
+		0x30, 0x0c,
+		0x30, 0x0a,
+		0x04, 0x03, 'u', 'i', 'd',
+		0x31, 0x03, 0x04, 0x01, 'a',
+	)
+	if got := []byte(*control.Value); !bytes.Equal(got, want) {
+		t.Fatalf("read entry control value = %x, want %x", got, want)
+	}
+}
+
+func TestNewVirtualListViewResponseControl(t *testing.T) {
+	control := NewVirtualListViewResponseControl(5, 200, ldapmsg.ResultCodeSuccess, "")
+	if control.OID != VirtualListViewResponseControlOID || control.Value == nil {
+		t.Fatalf("virtual list view response control = %+v, want control with value", control)
+	}
+	want := []byte{
+		0x30, 0x0a,
+		0x02, 0x01, 0x05,
+		0x02, 0x02, 0x00, 0xc8,
+		0x0a, 0x01, 0x00,
+	}
+	if got := []byte(*control.Value); !bytes.Equal(got, want) {
+		t.Fatalf("virtual list view response value = %x, want %x", got, want)
+	}
+}
+
+func TestDecodePasswordModifyRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   []byte
+		absent  bool
+		want    [3]string // userIdentity, oldPasswd, newPasswd; "-" when absent
+		wantErr bool
+	}{
+		{
+			name:   "absent value",
+			absent: true,
+			want:   [3]string{"-", "-", "-"},
+		},
+		{
+			name: "all fields",
+			value: []byte{
+				0x30, 0x0e,
+				0x80, 0x04, 'u', ':', 'j', 'o',
+				0x81, 0x01, 'a',
+				0x82, 0x03, 'n', 'e', 'w',
+			},
+			want: [3]string{"u:jo", "a", "new"},
+		},
+		{
+			name:  "empty sequence",
+			value: []byte{0x30, 0x00},
+			want:  [3]string{"-", "-", "-"},
+		},
+		{
+			name:    "fields out of order",
+			value:   []byte{0x30, 0x06, 0x82, 0x01, 'n', 0x81, 0x01, 'o'},
+			wantErr: true,
+		},
+		{
+			name:    "unknown field",
+			value:   []byte{0x30, 0x03, 0x83, 0x01, 'x'},
+			wantErr: true,
+		},
+		{
+			name:    "not a sequence",
+			value:   []byte{0x04, 0x01, 'x'},
+			wantErr: true,
+		},
+	}
+
+	field := func(value *string) string {
+		if value == nil {
+			return "-"
+		}
+		return *value
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var value *string
+			if !tt.absent {
+				v := string(tt.value)
+				value = &v
+			}
+			got, err := DecodePasswordModifyRequest(value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("DecodePasswordModifyRequest() = %+v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DecodePasswordModifyRequest() failed: %v", err)
+			}
+			if fields := [3]string{field(got.UserIdentity), field(got.OldPassword), field(got.NewPassword)}; fields != tt.want {
+				t.Fatalf("DecodePasswordModifyRequest() = %q, want %q", fields, tt.want)
+			}
+		})
+	}
+}
+
+func TestPasswordModifyResponseEncodesExactBERFixture(t *testing.T) {
+	want := []byte{
+		0x30, 0x15,
+		0x02, 0x01, 0x01,
+		0x78, 0x10,
+		0x0a, 0x01, 0x00,
+		0x04, 0x00,
+		0x04, 0x00,
+		0x8b, 0x07,
+		0x30, 0x05, 0x80, 0x03, 'g', 'e', 'n',
+	}
+	if got := encodeProtocolOpFixture(t, NewPasswordModifyResponse("gen")); !bytes.Equal(got, want) {
+		t.Fatalf("encoded password modify BER = %x, want %x", got, want)
+	}
+
+	// Without a generated password the response has no value.
+	want = []byte{0x30, 0x0c, 0x02, 0x01, 0x01, 0x78, 0x07, 0x0a, 0x01, 0x00, 0x04, 0x00, 0x04, 0x00}
+	if got := encodeProtocolOpFixture(t, NewPasswordModifyResponse("")); !bytes.Equal(got, want) {
+		t.Fatalf("encoded password modify BER = %x, want %x", got, want)
+	}
+}
@@ -0,0 +1,19 @@
+package ldapmsg
+
+// Control is an LDAP control attached to a request or response message
+// (RFC 4511 section 4.1.11). Value is nil when the control has no value.
+type Control struct {
+	OID         string
+	Criticality bool
+	Value       *string
+}
+
+// Control returns the first control with the given OID.
+func (m *Message) Control(oid string) (Control, bool) {
+	for _, control := range m.Controls {
+		if control.OID == oid {
+			return control, true
+		}
+	}
+	return Control{}, false
+}
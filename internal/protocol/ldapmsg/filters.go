@@ -63,6 +63,17 @@ type SubstringsFilter struct {
 
 func (SubstringsFilter) isFilter() {}
 
+// ExtensibleMatchFilter is a MatchingRuleAssertion (RFC 4511 section 4.5.1.7).
+// MatchingRule or Attribute may be empty, but not both.
+type ExtensibleMatchFilter struct {
+	MatchingRule string
+	Attribute    string
+	Value        string
+	DNAttributes bool
+}
+
+func (ExtensibleMatchFilter) isFilter() {}
+
 type SubstringKind int
 
 const (
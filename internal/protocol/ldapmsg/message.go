@@ -3,8 +3,9 @@ package ldapmsg
 type MessageID int
 
 type Message struct {
-	ID MessageID
-	Op Operation
+	ID       MessageID
+	Op       Operation
+	Controls []Control
 }
 
 type Operation interface {
@@ -14,19 +15,38 @@ type Operation interface {
 type ResultCode int
 
 const (
-	ResultCodeSuccess                  ResultCode = 0
-	ResultCodeOperationsError          ResultCode = 1
-	ResultCodeProtocolError            ResultCode = 2
-	ResultCodeCompareFalse             ResultCode = 5
-	ResultCodeCompareTrue              ResultCode = 6
-	ResultCodeInvalidCredentials       ResultCode = 49
-	ResultCodeInsufficientAccessRights ResultCode = 50
-	ResultCodeUnavailable              ResultCode = 52
-	ResultCodeUnwillingToPerform       ResultCode = 53
-	ResultCodeNoSuchObject             ResultCode = 32
-	ResultCodeEntryAlreadyExists       ResultCode = 68
-	ResultCodeObjectClassViolation     ResultCode = 65
-	ResultCodeConstraintViolation      ResultCode = 19
+	ResultCodeSuccess                      ResultCode = 0
+	ResultCodeOperationsError              ResultCode = 1
+	ResultCodeProtocolError                ResultCode = 2
+	ResultCodeTimeLimitExceeded            ResultCode = 3
+	ResultCodeSizeLimitExceeded            ResultCode = 4
+	ResultCodeCompareFalse                 ResultCode = 5
+	ResultCodeCompareTrue                  ResultCode = 6
+	ResultCodeAuthMethodNotSupported       ResultCode = 7
+	ResultCodeUnavailableCriticalExtension ResultCode = 12
+	ResultCodeSaslBindInProgress           ResultCode = 14
+	ResultCodeInappropriateAuthentication  ResultCode = 48
+	ResultCodeInvalidCredentials           ResultCode = 49
+	ResultCodeInsufficientAccessRights     ResultCode = 50
+	ResultCodeBusy                         ResultCode = 51
+	ResultCodeUnavailable                  ResultCode = 52
+	ResultCodeUnwillingToPerform           ResultCode = 53
+	ResultCodeNoSuchObject                 ResultCode = 32
+	ResultCodeInvalidDNSyntax              ResultCode = 34
+	ResultCodeEntryAlreadyExists           ResultCode = 68
+	ResultCodeObjectClassViolation         ResultCode = 65
+	ResultCodeNotAllowedOnNonLeaf          ResultCode = 66
+	ResultCodeNoSuchAttribute              ResultCode = 16
+	ResultCodeConstraintViolation          ResultCode = 19
+	ResultCodeInvalidAttributeSyntax       ResultCode = 21
+	ResultCodeInappropriateMatching        ResultCode = 18
+	ResultCodeSortControlMissing           ResultCode = 60
+	ResultCodeOffsetRangeError             ResultCode = 61
+	ResultCodeAssertionFailed              ResultCode = 122
+	ResultCodeAuthorizationDenied          ResultCode = 123
+	// ResultCodeCanceled (RFC 3909) is recorded for abandoned operations.
+	// Abandoned operations send no response, so clients never see it.
+	ResultCodeCanceled ResultCode = 118
 )
 
 type Attribute struct {
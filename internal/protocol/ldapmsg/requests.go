@@ -3,10 +3,19 @@ package ldapmsg
 type BindRequest struct {
 	Name     string
 	Password string
+	// SASL is set for SASL binds, which carry no Password.
+	SASL *SASLCredentials
 }
 
 func (BindRequest) isOperation() {}
 
+// SASLCredentials is the sasl choice of a bind request's authentication.
+// Credentials is nil when the client sent none.
+type SASLCredentials struct {
+	Mechanism   string
+	Credentials *string
+}
+
 type SearchScope int
 
 const (
@@ -15,12 +24,28 @@ const (
 	SearchScopeWholeSubtree
 )
 
+// DerefAliases is the derefAliases field of a search request (RFC 4511
+// section 4.5.1.3).
+type DerefAliases int
+
+const (
+	NeverDerefAliases DerefAliases = iota
+	DerefInSearching
+	DerefFindingBaseObj
+	DerefAlways
+)
+
+// SearchRequest is an LDAP search. SizeLimit is an entry count and TimeLimit a
+// number of seconds; zero means the client requested no limit.
 type SearchRequest struct {
-	BaseObject string
-	Scope      SearchScope
-	TypesOnly  bool
-	Filter     Filter
-	Attributes []string
+	BaseObject   string
+	Scope        SearchScope
+	DerefAliases DerefAliases
+	SizeLimit    int
+	TimeLimit    int
+	TypesOnly    bool
+	Filter       Filter
+	Attributes   []string
 }
 
 func (SearchRequest) isOperation() {}
@@ -32,12 +57,15 @@ type AddRequest struct {
 
 func (AddRequest) isOperation() {}
 
+// ModifyOperation is the operation of a modify change (RFC 4511 section
+// 4.6, with increment from RFC 4525).
 type ModifyOperation int
 
 const (
 	ModifyOperationAdd ModifyOperation = iota
 	ModifyOperationDelete
 	ModifyOperationReplace
+	ModifyOperationIncrement
 )
 
 type ModifyRequest struct {
@@ -58,6 +86,15 @@ type DeleteRequest struct {
 
 func (DeleteRequest) isOperation() {}
 
+type ModifyDNRequest struct {
+	Entry        string
+	NewRDN       string
+	DeleteOldRDN bool
+	NewSuperior  *string
+}
+
+func (ModifyDNRequest) isOperation() {}
+
 type CompareRequest struct {
 	Entry string
 	AVA   AttributeValueAssertion
@@ -75,3 +112,11 @@ func (ExtendedRequest) isOperation() {}
 type UnbindRequest struct{}
 
 func (UnbindRequest) isOperation() {}
+
+// AbandonRequest asks the server to stop processing the operation with the
+// given message ID. It has no response.
+type AbandonRequest struct {
+	MessageID MessageID
+}
+
+func (AbandonRequest) isOperation() {}
@@ -8,6 +8,8 @@ type LDAPResult struct {
 
 type BindResponse struct {
 	LDAPResult
+	// ServerSASLCreds carries the server's SASL challenge or final message.
+	ServerSASLCreds *string
 }
 
 func (BindResponse) isOperation() {}
@@ -43,6 +45,12 @@ type DeleteResponse struct {
 
 func (DeleteResponse) isOperation() {}
 
+type ModifyDNResponse struct {
+	LDAPResult
+}
+
+func (ModifyDNResponse) isOperation() {}
+
 type CompareResponse struct {
 	LDAPResult
 }
@@ -0,0 +1,82 @@
+package protocol
+
+import (
+	"fmt"
+
+	"github.com/smarzola/ldaplite/internal/protocol/ber"
+	"github.com/smarzola/ldaplite/internal/protocol/ldapmsg"
+)
+
+// PasswordModifyOID is the LDAP Password Modify extended operation (RFC 3062).
+const PasswordModifyOID = "1.3.6.1.4.1.4203.1.11.1"
+
+const (
+	tagPasswordModifyUserIdentity byte = 0x80
+	tagPasswordModifyOldPassword  byte = 0x81
+	tagPasswordModifyNewPassword  byte = 0x82
+	tagPasswordModifyGenPassword  byte = 0x80
+)
+
+// PasswordModifyRequest is a decoded PasswdModifyRequestValue. Nil fields were
+// absent from the request.
+type PasswordModifyRequest struct {
+	UserIdentity *string
+	OldPassword  *string
+	NewPassword  *string
+}
+
+// DecodePasswordModifyRequest decodes the Password Modify request value. An
+// absent value is an empty request: change the bound user's password to a
+// generated one.
+func DecodePasswordModifyRequest(value *string) (PasswordModifyRequest, error) {
+	var req PasswordModifyRequest
+	if value == nil {
+		return req, nil
+	}
+
+	packet, n, err := ber.ReadPacket([]byte(*value))
+	if err != nil {
+		return req, fmt.Errorf("password modify request: %w", err)
+	}
+	if n != len(*value) {
+		return req, fmt.Errorf("password modify request has %d trailing bytes", len(*value)-n)
+	}
+	if err := packet.RequireTag(ber.ClassUniversal | ber.Constructed | ber.TagSequence); err != nil {
+		return req, fmt.Errorf("password modify request: %w", err)
+	}
+
+	// The fields are optional but ordered, so each tag must be greater than
+	// the one before it.
+	last := byte(0)
+	for _, child := range packet.Children {
+		if child.Tag <= last {
+			return req, fmt.Errorf("password modify request field 0x%02x out of order", child.Tag)
+		}
+		last = child.Tag
+
+		field := child.String()
+		switch child.Tag {
+		case tagPasswordModifyUserIdentity:
+			req.UserIdentity = &field
+		case tagPasswordModifyOldPassword:
+			req.OldPassword = &field
+		case tagPasswordModifyNewPassword:
+			req.NewPassword = &field
+		default:
+			return req, fmt.Errorf("password modify request has unexpected field 0x%02x", child.Tag)
+		}
+	}
+	return req, nil
+}
+
+// NewPasswordModifyResponse creates a Password Modify response. RFC 3062
+// omits the responseName; the value carries genPasswd only when the server
+// generated the new password.
+func NewPasswordModifyResponse(genPassword string) ldapmsg.ExtendedResponse {
+	resp := NewExtendedResponse(ldapmsg.ResultCodeSuccess)
+	if genPassword != "" {
+		value := string(ber.Sequence(ber.TLV(tagPasswordModifyGenPassword, []byte(genPassword))))
+		resp.ResponseValue = &value
+	}
+	return resp
+}
@@ -13,6 +13,14 @@ func NewBindResponse(resultCode ldapmsg.ResultCode) ldapmsg.BindResponse {
 	return ldapmsg.BindResponse{LDAPResult: ldapmsg.LDAPResult{ResultCode: resultCode}}
 }
 
+// NewSASLBindResponse creates a bind response carrying serverSaslCreds, such
+// as a SASL challenge sent with saslBindInProgress.
+func NewSASLBindResponse(resultCode ldapmsg.ResultCode, serverSASLCreds string) ldapmsg.BindResponse {
+	resp := NewBindResponse(resultCode)
+	resp.ServerSASLCreds = &serverSASLCreds
+	return resp
+}
+
 // NewSearchResultEntry creates a search result entry with the given DN
 func NewSearchResultEntry(dn string) ldapmsg.SearchResultEntry {
 	return ldapmsg.SearchResultEntry{ObjectName: dn}
@@ -56,6 +64,20 @@ func CanonicalAttributeName(name string) string {
 		return "supportedLDAPVersion"
 	case "supportedextension":
 		return "supportedExtension"
+	case "supportedcontrol":
+		return "supportedControl"
+	case "supportedsaslmechanisms":
+		return "supportedSASLMechanisms"
+	case "pwdchangedtime":
+		return "pwdChangedTime"
+	case "pwdfailuretime":
+		return "pwdFailureTime"
+	case "pwdaccountlockedtime":
+		return "pwdAccountLockedTime"
+	case "accountdisabled":
+		return "accountDisabled"
+	case "accountexpirationtime":
+		return "accountExpirationTime"
 	case "vendorname":
 		return "vendorName"
 	case "vendorversion":
@@ -85,6 +107,11 @@ func NewDelResponse(resultCode ldapmsg.ResultCode) ldapmsg.DeleteResponse {
 	return ldapmsg.DeleteResponse{LDAPResult: ldapmsg.LDAPResult{ResultCode: resultCode}}
 }
 
+// NewModifyDNResponse creates a modify DN response
+func NewModifyDNResponse(resultCode ldapmsg.ResultCode) ldapmsg.ModifyDNResponse {
+	return ldapmsg.ModifyDNResponse{LDAPResult: ldapmsg.LDAPResult{ResultCode: resultCode}}
+}
+
 // NewCompareResponse creates a compare response
 func NewCompareResponse(resultCode ldapmsg.ResultCode) ldapmsg.CompareResponse {
 	return ldapmsg.CompareResponse{LDAPResult: ldapmsg.LDAPResult{ResultCode: resultCode}}
@@ -94,3 +121,29 @@ func NewCompareResponse(resultCode ldapmsg.ResultCode) ldapmsg.CompareResponse {
 func NewExtendedResponse(resultCode ldapmsg.ResultCode) ldapmsg.ExtendedResponse {
 	return ldapmsg.ExtendedResponse{LDAPResult: ldapmsg.LDAPResult{ResultCode: resultCode}}
 }
+
+// NewResultResponse creates the response type matching a request operation
+// with the given result code. It reports false for requests without a
+// response, such as unbind.
+func NewResultResponse(op ldapmsg.Operation, resultCode ldapmsg.ResultCode) (ldapmsg.Operation, bool) {
+	switch op.(type) {
+	case ldapmsg.BindRequest:
+		return NewBindResponse(resultCode), true
+	case ldapmsg.SearchRequest:
+		return NewSearchResultDone(resultCode), true
+	case ldapmsg.AddRequest:
+		return NewAddResponse(resultCode), true
+	case ldapmsg.ModifyRequest:
+		return NewModifyResponse(resultCode), true
+	case ldapmsg.DeleteRequest:
+		return NewDelResponse(resultCode), true
+	case ldapmsg.ModifyDNRequest:
+		return NewModifyDNResponse(resultCode), true
+	case ldapmsg.CompareRequest:
+		return NewCompareResponse(resultCode), true
+	case ldapmsg.ExtendedRequest:
+		return NewExtendedResponse(resultCode), true
+	default:
+		return nil, false
+	}
+}
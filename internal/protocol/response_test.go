@@ -24,6 +24,10 @@ func TestCanonicalAttributeName(t *testing.T) {
 		{name: "subschemasubentry", want: "subschemaSubentry"},
 		{name: "supportedldapversion", want: "supportedLDAPVersion"},
 		{name: "supportedextension", want: "supportedExtension"},
+		{name: "supportedcontrol", want: "supportedControl"},
+		{name: "supportedsaslmechanisms", want: "supportedSASLMechanisms"},
+		{name: "pwdaccountlockedtime", want: "pwdAccountLockedTime"},
+		{name: "accountexpirationtime", want: "accountExpirationTime"},
 		{name: "vendorname", want: "vendorName"},
 		{name: "vendorversion", want: "vendorVersion"},
 		{name: "customattr", want: "customattr"},
@@ -6,57 +6,86 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"time"
 
+	"github.com/smarzola/ldaplite/internal/protocol/ber"
 	"github.com/smarzola/ldaplite/internal/protocol/ldapmsg"
 )
 
+// ErrMessageTooLarge is returned when a request declares a length above the
+// connection's maximum message size. The message is not read.
+var ErrMessageTooLarge = errors.New("LDAP message exceeds maximum size")
+
+// errIdleTimeout is returned when no request starts within the idle timeout.
+var errIdleTimeout = errors.New("connection idle timeout")
+
 // ReadLDAPMessage reads a single BER-encoded LDAP message from the connection
 // LDAP messages are ASN.1 BER encoded with a length prefix
 func ReadLDAPMessage(conn net.Conn) (*ldapmsg.Message, error) {
-	// Read the BER tag and length
-	// BER format: [tag][length][value]
-	// For LDAP messages, the outer tag is SEQUENCE (0x30)
-
-	// Read initial bytes to determine message length
-	// We need to read the full BER-encoded message
-	var buf [4096]byte
-	n, err := conn.Read(buf[:])
-	if err != nil {
+	return readLDAPMessage(conn, ConnectionLimits{})
+}
+
+// readLDAPMessage reads a message within limits. The idle timeout bounds the
+// wait for the first byte and the read timeout the rest of the message.
+func readLDAPMessage(conn net.Conn, limits ConnectionLimits) (*ldapmsg.Message, error) {
+	maxSize := limits.MaxMessageSize
+	if maxSize <= 0 || maxSize > ber.MaxPacketSize {
+		maxSize = ber.MaxPacketSize
+	}
+
+	// BER format: [tag][length][value]. Read the tag and the first length
+	// byte, then any long-form length bytes, then exactly the content. Never
+	// reading past the message leaves pipelined requests on the connection
+	// for the next call.
+	header := make([]byte, 2, 6)
+	// Deadline errors mean the connection is already unusable; the read that
+	// follows reports why.
+	_ = conn.SetReadDeadline(deadline(limits.IdleTimeout))
+	if _, err := io.ReadFull(conn, header[:1]); err != nil {
 		if err == io.EOF {
 			return nil, err
 		}
+		if isTimeout(err) {
+			return nil, errIdleTimeout
+		}
 		return nil, fmt.Errorf("failed to read from connection: %w", err)
 	}
-
-	if n < 2 {
-		return nil, fmt.Errorf("message too short: %d bytes", n)
+	_ = conn.SetReadDeadline(deadline(limits.ReadTimeout))
+	if _, err := io.ReadFull(conn, header[1:]); err != nil {
+		return nil, fmt.Errorf("failed to read from connection: %w", err)
+	}
+	if header[1]&0x80 != 0 {
+		numLengthBytes := int(header[1] & 0x7f)
+		if numLengthBytes == 0 || numLengthBytes > 4 {
+			return nil, fmt.Errorf("invalid BER length encoding")
+		}
+		header = header[:2+numLengthBytes]
+		if _, err := io.ReadFull(conn, header[2:]); err != nil {
+			return nil, fmt.Errorf("failed to read message length: %w", err)
+		}
 	}
 
 	// Parse BER length to determine full message size
-	messageLen, headerLen := parseBERLength(buf[:n])
+	messageLen, headerLen := parseBERLength(header)
 	if messageLen < 0 {
 		return nil, fmt.Errorf("invalid BER length encoding")
 	}
+	if messageLen > maxSize {
+		return nil, fmt.Errorf("%w: %d bytes, limit %d", ErrMessageTooLarge, messageLen, maxSize)
+	}
 
-	totalLen := headerLen + messageLen
-
-	// If we haven't read the full message yet, read more
-	data := make([]byte, totalLen)
-	copy(data, buf[:n])
-
-	if n < totalLen {
-		_, err = io.ReadFull(conn, data[n:])
-		if err != nil {
-			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
-				return nil, err
-			}
-			return nil, fmt.Errorf("failed to read full message: %w", err)
+	data := make([]byte, headerLen+messageLen)
+	copy(data, header)
+	if _, err := io.ReadFull(conn, data[headerLen:]); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, err
 		}
+		return nil, fmt.Errorf("failed to read full message: %w", err)
 	}
 
 	normalizeBERBooleans(data)
 
-	msg, err := DecodeLDAPMessage(data)
+	msg, err := decodeLDAPMessageLimit(data, maxSize)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode LDAP message: %w", err)
 	}
@@ -64,6 +93,20 @@ func ReadLDAPMessage(conn net.Conn) (*ldapmsg.Message, error) {
 	return msg, nil
 }
 
+// deadline returns the deadline for a timeout starting now, or the zero time
+// (no deadline) when the timeout is disabled.
+func deadline(timeout time.Duration) time.Time {
+	if timeout <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(timeout)
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
 func normalizeBERBooleans(data []byte) {
 	normalizeBERBooleansInRange(data, 0, len(data))
 }
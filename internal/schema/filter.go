@@ -5,6 +5,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/smarzola/ldaplite/internal/ldapdn"
 	"github.com/smarzola/ldaplite/internal/models"
 )
 
@@ -21,14 +22,74 @@ const (
 	FilterTypeGreaterOrEqual
 	FilterTypeLessOrEqual
 	FilterTypeSubstrings
+	FilterTypeExtensibleMatch
 )
 
+// Matching rules supported by extensible match filters. A filter without a
+// matching rule uses the attribute's equality rule, which is caseIgnoreMatch
+// for every attribute this server stores.
+const (
+	MatchingRuleCaseIgnore = "2.5.13.2"
+	MatchingRuleCaseExact  = "2.5.13.5"
+	// MatchingRuleInChain is LDAP_MATCHING_RULE_IN_CHAIN from Active
+	// Directory. On memberOf and member it follows nested group membership.
+	MatchingRuleInChain = "1.2.840.113556.1.4.1941"
+)
+
+var matchingRuleNames = map[string]string{
+	"caseignorematch": MatchingRuleCaseIgnore,
+	"caseexactmatch":  MatchingRuleCaseExact,
+}
+
+// canonicalMatchingRule maps a matching rule name to its OID.
+func canonicalMatchingRule(rule string) string {
+	if oid, ok := matchingRuleNames[strings.ToLower(rule)]; ok {
+		return oid
+	}
+	return rule
+}
+
 // Filter represents an LDAP search filter
 type Filter struct {
 	Type      FilterType
 	Attribute string
 	Value     string
 	Filters   []*Filter
+
+	// MatchingRule and DNAttributes are set on extensible match filters.
+	MatchingRule string
+	DNAttributes bool
+	// ChainEntryIDs holds the IDs of the entries matching an in-chain
+	// filter. The store resolves it before matching entries in memory.
+	ChainEntryIDs map[int64]bool
+}
+
+// IsInChain reports whether the filter is an extensible match using the
+// in-chain matching rule.
+func (f *Filter) IsInChain() bool {
+	return f.Type == FilterTypeExtensibleMatch && canonicalMatchingRule(f.MatchingRule) == MatchingRuleInChain
+}
+
+// Attributes returns the attribute names the filter tests, lowercased and
+// without duplicates.
+func (f *Filter) Attributes() []string {
+	var names []string
+	seen := make(map[string]bool)
+	var walk func(*Filter)
+	walk = func(filter *Filter) {
+		if filter == nil {
+			return
+		}
+		if name := strings.ToLower(filter.Attribute); name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+		for _, sub := range filter.Filters {
+			walk(sub)
+		}
+	}
+	walk(f)
+	return names
 }
 
 const escapedFilterAsterisk = '\ue000'
@@ -147,6 +208,15 @@ func parseFilterRecursive(filterStr string, pos int) (*Filter, int, error) {
 
 	filterPart := filterStr[pos : pos+endPos]
 
+	// Extensible match: (attr[:dn][:rule]:=value)
+	if idx := strings.IndexByte(filterPart, '='); idx > 0 && filterPart[idx-1] == ':' {
+		filter, err := parseExtensibleMatch(filterPart[:idx-1], filterPart[idx+1:])
+		if err != nil {
+			return nil, pos, err
+		}
+		return filter, pos + endPos + 1, nil
+	}
+
 	// Check for comparison operators: >=, <=, ~=
 	var filterType FilterType
 	var attribute, value string
@@ -200,6 +270,33 @@ func parseFilterRecursive(filterStr string, pos int) (*Filter, int, error) {
 	return filter, pos + endPos + 1, nil
 }
 
+// parseExtensibleMatch parses the attr[:dn][:rule] description and value of
+// an extensible match filter.
+func parseExtensibleMatch(description, rawValue string) (*Filter, error) {
+	parts := strings.Split(strings.TrimSpace(description), ":")
+	filter := &Filter{
+		Type:      FilterTypeExtensibleMatch,
+		Attribute: parts[0],
+		Value:     decodeLDAPFilterValue(strings.TrimSpace(rawValue), false),
+	}
+	rest := parts[1:]
+	if len(rest) > 0 && strings.EqualFold(rest[0], "dn") {
+		filter.DNAttributes = true
+		rest = rest[1:]
+	}
+	switch len(rest) {
+	case 0:
+	case 1:
+		filter.MatchingRule = rest[0]
+	default:
+		return nil, fmt.Errorf("invalid extensible match: %s:=", description)
+	}
+	if filter.Attribute == "" && filter.MatchingRule == "" {
+		return nil, fmt.Errorf("extensible match needs an attribute or a matching rule")
+	}
+	return filter, nil
+}
+
 func containsUnescapedWildcard(value string) bool {
 	for i := 0; i < len(value); i++ {
 		if value[i] == '\\' && i+2 < len(value) && isHexPair(value[i+1], value[i+2]) {
@@ -386,11 +483,66 @@ func (f *Filter) Matches(entry *models.Entry) bool {
 		}
 		return false
 
+	case FilterTypeExtensibleMatch:
+		return f.matchesExtensible(entry)
+
 	default:
 		return false
 	}
 }
 
+// matchesExtensible evaluates an extensible match filter. Unknown matching
+// rules evaluate to Undefined, which does not match.
+func (f *Filter) matchesExtensible(entry *models.Entry) bool {
+	var equal func(a, b string) bool
+	switch canonicalMatchingRule(f.MatchingRule) {
+	case MatchingRuleInChain:
+		return f.ChainEntryIDs[entry.ID]
+	case "", MatchingRuleCaseIgnore:
+		equal = strings.EqualFold
+	case MatchingRuleCaseExact:
+		equal = func(a, b string) bool { return a == b }
+	default:
+		return false
+	}
+
+	matchValues := func(values []string) bool {
+		for _, v := range values {
+			if equal(v, f.Value) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if f.Attribute != "" {
+		if matchValues(filterAttributeValues(entry, f.Attribute)) {
+			return true
+		}
+	} else {
+		if matchValues(filterAttributeValues(entry, "objectClass")) {
+			return true
+		}
+		for _, values := range entry.Attributes {
+			if matchValues(values) {
+				return true
+			}
+		}
+	}
+
+	if !f.DNAttributes {
+		return false
+	}
+	// dnAttributes also matches the attribute values in the entry's DN.
+	for dn := entry.DN; dn != ""; dn = ldapdn.Parent(dn) {
+		name, value, ok := ldapdn.SplitRDN(ldapdn.RDN(dn))
+		if ok && (f.Attribute == "" || strings.EqualFold(name, f.Attribute)) && equal(value, f.Value) {
+			return true
+		}
+	}
+	return false
+}
+
 func filterAttributeValues(entry *models.Entry, attribute string) []string {
 	switch strings.ToLower(attribute) {
 	case "objectclass":
@@ -450,6 +602,16 @@ func (f *Filter) String() string {
 	case FilterTypeLessOrEqual:
 		return fmt.Sprintf("(%s<=%s)", f.Attribute, f.Value)
 
+	case FilterTypeExtensibleMatch:
+		description := f.Attribute
+		if f.DNAttributes {
+			description += ":dn"
+		}
+		if f.MatchingRule != "" {
+			description += ":" + f.MatchingRule
+		}
+		return fmt.Sprintf("(%s:=%s)", description, f.Value)
+
 	default:
 		return ""
 	}
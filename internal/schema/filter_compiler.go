@@ -37,11 +37,55 @@ func (fc *FilterCompiler) CompileToSQL(filter *Filter) (string, []interface{}, e
 		return fc.compileGreaterOrEqual(filter.Attribute, filter.Value)
 	case FilterTypeLessOrEqual:
 		return fc.compileLessOrEqual(filter.Attribute, filter.Value)
+	case FilterTypeExtensibleMatch:
+		return fc.compileExtensibleMatch(filter)
 	default:
 		return "", nil, fmt.Errorf("unsupported filter type: %d", filter.Type)
 	}
 }
 
+// TransitiveMembersCTE defines members(entry_id, depth, path): every entry
+// that is a direct or nested member of the group whose DN is the single
+// placeholder. Queries append further CTEs or a SELECT from members.
+const TransitiveMembersCTE = `
+		WITH RECURSIVE members(entry_id, depth, path) AS (
+			SELECT gm.member_entry_id, 0, printf(',%d,', gm.member_entry_id)
+			FROM group_members gm
+			INNER JOIN entries target_group ON gm.group_entry_id = target_group.id
+			WHERE LOWER(target_group.dn) = LOWER(?)
+
+			UNION ALL
+
+			SELECT gm.member_entry_id, m.depth + 1, m.path || gm.member_entry_id || ','
+			FROM members m
+			INNER JOIN entries member_group ON m.entry_id = member_group.id
+			INNER JOIN group_members gm ON gm.group_entry_id = member_group.id
+			WHERE member_group.object_class = 'groupOfNames'
+			  AND m.depth < 100
+			  AND instr(m.path, printf(',%d,', gm.member_entry_id)) = 0
+		)`
+
+// TransitiveGroupsCTE defines member_groups(group_id, depth, path): every
+// group that directly or through nested groups contains the entry whose DN is
+// the single placeholder.
+const TransitiveGroupsCTE = `
+		WITH RECURSIVE member_groups(group_id, depth, path) AS (
+			-- Direct groups containing the entry
+			SELECT gm.group_entry_id, 0, printf(',%d,', gm.group_entry_id)
+			FROM group_members gm
+			INNER JOIN entries member_entry ON gm.member_entry_id = member_entry.id
+			WHERE LOWER(member_entry.dn) = LOWER(?)
+
+			UNION ALL
+
+			-- Parent groups containing one of the entry's groups
+			SELECT gm.group_entry_id, mg.depth + 1, mg.path || gm.group_entry_id || ','
+			FROM group_members gm
+			INNER JOIN member_groups mg ON gm.member_entry_id = mg.group_id
+			WHERE mg.depth < 100
+			  AND instr(mg.path, printf(',%d,', gm.group_entry_id)) = 0
+		)`
+
 // computedAttributes are attributes that are not stored in the attributes table
 // but computed dynamically (e.g., memberOf from group_members table).
 // These require in-memory filtering and cannot be compiled to SQL.
@@ -65,6 +109,9 @@ func FilterUsesComputedAttributes(filter *Filter) bool {
 	case FilterTypeEquality, FilterTypePresent, FilterTypeSubstrings,
 		FilterTypeGreaterOrEqual, FilterTypeLessOrEqual, FilterTypeApproxMatch:
 		return isComputedAttribute(filter.Attribute)
+	case FilterTypeExtensibleMatch:
+		// In-chain filters are resolved from group_members, not memberOf.
+		return !filter.IsInChain() && isComputedAttribute(filter.Attribute)
 	case FilterTypeAnd, FilterTypeOr:
 		for _, sf := range filter.Filters {
 			if FilterUsesComputedAttributes(sf) {
@@ -146,6 +193,17 @@ func (fc *FilterCompiler) CanCompileToSQL(filter *Filter) bool {
 		// Comparison operators supported for operational timestamp attributes
 		attrLower := strings.ToLower(filter.Attribute)
 		return attrLower == "createtimestamp" || attrLower == "modifytimestamp"
+	case FilterTypeExtensibleMatch:
+		if filter.IsInChain() {
+			return true
+		}
+		// dnAttributes and filters without an attribute are matched in memory
+		switch canonicalMatchingRule(filter.MatchingRule) {
+		case "", MatchingRuleCaseIgnore, MatchingRuleCaseExact:
+			return !filter.DNAttributes && filter.Attribute != "" && !isComputedAttribute(filter.Attribute)
+		default:
+			return false
+		}
 	case FilterTypeAnd, FilterTypeOr:
 		// All sub-filters must be compilable
 		for _, sf := range filter.Filters {
@@ -184,6 +242,40 @@ func (fc *FilterCompiler) compileEquality(attr, value string) (string, []interfa
 	return clause, []interface{}{attr, value}, nil
 }
 
+// compileExtensibleMatch compiles an extensible match filter with a known
+// matching rule: (attr:rule:=value)
+func (fc *FilterCompiler) compileExtensibleMatch(filter *Filter) (string, []interface{}, error) {
+	switch canonicalMatchingRule(filter.MatchingRule) {
+	case MatchingRuleInChain:
+		switch strings.ToLower(filter.Attribute) {
+		case "memberof":
+			return "e.id IN (" + TransitiveMembersCTE + `
+		SELECT entry_id FROM members)`, []interface{}{filter.Value}, nil
+		case "member":
+			return "e.id IN (" + TransitiveGroupsCTE + `
+		SELECT group_id FROM member_groups)`, []interface{}{filter.Value}, nil
+		default:
+			// The in-chain rule only applies to DN-valued membership attributes
+			return "1=0", nil, nil
+		}
+	case "", MatchingRuleCaseIgnore:
+		return fc.compileEquality(filter.Attribute, filter.Value)
+	case MatchingRuleCaseExact:
+		if strings.EqualFold(filter.Attribute, "objectClass") {
+			return "e.object_class = ?", []interface{}{filter.Value}, nil
+		}
+		clause := `EXISTS (
+		SELECT 1 FROM attributes a
+		WHERE a.entry_id = e.id
+		  AND LOWER(a.name) = LOWER(?)
+		  AND a.value = ?
+	)`
+		return clause, []interface{}{filter.Attribute, filter.Value}, nil
+	default:
+		return "", nil, fmt.Errorf("unsupported matching rule: %s", filter.MatchingRule)
+	}
+}
+
 // compilePresent compiles a presence filter: (attr=*)
 func (fc *FilterCompiler) compilePresent(attr string) (string, []interface{}, error) {
 	attrLower := strings.ToLower(attr)
@@ -512,6 +512,31 @@ func TestCanCompileToSQL(t *testing.T) {
 			},
 			want: true,
 		},
+		{
+			name:   "extensible match with caseExactMatch",
+			filter: &Filter{Type: FilterTypeExtensibleMatch, Attribute: "cn", MatchingRule: "caseExactMatch", Value: "John"},
+			want:   true,
+		},
+		{
+			name:   "extensible match with dnAttributes",
+			filter: &Filter{Type: FilterTypeExtensibleMatch, Attribute: "ou", DNAttributes: true, Value: "users"},
+			want:   false,
+		},
+		{
+			name:   "extensible match without attribute",
+			filter: &Filter{Type: FilterTypeExtensibleMatch, MatchingRule: MatchingRuleCaseIgnore, Value: "users"},
+			want:   false,
+		},
+		{
+			name:   "extensible match with unknown rule",
+			filter: &Filter{Type: FilterTypeExtensibleMatch, Attribute: "cn", MatchingRule: "1.2.3.4", Value: "John"},
+			want:   false,
+		},
+		{
+			name:   "in-chain memberOf",
+			filter: &Filter{Type: FilterTypeExtensibleMatch, Attribute: "memberOf", MatchingRule: MatchingRuleInChain, Value: "cn=team,dc=example,dc=com"},
+			want:   true,
+		},
 		{
 			name:   "nil filter",
 			filter: nil,
@@ -529,6 +554,69 @@ func TestCanCompileToSQL(t *testing.T) {
 	}
 }
 
+func TestCompileExtensibleMatch(t *testing.T) {
+	compiler := NewFilterCompiler()
+
+	tests := []struct {
+		name     string
+		filter   *Filter
+		wantSQL  []string
+		wantArgs []interface{}
+	}{
+		{
+			name:     "caseIgnoreMatch compiles like equality",
+			filter:   &Filter{Type: FilterTypeExtensibleMatch, Attribute: "cn", MatchingRule: "caseIgnoreMatch", Value: "John"},
+			wantSQL:  []string{"LOWER(a.value) = LOWER(?)"},
+			wantArgs: []interface{}{"cn", "John"},
+		},
+		{
+			name:     "caseExactMatch compares values exactly",
+			filter:   &Filter{Type: FilterTypeExtensibleMatch, Attribute: "cn", MatchingRule: MatchingRuleCaseExact, Value: "John"},
+			wantSQL:  []string{"a.value = ?"},
+			wantArgs: []interface{}{"cn", "John"},
+		},
+		{
+			name:     "in-chain memberOf walks nested members",
+			filter:   &Filter{Type: FilterTypeExtensibleMatch, Attribute: "memberOf", MatchingRule: MatchingRuleInChain, Value: "cn=team,dc=example,dc=com"},
+			wantSQL:  []string{"e.id IN (", "WITH RECURSIVE members", "SELECT entry_id FROM members"},
+			wantArgs: []interface{}{"cn=team,dc=example,dc=com"},
+		},
+		{
+			name:     "in-chain member walks parent groups",
+			filter:   &Filter{Type: FilterTypeExtensibleMatch, Attribute: "member", MatchingRule: MatchingRuleInChain, Value: "uid=john,dc=example,dc=com"},
+			wantSQL:  []string{"e.id IN (", "WITH RECURSIVE member_groups", "SELECT group_id FROM member_groups"},
+			wantArgs: []interface{}{"uid=john,dc=example,dc=com"},
+		},
+		{
+			name:    "in-chain on other attributes matches nothing",
+			filter:  &Filter{Type: FilterTypeExtensibleMatch, Attribute: "cn", MatchingRule: MatchingRuleInChain, Value: "x"},
+			wantSQL: []string{"1=0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, args, err := compiler.CompileToSQL(tt.filter)
+			if err != nil {
+				t.Fatalf("CompileToSQL() error = %v", err)
+			}
+			for _, want := range tt.wantSQL {
+				if !strings.Contains(sql, want) {
+					t.Errorf("CompileToSQL() SQL = %v, want to contain %v", sql, want)
+				}
+			}
+			if len(args) != len(tt.wantArgs) {
+				t.Fatalf("CompileToSQL() args = %v, want %v", args, tt.wantArgs)
+			}
+			for i := range args {
+				if args[i] != tt.wantArgs[i] {
+					t.Errorf("CompileToSQL() args = %v, want %v", args, tt.wantArgs)
+				}
+			}
+		})
+	}
+}
+
 func TestMemberOfEqualityValue(t *testing.T) {
 	tests := []struct {
 		name   string
@@ -75,6 +75,12 @@ func TestParseNot(t *testing.T) {
 	assert.Equal(t, "uid", filter.Filters[0].Attribute)
 }
 
+func TestFilterAttributes(t *testing.T) {
+	filter, err := ParseFilter("(&(objectClass=inetOrgPerson)(|(Mobile=555*)(!(mobile=*)))(uid=jane))")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"objectclass", "mobile", "uid"}, filter.Attributes())
+}
+
 func TestParseEmptyFilter(t *testing.T) {
 	filter, err := ParseFilter("")
 	assert.NoError(t, err)
@@ -91,6 +97,8 @@ func TestParseInvalid(t *testing.T) {
 		{"missing closing paren", "(uid=john"},
 		{"missing opening paren", "uid=john)"},
 		{"invalid format", "(invalid)"},
+		{"extensible match without attribute or rule", "(:=john)"},
+		{"extensible match with extra components", "(cn:dn:2.5.13.2:x:=john)"},
 	}
 
 	for _, tt := range tests {
@@ -101,6 +109,66 @@ func TestParseInvalid(t *testing.T) {
 	}
 }
 
+func TestParseExtensibleMatch(t *testing.T) {
+	tests := []struct {
+		filter string
+		want   Filter
+	}{
+		{"(cn:caseExactMatch:=John)", Filter{Type: FilterTypeExtensibleMatch, Attribute: "cn", MatchingRule: "caseExactMatch", Value: "John"}},
+		{"(ou:dn:=users)", Filter{Type: FilterTypeExtensibleMatch, Attribute: "ou", DNAttributes: true, Value: "users"}},
+		{"(:dn:2.5.13.2:=users)", Filter{Type: FilterTypeExtensibleMatch, MatchingRule: MatchingRuleCaseIgnore, DNAttributes: true, Value: "users"}},
+		{
+			"(memberOf:1.2.840.113556.1.4.1941:=cn=team,ou=groups,dc=example,dc=com)",
+			Filter{Type: FilterTypeExtensibleMatch, Attribute: "memberOf", MatchingRule: MatchingRuleInChain, Value: "cn=team,ou=groups,dc=example,dc=com"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			filter, err := ParseFilter(tt.filter)
+			assert.NoError(t, err)
+			assert.Equal(t, &tt.want, filter)
+			assert.Equal(t, tt.filter, filter.String())
+		})
+	}
+}
+
+func TestMatchesExtensibleMatch(t *testing.T) {
+	entry := models.NewEntry("uid=john,ou=users,dc=example,dc=com", "inetOrgPerson")
+	entry.ID = 7
+	entry.SetAttribute("uid", "john")
+	entry.SetAttribute("cn", "John Doe")
+
+	tests := []struct {
+		filter string
+		want   bool
+	}{
+		{"(cn:=john doe)", true},
+		{"(cn:caseIgnoreMatch:=john doe)", true},
+		{"(cn:caseExactMatch:=john doe)", false},
+		{"(cn:2.5.13.5:=John Doe)", true},
+		{"(:caseExactMatch:=John Doe)", true},
+		{"(ou:=users)", false},
+		{"(ou:dn:=Users)", true},
+		{"(:dn:caseExactMatch:=Users)", false},
+		{"(cn:1.2.3.4:=John Doe)", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			filter, err := ParseFilter(tt.filter)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, filter.Matches(entry))
+		})
+	}
+
+	inChain, err := ParseFilter("(memberOf:1.2.840.113556.1.4.1941:=cn=team,ou=groups,dc=example,dc=com)")
+	assert.NoError(t, err)
+	assert.False(t, inChain.Matches(entry), "unresolved in-chain filter matched")
+	inChain.ChainEntryIDs = map[int64]bool{entry.ID: true}
+	assert.True(t, inChain.Matches(entry))
+}
+
 func TestMatchesEquality(t *testing.T) {
 	filter, _ := ParseFilter("(uid=john)")
 	entry := models.NewEntry("uid=john,ou=users,dc=example,dc=com", "inetOrgPerson")
@@ -13,6 +13,7 @@ const (
 	resourceTypeSchema          = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
 	userSchema                  = "urn:ietf:params:scim:schemas:core:2.0:User"
 	groupSchema                 = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	patchOpSchema               = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
 )
 
 type Contract struct {
@@ -48,7 +49,7 @@ func DefaultContract() Contract {
 		},
 		AuthScheme:      "HTTP Basic with LDAPLite user credentials",
 		ReadCapability:  string(authz.DirectoryRead),
-		WriteCapability: string(authz.DirectoryWrite),
+		WriteCapability: string(authz.SCIMWrite),
 		SupportsPatch:   false,
 		SupportsBulk:    false,
 	}
@@ -5,15 +5,20 @@ import (
 	"errors"
 	"net/http"
 	"net/url"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/smarzola/ldaplite/internal/authz"
 	"github.com/smarzola/ldaplite/internal/directory"
 	"github.com/smarzola/ldaplite/internal/models"
+	"github.com/smarzola/ldaplite/internal/ppolicy"
 	"github.com/smarzola/ldaplite/internal/store"
+	"github.com/smarzola/ldaplite/internal/web/middleware"
 	"github.com/smarzola/ldaplite/pkg/config"
+	"github.com/smarzola/ldaplite/pkg/crypto"
 )
 
 type Handler struct {
@@ -109,6 +114,7 @@ func (h *Handler) Schemas(w http.ResponseWriter, r *http.Request) {
 				{Name: "displayName", Type: "string", MultiValued: false, Required: true, Mutability: "readWrite"},
 				{Name: "emails", Type: "complex", MultiValued: true, Required: false, Mutability: "readWrite"},
 				{Name: "password", Type: "string", MultiValued: false, Required: false, Mutability: "writeOnly"},
+				{Name: "active", Type: "boolean", MultiValued: false, Required: false, Mutability: "readWrite"},
 			},
 		},
 		{
@@ -177,6 +183,8 @@ func (h *Handler) Groups(w http.ResponseWriter, r *http.Request) {
 		h.createGroup(w, r)
 	case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, BasePath+"/Groups/"):
 		h.replaceGroup(w, r)
+	case r.Method == http.MethodPatch && strings.HasPrefix(r.URL.Path, BasePath+"/Groups/"):
+		h.patchGroupMembers(w, r)
 	case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, BasePath+"/Groups/"):
 		h.deleteGroup(w, r)
 	default:
@@ -206,6 +214,11 @@ func (h *Handler) listUsers(w http.ResponseWriter, r *http.Request) {
 		writeSCIMError(w, http.StatusInternalServerError, "Failed to search SCIM users")
 		return
 	}
+	entries, err = h.visibleEntries(r, entries, filter)
+	if err != nil {
+		writeSCIMError(w, http.StatusInternalServerError, "Failed to search SCIM users")
+		return
+	}
 	sort.Slice(entries, func(i, j int) bool {
 		return strings.ToLower(entries[i].DN) < strings.ToLower(entries[j].DN)
 	})
@@ -259,6 +272,11 @@ func (h *Handler) listGroups(w http.ResponseWriter, r *http.Request) {
 		writeSCIMError(w, http.StatusInternalServerError, "Failed to search SCIM groups")
 		return
 	}
+	entries, err = h.visibleEntries(r, entries, filter)
+	if err != nil {
+		writeSCIMError(w, http.StatusInternalServerError, "Failed to search SCIM groups")
+		return
+	}
 	sort.Slice(entries, func(i, j int) bool {
 		return strings.ToLower(entries[i].DN) < strings.ToLower(entries[j].DN)
 	})
@@ -300,7 +318,11 @@ func (h *Handler) createUser(w http.ResponseWriter, r *http.Request) {
 		writeSCIMError(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	entry, err := h.service.CreateUser(r.Context(), directoryInput)
+	if err := h.checkWrite(r, directory.NewEntryDN("uid", directoryInput.UID, directoryInput.ParentDN)); err != nil {
+		writeDirectoryError(w, err)
+		return
+	}
+	entry, err := h.service.CreateUser(r.Context(), authz.BoundUser(middleware.GetUserDN(r)), directoryInput)
 	if err != nil {
 		writeDirectoryError(w, err)
 		return
@@ -335,7 +357,11 @@ func (h *Handler) replaceUser(w http.ResponseWriter, r *http.Request) {
 		writeSCIMError(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	updated, err := h.service.UpdateUser(r.Context(), entry.DN, directoryInput)
+	if err := h.checkWrite(r, entry.DN); err != nil {
+		writeDirectoryError(w, err)
+		return
+	}
+	updated, err := h.service.UpdateUser(r.Context(), authz.BoundUser(middleware.GetUserDN(r)), entry.DN, directoryInput)
 	if err != nil {
 		writeDirectoryError(w, err)
 		return
@@ -358,6 +384,10 @@ func (h *Handler) deleteUser(w http.ResponseWriter, r *http.Request) {
 		writeSCIMError(w, http.StatusNotFound, "SCIM user not found")
 		return
 	}
+	if err := h.checkWrite(r, entry.DN); err != nil {
+		writeDirectoryError(w, err)
+		return
+	}
 	if err := h.service.DeleteEntry(r.Context(), entry.DN); err != nil {
 		writeDirectoryError(w, err)
 		return
@@ -375,6 +405,10 @@ func (h *Handler) createGroup(w http.ResponseWriter, r *http.Request) {
 		writeSCIMError(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	if err := h.checkWrite(r, directory.NewEntryDN("cn", directoryInput.CN, directoryInput.ParentDN)); err != nil {
+		writeDirectoryError(w, err)
+		return
+	}
 	entry, err := h.service.CreateGroup(r.Context(), directoryInput)
 	if err != nil {
 		writeDirectoryError(w, err)
@@ -410,6 +444,10 @@ func (h *Handler) replaceGroup(w http.ResponseWriter, r *http.Request) {
 		writeSCIMError(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	if err := h.checkWrite(r, entry.DN); err != nil {
+		writeDirectoryError(w, err)
+		return
+	}
 	updated, err := h.service.UpdateGroup(r.Context(), entry.DN, directoryInput)
 	if err != nil {
 		writeDirectoryError(w, err)
@@ -418,6 +456,118 @@ func (h *Handler) replaceGroup(w http.ResponseWriter, r *http.Request) {
 	writeSCIMJSON(w, http.StatusOK, h.groupResource(r, updated))
 }
 
+// patchGroupMembers applies PATCH operations that add or remove members.
+// Owners of the group may use it without scim.write; the directory service
+// checks ownership. Other PATCH operations are rejected.
+func (h *Handler) patchGroupMembers(w http.ResponseWriter, r *http.Request) {
+	id, err := pathResourceID(r.URL.Path, BasePath+"/Groups/")
+	if err != nil {
+		writeSCIMError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	entry, ok, err := h.groupByID(r, id)
+	if err != nil {
+		writeSCIMError(w, http.StatusInternalServerError, "Failed to load SCIM group")
+		return
+	}
+	if !ok {
+		writeSCIMError(w, http.StatusNotFound, "SCIM group not found")
+		return
+	}
+
+	var input patchRequest
+	if !decodeSCIMJSON(w, r, &input) {
+		return
+	}
+	change, err := h.membershipChange(r, input)
+	if err != nil {
+		writeSCIMError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	actor := authz.BoundUser(middleware.GetUserDN(r))
+	updated, err := h.service.ChangeMembers(r.Context(), actor, authz.SCIMWrite, entry.DN, change)
+	if err != nil {
+		writeDirectoryError(w, err)
+		return
+	}
+	writeSCIMJSON(w, http.StatusOK, h.groupResource(r, updated))
+}
+
+// membershipChange maps PATCH operations on members to directory member
+// DNs. Removals name the member either in a value filter on the path or in
+// the value, as clients differ.
+func (h *Handler) membershipChange(r *http.Request, input patchRequest) (directory.MembershipChange, error) {
+	if !slices.Contains(input.Schemas, patchOpSchema) {
+		return directory.MembershipChange{}, requestError("schemas must include " + patchOpSchema)
+	}
+	if len(input.Operations) == 0 {
+		return directory.MembershipChange{}, requestError("Operations are required")
+	}
+	var change directory.MembershipChange
+	for _, operation := range input.Operations {
+		op := strings.ToLower(strings.TrimSpace(operation.Op))
+		if op != "add" && op != "remove" {
+			return directory.MembershipChange{}, requestError("Only add and remove operations on members are supported")
+		}
+		path, filterID, err := parseMembersPath(operation.Path)
+		if err != nil {
+			return directory.MembershipChange{}, err
+		}
+		if path != "members" {
+			return directory.MembershipChange{}, requestError("Only add and remove operations on members are supported")
+		}
+
+		var ids []string
+		if filterID != "" {
+			ids = append(ids, filterID)
+		}
+		if len(operation.Value) > 0 {
+			var members []memberResource
+			if err := json.Unmarshal(operation.Value, &members); err != nil {
+				return directory.MembershipChange{}, requestError("members value must be a list of members")
+			}
+			for _, member := range members {
+				ids = append(ids, member.Value)
+			}
+		}
+		if len(ids) == 0 {
+			return directory.MembershipChange{}, requestError("member value is required")
+		}
+
+		for _, id := range ids {
+			memberDN, err := h.memberDNBySCIMID(r, id)
+			if err != nil {
+				return directory.MembershipChange{}, err
+			}
+			if op == "add" {
+				change.Add = append(change.Add, memberDN)
+			} else {
+				change.Remove = append(change.Remove, memberDN)
+			}
+		}
+	}
+	return change, nil
+}
+
+// parseMembersPath splits a PATCH path of the form members or
+// members[value eq "<id>"].
+func parseMembersPath(path string) (string, string, error) {
+	path = strings.TrimSpace(path)
+	name, filter, ok := strings.Cut(path, "[")
+	if !ok {
+		return strings.ToLower(path), "", nil
+	}
+	filter, ok = strings.CutSuffix(filter, "]")
+	if !ok {
+		return "", "", requestError("Invalid PATCH path")
+	}
+	attr, value, ok, err := parseSimpleEqualityFilter(filter)
+	if err != nil || !ok || !strings.EqualFold(attr, "value") {
+		return "", "", requestError(`Only members[value eq "..."] paths are supported`)
+	}
+	return strings.ToLower(strings.TrimSpace(name)), value, nil
+}
+
 func (h *Handler) deleteGroup(w http.ResponseWriter, r *http.Request) {
 	id, err := pathResourceID(r.URL.Path, BasePath+"/Groups/")
 	if err != nil {
@@ -433,6 +583,10 @@ func (h *Handler) deleteGroup(w http.ResponseWriter, r *http.Request) {
 		writeSCIMError(w, http.StatusNotFound, "SCIM group not found")
 		return
 	}
+	if err := h.checkWrite(r, entry.DN); err != nil {
+		writeDirectoryError(w, err)
+		return
+	}
 	if err := h.service.DeleteEntry(r.Context(), entry.DN); err != nil {
 		writeDirectoryError(w, err)
 		return
@@ -441,12 +595,16 @@ func (h *Handler) deleteGroup(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) userByID(r *http.Request, id string) (*models.Entry, bool, error) {
+	filter := "(&(objectClass=inetOrgPerson)(entryUUID=" + escapeLDAPFilterAssertionValue(id) + "))"
 	entries, err := h.store.SearchEntriesWithOptions(r.Context(), store.SearchOptions{
 		BaseDN:          h.cfg.LDAP.BaseDN,
-		Filter:          "(&(objectClass=inetOrgPerson)(entryUUID=" + escapeLDAPFilterAssertionValue(id) + "))",
+		Filter:          filter,
 		Scope:           store.SearchScopeWholeSubtree,
 		IncludeMemberOf: false,
 	})
+	if err == nil {
+		entries, err = h.visibleEntries(r, entries, filter)
+	}
 	if err != nil {
 		return nil, false, err
 	}
@@ -457,12 +615,16 @@ func (h *Handler) userByID(r *http.Request, id string) (*models.Entry, bool, err
 }
 
 func (h *Handler) groupByID(r *http.Request, id string) (*models.Entry, bool, error) {
+	filter := "(&(objectClass=groupOfNames)(entryUUID=" + escapeLDAPFilterAssertionValue(id) + "))"
 	entries, err := h.store.SearchEntriesWithOptions(r.Context(), store.SearchOptions{
 		BaseDN:          h.cfg.LDAP.BaseDN,
-		Filter:          "(&(objectClass=groupOfNames)(entryUUID=" + escapeLDAPFilterAssertionValue(id) + "))",
+		Filter:          filter,
 		Scope:           store.SearchScopeWholeSubtree,
 		IncludeMemberOf: false,
 	})
+	if err == nil {
+		entries, err = h.visibleEntries(r, entries, filter)
+	}
 	if err != nil {
 		return nil, false, err
 	}
@@ -472,6 +634,19 @@ func (h *Handler) groupByID(r *http.Request, id string) (*models.Entry, bool, er
 	return entries[0], true, nil
 }
 
+// visibleEntries applies the access rules of the request's user to entries
+// found with filter.
+func (h *Handler) visibleEntries(r *http.Request, entries []*models.Entry, filter string) ([]*models.Entry, error) {
+	actor := authz.BoundUser(middleware.GetUserDN(r))
+	return authz.FromConfig(h.cfg, h.store).VisibleEntries(r.Context(), actor, entries, filter)
+}
+
+// checkWrite rejects a write to dn outside the scope in which the request's
+// user holds SCIMWrite.
+func (h *Handler) checkWrite(r *http.Request, dn string) error {
+	return h.service.CheckWrite(r.Context(), authz.BoundUser(middleware.GetUserDN(r)), authz.SCIMWrite, dn)
+}
+
 func (h *Handler) userResource(r *http.Request, entry *models.Entry) userResource {
 	id := entry.GetAttribute("entryUUID")
 	resource := userResource{
@@ -479,6 +654,7 @@ func (h *Handler) userResource(r *http.Request, entry *models.Entry) userResourc
 		ID:          id,
 		UserName:    entry.GetAttribute("uid"),
 		DisplayName: entry.GetAttribute("cn"),
+		Active:      !entry.AccountStatus().Disabled,
 		Name: nameResource{
 			GivenName:  entry.GetAttribute("givenName"),
 			FamilyName: entry.GetAttribute("sn"),
@@ -524,6 +700,11 @@ func (h *Handler) memberResource(r *http.Request, memberDN string) (memberResour
 	if err != nil || entry == nil {
 		return memberResource{}, false
 	}
+	visible, err := h.visibleEntries(r, []*models.Entry{entry}, "")
+	if err != nil || len(visible) == 0 {
+		return memberResource{}, false
+	}
+	entry = visible[0]
 	id := entry.GetAttribute("entryUUID")
 	if id == "" {
 		return memberResource{}, false
@@ -543,9 +724,6 @@ func (h *Handler) memberResource(r *http.Request, memberDN string) (memberResour
 }
 
 func (h *Handler) userDirectoryInput(input userRequest, existingUID string, requirePassword bool) (directory.UserInput, error) {
-	if input.Active != nil {
-		return directory.UserInput{}, requestError("SCIM active state changes are not supported")
-	}
 	uid := strings.TrimSpace(input.UserName)
 	if uid == "" {
 		return directory.UserInput{}, requestError("userName is required")
@@ -563,7 +741,7 @@ func (h *Handler) userDirectoryInput(input userRequest, existingUID string, requ
 		return directory.UserInput{}, requestError("password is required")
 	}
 
-	return directory.UserInput{
+	userInput := directory.UserInput{
 		ParentDN:  "ou=users," + h.cfg.LDAP.BaseDN,
 		UID:       uid,
 		CN:        cn,
@@ -571,7 +749,13 @@ func (h *Handler) userDirectoryInput(input userRequest, existingUID string, requ
 		GivenName: input.Name.GivenName,
 		Mail:      primaryEmail(input.Emails),
 		Password:  input.Password,
-	}, nil
+	}
+	if input.Active != nil {
+		// active:false suspends the user instead of deleting it.
+		disabled := !*input.Active
+		userInput.Disabled = &disabled
+	}
+	return userInput, nil
 }
 
 func (h *Handler) groupDirectoryInput(r *http.Request, input groupRequest, existingCN string, requireMembers bool) (directory.GroupInput, error) {
@@ -651,13 +835,18 @@ func writeDirectoryError(w http.ResponseWriter, err error) {
 		errors.Is(err, directory.ErrProtectedAttribute),
 		errors.Is(err, directory.ErrUnsupportedObject),
 		errors.Is(err, directory.ErrPasswordNotProvided),
+		errors.Is(err, ppolicy.ErrPolicyViolation),
 		errors.Is(err, store.ErrConstraintViolation),
 		errors.Is(err, store.ErrObjectClassViolation):
 		writeSCIMError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, directory.ErrAccessDenied):
+		writeSCIMError(w, http.StatusForbidden, err.Error())
 	case errors.Is(err, store.ErrNoSuchObject):
 		writeSCIMError(w, http.StatusNotFound, err.Error())
 	case errors.Is(err, store.ErrEntryAlreadyExists):
 		writeSCIMError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, crypto.ErrHashPoolBusy):
+		writeSCIMError(w, http.StatusServiceUnavailable, err.Error())
 	default:
 		writeSCIMError(w, http.StatusInternalServerError, "SCIM directory operation failed")
 	}
@@ -927,6 +1116,7 @@ type userResource struct {
 	Name        nameResource    `json:"name"`
 	DisplayName string          `json:"displayName"`
 	Emails      []emailResource `json:"emails,omitempty"`
+	Active      bool            `json:"active"`
 	Meta        metaResource    `json:"meta"`
 }
 
@@ -954,6 +1144,17 @@ type groupRequest struct {
 	Members     []memberResource `json:"members,omitempty"`
 }
 
+type patchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []patchOperation `json:"Operations"`
+}
+
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
 type memberResource struct {
 	Value   string `json:"value"`
 	Display string `json:"display,omitempty"`
@@ -11,6 +11,7 @@ import (
 
 	"github.com/smarzola/ldaplite/internal/models"
 	"github.com/smarzola/ldaplite/internal/store"
+	"github.com/smarzola/ldaplite/internal/web/middleware"
 	"github.com/smarzola/ldaplite/pkg/config"
 	"github.com/smarzola/ldaplite/pkg/crypto"
 )
@@ -36,8 +37,8 @@ func TestDefaultContractCapturesBaselineScope(t *testing.T) {
 	if contract.ReadCapability != "directory.read" {
 		t.Fatalf("ReadCapability = %q, want directory.read", contract.ReadCapability)
 	}
-	if contract.WriteCapability != "directory.write" {
-		t.Fatalf("WriteCapability = %q, want directory.write", contract.WriteCapability)
+	if contract.WriteCapability != "scim.write" {
+		t.Fatalf("WriteCapability = %q, want scim.write", contract.WriteCapability)
 	}
 }
 
@@ -409,7 +410,7 @@ func TestUserCreateReplaceAndDeleteUseDirectoryService(t *testing.T) {
 	}
 	assertPasswordValid(t, st, "provisioned", "ProvisionedChanged123!")
 
-	deleteReq := httptest.NewRequest(http.MethodDelete, "http://ldaplite.test/scim/v2/Users/"+created.ID, nil)
+	deleteReq := asSCIMUser(httptest.NewRequest(http.MethodDelete, "http://ldaplite.test/scim/v2/Users/"+created.ID, nil), scimTestAdminDN)
 	deleteRR := httptest.NewRecorder()
 
 	handler.Users(deleteRR, deleteReq)
@@ -428,6 +429,156 @@ func TestUserCreateReplaceAndDeleteUseDirectoryService(t *testing.T) {
 	}
 }
 
+func TestWritesOutsideDelegatedSubtreeAreForbidden(t *testing.T) {
+	cfg, st := setupTestStore(t)
+	defer st.Close()
+	cfg.Authz.DelegatedAdmins = []config.DelegatedAdmin{{
+		GroupDN:  "cn=emea-admins,ou=groups,dc=test,dc=com",
+		Subtrees: []string{"ou=emea,dc=test,dc=com"},
+	}}
+	lead := createSCIMTestUser(t, st, "lead", "EMEA Lead", "Lead", "", "")
+	createSCIMTestGroup(t, st, "emea-admins", "", lead.DN)
+	jane := createSCIMTestUser(t, st, "jane", "Jane Doe", "Doe", "", "")
+	handler := NewHandler(st, cfg)
+
+	createReq := asSCIMUser(scimJSONRequest(t, http.MethodPost, "http://ldaplite.test/scim/v2/Users", userRequest{
+		UserName:    "provisioned",
+		DisplayName: "Provisioned User",
+		Name:        nameResource{FamilyName: "User"},
+		Password:    "ProvisionedPassword123!",
+	}), lead.DN)
+	createRR := httptest.NewRecorder()
+	handler.Users(createRR, createReq)
+	if createRR.Code != http.StatusForbidden {
+		t.Fatalf("create status = %d, want %d; body=%s", createRR.Code, http.StatusForbidden, createRR.Body.String())
+	}
+
+	deleteReq := asSCIMUser(httptest.NewRequest(http.MethodDelete, "http://ldaplite.test/scim/v2/Users/"+jane.GetAttribute("entryUUID"), nil), lead.DN)
+	deleteRR := httptest.NewRecorder()
+	handler.Users(deleteRR, deleteReq)
+	if deleteRR.Code != http.StatusForbidden {
+		t.Fatalf("delete status = %d, want %d; body=%s", deleteRR.Code, http.StatusForbidden, deleteRR.Body.String())
+	}
+}
+
+func TestGroupOwnersPatchOnlyMembers(t *testing.T) {
+	cfg, st := setupTestStore(t)
+	defer st.Close()
+	lead := createSCIMTestUser(t, st, "lead", "Team Lead", "Lead", "", "")
+	jane := createSCIMTestUser(t, st, "jane", "Jane Doe", "Doe", "", "")
+	ana := createSCIMTestUser(t, st, "ana", "Ana Diaz", "Diaz", "", "")
+	owners := createSCIMTestGroup(t, st, "team-owners", "", lead.DN)
+	team := createSCIMTestGroup(t, st, "team", "", jane.DN)
+	team.SetAttribute("owner", owners.DN)
+	if err := st.UpdateEntry(context.Background(), team); err != nil {
+		t.Fatalf("UpdateEntry(team) failed: %v", err)
+	}
+	handler := NewHandler(st, cfg)
+	target := "http://ldaplite.test/scim/v2/Groups/" + team.GetAttribute("entryUUID")
+
+	patchReq := asSCIMUser(scimJSONRequest(t, http.MethodPatch, target, map[string]any{
+		"schemas": []string{patchOpSchema},
+		"Operations": []map[string]any{
+			{"op": "add", "path": "members", "value": []memberResource{{Value: ana.GetAttribute("entryUUID")}}},
+			{"op": "remove", "path": `members[value eq "` + jane.GetAttribute("entryUUID") + `"]`},
+		},
+	}), lead.DN)
+	patchRR := httptest.NewRecorder()
+	handler.Groups(patchRR, patchReq)
+	if patchRR.Code != http.StatusOK {
+		t.Fatalf("patch status = %d, want %d; body=%s", patchRR.Code, http.StatusOK, patchRR.Body.String())
+	}
+	updated, err := st.GetEntry(context.Background(), team.DN)
+	if err != nil {
+		t.Fatalf("GetEntry(team) failed: %v", err)
+	}
+	if members := updated.GetAttributes("member"); len(members) != 1 || !strings.EqualFold(members[0], ana.DN) {
+		t.Fatalf("members = %v, want only %s", members, ana.DN)
+	}
+
+	renameReq := asSCIMUser(scimJSONRequest(t, http.MethodPatch, target, map[string]any{
+		"schemas":    []string{patchOpSchema},
+		"Operations": []map[string]any{{"op": "replace", "path": "displayName", "value": "renamed"}},
+	}), lead.DN)
+	renameRR := httptest.NewRecorder()
+	handler.Groups(renameRR, renameReq)
+	if renameRR.Code != http.StatusBadRequest {
+		t.Fatalf("rename patch status = %d, want %d; body=%s", renameRR.Code, http.StatusBadRequest, renameRR.Body.String())
+	}
+
+	replaceReq := asSCIMUser(scimJSONRequest(t, http.MethodPut, target, groupRequest{
+		DisplayName: "team",
+		Members:     []memberResource{{Value: lead.GetAttribute("entryUUID")}},
+	}), lead.DN)
+	replaceRR := httptest.NewRecorder()
+	handler.Groups(replaceRR, replaceReq)
+	if replaceRR.Code != http.StatusForbidden {
+		t.Fatalf("replace status = %d, want %d; body=%s", replaceRR.Code, http.StatusForbidden, replaceRR.Body.String())
+	}
+
+	outsiderReq := asSCIMUser(scimJSONRequest(t, http.MethodPatch, target, map[string]any{
+		"schemas":    []string{patchOpSchema},
+		"Operations": []map[string]any{{"op": "add", "path": "members", "value": []memberResource{{Value: jane.GetAttribute("entryUUID")}}}},
+	}), jane.DN)
+	outsiderRR := httptest.NewRecorder()
+	handler.Groups(outsiderRR, outsiderReq)
+	if outsiderRR.Code != http.StatusForbidden {
+		t.Fatalf("non-owner patch status = %d, want %d; body=%s", outsiderRR.Code, http.StatusForbidden, outsiderRR.Body.String())
+	}
+}
+
+func TestUserActiveMapsToDisabledState(t *testing.T) {
+	cfg, st := setupTestStore(t)
+	defer st.Close()
+	handler := NewHandler(st, cfg)
+	user := createSCIMTestUser(t, st, "contractor", "Contractor", "Contract", "Con", "contractor@example.com")
+	id := user.GetAttribute("entryUUID")
+
+	replace := func(payload userRequest) userResource {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		handler.Users(rr, scimJSONRequest(t, http.MethodPut, "http://ldaplite.test/scim/v2/Users/"+id, payload))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("replace status = %d, want %d; body=%s", rr.Code, http.StatusOK, rr.Body.String())
+		}
+		var resource userResource
+		if err := json.Unmarshal(rr.Body.Bytes(), &resource); err != nil {
+			t.Fatalf("failed to decode replaced user: %v", err)
+		}
+		return resource
+	}
+	inactive := false
+	payload := userRequest{
+		UserName:    "contractor",
+		DisplayName: "Contractor",
+		Name:        nameResource{FamilyName: "Contract"},
+		Active:      &inactive,
+	}
+
+	if resource := replace(payload); resource.Active {
+		t.Fatalf("user after active:false = %+v, want inactive", resource)
+	}
+	entry, err := st.GetEntry(context.Background(), user.DN)
+	if err != nil {
+		t.Fatalf("failed to load user: %v", err)
+	}
+	if !entry.AccountStatus().Disabled {
+		t.Fatal("active:false did not disable the LDAP account")
+	}
+
+	// Omitting active keeps the current state.
+	payload.Active = nil
+	if resource := replace(payload); resource.Active {
+		t.Fatalf("user after replace without active = %+v, want still inactive", resource)
+	}
+
+	active := true
+	payload.Active = &active
+	if resource := replace(payload); !resource.Active {
+		t.Fatalf("user after active:true = %+v, want active", resource)
+	}
+}
+
 func TestUserWritesRejectUnsupportedFields(t *testing.T) {
 	cfg, st := setupTestStore(t)
 	defer st.Close()
@@ -440,12 +591,6 @@ func TestUserWritesRejectUnsupportedFields(t *testing.T) {
 		target string
 		body   string
 	}{
-		{
-			name:   "active state",
-			method: http.MethodPost,
-			target: "http://ldaplite.test/scim/v2/Users",
-			body:   `{"userName":"activeuser","displayName":"Active User","name":{"familyName":"User"},"password":"Secret123!","active":true}`,
-		},
 		{
 			name:   "unknown protected-looking field",
 			method: http.MethodPost,
@@ -692,7 +837,7 @@ func TestGroupCreateReplaceAndDeleteUseDirectoryService(t *testing.T) {
 		t.Fatalf("LDAP group members = %v, want %s", got, second.DN)
 	}
 
-	deleteReq := httptest.NewRequest(http.MethodDelete, "http://ldaplite.test/scim/v2/Groups/"+created.ID, nil)
+	deleteReq := asSCIMUser(httptest.NewRequest(http.MethodDelete, "http://ldaplite.test/scim/v2/Groups/"+created.ID, nil), scimTestAdminDN)
 	deleteRR := httptest.NewRecorder()
 
 	handler.Groups(deleteRR, deleteReq)
@@ -833,6 +978,8 @@ func createSCIMTestGroup(t *testing.T, st store.Store, cn, description string, m
 	return entry
 }
 
+const scimTestAdminDN = "uid=admin,ou=users,dc=test,dc=com"
+
 func scimJSONRequest(t *testing.T, method, target string, payload any) *http.Request {
 	t.Helper()
 
@@ -842,7 +989,12 @@ func scimJSONRequest(t *testing.T, method, target string, payload any) *http.Req
 	}
 	req := httptest.NewRequest(method, target, strings.NewReader(string(body)))
 	req.Header.Set("Content-Type", ContentType)
-	return req
+	return asSCIMUser(req, scimTestAdminDN)
+}
+
+// asSCIMUser makes req come from userDN, as the auth middleware would.
+func asSCIMUser(req *http.Request, userDN string) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), middleware.UserDNKey, userDN))
 }
 
 func assertPasswordValid(t *testing.T, st store.Store, uid, password string) {
@@ -0,0 +1,29 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/smarzola/ldaplite/internal/protocol/ldapmsg"
+	"github.com/smarzola/ldaplite/internal/store"
+)
+
+// checkAccountStatus rejects binds to disabled or expired accounts. It runs
+// after the credentials are verified so the diagnostic message does not
+// reveal account state to clients that do not know the password.
+func (s *Server) checkAccountStatus(ctx context.Context, dn string) (ldapmsg.ResultCode, string) {
+	entry, err := s.store.GetEntryWithOptions(ctx, dn, store.EntryOptions{IncludeMemberOf: false})
+	if err != nil {
+		slog.Error("Failed to read account status", "dn", dn, "error", err)
+		return ldapmsg.ResultCodeOperationsError, ""
+	}
+	if entry == nil || !entry.IsUser() {
+		return ldapmsg.ResultCodeSuccess, ""
+	}
+	if err := entry.AccountStatus().Check(time.Now()); err != nil {
+		slog.Info("Bind rejected for inactive account", "dn", dn, "reason", err)
+		return ldapmsg.ResultCodeInvalidCredentials, err.Error()
+	}
+	return ldapmsg.ResultCodeSuccess, ""
+}
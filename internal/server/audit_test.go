@@ -6,8 +6,10 @@ import (
 	"io"
 	"log/slog"
 	"net"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/smarzola/ldaplite/internal/models"
 	"github.com/smarzola/ldaplite/internal/protocol"
@@ -32,7 +34,7 @@ func TestBindAuditLogIncludesStableFieldsAndRedactsPassword(t *testing.T) {
 	srv := NewServer(cfg, &auditStore{
 		passwordHash: hash,
 		passwordDN:   "uid=jane,ou=users,dc=example,dc=com",
-	}, "test")
+	}, "test", nil)
 	conn := protocol.NewConnection(serverConn, protocol.OperationHandlers{})
 	msg := &ldapmsg.Message{
 		ID: 7,
@@ -73,7 +75,7 @@ func TestSearchAuditLogIncludesBaseScopeResultCountAndActor(t *testing.T) {
 			models.NewEntry("uid=jane,ou=users,dc=example,dc=com", "inetOrgPerson"),
 			models.NewEntry("uid=john,ou=users,dc=example,dc=com", "inetOrgPerson"),
 		},
-	}, "test")
+	}, "test", nil)
 	conn := protocol.NewConnection(serverConn, protocol.OperationHandlers{})
 	conn.SetBoundDN("uid=admin,ou=users,dc=example,dc=com")
 	msg := &ldapmsg.Message{
@@ -105,7 +107,7 @@ func TestAddRejectedAuditLogIncludesActorTargetAndResultCode(t *testing.T) {
 	serverConn, clientConn, cleanup := auditTestConnection(t)
 	defer cleanup()
 
-	srv := NewServer(auditTestConfig(), &auditStore{}, "test")
+	srv := NewServer(auditTestConfig(), &auditStore{}, "test", nil)
 	conn := protocol.NewConnection(serverConn, protocol.OperationHandlers{})
 	msg := &ldapmsg.Message{
 		ID: 11,
@@ -127,6 +129,81 @@ func TestAddRejectedAuditLogIncludesActorTargetAndResultCode(t *testing.T) {
 	_ = clientConn.Close()
 }
 
+func TestTreeDeleteRequiresAdmin(t *testing.T) {
+	const editorsGroupDN = "cn=editors,ou=groups,dc=example,dc=com"
+	const targetDN = "uid=svc,ou=services,dc=example,dc=com"
+	cfg := auditTestConfig()
+	cfg.Authz.Roles = []config.Role{{Name: "editors", Capabilities: []string{"directory.write"}, Groups: []string{editorsGroupDN}}}
+	treeDelete := ldapmsg.Control{OID: protocol.TreeDeleteControlOID, Criticality: true}
+
+	tests := []struct {
+		name     string
+		boundDN  string
+		controls []ldapmsg.Control
+		want     string
+		subtree  bool
+	}{
+		{name: "editor without control", boundDN: testProxiedUserDN, want: `"result_code":0`},
+		{name: "editor with control", boundDN: testProxiedUserDN, controls: []ldapmsg.Control{treeDelete}, want: `"result_code":50`},
+		{name: "admin with control", boundDN: testAdminDN, controls: []ldapmsg.Control{treeDelete}, want: `"result_code":0`, subtree: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := captureAuditLogs(t)
+			serverConn, clientConn, cleanup := auditTestConnection(t)
+			defer cleanup()
+
+			st := proxyTestStore()
+			st.groups[testProxiedUserDN] = []string{editorsGroupDN}
+			srv := NewServer(cfg, st, "test", nil)
+			conn := protocol.NewConnection(serverConn, protocol.OperationHandlers{})
+			conn.SetBoundDN(tt.boundDN)
+			msg := &ldapmsg.Message{ID: 14, Op: ldapmsg.DeleteRequest{DN: targetDN}, Controls: tt.controls}
+
+			if err := srv.handleDelete(context.Background(), conn, msg); err != nil {
+				t.Fatalf("handleDelete() failed: %v", err)
+			}
+			assertLogContains(t, logs.String(), tt.want)
+			if deleted := len(st.deletedSubtrees) == 1 && st.deletedSubtrees[0] == targetDN; deleted != tt.subtree {
+				t.Fatalf("deleted subtrees = %v, want subtree delete %v", st.deletedSubtrees, tt.subtree)
+			}
+
+			_ = clientConn.Close()
+		})
+	}
+}
+
+func TestProxiedSearchAuditLogRecordsProxiedActorAndBindDN(t *testing.T) {
+	logs := captureAuditLogs(t)
+	serverConn, clientConn, cleanup := auditTestConnection(t)
+	defer cleanup()
+
+	srv := NewServer(proxyTestConfig(), proxyTestStore(), "test", nil)
+	conn := protocol.NewConnection(serverConn, protocol.OperationHandlers{})
+	conn.SetBoundDN(testPortalDN)
+	msg := &ldapmsg.Message{
+		ID: 13,
+		Op: ldapmsg.SearchRequest{
+			BaseObject: "dc=example,dc=com",
+			Scope:      ldapmsg.SearchScopeWholeSubtree,
+			Filter:     ldapmsg.PresentFilter{Attribute: "objectClass"},
+		},
+		Controls: []ldapmsg.Control{proxiedAuthorizationControl("dn:" + testProxiedUserDN)},
+	}
+
+	if err := srv.handleSearch(context.Background(), conn, msg); err != nil {
+		t.Fatalf("handleSearch() failed: %v", err)
+	}
+
+	got := logs.String()
+	assertLogContains(t, got, `"operation":"search"`)
+	assertLogContains(t, got, `"actor_dn":"`+testProxiedUserDN+`"`)
+	assertLogContains(t, got, `"bind_dn":"`+testPortalDN+`"`)
+	assertLogContains(t, got, `"result_code":0`)
+
+	_ = clientConn.Close()
+}
+
 func captureAuditLogs(t *testing.T) *bytes.Buffer {
 	t.Helper()
 
@@ -192,6 +269,10 @@ type auditStore struct {
 	passwordHash  string
 	passwordDN    string
 	searchEntries []*models.Entry
+	entries       map[string]*models.Entry
+	// groups maps user DNs to the group DNs they are members of.
+	groups          map[string][]string
+	deletedSubtrees []string
 }
 
 func (s *auditStore) Initialize(ctx context.Context) error { return nil }
@@ -201,15 +282,40 @@ func (s *auditStore) Close() error { return nil }
 func (s *auditStore) GetEntry(ctx context.Context, dn string) (*models.Entry, error) { return nil, nil }
 
 func (s *auditStore) GetEntryWithOptions(ctx context.Context, dn string, options store.EntryOptions) (*models.Entry, error) {
-	return nil, nil
+	return s.entries[dn], nil
 }
 
 func (s *auditStore) CreateEntry(ctx context.Context, entry *models.Entry) error { return nil }
 
+func (s *auditStore) CreateEntryWithOptions(ctx context.Context, entry *models.Entry, options store.WriteOptions) (store.WriteResult, error) {
+	return store.WriteResult{}, nil
+}
+
 func (s *auditStore) UpdateEntry(ctx context.Context, entry *models.Entry) error { return nil }
 
+func (s *auditStore) ModifyEntry(ctx context.Context, dn string, options store.WriteOptions, modify func(entry *models.Entry) error) (store.WriteResult, error) {
+	return store.WriteResult{}, nil
+}
+
 func (s *auditStore) DeleteEntry(ctx context.Context, dn string) error { return nil }
 
+func (s *auditStore) RenameEntry(ctx context.Context, dn string, options store.RenameOptions) (string, error) {
+	return "", nil
+}
+
+func (s *auditStore) DeleteEntryWithOptions(ctx context.Context, dn string, options store.WriteOptions) (store.WriteResult, error) {
+	return store.WriteResult{}, nil
+}
+
+func (s *auditStore) DeleteSubtree(ctx context.Context, dn string, options store.SubtreeDeleteOptions) (store.SubtreeDeleteResult, error) {
+	s.deletedSubtrees = append(s.deletedSubtrees, dn)
+	return store.SubtreeDeleteResult{DNs: []string{dn}}, nil
+}
+
+func (s *auditStore) RenameEntryWithOptions(ctx context.Context, dn string, rename store.RenameOptions, options store.WriteOptions) (string, store.WriteResult, error) {
+	return "", store.WriteResult{}, nil
+}
+
 func (s *auditStore) SearchEntries(ctx context.Context, baseDN string, filter string) ([]*models.Entry, error) {
 	return s.searchEntries, nil
 }
@@ -218,7 +324,13 @@ func (s *auditStore) SearchEntriesWithOptions(ctx context.Context, options store
 	return s.searchEntries, nil
 }
 
-func (s *auditStore) EntryExists(ctx context.Context, dn string) (bool, error) { return false, nil }
+func (s *auditStore) CountEntriesWithOptions(ctx context.Context, options store.SearchOptions) (int, error) {
+	return len(s.searchEntries), nil
+}
+
+func (s *auditStore) EntryExists(ctx context.Context, dn string) (bool, error) {
+	return s.entries[dn] != nil, nil
+}
 
 func (s *auditStore) GetUserPasswordHash(ctx context.Context, uid string) (string, string, error) {
 	return s.passwordHash, s.passwordDN, nil
@@ -228,6 +340,42 @@ func (s *auditStore) GetUserPasswordHashByDN(ctx context.Context, dn string) (st
 	return s.passwordHash, s.passwordDN, nil
 }
 
-func (s *auditStore) IsUserInGroup(ctx context.Context, userDN, groupDN string) (bool, error) {
+func (s *auditStore) GetUserSCRAMSecret(ctx context.Context, uid string) (string, string, error) {
+	return "", "", nil
+}
+
+func (s *auditStore) RehashUserPassword(ctx context.Context, dn, oldHash, newHash, scramSecret string) error {
+	return nil
+}
+
+func (s *auditStore) GetPasswordPolicyState(ctx context.Context, dn string) (*store.PasswordPolicyState, error) {
+	return nil, nil
+}
+
+func (s *auditStore) RecordPasswordFailure(ctx context.Context, dn string, failureTime, expireBefore time.Time, maxFailures int) (bool, error) {
 	return false, nil
 }
+
+func (s *auditStore) ResetPasswordFailures(ctx context.Context, dn string) error {
+	return nil
+}
+
+func (s *auditStore) RecordGraceLogin(ctx context.Context, dn string) error {
+	return nil
+}
+
+func (s *auditStore) GetPasswordHistory(ctx context.Context, dn string) ([]string, error) {
+	return nil, nil
+}
+
+func (s *auditStore) SetAccountStatus(ctx context.Context, dn string, status models.AccountStatus) error {
+	return nil
+}
+
+func (s *auditStore) UpdateEntryWithAccountStatus(ctx context.Context, entry *models.Entry, status models.AccountStatus) error {
+	return nil
+}
+
+func (s *auditStore) IsUserInGroup(ctx context.Context, userDN, groupDN string) (bool, error) {
+	return slices.Contains(s.groups[userDN], groupDN), nil
+}
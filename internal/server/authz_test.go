@@ -4,9 +4,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
 	"testing"
+	"time"
 
+	"github.com/smarzola/ldaplite/internal/authz"
+	"github.com/smarzola/ldaplite/internal/ldapdn"
 	"github.com/smarzola/ldaplite/internal/models"
+	"github.com/smarzola/ldaplite/internal/ppolicy"
 	"github.com/smarzola/ldaplite/internal/protocol"
 	"github.com/smarzola/ldaplite/internal/protocol/ldapmsg"
 	"github.com/smarzola/ldaplite/internal/store"
@@ -145,12 +150,12 @@ func TestCanWriteAccessPolicy(t *testing.T) {
 				conn.SetBoundDN(*tt.bindDN)
 			}
 
-			got, err := srv.canWrite(context.Background(), conn)
+			got, err := srv.canWriteEntry(context.Background(), conn, "uid=bob,ou=users,dc=example,dc=com", authz.PermissionDelete)
 			if (err != nil) != tt.wantErr {
-				t.Fatalf("canWrite() error = %v, wantErr %v", err, tt.wantErr)
+				t.Fatalf("canWriteEntry() error = %v, wantErr %v", err, tt.wantErr)
 			}
 			if got != tt.want {
-				t.Fatalf("canWrite() = %v, want %v", got, tt.want)
+				t.Fatalf("canWriteEntry() = %v, want %v", got, tt.want)
 			}
 			if authzStore.checks != tt.wantChecks {
 				t.Fatalf("membership checks = %d, want %d", authzStore.checks, tt.wantChecks)
@@ -259,6 +264,110 @@ func TestCanModifyAccessPolicy(t *testing.T) {
 	}
 }
 
+func TestGroupOwnerMayOnlyModifyMembers(t *testing.T) {
+	const groupDN = "cn=team,ou=groups,dc=example,dc=com"
+	group := models.NewEntry(groupDN, string(models.ObjectClassGroupOfNames))
+	group.SetAttribute("member", "uid=bob,ou=users,dc=example,dc=com")
+	group.SetAttribute("owner", "uid=jane,ou=users,dc=example,dc=com")
+
+	srv := testAuthzServer(false)
+	srv.store = &authzStore{entries: map[string]*models.Entry{groupDN: group}}
+	conn := protocol.NewConnection(nil, protocol.OperationHandlers{})
+	conn.SetBoundDN("uid=jane,ou=users,dc=example,dc=com")
+	ctx := context.Background()
+
+	members := append(
+		addChange("member", "uid=ana,ou=users,dc=example,dc=com"),
+		ldapmsg.ModifyChange{
+			Operation:    ldapmsg.ModifyOperationDelete,
+			Modification: ldapmsg.Attribute{Name: "member", Values: []string{"uid=bob,ou=users,dc=example,dc=com"}},
+		},
+	)
+	canModify, err := srv.canModify(ctx, conn, groupDN, members)
+	if err != nil || !canModify {
+		t.Fatalf("canModify(members) = %v, %v; want true", canModify, err)
+	}
+	canModify, err = srv.canModify(ctx, conn, groupDN, append(addChange("member", "uid=ana,ou=users,dc=example,dc=com"), replaceChange("description", "Team")...))
+	if err != nil || canModify {
+		t.Fatalf("canModify(members and description) = %v, %v; want false", canModify, err)
+	}
+	canModify, err = srv.canModify(ctx, conn, groupDN, replaceChange("owner", "uid=ana,ou=users,dc=example,dc=com"))
+	if err != nil || canModify {
+		t.Fatalf("canModify(owner) = %v, %v; want false", canModify, err)
+	}
+
+	conn.SetBoundDN("uid=bob,ou=users,dc=example,dc=com")
+	canModify, err = srv.canModify(ctx, conn, groupDN, addChange("member", "uid=ana,ou=users,dc=example,dc=com"))
+	if err != nil || canModify {
+		t.Fatalf("canModify(members by non-owner) = %v, %v; want false", canModify, err)
+	}
+}
+
+func TestPreHashedPasswordsNeedPasswordReset(t *testing.T) {
+	const janeDN = "uid=jane,ou=users,dc=example,dc=com"
+	hashed := replaceChange("userPassword", "{ARGON2ID}$argon2id$v=19$m=65536,t=3,p=2$c2FsdA$aGFzaA")
+	ctx := context.Background()
+
+	srv := testAuthzServer(false)
+	srv.store = &authzStore{}
+	conn := protocol.NewConnection(nil, protocol.OperationHandlers{})
+	conn.SetBoundDN(janeDN)
+	if err := srv.checkModifyPasswords(ctx, conn, janeDN, hashed); !errors.Is(err, ppolicy.ErrPreHashedPassword) {
+		t.Fatalf("checkModifyPasswords(self) error = %v, want %v", err, ppolicy.ErrPreHashedPassword)
+	}
+	if err := srv.checkPreHashedPasswords(ctx, conn, janeDN, []string{"{ARGON2ID}x"}); passwordPolicyError(err) == nil {
+		t.Fatalf("checkPreHashedPasswords(self) error = %v, want a password policy violation", err)
+	}
+
+	srv.store = &authzStore{admin: true}
+	conn.SetBoundDN("uid=admin,ou=users,dc=example,dc=com")
+	if err := srv.checkModifyPasswords(ctx, conn, janeDN, hashed); err != nil {
+		t.Fatalf("checkModifyPasswords(admin) error = %v", err)
+	}
+}
+
+func TestAccessRulesApplyToLDAPOperations(t *testing.T) {
+	srv := testAuthzServer(false)
+	srv.cfg.Authz.AccessRules = []config.AccessRule{
+		{Name: "own-mobile", Subject: "self", Attributes: []string{"mobile"}, Permissions: []string{"read", "write"}},
+		{Name: "private-mobile", Effect: "deny", Subject: "users", Attributes: []string{"mobile"}, Permissions: []string{"read", "compare"}},
+		{Name: "contractors", Subject: "users", Subtree: "ou=contractors,dc=example,dc=com", Permissions: []string{"add", "delete"}},
+	}
+	conn := protocol.NewConnection(nil, protocol.OperationHandlers{})
+	conn.SetBoundDN("uid=jane,ou=users,dc=example,dc=com")
+	ctx := context.Background()
+
+	canModify, err := srv.canModify(ctx, conn, "uid=jane,ou=users,dc=example,dc=com", replaceChange("mobile", "555-0100"))
+	if err != nil || !canModify {
+		t.Fatalf("canModify(own mobile) = %v, %v; want true", canModify, err)
+	}
+	canModify, err = srv.canModify(ctx, conn, "uid=bob,ou=users,dc=example,dc=com", replaceChange("mobile", "555-0100"))
+	if err != nil || canModify {
+		t.Fatalf("canModify(other mobile) = %v, %v; want false", canModify, err)
+	}
+	canDelete, err := srv.canWriteEntry(ctx, conn, "uid=temp,ou=contractors,dc=example,dc=com", authz.PermissionDelete)
+	if err != nil || !canDelete {
+		t.Fatalf("canWriteEntry(contractor) = %v, %v; want true", canDelete, err)
+	}
+
+	bob := models.NewEntry("uid=bob,ou=users,dc=example,dc=com", string(models.ObjectClassInetOrgPerson))
+	bob.SetAttribute("cn", "Bob")
+	bob.SetAttribute("mobile", "555-0101")
+	attrs, err := readableSearchAttributes(ctx, srv.authorizer(), connActor(conn), bob,
+		searchResponseAttributes(bob, newSearchAttributeSelection([]string{"cn", "mobile"})))
+	if err != nil {
+		t.Fatalf("readableSearchAttributes() error = %v", err)
+	}
+	for _, attr := range attrs {
+		if attr.name == "mobile" {
+			t.Fatalf("readableSearchAttributes() returned mobile for another user")
+		}
+	}
+	if len(attrs) != 1 {
+		t.Fatalf("readableSearchAttributes() = %v, want only cn", attrs)
+	}
+}
+
 func TestPublicSearchBase(t *testing.T) {
 	tests := []struct {
 		baseDN string
@@ -325,6 +434,16 @@ func TestEntryWriteResultCodeUsesTypedStoreErrors(t *testing.T) {
 			err:  fmt.Errorf("wrapped: %w", store.ErrConstraintViolation),
 			want: ldapmsg.ResultCodeConstraintViolation,
 		},
+		{
+			name: "unwilling to perform",
+			err:  fmt.Errorf("wrapped: %w", store.ErrUnwillingToPerform),
+			want: ldapmsg.ResultCodeUnwillingToPerform,
+		},
+		{
+			name: "assertion failed",
+			err:  fmt.Errorf("wrapped: %w", store.ErrAssertionFailed),
+			want: ldapmsg.ResultCodeAssertionFailed,
+		},
 		{
 			name: "unknown error",
 			err:  fmt.Errorf("unknown"),
@@ -367,9 +486,13 @@ func strPtr(s string) *string {
 }
 
 type authzStore struct {
-	admin  bool
-	err    error
-	checks int
+	admin   bool
+	err     error
+	checks  int
+	entries map[string]*models.Entry
+	// results are returned by searches, which are counted in searches.
+	results  []*models.Entry
+	searches int
 }
 
 func (s *authzStore) Initialize(ctx context.Context) error { return nil }
@@ -379,21 +502,65 @@ func (s *authzStore) Close() error { return nil }
 func (s *authzStore) GetEntry(ctx context.Context, dn string) (*models.Entry, error) { return nil, nil }
 
 func (s *authzStore) GetEntryWithOptions(ctx context.Context, dn string, options store.EntryOptions) (*models.Entry, error) {
-	return nil, nil
+	return s.entries[dn], nil
 }
 
 func (s *authzStore) CreateEntry(ctx context.Context, entry *models.Entry) error { return nil }
 
+func (s *authzStore) CreateEntryWithOptions(ctx context.Context, entry *models.Entry, options store.WriteOptions) (store.WriteResult, error) {
+	return store.WriteResult{}, nil
+}
+
 func (s *authzStore) UpdateEntry(ctx context.Context, entry *models.Entry) error { return nil }
 
+func (s *authzStore) ModifyEntry(ctx context.Context, dn string, options store.WriteOptions, modify func(entry *models.Entry) error) (store.WriteResult, error) {
+	return store.WriteResult{}, nil
+}
+
 func (s *authzStore) DeleteEntry(ctx context.Context, dn string) error { return nil }
 
+func (s *authzStore) RenameEntry(ctx context.Context, dn string, options store.RenameOptions) (string, error) {
+	return "", nil
+}
+
+func (s *authzStore) DeleteEntryWithOptions(ctx context.Context, dn string, options store.WriteOptions) (store.WriteResult, error) {
+	return store.WriteResult{}, nil
+}
+
+func (s *authzStore) DeleteSubtree(ctx context.Context, dn string, options store.SubtreeDeleteOptions) (store.SubtreeDeleteResult, error) {
+	return store.SubtreeDeleteResult{}, nil
+}
+
+func (s *authzStore) RenameEntryWithOptions(ctx context.Context, dn string, rename store.RenameOptions, options store.WriteOptions) (string, store.WriteResult, error) {
+	return "", store.WriteResult{}, nil
+}
+
 func (s *authzStore) SearchEntries(ctx context.Context, baseDN string, filter string) ([]*models.Entry, error) {
 	return nil, nil
 }
 
+// SearchEntriesWithOptions returns the results after AfterEntryID and outside
+// ExcludeSubtrees, windowed by Offset and Limit. Other options are ignored.
 func (s *authzStore) SearchEntriesWithOptions(ctx context.Context, options store.SearchOptions) ([]*models.Entry, error) {
-	return nil, nil
+	s.searches++
+	var matched []*models.Entry
+	for _, entry := range s.results {
+		if entry.ID > options.AfterEntryID &&
+			!slices.ContainsFunc(options.ExcludeSubtrees, func(dn string) bool { return ldapdn.WithinBase(entry.DN, dn) }) {
+			matched = append(matched, entry)
+		}
+	}
+	matched = matched[min(options.Offset, len(matched)):]
+	if options.Limit > 0 && len(matched) > options.Limit {
+		matched = matched[:options.Limit]
+	}
+	return matched, nil
+}
+
+func (s *authzStore) CountEntriesWithOptions(ctx context.Context, options store.SearchOptions) (int, error) {
+	options.Offset, options.Limit = 0, 0
+	entries, err := s.SearchEntriesWithOptions(ctx, options)
+	return len(entries), err
 }
 
 func (s *authzStore) EntryExists(ctx context.Context, dn string) (bool, error) { return false, nil }
@@ -406,6 +573,42 @@ func (s *authzStore) GetUserPasswordHashByDN(ctx context.Context, dn string) (st
 	return "", "", nil
 }
 
+func (s *authzStore) GetUserSCRAMSecret(ctx context.Context, uid string) (string, string, error) {
+	return "", "", nil
+}
+
+func (s *authzStore) RehashUserPassword(ctx context.Context, dn, oldHash, newHash, scramSecret string) error {
+	return nil
+}
+
+func (s *authzStore) GetPasswordPolicyState(ctx context.Context, dn string) (*store.PasswordPolicyState, error) {
+	return nil, nil
+}
+
+func (s *authzStore) RecordPasswordFailure(ctx context.Context, dn string, failureTime, expireBefore time.Time, maxFailures int) (bool, error) {
+	return false, nil
+}
+
+func (s *authzStore) ResetPasswordFailures(ctx context.Context, dn string) error {
+	return nil
+}
+
+func (s *authzStore) RecordGraceLogin(ctx context.Context, dn string) error {
+	return nil
+}
+
+func (s *authzStore) GetPasswordHistory(ctx context.Context, dn string) ([]string, error) {
+	return nil, nil
+}
+
+func (s *authzStore) SetAccountStatus(ctx context.Context, dn string, status models.AccountStatus) error {
+	return nil
+}
+
+func (s *authzStore) UpdateEntryWithAccountStatus(ctx context.Context, entry *models.Entry, status models.AccountStatus) error {
+	return nil
+}
+
 func (s *authzStore) IsUserInGroup(ctx context.Context, userDN, groupDN string) (bool, error) {
 	s.checks++
 	if s.err != nil {
@@ -416,6 +619,9 @@ func (s *authzStore) IsUserInGroup(ctx context.Context, userDN, groupDN string)
 		return s.admin, nil
 	case "cn=ldaplite.password,ou=groups,dc=example,dc=com":
 		return false, nil
+	case "uid=jane,ou=users,dc=example,dc=com":
+		// Owners are checked as groups too; users have no members.
+		return false, nil
 	default:
 		return false, fmt.Errorf("unexpected groupDN = %q", groupDN)
 	}
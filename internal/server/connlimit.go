@@ -0,0 +1,60 @@
+package server
+
+import (
+	"net"
+	"sync"
+)
+
+// connectionCounts tracks open LDAP connections in total and per client IP
+// address so acceptLoop can enforce the connection caps. The zero value is
+// ready to use.
+type connectionCounts struct {
+	mu    sync.Mutex
+	total int
+	perIP map[string]int
+}
+
+// acquire reserves a slot for a connection from ip. A zero cap means no
+// limit. On failure it returns the metric reason for the cap that was hit.
+func (c *connectionCounts) acquire(ip string, maxTotal, maxPerIP int) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if maxTotal > 0 && c.total >= maxTotal {
+		return "max_connections", false
+	}
+	if maxPerIP > 0 && c.perIP[ip] >= maxPerIP {
+		return "max_connections_per_ip", false
+	}
+	if c.perIP == nil {
+		c.perIP = make(map[string]int)
+	}
+	c.total++
+	c.perIP[ip]++
+	return "", true
+}
+
+// release frees the slot taken by acquire.
+func (c *connectionCounts) release(ip string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.total--
+	if c.perIP[ip] <= 1 {
+		delete(c.perIP, ip)
+		return
+	}
+	c.perIP[ip]--
+}
+
+// remoteIP returns the IP address part of a connection's remote address.
+func remoteIP(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"errors"
+
+	"github.com/smarzola/ldaplite/internal/protocol"
+	"github.com/smarzola/ldaplite/internal/protocol/ldapmsg"
+	"github.com/smarzola/ldaplite/internal/store"
+)
+
+// supportsControl reports which request controls the server implements for
+// each operation.
+func supportsControl(op ldapmsg.Operation, oid string) bool {
+	switch oid {
+	case protocol.PagedResultsControlOID, protocol.SortRequestControlOID, protocol.VirtualListViewRequestControlOID,
+		protocol.GetEffectiveRightsControlOID:
+		_, ok := op.(ldapmsg.SearchRequest)
+		return ok
+	case protocol.ProxiedAuthorizationControlOID:
+		switch op.(type) {
+		case ldapmsg.SearchRequest, ldapmsg.CompareRequest, ldapmsg.AddRequest, ldapmsg.ModifyRequest,
+			ldapmsg.DeleteRequest, ldapmsg.ModifyDNRequest:
+			return true
+		}
+		return false
+	case protocol.AssertionControlOID:
+		switch op.(type) {
+		case ldapmsg.AddRequest, ldapmsg.ModifyRequest, ldapmsg.DeleteRequest, ldapmsg.ModifyDNRequest:
+			return true
+		}
+		return false
+	case protocol.PreReadControlOID:
+		// An added entry has no state before the add.
+		switch op.(type) {
+		case ldapmsg.ModifyRequest, ldapmsg.DeleteRequest, ldapmsg.ModifyDNRequest:
+			return true
+		}
+		return false
+	case protocol.PostReadControlOID:
+		// A deleted entry has no state after the delete.
+		switch op.(type) {
+		case ldapmsg.AddRequest, ldapmsg.ModifyRequest, ldapmsg.ModifyDNRequest:
+			return true
+		}
+		return false
+	case protocol.TreeDeleteControlOID:
+		_, ok := op.(ldapmsg.DeleteRequest)
+		return ok
+	case protocol.PasswordPolicyControlOID:
+		switch op.(type) {
+		case ldapmsg.BindRequest, ldapmsg.ModifyRequest, ldapmsg.AddRequest, ldapmsg.ExtendedRequest:
+			return true
+		}
+		return false
+	default:
+		return false
+	}
+}
+
+// supportedControls lists the request controls advertised in the RootDSE.
+var supportedControls = []string{
+	protocol.PagedResultsControlOID,
+	protocol.SortRequestControlOID,
+	protocol.VirtualListViewRequestControlOID,
+	protocol.PasswordPolicyControlOID,
+	protocol.GetEffectiveRightsControlOID,
+	protocol.ProxiedAuthorizationControlOID,
+	protocol.AssertionControlOID,
+	protocol.PreReadControlOID,
+	protocol.PostReadControlOID,
+	protocol.TreeDeleteControlOID,
+}
+
+// sortRequest decodes the server-side sort control of a search request into
+// store sort keys.
+func sortRequest(msg *ldapmsg.Message) ([]store.SortKey, ldapmsg.Control, bool, error) {
+	control, ok := msg.Control(protocol.SortRequestControlOID)
+	if !ok {
+		return nil, ldapmsg.Control{}, false, nil
+	}
+	keys, err := protocol.DecodeSortRequestControl(control)
+	if err != nil {
+		return nil, control, true, err
+	}
+	sortKeys := make([]store.SortKey, 0, len(keys))
+	for _, key := range keys {
+		sortKeys = append(sortKeys, store.SortKey{
+			Attribute:    key.AttributeType,
+			OrderingRule: key.OrderingRule,
+			Reverse:      key.ReverseOrder,
+		})
+	}
+	return sortKeys, control, true, nil
+}
+
+// sortKeyResult maps a rejected sort key to the sortResult code of the sort
+// response control.
+func sortKeyResult(err *store.SortKeyError) ldapmsg.ResultCode {
+	if errors.Is(err, store.ErrInappropriateMatching) {
+		return ldapmsg.ResultCodeInappropriateMatching
+	}
+	return ldapmsg.ResultCodeUnwillingToPerform
+}
@@ -17,11 +17,14 @@ func (s *Server) handleRootDSE(conn *protocol.Connection, msg *ldapmsg.Message)
 	protocol.AddAttribute(&entry, "namingContexts", s.cfg.LDAP.BaseDN)
 	protocol.AddAttribute(&entry, "subschemaSubentry", "cn=Subschema")
 	protocol.AddAttribute(&entry, "supportedLDAPVersion", "3")
-	supportedExtensions := []string{protocol.WhoAmIOID}
+	supportedExtensions := []string{protocol.WhoAmIOID, protocol.PasswordModifyOID}
 	if s.cfg.Server.TLS.StartTLSEnabled {
 		supportedExtensions = append(supportedExtensions, protocol.StartTLSOID)
 	}
 	protocol.AddAttribute(&entry, "supportedExtension", supportedExtensions...)
+	protocol.AddAttribute(&entry, "supportedControl", supportedControls...)
+	protocol.AddAttribute(&entry, "supportedFeatures", protocol.ModifyIncrementFeatureOID)
+	protocol.AddAttribute(&entry, "supportedSASLMechanisms", s.supportedSASLMechanisms()...)
 	protocol.AddAttribute(&entry, "vendorName", "LDAPLite")
 	protocol.AddAttribute(&entry, "vendorVersion", s.version)
 
@@ -68,6 +71,7 @@ func (s *Server) handleExtended(ctx context.Context, conn *protocol.Connection,
 	start := time.Now()
 	extReq := msg.Op.(ldapmsg.ExtendedRequest)
 	reqOID := extReq.RequestName
+	targetDN := ""
 	resultCode := ldapmsg.ResultCodeOperationsError
 	ctx, span := telemetry.StartLDAPSpan(ctx, "extended")
 	defer func() {
@@ -76,6 +80,7 @@ func (s *Server) handleExtended(ctx context.Context, conn *protocol.Connection,
 	defer func() {
 		s.auditLDAPOperation(ctx, conn, msg, "extended", audit.LDAPEvent{
 			ActorDN:    conn.GetBoundDN(),
+			TargetDN:   targetDN,
 			OID:        reqOID,
 			ResultCode: int(resultCode),
 			Duration:   time.Since(start),
@@ -130,6 +135,14 @@ func (s *Server) handleExtended(ctx context.Context, conn *protocol.Connection,
 		return conn.StartTLS(s.tlsConfig)
 	}
 
+	if reqOID == protocol.PasswordModifyOID {
+		var resp ldapmsg.ExtendedResponse
+		var policyControl *ldapmsg.Control
+		resp, targetDN, policyControl = s.passwordModify(ctx, conn, extReq)
+		resultCode = resp.ResultCode
+		return conn.WriteResponse(msg.ID, resp, passwordPolicyControls(msg, policyControl)...)
+	}
+
 	// Unsupported extended operation
 	slog.Debug("Unsupported extended operation", "oid", reqOID)
 	resultCode = ldapmsg.ResultCodeUnavailable
@@ -1,12 +1,16 @@
 package server
 
 import (
+	"cmp"
 	"context"
+	"crypto/rand"
 	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"log/slog"
 	"net"
+	"os"
 	"strings"
 	"sync"
 	"time"
@@ -14,10 +18,12 @@ import (
 	"github.com/smarzola/ldaplite/internal/audit"
 	"github.com/smarzola/ldaplite/internal/authz"
 	"github.com/smarzola/ldaplite/internal/models"
+	"github.com/smarzola/ldaplite/internal/ppolicy"
 	"github.com/smarzola/ldaplite/internal/protocol"
 	"github.com/smarzola/ldaplite/internal/protocol/ldapmsg"
 	"github.com/smarzola/ldaplite/internal/store"
 	"github.com/smarzola/ldaplite/internal/telemetry"
+	"github.com/smarzola/ldaplite/internal/throttle"
 	"github.com/smarzola/ldaplite/pkg/config"
 	"github.com/smarzola/ldaplite/pkg/crypto"
 )
@@ -27,24 +33,39 @@ type Server struct {
 	cfg       *config.Config
 	store     store.Store
 	hasher    *crypto.PasswordHasher
+	policy    *ppolicy.Policy
+	throttler *throttle.Throttler
 	version   string
 	listener  net.Listener
 	tlsConfig *tls.Config
+	paging    pagedSearches
+	conns     connectionCounts
 	wg        sync.WaitGroup
 	ctx       context.Context
 	cancel    context.CancelFunc
+
+	// scramSaltKey keys the made-up SCRAM salts of unknown users. It is
+	// generated at startup and never leaves the process.
+	scramSaltKey []byte
 }
 
-// NewServer creates a new LDAP server
-func NewServer(cfg *config.Config, st store.Store, version string) *Server {
+// NewServer creates a new LDAP server. A nil throttler disables bind
+// throttling.
+func NewServer(cfg *config.Config, st store.Store, version string, throttler *throttle.Throttler) *Server {
 	ctx, cancel := context.WithCancel(context.Background())
+	hasher := crypto.NewPasswordHasher(cfg.Security.Argon2Config).WithLegacySchemes(cfg.Security.AllowLegacyPasswordHashes)
+	scramSaltKey := make([]byte, 32)
+	rand.Read(scramSaltKey)
 	return &Server{
-		cfg:     cfg,
-		store:   st,
-		hasher:  crypto.NewPasswordHasher(cfg.Security.Argon2Config),
-		version: version,
-		ctx:     ctx,
-		cancel:  cancel,
+		cfg:          cfg,
+		store:        st,
+		hasher:       hasher,
+		policy:       ppolicy.New(cfg.Security.PasswordPolicy, st, hasher),
+		throttler:    throttler,
+		version:      version,
+		scramSaltKey: scramSaltKey,
+		ctx:          ctx,
+		cancel:       cancel,
 	}
 }
 
@@ -52,21 +73,31 @@ func NewServer(cfg *config.Config, st store.Store, version string) *Server {
 // during Add. objectClass is structural and required during Add, so it is only
 // protected from later Modify operations.
 var addProtectedAttributes = []string{
+	"accountdisabled",
+	"accountexpirationtime",
 	"createtimestamp",
 	"entryuuid",
 	"memberof",
 	"modifytimestamp",
+	"pwdaccountlockedtime",
+	"pwdchangedtime",
+	"pwdfailuretime",
 	"uuid",
 }
 
 // modifyProtectedAttributes lists LDAP operational/structural attributes that
 // cannot be changed after entry creation.
 var modifyProtectedAttributes = []string{
+	"accountdisabled",
+	"accountexpirationtime",
 	"createtimestamp",
 	"entryuuid",
 	"memberof",
 	"modifytimestamp",
 	"objectclass",
+	"pwdaccountlockedtime",
+	"pwdchangedtime",
+	"pwdfailuretime",
 	"uuid",
 }
 
@@ -132,6 +163,14 @@ func (s *Server) acceptLoop() {
 			}
 		}
 
+		ip := remoteIP(conn.RemoteAddr())
+		if reason, ok := s.conns.acquire(ip, s.cfg.Server.MaxConnections, s.cfg.Server.MaxConnectionsPerIP); !ok {
+			slog.Warn("Rejecting LDAP connection", "remote", conn.RemoteAddr(), "reason", reason)
+			telemetry.RecordLDAPConnectionRejected(s.ctx, reason)
+			_ = conn.Close()
+			continue
+		}
+
 		slog.Debug("New connection", "remote", conn.RemoteAddr())
 		telemetry.RecordLDAPConnectionAccepted(s.ctx)
 
@@ -139,6 +178,7 @@ func (s *Server) acceptLoop() {
 		s.wg.Add(1)
 		go func() {
 			defer s.wg.Done()
+			defer s.conns.release(ip)
 			s.handleConnection(conn)
 		}()
 	}
@@ -166,16 +206,26 @@ func (s *Server) handleConnection(conn net.Conn) {
 		OnAdd:      s.handleAdd,
 		OnModify:   s.handleModify,
 		OnDelete:   s.handleDelete,
+		OnModifyDN: s.handleModifyDN,
 		OnCompare:  s.handleCompare,
 		OnExtended: s.handleExtended,
 		OnUnbind:   s.handleUnbind,
+
+		SupportsControl: supportsControl,
 	}
 
 	// Create connection wrapper
 	ldapConn := protocol.NewConnection(conn, handlers)
+	ldapConn.SetLimits(protocol.ConnectionLimits{
+		ReadTimeout:    time.Duration(s.cfg.Server.ReadTimeout) * time.Second,
+		WriteTimeout:   time.Duration(s.cfg.Server.WriteTimeout) * time.Second,
+		IdleTimeout:    time.Duration(s.cfg.Server.IdleTimeout) * time.Second,
+		MaxMessageSize: s.cfg.Server.MaxMessageSize,
+	})
 	if s.cfg.Server.TLS.Enabled {
 		ldapConn.MarkTLS()
 	}
+	defer s.paging.release(ldapConn.ID())
 
 	// Handle the connection
 	if err := ldapConn.Handle(s.ctx); err != nil {
@@ -195,10 +245,30 @@ func (s *Server) loadTLSConfig() (*tls.Config, error) {
 		return nil, fmt.Errorf("load LDAP TLS certificate: %w", err)
 	}
 
-	return &tls.Config{
+	tlsConfig := &tls.Config{
 		Certificates: []tls.Certificate{cert},
 		MinVersion:   tls.VersionTLS12,
-	}, nil
+	}
+
+	switch s.cfg.Server.TLS.ClientAuth {
+	case config.TLSClientAuthVerify:
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	case config.TLSClientAuthRequire:
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	if s.cfg.Server.TLS.ClientCAFile != "" {
+		pemData, err := os.ReadFile(s.cfg.Server.TLS.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("load LDAP TLS client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pemData) {
+			return nil, fmt.Errorf("load LDAP TLS client CA: no certificates found in %s", s.cfg.Server.TLS.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+	}
+
+	return tlsConfig, nil
 }
 
 // Stop stops the LDAP server
@@ -234,8 +304,35 @@ func (s *Server) handleBind(ctx context.Context, conn *protocol.Connection, msg
 	}()
 
 	conn.ClearBoundDN()
+	// Any bind request ends a SASL exchange in progress unless it continues it.
+	pendingSASL := conn.TakeSASLState()
 
 	bindReq := msg.Op.(ldapmsg.BindRequest)
+	if bindReq.SASL != nil {
+		// The account is only known once the exchange completes, so it is
+		// checked afterwards.
+		attempt := bindAttempt(conn, msg, "")
+		if resp := throttledBind(s.throttler.Wait(ctx, attempt)); resp != nil {
+			resultCode = resp.ResultCode
+			return conn.WriteResponse(msg.ID, *resp)
+		}
+		resp, dn, policyControl := s.saslBind(ctx, conn, bindReq, pendingSASL)
+		targetDN = dn
+		attempt.Account = dn
+		defer func() { s.recordBindResult(ctx, attempt, resultCode) }()
+		if resp.ResultCode == ldapmsg.ResultCodeSuccess {
+			if throttled := throttledBind(s.throttler.Check(ctx, attempt)); throttled != nil {
+				resp, policyControl = *throttled, nil
+			} else if code, diagnostic := s.checkAccountStatus(ctx, dn); code != ldapmsg.ResultCodeSuccess {
+				resp = bindError(code, diagnostic)
+			}
+		}
+		resultCode = resp.ResultCode
+		if resultCode == ldapmsg.ResultCodeSuccess {
+			conn.SetBoundDN(dn)
+		}
+		return conn.WriteResponse(msg.ID, resp, passwordPolicyControls(msg, policyControl)...)
+	}
 	bindDN := bindReq.Name
 	password := bindReq.Password
 	targetDN = bindDN
@@ -257,6 +354,12 @@ func (s *Server) handleBind(ctx context.Context, conn *protocol.Connection, msg
 
 	// Look up user by bind DN to get password hash and canonical DN from database
 	passwordHash, dn, err := s.store.GetUserPasswordHashByDN(ctx, bindDN)
+	attempt := bindAttempt(conn, msg, cmp.Or(dn, bindDN))
+	if resp := throttledBind(s.throttler.Wait(ctx, attempt)); resp != nil {
+		resultCode = resp.ResultCode
+		return conn.WriteResponse(msg.ID, *resp)
+	}
+	defer func() { s.recordBindResult(ctx, attempt, resultCode) }()
 	if err != nil {
 		slog.Debug("Error retrieving user", "dn", bindDN, "error", err)
 		resultCode = ldapmsg.ResultCodeInvalidCredentials
@@ -269,22 +372,25 @@ func (s *Server) handleBind(ctx context.Context, conn *protocol.Connection, msg
 		return conn.WriteResponse(msg.ID, protocol.NewBindResponse(ldapmsg.ResultCodeInvalidCredentials))
 	}
 
-	// Verify password
-	valid, err := s.hasher.Verify(password, passwordHash)
-	if err != nil || !valid {
+	// Verify password under the password policy (lockout and expiry)
+	targetDN = dn
+	resultCode, policyControl := s.authenticatePassword(ctx, dn, s.verifyPassword(password, passwordHash))
+	policyControls := passwordPolicyControls(msg, policyControl)
+	if resultCode != ldapmsg.ResultCodeSuccess {
 		slog.Debug("Password verification failed", "dn", dn)
-		targetDN = dn
-		resultCode = ldapmsg.ResultCodeInvalidCredentials
-		return conn.WriteResponse(msg.ID, protocol.NewBindResponse(ldapmsg.ResultCodeInvalidCredentials))
+		return conn.WriteResponse(msg.ID, protocol.NewBindResponse(resultCode), policyControls...)
+	}
+	var diagnostic string
+	if resultCode, diagnostic = s.checkAccountStatus(ctx, dn); resultCode != ldapmsg.ResultCodeSuccess {
+		return conn.WriteResponse(msg.ID, bindError(resultCode, diagnostic))
 	}
+	s.rehashPassword(ctx, dn, password, passwordHash)
 
 	// Bind successful - set the DN on the connection
 	conn.SetBoundDN(dn)
 
 	slog.Debug("Bind successful", "dn", dn)
-	targetDN = dn
-	resultCode = ldapmsg.ResultCodeSuccess
-	return conn.WriteResponse(msg.ID, protocol.NewBindResponse(ldapmsg.ResultCodeSuccess))
+	return conn.WriteResponse(msg.ID, protocol.NewBindResponse(ldapmsg.ResultCodeSuccess), policyControls...)
 }
 
 // handleCompare handles compare operations
@@ -305,6 +411,12 @@ func (s *Server) handleCompare(ctx context.Context, conn *protocol.Connection, m
 		})
 	}()
 
+	ctx, proxyResult, err := s.proxiedAuthorization(ctx, conn, msg)
+	if err != nil {
+		resultCode = proxyResult
+		return rejectProxiedAuthorization(conn, msg, proxyResult, err)
+	}
+
 	slog.Debug("Compare request", "dn", compareReq.Entry, "attribute", compareReq.AVA.Attribute)
 
 	if !s.canSearch(conn, compareReq.Entry) {
@@ -321,6 +433,22 @@ func (s *Server) handleCompare(ctx context.Context, conn *protocol.Connection, m
 		resultCode = ldapmsg.ResultCodeOperationsError
 		return conn.WriteResponse(msg.ID, protocol.NewCompareResponse(resultCode))
 	}
+	access := s.authorizer()
+	target := entry
+	if target == nil {
+		target = models.NewEntry(compareReq.Entry, "")
+	}
+	canCompare, err := access.Allowed(ctx, operationActor(ctx, conn), target, compareReq.AVA.Attribute, authz.PermissionCompare)
+	if err != nil {
+		slog.Error("Failed to check compare authorization", "dn", compareReq.Entry, "error", err)
+		resultCode = ldapmsg.ResultCodeOperationsError
+		return conn.WriteResponse(msg.ID, protocol.NewCompareResponse(resultCode))
+	}
+	if !canCompare {
+		slog.Info("Compare rejected - access denied", "dn", compareReq.Entry, "attribute", compareReq.AVA.Attribute)
+		resultCode = ldapmsg.ResultCodeInsufficientAccessRights
+		return conn.WriteResponse(msg.ID, protocol.NewCompareResponse(resultCode))
+	}
 	if entry == nil {
 		resultCode = ldapmsg.ResultCodeNoSuchObject
 		return conn.WriteResponse(msg.ID, protocol.NewCompareResponse(resultCode))
@@ -390,19 +518,45 @@ func (s *Server) auditLDAPOperation(ctx context.Context, conn *protocol.Connecti
 	event.ConnectionID = conn.ID()
 	event.MessageID = int(msg.ID)
 	event.RemoteAddr = conn.RemoteAddrString()
+	if actor, ok := proxiedActor(ctx); ok {
+		event.BindDN = conn.GetBoundDN()
+		event.ActorDN = actor.DN
+	}
 	audit.LogLDAP(ctx, event)
 	telemetry.RecordLDAPOperation(ctx, operation, event.ResultCode, event.Duration)
 }
 
-func (s *Server) canWrite(ctx context.Context, conn *protocol.Connection) (bool, error) {
-	baseDN := ""
-	if s.cfg != nil {
-		baseDN = s.cfg.LDAP.BaseDN
-	}
-	return authz.New(baseDN, s.store).CanWrite(ctx, authz.Actor{
+// authorizer returns the Authorizer for one request.
+func (s *Server) authorizer() *authz.Authorizer {
+	return authz.FromConfig(s.cfg, s.store)
+}
+
+// connActor returns the identity bound to the connection.
+func connActor(conn *protocol.Connection) authz.Actor {
+	return authz.Actor{
 		DN:    conn.GetBoundDN(),
 		Bound: conn.IsBound(),
-	})
+	}
+}
+
+// accessTarget loads the entry an operation on dn applies to, for access
+// rules to match against. Without access rules, or when the entry does not
+// exist, it is a bare entry with that DN, so a caller who is denied cannot
+// tell whether the entry exists.
+func (s *Server) accessTarget(ctx context.Context, access *authz.Authorizer, dn string) (*models.Entry, error) {
+	return s.loadAccessTarget(ctx, dn, access.HasAccessRules())
+}
+
+// loadAccessTarget returns the stored entry at dn when load is set and the
+// entry exists, and an entry with only the DN otherwise.
+func (s *Server) loadAccessTarget(ctx context.Context, dn string, load bool) (*models.Entry, error) {
+	if load {
+		entry, err := s.store.GetEntryWithOptions(ctx, dn, store.EntryOptions{IncludeMemberOf: false})
+		if err != nil || entry != nil {
+			return entry, err
+		}
+	}
+	return models.NewEntry(dn, ""), nil
 }
 
 func entryWriteResultCode(err error) ldapmsg.ResultCode {
@@ -422,6 +576,18 @@ func entryWriteResultCode(err error) ldapmsg.ResultCode {
 	if errors.Is(err, store.ErrConstraintViolation) {
 		return ldapmsg.ResultCodeConstraintViolation
 	}
+	if errors.Is(err, store.ErrNoSuchAttribute) {
+		return ldapmsg.ResultCodeNoSuchAttribute
+	}
+	if errors.Is(err, store.ErrUnwillingToPerform) {
+		return ldapmsg.ResultCodeUnwillingToPerform
+	}
+	if errors.Is(err, store.ErrAssertionFailed) {
+		return ldapmsg.ResultCodeAssertionFailed
+	}
+	if errors.Is(err, store.ErrNotAllowedOnNonLeaf) {
+		return ldapmsg.ResultCodeNotAllowedOnNonLeaf
+	}
 
 	return ldapmsg.ResultCodeOperationsError
 }
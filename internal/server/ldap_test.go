@@ -1,6 +1,8 @@
 package server
 
 import (
+	"errors"
+	"io"
 	"net"
 	"testing"
 	"time"
@@ -22,7 +24,7 @@ func TestHandleConnectionClosesIdleConnOnServerCancel(t *testing.T) {
 				KeyLength:   1,
 			},
 		},
-	}, nil, "test")
+	}, nil, "test", nil)
 
 	done := make(chan struct{})
 	go func() {
@@ -38,3 +40,51 @@ func TestHandleConnectionClosesIdleConnOnServerCancel(t *testing.T) {
 		t.Fatal("handleConnection did not exit after server context cancellation")
 	}
 }
+
+func TestAcceptLoopEnforcesPerIPConnectionCap(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	srv := NewServer(&config.Config{
+		Server: config.ServerConfig{MaxConnectionsPerIP: 1},
+	}, nil, "test", nil)
+	srv.listener = listener
+	go srv.acceptLoop()
+	t.Cleanup(func() {
+		srv.cancel()
+		_ = listener.Close()
+	})
+
+	dial := func(t *testing.T) net.Conn {
+		t.Helper()
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		t.Cleanup(func() { _ = conn.Close() })
+		return conn
+	}
+	closedByServer := func(conn net.Conn) bool {
+		_ = conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		_, err := conn.Read(make([]byte, 1))
+		return errors.Is(err, io.EOF)
+	}
+
+	first := dial(t)
+	if closedByServer(first) {
+		t.Fatal("first connection was closed, want it accepted")
+	}
+	if !closedByServer(dial(t)) {
+		t.Fatal("second connection from the same IP was not rejected")
+	}
+
+	// Closing the first connection frees its slot.
+	_ = first.Close()
+	deadline := time.Now().Add(time.Second)
+	for closedByServer(dial(t)) {
+		if time.Now().After(deadline) {
+			t.Fatal("connection slot was not released")
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"crypto/rand"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/smarzola/ldaplite/internal/protocol"
+	"github.com/smarzola/ldaplite/internal/protocol/ldapmsg"
+	"github.com/smarzola/ldaplite/internal/store"
+)
+
+// maxPagedSearchesPerConnection bounds the paged searches a single connection
+// can keep open. Clients that stop paging without sending a zero-size request
+// leave cursors behind; the oldest ones are dropped first.
+const maxPagedSearchesPerConnection = 16
+
+// pagedSearchCursor is the resume position of a simple paged results search.
+// The fingerprint ties the cookie to the original search request. Unsorted
+// searches resume after an entry ID; sorted searches resume at an offset.
+// Returned counts the entries earlier pages sent, so the size limit applies
+// to the whole search.
+type pagedSearchCursor struct {
+	fingerprint  string
+	afterEntryID int64
+	offset       int
+	returned     int
+	sequence     uint64
+}
+
+// pagedSearches tracks paged results cursors by connection ID and cookie. The
+// zero value is ready to use.
+type pagedSearches struct {
+	mu       sync.Mutex
+	sequence uint64
+	cursors  map[string]map[string]pagedSearchCursor
+}
+
+// save stores a cursor for the connection and returns its cookie.
+func (p *pagedSearches) save(connID, fingerprint string, afterEntryID int64, offset, returned int) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.cursors == nil {
+		p.cursors = make(map[string]map[string]pagedSearchCursor)
+	}
+	cursors := p.cursors[connID]
+	if cursors == nil {
+		cursors = make(map[string]pagedSearchCursor)
+		p.cursors[connID] = cursors
+	}
+	for len(cursors) >= maxPagedSearchesPerConnection {
+		oldest := ""
+		for cookie, cursor := range cursors {
+			if oldest == "" || cursor.sequence < cursors[oldest].sequence {
+				oldest = cookie
+			}
+		}
+		delete(cursors, oldest)
+	}
+
+	p.sequence++
+	cookie := rand.Text()
+	cursors[cookie] = pagedSearchCursor{
+		fingerprint:  fingerprint,
+		afterEntryID: afterEntryID,
+		offset:       offset,
+		returned:     returned,
+		sequence:     p.sequence,
+	}
+	return cookie
+}
+
+// take removes and returns the cursor for a cookie. Cookies are single use:
+// every page hands out a fresh one.
+func (p *pagedSearches) take(connID, cookie string) (pagedSearchCursor, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	cursor, ok := p.cursors[connID][cookie]
+	if ok {
+		delete(p.cursors[connID], cookie)
+	}
+	return cursor, ok
+}
+
+// release drops every cursor held by a closed connection.
+func (p *pagedSearches) release(connID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.cursors, connID)
+}
+
+// pagedSearchFingerprint identifies a search request so a cookie cannot be
+// replayed against a different search or after the connection rebinds.
+func pagedSearchFingerprint(boundDN string, req ldapmsg.SearchRequest, filter string, sortKeys []store.SortKey) string {
+	sortParts := make([]string, 0, len(sortKeys))
+	for _, key := range sortKeys {
+		sortParts = append(sortParts, strings.ToLower(key.Attribute+":"+key.OrderingRule+":"+strconv.FormatBool(key.Reverse)))
+	}
+	return strings.Join([]string{
+		strings.ToLower(boundDN),
+		strings.ToLower(req.BaseObject),
+		strconv.Itoa(int(req.Scope)),
+		filter,
+		strconv.FormatBool(req.TypesOnly),
+		strings.ToLower(strings.Join(req.Attributes, ",")),
+		strings.Join(sortParts, ","),
+	}, "\x00")
+}
+
+// pagedResultsRequest decodes the paged results control of a search request.
+func pagedResultsRequest(msg *ldapmsg.Message) (protocol.PagedResults, bool, error) {
+	control, ok := msg.Control(protocol.PagedResultsControlOID)
+	if !ok {
+		return protocol.PagedResults{}, false, nil
+	}
+	paging, err := protocol.DecodePagedResultsControl(control)
+	if err != nil {
+		return protocol.PagedResults{}, true, err
+	}
+	return paging, true, nil
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/smarzola/ldaplite/internal/protocol/ldapmsg"
+	"github.com/smarzola/ldaplite/internal/store"
+)
+
+func TestPagedSearchesCookiesAreSingleUsePerConnection(t *testing.T) {
+	var paging pagedSearches
+
+	cookie := paging.save("ldap-1", "search", 42, 0, 10)
+	if cookie == "" {
+		t.Fatal("save() returned empty cookie")
+	}
+	if _, ok := paging.take("ldap-2", cookie); ok {
+		t.Fatal("take() accepted cookie from another connection")
+	}
+	cursor, ok := paging.take("ldap-1", cookie)
+	if !ok || cursor.afterEntryID != 42 || cursor.returned != 10 || cursor.fingerprint != "search" {
+		t.Fatalf("take() = %+v, %v, want cursor after 42 with 10 returned", cursor, ok)
+	}
+	if _, ok := paging.take("ldap-1", cookie); ok {
+		t.Fatal("take() accepted a cookie twice")
+	}
+
+	cookie = paging.save("ldap-1", "search", 7, 0, 0)
+	paging.release("ldap-1")
+	if _, ok := paging.take("ldap-1", cookie); ok {
+		t.Fatal("take() accepted a cookie after release")
+	}
+}
+
+func TestPagedSearchesDropOldestCursorAtLimit(t *testing.T) {
+	var paging pagedSearches
+
+	first := paging.save("ldap-1", "search", 1, 0, 0)
+	var last string
+	for i := 0; i < maxPagedSearchesPerConnection; i++ {
+		last = paging.save("ldap-1", "search", int64(i+2), 0, 0)
+	}
+	if _, ok := paging.take("ldap-1", first); ok {
+		t.Fatal("oldest cursor survived past the per-connection limit")
+	}
+	if _, ok := paging.take("ldap-1", last); !ok {
+		t.Fatal("newest cursor was dropped")
+	}
+}
+
+func TestPagedSearchFingerprintTracksRequestAndIdentity(t *testing.T) {
+	req := ldapmsg.SearchRequest{
+		BaseObject: "dc=example,dc=com",
+		Scope:      ldapmsg.SearchScopeWholeSubtree,
+		Attributes: []string{"uid", "cn"},
+	}
+	base := pagedSearchFingerprint("uid=admin,ou=users,dc=example,dc=com", req, "(objectClass=*)", nil)
+
+	if got := pagedSearchFingerprint("UID=ADMIN,OU=USERS,DC=EXAMPLE,DC=COM", req, "(objectClass=*)", nil); got != base {
+		t.Fatal("fingerprint changed with DN case")
+	}
+	if got := pagedSearchFingerprint("uid=jane,ou=users,dc=example,dc=com", req, "(objectClass=*)", nil); got == base {
+		t.Fatal("fingerprint ignored the bound DN")
+	}
+	if got := pagedSearchFingerprint("uid=admin,ou=users,dc=example,dc=com", req, "(uid=jane)", nil); got == base {
+		t.Fatal("fingerprint ignored the filter")
+	}
+	other := req
+	other.Scope = ldapmsg.SearchScopeSingleLevel
+	if got := pagedSearchFingerprint("uid=admin,ou=users,dc=example,dc=com", other, "(objectClass=*)", nil); got == base {
+		t.Fatal("fingerprint ignored the scope")
+	}
+	sorted := []store.SortKey{{Attribute: "sn", Reverse: true}}
+	if got := pagedSearchFingerprint("uid=admin,ou=users,dc=example,dc=com", req, "(objectClass=*)", sorted); got == base {
+		t.Fatal("fingerprint ignored the sort keys")
+	}
+}
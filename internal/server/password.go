@@ -0,0 +1,190 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+
+	"github.com/smarzola/ldaplite/internal/authz"
+	"github.com/smarzola/ldaplite/internal/ldapdn"
+	"github.com/smarzola/ldaplite/internal/protocol"
+	"github.com/smarzola/ldaplite/internal/protocol/ldapmsg"
+	"github.com/smarzola/ldaplite/internal/store"
+	"github.com/smarzola/ldaplite/pkg/crypto"
+)
+
+// passwordModify performs a Password Modify extended operation (RFC 3062) and
+// returns the response together with the DN whose password it targeted and
+// the password policy control of a rejected new password.
+//
+// Users with password.changeSelf may change their own password when they
+// supply the current one as oldPasswd. Users with password.resetAny may set
+// any password they administer without it. A missing newPasswd makes the server generate one
+// and return it as genPasswd; only a client-chosen password is checked
+// against the password policy.
+func (s *Server) passwordModify(ctx context.Context, conn *protocol.Connection, extReq ldapmsg.ExtendedRequest) (ldapmsg.ExtendedResponse, string, *ldapmsg.Control) {
+	req, err := protocol.DecodePasswordModifyRequest(extReq.RequestValue)
+	if err != nil {
+		slog.Debug("Invalid password modify request", "error", err)
+		return passwordModifyError(ldapmsg.ResultCodeProtocolError, "invalid password modify request"), "", nil
+	}
+
+	boundDN := conn.GetBoundDN()
+	if boundDN == "" {
+		return passwordModifyError(ldapmsg.ResultCodeUnwillingToPerform, "authentication required"), "", nil
+	}
+
+	targetDN := boundDN
+	if req.UserIdentity != nil {
+		targetDN, err = s.passwordModifyTarget(ctx, *req.UserIdentity)
+		if err != nil {
+			slog.Error("Failed to resolve password modify user", "error", err)
+			return passwordModifyError(ldapmsg.ResultCodeOperationsError, ""), "", nil
+		}
+		if targetDN == "" {
+			return passwordModifyError(ldapmsg.ResultCodeNoSuchObject, ""), *req.UserIdentity, nil
+		}
+	}
+
+	access := s.authorizer()
+	actor := connActor(conn)
+	capabilities, err := access.Capabilities(ctx, actor)
+	resetAny := false
+	if err == nil && capabilities.Has(authz.PasswordResetAny) {
+		// Delegated administrators and other roles only reset passwords
+		// inside their subtrees.
+		resetAny, err = access.Allows(ctx, actor, authz.PasswordResetAny, targetDN)
+	}
+	if err != nil {
+		slog.Error("Failed to check password modify authorization", "dn", targetDN, "error", err)
+		return passwordModifyError(ldapmsg.ResultCodeOperationsError, ""), targetDN, nil
+	}
+	self := ldapdn.Equal(boundDN, targetDN)
+	if !resetAny && !(self && capabilities.Has(authz.PasswordChangeSelf)) {
+		slog.Info("Password modify rejected - access denied", "dn", targetDN)
+		return passwordModifyError(ldapmsg.ResultCodeInsufficientAccessRights, ""), targetDN, nil
+	}
+
+	entry, err := s.store.GetEntryWithOptions(ctx, targetDN, store.EntryOptions{IncludeMemberOf: false})
+	if err != nil {
+		slog.Error("Failed to get entry", "dn", targetDN, "error", err)
+		return passwordModifyError(ldapmsg.ResultCodeOperationsError, ""), targetDN, nil
+	}
+	if entry == nil {
+		return passwordModifyError(ldapmsg.ResultCodeNoSuchObject, ""), targetDN, nil
+	}
+	targetDN = entry.DN
+	if !entry.IsUser() {
+		return passwordModifyError(ldapmsg.ResultCodeUnwillingToPerform, "passwords can only be set on user entries"), targetDN, nil
+	}
+
+	if req.OldPassword != nil || !resetAny {
+		if req.OldPassword == nil {
+			return passwordModifyError(ldapmsg.ResultCodeUnwillingToPerform, "oldPasswd is required to change your own password"), targetDN, nil
+		}
+		// Entries never carry userPassword; the hash lives in the users table.
+		passwordHash, _, err := s.store.GetUserPasswordHashByDN(ctx, targetDN)
+		if err != nil {
+			slog.Error("Failed to get password hash", "dn", targetDN, "error", err)
+			return passwordModifyError(ldapmsg.ResultCodeOperationsError, ""), targetDN, nil
+		}
+		valid, err := s.hasher.Verify(*req.OldPassword, passwordHash)
+		if err != nil || !valid {
+			slog.Debug("Password modify old password mismatch", "dn", targetDN)
+			return passwordModifyError(ldapmsg.ResultCodeInvalidCredentials, ""), targetDN, nil
+		}
+	}
+
+	newPassword, generated := "", ""
+	if req.NewPassword != nil {
+		newPassword = *req.NewPassword
+		if newPassword == "" {
+			return passwordModifyError(ldapmsg.ResultCodeConstraintViolation, "newPasswd must not be empty"), targetDN, nil
+		}
+		if err := s.policy.CheckNewPassword(ctx, targetDN, newPassword); err != nil {
+			if control := passwordPolicyError(err); control != nil {
+				slog.Debug("Password modify rejected by password policy", "dn", targetDN, "error", err)
+				return passwordModifyError(ldapmsg.ResultCodeConstraintViolation, err.Error()), targetDN, control
+			}
+			slog.Error("Failed to check password policy", "dn", targetDN, "error", err)
+			return passwordModifyError(ldapmsg.ResultCodeOperationsError, ""), targetDN, nil
+		}
+	} else {
+		password, err := s.policy.GeneratePassword()
+		if err != nil {
+			slog.Error("Failed to generate password", "dn", targetDN, "error", err)
+			return passwordModifyError(ldapmsg.ResultCodeOperationsError, ""), targetDN, nil
+		}
+		newPassword = password
+		generated = newPassword
+	}
+
+	// newPasswd is always a cleartext password here, unlike userPassword
+	// values in Add and Modify, which may already carry a scheme prefix.
+	hashed, err := s.hasher.HashValues(newPassword)
+	if err != nil {
+		slog.Error("Failed to hash password", "dn", targetDN, "error", err)
+		if errors.Is(err, crypto.ErrHashPoolBusy) {
+			return passwordModifyError(ldapmsg.ResultCodeBusy, ""), targetDN, nil
+		}
+		return passwordModifyError(ldapmsg.ResultCodeOperationsError, ""), targetDN, nil
+	}
+	entry.SetAttributes("userPassword", hashed)
+	if err := s.store.UpdateEntry(ctx, entry); err != nil {
+		slog.Error("Failed to update password", "dn", targetDN, "error", err)
+		return passwordModifyError(entryWriteResultCode(err), ""), targetDN, nil
+	}
+
+	slog.Info("Password modified", "dn", targetDN, "generated", generated != "")
+	return protocol.NewPasswordModifyResponse(generated), targetDN, nil
+}
+
+// rehashPassword upgrades the stored hash of a user who just bound with
+// password when it uses a legacy scheme or outdated Argon2id parameters. The
+// bind has already succeeded, so a failure is only logged.
+func (s *Server) rehashPassword(ctx context.Context, dn, password, passwordHash string) {
+	if !s.hasher.NeedsRehash(passwordHash) {
+		return
+	}
+	hashed, err := s.hasher.HashValues(password)
+	if err != nil {
+		slog.Error("Failed to rehash password", "dn", dn, "error", err)
+		return
+	}
+	if err := s.store.RehashUserPassword(ctx, dn, passwordHash, hashed[0], hashed[1]); err != nil {
+		slog.Error("Failed to store rehashed password", "dn", dn, "error", err)
+		return
+	}
+	slog.Info("Password rehashed", "dn", dn)
+}
+
+// passwordModifyTarget resolves a userIdentity to an entry DN. It accepts a
+// plain DN or the "dn:" and "u:" authorization identity forms (RFC 4513
+// section 5.2.1.8). An unknown u: identity resolves to "".
+func (s *Server) passwordModifyTarget(ctx context.Context, identity string) (string, error) {
+	switch {
+	case strings.HasPrefix(identity, "u:"):
+		_, dn, err := s.store.GetUserPasswordHash(ctx, strings.TrimPrefix(identity, "u:"))
+		return dn, err
+	case strings.HasPrefix(identity, "dn:"):
+		return strings.TrimPrefix(identity, "dn:"), nil
+	default:
+		return identity, nil
+	}
+}
+
+// passwordHashResultCode maps an error processing a userPassword value: busy
+// when the hash pool is full, otherwise a rejected value.
+func passwordHashResultCode(err error) ldapmsg.ResultCode {
+	if errors.Is(err, crypto.ErrHashPoolBusy) {
+		return ldapmsg.ResultCodeBusy
+	}
+	return ldapmsg.ResultCodeConstraintViolation
+}
+
+func passwordModifyError(resultCode ldapmsg.ResultCode, diagnosticMessage string) ldapmsg.ExtendedResponse {
+	resp := protocol.NewExtendedResponse(resultCode)
+	resp.DiagnosticMessage = diagnosticMessage
+	return resp
+}
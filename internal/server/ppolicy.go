@@ -0,0 +1,137 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/smarzola/ldaplite/internal/authz"
+	"github.com/smarzola/ldaplite/internal/models"
+	"github.com/smarzola/ldaplite/internal/ppolicy"
+	"github.com/smarzola/ldaplite/internal/protocol"
+	"github.com/smarzola/ldaplite/internal/protocol/ldapmsg"
+	"github.com/smarzola/ldaplite/pkg/crypto"
+)
+
+// authenticatePassword runs a password check for the user at dn under the
+// password policy. It returns the bind result code and the password policy
+// response control describing the outcome, which is nil after internal
+// errors.
+func (s *Server) authenticatePassword(ctx context.Context, dn string, verify func() (bool, error)) (ldapmsg.ResultCode, *ldapmsg.Control) {
+	result, err := s.policy.Authenticate(ctx, dn, verify)
+	var policyErr *ppolicy.Error
+	switch {
+	case err == nil:
+		control := protocol.NewPasswordPolicyResponseControl(result.TimeBeforeExpiration, result.GraceAuthNsRemaining, -1)
+		return ldapmsg.ResultCodeSuccess, &control
+	case errors.As(err, &policyErr):
+		control := protocol.NewPasswordPolicyResponseControl(-1, -1, int(policyErr.Code))
+		return ldapmsg.ResultCodeInvalidCredentials, &control
+	case errors.Is(err, ppolicy.ErrInvalidCredentials):
+		control := protocol.NewPasswordPolicyResponseControl(-1, -1, -1)
+		return ldapmsg.ResultCodeInvalidCredentials, &control
+	case errors.Is(err, crypto.ErrHashPoolBusy):
+		slog.Warn("Bind rejected - password hashing is busy", "dn", dn)
+		return ldapmsg.ResultCodeBusy, nil
+	default:
+		slog.Error("Password policy check failed", "dn", dn, "error", err)
+		return ldapmsg.ResultCodeOperationsError, nil
+	}
+}
+
+// verifyPassword returns the verify callback for authenticatePassword. A
+// malformed stored hash simply fails to verify; only a busy hash pool is
+// reported as an error.
+func (s *Server) verifyPassword(password, passwordHash string) func() (bool, error) {
+	return func() (bool, error) {
+		valid, err := s.hasher.Verify(password, passwordHash)
+		if errors.Is(err, crypto.ErrHashPoolBusy) {
+			return false, err
+		}
+		return err == nil && valid, nil
+	}
+}
+
+// checkPreHashedPasswords returns ppolicy.ErrPreHashedPassword when values
+// hold a pre-hashed password and the acting identity may not reset passwords
+// at dn. Pre-hashed values skip the password policy, so users changing their
+// own password must send it in cleartext.
+func (s *Server) checkPreHashedPasswords(ctx context.Context, conn *protocol.Connection, dn string, values []string) error {
+	if !slices.ContainsFunc(values, crypto.IsHashedPassword) {
+		return nil
+	}
+	allowed, err := s.authorizer().Allows(ctx, operationActor(ctx, conn), authz.PasswordResetAny, dn)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return ppolicy.ErrPreHashedPassword
+	}
+	return nil
+}
+
+// passwordPolicyError returns the response control for a password rejected
+// by the policy, or nil when err is not a policy violation.
+func passwordPolicyError(err error) *ldapmsg.Control {
+	var policyErr *ppolicy.Error
+	if !errors.As(err, &policyErr) {
+		return nil
+	}
+	control := protocol.NewPasswordPolicyResponseControl(-1, -1, int(policyErr.Code))
+	return &control
+}
+
+// passwordPolicyControls returns the response controls carrying a password
+// policy control, which is only sent to clients that asked for it.
+func passwordPolicyControls(msg *ldapmsg.Message, control *ldapmsg.Control) []ldapmsg.Control {
+	if control == nil {
+		return nil
+	}
+	if _, ok := msg.Control(protocol.PasswordPolicyControlOID); !ok {
+		return nil
+	}
+	return []ldapmsg.Control{*control}
+}
+
+// passwordPolicyAttributeNames are the operational attributes that expose the
+// password policy state of user entries.
+var passwordPolicyAttributeNames = []string{"pwdChangedTime", "pwdFailureTime", "pwdAccountLockedTime"}
+
+func (s searchAttributeSelection) includesPasswordPolicyState() bool {
+	return slices.ContainsFunc(passwordPolicyAttributeNames, s.includes)
+}
+
+// passwordPolicyAttributes returns the selected password policy attributes of
+// a user entry.
+func (s *Server) passwordPolicyAttributes(ctx context.Context, dn string, selection searchAttributeSelection) ([]searchResponseAttribute, error) {
+	state, err := s.store.GetPasswordPolicyState(ctx, dn)
+	if err != nil || state == nil {
+		return nil, err
+	}
+	var failureTimes []string
+	for _, failureTime := range state.FailureTimes {
+		failureTimes = append(failureTimes, models.FormatLDAPTimestamp(failureTime))
+	}
+	values := map[string][]string{
+		"pwdChangedTime":       timestampValues(state.ChangedTime),
+		"pwdFailureTime":       failureTimes,
+		"pwdAccountLockedTime": timestampValues(state.AccountLockedTime),
+	}
+
+	var attrs []searchResponseAttribute
+	for _, name := range passwordPolicyAttributeNames {
+		if len(values[name]) > 0 && selection.includes(name) {
+			attrs = append(attrs, searchResponseAttribute{name: name, values: values[name]})
+		}
+	}
+	return attrs, nil
+}
+
+func timestampValues(t time.Time) []string {
+	if t.IsZero() {
+		return nil
+	}
+	return []string{models.FormatLDAPTimestamp(t)}
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/smarzola/ldaplite/internal/authz"
+	"github.com/smarzola/ldaplite/internal/protocol"
+	"github.com/smarzola/ldaplite/internal/protocol/ldapmsg"
+	"github.com/smarzola/ldaplite/internal/store"
+)
+
+type proxiedActorKey struct{}
+
+// proxiedAuthorization applies the proxied authorization control of an
+// operation (RFC 4370). When the bound identity may act as the identity the
+// control names, the returned context carries that identity for
+// operationActor and the audit log.
+//
+// The bound identity needs DirectoryProxy over the proxied entry, which must
+// be an enabled user outside the admin group unless the bound identity is an
+// admin itself. Other than admins, the bound identity must hold every
+// capability of the proxied user wherever that user holds it. Anonymous
+// proxying needs anonymous binds to be allowed.
+func (s *Server) proxiedAuthorization(ctx context.Context, conn *protocol.Connection, msg *ldapmsg.Message) (context.Context, ldapmsg.ResultCode, error) {
+	control, ok := msg.Control(protocol.ProxiedAuthorizationControlOID)
+	if !ok {
+		return ctx, ldapmsg.ResultCodeSuccess, nil
+	}
+	if !control.Criticality {
+		return ctx, ldapmsg.ResultCodeProtocolError, errors.New("proxied authorization control must be critical")
+	}
+	authzID := ""
+	if control.Value != nil {
+		authzID = *control.Value
+	}
+
+	requester := connActor(conn)
+	if requester.DN == "" {
+		return ctx, ldapmsg.ResultCodeAuthorizationDenied, errors.New("proxied authorization needs an authenticated bind")
+	}
+	proxied := authz.Actor{Bound: true}
+	if authzID != "" {
+		var err error
+		if proxied, err = s.authzIDActor(ctx, authzID); err != nil {
+			return ctx, ldapmsg.ResultCodeAuthorizationDenied, err
+		}
+	}
+
+	access := s.authorizer()
+	allowed, err := s.mayProxy(ctx, access, requester, proxied)
+	if err != nil {
+		return ctx, ldapmsg.ResultCodeOperationsError, err
+	}
+	if !allowed {
+		return ctx, ldapmsg.ResultCodeAuthorizationDenied, errors.New("not allowed to act as " + authzID)
+	}
+	return context.WithValue(ctx, proxiedActorKey{}, proxied), ldapmsg.ResultCodeSuccess, nil
+}
+
+func (s *Server) mayProxy(ctx context.Context, access *authz.Authorizer, requester, proxied authz.Actor) (bool, error) {
+	if proxied.DN == "" {
+		if !s.cfg.Security.AllowAnonymousBind {
+			return false, nil
+		}
+		capabilities, err := access.Capabilities(ctx, requester)
+		return capabilities.Has(authz.DirectoryProxy), err
+	}
+
+	allowed, err := access.Allows(ctx, requester, authz.DirectoryProxy, proxied.DN)
+	if err != nil || !allowed {
+		return false, err
+	}
+	entry, err := s.store.GetEntryWithOptions(ctx, proxied.DN, store.EntryOptions{IncludeMemberOf: false})
+	if err != nil || entry == nil || !entry.IsUser() {
+		return false, err
+	}
+	if entry.AccountStatus().Check(time.Now()) != nil {
+		return false, nil
+	}
+	// Acting as an admin would hand a service every capability, and acting
+	// as anyone else must not hand it capabilities it lacks either.
+	requesterIsAdmin, err := access.IsAdmin(ctx, requester.DN)
+	if err != nil || requesterIsAdmin {
+		return requesterIsAdmin, err
+	}
+	proxiedIsAdmin, err := access.IsAdmin(ctx, proxied.DN)
+	if err != nil || proxiedIsAdmin {
+		return false, err
+	}
+	return access.Covers(ctx, requester, proxied)
+}
+
+// rejectProxiedAuthorization answers an operation whose proxied
+// authorization control was refused.
+func rejectProxiedAuthorization(conn *protocol.Connection, msg *ldapmsg.Message, resultCode ldapmsg.ResultCode, err error) error {
+	slog.Info("Proxied authorization rejected", "bindDN", conn.GetBoundDN(), "error", err)
+	resp, _ := protocol.NewResultResponse(msg.Op, resultCode)
+	return conn.WriteResponse(msg.ID, resp)
+}
+
+// proxiedActor returns the identity an operation acts as through proxied
+// authorization.
+func proxiedActor(ctx context.Context) (authz.Actor, bool) {
+	actor, ok := ctx.Value(proxiedActorKey{}).(authz.Actor)
+	return actor, ok
+}
+
+// operationActor returns the identity an operation acts as: the proxied
+// identity when proxied authorization was accepted, otherwise the identity
+// bound to the connection.
+func operationActor(ctx context.Context, conn *protocol.Connection) authz.Actor {
+	if actor, ok := proxiedActor(ctx); ok {
+		return actor
+	}
+	return connActor(conn)
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/smarzola/ldaplite/internal/models"
+	"github.com/smarzola/ldaplite/internal/protocol"
+	"github.com/smarzola/ldaplite/internal/protocol/ldapmsg"
+	"github.com/smarzola/ldaplite/pkg/config"
+)
+
+const (
+	testPortalDN       = "uid=portal,ou=services,dc=example,dc=com"
+	testPortalsGroupDN = "cn=portals,ou=groups,dc=example,dc=com"
+	testProxiedUserDN  = "uid=jane,ou=users,dc=example,dc=com"
+	testAdminDN        = "uid=admin,ou=users,dc=example,dc=com"
+	testAdminGroupDN   = "cn=ldaplite.admin,ou=groups,dc=example,dc=com"
+
+	testEMEALeadDN      = "uid=lead,ou=emea,dc=example,dc=com"
+	testEMEAUserDN      = "uid=eve,ou=emea,dc=example,dc=com"
+	testEMEAAuditorDN   = "uid=audrey,ou=emea,dc=example,dc=com"
+	testEMEAAdminsDN    = "cn=emea-admins,ou=groups,dc=example,dc=com"
+	testAPACLeadDN      = "uid=kim,ou=apac,dc=example,dc=com"
+	testAPACUserDN      = "uid=lee,ou=apac,dc=example,dc=com"
+	testAPACAdminsDN    = "cn=apac-admins,ou=groups,dc=example,dc=com"
+	testAuditorsGroupDN = "cn=auditors,ou=groups,dc=example,dc=com"
+)
+
+func proxyTestConfig() *config.Config {
+	cfg := auditTestConfig()
+	cfg.Authz.Roles = []config.Role{{
+		Name:         "portal",
+		Capabilities: []string{"directory.proxy"},
+		Groups:       []string{testPortalsGroupDN},
+		Subtrees:     []string{"ou=users,dc=example,dc=com"},
+	}, {
+		Name:         "emea-proxy",
+		Capabilities: []string{"directory.proxy"},
+		Groups:       []string{testEMEAAdminsDN},
+		Subtrees:     []string{"ou=emea,dc=example,dc=com"},
+	}, {
+		Name:         "auditors",
+		Capabilities: []string{"password.resetAny"},
+		Groups:       []string{testAuditorsGroupDN},
+	}}
+	cfg.Authz.DelegatedAdmins = []config.DelegatedAdmin{
+		{GroupDN: testEMEAAdminsDN, Subtrees: []string{"ou=emea,dc=example,dc=com"}},
+		{GroupDN: testAPACAdminsDN, Subtrees: []string{"ou=apac,dc=example,dc=com"}},
+	}
+	return cfg
+}
+
+func proxyTestStore() *auditStore {
+	disabled := models.NewEntry("uid=gone,ou=users,dc=example,dc=com", string(models.ObjectClassInetOrgPerson))
+	disabled.SetAccountStatus(models.AccountStatus{Disabled: true})
+	return &auditStore{
+		entries: map[string]*models.Entry{
+			testProxiedUserDN:                       models.NewEntry(testProxiedUserDN, string(models.ObjectClassInetOrgPerson)),
+			testAdminDN:                             models.NewEntry(testAdminDN, string(models.ObjectClassInetOrgPerson)),
+			disabled.DN:                             disabled,
+			"uid=svc,ou=services,dc=example,dc=com": models.NewEntry("uid=svc,ou=services,dc=example,dc=com", string(models.ObjectClassInetOrgPerson)),
+			testEMEAUserDN:                          models.NewEntry(testEMEAUserDN, string(models.ObjectClassInetOrgPerson)),
+			testEMEAAuditorDN:                       models.NewEntry(testEMEAAuditorDN, string(models.ObjectClassInetOrgPerson)),
+			testAPACUserDN:                          models.NewEntry(testAPACUserDN, string(models.ObjectClassInetOrgPerson)),
+		},
+		groups: map[string][]string{
+			testPortalDN:      {testPortalsGroupDN},
+			testAdminDN:       {testAdminGroupDN},
+			testEMEALeadDN:    {testEMEAAdminsDN},
+			testEMEAAuditorDN: {testAuditorsGroupDN},
+			testAPACLeadDN:    {testAPACAdminsDN},
+		},
+	}
+}
+
+func proxiedAuthorizationControl(authzID string) ldapmsg.Control {
+	return ldapmsg.Control{OID: protocol.ProxiedAuthorizationControlOID, Criticality: true, Value: &authzID}
+}
+
+func TestProxiedAuthorization(t *testing.T) {
+	tests := []struct {
+		name      string
+		boundDN   string
+		control   ldapmsg.Control
+		want      ldapmsg.ResultCode
+		wantActor string
+	}{
+		{name: "user in role subtree", boundDN: testPortalDN, control: proxiedAuthorizationControl("dn:" + testProxiedUserDN), want: ldapmsg.ResultCodeSuccess, wantActor: testProxiedUserDN},
+		{name: "not critical", boundDN: testPortalDN, control: ldapmsg.Control{OID: protocol.ProxiedAuthorizationControlOID}, want: ldapmsg.ResultCodeProtocolError},
+		{name: "outside role subtree", boundDN: testPortalDN, control: proxiedAuthorizationControl("dn:uid=svc,ou=services,dc=example,dc=com"), want: ldapmsg.ResultCodeAuthorizationDenied},
+		{name: "missing user", boundDN: testPortalDN, control: proxiedAuthorizationControl("dn:uid=nobody,ou=users,dc=example,dc=com"), want: ldapmsg.ResultCodeAuthorizationDenied},
+		{name: "disabled user", boundDN: testPortalDN, control: proxiedAuthorizationControl("dn:uid=gone,ou=users,dc=example,dc=com"), want: ldapmsg.ResultCodeAuthorizationDenied},
+		{name: "admin user", boundDN: testPortalDN, control: proxiedAuthorizationControl("dn:" + testAdminDN), want: ldapmsg.ResultCodeAuthorizationDenied},
+		{name: "anonymous when anonymous binds are off", boundDN: testPortalDN, control: proxiedAuthorizationControl(""), want: ldapmsg.ResultCodeAuthorizationDenied},
+		{name: "without capability", boundDN: "uid=svc,ou=services,dc=example,dc=com", control: proxiedAuthorizationControl("dn:" + testProxiedUserDN), want: ldapmsg.ResultCodeAuthorizationDenied},
+		{name: "delegated admin with proxy role", boundDN: testEMEALeadDN, control: proxiedAuthorizationControl("dn:" + testEMEAUserDN), want: ldapmsg.ResultCodeSuccess, wantActor: testEMEAUserDN},
+		{name: "delegated admin as user with wider role", boundDN: testEMEALeadDN, control: proxiedAuthorizationControl("dn:" + testEMEAAuditorDN), want: ldapmsg.ResultCodeAuthorizationDenied},
+		{name: "delegated admin without proxy role", boundDN: testAPACLeadDN, control: proxiedAuthorizationControl("dn:" + testAPACUserDN), want: ldapmsg.ResultCodeAuthorizationDenied},
+		{name: "admin may proxy anyone", boundDN: testAdminDN, control: proxiedAuthorizationControl("dn:uid=svc,ou=services,dc=example,dc=com"), want: ldapmsg.ResultCodeSuccess, wantActor: "uid=svc,ou=services,dc=example,dc=com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := NewServer(proxyTestConfig(), proxyTestStore(), "test", nil)
+			conn := protocol.NewConnection(nil, protocol.OperationHandlers{})
+			conn.SetBoundDN(tt.boundDN)
+			msg := &ldapmsg.Message{Op: ldapmsg.DeleteRequest{DN: testProxiedUserDN}, Controls: []ldapmsg.Control{tt.control}}
+
+			ctx, got, err := srv.proxiedAuthorization(context.Background(), conn, msg)
+			if got != tt.want {
+				t.Fatalf("proxiedAuthorization() = %v, %v; want %v", got, err, tt.want)
+			}
+			actor := operationActor(ctx, conn)
+			if tt.wantActor != "" && actor.DN != tt.wantActor {
+				t.Fatalf("operationActor() = %q, want %q", actor.DN, tt.wantActor)
+			}
+			if tt.wantActor == "" && actor.DN != tt.boundDN {
+				t.Fatalf("operationActor() = %q, want the bound identity", actor.DN)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/smarzola/ldaplite/internal/authz"
+	"github.com/smarzola/ldaplite/internal/ldapdn"
+	"github.com/smarzola/ldaplite/internal/models"
+	"github.com/smarzola/ldaplite/internal/protocol"
+	"github.com/smarzola/ldaplite/internal/protocol/ldapmsg"
+)
+
+// Attributes carrying the answer to a get effective rights control, in the
+// format of 389 Directory Server.
+const (
+	entryLevelRightsAttribute     = "entryLevelRights"
+	attributeLevelRightsAttribute = "attributeLevelRights"
+)
+
+// effectiveRights is a get effective rights request: whose rights to report
+// and which attributes to report them for besides those returned.
+type effectiveRights struct {
+	actor      authz.Actor
+	attributes []string
+}
+
+// effectiveRightsRequest decodes the get effective rights control of a
+// search. Anyone may ask for their own rights; asking about another identity
+// needs the admin group, since the answer reveals the access rules.
+func (s *Server) effectiveRightsRequest(ctx context.Context, conn *protocol.Connection, msg *ldapmsg.Message) (*effectiveRights, ldapmsg.ResultCode, error) {
+	control, ok := msg.Control(protocol.GetEffectiveRightsControlOID)
+	if !ok {
+		return nil, ldapmsg.ResultCodeSuccess, nil
+	}
+	request, err := protocol.DecodeGetEffectiveRightsControl(control)
+	if err != nil {
+		return nil, ldapmsg.ResultCodeProtocolError, err
+	}
+
+	requester := operationActor(ctx, conn)
+	subject := requester
+	if request.AuthzID != "" {
+		if subject, err = s.authzIDActor(ctx, request.AuthzID); err != nil {
+			return nil, ldapmsg.ResultCodeProtocolError, err
+		}
+	}
+	if !ldapdn.Equal(subject.DN, requester.DN) {
+		isAdmin, err := s.authorizer().IsAdmin(ctx, requester.DN)
+		if err != nil {
+			return nil, ldapmsg.ResultCodeOperationsError, err
+		}
+		if !isAdmin {
+			return nil, ldapmsg.ResultCodeInsufficientAccessRights, errors.New("only administrators may read the rights of other identities")
+		}
+	}
+	return &effectiveRights{actor: subject, attributes: request.Attributes}, ldapmsg.ResultCodeSuccess, nil
+}
+
+// authzIDActor resolves an authorization identity (RFC 4513 section
+// 5.2.1.8). "dn:" alone is the anonymous identity.
+func (s *Server) authzIDActor(ctx context.Context, authzID string) (authz.Actor, error) {
+	switch {
+	case strings.HasPrefix(authzID, "dn:"):
+		dn := strings.TrimSpace(strings.TrimPrefix(authzID, "dn:"))
+		if dn == "" {
+			return authz.Actor{Bound: true}, nil
+		}
+		return authz.BoundUser(dn), nil
+	case strings.HasPrefix(authzID, "u:"):
+		_, dn, err := s.store.GetUserPasswordHash(ctx, strings.TrimPrefix(authzID, "u:"))
+		if err != nil || dn == "" {
+			return authz.Actor{}, fmt.Errorf("unknown authorization identity %q", authzID)
+		}
+		return authz.BoundUser(dn), nil
+	default:
+		return authz.Actor{}, fmt.Errorf("authorization identity %q must start with dn: or u:", authzID)
+	}
+}
+
+// entryAttributes returns the entryLevelRights and attributeLevelRights
+// values of entry for the attributes being returned and those the request
+// named.
+//
+// Entry rights are v (returned by searches), a (add), d (delete) and n
+// (rename, which needs both). Attribute rights are r (read), s (search), c
+// (compare), w (write) and o (delete values, which is also a write).
+func (r *effectiveRights) entryAttributes(ctx context.Context, access *authz.Authorizer, entry *models.Entry, returned []searchResponseAttribute, selection searchAttributeSelection) ([]searchResponseAttribute, error) {
+	entryRights, err := access.Explain(ctx, r.actor, entry, "")
+	if err != nil {
+		return nil, err
+	}
+	var letters strings.Builder
+	for _, right := range []struct {
+		letter      byte
+		permissions []authz.Permission
+	}{
+		{'v', []authz.Permission{authz.PermissionSearch}},
+		{'a', []authz.Permission{authz.PermissionAdd}},
+		{'d', []authz.Permission{authz.PermissionDelete}},
+		{'n', []authz.Permission{authz.PermissionAdd, authz.PermissionDelete}},
+	} {
+		if allowsAll(entryRights, right.permissions) {
+			letters.WriteByte(right.letter)
+		}
+	}
+
+	var attributeRights []string
+	for _, name := range r.attributeNames(returned, selection) {
+		rights, err := access.Explain(ctx, r.actor, entry, name)
+		if err != nil {
+			return nil, err
+		}
+		var attributeLetters strings.Builder
+		for _, right := range []struct {
+			letter     byte
+			permission authz.Permission
+		}{
+			{'r', authz.PermissionRead},
+			{'s', authz.PermissionSearch},
+			{'c', authz.PermissionCompare},
+			{'w', authz.PermissionWrite},
+			{'o', authz.PermissionWrite},
+		} {
+			if rights.Allowed(right.permission) {
+				attributeLetters.WriteByte(right.letter)
+			}
+		}
+		attributeRights = append(attributeRights, name+":"+rightsOrNone(attributeLetters.String()))
+	}
+
+	attrs := []searchResponseAttribute{{name: entryLevelRightsAttribute, values: []string{rightsOrNone(letters.String())}}}
+	if len(attributeRights) > 0 {
+		attrs = append(attrs, searchResponseAttribute{name: attributeLevelRightsAttribute, values: []string{strings.Join(attributeRights, ", ")}})
+	}
+	return attrs, nil
+}
+
+// attributeNames lists the attributes to report rights for, once each and
+// sorted.
+func (r *effectiveRights) attributeNames(returned []searchResponseAttribute, selection searchAttributeSelection) []string {
+	seen := make(map[string]bool)
+	var names []string
+	add := func(name string) {
+		lower := strings.ToLower(name)
+		if name == "" || seen[lower] || lower == strings.ToLower(entryLevelRightsAttribute) || lower == strings.ToLower(attributeLevelRightsAttribute) {
+			return
+		}
+		seen[lower] = true
+		names = append(names, name)
+	}
+	for _, attr := range returned {
+		add(attr.name)
+	}
+	for name := range selection.names {
+		add(name)
+	}
+	for _, name := range r.attributes {
+		add(strings.TrimSpace(name))
+	}
+	slices.SortFunc(names, func(a, b string) int {
+		return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+	})
+	return names
+}
+
+func allowsAll(explanation authz.Explanation, permissions []authz.Permission) bool {
+	for _, permission := range permissions {
+		if !explanation.Allowed(permission) {
+			return false
+		}
+	}
+	return true
+}
+
+func rightsOrNone(letters string) string {
+	if letters == "" {
+		return "none"
+	}
+	return letters
+}
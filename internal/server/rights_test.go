@@ -0,0 +1,92 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/smarzola/ldaplite/internal/authz"
+	"github.com/smarzola/ldaplite/internal/models"
+	"github.com/smarzola/ldaplite/internal/protocol"
+	"github.com/smarzola/ldaplite/internal/protocol/ber"
+	"github.com/smarzola/ldaplite/internal/protocol/ldapmsg"
+	"github.com/smarzola/ldaplite/pkg/config"
+)
+
+func effectiveRightsMessage(authzID string) *ldapmsg.Message {
+	value := string(ber.OctetString(authzID))
+	return &ldapmsg.Message{
+		Op:       ldapmsg.SearchRequest{BaseObject: "dc=example,dc=com"},
+		Controls: []ldapmsg.Control{{OID: protocol.GetEffectiveRightsControlOID, Value: &value}},
+	}
+}
+
+func TestEffectiveRightsRequestNeedsAdminForOtherIdentities(t *testing.T) {
+	const bobDN = "uid=bob,ou=users,dc=example,dc=com"
+	ctx := context.Background()
+	conn := protocol.NewConnection(nil, protocol.OperationHandlers{})
+	conn.SetBoundDN("uid=jane,ou=users,dc=example,dc=com")
+
+	srv := testAuthzServer(false)
+	rights, resultCode, err := srv.effectiveRightsRequest(ctx, conn, effectiveRightsMessage(""))
+	if err != nil || rights == nil || rights.actor.DN != "uid=jane,ou=users,dc=example,dc=com" {
+		t.Fatalf("effectiveRightsRequest(self) = %+v, %v, %v; want the requester", rights, resultCode, err)
+	}
+	_, resultCode, err = srv.effectiveRightsRequest(ctx, conn, effectiveRightsMessage("dn:"+bobDN))
+	if err == nil || resultCode != ldapmsg.ResultCodeInsufficientAccessRights {
+		t.Fatalf("effectiveRightsRequest(other) = %v, %v; want insufficientAccessRights", resultCode, err)
+	}
+	_, resultCode, err = srv.effectiveRightsRequest(ctx, conn, effectiveRightsMessage("bob"))
+	if err == nil || resultCode != ldapmsg.ResultCodeProtocolError {
+		t.Fatalf("effectiveRightsRequest(bare uid) = %v, %v; want protocolError", resultCode, err)
+	}
+
+	srv.store = &authzStore{admin: true}
+	rights, _, err = srv.effectiveRightsRequest(ctx, conn, effectiveRightsMessage("dn:"+bobDN))
+	if err != nil || rights.actor.DN != bobDN {
+		t.Fatalf("effectiveRightsRequest(other as admin) = %+v, %v; want bob", rights, err)
+	}
+	if rights, _, _ := srv.effectiveRightsRequest(ctx, conn, &ldapmsg.Message{}); rights != nil {
+		t.Fatalf("effectiveRightsRequest(no control) = %+v, want nil", rights)
+	}
+}
+
+func TestEffectiveRightsEntryAttributes(t *testing.T) {
+	srv := testAuthzServer(false)
+	srv.cfg.Authz.AccessRules = []config.AccessRule{
+		{Name: "own-mobile", Subject: "self", Attributes: []string{"mobile"}, Permissions: []string{"read", "write"}},
+		{Name: "private-mobile", Effect: "deny", Subject: "users", Attributes: []string{"mobile"}, Permissions: []string{"read", "compare"}},
+	}
+	ctx := context.Background()
+	bob := models.NewEntry("uid=bob,ou=users,dc=example,dc=com", string(models.ObjectClassInetOrgPerson))
+	bob.SetAttribute("cn", "Bob")
+	bob.SetAttribute("mobile", "555-0101")
+
+	tests := []struct {
+		name      string
+		actorDN   string
+		wantEntry string
+		wantAttrs string
+	}{
+		{name: "other user", actorDN: "uid=jane,ou=users,dc=example,dc=com", wantEntry: "v", wantAttrs: "cn:rsc, mail:rsc, mobile:s"},
+		{name: "self", actorDN: bob.DN, wantEntry: "v", wantAttrs: "cn:rsc, mail:rsc, mobile:rswo"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selection := newSearchAttributeSelection([]string{"cn", "mobile"})
+			rights := &effectiveRights{actor: authz.BoundUser(tt.actorDN), attributes: []string{"mail"}}
+			attrs, err := rights.entryAttributes(ctx, srv.authorizer(), bob, searchResponseAttributes(bob, selection), selection)
+			if err != nil {
+				t.Fatalf("entryAttributes() error = %v", err)
+			}
+			if len(attrs) != 2 || attrs[0].name != entryLevelRightsAttribute || attrs[1].name != attributeLevelRightsAttribute {
+				t.Fatalf("entryAttributes() = %+v, want entry and attribute rights", attrs)
+			}
+			if attrs[0].values[0] != tt.wantEntry {
+				t.Fatalf("entryLevelRights = %q, want %q", attrs[0].values[0], tt.wantEntry)
+			}
+			if attrs[1].values[0] != tt.wantAttrs {
+				t.Fatalf("attributeLevelRights = %q, want %q", attrs[1].values[0], tt.wantAttrs)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package server
+
+import (
+	"context"
+	"crypto/x509"
+	"log/slog"
+	"strings"
+
+	"github.com/smarzola/ldaplite/internal/ldapdn"
+	"github.com/smarzola/ldaplite/internal/protocol"
+	"github.com/smarzola/ldaplite/internal/protocol/ldapmsg"
+	"github.com/smarzola/ldaplite/internal/store"
+	"github.com/smarzola/ldaplite/pkg/config"
+)
+
+// SASL mechanism names (RFC 4422).
+const (
+	saslMechanismExternal    = "EXTERNAL"
+	saslMechanismPlain       = "PLAIN"
+	saslMechanismSCRAMSHA256 = "SCRAM-SHA-256"
+)
+
+// supportedSASLMechanisms lists the mechanisms advertised in the RootDSE.
+// EXTERNAL is only offered when clients can present verified certificates.
+func (s *Server) supportedSASLMechanisms() []string {
+	mechanisms := []string{saslMechanismPlain, saslMechanismSCRAMSHA256}
+	if s.cfg.Server.TLS.ClientAuth != "" && s.cfg.Server.TLS.ClientAuth != config.TLSClientAuthNone {
+		mechanisms = append(mechanisms, saslMechanismExternal)
+	}
+	return mechanisms
+}
+
+// saslBind performs a SASL bind and returns the response together with the
+// DN it authenticated, which is empty unless the bind succeeded, and the
+// password policy response control of password mechanisms. pending is the
+// state a multi-step mechanism saved on the connection after the previous
+// bind request, if any.
+func (s *Server) saslBind(ctx context.Context, conn *protocol.Connection, req ldapmsg.BindRequest, pending any) (ldapmsg.BindResponse, string, *ldapmsg.Control) {
+	switch strings.ToUpper(req.SASL.Mechanism) {
+	case saslMechanismExternal:
+		resp, dn := s.saslExternalBind(ctx, conn, req.SASL.Credentials)
+		return resp, dn, nil
+	case saslMechanismPlain:
+		return s.saslPlainBind(ctx, req.SASL.Credentials)
+	case saslMechanismSCRAMSHA256:
+		return s.saslSCRAMBind(ctx, conn, req.SASL.Credentials, pending)
+	default:
+		slog.Debug("Unsupported SASL mechanism", "mechanism", req.SASL.Mechanism)
+		return bindError(ldapmsg.ResultCodeAuthMethodNotSupported, "unsupported SASL mechanism"), "", nil
+	}
+}
+
+// saslExternalBind authenticates the client certificate verified during the
+// TLS handshake (RFC 4513 section 5.2.3). The certificate is mapped to an
+// entry through the configured DN template. An authorization identity in the
+// credentials must name that same entry.
+func (s *Server) saslExternalBind(ctx context.Context, conn *protocol.Connection, authzID *string) (ldapmsg.BindResponse, string) {
+	cert := conn.VerifiedClientCertificate()
+	if cert == nil {
+		return bindError(ldapmsg.ResultCodeInappropriateAuthentication, "EXTERNAL requires a verified TLS client certificate"), ""
+	}
+
+	dn, ok := mapCertificateDN(s.saslExternalMapping(), s.cfg.LDAP.BaseDN, cert)
+	if !ok {
+		slog.Info("SASL EXTERNAL certificate does not match the mapping", "subject", cert.Subject.String())
+		return bindError(ldapmsg.ResultCodeInvalidCredentials, ""), ""
+	}
+
+	entry, err := s.store.GetEntryWithOptions(ctx, dn, store.EntryOptions{IncludeMemberOf: false})
+	if err != nil {
+		slog.Error("Failed to get entry", "dn", dn, "error", err)
+		return bindError(ldapmsg.ResultCodeOperationsError, ""), ""
+	}
+	if entry == nil {
+		slog.Info("SASL EXTERNAL certificate maps to a missing entry", "subject", cert.Subject.String(), "dn", dn)
+		return bindError(ldapmsg.ResultCodeInvalidCredentials, ""), ""
+	}
+
+	if authzID != nil && *authzID != "" {
+		requested := strings.TrimPrefix(*authzID, "dn:")
+		if !strings.HasPrefix(*authzID, "dn:") || !ldapdn.Equal(requested, entry.DN) {
+			slog.Info("SASL EXTERNAL authorization identity rejected", "dn", entry.DN, "authzid", *authzID)
+			return bindError(ldapmsg.ResultCodeInvalidCredentials, "authorization identity does not match the certificate"), ""
+		}
+	}
+
+	slog.Debug("SASL EXTERNAL bind successful", "dn", entry.DN, "subject", cert.Subject.String())
+	return protocol.NewBindResponse(ldapmsg.ResultCodeSuccess), entry.DN
+}
+
+// saslPlainBind checks a PLAIN message (RFC 4616): an optional authorization
+// identity, the uid to authenticate and its password, separated by NUL bytes.
+func (s *Server) saslPlainBind(ctx context.Context, credentials *string) (ldapmsg.BindResponse, string, *ldapmsg.Control) {
+	if credentials == nil {
+		return bindError(ldapmsg.ResultCodeInvalidCredentials, "PLAIN requires credentials"), "", nil
+	}
+	parts := strings.Split(*credentials, "\x00")
+	if len(parts) != 3 || parts[1] == "" || parts[2] == "" {
+		return bindError(ldapmsg.ResultCodeInvalidCredentials, "malformed PLAIN credentials"), "", nil
+	}
+	authzID, authcID, password := parts[0], parts[1], parts[2]
+
+	passwordHash, dn, err := s.store.GetUserPasswordHash(ctx, authcID)
+	if err != nil {
+		slog.Error("Failed to get password hash", "uid", authcID, "error", err)
+		return bindError(ldapmsg.ResultCodeOperationsError, ""), "", nil
+	}
+	if passwordHash == "" || dn == "" {
+		slog.Debug("SASL PLAIN user not found", "uid", authcID)
+		return bindError(ldapmsg.ResultCodeInvalidCredentials, ""), "", nil
+	}
+	resultCode, policyControl := s.authenticatePassword(ctx, dn, s.verifyPassword(password, passwordHash))
+	if resultCode != ldapmsg.ResultCodeSuccess {
+		slog.Debug("SASL PLAIN password verification failed", "dn", dn)
+		return bindError(resultCode, ""), dn, policyControl
+	}
+	if !saslAuthorizationMatches(authzID, authcID, dn) {
+		slog.Info("SASL PLAIN authorization identity rejected", "dn", dn, "authzid", authzID)
+		return bindError(ldapmsg.ResultCodeInvalidCredentials, "authorization identity does not match the user"), "", nil
+	}
+
+	s.rehashPassword(ctx, dn, password, passwordHash)
+
+	slog.Debug("SASL PLAIN bind successful", "dn", dn)
+	return protocol.NewBindResponse(ldapmsg.ResultCodeSuccess), dn, policyControl
+}
+
+// saslAuthorizationMatches reports whether a requested authorization identity
+// names the authenticated user itself, as "dn:", "u:" or a bare uid. Acting
+// as another identity is not supported.
+func saslAuthorizationMatches(authzID, uid, dn string) bool {
+	switch {
+	case authzID == "":
+		return true
+	case strings.HasPrefix(authzID, "dn:"):
+		return ldapdn.Equal(strings.TrimPrefix(authzID, "dn:"), dn)
+	case strings.HasPrefix(authzID, "u:"):
+		return strings.TrimPrefix(authzID, "u:") == uid
+	default:
+		return authzID == uid
+	}
+}
+
+func (s *Server) saslExternalMapping() string {
+	if s.cfg.Security.SASLExternalMapping == "" {
+		return config.DefaultSASLExternalMapping
+	}
+	return s.cfg.Security.SASLExternalMapping
+}
+
+// mapCertificateDN fills a DN template from a client certificate. {cn} is the
+// subject common name, {email} and {dns} the first email and DNS subject
+// alternative names, {subject} the whole subject DN and {base} the directory
+// base DN. It reports false when the template uses a value the certificate
+// lacks.
+func mapCertificateDN(template, baseDN string, cert *x509.Certificate) (string, bool) {
+	values := map[string]string{
+		"{cn}":      ldapdn.EscapeValue(cert.Subject.CommonName),
+		"{subject}": cert.Subject.String(),
+		"{base}":    baseDN,
+	}
+	if len(cert.EmailAddresses) > 0 {
+		values["{email}"] = ldapdn.EscapeValue(cert.EmailAddresses[0])
+	}
+	if len(cert.DNSNames) > 0 {
+		values["{dns}"] = ldapdn.EscapeValue(cert.DNSNames[0])
+	}
+
+	var pairs []string
+	for _, placeholder := range []string{"{cn}", "{email}", "{dns}", "{subject}", "{base}"} {
+		if strings.Contains(template, placeholder) && values[placeholder] == "" {
+			return "", false
+		}
+		pairs = append(pairs, placeholder, values[placeholder])
+	}
+	// A single pass keeps certificate values from introducing placeholders.
+	return strings.NewReplacer(pairs...).Replace(template), true
+}
+
+func bindError(resultCode ldapmsg.ResultCode, diagnosticMessage string) ldapmsg.BindResponse {
+	resp := protocol.NewBindResponse(resultCode)
+	resp.DiagnosticMessage = diagnosticMessage
+	return resp
+}
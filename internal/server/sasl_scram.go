@@ -0,0 +1,194 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/smarzola/ldaplite/internal/protocol"
+	"github.com/smarzola/ldaplite/internal/protocol/ldapmsg"
+	"github.com/smarzola/ldaplite/pkg/crypto"
+)
+
+// scramExchange is the server state between the two SCRAM-SHA-256 bind
+// requests (RFC 5802 section 5).
+type scramExchange struct {
+	gs2Header       string
+	clientFirstBare string
+	serverFirst     string
+	nonce           string
+	authzID         string
+	uid             string
+	dn              string // empty when the user cannot use SCRAM
+	creds           crypto.SCRAMCredentials
+}
+
+// saslSCRAMBind runs one step of SCRAM-SHA-256. The client-first message
+// gets a saslBindInProgress challenge; the client-final message completes the
+// bind under the password policy and the response carries the server
+// signature.
+func (s *Server) saslSCRAMBind(ctx context.Context, conn *protocol.Connection, credentials *string, pending any) (ldapmsg.BindResponse, string, *ldapmsg.Control) {
+	if credentials == nil {
+		return bindError(ldapmsg.ResultCodeInvalidCredentials, "SCRAM-SHA-256 requires credentials"), "", nil
+	}
+	if exchange, ok := pending.(*scramExchange); ok {
+		var resp ldapmsg.BindResponse
+		var dn string
+		resultCode, policyControl := s.authenticatePassword(ctx, exchange.dn, func() (bool, error) {
+			resp, dn = exchange.finish(*credentials)
+			return resp.ResultCode == ldapmsg.ResultCodeSuccess, nil
+		})
+		switch {
+		case resultCode == ldapmsg.ResultCodeSuccess:
+			return resp, dn, policyControl
+		case resp.ResultCode == ldapmsg.ResultCodeSuccess:
+			// The proof was valid, or never checked because the account
+			// is locked, but the policy refused the bind.
+			return bindError(resultCode, ""), exchange.dn, policyControl
+		default:
+			return resp, exchange.dn, policyControl
+		}
+	}
+
+	exchange, resp, ok := s.startSCRAMExchange(ctx, *credentials)
+	if !ok {
+		return resp, "", nil
+	}
+	conn.SetSASLState(exchange)
+	return protocol.NewSASLBindResponse(ldapmsg.ResultCodeSaslBindInProgress, exchange.serverFirst), "", nil
+}
+
+func (s *Server) startSCRAMExchange(ctx context.Context, clientFirst string) (*scramExchange, ldapmsg.BindResponse, bool) {
+	// gs2-header: channel binding flag and optional authzid, then the bare
+	// client-first message.
+	flag, rest, ok := strings.Cut(clientFirst, ",")
+	if !ok {
+		return nil, bindError(ldapmsg.ResultCodeInvalidCredentials, "malformed SCRAM client-first message"), false
+	}
+	switch {
+	case flag == "n" || flag == "y":
+	case strings.HasPrefix(flag, "p="):
+		return nil, bindError(ldapmsg.ResultCodeAuthMethodNotSupported, "SCRAM channel binding is not supported"), false
+	default:
+		return nil, bindError(ldapmsg.ResultCodeInvalidCredentials, "malformed SCRAM client-first message"), false
+	}
+	authzField, clientFirstBare, ok := strings.Cut(rest, ",")
+	if !ok {
+		return nil, bindError(ldapmsg.ResultCodeInvalidCredentials, "malformed SCRAM client-first message"), false
+	}
+	exchange := &scramExchange{
+		gs2Header:       flag + "," + authzField + ",",
+		clientFirstBare: clientFirstBare,
+	}
+	if authzField != "" {
+		authzID, ok := strings.CutPrefix(authzField, "a=")
+		if !ok {
+			return nil, bindError(ldapmsg.ResultCodeInvalidCredentials, "malformed SCRAM client-first message"), false
+		}
+		exchange.authzID = decodeSCRAMName(authzID)
+	}
+
+	attrs := scramAttributes(clientFirstBare)
+	username, clientNonce := attrs["n"], attrs["r"]
+	if username == "" || clientNonce == "" || attrs["m"] != "" {
+		return nil, bindError(ldapmsg.ResultCodeInvalidCredentials, "malformed SCRAM client-first message"), false
+	}
+	exchange.uid = decodeSCRAMName(username)
+
+	secret, dn, err := s.store.GetUserSCRAMSecret(ctx, exchange.uid)
+	if err != nil {
+		slog.Error("Failed to get SCRAM secret", "uid", exchange.uid, "error", err)
+		return nil, bindError(ldapmsg.ResultCodeOperationsError, ""), false
+	}
+	if secret != "" {
+		exchange.creds, err = crypto.ParseSCRAMSecret(secret)
+		if err != nil {
+			slog.Error("Stored SCRAM secret is invalid", "dn", dn, "error", err)
+		} else {
+			exchange.dn = dn
+		}
+	}
+	if exchange.dn == "" {
+		// Carry on with made-up parameters so the challenge does not reveal
+		// whether the user exists; the proof check then fails.
+		slog.Debug("SASL SCRAM-SHA-256 user has no SCRAM keys", "uid", exchange.uid)
+		exchange.creds = s.fakeSCRAMCredentials(exchange.uid)
+	}
+
+	serverNonce := make([]byte, 18)
+	if _, err := rand.Read(serverNonce); err != nil {
+		slog.Error("Failed to generate SCRAM nonce", "error", err)
+		return nil, bindError(ldapmsg.ResultCodeOperationsError, ""), false
+	}
+	exchange.nonce = clientNonce + base64.StdEncoding.EncodeToString(serverNonce)
+	exchange.serverFirst = "r=" + exchange.nonce +
+		",s=" + base64.StdEncoding.EncodeToString(exchange.creds.Salt) +
+		",i=" + strconv.Itoa(exchange.creds.Iterations)
+	return exchange, ldapmsg.BindResponse{}, true
+}
+
+// fakeSCRAMCredentials returns the SCRAM parameters announced for a user
+// without SCRAM keys. The salt is an HMAC of the uid under a key generated at
+// startup: it stays the same for repeated attempts, like a real user's salt,
+// but cannot be computed outside the server. The iteration count is the one
+// real users get.
+func (s *Server) fakeSCRAMCredentials(uid string) crypto.SCRAMCredentials {
+	mac := hmac.New(sha256.New, s.scramSaltKey)
+	mac.Write([]byte(strings.ToLower(uid)))
+	return crypto.SCRAMCredentials{Iterations: crypto.SCRAMIterations, Salt: mac.Sum(nil)[:16]}
+}
+
+// finish verifies the client-final message and returns the server-final
+// message with the server signature.
+func (e *scramExchange) finish(clientFinal string) (ldapmsg.BindResponse, string) {
+	withoutProof, proofField, ok := strings.Cut(clientFinal, ",p=")
+	if !ok {
+		return bindError(ldapmsg.ResultCodeInvalidCredentials, "malformed SCRAM client-final message"), ""
+	}
+	attrs := scramAttributes(withoutProof)
+	if attrs["c"] != base64.StdEncoding.EncodeToString([]byte(e.gs2Header)) || attrs["r"] != e.nonce {
+		return bindError(ldapmsg.ResultCodeInvalidCredentials, "SCRAM channel binding or nonce mismatch"), ""
+	}
+	proof, err := base64.StdEncoding.DecodeString(proofField)
+	if err != nil {
+		return bindError(ldapmsg.ResultCodeInvalidCredentials, "malformed SCRAM client proof"), ""
+	}
+
+	authMessage := e.clientFirstBare + "," + e.serverFirst + "," + withoutProof
+	if e.dn == "" || !e.creds.VerifyClientProof(authMessage, proof) {
+		slog.Debug("SASL SCRAM-SHA-256 proof verification failed", "uid", e.uid)
+		return bindError(ldapmsg.ResultCodeInvalidCredentials, ""), ""
+	}
+	if !saslAuthorizationMatches(e.authzID, e.uid, e.dn) {
+		slog.Info("SASL SCRAM-SHA-256 authorization identity rejected", "dn", e.dn, "authzid", e.authzID)
+		return bindError(ldapmsg.ResultCodeInvalidCredentials, "authorization identity does not match the user"), ""
+	}
+
+	slog.Debug("SASL SCRAM-SHA-256 bind successful", "dn", e.dn)
+	serverFinal := "v=" + base64.StdEncoding.EncodeToString(e.creds.ServerSignature(authMessage))
+	return protocol.NewSASLBindResponse(ldapmsg.ResultCodeSuccess, serverFinal), e.dn
+}
+
+// scramAttributes splits a SCRAM message into its single-letter attributes.
+func scramAttributes(message string) map[string]string {
+	attrs := make(map[string]string)
+	for _, field := range strings.Split(message, ",") {
+		name, value, ok := strings.Cut(field, "=")
+		if ok && len(name) == 1 {
+			if _, seen := attrs[name]; !seen {
+				attrs[name] = value
+			}
+		}
+	}
+	return attrs
+}
+
+// decodeSCRAMName reverses the =2C and =3D escaping of SCRAM usernames.
+func decodeSCRAMName(name string) string {
+	return strings.NewReplacer("=2C", ",", "=3D", "=").Replace(name)
+}
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"testing"
+
+	"github.com/smarzola/ldaplite/internal/protocol/ldapmsg"
+	"github.com/smarzola/ldaplite/pkg/crypto"
+)
+
+func TestMapCertificateDN(t *testing.T) {
+	cert := &x509.Certificate{
+		Subject:        pkix.Name{CommonName: "Doe, Jane"},
+		EmailAddresses: []string{"jane@example.com"},
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+		wantOK   bool
+	}{
+		{name: "common name is escaped", template: "uid={cn},ou=users,{base}", want: `uid=Doe\, Jane,ou=users,dc=example,dc=com`, wantOK: true},
+		{name: "email SAN", template: "mail={email},ou=users,{base}", want: "mail=jane@example.com,ou=users,dc=example,dc=com", wantOK: true},
+		{name: "subject", template: "{subject}", want: `CN=Doe\, Jane`, wantOK: true},
+		{name: "missing DNS SAN", template: "cn={dns},ou=hosts,{base}", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := mapCertificateDN(tt.template, "dc=example,dc=com", cert)
+			if ok != tt.wantOK || got != tt.want {
+				t.Fatalf("mapCertificateDN() = %q, %v; want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestMapCertificateDNDoesNotExpandPlaceholdersFromCertificate(t *testing.T) {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "{base}"}}
+
+	got, ok := mapCertificateDN("uid={cn},{base}", "dc=example,dc=com", cert)
+	if !ok || got != "uid={base},dc=example,dc=com" {
+		t.Fatalf("mapCertificateDN() = %q, %v; want the literal common name", got, ok)
+	}
+}
+
+func TestSCRAMExchangeFinishMatchesRFC7677Example(t *testing.T) {
+	salt, err := base64.StdEncoding.DecodeString("W22ZaJ0SNY7soEsUEjb6gQ==")
+	if err != nil {
+		t.Fatal(err)
+	}
+	creds, err := crypto.DeriveSCRAMCredentials("pencil", salt, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const nonce = "rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0"
+	newExchange := func() *scramExchange {
+		return &scramExchange{
+			gs2Header:       "n,,",
+			clientFirstBare: "n=user,r=rOprNGfwEbeRWgbNEkqO",
+			serverFirst:     "r=" + nonce + ",s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096",
+			nonce:           nonce,
+			uid:             "user",
+			dn:              "uid=user,ou=users,dc=example,dc=com",
+			creds:           creds,
+		}
+	}
+
+	resp, dn := newExchange().finish("c=biws,r=" + nonce + ",p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ=")
+	if resp.ResultCode != ldapmsg.ResultCodeSuccess || dn != "uid=user,ou=users,dc=example,dc=com" {
+		t.Fatalf("finish() = %v, %q; want success", resp.ResultCode, dn)
+	}
+	if resp.ServerSASLCreds == nil || *resp.ServerSASLCreds != "v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4=" {
+		t.Fatalf("server-final = %v, want the RFC 7677 server signature", resp.ServerSASLCreds)
+	}
+
+	tests := map[string]string{
+		"wrong proof":   "c=biws,r=" + nonce + ",p=AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=",
+		"wrong nonce":   "c=biws,r=rOprNGfwEbeRWgbNEkqO,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ=",
+		"wrong binding": "c=eSws,r=" + nonce + ",p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ=",
+		"missing proof": "c=biws,r=" + nonce,
+	}
+	for name, clientFinal := range tests {
+		t.Run(name, func(t *testing.T) {
+			if resp, dn := newExchange().finish(clientFinal); resp.ResultCode != ldapmsg.ResultCodeInvalidCredentials || dn != "" {
+				t.Fatalf("finish() = %v, %q; want invalidCredentials", resp.ResultCode, dn)
+			}
+		})
+	}
+}
+
+func TestSASLAuthorizationMatches(t *testing.T) {
+	const dn = "uid=jane,ou=users,dc=example,dc=com"
+	tests := []struct {
+		authzID string
+		want    bool
+	}{
+		{authzID: "", want: true},
+		{authzID: "jane", want: true},
+		{authzID: "u:jane", want: true},
+		{authzID: "dn:UID=Jane,ou=users,dc=example,dc=com", want: true},
+		{authzID: "u:admin", want: false},
+		{authzID: "dn:uid=admin,ou=users,dc=example,dc=com", want: false},
+	}
+	for _, tt := range tests {
+		if got := saslAuthorizationMatches(tt.authzID, "jane", dn); got != tt.want {
+			t.Errorf("saslAuthorizationMatches(%q) = %v, want %v", tt.authzID, got, tt.want)
+		}
+	}
+}
+
+func TestFakeSCRAMCredentialsAreKeyedByServer(t *testing.T) {
+	srv := &Server{scramSaltKey: []byte("first server key")}
+	creds := srv.fakeSCRAMCredentials("ghost")
+	if creds.Iterations != crypto.SCRAMIterations || len(creds.Salt) != 16 {
+		t.Fatalf("fakeSCRAMCredentials() = %d iterations, %d byte salt; want %d and 16", creds.Iterations, len(creds.Salt), crypto.SCRAMIterations)
+	}
+	if again := srv.fakeSCRAMCredentials("GHOST"); !bytes.Equal(again.Salt, creds.Salt) {
+		t.Fatal("fakeSCRAMCredentials() salt changed between attempts")
+	}
+	other := &Server{scramSaltKey: []byte("second server key")}
+	if bytes.Equal(other.fakeSCRAMCredentials("ghost").Salt, creds.Salt) {
+		t.Fatal("fakeSCRAMCredentials() salt does not depend on the server key")
+	}
+}
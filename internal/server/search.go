@@ -2,15 +2,18 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"strings"
 	"time"
 
 	"github.com/smarzola/ldaplite/internal/audit"
+	"github.com/smarzola/ldaplite/internal/authz"
 	"github.com/smarzola/ldaplite/internal/models"
 	"github.com/smarzola/ldaplite/internal/protocol"
 	"github.com/smarzola/ldaplite/internal/protocol/ldapmsg"
+	"github.com/smarzola/ldaplite/internal/schema"
 	"github.com/smarzola/ldaplite/internal/store"
 	"github.com/smarzola/ldaplite/internal/telemetry"
 )
@@ -63,6 +66,12 @@ func (s *Server) handleSearch(ctx context.Context, conn *protocol.Connection, ms
 		return err
 	}
 
+	ctx, proxyResult, err := s.proxiedAuthorization(ctx, conn, msg)
+	if err != nil {
+		resultCode = proxyResult
+		return rejectProxiedAuthorization(conn, msg, proxyResult, err)
+	}
+
 	if !s.canSearch(conn, baseDN) {
 		slog.Info("Search rejected - bind required", "baseDN", baseDN)
 		resultCode = ldapmsg.ResultCodeInsufficientAccessRights
@@ -75,26 +84,273 @@ func (s *Server) handleSearch(ctx context.Context, conn *protocol.Connection, ms
 		filterStr = "(objectClass=*)"
 	}
 
-	slog.Debug("Search request", "baseDN", baseDN, "scope", scope, "filter", filterStr)
+	slog.Debug("Search request", "baseDN", baseDN, "scope", scope, "filter", filterStr, "derefAliases", searchReq.DerefAliases)
+
+	sizeLimit, timeLimit, err := s.searchLimits(ctx, conn, searchReq)
+	if err != nil {
+		slog.Error("Failed to resolve search limits", "error", err)
+		resultCode = ldapmsg.ResultCodeOperationsError
+		return conn.WriteResponse(msg.ID, protocol.NewSearchResultDone(ldapmsg.ResultCodeOperationsError))
+	}
+	searchCtx := ctx
+	if timeLimit > 0 {
+		var cancel context.CancelFunc
+		searchCtx, cancel = context.WithTimeout(ctx, timeLimit)
+		defer cancel()
+	}
+
+	searchFailed := func(err error) error {
+		if ctx.Err() != nil {
+			slog.Debug("Search abandoned", "baseDN", baseDN)
+			resultCode = ldapmsg.ResultCodeCanceled
+			return nil
+		}
+		if errors.Is(searchCtx.Err(), context.DeadlineExceeded) {
+			slog.Debug("Search time limit exceeded", "baseDN", baseDN, "timeLimit", timeLimit)
+			resultCode = ldapmsg.ResultCodeTimeLimitExceeded
+			return conn.WriteResponse(msg.ID, protocol.NewSearchResultDone(ldapmsg.ResultCodeTimeLimitExceeded))
+		}
+		slog.Error("Search error", "error", err)
+		resultCode = ldapmsg.ResultCodeOperationsError
+		return conn.WriteResponse(msg.ID, protocol.NewSearchResultDone(ldapmsg.ResultCodeOperationsError))
+	}
 
-	entries, err := s.store.SearchEntriesWithOptions(ctx, store.SearchOptions{
+	options := store.SearchOptions{
 		BaseDN:          baseDN,
 		Filter:          filterStr,
 		Scope:           scope,
 		IncludeMemberOf: selection.includes("memberOf"),
-	})
+	}
+
+	access := s.authorizer()
+	actor := operationActor(ctx, conn)
+	search, err := newVisibleSearch(searchCtx, s.store, access, actor, filterStr)
 	if err != nil {
-		slog.Error("Search error", "error", err)
-		resultCode = ldapmsg.ResultCodeOperationsError
-		return conn.WriteResponse(msg.ID, protocol.NewSearchResultDone(ldapmsg.ResultCodeOperationsError))
+		return searchFailed(err)
+	}
+
+	sortKeys, sortControl, sorted, err := sortRequest(msg)
+	if err != nil {
+		slog.Debug("Invalid sort control", "error", err)
+		resultCode = ldapmsg.ResultCodeProtocolError
+		return conn.WriteResponse(msg.ID, protocol.NewSearchResultDone(ldapmsg.ResultCodeProtocolError))
+	}
+	var controls []ldapmsg.Control
+	// sortControlIndex locates the sort response control in controls.
+	sortControlIndex := -1
+	if sorted {
+		sortResult := ldapmsg.ResultCodeSuccess
+		var sortAttribute string
+		var sortErr *store.SortKeyError
+		if err := store.ValidateSortKeys(sortKeys); errors.As(err, &sortErr) {
+			sortResult, sortAttribute = sortKeyResult(sortErr), sortErr.Attribute
+		}
+		sortControlIndex = len(controls)
+		if sortResult != ldapmsg.ResultCodeSuccess {
+			if sortControl.Criticality {
+				slog.Debug("Sorted search rejected", "baseDN", baseDN, "attribute", sortAttribute, "sortResult", sortResult)
+				resultCode = ldapmsg.ResultCodeUnavailableCriticalExtension
+				return conn.WriteResponse(msg.ID, protocol.NewSearchResultDone(ldapmsg.ResultCodeUnavailableCriticalExtension),
+					protocol.NewSortResponseControl(sortResult, sortAttribute))
+			}
+			// A non-critical sort control that cannot be honored leaves the
+			// results unsorted (RFC 2891 section 1.2).
+			controls = append(controls, protocol.NewSortResponseControl(sortResult, sortAttribute))
+		} else {
+			options.SortKeys = sortKeys
+			controls = append(controls, protocol.NewSortResponseControl(sortResult, ""))
+		}
+	}
+
+	paging, paged, err := pagedResultsRequest(msg)
+	if err != nil || paging.Size < 0 {
+		slog.Debug("Invalid paged results control", "error", err)
+		resultCode = ldapmsg.ResultCodeProtocolError
+		return conn.WriteResponse(msg.ID, protocol.NewSearchResultDone(ldapmsg.ResultCodeProtocolError))
+	}
+	vlv, viewed, err := virtualListViewRequest(msg)
+	if err != nil {
+		slog.Debug("Invalid virtual list view control", "error", err)
+		resultCode = ldapmsg.ResultCodeProtocolError
+		return conn.WriteResponse(msg.ID, protocol.NewSearchResultDone(ldapmsg.ResultCodeProtocolError))
+	}
+	if viewed && paged {
+		resultCode = ldapmsg.ResultCodeUnwillingToPerform
+		resp := protocol.NewSearchResultDone(ldapmsg.ResultCodeUnwillingToPerform)
+		resp.DiagnosticMessage = "virtual list view cannot be combined with paged results"
+		return conn.WriteResponse(msg.ID, resp)
+	}
+
+	rights, rightsResult, err := s.effectiveRightsRequest(ctx, conn, msg)
+	if err != nil {
+		slog.Debug("Get effective rights control rejected", "error", err)
+		resultCode = rightsResult
+		resp := protocol.NewSearchResultDone(rightsResult)
+		resp.DiagnosticMessage = err.Error()
+		return conn.WriteResponse(msg.ID, resp)
+	}
+
+	var fingerprint string
+	// returned counts the entries earlier pages of a paged search returned.
+	var returned int
+	if paged {
+		fingerprint = pagedSearchFingerprint(operationActor(ctx, conn).DN, searchReq, filterStr, sortKeys)
+		if paging.Cookie != "" {
+			cursor, ok := s.paging.take(conn.ID(), paging.Cookie)
+			if !ok || cursor.fingerprint != fingerprint {
+				slog.Debug("Paged search rejected - unknown cookie", "baseDN", baseDN)
+				resultCode = ldapmsg.ResultCodeUnwillingToPerform
+				resp := protocol.NewSearchResultDone(ldapmsg.ResultCodeUnwillingToPerform)
+				resp.DiagnosticMessage = "invalid paged results cookie"
+				return conn.WriteResponse(msg.ID, resp)
+			}
+			options.AfterEntryID = cursor.afterEntryID
+			options.Offset = cursor.offset
+			returned = cursor.returned
+		}
+		if paging.Size == 0 {
+			// A zero page size abandons the paged search (RFC 2696 section 3).
+			resultCode = ldapmsg.ResultCodeSuccess
+			count := 0
+			resultCount = &count
+			return conn.WriteResponse(msg.ID, protocol.NewSearchResultDone(ldapmsg.ResultCodeSuccess),
+				protocol.NewPagedResultsControl(0, ""))
+		}
+		// The size limit caps the whole paged search: the last page stops
+		// at the limit and ends the search with sizeLimitExceeded.
+		if sizeLimit > 0 && paging.Size > sizeLimit-returned {
+			paging.Size = sizeLimit - returned
+		}
+		// Fetch one extra entry to learn whether another page follows.
+		options.Limit = paging.Size + 1
+	} else if viewed {
+		// A virtual list view needs an ordered list; without a usable sort
+		// it is rejected (draft-ietf-ldapext-ldapv3-vlv section 6.2).
+		vlvResult := ldapmsg.ResultCodeSortControlMissing
+		if sorted {
+			vlvResult = ldapmsg.ResultCodeUnwillingToPerform
+		}
+		var target, contentCount int
+		if len(options.SortKeys) > 0 {
+			target, contentCount, vlvResult, err = virtualListViewWindow(searchCtx, search, &options, vlv)
+			if err != nil {
+				return searchFailed(err)
+			}
+		}
+		if vlvResult != ldapmsg.ResultCodeSuccess {
+			slog.Debug("Virtual list view rejected", "baseDN", baseDN, "result", vlvResult)
+			resultCode = vlvResult
+			return conn.WriteResponse(msg.ID, protocol.NewSearchResultDone(vlvResult),
+				append(controls, protocol.NewVirtualListViewResponseControl(target, contentCount, vlvResult, ""))...)
+		}
+		controls = append(controls, protocol.NewVirtualListViewResponseControl(target, contentCount, vlvResult, ""))
+		if sizeLimit > 0 && options.Limit > sizeLimit {
+			options.Limit = sizeLimit + 1
+		}
+	} else if sizeLimit > 0 {
+		options.Limit = sizeLimit + 1
+	}
+
+	entries, err := search.entries(searchCtx, options)
+	if err != nil {
+		return searchFailed(err)
+	}
+	if len(options.SortKeys) > 0 {
+		// Sorting on an attribute the actor cannot read on the returned
+		// entries would reveal the order of its values.
+		sortAttribute, err := search.unreadableSortKey(searchCtx, entries, options.SortKeys)
+		if err != nil {
+			return searchFailed(err)
+		}
+		if sortAttribute != "" {
+			rejected := protocol.NewSortResponseControl(ldapmsg.ResultCodeInsufficientAccessRights, sortAttribute)
+			// Pages and list views follow the sort order from their first
+			// request, so only a plain search can fall back to unsorted
+			// results.
+			if sortControl.Criticality || paged || viewed {
+				slog.Debug("Sorted search rejected", "baseDN", baseDN, "attribute", sortAttribute, "sortResult", ldapmsg.ResultCodeInsufficientAccessRights)
+				resultCode = ldapmsg.ResultCodeInsufficientAccessRights
+				if sortControl.Criticality {
+					resultCode = ldapmsg.ResultCodeUnavailableCriticalExtension
+				}
+				return conn.WriteResponse(msg.ID, protocol.NewSearchResultDone(resultCode), rejected)
+			}
+			controls[sortControlIndex] = rejected
+			options.SortKeys = nil
+			if entries, err = search.entries(searchCtx, options); err != nil {
+				return searchFailed(err)
+			}
+		}
+	}
+
+	doneCode := ldapmsg.ResultCodeSuccess
+	if paged {
+		var cookie string
+		if len(entries) > paging.Size {
+			entries = entries[:paging.Size]
+			returned += len(entries)
+			switch {
+			case sizeLimit > 0 && returned >= sizeLimit:
+				doneCode = ldapmsg.ResultCodeSizeLimitExceeded
+			case len(options.SortKeys) > 0:
+				// Sorted results are not in entry ID order, so sorted
+				// pages resume by position instead.
+				cookie = s.paging.save(conn.ID(), fingerprint, 0, options.Offset+len(entries), returned)
+			default:
+				cookie = s.paging.save(conn.ID(), fingerprint, entries[len(entries)-1].ID, 0, returned)
+			}
+		}
+		controls = append(controls, protocol.NewPagedResultsControl(0, cookie))
+	} else if sizeLimit > 0 && len(entries) > sizeLimit {
+		entries = entries[:sizeLimit]
+		doneCode = ldapmsg.ResultCodeSizeLimitExceeded
 	}
 
 	// Return matching entries
+	sent := 0
 	for _, entry := range entries {
+		if ctx.Err() != nil {
+			// Abandoned: the client expects no further responses.
+			slog.Debug("Search abandoned", "baseDN", baseDN, "sent", sent)
+			resultCode = ldapmsg.ResultCodeCanceled
+			resultCount = &sent
+			return nil
+		}
+		if searchCtx.Err() != nil {
+			slog.Debug("Search time limit exceeded", "baseDN", baseDN, "timeLimit", timeLimit, "sent", sent)
+			doneCode = ldapmsg.ResultCodeTimeLimitExceeded
+			break
+		}
+
 		// Build search result entry
 		result := protocol.NewSearchResultEntry(entry.DN)
 
-		for _, attr := range searchResponseAttributes(entry, selection) {
+		attrs := searchResponseAttributes(entry, selection)
+		if entry.IsUser() && selection.includesPasswordPolicyState() {
+			policyAttrs, err := s.passwordPolicyAttributes(ctx, entry.DN, selection)
+			if err != nil {
+				slog.Error("Failed to get password policy state", "dn", entry.DN, "error", err)
+				resultCode = ldapmsg.ResultCodeOperationsError
+				return conn.WriteResponse(msg.ID, protocol.NewSearchResultDone(ldapmsg.ResultCodeOperationsError))
+			}
+			attrs = append(attrs, policyAttrs...)
+		}
+		attrs, err = readableSearchAttributes(ctx, access, actor, entry, attrs)
+		if err != nil {
+			slog.Error("Failed to check read authorization", "dn", entry.DN, "error", err)
+			resultCode = ldapmsg.ResultCodeOperationsError
+			return conn.WriteResponse(msg.ID, protocol.NewSearchResultDone(ldapmsg.ResultCodeOperationsError))
+		}
+		if rights != nil {
+			rightsAttrs, err := rights.entryAttributes(ctx, access, entry, attrs, selection)
+			if err != nil {
+				slog.Error("Failed to check effective rights", "dn", entry.DN, "error", err)
+				resultCode = ldapmsg.ResultCodeOperationsError
+				return conn.WriteResponse(msg.ID, protocol.NewSearchResultDone(ldapmsg.ResultCodeOperationsError))
+			}
+			attrs = append(attrs, rightsAttrs...)
+		}
+		for _, attr := range attrs {
 			addSearchAttribute(&result, attr.name, attr.values, searchReq.TypesOnly)
 		}
 
@@ -102,13 +358,47 @@ func (s *Server) handleSearch(ctx context.Context, conn *protocol.Connection, ms
 		if err := conn.WriteResponse(msg.ID, result); err != nil {
 			return err
 		}
+		sent++
 	}
 
-	slog.Debug("Search completed", "baseDN", baseDN, "results", len(entries))
-	resultCode = ldapmsg.ResultCodeSuccess
-	count := len(entries)
-	resultCount = &count
-	return conn.WriteResponse(msg.ID, protocol.NewSearchResultDone(ldapmsg.ResultCodeSuccess))
+	slog.Debug("Search completed", "baseDN", baseDN, "results", sent, "resultCode", doneCode)
+	resultCode = doneCode
+	resultCount = &sent
+	return conn.WriteResponse(msg.ID, protocol.NewSearchResultDone(doneCode), controls...)
+}
+
+// searchLimits returns the effective size limit and time limit of a search.
+// Client-requested limits always apply. Administrative limits from the
+// configuration additionally cap them for every identity outside the admin
+// group. Limits follow the identity the search acts as, which is the proxied
+// identity under proxied authorization. Zero means unlimited.
+func (s *Server) searchLimits(ctx context.Context, conn *protocol.Connection, req ldapmsg.SearchRequest) (int, time.Duration, error) {
+	sizeLimit := req.SizeLimit
+	timeLimit := req.TimeLimit
+
+	if s.cfg != nil {
+		actorDN := operationActor(ctx, conn).DN
+		adminSize, adminTime := s.cfg.Limits.SearchLimitsFor(actorDN)
+		if adminSize > 0 || adminTime > 0 {
+			isAdmin, err := s.authorizer().IsAdmin(ctx, actorDN)
+			if err != nil {
+				return 0, 0, err
+			}
+			if !isAdmin {
+				sizeLimit = minSearchLimit(sizeLimit, adminSize)
+				timeLimit = minSearchLimit(timeLimit, adminTime)
+			}
+		}
+	}
+
+	return sizeLimit, time.Duration(timeLimit) * time.Second, nil
+}
+
+func minSearchLimit(requested, administrative int) int {
+	if requested == 0 || (administrative > 0 && administrative < requested) {
+		return administrative
+	}
+	return requested
 }
 
 func ldapSearchScope(scope ldapmsg.SearchScope) store.SearchScope {
@@ -208,7 +498,9 @@ func (s searchAttributeSelection) includes(attrName string) bool {
 
 func isOperationalAttribute(attrName string) bool {
 	switch strings.ToLower(attrName) {
-	case "createtimestamp", "entryuuid", "modifytimestamp", "memberof":
+	case "createtimestamp", "entryuuid", "modifytimestamp", "memberof",
+		"pwdchangedtime", "pwdfailuretime", "pwdaccountlockedtime",
+		"accountdisabled", "accountexpirationtime":
 		return true
 	default:
 		return false
@@ -257,6 +549,144 @@ func searchResponseAttributes(entry *models.Entry, selection searchAttributeSele
 	return attrs
 }
 
+// readableSearchAttributes drops the attributes of entry the access rules
+// do not let actor read.
+func readableSearchAttributes(ctx context.Context, access *authz.Authorizer, actor authz.Actor, entry *models.Entry, attrs []searchResponseAttribute) ([]searchResponseAttribute, error) {
+	if !access.HasAccessRules() {
+		return attrs, nil
+	}
+	readable := attrs[:0]
+	for _, attr := range attrs {
+		allowed, err := access.Allowed(ctx, actor, entry, attr.name, authz.PermissionRead)
+		if err != nil {
+			return nil, err
+		}
+		if allowed {
+			readable = append(readable, attr)
+		}
+	}
+	return readable, nil
+}
+
+// visibleSearchBatch is how many entries a search reads at a time when each
+// one needs an access check.
+const visibleSearchBatch = 256
+
+// visibleSearch runs the store queries of a search as actor sees them. With
+// access rules, the entries actor may not find are dropped before Offset and
+// Limit apply and before entries are counted, so pages, size limits and
+// virtual list view positions only cover entries actor can see. Subtrees the
+// rules hide are left to the store; other entries are checked one by one,
+// reading only as many as the window needs.
+type visibleSearch struct {
+	store  store.Store
+	access *authz.Authorizer
+	actor  authz.Actor
+	// filter is the parsed search filter; access rules decide whether actor
+	// may search on the attributes it tests.
+	filter       *schema.Filter
+	restrictions authz.SearchRestrictions
+}
+
+func newVisibleSearch(ctx context.Context, st store.Store, access *authz.Authorizer, actor authz.Actor, filter string) (visibleSearch, error) {
+	search := visibleSearch{store: st, access: access, actor: actor}
+	if !access.HasAccessRules() {
+		return search, nil
+	}
+	var err error
+	if search.filter, err = schema.ParseFilter(filter); err != nil {
+		return visibleSearch{}, err
+	}
+	search.restrictions, err = access.SearchRestrictions(ctx, actor, search.filter)
+	return search, err
+}
+
+// entries returns the entries of a search that actor may find.
+func (v visibleSearch) entries(ctx context.Context, options store.SearchOptions) ([]*models.Entry, error) {
+	options.ExcludeSubtrees = v.restrictions.HiddenSubtrees
+	if !v.restrictions.CheckEntries {
+		return v.store.SearchEntriesWithOptions(ctx, options)
+	}
+	skip, limit := options.Offset, options.Limit
+	var visible []*models.Entry
+	err := v.scan(ctx, options, func(entry *models.Entry) bool {
+		if skip > 0 {
+			skip--
+			return true
+		}
+		visible = append(visible, entry)
+		return limit == 0 || len(visible) < limit
+	})
+	return visible, err
+}
+
+// count counts the entries of a search that actor may find, ignoring Offset
+// and Limit.
+func (v visibleSearch) count(ctx context.Context, options store.SearchOptions) (int, error) {
+	options.ExcludeSubtrees = v.restrictions.HiddenSubtrees
+	if !v.restrictions.CheckEntries {
+		return v.store.CountEntriesWithOptions(ctx, options)
+	}
+	options.IncludeMemberOf = false
+	count := 0
+	err := v.scan(ctx, options, func(*models.Entry) bool {
+		count++
+		return true
+	})
+	return count, err
+}
+
+// scan reads the entries of a search in batches, ignoring Offset and Limit,
+// and calls visit with each one actor may find until visit returns false.
+func (v visibleSearch) scan(ctx context.Context, options store.SearchOptions, visit func(*models.Entry) bool) error {
+	options.Offset, options.Limit = 0, visibleSearchBatch
+	for {
+		batch, err := v.store.SearchEntriesWithOptions(ctx, options)
+		if err != nil {
+			return err
+		}
+		for _, entry := range batch {
+			allowed, err := v.access.CanSearch(ctx, v.actor, entry, v.filter)
+			if err != nil {
+				return err
+			}
+			if allowed && !visit(entry) {
+				return nil
+			}
+		}
+		if len(batch) < visibleSearchBatch {
+			return nil
+		}
+		// Unsorted results come in entry ID order and resume after the last
+		// one; sorted results resume by position.
+		if len(options.SortKeys) == 0 {
+			options.AfterEntryID = batch[len(batch)-1].ID
+		} else {
+			options.Offset += len(batch)
+		}
+	}
+}
+
+// unreadableSortKey returns the first sort key attribute that actor may not
+// read on one of entries, or "" when every key is readable.
+func (v visibleSearch) unreadableSortKey(ctx context.Context, entries []*models.Entry, keys []store.SortKey) (string, error) {
+	if !v.access.HasAccessRules() {
+		return "", nil
+	}
+	for _, entry := range entries {
+		for _, key := range keys {
+			allowed, err := v.access.Allowed(ctx, v.actor, entry, key.Attribute, authz.PermissionRead)
+			if err != nil {
+				return "", err
+			}
+			if !allowed {
+				return key.Attribute, nil
+			}
+		}
+	}
+	return "", nil
+}
+
 func isSearchProjectedAttribute(attrName string) bool {
 	switch strings.ToLower(attrName) {
 	case "objectclass", "createtimestamp", "modifytimestamp", "memberof":
@@ -318,6 +748,16 @@ func serializeFilter(f ldapmsg.Filter) string {
 	case ldapmsg.ApproxMatchFilter:
 		return fmt.Sprintf("(%s~=%s)", filter.Attribute, escapeLDAPFilterAssertionValue(filter.Value))
 
+	case ldapmsg.ExtensibleMatchFilter:
+		description := filter.Attribute
+		if filter.DNAttributes {
+			description += ":dn"
+		}
+		if filter.MatchingRule != "" {
+			description += ":" + filter.MatchingRule
+		}
+		return fmt.Sprintf("(%s:=%s)", description, escapeLDAPFilterAssertionValue(filter.Value))
+
 	case ldapmsg.SubstringsFilter:
 		attr := filter.Attribute
 		var sb strings.Builder
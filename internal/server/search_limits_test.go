@@ -0,0 +1,183 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/smarzola/ldaplite/internal/authz"
+	"github.com/smarzola/ldaplite/internal/models"
+	"github.com/smarzola/ldaplite/internal/protocol"
+	"github.com/smarzola/ldaplite/internal/protocol/ldapmsg"
+	"github.com/smarzola/ldaplite/pkg/config"
+)
+
+func TestSearchLimitsApplyAdministrativeCapsToNonAdmins(t *testing.T) {
+	const userDN = "uid=jane,ou=users,dc=example,dc=com"
+	const syncDN = "uid=sync,ou=users,dc=example,dc=com"
+
+	tests := []struct {
+		name      string
+		boundDN   string
+		admin     bool
+		request   ldapmsg.SearchRequest
+		wantSize  int
+		wantTime  time.Duration
+		wantCheck bool
+	}{
+		{
+			name:      "admin limits cap unlimited client request",
+			boundDN:   userDN,
+			request:   ldapmsg.SearchRequest{},
+			wantSize:  100,
+			wantTime:  10 * time.Second,
+			wantCheck: true,
+		},
+		{
+			name:      "smaller client limits win",
+			boundDN:   userDN,
+			request:   ldapmsg.SearchRequest{SizeLimit: 5, TimeLimit: 2},
+			wantSize:  5,
+			wantTime:  2 * time.Second,
+			wantCheck: true,
+		},
+		{
+			name:      "larger client limits are capped",
+			boundDN:   userDN,
+			request:   ldapmsg.SearchRequest{SizeLimit: 500, TimeLimit: 60},
+			wantSize:  100,
+			wantTime:  10 * time.Second,
+			wantCheck: true,
+		},
+		{
+			name:      "admins only get client limits",
+			boundDN:   userDN,
+			admin:     true,
+			request:   ldapmsg.SearchRequest{SizeLimit: 500},
+			wantSize:  500,
+			wantCheck: true,
+		},
+		{
+			name:      "identity override replaces global limits",
+			boundDN:   syncDN,
+			request:   ldapmsg.SearchRequest{},
+			wantSize:  5000,
+			wantCheck: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := &authzStore{admin: tt.admin}
+			srv := &Server{
+				cfg: &config.Config{
+					LDAP: config.LDAPConfig{BaseDN: "dc=example,dc=com"},
+					Limits: config.LimitsConfig{
+						SearchSizeLimit: 100,
+						SearchTimeLimit: 10,
+						IdentityLimits: []config.IdentityLimits{
+							{DN: syncDN, SearchSizeLimit: 5000},
+						},
+					},
+				},
+				store: st,
+			}
+			conn := protocol.NewConnection(nil, protocol.OperationHandlers{})
+			conn.SetBoundDN(tt.boundDN)
+
+			sizeLimit, timeLimit, err := srv.searchLimits(context.Background(), conn, tt.request)
+			if err != nil {
+				t.Fatalf("searchLimits() error = %v", err)
+			}
+			if sizeLimit != tt.wantSize || timeLimit != tt.wantTime {
+				t.Fatalf("searchLimits() = %d, %s, want %d, %s", sizeLimit, timeLimit, tt.wantSize, tt.wantTime)
+			}
+			if got := st.checks > 0; got != tt.wantCheck {
+				t.Fatalf("admin membership checked = %v, want %v", got, tt.wantCheck)
+			}
+		})
+	}
+}
+
+func TestSearchLimitsFollowProxiedIdentity(t *testing.T) {
+	const syncDN = "uid=sync,ou=users,dc=example,dc=com"
+	srv := &Server{
+		cfg: &config.Config{
+			LDAP: config.LDAPConfig{BaseDN: "dc=example,dc=com"},
+			Limits: config.LimitsConfig{
+				SearchSizeLimit: 100,
+				IdentityLimits: []config.IdentityLimits{
+					{DN: syncDN, SearchSizeLimit: 5000},
+				},
+			},
+		},
+		store: &authzStore{},
+	}
+	conn := protocol.NewConnection(nil, protocol.OperationHandlers{})
+	conn.SetBoundDN(syncDN)
+	ctx := context.WithValue(context.Background(), proxiedActorKey{}, authz.BoundUser("uid=jane,ou=users,dc=example,dc=com"))
+
+	sizeLimit, _, err := srv.searchLimits(ctx, conn, ldapmsg.SearchRequest{})
+	if err != nil {
+		t.Fatalf("searchLimits() error = %v", err)
+	}
+	if sizeLimit != 100 {
+		t.Fatalf("searchLimits() size = %d, want the proxied identity's 100", sizeLimit)
+	}
+}
+
+func TestSearchLimitsSkipAdminLookupWithoutAdministrativeLimits(t *testing.T) {
+	st := &authzStore{}
+	srv := &Server{
+		cfg:   &config.Config{LDAP: config.LDAPConfig{BaseDN: "dc=example,dc=com"}},
+		store: st,
+	}
+	conn := protocol.NewConnection(nil, protocol.OperationHandlers{})
+	conn.SetBoundDN("uid=jane,ou=users,dc=example,dc=com")
+
+	sizeLimit, timeLimit, err := srv.searchLimits(context.Background(), conn, ldapmsg.SearchRequest{SizeLimit: 3, TimeLimit: 4})
+	if err != nil {
+		t.Fatalf("searchLimits() error = %v", err)
+	}
+	if sizeLimit != 3 || timeLimit != 4*time.Second {
+		t.Fatalf("searchLimits() = %d, %s, want client limits", sizeLimit, timeLimit)
+	}
+	if st.checks != 0 {
+		t.Fatalf("admin membership checked %d times, want 0", st.checks)
+	}
+}
+
+func TestSearchSizeLimitReturnsPartialResults(t *testing.T) {
+	logs := captureAuditLogs(t)
+	serverConn, clientConn, cleanup := auditTestConnection(t)
+	defer cleanup()
+
+	srv := NewServer(auditTestConfig(), &auditStore{
+		searchEntries: []*models.Entry{
+			models.NewEntry("uid=jane,ou=users,dc=example,dc=com", "inetOrgPerson"),
+			models.NewEntry("uid=john,ou=users,dc=example,dc=com", "inetOrgPerson"),
+			models.NewEntry("uid=joe,ou=users,dc=example,dc=com", "inetOrgPerson"),
+		},
+	}, "test", nil)
+	conn := protocol.NewConnection(serverConn, protocol.OperationHandlers{})
+	conn.SetBoundDN("uid=admin,ou=users,dc=example,dc=com")
+	msg := &ldapmsg.Message{
+		ID: 12,
+		Op: ldapmsg.SearchRequest{
+			BaseObject: "dc=example,dc=com",
+			Scope:      ldapmsg.SearchScopeWholeSubtree,
+			SizeLimit:  2,
+			Filter:     ldapmsg.PresentFilter{Attribute: "objectClass"},
+		},
+	}
+
+	if err := srv.handleSearch(context.Background(), conn, msg); err != nil {
+		t.Fatalf("handleSearch() failed: %v", err)
+	}
+
+	got := logs.String()
+	assertLogContains(t, got, `"result_code":4`)
+	assertLogContains(t, got, `"result_count":2`)
+
+	_ = clientConn.Close()
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/smarzola/ldaplite/internal/audit"
+	"github.com/smarzola/ldaplite/internal/protocol"
+	"github.com/smarzola/ldaplite/internal/protocol/ldapmsg"
+	"github.com/smarzola/ldaplite/internal/throttle"
+)
+
+// bindAttempt identifies a bind request to the bind throttle.
+func bindAttempt(conn *protocol.Connection, msg *ldapmsg.Message, account string) throttle.Attempt {
+	return throttle.Attempt{
+		Component:  audit.ComponentLDAP,
+		RequestID:  audit.RequestID(conn.ID(), int(msg.ID)),
+		Account:    account,
+		RemoteAddr: conn.RemoteAddrString(),
+	}
+}
+
+// throttledBind returns the response for a bind the throttle did not let
+// through, or nil when the bind may go ahead.
+func throttledBind(err error) *ldapmsg.BindResponse {
+	if err == nil {
+		return nil
+	}
+	resp := bindError(ldapmsg.ResultCodeUnwillingToPerform, "too many failed bind attempts, try again later")
+	if !errors.Is(err, throttle.ErrThrottled) {
+		// The connection or server closed during the backoff delay.
+		slog.Debug("Bind abandoned during throttle delay", "error", err)
+		resp = bindError(ldapmsg.ResultCodeUnavailable, "")
+	}
+	return &resp
+}
+
+// recordBindResult feeds the outcome of a completed bind to the throttle.
+// Only wrong credentials count as failures; busy or unavailable results say
+// nothing about the password.
+func (s *Server) recordBindResult(ctx context.Context, attempt throttle.Attempt, resultCode ldapmsg.ResultCode) {
+	switch resultCode {
+	case ldapmsg.ResultCodeSuccess:
+		s.throttler.Success(attempt)
+	case ldapmsg.ResultCodeInvalidCredentials:
+		s.throttler.Failure(ctx, attempt)
+	}
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"context"
+	"errors"
+
+	"github.com/smarzola/ldaplite/internal/protocol"
+	"github.com/smarzola/ldaplite/internal/protocol/ldapmsg"
+	"github.com/smarzola/ldaplite/internal/store"
+)
+
+// virtualListViewRequest decodes the virtual list view control of a search
+// request.
+func virtualListViewRequest(msg *ldapmsg.Message) (protocol.VirtualListView, bool, error) {
+	control, ok := msg.Control(protocol.VirtualListViewRequestControlOID)
+	if !ok {
+		return protocol.VirtualListView{}, false, nil
+	}
+	vlv, err := protocol.DecodeVirtualListViewControl(control)
+	if err != nil {
+		return protocol.VirtualListView{}, true, err
+	}
+	return vlv, true, nil
+}
+
+// virtualListViewWindow locates the target entry of a virtual list view
+// request and narrows options to the entries around it. Positions and the
+// content count only cover the entries the search's actor can see. It returns
+// the 1-based target position and the content count for the response
+// control. A result other than success means the request cannot be served;
+// err is reserved for store failures.
+func virtualListViewWindow(ctx context.Context, search visibleSearch, options *store.SearchOptions, vlv protocol.VirtualListView) (int, int, ldapmsg.ResultCode, error) {
+	contentCount, err := search.count(ctx, *options)
+	if err != nil {
+		return 0, 0, ldapmsg.ResultCodeOperationsError, err
+	}
+
+	var target int
+	if vlv.GreaterThanOrEqual != nil {
+		before := *options
+		before.SortValueBefore = vlv.GreaterThanOrEqual
+		preceding, err := search.count(ctx, before)
+		var sortErr *store.SortKeyError
+		if errors.As(err, &sortErr) {
+			return 0, contentCount, sortKeyResult(sortErr), nil
+		}
+		if err != nil {
+			return 0, 0, ldapmsg.ResultCodeOperationsError, err
+		}
+		// When every entry sorts before the value the target is one past
+		// the end of the list.
+		target = preceding + 1
+	} else {
+		if vlv.Offset == 0 {
+			return 0, contentCount, ldapmsg.ResultCodeOffsetRangeError, nil
+		}
+		target = vlvOffsetPosition(vlv.Offset, vlv.ContentCount, contentCount)
+	}
+
+	start := max(target-vlv.BeforeCount, 1)
+	options.Offset = start - 1
+	options.Limit = target + vlv.AfterCount - start + 1
+	return target, contentCount, ldapmsg.ResultCodeSuccess, nil
+}
+
+// vlvOffsetPosition scales a client offset to the server's content count.
+// The client's content count is its estimate of the list size; zero means it
+// has none and the offset is used as is. The first and last client positions
+// always map to the first and last entries.
+func vlvOffsetPosition(offset, clientCount, contentCount int) int {
+	if contentCount == 0 {
+		return 0
+	}
+	position := offset
+	switch {
+	case clientCount == 0 || offset == 1:
+	case offset >= clientCount:
+		position = contentCount
+	default:
+		position = offset * contentCount / clientCount
+	}
+	return min(max(position, 1), contentCount)
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/smarzola/ldaplite/internal/authz"
+	"github.com/smarzola/ldaplite/internal/models"
+	"github.com/smarzola/ldaplite/internal/protocol"
+	"github.com/smarzola/ldaplite/internal/protocol/ldapmsg"
+	"github.com/smarzola/ldaplite/internal/store"
+	"github.com/smarzola/ldaplite/pkg/config"
+)
+
+func TestVLVOffsetPositionScalesClientEstimate(t *testing.T) {
+	tests := []struct {
+		name                              string
+		offset, clientCount, contentCount int
+		want                              int
+	}{
+		{name: "no client estimate", offset: 5, contentCount: 100, want: 5},
+		{name: "offset past end", offset: 500, contentCount: 100, want: 100},
+		{name: "first entry", offset: 1, clientCount: 50, contentCount: 100, want: 1},
+		{name: "last entry", offset: 50, clientCount: 50, contentCount: 100, want: 100},
+		{name: "scaled middle", offset: 25, clientCount: 50, contentCount: 100, want: 50},
+		{name: "scaled down", offset: 2, clientCount: 100, contentCount: 10, want: 1},
+		{name: "empty list", offset: 3, clientCount: 10, contentCount: 0, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := vlvOffsetPosition(tt.offset, tt.clientCount, tt.contentCount); got != tt.want {
+				t.Fatalf("vlvOffsetPosition(%d, %d, %d) = %d, want %d", tt.offset, tt.clientCount, tt.contentCount, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVisibleSearchWindowsOnlyVisibleEntries(t *testing.T) {
+	srv := testAuthzServer(false)
+	srv.cfg.Authz.AccessRules = []config.AccessRule{
+		{Name: "hidden", Effect: "deny", Subject: "users", Subtree: "ou=hidden,dc=example,dc=com", Permissions: []string{"search"}},
+		{Name: "private-mobile", Effect: "deny", Subject: "users", Attributes: []string{"mobile"}, Permissions: []string{"read"}},
+	}
+	var results []*models.Entry
+	for i, dn := range []string{
+		"uid=ana,ou=users,dc=example,dc=com",
+		"uid=bea,ou=hidden,dc=example,dc=com",
+		"uid=bob,ou=users,dc=example,dc=com",
+		"uid=cid,ou=hidden,dc=example,dc=com",
+		"uid=eve,ou=users,dc=example,dc=com",
+	} {
+		entry := models.NewEntry(dn, string(models.ObjectClassInetOrgPerson))
+		entry.ID = int64(i + 1)
+		results = append(results, entry)
+	}
+	ctx := context.Background()
+	search, err := newVisibleSearch(ctx, &authzStore{results: results}, srv.authorizer(), authz.BoundUser("uid=jane,ou=users,dc=example,dc=com"), "(objectClass=*)")
+	if err != nil {
+		t.Fatalf("newVisibleSearch() error = %v", err)
+	}
+	// The hidden subtree is left to the store; nothing else needs checks.
+	if search.restrictions.CheckEntries || len(search.restrictions.HiddenSubtrees) != 1 {
+		t.Fatalf("restrictions = %+v, want only the hidden subtree", search.restrictions)
+	}
+
+	page, err := search.entries(ctx, store.SearchOptions{Offset: 1, Limit: 1})
+	if err != nil || len(page) != 1 || page[0].DN != "uid=bob,ou=users,dc=example,dc=com" {
+		t.Fatalf("entries(offset 1, limit 1) = %v, %v; want bob", page, err)
+	}
+
+	options := store.SearchOptions{SortKeys: []store.SortKey{{Attribute: "cn"}}}
+	target, contentCount, result, err := virtualListViewWindow(ctx, search, &options, protocol.VirtualListView{Offset: 3})
+	if err != nil || result != ldapmsg.ResultCodeSuccess || target != 3 || contentCount != 3 {
+		t.Fatalf("virtualListViewWindow() = %d, %d, %d, %v; want target 3 of 3", target, contentCount, result, err)
+	}
+
+	if attribute, err := search.unreadableSortKey(ctx, page, options.SortKeys); err != nil || attribute != "" {
+		t.Fatalf("unreadableSortKey(cn) = %q, %v; want none", attribute, err)
+	}
+	mobile := []store.SortKey{{Attribute: "cn"}, {Attribute: "mobile"}}
+	if attribute, err := search.unreadableSortKey(ctx, page, mobile); err != nil || attribute != "mobile" {
+		t.Fatalf("unreadableSortKey(mobile) = %q, %v; want mobile", attribute, err)
+	}
+}
+
+func TestVisibleSearchStopsReadingOnceTheWindowIsFull(t *testing.T) {
+	srv := testAuthzServer(false)
+	srv.cfg.Authz.AccessRules = []config.AccessRule{
+		{Name: "hidden-services", Effect: "deny", Subject: "users", Filter: "(employeeType=service)", Permissions: []string{"search"}},
+	}
+	// Every other entry is a service account the rule hides.
+	var results []*models.Entry
+	for i := range 4 * visibleSearchBatch {
+		entry := models.NewEntry(fmt.Sprintf("uid=user%d,ou=users,dc=example,dc=com", i), string(models.ObjectClassInetOrgPerson))
+		entry.ID = int64(i + 1)
+		if i%2 == 1 {
+			entry.SetAttribute("employeeType", "service")
+		}
+		results = append(results, entry)
+	}
+	st := &authzStore{results: results}
+	ctx := context.Background()
+	search, err := newVisibleSearch(ctx, st, srv.authorizer(), authz.BoundUser("uid=jane,ou=users,dc=example,dc=com"), "(objectClass=*)")
+	if err != nil {
+		t.Fatalf("newVisibleSearch() error = %v", err)
+	}
+	if !search.restrictions.CheckEntries {
+		t.Fatalf("restrictions = %+v, want entry checks", search.restrictions)
+	}
+
+	page, err := search.entries(ctx, store.SearchOptions{Offset: 2, Limit: 3})
+	if err != nil || len(page) != 3 || page[0].DN != "uid=user4,ou=users,dc=example,dc=com" || page[2].DN != "uid=user8,ou=users,dc=example,dc=com" {
+		t.Fatalf("entries(offset 2, limit 3) = %v, %v; want user4 to user8", page, err)
+	}
+	if st.searches != 1 {
+		t.Fatalf("store searches = %d, want 1 batch", st.searches)
+	}
+
+	count, err := search.count(ctx, store.SearchOptions{})
+	if err != nil || count != 2*visibleSearchBatch {
+		t.Fatalf("count() = %d, %v; want %d", count, err, 2*visibleSearchBatch)
+	}
+}
@@ -2,7 +2,11 @@ package server
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
+	"maps"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
@@ -14,6 +18,7 @@ import (
 	"github.com/smarzola/ldaplite/internal/protocol/ldapmsg"
 	"github.com/smarzola/ldaplite/internal/store"
 	"github.com/smarzola/ldaplite/internal/telemetry"
+	"github.com/smarzola/ldaplite/pkg/crypto"
 )
 
 // handleAdd handles add operations
@@ -36,15 +41,29 @@ func (s *Server) handleAdd(ctx context.Context, conn *protocol.Connection, msg *
 		})
 	}()
 
+	ctx, proxyResult, err := s.proxiedAuthorization(ctx, conn, msg)
+	if err != nil {
+		resultCode = proxyResult
+		return rejectProxiedAuthorization(conn, msg, proxyResult, err)
+	}
+
 	slog.Debug("Add request", "dn", dn)
 
-	canWrite, err := s.canWrite(ctx, conn)
+	controls, err := decodeWriteControls(msg)
+	if err != nil {
+		slog.Debug("Invalid write controls", "dn", dn, "error", err)
+		resultCode = ldapmsg.ResultCodeProtocolError
+		return conn.WriteResponse(msg.ID, protocol.NewAddResponse(ldapmsg.ResultCodeProtocolError))
+	}
+
+	attrs := addRequestAttributes(addReq.Attributes)
+	canAdd, err := s.authorizer().Allowed(ctx, operationActor(ctx, conn), addAccessTarget(dn, attrs), "", authz.PermissionAdd)
 	if err != nil {
 		slog.Error("Failed to check write authorization", "dn", dn, "error", err)
 		resultCode = ldapmsg.ResultCodeOperationsError
 		return conn.WriteResponse(msg.ID, protocol.NewAddResponse(ldapmsg.ResultCodeOperationsError))
 	}
-	if !canWrite {
+	if !canAdd {
 		slog.Info("Add rejected - write access denied", "dn", dn)
 		resultCode = ldapmsg.ResultCodeInsufficientAccessRights
 		return conn.WriteResponse(msg.ID, protocol.NewAddResponse(ldapmsg.ResultCodeInsufficientAccessRights))
@@ -64,10 +83,24 @@ func (s *Server) handleAdd(ctx context.Context, conn *protocol.Connection, msg *
 		return conn.WriteResponse(msg.ID, protocol.NewAddResponse(ldapmsg.ResultCodeEntryAlreadyExists))
 	}
 
-	entry, resultCode, err := s.newAddEntry(dn, addRequestAttributes(addReq.Attributes))
+	if err := s.checkPreHashedPasswords(ctx, conn, dn, addPasswordValues(attrs)); err != nil {
+		policyControl := passwordPolicyError(err)
+		if policyControl == nil {
+			slog.Error("Failed to check password authorization", "dn", dn, "error", err)
+			resultCode = ldapmsg.ResultCodeOperationsError
+			return conn.WriteResponse(msg.ID, protocol.NewAddResponse(ldapmsg.ResultCodeOperationsError))
+		}
+		slog.Debug("Pre-hashed password rejected", "dn", dn)
+		resultCode = ldapmsg.ResultCodeConstraintViolation
+		resp := protocol.NewAddResponse(ldapmsg.ResultCodeConstraintViolation)
+		resp.DiagnosticMessage = err.Error()
+		return conn.WriteResponse(msg.ID, resp, passwordPolicyControls(msg, policyControl)...)
+	}
+
+	entry, resultCode, err := s.newAddEntry(dn, attrs)
 	if err != nil {
 		slog.Debug("Invalid add request", "dn", dn, "error", err)
-		return conn.WriteResponse(msg.ID, protocol.NewAddResponse(resultCode))
+		return conn.WriteResponse(msg.ID, protocol.NewAddResponse(resultCode), passwordPolicyControls(msg, passwordPolicyError(err))...)
 	}
 	if resultCode != ldapmsg.ResultCodeSuccess {
 		if resultCode == ldapmsg.ResultCodeUnwillingToPerform {
@@ -78,8 +111,10 @@ func (s *Server) handleAdd(ctx context.Context, conn *protocol.Connection, msg *
 
 	slog.Debug("Creating entry", "dn", dn, "objectClass", entry.ObjectClass)
 
-	// Store entry
-	if err := s.store.CreateEntry(ctx, entry); err != nil {
+	// Store entry. The requester supplies every attribute of a new entry,
+	// so its assertion needs no access check.
+	result, err := s.store.CreateEntryWithOptions(ctx, entry, controls.options)
+	if err != nil {
 		slog.Error("Failed to create entry", "dn", dn, "error", err)
 		resultCode = entryWriteResultCode(err)
 		return conn.WriteResponse(msg.ID, protocol.NewAddResponse(entryWriteResultCode(err)))
@@ -87,7 +122,7 @@ func (s *Server) handleAdd(ctx context.Context, conn *protocol.Connection, msg *
 
 	slog.Info("Entry created", "dn", dn)
 	resultCode = ldapmsg.ResultCodeSuccess
-	return conn.WriteResponse(msg.ID, protocol.NewAddResponse(ldapmsg.ResultCodeSuccess))
+	return conn.WriteResponse(msg.ID, protocol.NewAddResponse(ldapmsg.ResultCodeSuccess), s.readEntryControls(ctx, conn, controls, result)...)
 }
 
 // handleDelete handles delete operations
@@ -109,15 +144,37 @@ func (s *Server) handleDelete(ctx context.Context, conn *protocol.Connection, ms
 		})
 	}()
 
+	ctx, proxyResult, err := s.proxiedAuthorization(ctx, conn, msg)
+	if err != nil {
+		resultCode = proxyResult
+		return rejectProxiedAuthorization(conn, msg, proxyResult, err)
+	}
+
 	slog.Debug("Delete request", "dn", dn)
 
-	canWrite, err := s.canWrite(ctx, conn)
+	controls, err := decodeWriteControls(msg)
+	if err != nil {
+		slog.Debug("Invalid write controls", "dn", dn, "error", err)
+		resultCode = ldapmsg.ResultCodeProtocolError
+		return conn.WriteResponse(msg.ID, protocol.NewDelResponse(ldapmsg.ResultCodeProtocolError))
+	}
+
+	_, treeDelete := msg.Control(protocol.TreeDeleteControlOID)
+	canDelete, err := s.canWriteEntry(ctx, conn, dn, authz.PermissionDelete)
+	if err == nil && canDelete && treeDelete {
+		// A tree delete skips the access checks of every descendant, so it
+		// is for administrators only.
+		canDelete, err = s.authorizer().IsAdmin(ctx, operationActor(ctx, conn).DN)
+	}
+	if err == nil && canDelete {
+		canDelete, err = s.canAssert(ctx, conn, dn, controls)
+	}
 	if err != nil {
 		slog.Error("Failed to check write authorization", "dn", dn, "error", err)
 		resultCode = ldapmsg.ResultCodeOperationsError
 		return conn.WriteResponse(msg.ID, protocol.NewDelResponse(ldapmsg.ResultCodeOperationsError))
 	}
-	if !canWrite {
+	if !canDelete {
 		slog.Info("Delete rejected - write access denied", "dn", dn)
 		resultCode = ldapmsg.ResultCodeInsufficientAccessRights
 		return conn.WriteResponse(msg.ID, protocol.NewDelResponse(ldapmsg.ResultCodeInsufficientAccessRights))
@@ -137,15 +194,157 @@ func (s *Server) handleDelete(ctx context.Context, conn *protocol.Connection, ms
 		return conn.WriteResponse(msg.ID, protocol.NewDelResponse(ldapmsg.ResultCodeNoSuchObject))
 	}
 
-	if err := s.store.DeleteEntry(ctx, dn); err != nil {
+	var result store.WriteResult
+	if treeDelete {
+		var deleted store.SubtreeDeleteResult
+		deleted, err = s.store.DeleteSubtree(ctx, dn, store.SubtreeDeleteOptions{WriteOptions: controls.options})
+		result = deleted.WriteResult
+		if err == nil {
+			slog.Info("Subtree deleted", "dn", dn, "entries", len(deleted.DNs), "groups", len(deleted.Groups))
+		}
+	} else {
+		result, err = s.store.DeleteEntryWithOptions(ctx, dn, controls.options)
+	}
+	if err != nil {
 		slog.Error("Failed to delete entry", "dn", dn, "error", err)
-		resultCode = ldapmsg.ResultCodeOperationsError
-		return conn.WriteResponse(msg.ID, protocol.NewDelResponse(ldapmsg.ResultCodeOperationsError))
+		resultCode = entryWriteResultCode(err)
+		return conn.WriteResponse(msg.ID, protocol.NewDelResponse(resultCode))
 	}
 
 	slog.Info("Entry deleted", "dn", dn)
 	resultCode = ldapmsg.ResultCodeSuccess
-	return conn.WriteResponse(msg.ID, protocol.NewDelResponse(ldapmsg.ResultCodeSuccess))
+	return conn.WriteResponse(msg.ID, protocol.NewDelResponse(ldapmsg.ResultCodeSuccess), s.readEntryControls(ctx, conn, controls, result)...)
+}
+
+// handleModifyDN handles modify DN (rename and move) operations
+func (s *Server) handleModifyDN(ctx context.Context, conn *protocol.Connection, msg *ldapmsg.Message) error {
+	start := time.Now()
+	modDNReq := msg.Op.(ldapmsg.ModifyDNRequest)
+	dn := modDNReq.Entry
+	resultCode := ldapmsg.ResultCodeOperationsError
+	ctx, span := telemetry.StartLDAPSpan(ctx, "modifydn")
+	defer func() {
+		telemetry.EndLDAPSpan(span, int(resultCode))
+	}()
+	defer func() {
+		s.auditLDAPOperation(ctx, conn, msg, "modifydn", audit.LDAPEvent{
+			ActorDN:    conn.GetBoundDN(),
+			TargetDN:   dn,
+			ResultCode: int(resultCode),
+			Duration:   time.Since(start),
+		})
+	}()
+
+	ctx, proxyResult, err := s.proxiedAuthorization(ctx, conn, msg)
+	if err != nil {
+		resultCode = proxyResult
+		return rejectProxiedAuthorization(conn, msg, proxyResult, err)
+	}
+
+	slog.Debug("ModifyDN request", "dn", dn, "newRDN", modDNReq.NewRDN, "deleteOldRDN", modDNReq.DeleteOldRDN)
+
+	controls, err := decodeWriteControls(msg)
+	if err != nil {
+		slog.Debug("Invalid write controls", "dn", dn, "error", err)
+		resultCode = ldapmsg.ResultCodeProtocolError
+		return conn.WriteResponse(msg.ID, protocol.NewModifyDNResponse(ldapmsg.ResultCodeProtocolError))
+	}
+
+	access := s.authorizer()
+	actor := operationActor(ctx, conn)
+	target, err := s.accessTarget(ctx, access, dn)
+	canRename := false
+	if err == nil {
+		// A rename removes the entry from its old place and adds it at the
+		// new one.
+		canRename, err = access.Allowed(ctx, actor, target, "", authz.PermissionDelete)
+	}
+	if err == nil && canRename && controls.assertion != nil && access.HasAccessRules() {
+		canRename, err = access.CanSearch(ctx, actor, target, controls.assertion)
+	}
+	if err != nil {
+		slog.Error("Failed to check write authorization", "dn", dn, "error", err)
+		resultCode = ldapmsg.ResultCodeOperationsError
+		return conn.WriteResponse(msg.ID, protocol.NewModifyDNResponse(ldapmsg.ResultCodeOperationsError))
+	}
+	if !canRename {
+		slog.Info("ModifyDN rejected - write access denied", "dn", dn)
+		resultCode = ldapmsg.ResultCodeInsufficientAccessRights
+		return conn.WriteResponse(msg.ID, protocol.NewModifyDNResponse(ldapmsg.ResultCodeInsufficientAccessRights))
+	}
+
+	options, resultCode := modifyDNRenameOptions(modDNReq)
+	if resultCode != ldapmsg.ResultCodeSuccess {
+		slog.Debug("Invalid modify DN request", "dn", dn, "newRDN", modDNReq.NewRDN)
+		return conn.WriteResponse(msg.ID, protocol.NewModifyDNResponse(resultCode))
+	}
+
+	canRename, err = access.Allowed(ctx, actor, renamedAccessTarget(target, options), "", authz.PermissionAdd)
+	if err != nil {
+		slog.Error("Failed to check write authorization", "dn", dn, "error", err)
+		resultCode = ldapmsg.ResultCodeOperationsError
+		return conn.WriteResponse(msg.ID, protocol.NewModifyDNResponse(ldapmsg.ResultCodeOperationsError))
+	}
+	if !canRename {
+		slog.Info("ModifyDN rejected - write access denied at new location", "dn", dn)
+		resultCode = ldapmsg.ResultCodeInsufficientAccessRights
+		return conn.WriteResponse(msg.ID, protocol.NewModifyDNResponse(ldapmsg.ResultCodeInsufficientAccessRights))
+	}
+
+	newDN, result, err := s.store.RenameEntryWithOptions(ctx, dn, options, controls.options)
+	if err != nil {
+		slog.Error("Failed to rename entry", "dn", dn, "error", err)
+		resultCode = entryWriteResultCode(err)
+		return conn.WriteResponse(msg.ID, protocol.NewModifyDNResponse(resultCode))
+	}
+
+	slog.Info("Entry renamed", "dn", dn, "newDN", newDN)
+	resultCode = ldapmsg.ResultCodeSuccess
+	return conn.WriteResponse(msg.ID, protocol.NewModifyDNResponse(ldapmsg.ResultCodeSuccess), s.readEntryControls(ctx, conn, controls, result)...)
+}
+
+// modifyDNRenameOptions validates the client-supplied RDN and superior before
+// they reach the store.
+func modifyDNRenameOptions(req ldapmsg.ModifyDNRequest) (store.RenameOptions, ldapmsg.ResultCode) {
+	name, value, ok := ldapdn.SplitRDN(req.NewRDN)
+	if !ok || value == "" {
+		return store.RenameOptions{}, ldapmsg.ResultCodeInvalidDNSyntax
+	}
+	if isModifyProtectedAttribute(name) || strings.EqualFold(name, "userPassword") {
+		return store.RenameOptions{}, ldapmsg.ResultCodeUnwillingToPerform
+	}
+
+	options := store.RenameOptions{
+		NewRDN:       req.NewRDN,
+		DeleteOldRDN: req.DeleteOldRDN,
+	}
+	if req.NewSuperior != nil {
+		if strings.TrimSpace(*req.NewSuperior) == "" {
+			return store.RenameOptions{}, ldapmsg.ResultCodeUnwillingToPerform
+		}
+		options.NewSuperior = *req.NewSuperior
+	}
+	return options, ldapmsg.ResultCodeSuccess
+}
+
+// renamedAccessTarget is target as a ModifyDN would leave it, for the access
+// check at its new location.
+func renamedAccessTarget(target *models.Entry, options store.RenameOptions) *models.Entry {
+	parent := options.NewSuperior
+	if parent == "" {
+		parent = ldapdn.Parent(target.DN)
+	}
+	renamed := *target
+	renamed.DN = options.NewRDN + "," + parent
+	renamed.ParentDN = parent
+	renamed.Attributes = maps.Clone(target.Attributes)
+	if renamed.Attributes == nil {
+		renamed.Attributes = make(map[string][]string)
+	}
+	if name, value, ok := ldapdn.SplitRDN(options.NewRDN); ok {
+		renamed.AddAttribute(name, value)
+	}
+	return &renamed
 }
 
 // handleModify handles modify operations
@@ -168,9 +367,25 @@ func (s *Server) handleModify(ctx context.Context, conn *protocol.Connection, ms
 		})
 	}()
 
+	ctx, proxyResult, err := s.proxiedAuthorization(ctx, conn, msg)
+	if err != nil {
+		resultCode = proxyResult
+		return rejectProxiedAuthorization(conn, msg, proxyResult, err)
+	}
+
 	slog.Debug("Modify request", "dn", dn)
 
+	controls, err := decodeWriteControls(msg)
+	if err != nil {
+		slog.Debug("Invalid write controls", "dn", dn, "error", err)
+		resultCode = ldapmsg.ResultCodeProtocolError
+		return conn.WriteResponse(msg.ID, protocol.NewModifyResponse(ldapmsg.ResultCodeProtocolError))
+	}
+
 	canModify, err := s.canModify(ctx, conn, dn, modReq.Changes)
+	if err == nil && canModify {
+		canModify, err = s.canAssert(ctx, conn, dn, controls)
+	}
 	if err != nil {
 		slog.Error("Failed to check modify authorization", "dn", dn, "error", err)
 		resultCode = ldapmsg.ResultCodeOperationsError
@@ -196,29 +411,102 @@ func (s *Server) handleModify(ctx context.Context, conn *protocol.Connection, ms
 		return conn.WriteResponse(msg.ID, protocol.NewModifyResponse(ldapmsg.ResultCodeNoSuchObject))
 	}
 
-	// Apply modifications
-	changes := modReq.Changes
-	for _, change := range changes {
-		modification := change.Modification
-		attrType := modification.Name
+	if err := s.checkModifyPasswords(ctx, conn, entry.DN, modReq.Changes); err != nil {
+		policyControl := passwordPolicyError(err)
+		if policyControl == nil {
+			slog.Error("Failed to check password policy", "dn", dn, "error", err)
+			resultCode = ldapmsg.ResultCodeOperationsError
+			return conn.WriteResponse(msg.ID, protocol.NewModifyResponse(ldapmsg.ResultCodeOperationsError))
+		}
+		slog.Debug("Password rejected by password policy", "dn", dn, "error", err)
+		resultCode = ldapmsg.ResultCodeConstraintViolation
+		resp := protocol.NewModifyResponse(ldapmsg.ResultCodeConstraintViolation)
+		resp.DiagnosticMessage = err.Error()
+		return conn.WriteResponse(msg.ID, resp, passwordPolicyControls(msg, policyControl)...)
+	}
 
+	for _, change := range modReq.Changes {
 		// Check protected attributes
-		if isModifyProtectedAttribute(attrType) {
-			slog.Debug("Attempt to modify protected attribute", "dn", dn, "attribute", attrType)
+		if isModifyProtectedAttribute(change.Modification.Name) {
+			slog.Debug("Attempt to modify protected attribute", "dn", dn, "attribute", change.Modification.Name)
 			resultCode = ldapmsg.ResultCodeUnwillingToPerform
 			return conn.WriteResponse(msg.ID, protocol.NewModifyResponse(ldapmsg.ResultCodeUnwillingToPerform))
 		}
+		if change.Operation == ldapmsg.ModifyOperationIncrement {
+			if resultCode = incrementChangeResultCode(change); resultCode != ldapmsg.ResultCodeSuccess {
+				slog.Debug("Invalid increment", "dn", dn, "attribute", change.Modification.Name)
+				return conn.WriteResponse(msg.ID, protocol.NewModifyResponse(resultCode))
+			}
+		}
+	}
+
+	changes, err := s.hashModifyPasswords(modReq.Changes)
+	if err != nil {
+		slog.Debug("Password rejected", "dn", dn, "error", err)
+		resultCode = passwordHashResultCode(err)
+		resp := protocol.NewModifyResponse(resultCode)
+		resp.DiagnosticMessage = err.Error()
+		return conn.WriteResponse(msg.ID, resp)
+	}
+
+	// Apply the modifications to the entry as stored in the update
+	// transaction, so concurrent writes cannot slip in between.
+	var applyErr error
+	result, err := s.store.ModifyEntry(ctx, dn, controls.options, func(entry *models.Entry) error {
+		applyErr = applyModifyChanges(entry, changes)
+		return applyErr
+	})
+	if applyErr != nil {
+		slog.Debug("Modification rejected", "dn", dn, "error", applyErr)
+		resultCode = entryWriteResultCode(applyErr)
+		resp := protocol.NewModifyResponse(resultCode)
+		resp.DiagnosticMessage = applyErr.Error()
+		return conn.WriteResponse(msg.ID, resp)
+	}
+	if err != nil {
+		slog.Error("Failed to update entry", "dn", dn, "error", err)
+		resultCode = entryWriteResultCode(err)
+		return conn.WriteResponse(msg.ID, protocol.NewModifyResponse(entryWriteResultCode(err)))
+	}
+
+	slog.Info("Entry modified", "dn", dn)
+	resultCode = ldapmsg.ResultCodeSuccess
+	return conn.WriteResponse(msg.ID, protocol.NewModifyResponse(ldapmsg.ResultCodeSuccess), s.readEntryControls(ctx, conn, controls, result)...)
+}
+
+// hashModifyPasswords returns changes with the userPassword values they add or
+// replace hashed. Hashing is slow, so it runs before the modify transaction
+// instead of while the transaction holds the database write lock.
+func (s *Server) hashModifyPasswords(changes []ldapmsg.ModifyChange) ([]ldapmsg.ModifyChange, error) {
+	hashed := slices.Clone(changes)
+	for i, change := range hashed {
+		if (change.Operation != ldapmsg.ModifyOperationAdd && change.Operation != ldapmsg.ModifyOperationReplace) || !strings.EqualFold(change.Modification.Name, "userPassword") {
+			continue
+		}
+		var values []string
+		for _, value := range change.Modification.Values {
+			processedPasswords, err := s.hasher.ProcessPasswordValues(value)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, processedPasswords...)
+		}
+		hashed[i].Modification.Values = values
+	}
+	return hashed, nil
+}
 
-		vals := modification.Values
+// applyModifyChanges applies the changes of a Modify request, with passwords
+// already hashed, to entry.
+func applyModifyChanges(entry *models.Entry, changes []ldapmsg.ModifyChange) error {
+	for _, change := range changes {
+		attrType := change.Modification.Name
+		vals := change.Modification.Values
 
 		switch change.Operation {
 		case ldapmsg.ModifyOperationAdd:
 			slog.Debug("Add attribute", "attr", attrType)
-			if err := s.addModifyValues(entry, attrType, vals); err != nil {
-				slog.Debug("Invalid password format", "dn", dn, "error", err)
-				resultCode = ldapmsg.ResultCodeConstraintViolation
-				return conn.WriteResponse(msg.ID, protocol.NewModifyResponse(ldapmsg.ResultCodeConstraintViolation))
-			}
+			addModifyValues(entry, attrType, vals)
 
 		case ldapmsg.ModifyOperationDelete:
 			slog.Debug("Delete attribute", "attr", attrType)
@@ -226,42 +514,138 @@ func (s *Server) handleModify(ctx context.Context, conn *protocol.Connection, ms
 
 		case ldapmsg.ModifyOperationReplace:
 			slog.Debug("Replace attribute", "attr", attrType)
-			if err := s.replaceModifyValues(entry, attrType, vals); err != nil {
-				slog.Debug("Invalid password format", "dn", dn, "error", err)
-				resultCode = ldapmsg.ResultCodeConstraintViolation
-				return conn.WriteResponse(msg.ID, protocol.NewModifyResponse(ldapmsg.ResultCodeConstraintViolation))
+			replaceModifyValues(entry, attrType, vals)
+
+		case ldapmsg.ModifyOperationIncrement:
+			slog.Debug("Increment attribute", "attr", attrType)
+			if err := incrementModifyValues(entry, attrType, vals); err != nil {
+				return err
 			}
 		}
 	}
+	return nil
+}
 
-	// Update entry
-	if err := s.store.UpdateEntry(ctx, entry); err != nil {
-		slog.Error("Failed to update entry", "dn", dn, "error", err)
-		resultCode = entryWriteResultCode(err)
-		return conn.WriteResponse(msg.ID, protocol.NewModifyResponse(entryWriteResultCode(err)))
+// incrementChangeResultCode checks an increment change before the entry is
+// read: it carries exactly one integer and does not touch userPassword.
+func incrementChangeResultCode(change ldapmsg.ModifyChange) ldapmsg.ResultCode {
+	if strings.EqualFold(change.Modification.Name, "userPassword") {
+		return ldapmsg.ResultCodeUnwillingToPerform
 	}
+	if len(change.Modification.Values) != 1 {
+		return ldapmsg.ResultCodeProtocolError
+	}
+	if _, err := strconv.ParseInt(change.Modification.Values[0], 10, 64); err != nil {
+		return ldapmsg.ResultCodeInvalidAttributeSyntax
+	}
+	return ldapmsg.ResultCodeSuccess
+}
 
-	slog.Info("Entry modified", "dn", dn)
-	resultCode = ldapmsg.ResultCodeSuccess
-	return conn.WriteResponse(msg.ID, protocol.NewModifyResponse(ldapmsg.ResultCodeSuccess))
+// incrementModifyValues adds the increment to every value of attrType (RFC
+// 4525). It runs inside the modify transaction, so concurrent increments of a
+// counter entry never hand out the same value twice.
+func incrementModifyValues(entry *models.Entry, attrType string, vals []string) error {
+	if len(vals) != 1 {
+		return fmt.Errorf("%w: increment of %s needs exactly one value", store.ErrConstraintViolation, attrType)
+	}
+	delta, err := strconv.ParseInt(vals[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: increment %q is not an integer", store.ErrConstraintViolation, vals[0])
+	}
+	existing := entry.GetAttributes(attrType)
+	if len(existing) == 0 {
+		return fmt.Errorf("%w: %s", store.ErrNoSuchAttribute, attrType)
+	}
+	incremented := make([]string, 0, len(existing))
+	for _, value := range existing {
+		current, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%w: %s value %q is not an integer", store.ErrConstraintViolation, attrType, value)
+		}
+		next := current + delta
+		if (delta > 0 && next < current) || (delta < 0 && next > current) {
+			return fmt.Errorf("%w: incrementing %s overflows", store.ErrConstraintViolation, attrType)
+		}
+		incremented = append(incremented, strconv.FormatInt(next, 10))
+	}
+	entry.SetAttributes(attrType, incremented)
+	return nil
 }
 
+// checkModifyPasswords checks the cleartext userPassword values a Modify
+// request adds or replaces against the password policy. Pre-hashed values are
+// only accepted from identities that may reset passwords.
+func (s *Server) checkModifyPasswords(ctx context.Context, conn *protocol.Connection, dn string, changes []ldapmsg.ModifyChange) error {
+	for _, change := range changes {
+		if change.Operation == ldapmsg.ModifyOperationDelete || change.Operation == ldapmsg.ModifyOperationIncrement || !strings.EqualFold(change.Modification.Name, "userPassword") {
+			continue
+		}
+		if err := s.checkPreHashedPasswords(ctx, conn, dn, change.Modification.Values); err != nil {
+			return err
+		}
+		for _, value := range change.Modification.Values {
+			if crypto.IsHashedPassword(value) {
+				continue
+			}
+			if err := s.policy.CheckNewPassword(ctx, dn, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// canModify allows a Modify when the access rules let the acting identity
+// write every attribute it changes. Users may always replace their own
+// password.
 func (s *Server) canModify(ctx context.Context, conn *protocol.Connection, targetDN string, changes []ldapmsg.ModifyChange) (bool, error) {
-	baseDN := ""
-	if s.cfg != nil {
-		baseDN = s.cfg.LDAP.BaseDN
+	access := s.authorizer()
+	actor := operationActor(ctx, conn)
+	if isSelfPasswordModify(actor.DN, targetDN, changes) {
+		capabilities, err := access.Capabilities(ctx, actor)
+		if err != nil {
+			return false, err
+		}
+		if capabilities.Has(authz.PasswordChangeSelf) {
+			return true, nil
+		}
 	}
-	capabilities, err := authz.New(baseDN, s.store).Capabilities(ctx, authz.Actor{
-		DN:    conn.GetBoundDN(),
-		Bound: conn.IsBound(),
-	})
+
+	// Group owners may change members, which needs the group's owner values.
+	target, err := s.loadAccessTarget(ctx, targetDN, access.HasAccessRules() || changesAttribute(changes, authz.MemberAttribute))
 	if err != nil {
 		return false, err
 	}
-	if capabilities.Has(authz.DirectoryWrite) {
-		return true, nil
+	if len(changes) == 0 {
+		return access.Allowed(ctx, actor, target, "", authz.PermissionWrite)
+	}
+	for _, change := range changes {
+		allowed, err := access.Allowed(ctx, actor, target, change.Modification.Name, authz.PermissionWrite)
+		if err != nil || !allowed {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// canWriteEntry checks an entry-level write permission on dn for the
+// identity the operation acts as.
+func (s *Server) canWriteEntry(ctx context.Context, conn *protocol.Connection, dn string, permission authz.Permission) (bool, error) {
+	access := s.authorizer()
+	target, err := s.accessTarget(ctx, access, dn)
+	if err != nil {
+		return false, err
 	}
-	return capabilities.Has(authz.PasswordChangeSelf) && isSelfPasswordModify(conn.GetBoundDN(), targetDN, changes), nil
+	return access.Allowed(ctx, operationActor(ctx, conn), target, "", permission)
+}
+
+func changesAttribute(changes []ldapmsg.ModifyChange, name string) bool {
+	for _, change := range changes {
+		if strings.EqualFold(change.Modification.Name, name) {
+			return true
+		}
+	}
+	return false
 }
 
 func isSelfPasswordModify(boundDN, targetDN string, changes []ldapmsg.ModifyChange) bool {
@@ -282,6 +666,17 @@ func isSelfPasswordModify(boundDN, targetDN string, changes []ldapmsg.ModifyChan
 	return true
 }
 
+// addPasswordValues returns the userPassword values of an Add request.
+func addPasswordValues(attrs map[string][]string) []string {
+	var values []string
+	for name, attrValues := range attrs {
+		if strings.EqualFold(name, "userPassword") {
+			values = append(values, attrValues...)
+		}
+	}
+	return values
+}
+
 func addRequestAttributes(attrs []ldapmsg.Attribute) map[string][]string {
 	values := make(map[string][]string, len(attrs))
 	for _, attr := range attrs {
@@ -290,6 +685,22 @@ func addRequestAttributes(attrs []ldapmsg.Attribute) map[string][]string {
 	return values
 }
 
+// addAccessTarget is the entry an Add request would create, as access rules
+// see it. It is built before any password is checked or hashed.
+func addAccessTarget(dn string, attrs map[string][]string) *models.Entry {
+	entry := models.NewEntry(dn, "")
+	for name, values := range attrs {
+		if strings.EqualFold(name, "objectClass") {
+			if len(values) > 0 {
+				entry.ObjectClass = values[0]
+			}
+			continue
+		}
+		entry.SetAttributes(name, values)
+	}
+	return entry
+}
+
 func (s *Server) newAddEntry(dn string, attrs map[string][]string) (*models.Entry, ldapmsg.ResultCode, error) {
 	entry := &models.Entry{
 		DN:         dn,
@@ -309,11 +720,16 @@ func (s *Server) newAddEntry(dn string, attrs map[string][]string) (*models.Entr
 	}
 
 	if userPassword := entry.GetAttribute("userPassword"); userPassword != "" {
-		processedPassword, err := s.hasher.ProcessPassword(userPassword)
+		if !crypto.IsHashedPassword(userPassword) {
+			if err := s.policy.CheckQuality(userPassword); err != nil {
+				return nil, ldapmsg.ResultCodeConstraintViolation, err
+			}
+		}
+		processedPasswords, err := s.hasher.ProcessPasswordValues(userPassword)
 		if err != nil {
-			return nil, ldapmsg.ResultCodeConstraintViolation, err
+			return nil, passwordHashResultCode(err), err
 		}
-		entry.SetAttribute("userPassword", processedPassword)
+		entry.SetAttributes("userPassword", processedPasswords)
 	}
 
 	objectClass := entry.GetAttribute("objectClass")
@@ -330,22 +746,10 @@ func (s *Server) newAddEntry(dn string, attrs map[string][]string) (*models.Entr
 	return entry, ldapmsg.ResultCodeSuccess, nil
 }
 
-func (s *Server) addModifyValues(entry *models.Entry, attrType string, vals []string) error {
-	if attrType == "userPassword" {
-		for _, val := range vals {
-			processedPassword, err := s.hasher.ProcessPassword(val)
-			if err != nil {
-				return err
-			}
-			entry.AddAttribute(attrType, processedPassword)
-		}
-		return nil
-	}
-
+func addModifyValues(entry *models.Entry, attrType string, vals []string) {
 	for _, val := range vals {
 		entry.AddAttribute(attrType, val)
 	}
-	return nil
 }
 
 func deleteModifyValues(entry *models.Entry, attrType string, vals []string) {
@@ -368,7 +772,7 @@ func deleteModifyValues(entry *models.Entry, attrType string, vals []string) {
 	}
 }
 
-func (s *Server) replaceModifyValues(entry *models.Entry, attrType string, vals []string) error {
+func replaceModifyValues(entry *models.Entry, attrType string, vals []string) {
 	entry.RemoveAttribute(attrType)
-	return s.addModifyValues(entry, attrType, vals)
+	addModifyValues(entry, attrType, vals)
 }
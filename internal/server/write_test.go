@@ -1,10 +1,15 @@
 package server
 
 import (
+	"errors"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/smarzola/ldaplite/internal/models"
 	"github.com/smarzola/ldaplite/internal/protocol/ldapmsg"
+	"github.com/smarzola/ldaplite/internal/store"
+	"github.com/smarzola/ldaplite/pkg/crypto"
 )
 
 func TestAddRequestAttributesConvertsLDAPMessageAttributes(t *testing.T) {
@@ -90,6 +95,66 @@ func TestStableIDAttributesAreModifyProtected(t *testing.T) {
 	}
 }
 
+func TestModifyDNRenameOptions(t *testing.T) {
+	newSuperior := "ou=people,dc=example,dc=com"
+	emptySuperior := ""
+
+	tests := []struct {
+		name string
+		req  ldapmsg.ModifyDNRequest
+		want ldapmsg.ResultCode
+	}{
+		{
+			name: "rename",
+			req:  ldapmsg.ModifyDNRequest{Entry: "uid=jane,ou=users,dc=example,dc=com", NewRDN: "uid=janet", DeleteOldRDN: true},
+			want: ldapmsg.ResultCodeSuccess,
+		},
+		{
+			name: "move",
+			req:  ldapmsg.ModifyDNRequest{Entry: "uid=jane,ou=users,dc=example,dc=com", NewRDN: "uid=jane", NewSuperior: &newSuperior},
+			want: ldapmsg.ResultCodeSuccess,
+		},
+		{
+			name: "malformed RDN",
+			req:  ldapmsg.ModifyDNRequest{Entry: "uid=jane,ou=users,dc=example,dc=com", NewRDN: "janet"},
+			want: ldapmsg.ResultCodeInvalidDNSyntax,
+		},
+		{
+			name: "protected RDN attribute",
+			req:  ldapmsg.ModifyDNRequest{Entry: "uid=jane,ou=users,dc=example,dc=com", NewRDN: "entryUUID=1234"},
+			want: ldapmsg.ResultCodeUnwillingToPerform,
+		},
+		{
+			name: "password RDN attribute",
+			req:  ldapmsg.ModifyDNRequest{Entry: "uid=jane,ou=users,dc=example,dc=com", NewRDN: "userPassword=secret"},
+			want: ldapmsg.ResultCodeUnwillingToPerform,
+		},
+		{
+			name: "empty new superior",
+			req:  ldapmsg.ModifyDNRequest{Entry: "uid=jane,ou=users,dc=example,dc=com", NewRDN: "uid=jane", NewSuperior: &emptySuperior},
+			want: ldapmsg.ResultCodeUnwillingToPerform,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options, got := modifyDNRenameOptions(tt.req)
+			if got != tt.want {
+				t.Fatalf("modifyDNRenameOptions() resultCode = %d, want %d", got, tt.want)
+			}
+			if got != ldapmsg.ResultCodeSuccess {
+				return
+			}
+			if options.NewRDN != tt.req.NewRDN || options.DeleteOldRDN != tt.req.DeleteOldRDN {
+				t.Fatalf("options = %#v, want request RDN fields", options)
+			}
+			if tt.req.NewSuperior != nil && options.NewSuperior != *tt.req.NewSuperior {
+				t.Fatalf("NewSuperior = %q, want %q", options.NewSuperior, *tt.req.NewSuperior)
+			}
+		})
+	}
+}
+
 func TestNewAddEntryRequiresObjectClass(t *testing.T) {
 	srv := &Server{}
 
@@ -142,10 +207,7 @@ func TestReplaceModifyValues(t *testing.T) {
 	entry := models.NewEntry("uid=jane,ou=users,dc=example,dc=com", "inetOrgPerson")
 	entry.AddAttribute("mail", "old@example.com")
 
-	srv := &Server{}
-	if err := srv.replaceModifyValues(entry, "mail", []string{"new@example.com", "alt@example.com"}); err != nil {
-		t.Fatalf("replaceModifyValues() failed: %v", err)
-	}
+	replaceModifyValues(entry, "mail", []string{"new@example.com", "alt@example.com"})
 
 	got := entry.GetAttributes("mail")
 	want := []string{"new@example.com", "alt@example.com"}
@@ -158,3 +220,105 @@ func TestReplaceModifyValues(t *testing.T) {
 		}
 	}
 }
+
+func TestHashModifyPasswordsRunsBeforeTheTransaction(t *testing.T) {
+	srv := &Server{hasher: crypto.NewPasswordHasher(auditTestConfig().Security.Argon2Config)}
+	changes := append(replaceChange("userPassword", "NewPassword123!"), replaceChange("mail", "jane@example.com")...)
+
+	hashed, err := srv.hashModifyPasswords(changes)
+	if err != nil {
+		t.Fatalf("hashModifyPasswords() failed: %v", err)
+	}
+	if got := changes[0].Modification.Values; len(got) != 1 || got[0] != "NewPassword123!" {
+		t.Fatalf("request changes were modified: %#v", got)
+	}
+	passwords := hashed[0].Modification.Values
+	if len(passwords) != 2 || !strings.HasPrefix(passwords[0], crypto.SchemeArgon2ID) || !strings.HasPrefix(passwords[1], crypto.SchemeSCRAMSHA256) {
+		t.Fatalf("userPassword values = %#v, want Argon2id hash and SCRAM keys", passwords)
+	}
+	if got := hashed[1].Modification.Values; len(got) != 1 || got[0] != "jane@example.com" {
+		t.Fatalf("mail values = %#v, want unchanged", got)
+	}
+
+	entry := models.NewEntry("uid=jane,ou=users,dc=example,dc=com", "inetOrgPerson")
+	if err := applyModifyChanges(entry, hashed); err != nil {
+		t.Fatalf("applyModifyChanges() failed: %v", err)
+	}
+	if got := entry.GetAttributes("userPassword"); len(got) != 2 || got[0] != passwords[0] {
+		t.Fatalf("stored userPassword = %#v, want the hashed values", got)
+	}
+}
+
+func TestIncrementModifyValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  []string
+		delta   string
+		want    []string
+		wantErr error
+	}{
+		{name: "increment", values: []string{"10000"}, delta: "1", want: []string{"10001"}},
+		{name: "negative increment", values: []string{"10000"}, delta: "-5", want: []string{"9995"}},
+		{name: "every value", values: []string{"1", "7"}, delta: "2", want: []string{"3", "9"}},
+		{name: "missing attribute", delta: "1", wantErr: store.ErrNoSuchAttribute},
+		{name: "non-integer value", values: []string{"ten"}, delta: "1", wantErr: store.ErrConstraintViolation},
+		{name: "overflow", values: []string{"9223372036854775807"}, delta: "1", wantErr: store.ErrConstraintViolation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := models.NewEntry("cn=ids,dc=example,dc=com", "inetOrgPerson")
+			if tt.values != nil {
+				entry.SetAttributes("uidNumber", tt.values)
+			}
+
+			err := incrementModifyValues(entry, "uidNumber", []string{tt.delta})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("incrementModifyValues() error = %v, want %v", err, tt.wantErr)
+				}
+				if got := entry.GetAttributes("uidNumber"); len(tt.values) > 0 && !slices.Equal(got, tt.values) {
+					t.Fatalf("uidNumber = %v, want it unchanged", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("incrementModifyValues() failed: %v", err)
+			}
+			if got := entry.GetAttributes("uidNumber"); !slices.Equal(got, tt.want) {
+				t.Fatalf("uidNumber = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIncrementChangeResultCode(t *testing.T) {
+	tests := []struct {
+		name   string
+		change ldapmsg.ModifyChange
+		want   ldapmsg.ResultCode
+	}{
+		{name: "one integer", change: incrementChange("uidNumber", "1"), want: ldapmsg.ResultCodeSuccess},
+		{name: "no value", change: incrementChange("uidNumber"), want: ldapmsg.ResultCodeProtocolError},
+		{name: "two values", change: incrementChange("uidNumber", "1", "2"), want: ldapmsg.ResultCodeProtocolError},
+		{name: "not an integer", change: incrementChange("uidNumber", "one"), want: ldapmsg.ResultCodeInvalidAttributeSyntax},
+		{name: "password", change: incrementChange("userPassword", "1"), want: ldapmsg.ResultCodeUnwillingToPerform},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := incrementChangeResultCode(tt.change); got != tt.want {
+				t.Fatalf("incrementChangeResultCode() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	if got := entryWriteResultCode(incrementModifyValues(models.NewEntry("cn=ids,dc=example,dc=com", "inetOrgPerson"), "uidNumber", []string{"1"})); got != ldapmsg.ResultCodeNoSuchAttribute {
+		t.Fatalf("entryWriteResultCode(missing attribute) = %d, want noSuchAttribute", got)
+	}
+}
+
+func incrementChange(name string, values ...string) ldapmsg.ModifyChange {
+	return ldapmsg.ModifyChange{
+		Operation:    ldapmsg.ModifyOperationIncrement,
+		Modification: ldapmsg.Attribute{Name: name, Values: values},
+	}
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/smarzola/ldaplite/internal/models"
+	"github.com/smarzola/ldaplite/internal/protocol"
+	"github.com/smarzola/ldaplite/internal/protocol/ldapmsg"
+	"github.com/smarzola/ldaplite/internal/schema"
+	"github.com/smarzola/ldaplite/internal/store"
+)
+
+// writeControls holds the assertion (RFC 4528) and read entry (RFC 4527)
+// controls of a write request.
+type writeControls struct {
+	options   store.WriteOptions
+	assertion *schema.Filter
+	preRead   *searchAttributeSelection
+	postRead  *searchAttributeSelection
+}
+
+// decodeWriteControls decodes the assertion, pre-read and post-read controls
+// of a write request. Controls the operation does not support are left out.
+func decodeWriteControls(msg *ldapmsg.Message) (writeControls, error) {
+	var controls writeControls
+	if control, ok := msg.Control(protocol.AssertionControlOID); ok {
+		filter, err := protocol.DecodeAssertionControl(control)
+		if err != nil {
+			return writeControls{}, err
+		}
+		controls.options.Assertion = serializeFilter(filter)
+		if controls.assertion, err = schema.ParseFilter(controls.options.Assertion); err != nil {
+			return writeControls{}, err
+		}
+	}
+	for _, read := range []struct {
+		oid       string
+		selection **searchAttributeSelection
+	}{
+		{protocol.PreReadControlOID, &controls.preRead},
+		{protocol.PostReadControlOID, &controls.postRead},
+	} {
+		control, ok := msg.Control(read.oid)
+		if !ok || !supportsControl(msg.Op, read.oid) {
+			continue
+		}
+		attributes, err := protocol.DecodeReadEntryControl(control)
+		if err != nil {
+			return writeControls{}, err
+		}
+		selection := newSearchAttributeSelection(attributes)
+		*read.selection = &selection
+	}
+	controls.options.ReturnEntries = controls.preRead != nil || controls.postRead != nil
+	return controls, nil
+}
+
+// canAssert reports whether the identity the operation acts as may evaluate
+// the assertion against the entry at dn. Like a search filter, the assertion
+// may only test attributes the identity can search, or its outcome would
+// reveal values the identity cannot read.
+func (s *Server) canAssert(ctx context.Context, conn *protocol.Connection, dn string, controls writeControls) (bool, error) {
+	access := s.authorizer()
+	if controls.assertion == nil || !access.HasAccessRules() {
+		return true, nil
+	}
+	target, err := s.accessTarget(ctx, access, dn)
+	if err != nil {
+		return false, err
+	}
+	return access.CanSearch(ctx, operationActor(ctx, conn), target, controls.assertion)
+}
+
+// readEntryControls answers the pre-read and post-read controls with the
+// entries of a write, holding only the attributes the identity the
+// operation acts as may read. The write has already happened, so an entry
+// that cannot be checked is logged and left out.
+func (s *Server) readEntryControls(ctx context.Context, conn *protocol.Connection, controls writeControls, result store.WriteResult) []ldapmsg.Control {
+	var responses []ldapmsg.Control
+	for _, read := range []struct {
+		oid       string
+		selection *searchAttributeSelection
+		entry     *models.Entry
+	}{
+		{protocol.PreReadControlOID, controls.preRead, result.Before},
+		{protocol.PostReadControlOID, controls.postRead, result.After},
+	} {
+		if read.selection == nil || read.entry == nil {
+			continue
+		}
+		attrs, err := readableSearchAttributes(ctx, s.authorizer(), operationActor(ctx, conn), read.entry, searchResponseAttributes(read.entry, *read.selection))
+		if err != nil {
+			slog.Error("Failed to check read entry authorization", "dn", read.entry.DN, "control", read.oid, "error", err)
+			continue
+		}
+		entry := protocol.NewSearchResultEntry(read.entry.DN)
+		for _, attr := range attrs {
+			addSearchAttribute(&entry, attr.name, attr.values, false)
+		}
+		responses = append(responses, protocol.NewReadEntryControl(read.oid, entry))
+	}
+	return responses
+}
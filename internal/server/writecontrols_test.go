@@ -0,0 +1,101 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/smarzola/ldaplite/internal/models"
+	"github.com/smarzola/ldaplite/internal/protocol"
+	"github.com/smarzola/ldaplite/internal/protocol/ber"
+	"github.com/smarzola/ldaplite/internal/protocol/ldapmsg"
+	"github.com/smarzola/ldaplite/internal/store"
+	"github.com/smarzola/ldaplite/pkg/config"
+)
+
+func readEntryControl(oid string, attributes ...string) ldapmsg.Control {
+	values := make([][]byte, 0, len(attributes))
+	for _, attribute := range attributes {
+		values = append(values, ber.OctetString(attribute))
+	}
+	value := string(ber.Sequence(values...))
+	return ldapmsg.Control{OID: oid, Value: &value}
+}
+
+func TestDecodeWriteControls(t *testing.T) {
+	assertion := string(ber.TLV(ber.ClassContextSpecific|ber.Constructed|3, append(ber.OctetString("member"), ber.OctetString("uid=a,dc=example,dc=com")...)))
+	controls, err := decodeWriteControls(&ldapmsg.Message{
+		Op: ldapmsg.ModifyRequest{Object: "cn=devs,dc=example,dc=com"},
+		Controls: []ldapmsg.Control{
+			{OID: protocol.AssertionControlOID, Criticality: true, Value: &assertion},
+			readEntryControl(protocol.PreReadControlOID, "member"),
+			readEntryControl(protocol.PostReadControlOID),
+		},
+	})
+	if err != nil {
+		t.Fatalf("decodeWriteControls() failed: %v", err)
+	}
+	if controls.options.Assertion != "(member=uid=a,dc=example,dc=com)" || controls.assertion == nil {
+		t.Fatalf("assertion = %q, want the member filter", controls.options.Assertion)
+	}
+	if !controls.options.ReturnEntries || controls.preRead == nil || controls.postRead == nil {
+		t.Fatalf("decodeWriteControls() = %+v, want pre-read and post-read", controls)
+	}
+	if !controls.preRead.includes("member") || controls.preRead.includes("cn") {
+		t.Fatalf("pre-read selection = %+v, want member only", controls.preRead)
+	}
+
+	controls, err = decodeWriteControls(&ldapmsg.Message{
+		Op:       ldapmsg.DeleteRequest{DN: "cn=devs,dc=example,dc=com"},
+		Controls: []ldapmsg.Control{readEntryControl(protocol.PostReadControlOID)},
+	})
+	if err != nil || controls.postRead != nil || controls.options.ReturnEntries {
+		t.Fatalf("decodeWriteControls(delete with post-read) = %+v, %v; want no entries", controls, err)
+	}
+
+	invalid := string(ber.OctetString("member"))
+	if _, err := decodeWriteControls(&ldapmsg.Message{
+		Op:       ldapmsg.DeleteRequest{DN: "cn=devs,dc=example,dc=com"},
+		Controls: []ldapmsg.Control{{OID: protocol.AssertionControlOID, Value: &invalid}},
+	}); err == nil {
+		t.Fatal("decodeWriteControls(invalid assertion) succeeded, want error")
+	}
+}
+
+func TestReadEntryControlsReturnOnlyReadableAttributes(t *testing.T) {
+	srv := testAuthzServer(false)
+	srv.cfg.Authz.AccessRules = []config.AccessRule{
+		{Name: "private-mobile", Effect: "deny", Subject: "users", Attributes: []string{"mobile"}, Permissions: []string{"read"}},
+	}
+	conn := protocol.NewConnection(nil, protocol.OperationHandlers{})
+	conn.SetBoundDN("uid=jane,ou=users,dc=example,dc=com")
+
+	before := models.NewEntry("uid=bob,ou=users,dc=example,dc=com", string(models.ObjectClassInetOrgPerson))
+	before.SetAttribute("cn", "Bob")
+	before.SetAttribute("mobile", "555-0101")
+	after := before.Clone()
+	after.SetAttribute("cn", "Robert")
+
+	selection := newSearchAttributeSelection([]string{"cn", "mobile"})
+	controls := srv.readEntryControls(context.Background(), conn, writeControls{preRead: &selection, postRead: &selection}, store.WriteResult{Before: before, After: after})
+	if len(controls) != 2 {
+		t.Fatalf("readEntryControls() = %+v, want pre-read and post-read", controls)
+	}
+	for i, want := range []struct {
+		oid string
+		cn  string
+	}{
+		{protocol.PreReadControlOID, "Bob"},
+		{protocol.PostReadControlOID, "Robert"},
+	} {
+		entry := protocol.NewSearchResultEntry(before.DN)
+		protocol.AddAttribute(&entry, "cn", want.cn)
+		wantControl := protocol.NewReadEntryControl(want.oid, entry)
+		if controls[i].OID != want.oid || *controls[i].Value != *wantControl.Value {
+			t.Fatalf("control %d = %s %x, want %s %x", i, controls[i].OID, *controls[i].Value, want.oid, *wantControl.Value)
+		}
+	}
+
+	if controls := srv.readEntryControls(context.Background(), conn, writeControls{postRead: &selection}, store.WriteResult{}); len(controls) != 0 {
+		t.Fatalf("readEntryControls(no entries) = %+v, want none", controls)
+	}
+}
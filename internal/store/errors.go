@@ -8,12 +8,33 @@ import (
 )
 
 var (
-	ErrConstraintViolation  = errors.New("constraint violation")
-	ErrEntryAlreadyExists   = errors.New("entry already exists")
-	ErrNoSuchObject         = errors.New("no such object")
-	ErrObjectClassViolation = errors.New("object class violation")
+	ErrAssertionFailed       = errors.New("assertion failed")
+	ErrConstraintViolation   = errors.New("constraint violation")
+	ErrEntryAlreadyExists    = errors.New("entry already exists")
+	ErrInappropriateMatching = errors.New("inappropriate matching")
+	ErrNoSuchAttribute       = errors.New("no such attribute")
+	ErrNoSuchObject          = errors.New("no such object")
+	ErrNotAllowedOnNonLeaf   = errors.New("not allowed on non-leaf")
+	ErrObjectClassViolation  = errors.New("object class violation")
+	ErrUnwillingToPerform    = errors.New("unwilling to perform")
 )
 
+// SortKeyError reports a search sort key the store cannot order by. Err is
+// ErrUnwillingToPerform for unsortable attributes and ErrInappropriateMatching
+// for unsupported ordering rules.
+type SortKeyError struct {
+	Attribute string
+	Err       error
+}
+
+func (e *SortKeyError) Error() string {
+	return fmt.Sprintf("cannot sort by %s: %v", e.Attribute, e.Err)
+}
+
+func (e *SortKeyError) Unwrap() error {
+	return e.Err
+}
+
 func classifyModelValidationError(err error) error {
 	if err == nil {
 		return nil
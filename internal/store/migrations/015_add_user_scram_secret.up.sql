@@ -0,0 +1,4 @@
+-- SCRAM-SHA-256 keys are derived from the cleartext password, so they are
+-- written next to the Argon2id hash whenever a password is set. Existing users
+-- get them the next time their password changes.
+ALTER TABLE users ADD COLUMN scram_secret TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,19 @@
+DROP TABLE IF EXISTS password_history;
+DROP TABLE IF EXISTS password_failures;
+
+-- SQLite doesn't support DROP COLUMN on older versions, so we recreate the table
+CREATE TABLE users_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    entry_id INTEGER UNIQUE NOT NULL,
+    password_hash TEXT,
+    scram_secret TEXT NOT NULL DEFAULT '',
+    FOREIGN KEY (entry_id) REFERENCES entries(id) ON DELETE CASCADE
+);
+
+INSERT INTO users_new (id, entry_id, password_hash, scram_secret)
+SELECT id, entry_id, password_hash, scram_secret FROM users;
+
+DROP TABLE users;
+ALTER TABLE users_new RENAME TO users;
+
+CREATE INDEX IF NOT EXISTS idx_users_entry_id ON users(entry_id);
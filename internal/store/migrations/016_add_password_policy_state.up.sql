@@ -0,0 +1,27 @@
+-- Password policy state (draft-behera-ldap-password-policy). Existing
+-- passwords count as changed now so enabling a maximum age does not expire
+-- them all at once.
+ALTER TABLE users ADD COLUMN pwd_changed_time TIMESTAMP;
+ALTER TABLE users ADD COLUMN pwd_account_locked_time TIMESTAMP;
+ALTER TABLE users ADD COLUMN pwd_grace_use_count INTEGER NOT NULL DEFAULT 0;
+
+UPDATE users SET pwd_changed_time = CURRENT_TIMESTAMP;
+
+CREATE TABLE IF NOT EXISTS password_failures (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    entry_id INTEGER NOT NULL,
+    failure_time TIMESTAMP NOT NULL,
+    FOREIGN KEY (entry_id) REFERENCES entries(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_failures_entry_id ON password_failures(entry_id);
+
+CREATE TABLE IF NOT EXISTS password_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    entry_id INTEGER NOT NULL,
+    password_hash TEXT NOT NULL,
+    changed_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (entry_id) REFERENCES entries(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_history_entry_id ON password_history(entry_id);
@@ -3,6 +3,7 @@ package store
 import (
 	"context"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -321,3 +322,105 @@ func TestServerManagedAttributesNotStoredInGenericAttributes(t *testing.T) {
 		}
 	}
 }
+
+// TestSCRAMSecretStoredNextToPasswordHash verifies that SCRAM keys land in
+// users.scram_secret and are replaced whenever the password hash changes.
+func TestSCRAMSecretStoredNextToPasswordHash(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	const scramSecret = "{SCRAM-SHA-256}4096:c2FsdA==$c3RvcmVk:c2VydmVy"
+	user := models.NewUser("ou=users,dc=test,dc=com", "scramuser", "SCRAM", "User", "scram@example.com")
+	user.SetPassword("{ARGON2ID}$argon2id$v=19$m=65536,t=3,p=2$dGVzdHNhbHQ$testhash", scramSecret)
+	if err := store.CreateEntry(ctx, user.Entry); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	secret, dn, err := store.GetUserSCRAMSecret(ctx, "scramuser")
+	if err != nil {
+		t.Fatalf("GetUserSCRAMSecret failed: %v", err)
+	}
+	if secret != scramSecret || dn != user.DN {
+		t.Fatalf("GetUserSCRAMSecret = %q, %q; want %q, %q", secret, dn, scramSecret, user.DN)
+	}
+	hash, _, err := store.GetUserPasswordHash(ctx, "scramuser")
+	if err != nil || hash != user.Password {
+		t.Fatalf("GetUserPasswordHash = %q, %v; want the Argon2id hash", hash, err)
+	}
+
+	// A pre-hashed password comes without SCRAM keys; the old keys must go.
+	entry, err := store.GetEntry(ctx, user.DN)
+	if err != nil {
+		t.Fatalf("Failed to get user: %v", err)
+	}
+	entry.SetAttribute("userPassword", "{ARGON2ID}$argon2id$v=19$m=65536,t=3,p=2$bmV3c2FsdA$newhash")
+	if err := store.UpdateEntry(ctx, entry); err != nil {
+		t.Fatalf("Failed to update user: %v", err)
+	}
+	secret, _, err = store.GetUserSCRAMSecret(ctx, "scramuser")
+	if err != nil || secret != "" {
+		t.Fatalf("GetUserSCRAMSecret after pre-hashed update = %q, %v; want empty", secret, err)
+	}
+}
+
+func TestRehashUserPasswordKeepsPolicyState(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+	store.cfg.Security.PasswordPolicy.HistoryCount = 2
+	ctx := context.Background()
+
+	const legacyHash = "{SSHA}1G904nLkTkGWjKNnQuB/hpWXC/hzYWx0c2FsdA=="
+	const newHash = "{ARGON2ID}$argon2id$v=19$m=65536,t=3,p=2$bmV3c2FsdA$newhash"
+	const scramSecret = "{SCRAM-SHA-256}4096:c2FsdA==$c3RvcmVk:c2VydmVy"
+	user := models.NewUser("ou=users,dc=test,dc=com", "legacyuser", "Legacy", "User", "legacy@example.com")
+	user.SetPassword(legacyHash)
+	if err := store.CreateEntry(ctx, user.Entry); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	before, err := store.GetPasswordPolicyState(ctx, user.DN)
+	if err != nil {
+		t.Fatalf("GetPasswordPolicyState failed: %v", err)
+	}
+
+	// A stale old hash means the password changed meanwhile: nothing happens.
+	if err := store.RehashUserPassword(ctx, user.DN, "{SSHA}stale", newHash, scramSecret); err != nil {
+		t.Fatalf("RehashUserPassword with stale hash failed: %v", err)
+	}
+	if hash, _, _ := store.GetUserPasswordHashByDN(ctx, user.DN); hash != legacyHash {
+		t.Fatalf("hash after stale rehash = %q, want the legacy hash", hash)
+	}
+
+	if err := store.RehashUserPassword(ctx, strings.ToUpper(user.DN), legacyHash, newHash, scramSecret); err != nil {
+		t.Fatalf("RehashUserPassword failed: %v", err)
+	}
+	if hash, _, _ := store.GetUserPasswordHashByDN(ctx, user.DN); hash != newHash {
+		t.Fatalf("hash after rehash = %q, want %q", hash, newHash)
+	}
+	if secret, _, _ := store.GetUserSCRAMSecret(ctx, "legacyuser"); secret != scramSecret {
+		t.Fatalf("SCRAM secret after rehash = %q, want %q", secret, scramSecret)
+	}
+
+	history, err := store.GetPasswordHistory(ctx, user.DN)
+	if err != nil || len(history) != 0 {
+		t.Fatalf("history after rehash = %v, %v; want empty", history, err)
+	}
+	after, err := store.GetPasswordPolicyState(ctx, user.DN)
+	if err != nil || !after.ChangedTime.Equal(before.ChangedTime) {
+		t.Fatalf("pwdChangedTime after rehash = %v, %v; want %v", after.ChangedTime, err, before.ChangedTime)
+	}
+
+	hashes, err := store.ListUserPasswordHashes(ctx)
+	if err != nil {
+		t.Fatalf("ListUserPasswordHashes failed: %v", err)
+	}
+	found := false
+	for _, hash := range hashes {
+		if hash.DN == user.DN {
+			found = hash.PasswordHash == newHash
+		}
+	}
+	if !found {
+		t.Fatalf("ListUserPasswordHashes = %v, want %s with the new hash", hashes, user.DN)
+	}
+}
@@ -0,0 +1,116 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/smarzola/ldaplite/internal/models"
+	"github.com/smarzola/ldaplite/internal/telemetry"
+)
+
+// accountStatusAttributeNames are the stored (lowercase) names of the account
+// state attributes.
+var accountStatusAttributeNames = []any{
+	strings.ToLower(models.AttrAccountDisabled),
+	strings.ToLower(models.AttrAccountExpirationTime),
+}
+
+// SetAccountStatus replaces the disabled state and expiry of a user.
+//
+// The account state attributes live in the attributes table so filters can
+// match them, but UpdateEntry preserves their stored values: this method and
+// UpdateEntryWithAccountStatus are the only ways to change them after an
+// entry is created.
+func (s *SQLiteStore) SetAccountStatus(ctx context.Context, dn string, status models.AccountStatus) (err error) {
+	ctx, span := telemetry.StartStoreSpan(ctx, "SetAccountStatus")
+	defer func() {
+		telemetry.EndStoreSpan(span, err)
+	}()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := setAccountStatusTx(ctx, tx, dn, status); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateEntryWithAccountStatus stores entry like UpdateEntry and replaces the
+// account status of the user in the same transaction.
+func (s *SQLiteStore) UpdateEntryWithAccountStatus(ctx context.Context, entry *models.Entry, status models.AccountStatus) (err error) {
+	ctx, span := telemetry.StartStoreSpan(ctx, "UpdateEntryWithAccountStatus")
+	defer func() {
+		telemetry.EndStoreSpan(span, err)
+	}()
+
+	if err := entry.Validate(); err != nil {
+		return classifyModelValidationError(err)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.updateEntryTx(ctx, tx, entry); err != nil {
+		return err
+	}
+	if err := setAccountStatusTx(ctx, tx, entry.DN, status); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// setAccountStatusTx replaces the account state attributes of the user at dn.
+func setAccountStatusTx(ctx context.Context, tx *sql.Tx, dn string, status models.AccountStatus) error {
+	entryID, err := userEntryIDTx(ctx, tx, dn)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM attributes WHERE entry_id = ? AND LOWER(name) IN (?, ?)`,
+		append([]any{entryID}, accountStatusAttributeNames...)...); err != nil {
+		return fmt.Errorf("failed to clear account status: %w", err)
+	}
+	if err := insertGenericAttributes(ctx, tx, entryID, status.Attributes()); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE entries SET updated_at = ? WHERE id = ?`, time.Now(), entryID); err != nil {
+		return fmt.Errorf("failed to update entry: %w", err)
+	}
+	return nil
+}
+
+// preserveAccountStatusAttributes replaces the account state attributes of
+// an entry about to be written with the stored ones.
+func preserveAccountStatusAttributes(ctx context.Context, tx *sql.Tx, entryID int64, entry *models.Entry) error {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT name, value FROM attributes WHERE entry_id = ? AND LOWER(name) IN (?, ?)`,
+		append([]any{entryID}, accountStatusAttributeNames...)...)
+	if err != nil {
+		return fmt.Errorf("failed to read account status: %w", err)
+	}
+	defer rows.Close()
+
+	entry.RemoveAttribute(models.AttrAccountDisabled)
+	entry.RemoveAttribute(models.AttrAccountExpirationTime)
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return fmt.Errorf("failed to scan account status: %w", err)
+		}
+		entry.AddAttribute(name, value)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate account status: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/smarzola/ldaplite/internal/models"
+)
+
+func TestAccountStatusIsPreservedAcrossUpdates(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	user := models.NewUser("ou=users,dc=test,dc=com", "contractor", "Contractor", "User", "contractor@example.com")
+	user.SetPassword("{ARGON2ID}$argon2id$v=19$m=65536,t=3,p=2$c2FsdA$hash")
+	if err := store.CreateEntry(ctx, user.Entry); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	expires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	status := models.AccountStatus{Disabled: true, ExpirationTime: expires}
+	if err := store.SetAccountStatus(ctx, user.DN, status); err != nil {
+		t.Fatalf("SetAccountStatus failed: %v", err)
+	}
+
+	// Ordinary updates cannot change or drop the account state.
+	entry, err := store.GetEntry(ctx, user.DN)
+	if err != nil {
+		t.Fatalf("Failed to get user: %v", err)
+	}
+	entry.RemoveAttribute("accountDisabled")
+	entry.SetAttribute("accountExpirationTime", "20990101000000Z")
+	entry.SetAttribute("mail", "contractor@example.org")
+	if err := store.UpdateEntry(ctx, entry); err != nil {
+		t.Fatalf("Failed to update user: %v", err)
+	}
+	entry, err = store.GetEntry(ctx, user.DN)
+	if err != nil {
+		t.Fatalf("Failed to get user: %v", err)
+	}
+	if got := entry.AccountStatus(); got != status {
+		t.Fatalf("account status after update = %+v, want %+v", got, status)
+	}
+
+	disabled, err := store.SearchEntries(ctx, "dc=test,dc=com", "(accountDisabled=TRUE)")
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(disabled) != 1 || disabled[0].DN != user.DN {
+		t.Fatalf("disabled users = %v, want only %s", disabled, user.DN)
+	}
+
+	if err := store.SetAccountStatus(ctx, user.DN, models.AccountStatus{}); err != nil {
+		t.Fatalf("SetAccountStatus failed: %v", err)
+	}
+	entry, err = store.GetEntry(ctx, user.DN)
+	if err != nil {
+		t.Fatalf("Failed to get user: %v", err)
+	}
+	if entry.HasAttribute("accountDisabled") || entry.HasAttribute("accountExpirationTime") {
+		t.Fatalf("attributes after enabling = %v, want no account state", entry.Attributes)
+	}
+
+	if err := store.SetAccountStatus(ctx, "ou=users,dc=test,dc=com", status); err == nil {
+		t.Fatal("SetAccountStatus on an OU succeeded, want an error")
+	}
+}
+
+func TestUpdateEntryWithAccountStatusWritesBothOrNeither(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	user := models.NewUser("ou=users,dc=test,dc=com", "contractor", "Contractor", "User", "contractor@example.com")
+	user.SetPassword("{ARGON2ID}$argon2id$v=19$m=65536,t=3,p=2$c2FsdA$hash")
+	if err := store.CreateEntry(ctx, user.Entry); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	entry, err := store.GetEntry(ctx, user.DN)
+	if err != nil {
+		t.Fatalf("Failed to get user: %v", err)
+	}
+	entry.SetAttribute("mail", "contractor@example.org")
+	status := models.AccountStatus{Disabled: true}
+	if err := store.UpdateEntryWithAccountStatus(ctx, entry, status); err != nil {
+		t.Fatalf("UpdateEntryWithAccountStatus failed: %v", err)
+	}
+	entry, err = store.GetEntry(ctx, user.DN)
+	if err != nil {
+		t.Fatalf("Failed to get user: %v", err)
+	}
+	if entry.GetAttribute("mail") != "contractor@example.org" || entry.AccountStatus() != status {
+		t.Fatalf("entry after update = %v, want new mail and %+v", entry.Attributes, status)
+	}
+
+	// Entries without account state roll back the whole update.
+	users, err := store.GetEntry(ctx, "ou=users,dc=test,dc=com")
+	if err != nil {
+		t.Fatalf("Failed to get OU: %v", err)
+	}
+	users.SetAttribute("description", "Changed")
+	if err := store.UpdateEntryWithAccountStatus(ctx, users, status); err == nil {
+		t.Fatal("UpdateEntryWithAccountStatus on an OU succeeded, want an error")
+	}
+	users, err = store.GetEntry(ctx, "ou=users,dc=test,dc=com")
+	if err != nil {
+		t.Fatalf("Failed to get OU: %v", err)
+	}
+	if users.GetAttribute("description") == "Changed" {
+		t.Fatal("OU description changed by a failed update")
+	}
+}
@@ -59,6 +59,27 @@ func (s *SQLiteStore) GetUserPasswordHashByDN(ctx context.Context, dn string) (p
 	return s.queryPasswordHash(ctx, "get user password hash by DN", query, dn)
 }
 
+// GetUserSCRAMSecret retrieves the stored SCRAM-SHA-256 keys for a user by
+// UID. The secret is empty for users whose password was set before SCRAM
+// support or supplied pre-hashed. The same isolation rules as
+// GetUserPasswordHash apply.
+func (s *SQLiteStore) GetUserSCRAMSecret(ctx context.Context, uid string) (scramSecret string, dn string, err error) {
+	ctx, span := telemetry.StartStoreSpan(ctx, "GetUserSCRAMSecret")
+	defer func() {
+		telemetry.EndStoreSpan(span, err)
+	}()
+
+	query := `
+		SELECT u.scram_secret, e.dn
+		FROM users u
+		INNER JOIN entries e ON u.entry_id = e.id
+		INNER JOIN attributes a ON u.entry_id = a.entry_id
+		WHERE a.name = 'uid' AND a.value = ?
+		LIMIT 1
+	`
+	return s.queryPasswordHash(ctx, "get user SCRAM secret", query, uid)
+}
+
 func (s *SQLiteStore) queryPasswordHash(ctx context.Context, operation string, query string, arg string) (string, string, error) {
 	var passwordHash, dn string
 	err := s.db.QueryRowContext(ctx, query, arg).Scan(&passwordHash, &dn)
@@ -70,3 +91,64 @@ func (s *SQLiteStore) queryPasswordHash(ctx context.Context, operation string, q
 	}
 	return passwordHash, dn, nil
 }
+
+// RehashUserPassword replaces the stored password hash of a user with a
+// stronger hash of the same password. The update only applies while the
+// stored hash still equals oldHash, so a password changed concurrently is
+// never overwritten. Unlike a password change it records no history and
+// does not restart the password age.
+func (s *SQLiteStore) RehashUserPassword(ctx context.Context, dn, oldHash, newHash, scramSecret string) (err error) {
+	ctx, span := telemetry.StartStoreSpan(ctx, "RehashUserPassword")
+	defer func() {
+		telemetry.EndStoreSpan(span, err)
+	}()
+
+	query := `
+		UPDATE users SET password_hash = ?, scram_secret = ?
+		WHERE password_hash = ?
+		AND entry_id = (SELECT id FROM entries WHERE LOWER(dn) = LOWER(?))
+	`
+	if _, err := s.db.ExecContext(ctx, query, newHash, scramSecret, oldHash, dn); err != nil {
+		return fmt.Errorf("failed to rehash user password: %w", err)
+	}
+	return nil
+}
+
+// UserPasswordHash is the stored password hash of one user.
+type UserPasswordHash struct {
+	DN           string
+	PasswordHash string
+}
+
+// ListUserPasswordHashes returns the password hash of every user, in entry
+// order, for reporting which accounts still need a rehash. The same
+// isolation rules as GetUserPasswordHash apply.
+func (s *SQLiteStore) ListUserPasswordHashes(ctx context.Context) (hashes []UserPasswordHash, err error) {
+	ctx, span := telemetry.StartStoreSpan(ctx, "ListUserPasswordHashes")
+	defer func() {
+		telemetry.EndStoreSpan(span, err)
+	}()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT e.dn, COALESCE(u.password_hash, '')
+		FROM users u
+		INNER JOIN entries e ON u.entry_id = e.id
+		ORDER BY e.id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user password hashes: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var hash UserPasswordHash
+		if err := rows.Scan(&hash.DN, &hash.PasswordHash); err != nil {
+			return nil, fmt.Errorf("failed to scan user password hash: %w", err)
+		}
+		hashes = append(hashes, hash)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list user password hashes: %w", err)
+	}
+	return hashes, nil
+}
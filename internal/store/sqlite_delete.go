@@ -0,0 +1,223 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/smarzola/ldaplite/internal/ldapdn"
+	"github.com/smarzola/ldaplite/internal/models"
+	"github.com/smarzola/ldaplite/internal/telemetry"
+)
+
+// entryTables lists the tables holding rows of a single entry. SQLite does
+// not enforce their foreign keys, so deletes remove these rows explicitly.
+var entryTables = []string{"attributes", "users", "groups", "organizational_units", "password_failures", "password_history"}
+
+// subtreeEntry is an entry of a subtree with its depth below the root.
+type subtreeEntry struct {
+	id    int64
+	dn    string
+	depth int
+}
+
+// DeleteSubtree deletes an entry together with its whole subtree (the tree
+// delete control). Group memberships of the deleted entries are removed in
+// the same transaction, so groups outside the subtree keep no member values
+// pointing at deleted entries.
+func (s *SQLiteStore) DeleteSubtree(ctx context.Context, dn string, options SubtreeDeleteOptions) (result SubtreeDeleteResult, err error) {
+	ctx, span := telemetry.StartStoreSpan(ctx, "DeleteSubtree")
+	defer func() {
+		telemetry.EndStoreSpan(span, err)
+	}()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return SubtreeDeleteResult{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var entryID int64
+	var rootDN string
+	err = tx.QueryRowContext(ctx, `SELECT id, dn FROM entries WHERE LOWER(dn) = LOWER(?)`, dn).Scan(&entryID, &rootDN)
+	if err == sql.ErrNoRows {
+		return SubtreeDeleteResult{}, fmt.Errorf("%w: entry not found: %s", ErrNoSuchObject, dn)
+	}
+	if err != nil {
+		return SubtreeDeleteResult{}, fmt.Errorf("failed to get entry: %w", err)
+	}
+	if ldapdn.Equal(rootDN, strings.TrimSpace(s.cfg.LDAP.BaseDN)) {
+		return SubtreeDeleteResult{}, fmt.Errorf("%w: base DN cannot be deleted: %s", ErrUnwillingToPerform, rootDN)
+	}
+
+	if result.Before, err = checkWriteTargetTx(ctx, tx, rootDN, options.WriteOptions); err != nil {
+		return SubtreeDeleteResult{}, err
+	}
+
+	subtree, err := readSubtreeTx(ctx, tx, entryID)
+	if err != nil {
+		return SubtreeDeleteResult{}, err
+	}
+	slices.SortStableFunc(subtree, func(a, b subtreeEntry) int {
+		return b.depth - a.depth
+	})
+	if result.Groups, err = deleteEntriesTx(ctx, tx, rootDN, subtree); err != nil {
+		return SubtreeDeleteResult{}, err
+	}
+	for _, entry := range subtree {
+		result.DNs = append(result.DNs, entry.dn)
+	}
+
+	if options.DryRun {
+		return result, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return SubtreeDeleteResult{}, fmt.Errorf("failed to commit subtree delete: %w", err)
+	}
+	return result, nil
+}
+
+// checkWriteTargetTx evaluates the assertion of a write against the entry at
+// dn and returns the entry when the write asks for it.
+func checkWriteTargetTx(ctx context.Context, tx *sql.Tx, dn string, options WriteOptions) (*models.Entry, error) {
+	if options.Assertion == "" && !options.ReturnEntries {
+		return nil, nil
+	}
+	entry, err := getEntryTx(ctx, tx, dn)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, fmt.Errorf("%w: entry not found: %s", ErrNoSuchObject, dn)
+	}
+	if err := checkAssertionTx(ctx, tx, entry, options.Assertion); err != nil {
+		return nil, err
+	}
+	if !options.ReturnEntries {
+		return nil, nil
+	}
+	return entry, nil
+}
+
+// readSubtreeTx reads the entry with entryID and all of its descendants.
+// Every level has a longer DN than its parent, so the recursion ends at the
+// leaves without a depth cap; a cap would leave deeper entries orphaned.
+func readSubtreeTx(ctx context.Context, tx *sql.Tx, entryID int64) ([]subtreeEntry, error) {
+	rows, err := tx.QueryContext(ctx, `
+		WITH RECURSIVE subtree AS (
+			SELECT id, dn, 0 as depth
+			FROM entries
+			WHERE id = ?
+
+			UNION ALL
+
+			SELECT e.id, e.dn, s.depth + 1
+			FROM entries e
+			INNER JOIN subtree s ON LOWER(e.parent_dn) = LOWER(s.dn)
+		)
+		SELECT id, dn, depth FROM subtree
+	`, entryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read subtree: %w", err)
+	}
+	defer rows.Close()
+
+	var subtree []subtreeEntry
+	for rows.Next() {
+		var entry subtreeEntry
+		if err := rows.Scan(&entry.id, &entry.dn, &entry.depth); err != nil {
+			return nil, fmt.Errorf("failed to scan subtree entry: %w", err)
+		}
+		subtree = append(subtree, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read subtree: %w", err)
+	}
+	return subtree, nil
+}
+
+// hasChildrenTx reports whether any entry has dn as its parent.
+func hasChildrenTx(ctx context.Context, tx *sql.Tx, dn string) (bool, error) {
+	var exists bool
+	err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM entries WHERE LOWER(parent_dn) = LOWER(?))`, dn).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check child entries: %w", err)
+	}
+	return exists, nil
+}
+
+// deleteEntriesTx deletes entries, all at or below rootDN, with their rows
+// and group memberships. DN-valued attributes (member, owner) of other
+// entries that reference a deleted entry are removed, and the groups that
+// held them are returned.
+func deleteEntriesTx(ctx context.Context, tx *sql.Tx, rootDN string, entries []subtreeEntry) ([]string, error) {
+	args := make([]interface{}, 0, len(dnValuedAttributes)+2)
+	for _, name := range dnValuedAttributes {
+		args = append(args, name)
+	}
+	// LIKE narrows the candidates; WithinBase below performs the exact DN match.
+	args = append(args, strings.ToLower(rootDN), "%"+strings.ToLower(rootDN))
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT a.id, a.value, e.dn
+		FROM attributes a
+		INNER JOIN entries e ON e.id = a.entry_id
+		WHERE LOWER(a.name) IN (`+queryPlaceholders(len(dnValuedAttributes))+`)
+		  AND (LOWER(a.value) = ? OR LOWER(a.value) LIKE ?)
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read DN-valued attributes: %w", err)
+	}
+	var valueIDs []int64
+	var groups []string
+	for rows.Next() {
+		var id int64
+		var value, holderDN string
+		if err := rows.Scan(&id, &value, &holderDN); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan DN-valued attribute: %w", err)
+		}
+		if !ldapdn.WithinBase(value, rootDN) || ldapdn.WithinBase(holderDN, rootDN) {
+			continue
+		}
+		valueIDs = append(valueIDs, id)
+		if !slices.ContainsFunc(groups, func(group string) bool { return ldapdn.Equal(group, holderDN) }) {
+			groups = append(groups, holderDN)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return nil, fmt.Errorf("failed to read DN-valued attributes: %w", err)
+	}
+	rows.Close()
+
+	for _, id := range valueIDs {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM attributes WHERE id = ?`, id); err != nil {
+			return nil, fmt.Errorf("failed to delete DN-valued attribute: %w", err)
+		}
+	}
+	now := time.Now()
+	for _, group := range groups {
+		if _, err := tx.ExecContext(ctx, `UPDATE entries SET updated_at = ? WHERE LOWER(dn) = LOWER(?)`, now, group); err != nil {
+			return nil, fmt.Errorf("failed to update group %s: %w", group, err)
+		}
+	}
+
+	for _, entry := range entries {
+		for _, table := range entryTables {
+			if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE entry_id = ?`, entry.id); err != nil {
+				return nil, fmt.Errorf("failed to delete %s of %s: %w", table, entry.dn, err)
+			}
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM group_members WHERE group_entry_id = ? OR member_entry_id = ?`, entry.id, entry.id); err != nil {
+			return nil, fmt.Errorf("failed to delete group memberships of %s: %w", entry.dn, err)
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM entries WHERE id = ?`, entry.id); err != nil {
+			return nil, fmt.Errorf("failed to delete entry %s: %w", entry.dn, err)
+		}
+	}
+	return groups, nil
+}
@@ -0,0 +1,143 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/smarzola/ldaplite/internal/models"
+)
+
+func TestDeleteEntryRefusesNonLeafAndRemovesMemberships(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	if err := store.DeleteEntry(ctx, "ou=users,dc=test,dc=com"); !errors.Is(err, ErrNotAllowedOnNonLeaf) {
+		t.Fatalf("DeleteEntry(ou=users) error = %v, want ErrNotAllowedOnNonLeaf", err)
+	}
+	if exists, err := store.EntryExists(ctx, "uid=bob,ou=users,dc=test,dc=com"); err != nil || !exists {
+		t.Fatalf("EntryExists(bob) = %v, %v; want the children kept", exists, err)
+	}
+
+	if err := store.DeleteEntry(ctx, "uid=bob,ou=users,dc=test,dc=com"); err != nil {
+		t.Fatalf("DeleteEntry(bob) failed: %v", err)
+	}
+	developers, err := store.GetEntry(ctx, "cn=developers,ou=groups,dc=test,dc=com")
+	if err != nil || developers == nil {
+		t.Fatalf("GetEntry(developers) = %v, %v", developers, err)
+	}
+	if members := developers.GetAttributes("member"); len(members) != 1 || members[0] != "uid=jsmith,ou=users,dc=test,dc=com" {
+		t.Fatalf("developers members = %v, want jsmith only", members)
+	}
+	// A group that still referenced bob could not be saved again.
+	if err := store.UpdateEntry(ctx, developers); err != nil {
+		t.Fatalf("UpdateEntry(developers) failed: %v", err)
+	}
+}
+
+func TestDeleteSubtree(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	const contractorsDN = "ou=contractors,dc=test,dc=com"
+	const developersDN = "cn=developers,ou=groups,dc=test,dc=com"
+	contractors := models.NewOrganizationalUnit("dc=test,dc=com", "contractors", "Contractors")
+	carol := models.NewUser(contractorsDN, "carol", "Carol King", "King", "carol@test.com")
+	dave := models.NewUser(contractorsDN, "dave", "Dave Hill", "Hill", "dave@test.com")
+	crew := models.NewGroup(contractorsDN, "crew", "Contractor crew")
+	crew.AddMember(carol.DN)
+	crew.AddMember(dave.DN)
+	for _, entry := range []*models.Entry{contractors.Entry, carol.Entry, dave.Entry, crew.Entry} {
+		if err := store.CreateEntry(ctx, entry); err != nil {
+			t.Fatalf("CreateEntry(%s) failed: %v", entry.DN, err)
+		}
+	}
+	if _, err := store.ModifyEntry(ctx, developersDN, WriteOptions{}, func(entry *models.Entry) error {
+		entry.AddAttribute("member", "UID=CAROL,OU=CONTRACTORS,DC=TEST,DC=COM")
+		return nil
+	}); err != nil {
+		t.Fatalf("ModifyEntry(developers) failed: %v", err)
+	}
+
+	preview, err := store.DeleteSubtree(ctx, contractorsDN, SubtreeDeleteOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("DeleteSubtree(dry run) failed: %v", err)
+	}
+	if len(preview.DNs) != 4 || preview.DNs[3] != contractorsDN {
+		t.Fatalf("DeleteSubtree(dry run) DNs = %v, want three children before the OU", preview.DNs)
+	}
+	if len(preview.Groups) != 1 || preview.Groups[0] != developersDN {
+		t.Fatalf("DeleteSubtree(dry run) Groups = %v, want developers", preview.Groups)
+	}
+	if exists, err := store.EntryExists(ctx, carol.DN); err != nil || !exists {
+		t.Fatalf("EntryExists(carol) = %v, %v; want the dry run rolled back", exists, err)
+	}
+
+	if _, err := store.DeleteSubtree(ctx, contractorsDN, SubtreeDeleteOptions{WriteOptions: WriteOptions{Assertion: "(ou=staff)"}}); !errors.Is(err, ErrAssertionFailed) {
+		t.Fatalf("DeleteSubtree(failing assertion) error = %v, want ErrAssertionFailed", err)
+	}
+
+	result, err := store.DeleteSubtree(ctx, contractorsDN, SubtreeDeleteOptions{WriteOptions: WriteOptions{ReturnEntries: true}})
+	if err != nil {
+		t.Fatalf("DeleteSubtree() failed: %v", err)
+	}
+	if len(result.DNs) != 4 || result.Before == nil || result.Before.GetAttribute("ou") != "contractors" {
+		t.Fatalf("DeleteSubtree() = %+v, want four entries and the OU before the delete", result)
+	}
+	for _, dn := range []string{contractorsDN, carol.DN, dave.DN, crew.DN} {
+		if exists, err := store.EntryExists(ctx, dn); err != nil || exists {
+			t.Fatalf("EntryExists(%s) = %v, %v; want it deleted", dn, exists, err)
+		}
+	}
+	developers, err := store.GetEntry(ctx, developersDN)
+	if err != nil || developers == nil {
+		t.Fatalf("GetEntry(developers) = %v, %v", developers, err)
+	}
+	if members := developers.GetAttributes("member"); len(members) != 2 || containsValue(members, "UID=CAROL,OU=CONTRACTORS,DC=TEST,DC=COM") {
+		t.Fatalf("developers members = %v, want jsmith and bob", members)
+	}
+	groups, err := store.SearchEntries(ctx, "dc=test,dc=com", "(member=uid=carol,ou=contractors,dc=test,dc=com)")
+	if err != nil || len(groups) != 0 {
+		t.Fatalf("groups with carol = %v, %v; want none", groups, err)
+	}
+
+	if _, err := store.DeleteSubtree(ctx, "dc=test,dc=com", SubtreeDeleteOptions{DryRun: true}); !errors.Is(err, ErrUnwillingToPerform) {
+		t.Fatalf("DeleteSubtree(base DN) error = %v, want ErrUnwillingToPerform", err)
+	}
+	if _, err := store.DeleteSubtree(ctx, contractorsDN, SubtreeDeleteOptions{}); !errors.Is(err, ErrNoSuchObject) {
+		t.Fatalf("DeleteSubtree(missing) error = %v, want ErrNoSuchObject", err)
+	}
+}
+
+func TestDeleteSubtreeRemovesDeepTrees(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	const rootDN = "ou=deep,dc=test,dc=com"
+	if err := store.CreateEntry(ctx, models.NewOrganizationalUnit("dc=test,dc=com", "deep", "Deep").Entry); err != nil {
+		t.Fatalf("CreateEntry(%s) failed: %v", rootDN, err)
+	}
+	parentDN := rootDN
+	for i := range 120 {
+		unit := models.NewOrganizationalUnit(parentDN, fmt.Sprintf("level%d", i), "Level")
+		if err := store.CreateEntry(ctx, unit.Entry); err != nil {
+			t.Fatalf("CreateEntry(%s) failed: %v", unit.DN, err)
+		}
+		parentDN = unit.DN
+	}
+
+	result, err := store.DeleteSubtree(ctx, rootDN, SubtreeDeleteOptions{})
+	if err != nil {
+		t.Fatalf("DeleteSubtree() failed: %v", err)
+	}
+	if len(result.DNs) != 121 {
+		t.Fatalf("DeleteSubtree() deleted %d entries, want 121", len(result.DNs))
+	}
+	if exists, err := store.EntryExists(ctx, parentDN); err != nil || exists {
+		t.Fatalf("EntryExists(deepest) = %v, %v; want deleted", exists, err)
+	}
+}
@@ -5,13 +5,37 @@ import (
 	"database/sql"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/smarzola/ldaplite/internal/ldapdn"
 	"github.com/smarzola/ldaplite/internal/models"
+	"github.com/smarzola/ldaplite/internal/schema"
 	"github.com/smarzola/ldaplite/internal/telemetry"
+	"github.com/smarzola/ldaplite/pkg/crypto"
 )
 
+// getEntryQuery uses JSON aggregation to fetch an entry with its attributes
+// in a single query.
+const getEntryQuery = `
+	SELECT
+		e.id,
+		e.dn,
+		e.parent_dn,
+		e.object_class,
+		e.created_at,
+		e.updated_at,
+		json_group_array(
+			CASE WHEN a.name IS NOT NULL
+			THEN json_object('name', a.name, 'value', a.value)
+			ELSE NULL END
+		) as attributes_json
+	FROM entries e
+	LEFT JOIN attributes a ON e.id = a.entry_id
+	WHERE LOWER(e.dn) = LOWER(?)
+	GROUP BY e.id, e.dn, e.parent_dn, e.object_class, e.created_at, e.updated_at
+`
+
 // GetEntry retrieves an entry by DN
 func (s *SQLiteStore) GetEntry(ctx context.Context, dn string) (*models.Entry, error) {
 	return s.GetEntryWithOptions(ctx, dn, EntryOptions{IncludeMemberOf: true})
@@ -24,27 +48,7 @@ func (s *SQLiteStore) GetEntryWithOptions(ctx context.Context, dn string, option
 		telemetry.EndStoreSpan(span, err)
 	}()
 
-	// Use JSON aggregation to fetch entry with attributes in a single query
-	query := `
-		SELECT
-			e.id,
-			e.dn,
-			e.parent_dn,
-			e.object_class,
-			e.created_at,
-			e.updated_at,
-			json_group_array(
-				CASE WHEN a.name IS NOT NULL
-				THEN json_object('name', a.name, 'value', a.value)
-				ELSE NULL END
-			) as attributes_json
-		FROM entries e
-		LEFT JOIN attributes a ON e.id = a.entry_id
-		WHERE LOWER(e.dn) = LOWER(?)
-		GROUP BY e.id, e.dn, e.parent_dn, e.object_class, e.created_at, e.updated_at
-	`
-
-	entries, err := s.queryEntriesWithAttributesOptions(ctx, "get entry", options.IncludeMemberOf, query, dn)
+	entries, err := s.queryEntriesWithAttributesOptions(ctx, "get entry", options.IncludeMemberOf, getEntryQuery, dn)
 	if err != nil {
 		return nil, err
 	}
@@ -67,16 +71,59 @@ func (s *SQLiteStore) CreateEntry(ctx context.Context, entry *models.Entry) (err
 		telemetry.EndStoreSpan(span, err)
 	}()
 
+	_, err = s.createEntry(ctx, entry, WriteOptions{})
+	return err
+}
+
+// CreateEntryWithOptions creates a new entry like CreateEntry. The assertion
+// is evaluated against the entry as stored, before the transaction commits.
+func (s *SQLiteStore) CreateEntryWithOptions(ctx context.Context, entry *models.Entry, options WriteOptions) (result WriteResult, err error) {
+	ctx, span := telemetry.StartStoreSpan(ctx, "CreateEntryWithOptions")
+	defer func() {
+		telemetry.EndStoreSpan(span, err)
+	}()
+
+	return s.createEntry(ctx, entry, options)
+}
+
+func (s *SQLiteStore) createEntry(ctx context.Context, entry *models.Entry, options WriteOptions) (WriteResult, error) {
 	if err := entry.Validate(); err != nil {
-		return classifyModelValidationError(err)
+		return WriteResult{}, classifyModelValidationError(err)
 	}
 
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return WriteResult{}, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
+	if err := s.insertEntryTx(ctx, tx, entry); err != nil {
+		return WriteResult{}, err
+	}
+
+	var result WriteResult
+	if options.Assertion != "" || options.ReturnEntries {
+		stored, err := getEntryTx(ctx, tx, entry.DN)
+		if err != nil {
+			return WriteResult{}, err
+		}
+		if err := checkAssertionTx(ctx, tx, stored, options.Assertion); err != nil {
+			return WriteResult{}, err
+		}
+		if options.ReturnEntries {
+			result.After = stored
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return WriteResult{}, fmt.Errorf("failed to commit entry: %w", err)
+	}
+	return result, nil
+}
+
+// insertEntryTx stores a new entry in all the tables it belongs to.
+func (s *SQLiteStore) insertEntryTx(ctx context.Context, tx *sql.Tx, entry *models.Entry) error {
+
 	if err := s.validateEntryPlacement(ctx, tx, entry); err != nil {
 		return err
 	}
@@ -138,10 +185,10 @@ func (s *SQLiteStore) CreateEntry(ctx context.Context, entry *models.Entry) (err
 		if err := user.ValidateUser(); err != nil {
 			return classifyModelValidationError(err)
 		}
-		// Users table stores only password_hash (security-sensitive data)
-		passwordHash := entry.GetAttribute("userPassword")
-		userQuery := `INSERT INTO users (entry_id, password_hash) VALUES (?, ?)`
-		if _, err := tx.ExecContext(ctx, userQuery, entryID, passwordHash); err != nil {
+		// Users table stores only password material (security-sensitive data)
+		passwordHash, scramSecret := userPasswordColumns(entry)
+		userQuery := `INSERT INTO users (entry_id, password_hash, scram_secret, pwd_changed_time) VALUES (?, ?, ?, ?)`
+		if _, err := tx.ExecContext(ctx, userQuery, entryID, passwordHash, scramSecret, time.Now()); err != nil {
 			return fmt.Errorf("failed to create user entry: %w", err)
 		}
 	} else if entry.IsGroup() {
@@ -174,7 +221,7 @@ func (s *SQLiteStore) CreateEntry(ctx context.Context, entry *models.Entry) (err
 		}
 	}
 
-	return tx.Commit()
+	return nil
 }
 
 // UpdateEntry updates an existing entry while maintaining dual-storage consistency:
@@ -200,6 +247,64 @@ func (s *SQLiteStore) UpdateEntry(ctx context.Context, entry *models.Entry) (err
 	}
 	defer tx.Rollback()
 
+	if err := s.updateEntryTx(ctx, tx, entry); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ModifyEntry reads the entry at dn, checks the assertion, lets modify change
+// the entry and stores it, all in one transaction, so concurrent writes
+// cannot interleave between the read and the write.
+func (s *SQLiteStore) ModifyEntry(ctx context.Context, dn string, options WriteOptions, modify func(entry *models.Entry) error) (result WriteResult, err error) {
+	ctx, span := telemetry.StartStoreSpan(ctx, "ModifyEntry")
+	defer func() {
+		telemetry.EndStoreSpan(span, err)
+	}()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return WriteResult{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	entry, err := getEntryTx(ctx, tx, dn)
+	if err != nil {
+		return WriteResult{}, err
+	}
+	if entry == nil {
+		return WriteResult{}, fmt.Errorf("%w: entry not found: %s", ErrNoSuchObject, dn)
+	}
+	if err := checkAssertionTx(ctx, tx, entry, options.Assertion); err != nil {
+		return WriteResult{}, err
+	}
+	if options.ReturnEntries {
+		result.Before = entry.Clone()
+	}
+
+	if err := modify(entry); err != nil {
+		return WriteResult{}, err
+	}
+	if err := entry.Validate(); err != nil {
+		return WriteResult{}, classifyModelValidationError(err)
+	}
+	if err := s.updateEntryTx(ctx, tx, entry); err != nil {
+		return WriteResult{}, err
+	}
+	if options.ReturnEntries {
+		if result.After, err = getEntryTx(ctx, tx, entry.DN); err != nil {
+			return WriteResult{}, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return WriteResult{}, fmt.Errorf("failed to commit entry: %w", err)
+	}
+	return result, nil
+}
+
+// updateEntryTx stores entry over the existing entry with the same DN.
+func (s *SQLiteStore) updateEntryTx(ctx context.Context, tx *sql.Tx, entry *models.Entry) error {
 	// Step 1: Update entry metadata (timestamp)
 	query := `UPDATE entries SET updated_at = ? WHERE LOWER(dn) = LOWER(?)`
 	result, err := tx.ExecContext(ctx, query, entry.UpdatedAt, entry.DN)
@@ -222,6 +327,9 @@ func (s *SQLiteStore) UpdateEntry(ctx context.Context, entry *models.Entry) (err
 	if err := preserveStableIDAttributes(ctx, tx, entryID, entry); err != nil {
 		return err
 	}
+	if err := preserveAccountStatusAttributes(ctx, tx, entryID, entry); err != nil {
+		return err
+	}
 
 	// Step 2: Replace attributes in attributes table (delete-then-insert pattern)
 	// This is simpler than diffing changes and ensures consistency
@@ -238,12 +346,23 @@ func (s *SQLiteStore) UpdateEntry(ctx context.Context, entry *models.Entry) (err
 	// Step 3: Update password in specialized users table if changed
 	// This maintains security isolation - password never touches attributes table
 	if entry.IsUser() {
-		passwordHash := entry.GetAttribute("userPassword")
+		passwordHash, scramSecret := userPasswordColumns(entry)
 		if passwordHash != "" {
-			updatePasswordQuery := `UPDATE users SET password_hash = ? WHERE entry_id = ?`
-			if _, err := tx.ExecContext(ctx, updatePasswordQuery, passwordHash, entryID); err != nil {
+			var oldHash sql.NullString
+			if err := tx.QueryRowContext(ctx, `SELECT password_hash FROM users WHERE entry_id = ?`, entryID).Scan(&oldHash); err != nil {
+				return fmt.Errorf("failed to get user password: %w", err)
+			}
+			// SCRAM keys always change with the hash so a pre-hashed
+			// password does not leave keys for the old password behind.
+			updatePasswordQuery := `UPDATE users SET password_hash = ?, scram_secret = ? WHERE entry_id = ?`
+			if _, err := tx.ExecContext(ctx, updatePasswordQuery, passwordHash, scramSecret, entryID); err != nil {
 				return fmt.Errorf("failed to update user password: %w", err)
 			}
+			if passwordHash != oldHash.String {
+				if err := s.recordPasswordChangeTx(ctx, tx, entryID, oldHash.String); err != nil {
+					return err
+				}
+			}
 		}
 	}
 
@@ -255,7 +374,22 @@ func (s *SQLiteStore) UpdateEntry(ctx context.Context, entry *models.Entry) (err
 		}
 	}
 
-	return tx.Commit()
+	return nil
+}
+
+// userPasswordColumns splits userPassword values into the users table
+// columns: the hash simple binds verify and the optional SCRAM-SHA-256 keys.
+func userPasswordColumns(entry *models.Entry) (passwordHash string, scramSecret string) {
+	for _, value := range entry.GetAttributes("userPassword") {
+		if strings.HasPrefix(value, crypto.SchemeSCRAMSHA256) {
+			if scramSecret == "" {
+				scramSecret = value
+			}
+		} else if passwordHash == "" {
+			passwordHash = value
+		}
+	}
+	return passwordHash, scramSecret
 }
 
 func insertGenericAttributes(ctx context.Context, tx *sql.Tx, entryID int64, attrs map[string][]string) error {
@@ -379,28 +513,107 @@ func entryExistsTx(ctx context.Context, tx *sql.Tx, dn string) (bool, error) {
 	return exists, nil
 }
 
-// DeleteEntry deletes an entry
+// DeleteEntry deletes a leaf entry with its group memberships. An entry
+// with children fails with ErrNotAllowedOnNonLeaf; DeleteSubtree removes
+// those.
 func (s *SQLiteStore) DeleteEntry(ctx context.Context, dn string) (err error) {
 	ctx, span := telemetry.StartStoreSpan(ctx, "DeleteEntry")
 	defer func() {
 		telemetry.EndStoreSpan(span, err)
 	}()
 
-	query := `DELETE FROM entries WHERE LOWER(dn) = LOWER(?)`
-	result, err := s.db.ExecContext(ctx, query, dn)
+	_, err = s.deleteEntry(ctx, dn, WriteOptions{})
+	return err
+}
+
+// DeleteEntryWithOptions deletes an entry like DeleteEntry, once the entry
+// still matches the assertion inside the delete transaction.
+func (s *SQLiteStore) DeleteEntryWithOptions(ctx context.Context, dn string, options WriteOptions) (result WriteResult, err error) {
+	ctx, span := telemetry.StartStoreSpan(ctx, "DeleteEntryWithOptions")
+	defer func() {
+		telemetry.EndStoreSpan(span, err)
+	}()
+
+	return s.deleteEntry(ctx, dn, options)
+}
+
+func (s *SQLiteStore) deleteEntry(ctx context.Context, dn string, options WriteOptions) (WriteResult, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to delete entry: %w", err)
+		return WriteResult{}, fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	rowsAffected, err := result.RowsAffected()
+	var entryID int64
+	var entryDN string
+	err = tx.QueryRowContext(ctx, `SELECT id, dn FROM entries WHERE LOWER(dn) = LOWER(?)`, dn).Scan(&entryID, &entryDN)
+	if err == sql.ErrNoRows {
+		return WriteResult{}, fmt.Errorf("%w: entry not found: %s", ErrNoSuchObject, dn)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		return WriteResult{}, fmt.Errorf("failed to get entry: %w", err)
 	}
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("%w: entry not found: %s", ErrNoSuchObject, dn)
+	var result WriteResult
+	if result.Before, err = checkWriteTargetTx(ctx, tx, entryDN, options); err != nil {
+		return WriteResult{}, err
 	}
 
+	hasChildren, err := hasChildrenTx(ctx, tx, entryDN)
+	if err != nil {
+		return WriteResult{}, err
+	}
+	if hasChildren {
+		return WriteResult{}, fmt.Errorf("%w: entry has children: %s", ErrNotAllowedOnNonLeaf, entryDN)
+	}
+
+	if _, err := deleteEntriesTx(ctx, tx, entryDN, []subtreeEntry{{id: entryID, dn: entryDN}}); err != nil {
+		return WriteResult{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return WriteResult{}, fmt.Errorf("failed to commit delete: %w", err)
+	}
+	return result, nil
+}
+
+// getEntryTx reads the entry at dn with memberOf inside tx. It returns nil
+// when no entry has that DN.
+func getEntryTx(ctx context.Context, tx *sql.Tx, dn string) (*models.Entry, error) {
+	rows, err := tx.QueryContext(ctx, getEntryQuery, dn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get entry: %w", err)
+	}
+	entries, err := scanEntriesWithAttributes(rows)
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	if err := populateMemberOf(ctx, tx, entries); err != nil {
+		return nil, fmt.Errorf("failed to populate memberOf: %w", err)
+	}
+	return entries[0], nil
+}
+
+// checkAssertionTx fails with ErrAssertionFailed unless entry, as read inside
+// tx, matches the assertion filter. An empty assertion always holds.
+func checkAssertionTx(ctx context.Context, tx *sql.Tx, entry *models.Entry, assertion string) error {
+	if assertion == "" {
+		return nil
+	}
+	filter, err := schema.ParseFilter(assertion)
+	if err != nil {
+		return fmt.Errorf("invalid assertion filter: %w", err)
+	}
+	if err := resolveChainFilters(ctx, tx, filter); err != nil {
+		return err
+	}
+	if !filter.Matches(entry) {
+		return fmt.Errorf("%w: %s does not match %s", ErrAssertionFailed, entry.DN, assertion)
+	}
 	return nil
 }
 
@@ -88,6 +88,12 @@ func isSQLiteUniqueConstraint(err error) bool {
 	return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
 }
 
+// queryer runs read queries on either the database or a transaction, so
+// reads can see the uncommitted state of a write.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
 func (s *SQLiteStore) queryEntriesWithAttributesOptions(ctx context.Context, operation string, includeMemberOf bool, query string, args ...interface{}) ([]*models.Entry, error) {
 	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -101,7 +107,7 @@ func (s *SQLiteStore) queryEntriesWithAttributesOptions(ctx context.Context, ope
 	}
 
 	if includeMemberOf {
-		if err := s.populateMemberOf(ctx, entries); err != nil {
+		if err := populateMemberOf(ctx, s.db, entries); err != nil {
 			return nil, fmt.Errorf("failed to populate memberOf: %w", err)
 		}
 	}
@@ -29,6 +29,21 @@ func NewSQLiteStore(cfg *config.Config) *SQLiteStore {
 	}
 }
 
+// sqliteBusyTimeout is how long a transaction waits for another writer to
+// release the database lock before failing with SQLITE_BUSY.
+const sqliteBusyTimeout = 5 * time.Second
+
+// sqliteDSN returns the data source name for the store's connection pool.
+// Every store transaction reads and then writes, so transactions begin
+// IMMEDIATE: they take the write lock up front and queue behind other writers
+// for up to sqliteBusyTimeout. A deferred transaction would instead fail with
+// SQLITE_BUSY when it tries to upgrade its read lock, and two concurrent
+// read-modify-writes of one entry (counter increments in particular) could
+// both read the old value.
+func sqliteDSN(path string) string {
+	return fmt.Sprintf("%s?_pragma=busy_timeout(%d)&_txlock=immediate", path, sqliteBusyTimeout.Milliseconds())
+}
+
 // Initialize sets up the database and runs migrations
 func (s *SQLiteStore) Initialize(ctx context.Context) error {
 	// Create data directory if it doesn't exist
@@ -41,7 +56,7 @@ func (s *SQLiteStore) Initialize(ctx context.Context) error {
 	isNew := !fileExists(s.cfg.Database.Path)
 
 	// Open database connection
-	db, err := sql.Open("sqlite", s.cfg.Database.Path)
+	db, err := sql.Open("sqlite", sqliteDSN(s.cfg.Database.Path))
 	if err != nil {
 		return fmt.Errorf("failed to open database: %w", err)
 	}
@@ -146,12 +161,12 @@ func (s *SQLiteStore) initializeDatabase(ctx context.Context) error {
 	// Create admin user (under ou=users)
 	usersOU := fmt.Sprintf("ou=users,%s", baseDN)
 	adminUser := models.NewUser(usersOU, "admin", "Administrator", "Administrator", "admin@example.com")
-	hashedPassword, err := s.hasher.Hash(adminPassword)
+	hashedPasswords, err := s.hasher.HashValues(adminPassword)
 	if err != nil {
 		return fmt.Errorf("failed to hash admin password: %w", err)
 	}
 
-	adminUser.SetPassword(hashedPassword)
+	adminUser.SetPassword(hashedPasswords[0], hashedPasswords[1:]...)
 	if err := s.CreateEntry(ctx, adminUser.Entry); err != nil {
 		return fmt.Errorf("failed to create admin user: %w", err)
 	}
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/smarzola/ldaplite/internal/models"
+	"github.com/smarzola/ldaplite/internal/schema"
 )
 
 func syncGroupMembers(ctx context.Context, tx *sql.Tx, groupEntryID int64, groupDN string, memberDNs []string, replace bool) error {
@@ -102,27 +103,11 @@ func resolveMemberEntryIDs(ctx context.Context, tx *sql.Tx, memberDNs []string)
 // through nested groups. A recursive CTE walks from the user's direct groups up
 // through parent groups with cycle protection.
 func (s *SQLiteStore) IsUserInGroup(ctx context.Context, userDN, groupDN string) (bool, error) {
-	query := `
-		WITH RECURSIVE user_groups(group_id, depth, path) AS (
-			-- Direct groups containing the user
-			SELECT gm.group_entry_id, 0, printf(',%d,', gm.group_entry_id)
-			FROM group_members gm
-			INNER JOIN entries user_entry ON gm.member_entry_id = user_entry.id
-			WHERE LOWER(user_entry.dn) = LOWER(?)
-
-			UNION ALL
-
-			-- Parent groups containing one of the user's groups
-			SELECT gm.group_entry_id, ug.depth + 1, ug.path || gm.group_entry_id || ','
-			FROM group_members gm
-			INNER JOIN user_groups ug ON gm.member_entry_id = ug.group_id
-			WHERE ug.depth < 100
-			  AND instr(ug.path, printf(',%d,', gm.group_entry_id)) = 0
-		)
+	query := schema.TransitiveGroupsCTE + `
 		SELECT EXISTS(
 			SELECT 1
-			FROM user_groups ug
-			INNER JOIN entries group_entry ON ug.group_id = group_entry.id
+			FROM member_groups mg
+			INNER JOIN entries group_entry ON mg.group_id = group_entry.id
 			WHERE LOWER(group_entry.dn) = LOWER(?)
 		)
 	`
@@ -142,7 +127,7 @@ func (s *SQLiteStore) IsUserInGroup(ctx context.Context, userDN, groupDN string)
 // 1. Collect all user entry IDs
 // 2. Single query to get all group memberships for those users
 // 3. Populate memberOf as a computed attribute for each user entry
-func (s *SQLiteStore) populateMemberOf(ctx context.Context, entries []*models.Entry) error {
+func populateMemberOf(ctx context.Context, q queryer, entries []*models.Entry) error {
 	if len(entries) == 0 {
 		return nil
 	}
@@ -191,7 +176,7 @@ func (s *SQLiteStore) populateMemberOf(ctx context.Context, entries []*models.En
 		FROM memberships
 	`
 
-	rows, err := s.db.QueryContext(ctx, query, args...)
+	rows, err := q.QueryContext(ctx, query, args...)
 	if err != nil {
 		return fmt.Errorf("failed to query group memberships: %w", err)
 	}
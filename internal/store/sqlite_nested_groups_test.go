@@ -148,6 +148,74 @@ func TestMemberOfFilterFastPathHandlesNestedGroupCycle(t *testing.T) {
 	}
 }
 
+func TestInChainFiltersFollowNestedGroups(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	engineering := models.NewGroup("ou=groups,dc=test,dc=com", "engineering", "Engineering group")
+	engineering.AddMember("cn=developers,ou=groups,dc=test,dc=com")
+	if err := store.CreateEntry(ctx, engineering.Entry); err != nil {
+		t.Fatalf("CreateEntry(engineering) failed: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		filter string
+		want   []string
+	}{
+		{
+			name:   "memberOf in chain",
+			filter: "(memberOf:1.2.840.113556.1.4.1941:=cn=engineering,ou=groups,dc=test,dc=com)",
+			want: []string{
+				"cn=developers,ou=groups,dc=test,dc=com",
+				"uid=jsmith,ou=users,dc=test,dc=com",
+				"uid=bob,ou=users,dc=test,dc=com",
+			},
+		},
+		{
+			name:   "member in chain",
+			filter: "(member:1.2.840.113556.1.4.1941:=uid=bob,ou=users,dc=test,dc=com)",
+			want: []string{
+				"cn=developers,ou=groups,dc=test,dc=com",
+				"cn=engineering,ou=groups,dc=test,dc=com",
+			},
+		},
+		{
+			// dnAttributes cannot be compiled, so the in-chain filter is
+			// resolved before matching in memory.
+			name:   "in chain with in-memory filter",
+			filter: "(&(memberOf:1.2.840.113556.1.4.1941:=cn=engineering,ou=groups,dc=test,dc=com)(ou:dn:=users))",
+			want: []string{
+				"uid=jsmith,ou=users,dc=test,dc=com",
+				"uid=bob,ou=users,dc=test,dc=com",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := store.SearchEntriesWithOptions(ctx, SearchOptions{
+				BaseDN: "dc=test,dc=com",
+				Filter: tt.filter,
+				Scope:  SearchScopeWholeSubtree,
+			})
+			if err != nil {
+				t.Fatalf("SearchEntriesWithOptions() failed: %v", err)
+			}
+			gotDNs := entryDNSet(entries)
+			for _, wantDN := range tt.want {
+				if !gotDNs[wantDN] {
+					t.Fatalf("SearchEntriesWithOptions() missing %s from %v", wantDN, entryDNs(entries))
+				}
+			}
+			if len(entries) != len(tt.want) {
+				t.Fatalf("SearchEntriesWithOptions() got %d entries, want %d: %v", len(entries), len(tt.want), entryDNs(entries))
+			}
+		})
+	}
+}
+
 func countValue(values []string, want string) int {
 	count := 0
 	for _, value := range values {
@@ -0,0 +1,252 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/smarzola/ldaplite/internal/telemetry"
+)
+
+// PasswordPolicyState is the password policy bookkeeping kept for a user:
+// the pwdChangedTime, pwdFailureTime and pwdAccountLockedTime operational
+// attributes plus the number of grace logins used since the password expired.
+type PasswordPolicyState struct {
+	ChangedTime       time.Time   // zero when unknown
+	AccountLockedTime time.Time   // zero when the account is not locked
+	FailureTimes      []time.Time // oldest first
+	GraceUseCount     int
+}
+
+// GetPasswordPolicyState returns the password policy state of a user entry,
+// or nil when the DN is not a user.
+func (s *SQLiteStore) GetPasswordPolicyState(ctx context.Context, dn string) (state *PasswordPolicyState, err error) {
+	ctx, span := telemetry.StartStoreSpan(ctx, "GetPasswordPolicyState")
+	defer func() {
+		telemetry.EndStoreSpan(span, err)
+	}()
+
+	var entryID int64
+	var changedTime, lockedTime sql.NullTime
+	state = &PasswordPolicyState{}
+	err = s.db.QueryRowContext(ctx, `
+		SELECT u.entry_id, u.pwd_changed_time, u.pwd_account_locked_time, u.pwd_grace_use_count
+		FROM users u
+		INNER JOIN entries e ON u.entry_id = e.id
+		WHERE LOWER(e.dn) = LOWER(?)
+	`, dn).Scan(&entryID, &changedTime, &lockedTime, &state.GraceUseCount)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get password policy state: %w", err)
+	}
+	state.ChangedTime = changedTime.Time
+	state.AccountLockedTime = lockedTime.Time
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT failure_time FROM password_failures WHERE entry_id = ? ORDER BY failure_time, id`, entryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get password failures: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var failureTime time.Time
+		if err := rows.Scan(&failureTime); err != nil {
+			return nil, fmt.Errorf("failed to scan password failure: %w", err)
+		}
+		state.FailureTimes = append(state.FailureTimes, failureTime)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate password failures: %w", err)
+	}
+	return state, nil
+}
+
+// RecordPasswordFailure stores a failed bind for a user and forgets failures
+// older than expireBefore (a zero time keeps them all). When maxFailures is
+// positive and the failures kept reach it, the account is locked at
+// failureTime. Counting and locking happen in the transaction that records
+// the failure, so concurrent failed binds cannot all miss the limit. locked
+// reports whether this failure locked the account.
+func (s *SQLiteStore) RecordPasswordFailure(ctx context.Context, dn string, failureTime, expireBefore time.Time, maxFailures int) (locked bool, err error) {
+	ctx, span := telemetry.StartStoreSpan(ctx, "RecordPasswordFailure")
+	defer func() {
+		telemetry.EndStoreSpan(span, err)
+	}()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	entryID, err := userEntryIDTx(ctx, tx, dn)
+	if err != nil {
+		return false, err
+	}
+	if !expireBefore.IsZero() {
+		if _, err := tx.ExecContext(ctx,
+			`DELETE FROM password_failures WHERE entry_id = ? AND failure_time < ?`, entryID, expireBefore); err != nil {
+			return false, fmt.Errorf("failed to expire password failures: %w", err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO password_failures (entry_id, failure_time) VALUES (?, ?)`, entryID, failureTime); err != nil {
+		return false, fmt.Errorf("failed to record password failure: %w", err)
+	}
+	if maxFailures > 0 {
+		var failures int
+		if err := tx.QueryRowContext(ctx,
+			`SELECT COUNT(*) FROM password_failures WHERE entry_id = ?`, entryID).Scan(&failures); err != nil {
+			return false, fmt.Errorf("failed to count password failures: %w", err)
+		}
+		if failures >= maxFailures {
+			// An account that is already locked keeps its original lock time.
+			result, err := tx.ExecContext(ctx,
+				`UPDATE users SET pwd_account_locked_time = ? WHERE entry_id = ? AND pwd_account_locked_time IS NULL`, failureTime, entryID)
+			if err != nil {
+				return false, fmt.Errorf("failed to lock account: %w", err)
+			}
+			rowsAffected, err := result.RowsAffected()
+			if err != nil {
+				return false, fmt.Errorf("failed to verify account lock: %w", err)
+			}
+			locked = rowsAffected > 0
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit password failure: %w", err)
+	}
+	return locked, nil
+}
+
+// ResetPasswordFailures forgets the failed binds of a user and unlocks the
+// account.
+func (s *SQLiteStore) ResetPasswordFailures(ctx context.Context, dn string) (err error) {
+	ctx, span := telemetry.StartStoreSpan(ctx, "ResetPasswordFailures")
+	defer func() {
+		telemetry.EndStoreSpan(span, err)
+	}()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	entryID, err := userEntryIDTx(ctx, tx, dn)
+	if err != nil {
+		return err
+	}
+	if err := resetPasswordFailuresTx(ctx, tx, entryID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RecordGraceLogin counts a bind made with an expired password.
+func (s *SQLiteStore) RecordGraceLogin(ctx context.Context, dn string) (err error) {
+	ctx, span := telemetry.StartStoreSpan(ctx, "RecordGraceLogin")
+	defer func() {
+		telemetry.EndStoreSpan(span, err)
+	}()
+
+	_, err = s.db.ExecContext(ctx, `
+		UPDATE users SET pwd_grace_use_count = pwd_grace_use_count + 1
+		WHERE entry_id = (SELECT id FROM entries WHERE LOWER(dn) = LOWER(?))
+	`, dn)
+	if err != nil {
+		return fmt.Errorf("failed to record grace login: %w", err)
+	}
+	return nil
+}
+
+// GetPasswordHistory returns the previous password hashes of a user, newest
+// first. It holds at most the configured history count.
+func (s *SQLiteStore) GetPasswordHistory(ctx context.Context, dn string) (hashes []string, err error) {
+	ctx, span := telemetry.StartStoreSpan(ctx, "GetPasswordHistory")
+	defer func() {
+		telemetry.EndStoreSpan(span, err)
+	}()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT h.password_hash
+		FROM password_history h
+		INNER JOIN entries e ON h.entry_id = e.id
+		WHERE LOWER(e.dn) = LOWER(?)
+		ORDER BY h.changed_at DESC, h.id DESC
+	`, dn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get password history: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, fmt.Errorf("failed to scan password history: %w", err)
+		}
+		hashes = append(hashes, hash)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate password history: %w", err)
+	}
+	return hashes, nil
+}
+
+// recordPasswordChangeTx updates the policy state when a user's password
+// changes: the old hash joins the history, the change time restarts the
+// maximum age, and grace logins, failures and any lock are cleared.
+func (s *SQLiteStore) recordPasswordChangeTx(ctx context.Context, tx *sql.Tx, entryID int64, oldHash string) error {
+	now := time.Now()
+	historyCount := s.cfg.Security.PasswordPolicy.HistoryCount
+	if oldHash != "" && historyCount > 0 {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO password_history (entry_id, password_hash, changed_at) VALUES (?, ?, ?)`,
+			entryID, oldHash, now); err != nil {
+			return fmt.Errorf("failed to record password history: %w", err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM password_history
+		WHERE entry_id = ? AND id NOT IN (
+			SELECT id FROM password_history WHERE entry_id = ? ORDER BY changed_at DESC, id DESC LIMIT ?
+		)
+	`, entryID, entryID, historyCount); err != nil {
+		return fmt.Errorf("failed to trim password history: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE users SET pwd_changed_time = ?, pwd_grace_use_count = 0 WHERE entry_id = ?`, now, entryID); err != nil {
+		return fmt.Errorf("failed to record password change: %w", err)
+	}
+	return resetPasswordFailuresTx(ctx, tx, entryID)
+}
+
+func resetPasswordFailuresTx(ctx context.Context, tx *sql.Tx, entryID int64) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM password_failures WHERE entry_id = ?`, entryID); err != nil {
+		return fmt.Errorf("failed to reset password failures: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE users SET pwd_account_locked_time = NULL WHERE entry_id = ?`, entryID); err != nil {
+		return fmt.Errorf("failed to unlock account: %w", err)
+	}
+	return nil
+}
+
+func userEntryIDTx(ctx context.Context, tx *sql.Tx, dn string) (int64, error) {
+	var entryID int64
+	err := tx.QueryRowContext(ctx, `
+		SELECT u.entry_id
+		FROM users u
+		INNER JOIN entries e ON u.entry_id = e.id
+		WHERE LOWER(e.dn) = LOWER(?)
+	`, dn).Scan(&entryID)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("%w: user not found: %s", ErrNoSuchObject, dn)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get user entry ID: %w", err)
+	}
+	return entryID, nil
+}
@@ -0,0 +1,84 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/smarzola/ldaplite/internal/models"
+)
+
+func TestPasswordPolicyStateFollowsPasswordChanges(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+	store.cfg.Security.PasswordPolicy.HistoryCount = 1
+	ctx := context.Background()
+
+	const firstHash = "{ARGON2ID}$argon2id$v=19$m=65536,t=3,p=2$Zmlyc3Q$firsthash"
+	user := models.NewUser("ou=users,dc=test,dc=com", "policyuser", "Policy", "User", "policy@example.com")
+	user.SetPassword(firstHash)
+	if err := store.CreateEntry(ctx, user.Entry); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	state, err := store.GetPasswordPolicyState(ctx, user.DN)
+	if err != nil || state == nil {
+		t.Fatalf("GetPasswordPolicyState = %+v, %v; want state", state, err)
+	}
+	if state.ChangedTime.IsZero() {
+		t.Fatal("pwdChangedTime not set on create")
+	}
+
+	failure := time.Now().Add(-time.Minute)
+	if locked, err := store.RecordPasswordFailure(ctx, user.DN, failure, time.Time{}, 2); err != nil || locked {
+		t.Fatalf("RecordPasswordFailure = %v, %v; want recorded without a lock", locked, err)
+	}
+	// The expired failure no longer counts towards the limit.
+	if locked, err := store.RecordPasswordFailure(ctx, user.DN, time.Now(), failure.Add(time.Second), 2); err != nil || locked {
+		t.Fatalf("RecordPasswordFailure = %v, %v; want recorded without a lock", locked, err)
+	}
+	if locked, err := store.RecordPasswordFailure(ctx, user.DN, time.Now(), failure.Add(time.Second), 2); err != nil || !locked {
+		t.Fatalf("RecordPasswordFailure = %v, %v; want the account locked", locked, err)
+	}
+	state, err = store.GetPasswordPolicyState(ctx, user.DN)
+	if err != nil {
+		t.Fatalf("GetPasswordPolicyState failed: %v", err)
+	}
+	if len(state.FailureTimes) != 2 || state.AccountLockedTime.IsZero() {
+		t.Fatalf("state after failures = %+v, want two recent failures and a lock", state)
+	}
+
+	// Changing the password records history, unlocks and clears failures.
+	for _, hash := range []string{
+		"{ARGON2ID}$argon2id$v=19$m=65536,t=3,p=2$c2Vjb25k$secondhash",
+		"{ARGON2ID}$argon2id$v=19$m=65536,t=3,p=2$dGhpcmQ$thirdhash",
+	} {
+		entry, err := store.GetEntry(ctx, user.DN)
+		if err != nil {
+			t.Fatalf("Failed to get user: %v", err)
+		}
+		entry.SetAttribute("userPassword", hash)
+		if err := store.UpdateEntry(ctx, entry); err != nil {
+			t.Fatalf("Failed to update user: %v", err)
+		}
+	}
+
+	history, err := store.GetPasswordHistory(ctx, user.DN)
+	if err != nil {
+		t.Fatalf("GetPasswordHistory failed: %v", err)
+	}
+	if len(history) != 1 || history[0] != "{ARGON2ID}$argon2id$v=19$m=65536,t=3,p=2$c2Vjb25k$secondhash" {
+		t.Fatalf("history = %v, want only the previous hash", history)
+	}
+	state, err = store.GetPasswordPolicyState(ctx, user.DN)
+	if err != nil {
+		t.Fatalf("GetPasswordPolicyState failed: %v", err)
+	}
+	if len(state.FailureTimes) != 0 || !state.AccountLockedTime.IsZero() {
+		t.Fatalf("state after password change = %+v, want unlocked without failures", state)
+	}
+
+	if state, err := store.GetPasswordPolicyState(ctx, "ou=users,dc=test,dc=com"); err != nil || state != nil {
+		t.Fatalf("GetPasswordPolicyState(OU) = %+v, %v; want nil", state, err)
+	}
+}
@@ -0,0 +1,276 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/smarzola/ldaplite/internal/ldapdn"
+	"github.com/smarzola/ldaplite/internal/telemetry"
+)
+
+// dnValuedAttributes lists generic attributes whose values reference other
+// entries by DN. Their values follow renamed entries and are removed with
+// deleted ones; owner grants group ownership, so a stale value must not
+// outlive the entry it names.
+var dnValuedAttributes = []string{"member", "owner"}
+
+// RenameEntry renames or moves an entry together with its whole subtree:
+//
+// 1. dn and parent_dn are rewritten for the entry and every descendant
+// 2. the new RDN value is added to the entry, and the old one is removed when requested
+// 3. DN-valued attributes (member, owner) referencing a renamed entry are rewritten
+//
+// group_members rows are keyed by entry ID and stay valid, and entryUUID is
+// never touched, so the renamed entries keep their stable identifiers.
+func (s *SQLiteStore) RenameEntry(ctx context.Context, dn string, options RenameOptions) (newDN string, err error) {
+	ctx, span := telemetry.StartStoreSpan(ctx, "RenameEntry")
+	defer func() {
+		telemetry.EndStoreSpan(span, err)
+	}()
+
+	newDN, _, err = s.renameEntry(ctx, dn, options, WriteOptions{})
+	return newDN, err
+}
+
+// RenameEntryWithOptions renames an entry like RenameEntry, once the entry
+// still matches the assertion inside the rename transaction.
+func (s *SQLiteStore) RenameEntryWithOptions(ctx context.Context, dn string, rename RenameOptions, options WriteOptions) (newDN string, result WriteResult, err error) {
+	ctx, span := telemetry.StartStoreSpan(ctx, "RenameEntryWithOptions")
+	defer func() {
+		telemetry.EndStoreSpan(span, err)
+	}()
+
+	return s.renameEntry(ctx, dn, rename, options)
+}
+
+func (s *SQLiteStore) renameEntry(ctx context.Context, dn string, options RenameOptions, write WriteOptions) (string, WriteResult, error) {
+	newRDN := strings.TrimSpace(options.NewRDN)
+	rdnName, rdnValue, ok := ldapdn.SplitRDN(newRDN)
+	if !ok || rdnValue == "" {
+		return "", WriteResult{}, fmt.Errorf("%w: invalid RDN: %s", ErrConstraintViolation, options.NewRDN)
+	}
+	if !isGenericStoredAttribute(rdnName) || strings.EqualFold(rdnName, "entryUUID") {
+		return "", WriteResult{}, fmt.Errorf("%w: RDN attribute is server-managed: %s", ErrUnwillingToPerform, rdnName)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", WriteResult{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var entryID int64
+	var oldDN, parentDN string
+	err = tx.QueryRowContext(ctx, `SELECT id, dn, parent_dn FROM entries WHERE LOWER(dn) = LOWER(?)`, dn).Scan(&entryID, &oldDN, &parentDN)
+	if err == sql.ErrNoRows {
+		return "", WriteResult{}, fmt.Errorf("%w: entry not found: %s", ErrNoSuchObject, dn)
+	}
+	if err != nil {
+		return "", WriteResult{}, fmt.Errorf("failed to get entry: %w", err)
+	}
+
+	var result WriteResult
+	if write.Assertion != "" || write.ReturnEntries {
+		entry, err := getEntryTx(ctx, tx, oldDN)
+		if err != nil {
+			return "", WriteResult{}, err
+		}
+		if err := checkAssertionTx(ctx, tx, entry, write.Assertion); err != nil {
+			return "", WriteResult{}, err
+		}
+		if write.ReturnEntries {
+			result.Before = entry
+		}
+	}
+
+	baseDN := strings.TrimSpace(s.cfg.LDAP.BaseDN)
+	if ldapdn.Equal(oldDN, baseDN) {
+		return "", WriteResult{}, fmt.Errorf("%w: base DN cannot be renamed: %s", ErrUnwillingToPerform, oldDN)
+	}
+
+	newParentDN := parentDN
+	if options.NewSuperior != "" {
+		newParentDN = strings.TrimSpace(options.NewSuperior)
+	}
+	newDN := newRDN + "," + newParentDN
+
+	if !ldapdn.WithinBase(newParentDN, baseDN) {
+		return "", WriteResult{}, fmt.Errorf("%w: entry DN %s is outside base DN %s", ErrConstraintViolation, newDN, baseDN)
+	}
+	if _, below := ldapdn.Rebase(newParentDN, oldDN, oldDN); below {
+		return "", WriteResult{}, fmt.Errorf("%w: entry cannot be moved below itself: %s", ErrUnwillingToPerform, oldDN)
+	}
+	if !ldapdn.Equal(newParentDN, parentDN) {
+		exists, err := entryExistsTx(ctx, tx, newParentDN)
+		if err != nil {
+			return "", WriteResult{}, fmt.Errorf("failed to verify parent DN: %w", err)
+		}
+		if !exists {
+			return "", WriteResult{}, fmt.Errorf("%w: parent DN does not exist: %s", ErrNoSuchObject, newParentDN)
+		}
+	}
+	if !ldapdn.Equal(newDN, oldDN) {
+		exists, err := entryExistsTx(ctx, tx, newDN)
+		if err != nil {
+			return "", WriteResult{}, fmt.Errorf("failed to check entry existence: %w", err)
+		}
+		if exists {
+			return "", WriteResult{}, fmt.Errorf("%w: %s", ErrEntryAlreadyExists, newDN)
+		}
+	}
+
+	if err := rebaseSubtreeTx(ctx, tx, entryID, oldDN, newDN); err != nil {
+		return "", WriteResult{}, err
+	}
+	if err := updateRDNAttributesTx(ctx, tx, entryID, ldapdn.RDN(oldDN), newRDN, options.DeleteOldRDN); err != nil {
+		return "", WriteResult{}, err
+	}
+	if err := rebaseDNValuedAttributesTx(ctx, tx, oldDN, newDN); err != nil {
+		return "", WriteResult{}, err
+	}
+
+	if write.ReturnEntries {
+		if result.After, err = getEntryTx(ctx, tx, newDN); err != nil {
+			return "", WriteResult{}, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", WriteResult{}, fmt.Errorf("failed to commit rename: %w", err)
+	}
+	return newDN, result, nil
+}
+
+// rebaseSubtreeTx rewrites dn and parent_dn for the renamed entry and all of
+// its descendants. Only the renamed entry gets a new modifyTimestamp.
+func rebaseSubtreeTx(ctx context.Context, tx *sql.Tx, entryID int64, oldDN, newDN string) error {
+	subtree, err := readSubtreeTx(ctx, tx, entryID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, entry := range subtree {
+		renamedDN, ok := ldapdn.Rebase(entry.dn, oldDN, newDN)
+		if !ok {
+			return fmt.Errorf("subtree entry %s is not below %s", entry.dn, oldDN)
+		}
+		var err error
+		if entry.id == entryID {
+			_, err = tx.ExecContext(ctx,
+				`UPDATE entries SET dn = ?, parent_dn = ?, updated_at = ? WHERE id = ?`,
+				renamedDN, ldapdn.Parent(renamedDN), now, entry.id,
+			)
+		} else {
+			_, err = tx.ExecContext(ctx,
+				`UPDATE entries SET dn = ?, parent_dn = ? WHERE id = ?`,
+				renamedDN, ldapdn.Parent(renamedDN), entry.id,
+			)
+		}
+		if err != nil {
+			if isSQLiteUniqueConstraint(err) {
+				return fmt.Errorf("%w: %s", ErrEntryAlreadyExists, renamedDN)
+			}
+			return fmt.Errorf("failed to rename entry %s: %w", entry.dn, err)
+		}
+	}
+	return nil
+}
+
+// updateRDNAttributesTx makes sure the new RDN value is present on the renamed
+// entry and, when deleteOldRDN is set, removes the old RDN value.
+func updateRDNAttributesTx(ctx context.Context, tx *sql.Tx, entryID int64, oldRDN, newRDN string, deleteOldRDN bool) error {
+	newName, newValue, _ := ldapdn.SplitRDN(newRDN)
+	oldName, oldValue, ok := ldapdn.SplitRDN(oldRDN)
+	sameValue := ok && strings.EqualFold(oldName, newName) && strings.EqualFold(oldValue, newValue)
+
+	if deleteOldRDN && ok && !sameValue {
+		if _, err := tx.ExecContext(ctx, `
+			DELETE FROM attributes
+			WHERE entry_id = ?
+			  AND LOWER(name) = LOWER(?)
+			  AND LOWER(value) = LOWER(?)
+		`, entryID, oldName, oldValue); err != nil {
+			return fmt.Errorf("failed to delete old RDN value: %w", err)
+		}
+	}
+
+	// Update in place first so case-only renames keep a single value.
+	result, err := tx.ExecContext(ctx, `
+		UPDATE attributes
+		SET value = ?
+		WHERE entry_id = ?
+		  AND LOWER(name) = LOWER(?)
+		  AND LOWER(value) = LOWER(?)
+	`, newValue, entryID, newName, newValue)
+	if err != nil {
+		return fmt.Errorf("failed to update RDN value: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to verify RDN value update: %w", err)
+	}
+	if rowsAffected > 0 {
+		return nil
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO attributes (entry_id, name, value) VALUES (?, ?, ?)`,
+		entryID, strings.ToLower(newName), newValue,
+	); err != nil {
+		return fmt.Errorf("failed to insert RDN value: %w", err)
+	}
+	return nil
+}
+
+// rebaseDNValuedAttributesTx rewrites DN-valued attributes that reference the
+// renamed entry or one of its descendants.
+func rebaseDNValuedAttributesTx(ctx context.Context, tx *sql.Tx, oldDN, newDN string) error {
+	args := make([]interface{}, 0, len(dnValuedAttributes)+2)
+	for _, name := range dnValuedAttributes {
+		args = append(args, name)
+	}
+	// LIKE narrows the candidates; Rebase below performs the exact DN match.
+	args = append(args, strings.ToLower(oldDN), "%"+strings.ToLower(oldDN))
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, value
+		FROM attributes
+		WHERE LOWER(name) IN (`+queryPlaceholders(len(dnValuedAttributes))+`)
+		  AND (LOWER(value) = ? OR LOWER(value) LIKE ?)
+	`, args...)
+	if err != nil {
+		return fmt.Errorf("failed to read DN-valued attributes: %w", err)
+	}
+
+	type attributeValue struct {
+		id    int64
+		value string
+	}
+	var updates []attributeValue
+	for rows.Next() {
+		var attr attributeValue
+		if err := rows.Scan(&attr.id, &attr.value); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan DN-valued attribute: %w", err)
+		}
+		if renamed, ok := ldapdn.Rebase(attr.value, oldDN, newDN); ok {
+			updates = append(updates, attributeValue{id: attr.id, value: renamed})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return fmt.Errorf("failed to read DN-valued attributes: %w", err)
+	}
+	rows.Close()
+
+	for _, update := range updates {
+		if _, err := tx.ExecContext(ctx, `UPDATE attributes SET value = ? WHERE id = ?`, update.value, update.id); err != nil {
+			return fmt.Errorf("failed to rewrite DN-valued attribute: %w", err)
+		}
+	}
+	return nil
+}
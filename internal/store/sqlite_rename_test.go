@@ -0,0 +1,268 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/smarzola/ldaplite/internal/models"
+)
+
+func TestRenameEntryUpdatesRDNMembershipAndKeepsEntryUUID(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	before, err := store.GetEntry(ctx, "uid=bob,ou=users,dc=test,dc=com")
+	if err != nil || before == nil {
+		t.Fatalf("GetEntry(bob) = %v, %v", before, err)
+	}
+	entryUUID := before.GetAttribute("entryUUID")
+
+	newDN, err := store.RenameEntry(ctx, "UID=BOB,OU=USERS,DC=TEST,DC=COM", RenameOptions{
+		NewRDN:       "uid=robert",
+		DeleteOldRDN: true,
+	})
+	if err != nil {
+		t.Fatalf("RenameEntry() failed: %v", err)
+	}
+	if newDN != "uid=robert,ou=users,dc=test,dc=com" {
+		t.Fatalf("RenameEntry() newDN = %q", newDN)
+	}
+
+	if old, err := store.GetEntry(ctx, "uid=bob,ou=users,dc=test,dc=com"); err != nil || old != nil {
+		t.Fatalf("old DN lookup = %v, %v, want nil", old, err)
+	}
+	renamed, err := store.GetEntry(ctx, newDN)
+	if err != nil || renamed == nil {
+		t.Fatalf("GetEntry(renamed) = %v, %v", renamed, err)
+	}
+	if got := renamed.GetAttributes("uid"); len(got) != 1 || got[0] != "robert" {
+		t.Fatalf("uid = %v, want [robert]", got)
+	}
+	if got := renamed.GetAttribute("entryUUID"); got != entryUUID {
+		t.Fatalf("entryUUID = %q, want %q", got, entryUUID)
+	}
+	if !containsValue(renamed.GetAttributes("memberOf"), "cn=developers,ou=groups,dc=test,dc=com") {
+		t.Fatalf("memberOf = %v, want developers", renamed.GetAttributes("memberOf"))
+	}
+
+	developers, err := store.GetEntry(ctx, "cn=developers,ou=groups,dc=test,dc=com")
+	if err != nil {
+		t.Fatalf("GetEntry(developers) failed: %v", err)
+	}
+	members := developers.GetAttributes("member")
+	if !containsValue(members, newDN) || containsValue(members, "uid=bob,ou=users,dc=test,dc=com") {
+		t.Fatalf("developers members = %v, want renamed DN only", members)
+	}
+
+	passwordHash, canonicalDN, err := store.GetUserPasswordHashByDN(ctx, newDN)
+	if err != nil || passwordHash == "" || canonicalDN != newDN {
+		t.Fatalf("GetUserPasswordHashByDN() = %q, %q, %v", passwordHash, canonicalDN, err)
+	}
+}
+
+func TestRenameEntryKeepsOldRDNValueWhenRequested(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	newDN, err := store.RenameEntry(ctx, "cn=admins,ou=groups,dc=test,dc=com", RenameOptions{NewRDN: "cn=operators"})
+	if err != nil {
+		t.Fatalf("RenameEntry() failed: %v", err)
+	}
+	renamed, err := store.GetEntry(ctx, newDN)
+	if err != nil || renamed == nil {
+		t.Fatalf("GetEntry(renamed) = %v, %v", renamed, err)
+	}
+	cn := renamed.GetAttributes("cn")
+	if !containsValue(cn, "admins") || !containsValue(cn, "operators") {
+		t.Fatalf("cn = %v, want old and new RDN values", cn)
+	}
+
+	isMember, err := store.IsUserInGroup(ctx, "uid=jdoe,ou=users,dc=test,dc=com", newDN)
+	if err != nil || !isMember {
+		t.Fatalf("IsUserInGroup(renamed group) = %v, %v, want true", isMember, err)
+	}
+}
+
+func TestRenameEntryMovesSubtree(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	for _, ou := range []*models.OrganizationalUnit{
+		models.NewOrganizationalUnit("dc=test,dc=com", "people", "People"),
+		models.NewOrganizationalUnit("ou=people,dc=test,dc=com", "eng", "Engineering"),
+		models.NewOrganizationalUnit("dc=test,dc=com", "archive", "Archive"),
+	} {
+		if err := store.CreateEntry(ctx, ou.Entry); err != nil {
+			t.Fatalf("CreateEntry(%s) failed: %v", ou.DN, err)
+		}
+	}
+	user := models.NewUser("ou=eng,ou=people,dc=test,dc=com", "carol", "Carol", "C", "carol@test.com")
+	user.SetPassword("{ARGON2ID}$argon2id$v=19$m=65536,t=3,p=2$dummyhash$dummyhash")
+	if err := store.CreateEntry(ctx, user.Entry); err != nil {
+		t.Fatalf("CreateEntry(carol) failed: %v", err)
+	}
+	group := models.NewGroup("ou=groups,dc=test,dc=com", "eng", "Engineering")
+	group.AddMember(user.DN)
+	if err := store.CreateEntry(ctx, group.Entry); err != nil {
+		t.Fatalf("CreateEntry(group) failed: %v", err)
+	}
+
+	newDN, err := store.RenameEntry(ctx, "ou=people,dc=test,dc=com", RenameOptions{
+		NewRDN:       "ou=people",
+		DeleteOldRDN: true,
+		NewSuperior:  "ou=archive,dc=test,dc=com",
+	})
+	if err != nil {
+		t.Fatalf("RenameEntry() failed: %v", err)
+	}
+	if newDN != "ou=people,ou=archive,dc=test,dc=com" {
+		t.Fatalf("RenameEntry() newDN = %q", newDN)
+	}
+
+	entries, err := store.SearchEntriesWithOptions(ctx, SearchOptions{
+		BaseDN: "ou=archive,dc=test,dc=com",
+		Scope:  SearchScopeWholeSubtree,
+	})
+	if err != nil {
+		t.Fatalf("SearchEntriesWithOptions() failed: %v", err)
+	}
+	dns := entryDNSet(entries)
+	for _, want := range []string{
+		"ou=people,ou=archive,dc=test,dc=com",
+		"ou=eng,ou=people,ou=archive,dc=test,dc=com",
+		"uid=carol,ou=eng,ou=people,ou=archive,dc=test,dc=com",
+	} {
+		if !dns[want] {
+			t.Fatalf("moved subtree = %v, missing %s", entryDNs(entries), want)
+		}
+	}
+
+	carol, err := store.GetEntry(ctx, "uid=carol,ou=eng,ou=people,ou=archive,dc=test,dc=com")
+	if err != nil || carol == nil {
+		t.Fatalf("GetEntry(carol) = %v, %v", carol, err)
+	}
+	if carol.ParentDN != "ou=eng,ou=people,ou=archive,dc=test,dc=com" {
+		t.Fatalf("carol ParentDN = %q", carol.ParentDN)
+	}
+	if !containsValue(carol.GetAttributes("memberOf"), "cn=eng,ou=groups,dc=test,dc=com") {
+		t.Fatalf("carol memberOf = %v, want eng group", carol.GetAttributes("memberOf"))
+	}
+
+	engGroup, err := store.GetEntry(ctx, "cn=eng,ou=groups,dc=test,dc=com")
+	if err != nil {
+		t.Fatalf("GetEntry(group) failed: %v", err)
+	}
+	if got := engGroup.GetAttributes("member"); len(got) != 1 || got[0] != carol.DN {
+		t.Fatalf("group member = %v, want [%s]", got, carol.DN)
+	}
+}
+
+func TestRenameEntryRejectsInvalidTargets(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		dn      string
+		options RenameOptions
+		want    error
+	}{
+		{
+			name:    "missing entry",
+			dn:      "uid=missing,ou=users,dc=test,dc=com",
+			options: RenameOptions{NewRDN: "uid=other"},
+			want:    ErrNoSuchObject,
+		},
+		{
+			name:    "existing target",
+			dn:      "uid=bob,ou=users,dc=test,dc=com",
+			options: RenameOptions{NewRDN: "uid=alice"},
+			want:    ErrEntryAlreadyExists,
+		},
+		{
+			name:    "missing new superior",
+			dn:      "uid=bob,ou=users,dc=test,dc=com",
+			options: RenameOptions{NewRDN: "uid=bob", NewSuperior: "ou=missing,dc=test,dc=com"},
+			want:    ErrNoSuchObject,
+		},
+		{
+			name:    "move below itself",
+			dn:      "ou=users,dc=test,dc=com",
+			options: RenameOptions{NewRDN: "ou=users", NewSuperior: "uid=bob,ou=users,dc=test,dc=com"},
+			want:    ErrUnwillingToPerform,
+		},
+		{
+			name:    "base DN",
+			dn:      "dc=test,dc=com",
+			options: RenameOptions{NewRDN: "dc=other"},
+			want:    ErrUnwillingToPerform,
+		},
+		{
+			name:    "outside base DN",
+			dn:      "uid=bob,ou=users,dc=test,dc=com",
+			options: RenameOptions{NewRDN: "uid=bob", NewSuperior: "dc=other,dc=com"},
+			want:    ErrConstraintViolation,
+		},
+		{
+			name:    "server-managed RDN attribute",
+			dn:      "uid=bob,ou=users,dc=test,dc=com",
+			options: RenameOptions{NewRDN: "entryUUID=1234"},
+			want:    ErrUnwillingToPerform,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := store.RenameEntry(ctx, tt.dn, tt.options); !errors.Is(err, tt.want) {
+				t.Fatalf("RenameEntry() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	if exists, err := store.EntryExists(ctx, "uid=bob,ou=users,dc=test,dc=com"); err != nil || !exists {
+		t.Fatalf("bob should be unchanged after rejected renames: exists=%v err=%v", exists, err)
+	}
+}
+
+func TestOwnerValuesFollowRenamedAndDeletedEntries(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	const developersDN = "cn=developers,ou=groups,dc=test,dc=com"
+	if _, err := store.ModifyEntry(ctx, developersDN, WriteOptions{}, func(entry *models.Entry) error {
+		entry.SetAttributes("owner", []string{"uid=bob,ou=users,dc=test,dc=com", "uid=jsmith,ou=users,dc=test,dc=com"})
+		return nil
+	}); err != nil {
+		t.Fatalf("ModifyEntry(owner) failed: %v", err)
+	}
+
+	newDN, err := store.RenameEntry(ctx, "uid=bob,ou=users,dc=test,dc=com", RenameOptions{NewRDN: "uid=robert", DeleteOldRDN: true})
+	if err != nil {
+		t.Fatalf("RenameEntry(bob) failed: %v", err)
+	}
+	developers, err := store.GetEntry(ctx, developersDN)
+	if err != nil || developers == nil {
+		t.Fatalf("GetEntry(developers) = %v, %v", developers, err)
+	}
+	if owners := developers.GetAttributes("owner"); !containsValue(owners, newDN) || containsValue(owners, "uid=bob,ou=users,dc=test,dc=com") {
+		t.Fatalf("developers owners = %v, want the renamed DN", owners)
+	}
+
+	if err := store.DeleteEntry(ctx, newDN); err != nil {
+		t.Fatalf("DeleteEntry(robert) failed: %v", err)
+	}
+	developers, err = store.GetEntry(ctx, developersDN)
+	if err != nil || developers == nil {
+		t.Fatalf("GetEntry(developers) = %v, %v", developers, err)
+	}
+	// An entry created later at the deleted DN must not inherit ownership.
+	if owners := developers.GetAttributes("owner"); len(owners) != 1 || owners[0] != "uid=jsmith,ou=users,dc=test,dc=com" {
+		t.Fatalf("developers owners = %v, want jsmith only", owners)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/smarzola/ldaplite/internal/ldapdn"
@@ -40,36 +41,31 @@ func (s *SQLiteStore) SearchEntriesWithOptions(ctx context.Context, options Sear
 		return nil, fmt.Errorf("failed to parse filter: %w", err)
 	}
 
-	if fastEntries, handled, fastErr := s.searchEntriesFastPath(ctx, options, parsedFilter); handled {
-		return fastEntries, fastErr
+	// The fast paths return entries in ID order, so sorted searches always
+	// take the general query.
+	if len(options.SortKeys) == 0 {
+		if fastEntries, handled, fastErr := s.searchEntriesFastPath(ctx, options, parsedFilter); handled {
+			return fastEntries, fastErr
+		}
 	}
 
-	// Try to compile filter to SQL (hybrid approach)
-	compiler := schema.NewFilterCompiler()
-	var filterClause string
-	var filterArgs []interface{}
-	var useInMemoryFilter bool
-
-	if compiler.CanCompileToSQL(parsedFilter) {
-		// Compile filter to SQL WHERE clause
-		filterClause, filterArgs, err = compiler.CompileToSQL(parsedFilter)
-		if err != nil {
-			// If compilation fails, fall back to in-memory filtering
-			filterClause = "1=1"
-			filterArgs = nil
-			useInMemoryFilter = true
-		} else {
-			useInMemoryFilter = false
+	filterClause, filterArgs, useInMemoryFilter, err := searchWhereClause(parsedFilter, options)
+	if err != nil {
+		return nil, err
+	}
+	query, args, err := searchEntriesQuery(options.Scope, filterClause, options.BaseDN, filterArgs, options.SortKeys)
+	if err != nil {
+		return nil, err
+	}
+	if !useInMemoryFilter && (options.Limit > 0 || options.Offset > 0) {
+		limit := options.Limit
+		if limit == 0 {
+			limit = -1
 		}
-	} else {
-		// Filter not supported in SQL, use in-memory filtering
-		filterClause = "1=1"
-		filterArgs = nil
-		useInMemoryFilter = true
+		query += `LIMIT ? OFFSET ?`
+		args = append(args, limit, options.Offset)
 	}
 
-	query, args := searchEntriesQuery(options.Scope, filterClause, options.BaseDN, filterArgs)
-
 	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to search entries: %w", err)
@@ -89,9 +85,12 @@ func (s *SQLiteStore) SearchEntriesWithOptions(ctx context.Context, options Sear
 	filterUsesComputed := schema.FilterUsesComputedAttributes(parsedFilter)
 
 	if useInMemoryFilter {
+		if err := resolveChainFilters(ctx, s.db, parsedFilter); err != nil {
+			return nil, err
+		}
 		if filterUsesComputed {
 			// Filter needs memberOf -> populate first, then filter
-			if err := s.populateMemberOf(ctx, allEntries); err != nil {
+			if err := populateMemberOf(ctx, s.db, allEntries); err != nil {
 				return nil, fmt.Errorf("failed to populate memberOf: %w", err)
 			}
 			for _, entry := range allEntries {
@@ -106,8 +105,9 @@ func (s *SQLiteStore) SearchEntriesWithOptions(ctx context.Context, options Sear
 					entries = append(entries, entry)
 				}
 			}
+			entries = windowEntries(entries, options.Offset, options.Limit)
 			if options.IncludeMemberOf {
-				if err := s.populateMemberOf(ctx, entries); err != nil {
+				if err := populateMemberOf(ctx, s.db, entries); err != nil {
 					return nil, fmt.Errorf("failed to populate memberOf: %w", err)
 				}
 			}
@@ -115,7 +115,7 @@ func (s *SQLiteStore) SearchEntriesWithOptions(ctx context.Context, options Sear
 	} else {
 		// No in-memory filter needed - all entries pass.
 		if options.IncludeMemberOf {
-			if err := s.populateMemberOf(ctx, allEntries); err != nil {
+			if err := populateMemberOf(ctx, s.db, allEntries); err != nil {
 				return nil, fmt.Errorf("failed to populate memberOf: %w", err)
 			}
 		}
@@ -128,9 +128,143 @@ func (s *SQLiteStore) SearchEntriesWithOptions(ctx context.Context, options Sear
 		}
 	}
 
+	if useInMemoryFilter && filterUsesComputed {
+		entries = windowEntries(entries, options.Offset, options.Limit)
+	}
+
 	return entries, nil
 }
 
+// CountEntriesWithOptions counts the entries matching a search, ignoring
+// Offset and Limit. Filters that compile to SQL are counted without loading
+// entries.
+func (s *SQLiteStore) CountEntriesWithOptions(ctx context.Context, options SearchOptions) (count int, err error) {
+	ctx, span := telemetry.StartStoreSpan(ctx, "CountEntriesWithOptions")
+	defer func() {
+		telemetry.EndStoreSpan(span, err)
+	}()
+
+	filterStr := options.Filter
+	if filterStr == "" {
+		filterStr = "(objectClass=*)"
+	}
+	parsedFilter, err := schema.ParseFilter(filterStr)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse filter: %w", err)
+	}
+
+	filterClause, filterArgs, useInMemoryFilter, err := searchWhereClause(parsedFilter, options)
+	if err != nil {
+		return 0, err
+	}
+	if useInMemoryFilter {
+		options.Offset = 0
+		options.Limit = 0
+		options.IncludeMemberOf = false
+		entries, err := s.SearchEntriesWithOptions(ctx, options)
+		if err != nil {
+			return 0, err
+		}
+		return len(entries), nil
+	}
+
+	query, args := countEntriesQuery(options.Scope, filterClause, options.BaseDN, filterArgs)
+	if err := s.db.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count entries: %w", err)
+	}
+	return count, nil
+}
+
+// searchWhereClause compiles a search filter into a SQL predicate and adds
+// the AfterEntryID and SortValueBefore restrictions. When the filter cannot be
+// compiled the predicate matches every entry and useInMemoryFilter is set.
+func searchWhereClause(parsedFilter *schema.Filter, options SearchOptions) (clause string, args []interface{}, useInMemoryFilter bool, err error) {
+	// Try to compile filter to SQL (hybrid approach)
+	compiler := schema.NewFilterCompiler()
+	clause = "1=1"
+	if compiler.CanCompileToSQL(parsedFilter) {
+		compiled, compiledArgs, compileErr := compiler.CompileToSQL(parsedFilter)
+		if compileErr != nil {
+			// If compilation fails, fall back to in-memory filtering
+			useInMemoryFilter = true
+		} else {
+			clause = compiled
+			args = compiledArgs
+		}
+	} else {
+		// Filter not supported in SQL, use in-memory filtering
+		useInMemoryFilter = true
+	}
+
+	if options.AfterEntryID > 0 {
+		clause = "(" + clause + ") AND e.id > ?"
+		args = append(args, options.AfterEntryID)
+	}
+	if options.SortValueBefore != nil {
+		if len(options.SortKeys) == 0 {
+			return "", nil, false, fmt.Errorf("sort value position requires a sort key")
+		}
+		before, beforeArgs, err := sortKeyBeforeClause(options.SortKeys[0], *options.SortValueBefore)
+		if err != nil {
+			return "", nil, false, err
+		}
+		clause = "(" + clause + ") AND " + before
+		args = append(args, beforeArgs...)
+	}
+	for _, dn := range options.ExcludeSubtrees {
+		clause = "(" + clause + ") AND " + excludedSubtreeClause
+		args = append(args, dn)
+	}
+	return clause, args, useInMemoryFilter, nil
+}
+
+// excludedSubtreeClause drops the entries at or below one DN, walking
+// parent_dn like the subtree scope does.
+const excludedSubtreeClause = `e.id NOT IN (
+			WITH RECURSIVE excluded AS (
+				SELECT id, dn FROM entries WHERE LOWER(dn) = LOWER(?)
+
+				UNION ALL
+
+				SELECT c.id, c.dn
+				FROM entries c
+				INNER JOIN excluded x ON LOWER(c.parent_dn) = LOWER(x.dn)
+			)
+			SELECT id FROM excluded
+		)`
+
+// resolveChainFilters loads the matching entry IDs of every in-chain filter
+// so the filter can be evaluated in memory.
+func resolveChainFilters(ctx context.Context, q queryer, filter *schema.Filter) error {
+	if filter.IsInChain() {
+		clause, args, err := schema.NewFilterCompiler().CompileToSQL(filter)
+		if err != nil {
+			return err
+		}
+		rows, err := q.QueryContext(ctx, `SELECT e.id FROM entries e WHERE `+clause, args...)
+		if err != nil {
+			return fmt.Errorf("failed to resolve in-chain filter: %w", err)
+		}
+		defer rows.Close()
+
+		filter.ChainEntryIDs = make(map[int64]bool)
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				return fmt.Errorf("failed to scan in-chain entry: %w", err)
+			}
+			filter.ChainEntryIDs[id] = true
+		}
+		return rows.Err()
+	}
+	for _, sf := range filter.Filters {
+		if err := resolveChainFilters(ctx, q, sf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (s *SQLiteStore) searchEntriesFastPath(ctx context.Context, options SearchOptions, parsedFilter *schema.Filter) ([]*models.Entry, bool, error) {
 	if groupDN, ok := schema.MemberOfEqualityValue(parsedFilter); ok {
 		entries, err := s.searchEntriesByMemberOfEquality(ctx, groupDN, options)
@@ -170,9 +304,9 @@ func (s *SQLiteStore) searchEntriesByAttributeEquality(ctx context.Context, attr
 		return nil, err
 	}
 
-	entries = filterEntriesByScope(entries, options.BaseDN, options.Scope)
+	entries = windowEntries(filterEntriesByScope(entries, options), options.Offset, options.Limit)
 	if options.IncludeMemberOf {
-		if err := s.populateMemberOf(ctx, entries); err != nil {
+		if err := populateMemberOf(ctx, s.db, entries); err != nil {
 			return nil, fmt.Errorf("failed to populate memberOf: %w", err)
 		}
 	}
@@ -181,23 +315,7 @@ func (s *SQLiteStore) searchEntriesByAttributeEquality(ctx context.Context, attr
 }
 
 func (s *SQLiteStore) searchEntriesByMemberOfEquality(ctx context.Context, groupDN string, options SearchOptions) ([]*models.Entry, error) {
-	query := `
-		WITH RECURSIVE members(entry_id, depth, path) AS (
-			SELECT gm.member_entry_id, 0, printf(',%d,', gm.member_entry_id)
-			FROM group_members gm
-			INNER JOIN entries target_group ON gm.group_entry_id = target_group.id
-			WHERE LOWER(target_group.dn) = LOWER(?)
-
-			UNION ALL
-
-			SELECT gm.member_entry_id, m.depth + 1, m.path || gm.member_entry_id || ','
-			FROM members m
-			INNER JOIN entries member_group ON m.entry_id = member_group.id
-			INNER JOIN group_members gm ON gm.group_entry_id = member_group.id
-			WHERE member_group.object_class = 'groupOfNames'
-			  AND m.depth < 100
-			  AND instr(m.path, printf(',%d,', gm.member_entry_id)) = 0
-		),
+	query := schema.TransitiveMembersCTE + `,
 		matched_entries AS (
 			SELECT DISTINCT e.id, e.dn, e.parent_dn, e.object_class, e.created_at, e.updated_at
 			FROM members m
@@ -223,9 +341,9 @@ func (s *SQLiteStore) searchEntriesByMemberOfEquality(ctx context.Context, group
 		return nil, err
 	}
 
-	entries = filterEntriesByScope(entries, options.BaseDN, options.Scope)
+	entries = windowEntries(filterEntriesByScope(entries, options), options.Offset, options.Limit)
 	if options.IncludeMemberOf {
-		if err := s.populateMemberOf(ctx, entries); err != nil {
+		if err := populateMemberOf(ctx, s.db, entries); err != nil {
 			return nil, fmt.Errorf("failed to populate memberOf: %w", err)
 		}
 	}
@@ -282,16 +400,32 @@ func scanEntriesWithAttributeRows(rows *sql.Rows) ([]*models.Entry, error) {
 	return entries, nil
 }
 
-func filterEntriesByScope(entries []*models.Entry, baseDN string, scope SearchScope) []*models.Entry {
+// filterEntriesByScope keeps the entries within the search scope that come
+// after options.AfterEntryID and lie outside options.ExcludeSubtrees.
+func filterEntriesByScope(entries []*models.Entry, options SearchOptions) []*models.Entry {
 	filtered := entries[:0]
 	for _, entry := range entries {
-		if entryInSearchScope(entry, baseDN, scope) {
+		if entry.ID > options.AfterEntryID && entryInSearchScope(entry, options.BaseDN, options.Scope) &&
+			!slices.ContainsFunc(options.ExcludeSubtrees, func(dn string) bool { return ldapdn.WithinBase(entry.DN, dn) }) {
 			filtered = append(filtered, entry)
 		}
 	}
 	return filtered
 }
 
+// windowEntries skips offset entries and caps the rest at limit. Zero limit
+// means no cap.
+func windowEntries(entries []*models.Entry, offset, limit int) []*models.Entry {
+	if offset >= len(entries) {
+		return nil
+	}
+	entries = entries[offset:]
+	if limit > 0 && len(entries) > limit {
+		return entries[:limit]
+	}
+	return entries
+}
+
 func entryInSearchScope(entry *models.Entry, baseDN string, scope SearchScope) bool {
 	switch scope {
 	case SearchScopeBaseObject:
@@ -303,8 +437,14 @@ func entryInSearchScope(entry *models.Entry, baseDN string, scope SearchScope) b
 	}
 }
 
-func searchEntriesQuery(scope SearchScope, filterClause string, baseDN string, filterArgs []interface{}) (string, []interface{}) {
-	selectClause := `
+func searchEntriesQuery(scope SearchScope, filterClause string, baseDN string, filterArgs []interface{}, sortKeys []SortKey) (string, []interface{}, error) {
+	orderBy, orderArgs, err := searchOrderByClause(sortKeys)
+	if err != nil {
+		return "", nil, err
+	}
+
+	with, from, where, args := searchScopeSource(scope, baseDN, filterArgs)
+	return with + `
 		SELECT
 			e.id, e.dn, e.parent_dn, e.object_class, e.created_at, e.updated_at,
 			json_group_array(
@@ -312,35 +452,46 @@ func searchEntriesQuery(scope SearchScope, filterClause string, baseDN string, f
 				THEN json_object('name', a.name, 'value', a.value)
 				ELSE NULL END
 			) as attributes_json
-	`
-	joinWhere := `
+	` + from + `
 		LEFT JOIN attributes a ON e.id = a.entry_id
 		WHERE (` + filterClause + `)
-	`
-	groupBy := `
+	` + where + `
 		GROUP BY e.id, e.dn, e.parent_dn, e.object_class, e.created_at, e.updated_at
-	`
+	` + orderBy, append(args, orderArgs...), nil
+}
+
+// countEntriesQuery counts the entries of searchEntriesQuery without joining
+// their attributes.
+func countEntriesQuery(scope SearchScope, filterClause string, baseDN string, filterArgs []interface{}) (string, []interface{}) {
+	with, from, where, args := searchScopeSource(scope, baseDN, filterArgs)
+	return with + `
+		SELECT COUNT(*)
+	` + from + `
+		WHERE (` + filterClause + `)
+	` + where, args
+}
 
+// searchScopeSource returns the WITH clause, FROM clause, and extra WHERE
+// condition that select the entries of a scope, with the filter arguments and
+// base DN in placeholder order.
+func searchScopeSource(scope SearchScope, baseDN string, filterArgs []interface{}) (with, from, where string, args []interface{}) {
 	switch scope {
 	case SearchScopeBaseObject:
-		args := append([]interface{}{}, filterArgs...)
-		args = append(args, baseDN)
-		return selectClause + `
+		args = append(append(args, filterArgs...), baseDN)
+		return "", `
 		FROM entries e
-	` + joinWhere + `
+	`, `
 		  AND LOWER(e.dn) = LOWER(?)
-	` + groupBy, args
+	`, args
 	case SearchScopeSingleLevel:
-		args := append([]interface{}{}, filterArgs...)
-		args = append(args, baseDN)
-		return selectClause + `
+		args = append(append(args, filterArgs...), baseDN)
+		return "", `
 		FROM entries e
-	` + joinWhere + `
+	`, `
 		  AND LOWER(e.parent_dn) = LOWER(?)
-	` + groupBy, args
+	`, args
 	default:
-		args := []interface{}{baseDN}
-		args = append(args, filterArgs...)
+		args = append(append(args, baseDN), filterArgs...)
 		// Recursive CTE for subtree traversal. This avoids leading % LIKE
 		// patterns and uses the parent_dn index for each level.
 		return `
@@ -356,8 +507,8 @@ func searchEntriesQuery(scope SearchScope, filterClause string, baseDN string, f
 			INNER JOIN subtree s ON LOWER(e.parent_dn) = LOWER(s.dn)
 			WHERE s.depth < 100
 		)
-	` + selectClause + `
+	`, `
 		FROM subtree e
-	` + joinWhere + groupBy, args
+	`, "", args
 	}
 }
@@ -2,6 +2,7 @@ package store
 
 import (
 	"context"
+	"errors"
 	"strings"
 	"testing"
 	"time"
@@ -337,10 +338,282 @@ func TestSearchEntriesWithOptionsHonorsScope(t *testing.T) {
 	}
 }
 
+func TestSearchEntriesWithOptionsResumesAfterEntryID(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	filters := []string{
+		"(objectClass=*)",
+		"(uid=bob)",
+		"(memberOf=cn=developers,ou=groups,dc=test,dc=com)",
+		"(|(uid=jdoe)(cn=developers)(ou=users))",
+		"(|(uid=jdoe)(memberOf=cn=developers,ou=groups,dc=test,dc=com))",
+	}
+
+	for _, filter := range filters {
+		t.Run(filter, func(t *testing.T) {
+			options := SearchOptions{
+				BaseDN: "dc=test,dc=com",
+				Filter: filter,
+				Scope:  SearchScopeWholeSubtree,
+			}
+			all, err := store.SearchEntriesWithOptions(ctx, options)
+			if err != nil {
+				t.Fatalf("SearchEntriesWithOptions() error = %v", err)
+			}
+
+			var paged []*models.Entry
+			options.Limit = 2
+			for page := 0; page <= len(all); page++ {
+				entries, err := store.SearchEntriesWithOptions(ctx, options)
+				if err != nil {
+					t.Fatalf("SearchEntriesWithOptions(page %d) error = %v", page, err)
+				}
+				if len(entries) > options.Limit {
+					t.Fatalf("page %d has %d entries, limit %d", page, len(entries), options.Limit)
+				}
+				if len(entries) == 0 {
+					break
+				}
+				paged = append(paged, entries...)
+				options.AfterEntryID = entries[len(entries)-1].ID
+			}
+
+			if strings.Join(entryDNs(paged), ";") != strings.Join(entryDNs(all), ";") {
+				t.Fatalf("paged results = %v, want %v", entryDNs(paged), entryDNs(all))
+			}
+		})
+	}
+}
+
+func TestSearchEntriesWithOptionsExcludesSubtrees(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	// Filters that compile to SQL, take a fast path and need the in-memory
+	// filter must all drop the excluded subtree.
+	for _, filter := range []string{
+		"(objectClass=*)",
+		"(uid=bob)",
+		"(memberOf=cn=developers,ou=groups,dc=test,dc=com)",
+		"(|(uid=bob)(memberOf=cn=admins,ou=groups,dc=test,dc=com))",
+	} {
+		t.Run(filter, func(t *testing.T) {
+			options := SearchOptions{
+				BaseDN:          "dc=test,dc=com",
+				Filter:          filter,
+				Scope:           SearchScopeWholeSubtree,
+				ExcludeSubtrees: []string{"OU=Users,dc=test,dc=com"},
+			}
+			entries, err := store.SearchEntriesWithOptions(ctx, options)
+			if err != nil {
+				t.Fatalf("SearchEntriesWithOptions() error = %v", err)
+			}
+			for _, dn := range entryDNs(entries) {
+				if strings.HasSuffix(strings.ToLower(dn), "ou=users,dc=test,dc=com") {
+					t.Fatalf("SearchEntriesWithOptions() = %v, want nothing under ou=users", entryDNs(entries))
+				}
+			}
+			count, err := store.CountEntriesWithOptions(ctx, options)
+			if err != nil || count != len(entries) {
+				t.Fatalf("CountEntriesWithOptions() = %d, %v; want %d", count, err, len(entries))
+			}
+		})
+	}
+
+	entries, err := store.SearchEntriesWithOptions(ctx, SearchOptions{
+		BaseDN:          "dc=test,dc=com",
+		Filter:          "(objectClass=organizationalUnit)",
+		Scope:           SearchScopeWholeSubtree,
+		ExcludeSubtrees: []string{"ou=users,dc=test,dc=com"},
+	})
+	if err != nil {
+		t.Fatalf("SearchEntriesWithOptions(ou) error = %v", err)
+	}
+	if got := strings.Join(entryDNs(entries), ";"); got != "ou=groups,dc=test,dc=com" {
+		t.Fatalf("SearchEntriesWithOptions(ou) = %s, want only ou=groups", got)
+	}
+}
+
+func TestSearchEntriesWithOptionsSortsResults(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	users := "(|(uid=jdoe)(uid=jsmith)(uid=bob)(uid=alice))"
+	tests := []struct {
+		name     string
+		filter   string
+		sortKeys []SortKey
+		offset   int
+		limit    int
+		want     []string
+	}{
+		{
+			name:     "ascending",
+			filter:   users,
+			sortKeys: []SortKey{{Attribute: "sn"}},
+			want:     []string{"jdoe", "bob", "jsmith", "alice"},
+		},
+		{
+			name:     "reverse",
+			filter:   users,
+			sortKeys: []SortKey{{Attribute: "SN", OrderingRule: "caseIgnoreOrderingMatch", Reverse: true}},
+			want:     []string{"alice", "jsmith", "bob", "jdoe"},
+		},
+		{
+			name:     "offset and limit",
+			filter:   users,
+			sortKeys: []SortKey{{Attribute: "mail"}},
+			offset:   1,
+			limit:    2,
+			want:     []string{"bob", "jdoe"},
+		},
+		{
+			name:     "missing attribute sorts last",
+			filter:   "(|(uid=bob)(cn=admins)(cn=developers))",
+			sortKeys: []SortKey{{Attribute: "description"}},
+			want:     []string{"admins", "developers", "bob"},
+		},
+		{
+			name:     "missing attribute sorts first in reverse",
+			filter:   "(|(uid=bob)(cn=admins)(cn=developers))",
+			sortKeys: []SortKey{{Attribute: "description", Reverse: true}},
+			want:     []string{"bob", "developers", "admins"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := store.SearchEntriesWithOptions(ctx, SearchOptions{
+				BaseDN:   "dc=test,dc=com",
+				Filter:   tt.filter,
+				Scope:    SearchScopeWholeSubtree,
+				SortKeys: tt.sortKeys,
+				Offset:   tt.offset,
+				Limit:    tt.limit,
+			})
+			if err != nil {
+				t.Fatalf("SearchEntriesWithOptions() error = %v", err)
+			}
+			got := make([]string, 0, len(entries))
+			for _, entry := range entries {
+				name := entry.GetAttribute("uid")
+				if name == "" {
+					name = entry.GetAttribute("cn")
+				}
+				got = append(got, name)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("sorted results = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchEntriesWithOptionsRejectsUnsortableKeys(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		key     SortKey
+		wantErr error
+	}{
+		{name: "computed attribute", key: SortKey{Attribute: "memberOf"}, wantErr: ErrUnwillingToPerform},
+		{name: "unknown ordering rule", key: SortKey{Attribute: "sn", OrderingRule: "1.2.3.4"}, wantErr: ErrInappropriateMatching},
+		{name: "timestamp with string ordering", key: SortKey{Attribute: "createTimestamp", OrderingRule: "caseExactOrderingMatch"}, wantErr: ErrInappropriateMatching},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := store.SearchEntriesWithOptions(ctx, SearchOptions{
+				BaseDN:   "dc=test,dc=com",
+				Filter:   "(objectClass=*)",
+				Scope:    SearchScopeWholeSubtree,
+				SortKeys: []SortKey{tt.key},
+			})
+			var sortErr *SortKeyError
+			if !errors.As(err, &sortErr) || !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SearchEntriesWithOptions() error = %v, want SortKeyError wrapping %v", err, tt.wantErr)
+			}
+			if sortErr.Attribute != tt.key.Attribute {
+				t.Fatalf("SortKeyError.Attribute = %q, want %q", sortErr.Attribute, tt.key.Attribute)
+			}
+		})
+	}
+}
+
+func TestCountEntriesWithOptionsLocatesSortValues(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	users := SearchOptions{
+		BaseDN:   "ou=users,dc=test,dc=com",
+		Filter:   "(|(uid=jdoe)(uid=jsmith)(uid=bob)(uid=alice))",
+		Scope:    SearchScopeSingleLevel,
+		SortKeys: []SortKey{{Attribute: "sn"}},
+	}
+	count, err := store.CountEntriesWithOptions(ctx, users)
+	if err != nil {
+		t.Fatalf("CountEntriesWithOptions() error = %v", err)
+	}
+	if count != 4 {
+		t.Fatalf("CountEntriesWithOptions() = %d, want 4", count)
+	}
+
+	memberOf := SearchOptions{
+		BaseDN: "dc=test,dc=com",
+		Filter: "(memberOf=cn=developers,ou=groups,dc=test,dc=com)",
+		Scope:  SearchScopeWholeSubtree,
+	}
+	if count, err := store.CountEntriesWithOptions(ctx, memberOf); err != nil || count != 2 {
+		t.Fatalf("CountEntriesWithOptions(memberOf) = %d, %v, want 2", count, err)
+	}
+
+	// Sorted by sn the users are Doe, Johnson, Smith, Williams.
+	tests := []struct {
+		value   string
+		reverse bool
+		want    int
+	}{
+		{value: "a", want: 0},
+		{value: "Johnson", want: 1},
+		{value: "k", want: 2},
+		{value: "zz", want: 4},
+		{value: "k", reverse: true, want: 2},
+		{value: "Williams", reverse: true, want: 0},
+	}
+	for _, tt := range tests {
+		options := users
+		options.SortKeys = []SortKey{{Attribute: "sn", Reverse: tt.reverse}}
+		options.SortValueBefore = &tt.value
+		got, err := store.CountEntriesWithOptions(ctx, options)
+		if err != nil {
+			t.Fatalf("CountEntriesWithOptions(before %q) error = %v", tt.value, err)
+		}
+		if got != tt.want {
+			t.Fatalf("entries before %q (reverse %v) = %d, want %d", tt.value, tt.reverse, got, tt.want)
+		}
+	}
+
+	value := "20240101000000Z"
+	options := users
+	options.SortKeys = []SortKey{{Attribute: "createTimestamp"}}
+	options.SortValueBefore = &value
+	if _, err := store.CountEntriesWithOptions(ctx, options); !errors.Is(err, ErrUnwillingToPerform) {
+		t.Fatalf("CountEntriesWithOptions(before timestamp) error = %v, want ErrUnwillingToPerform", err)
+	}
+}
+
 func TestSearchEntriesQueryUsesScopeSpecificShape(t *testing.T) {
 	filterClause := "e.object_class IS NOT NULL"
 
-	baseQuery, _ := searchEntriesQuery(SearchScopeBaseObject, filterClause, "dc=test,dc=com", nil)
+	baseQuery, _, _ := searchEntriesQuery(SearchScopeBaseObject, filterClause, "dc=test,dc=com", nil, nil)
 	if strings.Contains(baseQuery, "WITH RECURSIVE") {
 		t.Fatalf("base-object query should not use recursive CTE:\n%s", baseQuery)
 	}
@@ -348,7 +621,7 @@ func TestSearchEntriesQueryUsesScopeSpecificShape(t *testing.T) {
 		t.Fatalf("base-object query should constrain DN case-insensitively:\n%s", baseQuery)
 	}
 
-	oneQuery, _ := searchEntriesQuery(SearchScopeSingleLevel, filterClause, "dc=test,dc=com", nil)
+	oneQuery, _, _ := searchEntriesQuery(SearchScopeSingleLevel, filterClause, "dc=test,dc=com", nil, nil)
 	if strings.Contains(oneQuery, "WITH RECURSIVE") {
 		t.Fatalf("single-level query should not use recursive CTE:\n%s", oneQuery)
 	}
@@ -356,7 +629,7 @@ func TestSearchEntriesQueryUsesScopeSpecificShape(t *testing.T) {
 		t.Fatalf("single-level query should constrain parent DN case-insensitively:\n%s", oneQuery)
 	}
 
-	subtreeQuery, _ := searchEntriesQuery(SearchScopeWholeSubtree, filterClause, "dc=test,dc=com", nil)
+	subtreeQuery, _, _ := searchEntriesQuery(SearchScopeWholeSubtree, filterClause, "dc=test,dc=com", nil, nil)
 	if !strings.Contains(subtreeQuery, "WITH RECURSIVE subtree") {
 		t.Fatalf("subtree query should use recursive CTE:\n%s", subtreeQuery)
 	}
@@ -0,0 +1,128 @@
+package store
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Ordering rules accepted in sort keys, by name and OID.
+var (
+	caseIgnoreOrderingRules  = []string{"caseignoreorderingmatch", "2.5.13.3"}
+	caseExactOrderingRules   = []string{"caseexactorderingmatch", "2.5.13.6"}
+	integerOrderingRules     = []string{"integerorderingmatch", "2.5.13.15"}
+	generalizedTimeOrderings = []string{"generalizedtimeorderingmatch", "2.5.13.28"}
+)
+
+// searchOrderByClause compiles sort keys into an ORDER BY clause for
+// searchEntriesQuery. Generic attributes are ordered by a correlated lookup in
+// the attributes table: the smallest value for ascending keys and the largest
+// for reverse keys. Entries lacking the attribute sort as if their value were
+// larger than any other (RFC 2891 section 2.2), so they come last in ascending
+// order and first in reverse order. Entry ID breaks ties so results stay
+// deterministic.
+func searchOrderByClause(sortKeys []SortKey) (string, []interface{}, error) {
+	terms := make([]string, 0, len(sortKeys)+1)
+	var args []interface{}
+	for _, key := range sortKeys {
+		term, termArgs, err := sortKeyTerm(key)
+		if err != nil {
+			return "", nil, err
+		}
+		terms = append(terms, term)
+		args = append(args, termArgs...)
+	}
+	terms = append(terms, "e.id")
+	return `
+		ORDER BY ` + strings.Join(terms, ", ") + `
+	`, args, nil
+}
+
+// ValidateSortKeys reports the first sort key searches cannot order by as a
+// *SortKeyError, without running a query.
+func ValidateSortKeys(sortKeys []SortKey) error {
+	_, _, err := searchOrderByClause(sortKeys)
+	return err
+}
+
+func sortKeyTerm(key SortKey) (string, []interface{}, error) {
+	expr, args, _, err := sortKeyExpression(key)
+	if err != nil {
+		return "", nil, err
+	}
+	if key.Reverse {
+		return expr + " DESC NULLS FIRST", args, nil
+	}
+	return expr + " ASC NULLS LAST", args, nil
+}
+
+// sortKeyBeforeClause restricts a search to the entries that sort before
+// value on key, which makes their count the position of the first entry at or
+// after value.
+func sortKeyBeforeClause(key SortKey, value string) (string, []interface{}, error) {
+	expr, args, valueExpr, err := sortKeyExpression(key)
+	if err != nil {
+		return "", nil, err
+	}
+	if valueExpr == "" {
+		return "", nil, &SortKeyError{Attribute: key.Attribute, Err: ErrUnwillingToPerform}
+	}
+	if key.Reverse {
+		// Entries without the attribute sort first in reverse order.
+		reverseArgs := append(append(append([]interface{}{}, args...), args...), value)
+		return "(" + expr + " IS NULL OR " + expr + " > " + valueExpr + ")", reverseArgs, nil
+	}
+	return expr + " < " + valueExpr, append(args, value), nil
+}
+
+// sortKeyExpression returns the SQL expression a sort key orders by and the
+// expression that converts a compared value the same way. The value
+// expression is empty when the key cannot be compared with client values.
+func sortKeyExpression(key SortKey) (string, []interface{}, string, error) {
+	attr := strings.ToLower(strings.TrimSpace(key.Attribute))
+	rule := strings.ToLower(strings.TrimSpace(key.OrderingRule))
+
+	switch attr {
+	case "createtimestamp", "modifytimestamp":
+		if rule != "" && !slices.Contains(generalizedTimeOrderings, rule) {
+			return "", nil, "", &SortKeyError{Attribute: key.Attribute, Err: ErrInappropriateMatching}
+		}
+		if attr == "modifytimestamp" {
+			return "e.updated_at", nil, "", nil
+		}
+		return "e.created_at", nil, "", nil
+	case "objectclass":
+		switch {
+		case rule == "" || slices.Contains(caseIgnoreOrderingRules, rule):
+			return "LOWER(e.object_class)", nil, "LOWER(?)", nil
+		case slices.Contains(caseExactOrderingRules, rule):
+			return "e.object_class", nil, "?", nil
+		default:
+			return "", nil, "", &SortKeyError{Attribute: key.Attribute, Err: ErrInappropriateMatching}
+		}
+	}
+	if attr == "" || !isGenericStoredAttribute(attr) {
+		return "", nil, "", &SortKeyError{Attribute: key.Attribute, Err: ErrUnwillingToPerform}
+	}
+
+	var value, valueExpr string
+	switch {
+	case rule == "" || slices.Contains(caseIgnoreOrderingRules, rule):
+		value, valueExpr = "LOWER(sa.value)", "LOWER(?)"
+	case slices.Contains(caseExactOrderingRules, rule):
+		value, valueExpr = "sa.value", "?"
+	case slices.Contains(integerOrderingRules, rule):
+		value, valueExpr = "CAST(sa.value AS INTEGER)", "CAST(? AS INTEGER)"
+	default:
+		return "", nil, "", &SortKeyError{Attribute: key.Attribute, Err: ErrInappropriateMatching}
+	}
+
+	aggregate := "MIN"
+	if key.Reverse {
+		aggregate = "MAX"
+	}
+	return fmt.Sprintf(
+		"(SELECT %s(%s) FROM attributes sa WHERE sa.entry_id = e.id AND LOWER(sa.name) = LOWER(?))",
+		aggregate, value,
+	), []interface{}{attr}, valueExpr, nil
+}
@@ -0,0 +1,185 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/smarzola/ldaplite/internal/models"
+)
+
+func TestModifyEntryAppliesOnlyWhileAssertionHolds(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	const developersDN = "cn=developers,ou=groups,dc=test,dc=com"
+	options := WriteOptions{Assertion: "(member=uid=bob,ou=users,dc=test,dc=com)", ReturnEntries: true}
+	replaceBob := func(entry *models.Entry) error {
+		entry.SetAttributes("member", []string{"uid=jsmith,ou=users,dc=test,dc=com", "uid=alice,ou=users,dc=test,dc=com"})
+		return nil
+	}
+
+	result, err := store.ModifyEntry(ctx, developersDN, options, replaceBob)
+	if err != nil {
+		t.Fatalf("ModifyEntry() failed: %v", err)
+	}
+	if result.Before == nil || !containsValue(result.Before.GetAttributes("member"), "uid=bob,ou=users,dc=test,dc=com") {
+		t.Fatalf("Before = %+v, want the members before the write", result.Before)
+	}
+	if result.After == nil || containsValue(result.After.GetAttributes("member"), "uid=bob,ou=users,dc=test,dc=com") ||
+		!containsValue(result.After.GetAttributes("member"), "uid=alice,ou=users,dc=test,dc=com") {
+		t.Fatalf("After = %+v, want the members after the write", result.After)
+	}
+
+	// A second writer that read the group before the first write no longer
+	// matches its assertion.
+	called := false
+	_, err = store.ModifyEntry(ctx, developersDN, options, func(entry *models.Entry) error {
+		called = true
+		return replaceBob(entry)
+	})
+	if !errors.Is(err, ErrAssertionFailed) {
+		t.Fatalf("ModifyEntry(stale assertion) error = %v, want ErrAssertionFailed", err)
+	}
+	if called {
+		t.Fatal("ModifyEntry(stale assertion) applied the modification")
+	}
+
+	alice, err := store.GetEntry(ctx, "uid=alice,ou=users,dc=test,dc=com")
+	if err != nil || alice == nil {
+		t.Fatalf("GetEntry(alice) = %v, %v", alice, err)
+	}
+	if !containsValue(alice.GetAttributes("memberOf"), developersDN) {
+		t.Fatalf("alice memberOf = %v, want developers", alice.GetAttributes("memberOf"))
+	}
+}
+
+func TestModifyEntryRollsBackWhenModifyFails(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	failure := errors.New("invalid value")
+	_, err := store.ModifyEntry(ctx, "uid=bob,ou=users,dc=test,dc=com", WriteOptions{}, func(entry *models.Entry) error {
+		entry.SetAttribute("mail", "robert@test.com")
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("ModifyEntry() error = %v, want the modify error", err)
+	}
+	bob, err := store.GetEntry(ctx, "uid=bob,ou=users,dc=test,dc=com")
+	if err != nil || bob.GetAttribute("mail") != "bob@test.com" {
+		t.Fatalf("bob mail = %q, %v; want it unchanged", bob.GetAttribute("mail"), err)
+	}
+
+	if _, err := store.ModifyEntry(ctx, "uid=nobody,ou=users,dc=test,dc=com", WriteOptions{}, func(*models.Entry) error { return nil }); !errors.Is(err, ErrNoSuchObject) {
+		t.Fatalf("ModifyEntry(missing) error = %v, want ErrNoSuchObject", err)
+	}
+}
+
+func TestModifyEntrySerializesConcurrentModifies(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	const bobDN = "uid=bob,ou=users,dc=test,dc=com"
+	if _, err := store.ModifyEntry(ctx, bobDN, WriteOptions{}, func(entry *models.Entry) error {
+		entry.SetAttribute("employeeNumber", "0")
+		return nil
+	}); err != nil {
+		t.Fatalf("ModifyEntry(employeeNumber) failed: %v", err)
+	}
+
+	const writers = 8
+	results := make(chan string, writers)
+	errs := make(chan error, writers)
+	var wg sync.WaitGroup
+	for range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := store.ModifyEntry(ctx, bobDN, WriteOptions{ReturnEntries: true}, func(entry *models.Entry) error {
+				n, err := strconv.Atoi(entry.GetAttribute("employeeNumber"))
+				entry.SetAttribute("employeeNumber", strconv.Itoa(n+1))
+				return err
+			})
+			if err != nil {
+				errs <- err
+				return
+			}
+			results <- result.After.GetAttribute("employeeNumber")
+		}()
+	}
+	wg.Wait()
+	close(results)
+	close(errs)
+
+	for err := range errs {
+		t.Fatalf("concurrent ModifyEntry() failed: %v", err)
+	}
+	seen := make(map[string]bool, writers)
+	for value := range results {
+		if seen[value] {
+			t.Fatalf("employeeNumber %s handed out twice", value)
+		}
+		seen[value] = true
+	}
+	bob, err := store.GetEntry(ctx, bobDN)
+	if err != nil || bob.GetAttribute("employeeNumber") != strconv.Itoa(writers) {
+		t.Fatalf("bob employeeNumber = %q, %v; want %d", bob.GetAttribute("employeeNumber"), err, writers)
+	}
+}
+
+func TestWritesWithOptionsCheckAssertionAndReturnEntries(t *testing.T) {
+	store := setupTestStore(t)
+	defer store.Close()
+	ctx := context.Background()
+
+	carol := models.NewUser("ou=users,dc=test,dc=com", "carol", "Carol King", "King", "carol@test.com")
+	if _, err := store.CreateEntryWithOptions(ctx, carol.Entry, WriteOptions{Assertion: "(mail=nobody@test.com)"}); !errors.Is(err, ErrAssertionFailed) {
+		t.Fatalf("CreateEntryWithOptions(failing assertion) error = %v, want ErrAssertionFailed", err)
+	}
+	if exists, err := store.EntryExists(ctx, carol.DN); err != nil || exists {
+		t.Fatalf("EntryExists(carol) = %v, %v; want the add rolled back", exists, err)
+	}
+	carol = models.NewUser("ou=users,dc=test,dc=com", "carol", "Carol King", "King", "carol@test.com")
+	created, err := store.CreateEntryWithOptions(ctx, carol.Entry, WriteOptions{Assertion: "(uid=carol)", ReturnEntries: true})
+	if err != nil {
+		t.Fatalf("CreateEntryWithOptions() failed: %v", err)
+	}
+	if created.Before != nil || created.After == nil || created.After.GetAttribute("entryUUID") == "" {
+		t.Fatalf("CreateEntryWithOptions() = %+v, want the stored entry after the add", created)
+	}
+
+	if _, err := store.DeleteEntryWithOptions(ctx, carol.DN, WriteOptions{Assertion: "(mail=nobody@test.com)"}); !errors.Is(err, ErrAssertionFailed) {
+		t.Fatalf("DeleteEntryWithOptions(failing assertion) error = %v, want ErrAssertionFailed", err)
+	}
+	deleted, err := store.DeleteEntryWithOptions(ctx, carol.DN, WriteOptions{Assertion: "(uid=carol)", ReturnEntries: true})
+	if err != nil {
+		t.Fatalf("DeleteEntryWithOptions() failed: %v", err)
+	}
+	if deleted.After != nil || deleted.Before == nil || deleted.Before.GetAttribute("uid") != "carol" {
+		t.Fatalf("DeleteEntryWithOptions() = %+v, want the entry before the delete", deleted)
+	}
+
+	rename := RenameOptions{NewRDN: "uid=robert", DeleteOldRDN: true}
+	if _, _, err := store.RenameEntryWithOptions(ctx, "uid=bob,ou=users,dc=test,dc=com", rename, WriteOptions{Assertion: "(uid=robert)"}); !errors.Is(err, ErrAssertionFailed) {
+		t.Fatalf("RenameEntryWithOptions(failing assertion) error = %v, want ErrAssertionFailed", err)
+	}
+	newDN, renamed, err := store.RenameEntryWithOptions(ctx, "uid=bob,ou=users,dc=test,dc=com", rename, WriteOptions{
+		Assertion:     "(memberOf=cn=developers,ou=groups,dc=test,dc=com)",
+		ReturnEntries: true,
+	})
+	if err != nil {
+		t.Fatalf("RenameEntryWithOptions() failed: %v", err)
+	}
+	if renamed.Before == nil || renamed.Before.DN != "uid=bob,ou=users,dc=test,dc=com" {
+		t.Fatalf("Before = %+v, want bob", renamed.Before)
+	}
+	if renamed.After == nil || renamed.After.DN != newDN || renamed.After.GetAttribute("uid") != "robert" {
+		t.Fatalf("After = %+v, want robert at %s", renamed.After, newDN)
+	}
+}
@@ -2,6 +2,7 @@ package store
 
 import (
 	"context"
+	"time"
 
 	"github.com/smarzola/ldaplite/internal/models"
 )
@@ -19,12 +20,84 @@ type SearchOptions struct {
 	Filter          string
 	Scope           SearchScope
 	IncludeMemberOf bool
+
+	// AfterEntryID resumes a scan after the entry with this ID. Without
+	// SortKeys results are returned in entry ID order, so the ID of the last
+	// entry of one call resumes the next.
+	AfterEntryID int64
+	// Limit caps the number of returned entries. Zero means no limit.
+	Limit int
+	// Offset skips that many matching entries before Limit applies.
+	Offset int
+	// SortKeys orders the results (RFC 2891). Entries that tie on every key,
+	// or all entries when no keys are given, are returned in entry ID order.
+	SortKeys []SortKey
+	// SortValueBefore keeps only the entries whose first sort key orders
+	// before this value. Counting them locates the value in the sorted
+	// results, as the virtual list view control requires.
+	SortValueBefore *string
+	// ExcludeSubtrees drops the entries at or below each of these DNs, such
+	// as the subtrees access rules hide from the searching identity.
+	ExcludeSubtrees []string
+}
+
+// SortKey orders search results by one attribute. OrderingRule is a matching
+// rule name or OID; empty selects the attribute's default ordering.
+type SortKey struct {
+	Attribute    string
+	OrderingRule string
+	Reverse      bool
 }
 
 type EntryOptions struct {
 	IncludeMemberOf bool
 }
 
+// RenameOptions describes a ModifyDN request. An empty NewSuperior keeps the
+// entry under its current parent.
+type RenameOptions struct {
+	NewRDN       string
+	DeleteOldRDN bool
+	NewSuperior  string
+}
+
+// WriteOptions holds the conditions and results a write request asks for.
+type WriteOptions struct {
+	// Assertion is an LDAP filter the target entry must match for the write
+	// to apply (RFC 4528). It is evaluated inside the write transaction, and
+	// against the new entry for adds. A mismatch fails with
+	// ErrAssertionFailed.
+	Assertion string
+	// ReturnEntries fills WriteResult with the target entry as read inside
+	// the write transaction (RFC 4527).
+	ReturnEntries bool
+}
+
+// WriteResult holds the target entry of a write, with memberOf, as stored
+// right before and right after the write. Before is nil for adds and After
+// is nil for deletes; both are nil unless WriteOptions.ReturnEntries is set.
+type WriteResult struct {
+	Before *models.Entry
+	After  *models.Entry
+}
+
+// SubtreeDeleteOptions holds the conditions of a subtree delete. The
+// assertion applies to the root of the subtree.
+type SubtreeDeleteOptions struct {
+	WriteOptions
+	// DryRun reports what the delete would remove and rolls it back.
+	DryRun bool
+}
+
+// SubtreeDeleteResult lists what a subtree delete removed: the entries,
+// children before their parents, and the groups outside the subtree that
+// lost members. WriteResult holds the root of the subtree.
+type SubtreeDeleteResult struct {
+	WriteResult
+	DNs    []string
+	Groups []string
+}
+
 // Store defines the interface for LDAP data storage
 type Store interface {
 	// Initialize sets up the database and runs migrations
@@ -37,14 +110,41 @@ type Store interface {
 	GetEntry(ctx context.Context, dn string) (*models.Entry, error)
 	GetEntryWithOptions(ctx context.Context, dn string, options EntryOptions) (*models.Entry, error)
 	CreateEntry(ctx context.Context, entry *models.Entry) error
+	CreateEntryWithOptions(ctx context.Context, entry *models.Entry, options WriteOptions) (WriteResult, error)
 	UpdateEntry(ctx context.Context, entry *models.Entry) error
+	// ModifyEntry reads the entry at dn, lets modify change it and stores
+	// the result in a single transaction. An error from modify aborts the
+	// write and is returned as is.
+	ModifyEntry(ctx context.Context, dn string, options WriteOptions, modify func(entry *models.Entry) error) (WriteResult, error)
 	DeleteEntry(ctx context.Context, dn string) error
+	DeleteEntryWithOptions(ctx context.Context, dn string, options WriteOptions) (WriteResult, error)
+	// DeleteSubtree deletes the entry at dn with all of its descendants in a
+	// single transaction.
+	DeleteSubtree(ctx context.Context, dn string, options SubtreeDeleteOptions) (SubtreeDeleteResult, error)
+	RenameEntry(ctx context.Context, dn string, options RenameOptions) (newDN string, err error)
+	RenameEntryWithOptions(ctx context.Context, dn string, rename RenameOptions, options WriteOptions) (newDN string, result WriteResult, err error)
 	SearchEntries(ctx context.Context, baseDN string, filter string) ([]*models.Entry, error)
 	SearchEntriesWithOptions(ctx context.Context, options SearchOptions) ([]*models.Entry, error)
+	CountEntriesWithOptions(ctx context.Context, options SearchOptions) (int, error)
 	EntryExists(ctx context.Context, dn string) (bool, error)
 
 	// Authentication and Authorization
 	GetUserPasswordHash(ctx context.Context, uid string) (passwordHash string, dn string, err error)
 	GetUserPasswordHashByDN(ctx context.Context, dn string) (passwordHash string, canonicalDN string, err error)
+	GetUserSCRAMSecret(ctx context.Context, uid string) (scramSecret string, dn string, err error)
+	RehashUserPassword(ctx context.Context, dn, oldHash, newHash, scramSecret string) error
 	IsUserInGroup(ctx context.Context, userDN, groupDN string) (bool, error)
+
+	// Password policy state
+	GetPasswordPolicyState(ctx context.Context, dn string) (*PasswordPolicyState, error)
+	RecordPasswordFailure(ctx context.Context, dn string, failureTime, expireBefore time.Time, maxFailures int) (bool, error)
+	ResetPasswordFailures(ctx context.Context, dn string) error
+	RecordGraceLogin(ctx context.Context, dn string) error
+	GetPasswordHistory(ctx context.Context, dn string) ([]string, error)
+
+	// Account state
+	SetAccountStatus(ctx context.Context, dn string, status models.AccountStatus) error
+	// UpdateEntryWithAccountStatus updates a user entry and its account
+	// status in a single transaction.
+	UpdateEntryWithAccountStatus(ctx context.Context, entry *models.Entry, status models.AccountStatus) error
 }
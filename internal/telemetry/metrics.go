@@ -8,6 +8,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/smarzola/ldaplite/pkg/crypto"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
@@ -18,17 +19,22 @@ var (
 	instruments           metricInstruments
 	activeLDAPConnections atomic.Int64
 	dbStatsProvider       atomic.Value
+	hashPoolStatsProvider atomic.Value
 )
 
 type metricInstruments struct {
 	ldapOperations        metric.Int64Counter
 	ldapOperationDuration metric.Float64Histogram
 	ldapConnections       metric.Int64Counter
+	ldapRejected          metric.Int64Counter
+	ldapServerClosed      metric.Int64Counter
 	ldapReadErrors        metric.Int64Counter
 	ldapHandlerErrors     metric.Int64Counter
 	httpRequests          metric.Int64Counter
 	httpRequestDuration   metric.Float64Histogram
 	webWrites             metric.Int64Counter
+	passwordHashWait      metric.Float64Histogram
+	passwordHashRejected  metric.Int64Counter
 }
 
 func initMetrics() error {
@@ -58,6 +64,22 @@ func initMetrics() error {
 	if err != nil {
 		return err
 	}
+	ldapRejected, err := meter.Int64Counter(
+		"ldaplite.ldap.connections.rejected",
+		metric.WithDescription("LDAP connections rejected by connection limits."),
+		metric.WithUnit("{connection}"),
+	)
+	if err != nil {
+		return err
+	}
+	ldapServerClosed, err := meter.Int64Counter(
+		"ldaplite.ldap.connections.server_closed",
+		metric.WithDescription("LDAP connections closed by the server for idling or oversized requests."),
+		metric.WithUnit("{connection}"),
+	)
+	if err != nil {
+		return err
+	}
 	ldapReadErrors, err := meter.Int64Counter(
 		"ldaplite.ldap.read_errors",
 		metric.WithDescription("LDAP transport read errors."),
@@ -98,6 +120,22 @@ func initMetrics() error {
 	if err != nil {
 		return err
 	}
+	passwordHashWait, err := meter.Float64Histogram(
+		"ldaplite.password_hash.queue.wait",
+		metric.WithDescription("Time password checks waited for a hashing worker."),
+		metric.WithUnit("ms"),
+	)
+	if err != nil {
+		return err
+	}
+	passwordHashRejected, err := meter.Int64Counter(
+		"ldaplite.password_hash.rejected",
+		metric.WithDescription("Password checks rejected because no hashing worker became free."),
+		metric.WithUnit("{check}"),
+	)
+	if err != nil {
+		return err
+	}
 
 	activeConnections, err := meter.Int64ObservableGauge(
 		"ldaplite.ldap.connections.active",
@@ -131,8 +169,29 @@ func initMetrics() error {
 	if err != nil {
 		return err
 	}
+	hashQueueDepth, err := meter.Int64ObservableGauge(
+		"ldaplite.password_hash.queue.depth",
+		metric.WithDescription("Password checks waiting for a hashing worker."),
+		metric.WithUnit("{check}"),
+	)
+	if err != nil {
+		return err
+	}
+	hashWorkersInUse, err := meter.Int64ObservableGauge(
+		"ldaplite.password_hash.workers.in_use",
+		metric.WithDescription("Busy password hashing workers."),
+		metric.WithUnit("{worker}"),
+	)
+	if err != nil {
+		return err
+	}
 	if _, err := meter.RegisterCallback(func(ctx context.Context, observer metric.Observer) error {
 		observer.ObserveInt64(activeConnections, activeLDAPConnections.Load())
+		if provider, ok := hashPoolStatsProvider.Load().(func() crypto.HashPoolStats); ok && provider != nil {
+			stats := provider()
+			observer.ObserveInt64(hashQueueDepth, int64(stats.Waiting))
+			observer.ObserveInt64(hashWorkersInUse, int64(stats.InUse))
+		}
 		if provider, ok := dbStatsProvider.Load().(func() sql.DBStats); ok && provider != nil {
 			stats := provider()
 			observer.ObserveInt64(dbOpenConnections, int64(stats.OpenConnections))
@@ -140,7 +199,7 @@ func initMetrics() error {
 			observer.ObserveInt64(dbIdleConnections, int64(stats.Idle))
 		}
 		return nil
-	}, activeConnections, dbOpenConnections, dbInUseConnections, dbIdleConnections); err != nil {
+	}, activeConnections, dbOpenConnections, dbInUseConnections, dbIdleConnections, hashQueueDepth, hashWorkersInUse); err != nil {
 		return err
 	}
 
@@ -149,11 +208,15 @@ func initMetrics() error {
 		ldapOperations:        ldapOperations,
 		ldapOperationDuration: ldapOperationDuration,
 		ldapConnections:       ldapConnections,
+		ldapRejected:          ldapRejected,
+		ldapServerClosed:      ldapServerClosed,
 		ldapReadErrors:        ldapReadErrors,
 		ldapHandlerErrors:     ldapHandlerErrors,
 		httpRequests:          httpRequests,
 		httpRequestDuration:   httpRequestDuration,
 		webWrites:             webWrites,
+		passwordHashWait:      passwordHashWait,
+		passwordHashRejected:  passwordHashRejected,
 	}
 	instrumentsMu.Unlock()
 
@@ -180,6 +243,24 @@ func RecordLDAPConnectionAccepted(ctx context.Context) {
 	}
 }
 
+// RecordLDAPConnectionRejected counts a connection refused by a connection
+// cap. reason is max_connections or max_connections_per_ip.
+func RecordLDAPConnectionRejected(ctx context.Context, reason string) {
+	current := currentInstruments()
+	if current.ldapRejected != nil {
+		current.ldapRejected.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", reason)))
+	}
+}
+
+// RecordLDAPConnectionServerClosed counts a connection the server closed.
+// reason is idle_timeout or message_too_large.
+func RecordLDAPConnectionServerClosed(ctx context.Context, reason string) {
+	current := currentInstruments()
+	if current.ldapServerClosed != nil {
+		current.ldapServerClosed.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", reason)))
+	}
+}
+
 func AddActiveLDAPConnection(delta int64) {
 	activeLDAPConnections.Add(delta)
 }
@@ -227,12 +308,30 @@ func RegisterDatabaseStatsProvider(provider func() sql.DBStats) {
 	dbStatsProvider.Store(provider)
 }
 
+// RecordPasswordHashWait records how long a password check queued for a
+// hashing worker and counts the checks that gave up.
+func RecordPasswordHashWait(wait time.Duration, acquired bool) {
+	current := currentInstruments()
+	ctx := context.Background()
+	if current.passwordHashWait != nil {
+		current.passwordHashWait.Record(ctx, float64(wait.Milliseconds()))
+	}
+	if !acquired && current.passwordHashRejected != nil {
+		current.passwordHashRejected.Add(ctx, 1)
+	}
+}
+
+func RegisterPasswordHashPoolStatsProvider(provider func() crypto.HashPoolStats) {
+	hashPoolStatsProvider.Store(provider)
+}
+
 func resetMetricsForTest() {
 	instrumentsMu.Lock()
 	instruments = metricInstruments{}
 	instrumentsMu.Unlock()
 	activeLDAPConnections.Store(0)
 	dbStatsProvider.Store((func() sql.DBStats)(nil))
+	hashPoolStatsProvider.Store((func() crypto.HashPoolStats)(nil))
 }
 
 func currentInstruments() metricInstruments {
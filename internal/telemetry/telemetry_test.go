@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/smarzola/ldaplite/pkg/config"
+	"github.com/smarzola/ldaplite/pkg/crypto"
 )
 
 func TestRuntimeDisabledDoesNotStartMetricsListener(t *testing.T) {
@@ -91,7 +92,14 @@ func TestRuntimeScrapesRecordedMetricsWithBoundedLabels(t *testing.T) {
 	RegisterDatabaseStatsProvider(func() sql.DBStats {
 		return sql.DBStats{OpenConnections: 2, InUse: 1, Idle: 1}
 	})
+	RegisterPasswordHashPoolStatsProvider(func() crypto.HashPoolStats {
+		return crypto.HashPoolStats{Workers: 4, InUse: 4, Waiting: 3}
+	})
+	RecordPasswordHashWait(3*time.Millisecond, true)
+	RecordPasswordHashWait(5*time.Second, false)
 	RecordLDAPConnectionAccepted(ctx)
+	RecordLDAPConnectionRejected(ctx, "max_connections_per_ip")
+	RecordLDAPConnectionServerClosed(ctx, "idle_timeout")
 	AddActiveLDAPConnection(1)
 	RecordLDAPOperation(ctx, "bind", 0, 12*time.Millisecond)
 	RecordLDAPReadError(ctx)
@@ -115,6 +123,10 @@ func TestRuntimeScrapesRecordedMetricsWithBoundedLabels(t *testing.T) {
 	assertMetricsContain(t, body, `result_code="0"`)
 	assertMetricsContain(t, body, `ldaplite_ldap_connections_accepted`)
 	assertMetricsContain(t, body, `ldaplite_ldap_connections_active`)
+	assertMetricsContain(t, body, `ldaplite_ldap_connections_rejected`)
+	assertMetricsContain(t, body, `reason="max_connections_per_ip"`)
+	assertMetricsContain(t, body, `ldaplite_ldap_connections_server_closed`)
+	assertMetricsContain(t, body, `reason="idle_timeout"`)
 	assertMetricsContain(t, body, `ldaplite_ldap_read_errors`)
 	assertMetricsContain(t, body, `ldaplite_ldap_handler_errors`)
 	assertMetricsContain(t, body, `operation="search"`)
@@ -127,6 +139,10 @@ func TestRuntimeScrapesRecordedMetricsWithBoundedLabels(t *testing.T) {
 	assertMetricsContain(t, body, `ldaplite_db_connections_open`)
 	assertMetricsContain(t, body, `ldaplite_db_connections_in_use`)
 	assertMetricsContain(t, body, `ldaplite_db_connections_idle`)
+	assertMetricsContain(t, body, `ldaplite_password_hash_queue_wait`)
+	assertMetricsContain(t, body, `ldaplite_password_hash_rejected`)
+	assertMetricsContain(t, body, `ldaplite_password_hash_queue_depth`)
+	assertMetricsContain(t, body, `ldaplite_password_hash_workers_in_use`)
 }
 
 func assertMetricsContain(t *testing.T, body, want string) {
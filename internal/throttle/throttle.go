@@ -0,0 +1,405 @@
+// Package throttle slows down and then temporarily rejects repeated failed
+// authentications. Failures are counted per account and per client address
+// and shared by LDAP binds and Web UI and SCIM Basic auth, so a password
+// spray against either front end costs the attacker time.
+package throttle
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/smarzola/ldaplite/internal/audit"
+	"github.com/smarzola/ldaplite/pkg/config"
+)
+
+// Scopes a counter can belong to.
+const (
+	ScopeAccount = "account"
+	ScopeAddress = "address"
+)
+
+// minPruneSize is the number of counters below which expired ones are left
+// for lookups to remove.
+const minPruneSize = 10000
+
+// defaultMaxCounters caps the counters kept in memory. Account counters are
+// keyed by whatever name a client sends, so failures for made-up names would
+// otherwise grow the map without bound.
+const defaultMaxCounters = 100000
+
+// ErrThrottled matches every *RejectedError.
+var ErrThrottled = errors.New("too many failed authentication attempts")
+
+// RejectedError reports an attempt rejected because its account or client
+// address is blocked.
+type RejectedError struct {
+	Scope      string
+	RetryAfter time.Duration
+}
+
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("%s: %s blocked for %s", ErrThrottled, e.Scope, e.RetryAfter.Round(time.Second))
+}
+
+func (e *RejectedError) Unwrap() error {
+	return ErrThrottled
+}
+
+// Attempt identifies an authentication attempt.
+type Attempt struct {
+	Component string // audit.ComponentLDAP or audit.ComponentWeb
+	RequestID string
+	// Account is the bind DN or user name. It is empty while not known,
+	// for example before a SASL exchange completes.
+	Account    string
+	RemoteAddr string
+}
+
+type counter struct {
+	Failures     int       `json:"failures"`
+	LastFailure  time.Time `json:"last_failure"`
+	BlockedUntil time.Time `json:"blocked_until,omitzero"`
+}
+
+type counterKey struct {
+	scope string
+	value string
+}
+
+func (k counterKey) String() string {
+	return k.scope + ":" + k.value
+}
+
+// Throttler keeps the failure counters in memory. A nil *Throttler never
+// throttles.
+type Throttler struct {
+	cfg       config.BindThrottleConfig
+	mu        sync.Mutex
+	counters  map[string]*counter
+	pruneSize int
+	// maxCounters caps len(counters); see evict.
+	maxCounters int
+	now         func() time.Time
+}
+
+func New(cfg config.BindThrottleConfig) *Throttler {
+	return &Throttler{
+		cfg:         cfg,
+		counters:    make(map[string]*counter),
+		pruneSize:   minPruneSize,
+		maxCounters: defaultMaxCounters,
+		now:         time.Now,
+	}
+}
+
+// Wait rejects an attempt whose account or client address is blocked with a
+// *RejectedError. Otherwise it waits out the backoff delay earned by recent
+// failures of either before returning nil.
+func (t *Throttler) Wait(ctx context.Context, attempt Attempt) error {
+	if t == nil {
+		return nil
+	}
+	delay, err := t.check(ctx, attempt)
+	if err != nil || delay <= 0 {
+		return err
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Check rejects an attempt whose account or client address is blocked,
+// without waiting. It covers attempts whose account only became known after
+// Wait, such as SASL exchanges.
+func (t *Throttler) Check(ctx context.Context, attempt Attempt) error {
+	if t == nil {
+		return nil
+	}
+	_, err := t.check(ctx, attempt)
+	return err
+}
+
+// Failure counts a failed attempt against its account and client address,
+// blocking either once it reaches its threshold.
+func (t *Throttler) Failure(ctx context.Context, attempt Attempt) {
+	if t == nil {
+		return
+	}
+	for _, event := range t.recordFailure(attempt) {
+		audit.LogThrottle(ctx, event)
+	}
+}
+
+// Success forgets the failures of the attempt's account. Address counters
+// only expire, so one valid account does not unlock a spray from the same
+// address.
+func (t *Throttler) Success(attempt Attempt) {
+	if t == nil || attempt.Account == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.counters, accountKey(attempt.Account).String())
+}
+
+// check returns the backoff delay for attempt, or a *RejectedError logged
+// as an audit event when it is blocked.
+func (t *Throttler) check(ctx context.Context, attempt Attempt) (time.Duration, error) {
+	delay, rejected, failures := t.lookup(attempt)
+	if rejected != nil {
+		audit.LogThrottle(ctx, t.event(audit.EventAuthThrottled, attempt, rejected.Scope, failures, rejected.RetryAfter))
+		return 0, rejected
+	}
+	return delay, nil
+}
+
+func (t *Throttler) lookup(attempt Attempt) (time.Duration, *RejectedError, int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	var delay time.Duration
+	for _, key := range keys(attempt) {
+		c := t.current(key, now)
+		if c == nil {
+			continue
+		}
+		if now.Before(c.BlockedUntil) {
+			return 0, &RejectedError{Scope: key.scope, RetryAfter: c.BlockedUntil.Sub(now)}, c.Failures
+		}
+		delay = max(delay, t.backoff(c.Failures))
+	}
+	return delay, nil, 0
+}
+
+func (t *Throttler) recordFailure(attempt Attempt) []audit.ThrottleEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	var events []audit.ThrottleEvent
+	for _, key := range keys(attempt) {
+		c := t.current(key, now)
+		if c == nil {
+			c = &counter{}
+			t.counters[key.String()] = c
+		}
+		if now.Before(c.BlockedUntil) {
+			continue
+		}
+		c.Failures++
+		c.LastFailure = now
+		if threshold := t.threshold(key.scope); threshold > 0 && t.cfg.BlockDuration > 0 && c.Failures >= threshold {
+			blockDuration := time.Duration(t.cfg.BlockDuration) * time.Second
+			c.BlockedUntil = now.Add(blockDuration)
+			events = append(events, t.event(audit.EventAuthBlocked, attempt, key.scope, c.Failures, blockDuration))
+		}
+	}
+	if len(t.counters) >= t.pruneSize {
+		t.prune(now)
+		t.pruneSize = max(minPruneSize, 2*len(t.counters))
+	}
+	if len(t.counters) > t.maxCounters {
+		t.evict(now)
+	}
+	return events
+}
+
+// current returns the live counter for key, dropping it once its block or
+// failure window has passed.
+func (t *Throttler) current(key counterKey, now time.Time) *counter {
+	c := t.counters[key.String()]
+	if c == nil {
+		return nil
+	}
+	if t.expired(c, now) {
+		delete(t.counters, key.String())
+		return nil
+	}
+	return c
+}
+
+func (t *Throttler) expired(c *counter, now time.Time) bool {
+	if !c.BlockedUntil.IsZero() {
+		return !now.Before(c.BlockedUntil)
+	}
+	return t.cfg.FailureWindow > 0 && now.Sub(c.LastFailure) >= time.Duration(t.cfg.FailureWindow)*time.Second
+}
+
+func (t *Throttler) prune(now time.Time) {
+	for key, c := range t.counters {
+		if t.expired(c, now) {
+			delete(t.counters, key)
+		}
+	}
+}
+
+// evict drops counters until a tenth of maxCounters is free, so it runs
+// rarely even under a spray of made-up account names. Counters that block
+// nothing go first, account counters before address counters and the oldest
+// failures first; blocks are only dropped when nothing else is left.
+func (t *Throttler) evict(now time.Time) {
+	keys := make([]string, 0, len(t.counters))
+	for key := range t.counters {
+		keys = append(keys, key)
+	}
+	rank := func(key string) int {
+		c := t.counters[key]
+		switch {
+		case now.Before(c.BlockedUntil):
+			return 2
+		case strings.HasPrefix(key, ScopeAccount+":"):
+			return 0
+		default:
+			return 1
+		}
+	}
+	slices.SortFunc(keys, func(a, b string) int {
+		if ra, rb := rank(a), rank(b); ra != rb {
+			return ra - rb
+		}
+		return t.counters[a].LastFailure.Compare(t.counters[b].LastFailure)
+	})
+	for _, key := range keys[:len(keys)-t.maxCounters*9/10] {
+		delete(t.counters, key)
+	}
+}
+
+func (t *Throttler) threshold(scope string) int {
+	if scope == ScopeAccount {
+		return t.cfg.AccountFailures
+	}
+	return t.cfg.AddressFailures
+}
+
+// backoff doubles the configured delay for every failure after the first.
+// Without a maximum it stops doubling after 20 failures.
+func (t *Throttler) backoff(failures int) time.Duration {
+	if t.cfg.Delay <= 0 || failures <= 0 {
+		return 0
+	}
+	maxDelay := time.Duration(t.cfg.MaxDelay) * time.Millisecond
+	delay := time.Duration(t.cfg.Delay) * time.Millisecond
+	for i := 1; i < min(failures, 20); i++ {
+		delay *= 2
+		if maxDelay > 0 && delay >= maxDelay {
+			return maxDelay
+		}
+	}
+	if maxDelay > 0 {
+		return min(delay, maxDelay)
+	}
+	return delay
+}
+
+func (t *Throttler) event(name string, attempt Attempt, scope string, failures int, retryAfter time.Duration) audit.ThrottleEvent {
+	return audit.ThrottleEvent{
+		Event:      name,
+		Component:  attempt.Component,
+		RequestID:  attempt.RequestID,
+		RemoteAddr: attempt.RemoteAddr,
+		Account:    attempt.Account,
+		Scope:      scope,
+		Failures:   failures,
+		RetryAfter: retryAfter,
+	}
+}
+
+func keys(attempt Attempt) []counterKey {
+	var keys []counterKey
+	if attempt.Account != "" {
+		keys = append(keys, accountKey(attempt.Account))
+	}
+	if address := remoteHost(attempt.RemoteAddr); address != "" {
+		keys = append(keys, counterKey{scope: ScopeAddress, value: address})
+	}
+	return keys
+}
+
+// accountKey matches DNs case-insensitively, like the rest of the server.
+func accountKey(account string) counterKey {
+	return counterKey{scope: ScopeAccount, value: strings.ToLower(strings.TrimSpace(account))}
+}
+
+func remoteHost(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+type savedState struct {
+	Counters map[string]*counter `json:"counters"`
+}
+
+// Load restores the counters written by Save. A missing file is not an
+// error.
+func (t *Throttler) Load(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read bind throttle state: %w", err)
+	}
+	var state savedState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("failed to parse bind throttle state: %w", err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	for key, c := range state.Counters {
+		if c != nil && !t.expired(c, now) {
+			t.counters[key] = c
+		}
+	}
+	if len(t.counters) > t.maxCounters {
+		t.evict(now)
+	}
+	return nil
+}
+
+// Save writes the counters that have not expired to path, replacing it
+// atomically.
+func (t *Throttler) Save(path string) error {
+	t.mu.Lock()
+	t.prune(t.now())
+	data, err := json.Marshal(savedState{Counters: t.counters})
+	t.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode bind throttle state: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write bind throttle state: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write bind throttle state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write bind throttle state: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write bind throttle state: %w", err)
+	}
+	return nil
+}
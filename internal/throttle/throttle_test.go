@@ -0,0 +1,154 @@
+package throttle
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/smarzola/ldaplite/internal/audit"
+	"github.com/smarzola/ldaplite/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func newTestThrottler(cfg config.BindThrottleConfig) (*Throttler, *testClock) {
+	clock := &testClock{now: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)}
+	throttler := New(cfg)
+	throttler.now = clock.Now
+	return throttler, clock
+}
+
+func captureAuditLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var logs bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &logs
+}
+
+func TestBackoffDoublesUpToMaxDelay(t *testing.T) {
+	throttler, _ := newTestThrottler(config.BindThrottleConfig{Delay: 100, MaxDelay: 500})
+
+	assert.Equal(t, time.Duration(0), throttler.backoff(0))
+	assert.Equal(t, 100*time.Millisecond, throttler.backoff(1))
+	assert.Equal(t, 400*time.Millisecond, throttler.backoff(3))
+	assert.Equal(t, 500*time.Millisecond, throttler.backoff(4))
+	assert.Equal(t, 500*time.Millisecond, throttler.backoff(80))
+}
+
+func TestAccountIsBlockedAfterThreshold(t *testing.T) {
+	logs := captureAuditLogs(t)
+	throttler, clock := newTestThrottler(config.BindThrottleConfig{AccountFailures: 3, BlockDuration: 60})
+	ctx := context.Background()
+	attempt := Attempt{Component: audit.ComponentLDAP, Account: "uid=jane,ou=users,dc=example,dc=com", RemoteAddr: "192.0.2.1:4000"}
+
+	for range 3 {
+		require.NoError(t, throttler.Wait(ctx, attempt))
+		throttler.Failure(ctx, attempt)
+	}
+	assert.Contains(t, logs.String(), `"event":"auth.blocked"`)
+
+	// The same account from another address is still blocked; DNs match
+	// case-insensitively.
+	err := throttler.Wait(ctx, Attempt{Account: "UID=Jane,ou=users,dc=example,dc=com", RemoteAddr: "198.51.100.7:5000"})
+	var rejected *RejectedError
+	require.ErrorAs(t, err, &rejected)
+	assert.ErrorIs(t, err, ErrThrottled)
+	assert.Equal(t, ScopeAccount, rejected.Scope)
+	assert.Equal(t, 60*time.Second, rejected.RetryAfter)
+	assert.Contains(t, logs.String(), `"event":"auth.throttled"`)
+
+	clock.now = clock.now.Add(time.Minute)
+	assert.NoError(t, throttler.Wait(ctx, attempt))
+}
+
+func TestAddressIsBlockedAcrossAccounts(t *testing.T) {
+	throttler, _ := newTestThrottler(config.BindThrottleConfig{AddressFailures: 2, BlockDuration: 60})
+	ctx := context.Background()
+
+	throttler.Failure(ctx, Attempt{Account: "uid=a,dc=example,dc=com", RemoteAddr: "192.0.2.1:4000"})
+	throttler.Failure(ctx, Attempt{Account: "uid=b,dc=example,dc=com", RemoteAddr: "192.0.2.1:4001"})
+	// A successful bind from the same address does not lift the block.
+	throttler.Success(Attempt{Account: "uid=c,dc=example,dc=com", RemoteAddr: "192.0.2.1:4002"})
+
+	err := throttler.Wait(ctx, Attempt{Account: "uid=c,dc=example,dc=com", RemoteAddr: "192.0.2.1:4003"})
+	var rejected *RejectedError
+	require.ErrorAs(t, err, &rejected)
+	assert.Equal(t, ScopeAddress, rejected.Scope)
+	assert.NoError(t, throttler.Wait(ctx, Attempt{Account: "uid=c,dc=example,dc=com", RemoteAddr: "198.51.100.7:4000"}))
+}
+
+func TestSuccessAndFailureWindowResetAccount(t *testing.T) {
+	throttler, clock := newTestThrottler(config.BindThrottleConfig{AccountFailures: 2, BlockDuration: 60, FailureWindow: 300})
+	ctx := context.Background()
+	attempt := Attempt{Account: "uid=jane,ou=users,dc=example,dc=com"}
+
+	throttler.Failure(ctx, attempt)
+	throttler.Success(attempt)
+	throttler.Failure(ctx, attempt)
+	assert.NoError(t, throttler.Wait(ctx, attempt))
+
+	clock.now = clock.now.Add(5 * time.Minute)
+	throttler.Failure(ctx, attempt)
+	assert.NoError(t, throttler.Wait(ctx, attempt))
+	throttler.Failure(ctx, attempt)
+	assert.ErrorIs(t, throttler.Wait(ctx, attempt), ErrThrottled)
+}
+
+func TestMadeUpAccountsCannotGrowCountersWithoutBound(t *testing.T) {
+	throttler, clock := newTestThrottler(config.BindThrottleConfig{AccountFailures: 2, BlockDuration: 60})
+	throttler.maxCounters = 20
+	ctx := context.Background()
+
+	blocked := Attempt{Account: "uid=jane,ou=users,dc=example,dc=com"}
+	throttler.Failure(ctx, blocked)
+	throttler.Failure(ctx, blocked)
+	for i := range 100 {
+		clock.now = clock.now.Add(time.Millisecond)
+		throttler.Failure(ctx, Attempt{Account: fmt.Sprintf("uid=ghost%d,dc=example,dc=com", i)})
+		assert.LessOrEqual(t, len(throttler.counters), throttler.maxCounters)
+	}
+	// Counters that block nothing are evicted first, so the block holds.
+	assert.ErrorIs(t, throttler.Wait(ctx, blocked), ErrThrottled)
+}
+
+func TestSaveAndLoadKeepBlocks(t *testing.T) {
+	throttler, clock := newTestThrottler(config.BindThrottleConfig{AccountFailures: 1, BlockDuration: 60})
+	ctx := context.Background()
+	attempt := Attempt{Account: "uid=jane,ou=users,dc=example,dc=com"}
+	throttler.Failure(ctx, attempt)
+
+	path := filepath.Join(t.TempDir(), "throttle.json")
+	require.NoError(t, throttler.Save(path))
+
+	restored, _ := newTestThrottler(config.BindThrottleConfig{AccountFailures: 1, BlockDuration: 60})
+	restored.now = clock.Now
+	require.NoError(t, restored.Load(path))
+	assert.ErrorIs(t, restored.Wait(ctx, attempt), ErrThrottled)
+
+	missing := New(config.BindThrottleConfig{})
+	assert.NoError(t, missing.Load(filepath.Join(t.TempDir(), "missing.json")))
+}
+
+func TestNilThrottlerNeverThrottles(t *testing.T) {
+	var throttler *Throttler
+	ctx := context.Background()
+	attempt := Attempt{Account: "uid=jane,ou=users,dc=example,dc=com"}
+
+	throttler.Failure(ctx, attempt)
+	throttler.Success(attempt)
+	assert.NoError(t, throttler.Wait(ctx, attempt))
+}
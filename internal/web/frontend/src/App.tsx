@@ -102,6 +102,7 @@ type Session = {
     passwordSelf: boolean
     passwordReset: boolean
   }
+  adminScopes: string[]
 }
 
 type EntrySummary = {
@@ -126,7 +127,7 @@ type Notice = {
   text: string
 }
 
-type ViewId = "directory" | "users" | "groups" | "ous" | "admin" | "account"
+type ViewId = "directory" | "users" | "groups" | "ous" | "owned" | "admin" | "account"
 type DirectorySearchType = "all" | "users" | "groups" | "ous"
 type DirectoryEntryType = "entry" | "user" | "group" | "ou"
 
@@ -141,6 +142,10 @@ type DirectorySearchResponse = {
   entries: EntrySummary[]
 }
 
+type OwnedGroupsResponse = {
+  groups: EntrySummary[]
+}
+
 type EntryDetail = EntrySummary & {
   attributes: Record<string, string[]>
   createdAt?: string
@@ -152,6 +157,13 @@ type DirectoryDetailResponse = {
   entry: EntryDetail
 }
 
+type SubtreeDeleteResponse = {
+  dn: string
+  deleted: boolean
+  entries: string[]
+  groups: string[]
+}
+
 type WorkflowType = "user" | "group" | "ou"
 
 type AdminWorkflow =
@@ -161,6 +173,8 @@ type AdminWorkflow =
   | { kind: "members"; entry: EntryDetail }
 
 const protectedExtraAttributes = [
+  "accountdisabled",
+  "accountexpirationtime",
   "createtimestamp",
   "entryuuid",
   "memberof",
@@ -359,6 +373,10 @@ function AppView({
     )
   }
 
+  if (activeView === "owned") {
+    return <OwnedGroupsPanel disabled={mutating} onMutate={onMutate} />
+  }
+
   if (activeView === "admin" && session.roles.admin) {
     return (
       <AdminPanel
@@ -704,7 +722,7 @@ function DirectorySearchView({
                 onSelect={setSelectedEntry}
                 onAdmin={setSelectedEntry}
                 selectedDN={selectedEntry?.dn}
-                showAdminAction={session.roles.admin}
+                canManage={(entry) => canAdminister(session, entry.dn)}
               />
               <ResultPagination
                 page={data.page}
@@ -743,7 +761,7 @@ function DirectorySearchView({
         }}
         onRetry={() => setDetailRetryKey((current) => current + 1)}
         onResetPassword={(entry) => setWorkflow({ kind: "reset", entry })}
-        showAdminActions={session.roles.admin}
+        showAdminActions={selectedEntry !== undefined && canAdminister(session, selectedEntry.dn)}
       />
 
       <AdminWorkflowDialog
@@ -803,19 +821,19 @@ function SessionCard({ session }: { session: Session }) {
 }
 
 function SearchResults({
+  canManage,
   entries,
   onAdmin,
   onCopyDN,
   onSelect,
   selectedDN,
-  showAdminAction,
 }: {
+  canManage: (entry: EntrySummary) => boolean
   entries: EntrySummary[]
   onAdmin: (entry: EntrySummary) => void
   onCopyDN: (entry: EntrySummary) => void
   onSelect: (entry: EntrySummary) => void
   selectedDN?: string
-  showAdminAction: boolean
 }) {
   return (
     <>
@@ -840,7 +858,7 @@ function SearchResults({
               onAdmin={onAdmin}
               onCopyDN={onCopyDN}
               onSelect={onSelect}
-              showAdminAction={showAdminAction}
+              showAdminAction={canManage(entry)}
             />
           </div>
         ))}
@@ -875,7 +893,7 @@ function SearchResults({
                     onAdmin={onAdmin}
                     onCopyDN={onCopyDN}
                     onSelect={onSelect}
-                    showAdminAction={showAdminAction}
+                    showAdminAction={canManage(entry)}
                   />
                 </TableCell>
               </TableRow>
@@ -1374,6 +1392,7 @@ function CreateUserWorkflow({
     password: "",
     attributes: "",
   })
+  const [account, setAccount] = useState<AccountState>({ disabled: false, expires: "" })
   const [error, setError] = useState("")
 
   return (
@@ -1392,7 +1411,12 @@ function CreateUserWorkflow({
           setError(missing)
           return
         }
-        void onSubmit("/api/users", "POST", { ...form, attributes: parseAttributes(form.attributes) }, "User created.")
+        void onSubmit(
+          "/api/users",
+          "POST",
+          { ...form, ...accountPayload(account), attributes: parseAttributes(form.attributes) },
+          "User created."
+        )
       }}
     >
       <WorkflowError message={error} />
@@ -1405,6 +1429,7 @@ function CreateUserWorkflow({
         <TextField id="create-user-mail" label="Email" value={form.mail} onChange={(mail) => setForm({ ...form, mail })} type="email" />
         <TextField id="create-user-password" label="Initial password" value={form.password} onChange={(password) => setForm({ ...form, password })} type="password" />
       </FieldGroup>
+      <AccountFields id="create-user" value={account} onChange={setAccount} />
       <AttributesField id="create-user-attributes" value={form.attributes} onChange={(attributes) => setForm({ ...form, attributes })} />
       <WorkflowFooter onCancel={onCancel} submitLabel="Create user" />
     </form>
@@ -1521,6 +1546,10 @@ function EditUserWorkflow({
     mail: firstAttribute(entry, "mail") || entry.mail || "",
     attributes: attributesToText(entry.attributes, ["uid", "cn", "sn", "givenname", "mail", ...protectedExtraAttributes]),
   })
+  const [account, setAccount] = useState<AccountState>({
+    disabled: firstAttribute(entry, "accountdisabled").toUpperCase() === "TRUE",
+    expires: generalizedTimeToInput(firstAttribute(entry, "accountexpirationtime")),
+  })
   const [error, setError] = useState("")
 
   return (
@@ -1539,7 +1568,7 @@ function EditUserWorkflow({
         void onSubmit(
           `/api/users?dn=${encodeURIComponent(entry.dn)}`,
           "PUT",
-          { dn: entry.dn, ...form, attributes: parseAttributes(form.attributes) },
+          { dn: entry.dn, ...form, ...accountPayload(account), attributes: parseAttributes(form.attributes) },
           "User updated."
         )
       }}
@@ -1552,6 +1581,7 @@ function EditUserWorkflow({
         <TextField id="edit-user-given" label="Given name" value={form.givenName} onChange={(givenName) => setForm({ ...form, givenName })} />
         <TextField id="edit-user-mail" label="Email" value={form.mail} onChange={(mail) => setForm({ ...form, mail })} type="email" />
       </FieldGroup>
+      <AccountFields id="edit-user" value={account} onChange={setAccount} />
       <AttributesField id="edit-user-attributes" value={form.attributes} onChange={(attributes) => setForm({ ...form, attributes })} />
       <WorkflowFooter onCancel={onCancel} submitLabel="Save user" />
     </form>
@@ -1722,6 +1752,32 @@ function DeleteEntryDialog({
   onOpenChange: (open: boolean) => void
   onSubmit: (path: string, method: string, payload: unknown, success: string) => Promise<void>
 }) {
+  const [preview, setPreview] = useState<SubtreeDeleteResponse>()
+
+  useEffect(() => {
+    let cancelled = false
+    setPreview(undefined)
+    if (!entry) {
+      return
+    }
+
+    // Only directory administrators may preview and delete subtrees; everyone
+    // else keeps the single entry delete.
+    void fetchJSON<SubtreeDeleteResponse>(`/api/directory/subtree?dn=${encodeURIComponent(entry.dn)}`)
+      .then((data) => {
+        if (!cancelled) {
+          setPreview(data)
+        }
+      })
+      .catch(() => undefined)
+
+    return () => {
+      cancelled = true
+    }
+  }, [entry])
+
+  const subtree = preview && preview.entries.length > 1 ? preview : undefined
+
   return (
     <AlertDialog open={Boolean(entry)} onOpenChange={onOpenChange}>
       <AlertDialogContent>
@@ -1729,22 +1785,45 @@ function DeleteEntryDialog({
           <AlertDialogMedia>
             <Trash2 />
           </AlertDialogMedia>
-          <AlertDialogTitle>Delete entry</AlertDialogTitle>
+          <AlertDialogTitle>{subtree ? "Delete subtree" : "Delete entry"}</AlertDialogTitle>
           <AlertDialogDescription>
-            {entry ? `Delete ${entry.dn}? This cannot be undone and may fail if child entries still exist.` : ""}
+            {entry && subtree
+              ? `Delete ${entry.dn} with the ${subtree.entries.length - 1} entries below it? This cannot be undone.`
+              : entry
+                ? `Delete ${entry.dn}? This cannot be undone and may fail if child entries still exist.`
+                : ""}
           </AlertDialogDescription>
         </AlertDialogHeader>
+        {subtree ? (
+          <div className="grid gap-2">
+            <div className="max-h-48 overflow-y-auto rounded-md border p-2">
+              {subtree.entries.map((dn) => (
+                <p className="break-all font-mono text-xs leading-relaxed text-muted-foreground" key={dn}>
+                  {dn}
+                </p>
+              ))}
+            </div>
+            {subtree.groups.length > 0 ? (
+              <p className="text-sm text-muted-foreground">
+                Deleted entries are removed from {subtree.groups.length === 1 ? "the group" : "the groups"}{" "}
+                {subtree.groups.join(", ")}.
+              </p>
+            ) : null}
+          </div>
+        ) : null}
         <AlertDialogFooter>
           <AlertDialogCancel>Cancel</AlertDialogCancel>
           <AlertDialogAction
             onClick={() => {
-              if (entry) {
+              if (entry && subtree) {
+                void onSubmit(`/api/directory/subtree?dn=${encodeURIComponent(entry.dn)}`, "DELETE", undefined, "Subtree deleted.")
+              } else if (entry) {
                 void onSubmit(deletePath(entry), "DELETE", undefined, "Entry deleted.")
               }
             }}
             variant="destructive"
           >
-            Delete entry
+            {subtree ? "Delete subtree" : "Delete entry"}
           </AlertDialogAction>
         </AlertDialogFooter>
       </AlertDialogContent>
@@ -1836,6 +1915,120 @@ function AccountPanel({
   )
 }
 
+// Group owners manage members here without the admin console.
+function OwnedGroupsPanel({
+  disabled,
+  onMutate,
+}: {
+  disabled: boolean
+  onMutate: (path: string, method: string, payload: unknown, success: string, reload?: boolean) => Promise<void>
+}) {
+  const [groups, setGroups] = useState<EntrySummary[]>()
+  const [error, setError] = useState<string>()
+  const [newMembers, setNewMembers] = useState<Record<string, string>>({})
+
+  async function reload() {
+    try {
+      const response = await fetchJSON<OwnedGroupsResponse>("/api/groups/owned")
+      setGroups(response.groups)
+      setError(undefined)
+    } catch (loadError) {
+      setError(loadError instanceof Error ? loadError.message : "Unable to load your groups.")
+    }
+  }
+
+  useEffect(() => {
+    void reload()
+  }, [])
+
+  function changeMembers(group: EntrySummary, change: { add?: string[]; remove?: string[] }, success: string) {
+    void onMutate(`/api/groups/members?dn=${encodeURIComponent(group.dn)}`, "PATCH", change, success, false)
+      .then(() => {
+        setNewMembers((current) => ({ ...current, [group.dn]: "" }))
+        return reload()
+      })
+      .catch(() => undefined)
+  }
+
+  if (error) {
+    return (
+      <Alert variant="destructive">
+        <AlertCircle />
+        <AlertTitle>Groups unavailable</AlertTitle>
+        <AlertDescription>{error}</AlertDescription>
+      </Alert>
+    )
+  }
+  if (!groups) {
+    return <ResultSkeleton />
+  }
+  if (groups.length === 0) {
+    return (
+      <Empty>
+        <EmptyHeader>
+          <EmptyMedia variant="icon">
+            <Users />
+          </EmptyMedia>
+          <EmptyTitle>No owned groups</EmptyTitle>
+          <EmptyDescription>Groups that list you, or a group you belong to, as owner appear here.</EmptyDescription>
+        </EmptyHeader>
+      </Empty>
+    )
+  }
+
+  return (
+    <section className="grid gap-4">
+      {groups.map((group) => {
+        const newMember = newMembers[group.dn] ?? ""
+        return (
+          <Card key={group.dn}>
+            <CardHeader>
+              <CardTitle>{group.name}</CardTitle>
+              <CardDescription className="break-all font-mono text-xs">{group.dn}</CardDescription>
+            </CardHeader>
+            <CardContent className="flex flex-col gap-3">
+              {(group.members ?? []).map((member) => (
+                <div className="flex min-w-0 items-center justify-between gap-2" key={member}>
+                  <span className="break-all font-mono text-xs">{member}</span>
+                  <Button
+                    aria-label={`Remove ${member}`}
+                    disabled={disabled || (group.members ?? []).length <= 1}
+                    onClick={() => changeMembers(group, { remove: [member] }, "Member removed.")}
+                    size="sm"
+                    variant="ghost"
+                  >
+                    <Trash2 />
+                  </Button>
+                </div>
+              ))}
+              <Separator />
+              <form
+                className="flex min-w-0 gap-2"
+                onSubmit={(event: FormEvent) => {
+                  event.preventDefault()
+                  changeMembers(group, { add: [newMember.trim()] }, "Member added.")
+                }}
+              >
+                <Input
+                  aria-label={`New member of ${group.name}`}
+                  disabled={disabled}
+                  onChange={(event) => setNewMembers((current) => ({ ...current, [group.dn]: event.target.value }))}
+                  placeholder="uid=jane,ou=users,..."
+                  value={newMember}
+                />
+                <Button disabled={disabled || newMember.trim() === ""} type="submit">
+                  <Plus data-icon="inline-start" />
+                  Add
+                </Button>
+              </form>
+            </CardContent>
+          </Card>
+        )
+      })}
+    </section>
+  )
+}
+
 function AdminPanel({
   baseDN,
   onMutate,
@@ -1901,6 +2094,46 @@ function TextField({
   )
 }
 
+type AccountState = {
+  disabled: boolean
+  expires: string
+}
+
+function AccountFields({
+  id,
+  onChange,
+  value,
+}: {
+  id: string
+  onChange: (value: AccountState) => void
+  value: AccountState
+}) {
+  return (
+    <FieldGroup className="grid gap-4 md:grid-cols-2">
+      <Field>
+        <FieldLabel htmlFor={`${id}-status`}>Account status</FieldLabel>
+        <Select onValueChange={(status) => onChange({ ...value, disabled: status === "disabled" })} value={value.disabled ? "disabled" : "active"}>
+          <SelectTrigger aria-label="Account status" className="w-full" id={`${id}-status`}>
+            <SelectValue />
+          </SelectTrigger>
+          <SelectContent>
+            <SelectGroup>
+              <SelectItem value="active">Active</SelectItem>
+              <SelectItem value="disabled">Disabled</SelectItem>
+            </SelectGroup>
+          </SelectContent>
+        </Select>
+        <FieldDescription>Disabled users cannot bind or sign in.</FieldDescription>
+      </Field>
+      <Field>
+        <FieldLabel htmlFor={`${id}-expires`}>Account expires (UTC)</FieldLabel>
+        <Input id={`${id}-expires`} onChange={(event) => onChange({ ...value, expires: event.target.value })} type="datetime-local" value={value.expires} />
+        <FieldDescription>Leave empty for an account that never expires.</FieldDescription>
+      </Field>
+    </FieldGroup>
+  )
+}
+
 function LinesField({
   id,
   label,
@@ -2008,6 +2241,7 @@ function normalizeViewId(value: string | null): ViewId {
     case "users":
     case "groups":
     case "ous":
+    case "owned":
     case "admin":
     case "account":
       return value
@@ -2090,6 +2324,12 @@ function buildNavItems(session?: Session): NavItem[] {
       }
     )
   }
+  items.push({
+    id: "owned",
+    label: "My groups",
+    description: "Manage members of groups you own.",
+    icon: Users,
+  })
   if (session.roles.admin) {
     items.push({
       id: "admin",
@@ -2124,6 +2364,11 @@ function viewCopy(view: ViewId) {
         title: "Organizational units",
         description: "Browse the containers that shape the directory tree.",
       }
+    case "owned":
+      return {
+        title: "My groups",
+        description: "Add and remove members of the groups you own.",
+      }
     case "admin":
       return {
         title: "Directory administration",
@@ -2311,6 +2556,18 @@ function validateMemberDNs(value: string) {
   return invalid ? `Member DN is not valid: ${invalid}` : ""
 }
 
+function accountPayload(account: AccountState) {
+  return {
+    disabled: account.disabled,
+    expirationTime: account.expires ? `${account.expires}:00Z` : "",
+  }
+}
+
+function generalizedTimeToInput(value: string) {
+  const match = /^(\d{4})(\d{2})(\d{2})(\d{2})(\d{2})/.exec(value)
+  return match ? `${match[1]}-${match[2]}-${match[3]}T${match[4]}:${match[5]}` : ""
+}
+
 function firstAttribute(entry: EntryDetail, name: string) {
   return entry.attributes[name.toLowerCase()]?.[0] ?? entry.attributes[name]?.[0] ?? ""
 }
@@ -2337,6 +2594,15 @@ function deletePath(entry: EntrySummary) {
   return `/api/directory/entry?dn=${encoded}`
 }
 
+// Delegated administrators only manage entries inside their adminScopes.
+function canAdminister(session: Session, dn: string) {
+  if (!session.roles.admin) {
+    return false
+  }
+  const scopes = new Set(session.adminScopes.map((scope) => scope.toLowerCase()))
+  return dnLineage(dn.toLowerCase()).some((ancestor) => scopes.has(ancestor))
+}
+
 function parentDN(dn: string) {
   const [, parent = ""] = dn.split(/,(.*)/s)
   return parent
@@ -30,6 +30,8 @@ type sessionResponse struct {
 	UserID       string   `json:"userID"`
 	Capabilities []string `json:"capabilities"`
 	Roles        roles    `json:"roles"`
+	// AdminScopes lists the subtrees the user may administer.
+	AdminScopes []string `json:"adminScopes"`
 }
 
 type roles struct {
@@ -58,6 +60,38 @@ type entrySummary struct {
 	MemberOf    []string `json:"memberOf,omitempty"`
 }
 
+// subtreeDeleteResponse lists the entries a subtree delete removes, children
+// before their parents, and the groups outside the subtree losing members.
+type subtreeDeleteResponse struct {
+	DN      string   `json:"dn"`
+	Deleted bool     `json:"deleted"`
+	Entries []string `json:"entries"`
+	Groups  []string `json:"groups"`
+}
+
+type ownedGroupsResponse struct {
+	Groups []entrySummary `json:"groups"`
+}
+
+type accessExplainResponse struct {
+	Subject    string                 `json:"subject"`
+	DN         string                 `json:"dn"`
+	Entry      []permissionDecision   `json:"entry"`
+	Attributes []attributeExplanation `json:"attributes"`
+}
+
+type attributeExplanation struct {
+	Name      string               `json:"name"`
+	Decisions []permissionDecision `json:"decisions"`
+}
+
+type permissionDecision struct {
+	Permission string `json:"permission"`
+	Allowed    bool   `json:"allowed"`
+	Reason     string `json:"reason"`
+	Rule       string `json:"rule,omitempty"`
+}
+
 type directorySearchResponse struct {
 	BaseDN     string         `json:"baseDN"`
 	Query      string         `json:"query"`
@@ -103,6 +137,14 @@ func (h *APIHandler) Session(w http.ResponseWriter, r *http.Request) {
 	}
 
 	capabilities := middleware.GetCapabilities(r)
+	scopes, err := authz.FromConfig(h.cfg, h.store).AdminScopes(r.Context(), requestActor(r))
+	if err != nil {
+		http.Error(w, "Failed to load session", http.StatusInternalServerError)
+		return
+	}
+	if scopes == nil {
+		scopes = []string{}
+	}
 	writeJSON(w, sessionResponse{
 		BaseDN:       h.cfg.LDAP.BaseDN,
 		UserDN:       middleware.GetUserDN(r),
@@ -115,6 +157,7 @@ func (h *APIHandler) Session(w http.ResponseWriter, r *http.Request) {
 			PasswordSelf:   capabilities.Has(authz.PasswordChangeSelf),
 			PasswordReset:  capabilities.Has(authz.PasswordResetAny),
 		},
+		AdminScopes: scopes,
 	})
 }
 
@@ -146,6 +189,14 @@ func (h *APIHandler) DirectorySearch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Queries match only what the user may read, so they cannot probe
+	// hidden values.
+	entries, err = visibleEntries(r.Context(), h.store, h.cfg, requestActor(r), entries, directoryTypeFilter(entryType))
+	if err != nil {
+		http.Error(w, "Failed to search directory", http.StatusInternalServerError)
+		return
+	}
+
 	summaries := make([]entrySummary, 0, len(entries))
 	for _, entry := range entries {
 		if !matchesDirectoryQuery(entry, query) {
@@ -212,10 +263,19 @@ func (h *APIHandler) DirectoryEntry(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Entry not found", http.StatusNotFound)
 		return
 	}
+	visible, err := visibleEntries(r.Context(), h.store, h.cfg, requestActor(r), []*models.Entry{entry}, "")
+	if err != nil {
+		http.Error(w, "Failed to load entry", http.StatusInternalServerError)
+		return
+	}
+	if len(visible) == 0 {
+		http.Error(w, "Entry not found", http.StatusNotFound)
+		return
+	}
 
 	writeJSON(w, directoryDetailResponse{
 		BaseDN: h.cfg.LDAP.BaseDN,
-		Entry:  detailEntry(entry),
+		Entry:  detailEntry(visible[0]),
 	})
 }
 
@@ -226,17 +286,18 @@ func (h *APIHandler) Directory(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ctx := r.Context()
-	users, err := h.searchSummaries(ctx, "(objectClass=inetOrgPerson)")
+	actor := requestActor(r)
+	users, err := h.searchSummaries(ctx, actor, "(objectClass=inetOrgPerson)")
 	if err != nil {
 		http.Error(w, "Failed to load users", http.StatusInternalServerError)
 		return
 	}
-	groups, err := h.searchSummaries(ctx, "(objectClass=groupOfNames)")
+	groups, err := h.searchSummaries(ctx, actor, "(objectClass=groupOfNames)")
 	if err != nil {
 		http.Error(w, "Failed to load groups", http.StatusInternalServerError)
 		return
 	}
-	ous, err := h.searchSummaries(ctx, "(objectClass=organizationalUnit)")
+	ous, err := h.searchSummaries(ctx, actor, "(objectClass=organizationalUnit)")
 	if err != nil {
 		http.Error(w, "Failed to load organizational units", http.StatusInternalServerError)
 		return
@@ -250,7 +311,114 @@ func (h *APIHandler) Directory(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-func (h *APIHandler) searchSummaries(ctx context.Context, filter string) ([]entrySummary, error) {
+// OwnedGroups lists the groups the signed-in user owns.
+func (h *APIHandler) OwnedGroups(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	entries, err := h.service.OwnedGroups(r.Context(), requestActor(r))
+	if err != nil {
+		http.Error(w, "Failed to load owned groups", http.StatusInternalServerError)
+		return
+	}
+	groups := make([]entrySummary, 0, len(entries))
+	for _, entry := range entries {
+		groups = append(groups, summarizeEntry(entry))
+	}
+	writeJSON(w, ownedGroupsResponse{Groups: groups})
+}
+
+// AccessExplain explains what subject may do to the entry at dn and to its
+// attributes, and which access rule decided each permission. Without
+// attribute parameters it explains every attribute the entry has. Delegated
+// administrators may only explain entries inside their subtrees.
+func (h *APIHandler) AccessExplain(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	subject := strings.TrimSpace(query.Get("subject"))
+	dn := strings.TrimSpace(query.Get("dn"))
+	if subject == "" || dn == "" {
+		http.Error(w, "subject and dn parameters required", http.StatusBadRequest)
+		return
+	}
+	// Entries outside the caller's administrative subtrees look missing, so
+	// delegated administrators cannot probe the rest of the directory.
+	access := authz.FromConfig(h.cfg, h.store)
+	allowed, err := access.Allows(r.Context(), requestActor(r), authz.UIAdmin, dn)
+	if err != nil {
+		http.Error(w, "Failed to explain access", http.StatusInternalServerError)
+		return
+	}
+	if !allowed || !ldapdn.WithinBase(dn, h.cfg.LDAP.BaseDN) {
+		http.Error(w, "Entry not found", http.StatusNotFound)
+		return
+	}
+	entry, err := getEntryWithoutMemberOf(r.Context(), h.store, dn)
+	if err != nil && !errors.Is(err, store.ErrNoSuchObject) {
+		http.Error(w, "Failed to load entry", http.StatusInternalServerError)
+		return
+	}
+	if entry == nil {
+		http.Error(w, "Entry not found", http.StatusNotFound)
+		return
+	}
+
+	attributes := query["attribute"]
+	if len(attributes) == 0 {
+		for name := range entry.Attributes {
+			attributes = append(attributes, name)
+		}
+		sort.Slice(attributes, func(i, j int) bool {
+			return strings.ToLower(attributes[i]) < strings.ToLower(attributes[j])
+		})
+	}
+
+	actor := authz.BoundUser(subject)
+	entryDecisions, err := access.Explain(r.Context(), actor, entry, "")
+	if err != nil {
+		http.Error(w, "Failed to explain access", http.StatusInternalServerError)
+		return
+	}
+	response := accessExplainResponse{
+		Subject:    subject,
+		DN:         entry.DN,
+		Entry:      permissionDecisions(entryDecisions),
+		Attributes: make([]attributeExplanation, 0, len(attributes)),
+	}
+	for _, name := range attributes {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		decisions, err := access.Explain(r.Context(), actor, entry, name)
+		if err != nil {
+			http.Error(w, "Failed to explain access", http.StatusInternalServerError)
+			return
+		}
+		response.Attributes = append(response.Attributes, attributeExplanation{Name: name, Decisions: permissionDecisions(decisions)})
+	}
+	writeJSON(w, response)
+}
+
+func permissionDecisions(explanation authz.Explanation) []permissionDecision {
+	decisions := make([]permissionDecision, 0, len(explanation))
+	for _, d := range explanation {
+		decisions = append(decisions, permissionDecision{
+			Permission: string(d.Permission),
+			Allowed:    d.Allowed,
+			Reason:     d.Reason,
+			Rule:       d.Rule,
+		})
+	}
+	return decisions
+}
+
+func (h *APIHandler) searchSummaries(ctx context.Context, actor authz.Actor, filter string) ([]entrySummary, error) {
 	entries, err := h.store.SearchEntriesWithOptions(ctx, store.SearchOptions{
 		BaseDN:          h.cfg.LDAP.BaseDN,
 		Filter:          filter,
@@ -260,6 +428,10 @@ func (h *APIHandler) searchSummaries(ctx context.Context, filter string) ([]entr
 	if err != nil {
 		return nil, err
 	}
+	entries, err = visibleEntries(ctx, h.store, h.cfg, actor, entries, filter)
+	if err != nil {
+		return nil, err
+	}
 
 	summaries := make([]entrySummary, 0, len(entries))
 	for _, entry := range entries {
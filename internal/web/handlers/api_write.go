@@ -4,10 +4,14 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"strings"
 
+	"github.com/smarzola/ldaplite/internal/authz"
 	"github.com/smarzola/ldaplite/internal/directory"
+	"github.com/smarzola/ldaplite/internal/ppolicy"
 	"github.com/smarzola/ldaplite/internal/store"
 	"github.com/smarzola/ldaplite/internal/web/middleware"
+	"github.com/smarzola/ldaplite/pkg/crypto"
 )
 
 type passwordRequest struct {
@@ -22,7 +26,10 @@ func (h *APIHandler) Users(w http.ResponseWriter, r *http.Request) {
 		if !decodeJSON(w, r, &input) {
 			return
 		}
-		entry, err := h.service.CreateUser(r.Context(), input)
+		if !h.checkWrite(w, r, authz.UsersCreate, "create", "user", directory.NewEntryDN("uid", input.UID, input.ParentDN)) {
+			return
+		}
+		entry, err := h.service.CreateUser(r.Context(), requestActor(r), input)
 		if err != nil {
 			writeAPIError(w, err)
 			auditWebWrite(r, "create", "user", "", statusForError(err), err)
@@ -36,7 +43,10 @@ func (h *APIHandler) Users(w http.ResponseWriter, r *http.Request) {
 		if !decodeJSON(w, r, &input) {
 			return
 		}
-		entry, err := h.service.UpdateUser(r.Context(), dn, input)
+		if !h.checkWrite(w, r, authz.DirectoryWrite, "update", "user", dn) {
+			return
+		}
+		entry, err := h.service.UpdateUser(r.Context(), requestActor(r), dn, input)
 		if err != nil {
 			writeAPIError(w, err)
 			auditWebWrite(r, "update", "user", dn, statusForError(err), err)
@@ -46,6 +56,9 @@ func (h *APIHandler) Users(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, summarizeEntry(entry))
 	case http.MethodDelete:
 		dn := r.URL.Query().Get("dn")
+		if !h.checkWrite(w, r, authz.DirectoryWrite, "delete", "user", dn) {
+			return
+		}
 		if err := h.service.DeleteEntry(r.Context(), dn); err != nil {
 			writeAPIError(w, err)
 			auditWebWrite(r, "delete", "user", dn, statusForError(err), err)
@@ -65,6 +78,9 @@ func (h *APIHandler) Groups(w http.ResponseWriter, r *http.Request) {
 		if !decodeJSON(w, r, &input) {
 			return
 		}
+		if !h.checkWrite(w, r, authz.DirectoryWrite, "create", "group", directory.NewEntryDN("cn", input.CN, input.ParentDN)) {
+			return
+		}
 		entry, err := h.service.CreateGroup(r.Context(), input)
 		if err != nil {
 			writeAPIError(w, err)
@@ -79,6 +95,9 @@ func (h *APIHandler) Groups(w http.ResponseWriter, r *http.Request) {
 		if !decodeJSON(w, r, &input) {
 			return
 		}
+		if !h.checkWrite(w, r, authz.DirectoryWrite, "update", "group", dn) {
+			return
+		}
 		entry, err := h.service.UpdateGroup(r.Context(), dn, input)
 		if err != nil {
 			writeAPIError(w, err)
@@ -89,6 +108,9 @@ func (h *APIHandler) Groups(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, summarizeEntry(entry))
 	case http.MethodDelete:
 		dn := r.URL.Query().Get("dn")
+		if !h.checkWrite(w, r, authz.DirectoryWrite, "delete", "group", dn) {
+			return
+		}
 		if err := h.service.DeleteEntry(r.Context(), dn); err != nil {
 			writeAPIError(w, err)
 			auditWebWrite(r, "delete", "group", dn, statusForError(err), err)
@@ -101,6 +123,28 @@ func (h *APIHandler) Groups(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// GroupMembers adds and removes members of the group named by the dn query
+// parameter. Group owners may use it as well as administrators.
+func (h *APIHandler) GroupMembers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	dn := r.URL.Query().Get("dn")
+	var input directory.MembershipChange
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	entry, err := h.service.ChangeMembers(r.Context(), requestActor(r), authz.DirectoryWrite, dn, input)
+	if err != nil {
+		writeAPIError(w, err)
+		auditWebWrite(r, "update-members", "group", dn, statusForError(err), err)
+		return
+	}
+	auditWebWrite(r, "update-members", "group", entry.DN, http.StatusOK, nil)
+	writeJSON(w, summarizeEntry(entry))
+}
+
 func (h *APIHandler) OUs(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodPost:
@@ -108,6 +152,9 @@ func (h *APIHandler) OUs(w http.ResponseWriter, r *http.Request) {
 		if !decodeJSON(w, r, &input) {
 			return
 		}
+		if !h.checkWrite(w, r, authz.DirectoryWrite, "create", "ou", directory.NewEntryDN("ou", input.OU, input.ParentDN)) {
+			return
+		}
 		entry, err := h.service.CreateOU(r.Context(), input)
 		if err != nil {
 			writeAPIError(w, err)
@@ -122,6 +169,9 @@ func (h *APIHandler) OUs(w http.ResponseWriter, r *http.Request) {
 		if !decodeJSON(w, r, &input) {
 			return
 		}
+		if !h.checkWrite(w, r, authz.DirectoryWrite, "update", "ou", dn) {
+			return
+		}
 		entry, err := h.service.UpdateOU(r.Context(), dn, input)
 		if err != nil {
 			writeAPIError(w, err)
@@ -132,6 +182,9 @@ func (h *APIHandler) OUs(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, summarizeEntry(entry))
 	case http.MethodDelete:
 		dn := r.URL.Query().Get("dn")
+		if !h.checkWrite(w, r, authz.DirectoryWrite, "delete", "ou", dn) {
+			return
+		}
 		if err := h.service.DeleteEntry(r.Context(), dn); err != nil {
 			writeAPIError(w, err)
 			auditWebWrite(r, "delete", "ou", dn, statusForError(err), err)
@@ -144,6 +197,38 @@ func (h *APIHandler) OUs(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// Subtree previews (GET) and performs (DELETE) the delete of an entry with
+// all of its descendants. Only administrators may use it.
+func (h *APIHandler) Subtree(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	dn := strings.TrimSpace(r.URL.Query().Get("dn"))
+	dryRun := r.Method == http.MethodGet
+	result, err := h.service.DeleteSubtree(r.Context(), requestActor(r), dn, dryRun)
+	if err != nil {
+		writeAPIError(w, err)
+		if !dryRun {
+			auditWebWrite(r, "delete-subtree", "entry", dn, statusForError(err), err)
+		}
+		return
+	}
+	if !dryRun {
+		auditWebWrite(r, "delete-subtree", "entry", dn, http.StatusOK, nil)
+	}
+	groups := result.Groups
+	if groups == nil {
+		groups = []string{}
+	}
+	writeJSON(w, subtreeDeleteResponse{
+		DN:      dn,
+		Deleted: !dryRun,
+		Entries: result.DNs,
+		Groups:  groups,
+	})
+}
+
 func (h *APIHandler) ChangeOwnPassword(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -176,7 +261,10 @@ func (h *APIHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.service.ResetPassword(r.Context(), input.DN, input.Password); err != nil {
+	if !h.checkWrite(w, r, authz.PasswordResetAny, "reset-password", "user", input.DN) {
+		return
+	}
+	if err := h.service.ResetPassword(r.Context(), requestActor(r), input.DN, input.Password); err != nil {
 		writeAPIError(w, err)
 		auditWebWrite(r, "reset-password", "user", input.DN, statusForError(err), err)
 		return
@@ -185,6 +273,18 @@ func (h *APIHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// checkWrite rejects, answers and audits a write to dn that the signed-in
+// user does not hold capability for.
+func (h *APIHandler) checkWrite(w http.ResponseWriter, r *http.Request, capability authz.Capability, operation, resource, dn string) bool {
+	err := h.service.CheckWrite(r.Context(), requestActor(r), capability, dn)
+	if err == nil {
+		return true
+	}
+	writeAPIError(w, err)
+	auditWebWrite(r, operation, resource, dn, statusForError(err), err)
+	return false
+}
+
 func decodeJSON(w http.ResponseWriter, r *http.Request, target any) bool {
 	defer r.Body.Close()
 	decoder := json.NewDecoder(r.Body)
@@ -212,13 +312,19 @@ func statusForError(err error) int {
 		errors.Is(err, directory.ErrProtectedAttribute),
 		errors.Is(err, directory.ErrUnsupportedObject),
 		errors.Is(err, directory.ErrPasswordNotProvided),
+		errors.Is(err, ppolicy.ErrPolicyViolation),
 		errors.Is(err, store.ErrConstraintViolation),
 		errors.Is(err, store.ErrObjectClassViolation):
 		return http.StatusBadRequest
+	case errors.Is(err, directory.ErrAccessDenied):
+		return http.StatusForbidden
 	case errors.Is(err, store.ErrNoSuchObject):
 		return http.StatusNotFound
-	case errors.Is(err, store.ErrEntryAlreadyExists):
+	case errors.Is(err, store.ErrEntryAlreadyExists),
+		errors.Is(err, store.ErrNotAllowedOnNonLeaf):
 		return http.StatusConflict
+	case errors.Is(err, crypto.ErrHashPoolBusy):
+		return http.StatusServiceUnavailable
 	default:
 		return http.StatusInternalServerError
 	}
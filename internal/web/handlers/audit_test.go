@@ -8,10 +8,13 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/smarzola/ldaplite/internal/audit"
 	"github.com/smarzola/ldaplite/internal/models"
 	"github.com/smarzola/ldaplite/internal/store"
+	"github.com/smarzola/ldaplite/internal/web/middleware"
+	"github.com/smarzola/ldaplite/pkg/config"
 )
 
 func TestDeleteEntryAuditsSuccessfulWebWrite(t *testing.T) {
@@ -24,10 +27,12 @@ func TestDeleteEntryAuditsSuccessfulWebWrite(t *testing.T) {
 		Method:    http.MethodPost,
 		Route:     "/users/delete",
 	}
-	req = req.WithContext(audit.WithRequestInfo(req.Context(), info))
+	ctx := context.WithValue(audit.WithRequestInfo(req.Context(), info), middleware.UserDNKey, info.ActorDN)
+	req = req.WithContext(ctx)
 	rr := httptest.NewRecorder()
 
-	deleteEntry(rr, req, &handlerAuditStore{}, "/users", "user", "user")
+	st := &handlerAuditStore{groups: map[string]bool{"cn=ldaplite.admin,ou=groups,dc=example,dc=com": true}}
+	deleteEntry(rr, req, st, handlerAuditConfig(), "/users", "user", "user")
 
 	got := logs.String()
 	assertHandlerLogContains(t, got, `"event":"web.write"`)
@@ -39,6 +44,34 @@ func TestDeleteEntryAuditsSuccessfulWebWrite(t *testing.T) {
 	assertHandlerLogContains(t, got, `"status":302`)
 }
 
+func TestDeleteEntryRejectsTargetOutsideDelegatedSubtree(t *testing.T) {
+	logs := captureHandlerAuditLogs(t)
+	req := httptest.NewRequest(http.MethodPost, "http://ldaplite.test/users/delete", strings.NewReader("dn=uid%3Djane%2Cou%3Dusers%2Cdc%3Dexample%2Cdc%3Dcom"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserDNKey, "uid=lead,ou=emea,dc=example,dc=com"))
+	rr := httptest.NewRecorder()
+
+	cfg := handlerAuditConfig()
+	cfg.Authz.DelegatedAdmins = []config.DelegatedAdmin{{
+		GroupDN:  "cn=emea-admins,ou=groups,dc=example,dc=com",
+		Subtrees: []string{"ou=emea,dc=example,dc=com"},
+	}}
+	st := &handlerAuditStore{groups: map[string]bool{"cn=emea-admins,ou=groups,dc=example,dc=com": true}}
+	deleteEntry(rr, req, st, cfg, "/users", "user", "user")
+
+	if st.deleted != 0 {
+		t.Fatalf("DeleteEntry called %d times, want 0", st.deleted)
+	}
+	if location := rr.Header().Get("Location"); !strings.Contains(location, "error=") {
+		t.Fatalf("Location = %q, want an error message", location)
+	}
+	assertHandlerLogContains(t, logs.String(), `"status":403`)
+}
+
+func handlerAuditConfig() *config.Config {
+	return &config.Config{LDAP: config.LDAPConfig{BaseDN: "dc=example,dc=com"}}
+}
+
 func captureHandlerAuditLogs(t *testing.T) *bytes.Buffer {
 	t.Helper()
 
@@ -58,7 +91,10 @@ func assertHandlerLogContains(t *testing.T, got, want string) {
 	}
 }
 
-type handlerAuditStore struct{}
+type handlerAuditStore struct {
+	groups  map[string]bool
+	deleted int
+}
 
 func (s *handlerAuditStore) Initialize(ctx context.Context) error { return nil }
 
@@ -74,9 +110,36 @@ func (s *handlerAuditStore) GetEntryWithOptions(ctx context.Context, dn string,
 
 func (s *handlerAuditStore) CreateEntry(ctx context.Context, entry *models.Entry) error { return nil }
 
+func (s *handlerAuditStore) CreateEntryWithOptions(ctx context.Context, entry *models.Entry, options store.WriteOptions) (store.WriteResult, error) {
+	return store.WriteResult{}, nil
+}
+
 func (s *handlerAuditStore) UpdateEntry(ctx context.Context, entry *models.Entry) error { return nil }
 
-func (s *handlerAuditStore) DeleteEntry(ctx context.Context, dn string) error { return nil }
+func (s *handlerAuditStore) ModifyEntry(ctx context.Context, dn string, options store.WriteOptions, modify func(entry *models.Entry) error) (store.WriteResult, error) {
+	return store.WriteResult{}, nil
+}
+
+func (s *handlerAuditStore) DeleteEntry(ctx context.Context, dn string) error {
+	s.deleted++
+	return nil
+}
+
+func (s *handlerAuditStore) RenameEntry(ctx context.Context, dn string, options store.RenameOptions) (string, error) {
+	return "", nil
+}
+
+func (s *handlerAuditStore) DeleteEntryWithOptions(ctx context.Context, dn string, options store.WriteOptions) (store.WriteResult, error) {
+	return store.WriteResult{}, nil
+}
+
+func (s *handlerAuditStore) DeleteSubtree(ctx context.Context, dn string, options store.SubtreeDeleteOptions) (store.SubtreeDeleteResult, error) {
+	return store.SubtreeDeleteResult{}, nil
+}
+
+func (s *handlerAuditStore) RenameEntryWithOptions(ctx context.Context, dn string, rename store.RenameOptions, options store.WriteOptions) (string, store.WriteResult, error) {
+	return "", store.WriteResult{}, nil
+}
 
 func (s *handlerAuditStore) SearchEntries(ctx context.Context, baseDN string, filter string) ([]*models.Entry, error) {
 	return nil, nil
@@ -86,6 +149,10 @@ func (s *handlerAuditStore) SearchEntriesWithOptions(ctx context.Context, option
 	return nil, nil
 }
 
+func (s *handlerAuditStore) CountEntriesWithOptions(ctx context.Context, options store.SearchOptions) (int, error) {
+	return 0, nil
+}
+
 func (s *handlerAuditStore) EntryExists(ctx context.Context, dn string) (bool, error) {
 	return false, nil
 }
@@ -98,6 +165,42 @@ func (s *handlerAuditStore) GetUserPasswordHashByDN(ctx context.Context, dn stri
 	return "", "", nil
 }
 
-func (s *handlerAuditStore) IsUserInGroup(ctx context.Context, userDN, groupDN string) (bool, error) {
+func (s *handlerAuditStore) GetUserSCRAMSecret(ctx context.Context, uid string) (string, string, error) {
+	return "", "", nil
+}
+
+func (s *handlerAuditStore) RehashUserPassword(ctx context.Context, dn, oldHash, newHash, scramSecret string) error {
+	return nil
+}
+
+func (s *handlerAuditStore) GetPasswordPolicyState(ctx context.Context, dn string) (*store.PasswordPolicyState, error) {
+	return nil, nil
+}
+
+func (s *handlerAuditStore) RecordPasswordFailure(ctx context.Context, dn string, failureTime, expireBefore time.Time, maxFailures int) (bool, error) {
 	return false, nil
 }
+
+func (s *handlerAuditStore) ResetPasswordFailures(ctx context.Context, dn string) error {
+	return nil
+}
+
+func (s *handlerAuditStore) RecordGraceLogin(ctx context.Context, dn string) error {
+	return nil
+}
+
+func (s *handlerAuditStore) GetPasswordHistory(ctx context.Context, dn string) ([]string, error) {
+	return nil, nil
+}
+
+func (s *handlerAuditStore) SetAccountStatus(ctx context.Context, dn string, status models.AccountStatus) error {
+	return nil
+}
+
+func (s *handlerAuditStore) UpdateEntryWithAccountStatus(ctx context.Context, entry *models.Entry, status models.AccountStatus) error {
+	return nil
+}
+
+func (s *handlerAuditStore) IsUserInGroup(ctx context.Context, userDN, groupDN string) (bool, error) {
+	return s.groups[groupDN], nil
+}
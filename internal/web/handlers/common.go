@@ -10,6 +10,8 @@ import (
 	"strings"
 
 	"github.com/smarzola/ldaplite/internal/audit"
+	"github.com/smarzola/ldaplite/internal/authz"
+	"github.com/smarzola/ldaplite/internal/directory"
 	"github.com/smarzola/ldaplite/internal/ldapdn"
 	"github.com/smarzola/ldaplite/internal/models"
 	"github.com/smarzola/ldaplite/internal/store"
@@ -49,7 +51,7 @@ func redirectWithMessage(w http.ResponseWriter, r *http.Request, path, key, mess
 	http.Redirect(w, r, path+"?"+values.Encode(), http.StatusFound)
 }
 
-func deleteEntry(w http.ResponseWriter, r *http.Request, st store.Store, path, errorResourceName, successResourceName string) {
+func deleteEntry(w http.ResponseWriter, r *http.Request, st store.Store, cfg *config.Config, path, errorResourceName, successResourceName string) {
 	if r.Method != http.MethodPost {
 		auditWebWrite(r, "delete", errorResourceName, "", http.StatusMethodNotAllowed, fmt.Errorf("method not allowed"))
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -63,6 +65,12 @@ func deleteEntry(w http.ResponseWriter, r *http.Request, st store.Store, path, e
 		return
 	}
 
+	if err := directory.CheckWrite(r.Context(), st, cfg, requestActor(r), authz.DirectoryWrite, dn); err != nil {
+		auditWebWrite(r, "delete", errorResourceName, dn, statusForError(err), err)
+		redirectWithMessage(w, r, path, "error", fmt.Sprintf("Failed to delete %s: %v", errorResourceName, err))
+		return
+	}
+
 	if err := st.DeleteEntry(r.Context(), dn); err != nil {
 		auditWebWrite(r, "delete", errorResourceName, dn, http.StatusInternalServerError, err)
 		redirectWithMessage(w, r, path, "error", fmt.Sprintf("Failed to delete %s: %v", errorResourceName, err))
@@ -203,6 +211,15 @@ func searchEntriesWithoutMemberOf(ctx context.Context, st store.Store, baseDN, f
 	})
 }
 
+// visibleEntries applies the access rules to entries found with filter.
+func visibleEntries(ctx context.Context, st store.Store, cfg *config.Config, actor authz.Actor, entries []*models.Entry, filter string) ([]*models.Entry, error) {
+	return authz.FromConfig(cfg, st).VisibleEntries(ctx, actor, entries, filter)
+}
+
+func requestActor(r *http.Request) authz.Actor {
+	return authz.BoundUser(middleware.GetUserDN(r))
+}
+
 func getEntryWithoutMemberOf(ctx context.Context, st store.Store, dn string) (*models.Entry, error) {
 	return st.GetEntryWithOptions(ctx, dn, store.EntryOptions{IncludeMemberOf: false})
 }
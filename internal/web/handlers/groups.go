@@ -6,6 +6,8 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/smarzola/ldaplite/internal/authz"
+	"github.com/smarzola/ldaplite/internal/directory"
 	"github.com/smarzola/ldaplite/internal/models"
 	"github.com/smarzola/ldaplite/internal/store"
 	"github.com/smarzola/ldaplite/pkg/config"
@@ -33,6 +35,9 @@ func (h *GroupHandler) List(w http.ResponseWriter, r *http.Request) {
 
 	// Get all groups from all OUs (search recursively from base DN)
 	entries, err := searchEntriesWithoutMemberOf(ctx, h.store, h.cfg.LDAP.BaseDN, "(objectClass=groupOfNames)")
+	if err == nil {
+		entries, err = visibleEntries(ctx, h.store, h.cfg, requestActor(r), entries, "(objectClass=groupOfNames)")
+	}
 	if err != nil {
 		slog.Error("Failed to search groups", "error", err)
 		entries = []*models.Entry{}
@@ -98,6 +103,11 @@ func (h *GroupHandler) create(w http.ResponseWriter, r *http.Request) {
 	}
 
 	group := models.NewGroup(parentDN, cn, description)
+	if err := directory.CheckWrite(ctx, h.store, h.cfg, requestActor(r), authz.DirectoryWrite, group.DN); err != nil {
+		auditWebWrite(r, "create", "group", group.DN, statusForError(err), err)
+		h.showError(w, r, err.Error(), nil)
+		return
+	}
 	for _, member := range members {
 		group.AddMember(member)
 	}
@@ -159,6 +169,11 @@ func (h *GroupHandler) update(w http.ResponseWriter, r *http.Request, dn string)
 		h.showError(w, r, fmt.Sprintf("Group not found: %v", err), nil)
 		return
 	}
+	if err := directory.CheckWrite(ctx, h.store, h.cfg, requestActor(r), authz.DirectoryWrite, dn); err != nil {
+		auditWebWrite(r, "update", "group", dn, statusForError(err), err)
+		h.showError(w, r, err.Error(), entry)
+		return
+	}
 
 	if err := r.ParseForm(); err != nil {
 		auditWebWrite(r, "update", "group", dn, http.StatusBadRequest, err)
@@ -186,7 +201,7 @@ func (h *GroupHandler) update(w http.ResponseWriter, r *http.Request, dn string)
 }
 
 func (h *GroupHandler) Delete(w http.ResponseWriter, r *http.Request) {
-	deleteEntry(w, r, h.store, "/groups", "group", "Group")
+	deleteEntry(w, r, h.store, h.cfg, "/groups", "group", "Group")
 }
 
 func (h *GroupHandler) showError(w http.ResponseWriter, r *http.Request, errMsg string, group *models.Entry) {
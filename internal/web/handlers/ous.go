@@ -6,6 +6,8 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/smarzola/ldaplite/internal/authz"
+	"github.com/smarzola/ldaplite/internal/directory"
 	"github.com/smarzola/ldaplite/internal/models"
 	"github.com/smarzola/ldaplite/internal/store"
 	"github.com/smarzola/ldaplite/pkg/config"
@@ -32,6 +34,9 @@ func (h *OUHandler) List(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
 	entries, err := searchEntriesWithoutMemberOf(ctx, h.store, h.cfg.LDAP.BaseDN, "(objectClass=organizationalUnit)")
+	if err == nil {
+		entries, err = visibleEntries(ctx, h.store, h.cfg, requestActor(r), entries, "(objectClass=organizationalUnit)")
+	}
 	if err != nil {
 		slog.Error("Failed to search OUs", "error", err)
 		entries = []*models.Entry{}
@@ -90,6 +95,11 @@ func (h *OUHandler) create(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ouEntry := models.NewOrganizationalUnit(parentDN, ou, description)
+	if err := directory.CheckWrite(ctx, h.store, h.cfg, requestActor(r), authz.DirectoryWrite, ouEntry.DN); err != nil {
+		auditWebWrite(r, "create", "ou", ouEntry.DN, statusForError(err), err)
+		h.showError(w, r, err.Error(), nil)
+		return
+	}
 
 	// Add extra attributes
 	addExtraAttributes(ouEntry.Entry, ParseAttributes(r.FormValue("attributes")))
@@ -148,6 +158,11 @@ func (h *OUHandler) update(w http.ResponseWriter, r *http.Request, dn string) {
 		h.showError(w, r, fmt.Sprintf("OU not found: %v", err), nil)
 		return
 	}
+	if err := directory.CheckWrite(ctx, h.store, h.cfg, requestActor(r), authz.DirectoryWrite, dn); err != nil {
+		auditWebWrite(r, "update", "ou", dn, statusForError(err), err)
+		h.showError(w, r, err.Error(), entry)
+		return
+	}
 
 	if err := r.ParseForm(); err != nil {
 		auditWebWrite(r, "update", "ou", dn, http.StatusBadRequest, err)
@@ -172,7 +187,7 @@ func (h *OUHandler) update(w http.ResponseWriter, r *http.Request, dn string) {
 }
 
 func (h *OUHandler) Delete(w http.ResponseWriter, r *http.Request) {
-	deleteEntry(w, r, h.store, "/ous", "OU", "OU")
+	deleteEntry(w, r, h.store, h.cfg, "/ous", "OU", "OU")
 }
 
 func (h *OUHandler) showError(w http.ResponseWriter, r *http.Request, errMsg string, ou *models.Entry) {
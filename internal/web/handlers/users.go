@@ -1,12 +1,16 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"strings"
 
+	"github.com/smarzola/ldaplite/internal/authz"
+	"github.com/smarzola/ldaplite/internal/directory"
 	"github.com/smarzola/ldaplite/internal/models"
+	"github.com/smarzola/ldaplite/internal/ppolicy"
 	"github.com/smarzola/ldaplite/internal/store"
 	"github.com/smarzola/ldaplite/pkg/config"
 	"github.com/smarzola/ldaplite/pkg/crypto"
@@ -17,17 +21,20 @@ type UserHandler struct {
 	cfg       *config.Config
 	templates TemplateGetter
 	hasher    *crypto.PasswordHasher
+	policy    *ppolicy.Policy
 }
 
 var userFormAttributes = []string{"uid", "cn", "sn", "givenName", "mail"}
-var userFormExcludeAttributes = []string{"uid", "cn", "sn", "givenName", "mail", "objectClass", "userPassword", "createTimestamp", "modifyTimestamp", "memberOf"}
+var userFormExcludeAttributes = []string{"uid", "cn", "sn", "givenName", "mail", "objectClass", "userPassword", "createTimestamp", "modifyTimestamp", "memberOf", "accountDisabled", "accountExpirationTime"}
 
 func NewUserHandler(st store.Store, cfg *config.Config, getter TemplateGetter) *UserHandler {
+	hasher := crypto.NewPasswordHasher(cfg.Security.Argon2Config)
 	return &UserHandler{
 		store:     st,
 		cfg:       cfg,
 		templates: getter,
-		hasher:    crypto.NewPasswordHasher(cfg.Security.Argon2Config),
+		hasher:    hasher,
+		policy:    ppolicy.New(cfg.Security.PasswordPolicy, st, hasher),
 	}
 }
 
@@ -36,6 +43,9 @@ func (h *UserHandler) List(w http.ResponseWriter, r *http.Request) {
 
 	// Get all users from all OUs (search recursively from base DN)
 	entries, err := searchEntriesWithoutMemberOf(ctx, h.store, h.cfg.LDAP.BaseDN, "(objectClass=inetOrgPerson)")
+	if err == nil {
+		entries, err = visibleEntries(ctx, h.store, h.cfg, requestActor(r), entries, "(objectClass=inetOrgPerson)")
+	}
 	if err != nil {
 		slog.Error("Failed to search users", "error", err)
 		entries = []*models.Entry{}
@@ -96,21 +106,31 @@ func (h *UserHandler) create(w http.ResponseWriter, r *http.Request) {
 		h.showError(w, r, "Parent OU, UID, CN, SN, and password are required", nil)
 		return
 	}
+	if err := h.policy.CheckQuality(password); err != nil {
+		auditWebWrite(r, "create", "user", "", http.StatusBadRequest, err)
+		h.showError(w, r, err.Error(), nil)
+		return
+	}
 
 	// Create user
 	user := models.NewUser(parentDN, uid, cn, sn, mail)
+	if err := directory.CheckWrite(ctx, h.store, h.cfg, requestActor(r), authz.UsersCreate, user.DN); err != nil {
+		auditWebWrite(r, "create", "user", user.DN, statusForError(err), err)
+		h.showError(w, r, err.Error(), nil)
+		return
+	}
 	if givenName != "" {
 		user.SetAttribute("givenName", givenName)
 	}
 
 	// Hash password
-	hashedPassword, err := h.hasher.Hash(password)
+	hashedPasswords, err := h.hasher.HashValues(password)
 	if err != nil {
 		auditWebWrite(r, "create", "user", user.DN, http.StatusInternalServerError, err)
 		h.showError(w, r, "Failed to hash password", nil)
 		return
 	}
-	user.SetPassword(hashedPassword)
+	user.SetPassword(hashedPasswords[0], hashedPasswords[1:]...)
 
 	// Add extra attributes
 	addExtraAttributes(user.Entry, ParseAttributes(r.FormValue("attributes")))
@@ -169,6 +189,11 @@ func (h *UserHandler) update(w http.ResponseWriter, r *http.Request, dn string)
 		h.showError(w, r, fmt.Sprintf("User not found: %v", err), nil)
 		return
 	}
+	if err := directory.CheckWrite(ctx, h.store, h.cfg, requestActor(r), authz.DirectoryWrite, dn); err != nil {
+		auditWebWrite(r, "update", "user", dn, statusForError(err), err)
+		h.showError(w, r, err.Error(), entry)
+		return
+	}
 
 	if err := r.ParseForm(); err != nil {
 		auditWebWrite(r, "update", "user", dn, http.StatusBadRequest, err)
@@ -190,13 +215,22 @@ func (h *UserHandler) update(w http.ResponseWriter, r *http.Request, dn string)
 	// Update password if provided
 	password := r.FormValue("userPassword")
 	if password != "" {
-		hashedPassword, err := h.hasher.Hash(password)
+		if err := h.policy.CheckNewPassword(ctx, entry.DN, password); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, ppolicy.ErrPolicyViolation) {
+				status = http.StatusBadRequest
+			}
+			auditWebWrite(r, "update", "user", dn, status, err)
+			h.showError(w, r, err.Error(), entry)
+			return
+		}
+		hashedPasswords, err := h.hasher.HashValues(password)
 		if err != nil {
 			auditWebWrite(r, "update", "user", dn, http.StatusInternalServerError, err)
 			h.showError(w, r, "Failed to hash password", entry)
 			return
 		}
-		entry.SetAttribute("userPassword", hashedPassword)
+		entry.SetAttributes("userPassword", hashedPasswords)
 	}
 
 	if err := h.store.UpdateEntry(ctx, entry); err != nil {
@@ -210,7 +244,7 @@ func (h *UserHandler) update(w http.ResponseWriter, r *http.Request, dn string)
 }
 
 func (h *UserHandler) Delete(w http.ResponseWriter, r *http.Request) {
-	deleteEntry(w, r, h.store, "/users", "user", "User")
+	deleteEntry(w, r, h.store, h.cfg, "/users", "user", "User")
 }
 
 func (h *UserHandler) showError(w http.ResponseWriter, r *http.Request, errMsg string, user *models.Entry) {
@@ -48,7 +48,7 @@ func AuditHTTP(next http.Handler) http.Handler {
 
 func NormalizeRoute(path string) string {
 	switch path {
-	case "/", "/logout", "/api/session", "/api/directory", "/api/users", "/api/groups", "/api/ous", "/api/account/password", "/api/users/password", "/users", "/users/new", "/users/edit", "/users/delete", "/groups", "/groups/new", "/groups/edit", "/groups/delete", "/ous", "/ous/new", "/ous/edit", "/ous/delete":
+	case "/", "/logout", "/api/session", "/api/directory", "/api/users", "/api/groups", "/api/groups/owned", "/api/groups/members", "/api/ous", "/api/directory/subtree", "/api/access/explain", "/api/account/password", "/api/users/password", "/users", "/users/new", "/users/edit", "/users/delete", "/groups", "/groups/new", "/groups/edit", "/groups/delete", "/ous", "/ous/new", "/ous/edit", "/ous/delete":
 		return path
 	default:
 		if strings.HasPrefix(path, "/static/") {
@@ -1,16 +1,23 @@
 package middleware
 
 import (
+	"cmp"
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"log/slog"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/smarzola/ldaplite/internal/audit"
 	"github.com/smarzola/ldaplite/internal/authz"
+	"github.com/smarzola/ldaplite/internal/ppolicy"
 	"github.com/smarzola/ldaplite/internal/store"
+	"github.com/smarzola/ldaplite/internal/throttle"
 	"github.com/smarzola/ldaplite/pkg/config"
 	"github.com/smarzola/ldaplite/pkg/crypto"
 )
@@ -20,20 +27,35 @@ type contextKey string
 const UserDNKey contextKey = "user_dn"
 const capabilitiesKey contextKey = "capabilities"
 
+// errInvalidCredentials marks sign-in failures caused by the credentials,
+// which count against the bind throttle. Locked accounts fail the same way,
+// so a sign-in does not reveal the lock.
+var errInvalidCredentials = errors.New("invalid credentials")
+
+// errPasswordExpired marks a correct password that has expired with no grace
+// logins left.
+var errPasswordExpired = errors.New("password expired")
+
 // Auth is the authentication middleware that validates HTTP Basic Auth against
 // LDAP credentials and attaches resolved capabilities to the request context.
 type Auth struct {
-	store  store.Store
-	cfg    *config.Config
-	hasher *crypto.PasswordHasher
+	store     store.Store
+	cfg       *config.Config
+	hasher    *crypto.PasswordHasher
+	policy    *ppolicy.Policy
+	throttler *throttle.Throttler
 }
 
-// NewAuth creates a new authentication middleware
-func NewAuth(st store.Store, cfg *config.Config) *Auth {
+// NewAuth creates a new authentication middleware. A nil throttler disables
+// sign-in throttling.
+func NewAuth(st store.Store, cfg *config.Config, throttler *throttle.Throttler) *Auth {
+	hasher := crypto.NewPasswordHasher(cfg.Security.Argon2Config)
 	return &Auth{
-		store:  st,
-		cfg:    cfg,
-		hasher: crypto.NewPasswordHasher(cfg.Security.Argon2Config),
+		store:     st,
+		cfg:       cfg,
+		hasher:    hasher,
+		policy:    ppolicy.New(cfg.Security.PasswordPolicy, st, hasher),
+		throttler: throttler,
 	}
 }
 
@@ -84,8 +106,18 @@ func (a *Auth) RequireCapability(capability authz.Capability, next http.Handler)
 
 		// Authenticate against LDAP
 		ctx := r.Context()
-		userDN, err := a.authenticate(ctx, uid, password)
+		userDN, err := a.authenticate(ctx, uid, password, r.RemoteAddr)
 		if err != nil {
+			status := http.StatusUnauthorized
+			var rejected *throttle.RejectedError
+			switch {
+			case errors.As(err, &rejected):
+				status = http.StatusTooManyRequests
+			case errors.Is(err, crypto.ErrHashPoolBusy):
+				status = http.StatusServiceUnavailable
+			case errors.Is(err, errPasswordExpired):
+				status = http.StatusForbidden
+			}
 			slog.Warn("Authentication failed", "uid", uid, "error", err)
 			audit.LogWeb(ctx, audit.WebEvent{
 				Event:      audit.EventWebAuthFailed,
@@ -93,15 +125,28 @@ func (a *Auth) RequireCapability(capability authz.Capability, next http.Handler)
 				ActorUID:   uid,
 				Method:     r.Method,
 				Route:      NormalizeRoute(r.URL.Path),
-				Status:     http.StatusUnauthorized,
+				Status:     status,
 				Error:      err,
 			})
-			a.requestAuth(w)
+			// The password was not checked when throttled or busy; ask the
+			// client to retry rather than to re-enter credentials.
+			switch status {
+			case http.StatusTooManyRequests:
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(rejected.RetryAfter.Seconds()))))
+				http.Error(w, "Too many failed sign-in attempts", status)
+			case http.StatusServiceUnavailable:
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, "Service busy", status)
+			case http.StatusForbidden:
+				http.Error(w, "Password has expired", status)
+			default:
+				a.requestAuth(w)
+			}
 			return
 		}
 		audit.SetActorDN(ctx, userDN)
 
-		capabilities, err := authz.New(a.cfg.LDAP.BaseDN, a.store).Capabilities(ctx, authz.BoundUser(userDN))
+		capabilities, err := authz.FromConfig(a.cfg, a.store).Capabilities(ctx, authz.BoundUser(userDN))
 		if err != nil {
 			slog.Error("Failed to resolve capabilities", "user_dn", userDN, "error", err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -140,29 +185,73 @@ func (a *Auth) logAuthRequired(r *http.Request, uid string) {
 	})
 }
 
-// authenticate validates credentials against LDAP and returns the user DN
-func (a *Auth) authenticate(ctx context.Context, uid, password string) (string, error) {
+// authenticate validates credentials against LDAP and returns the user DN.
+// Attempts go through the bind throttle shared with LDAP binds.
+func (a *Auth) authenticate(ctx context.Context, uid, password, remoteAddr string) (string, error) {
 	// Get password hash and DN from store
 	passwordHash, userDN, err := a.store.GetUserPasswordHash(ctx, uid)
 	if err != nil {
 		return "", fmt.Errorf("failed to get password hash: %w", err)
 	}
 
+	// Unknown users are throttled by name, so probing them costs the same.
+	attempt := throttle.Attempt{
+		Component:  audit.ComponentWeb,
+		Account:    cmp.Or(userDN, uid),
+		RemoteAddr: remoteAddr,
+	}
+	if err := a.throttler.Wait(ctx, attempt); err != nil {
+		return "", err
+	}
+	if err := a.checkCredentials(ctx, uid, password, passwordHash, userDN); err != nil {
+		if errors.Is(err, errInvalidCredentials) {
+			a.throttler.Failure(ctx, attempt)
+		}
+		return "", err
+	}
+	a.throttler.Success(attempt)
+	return userDN, nil
+}
+
+// checkCredentials verifies the password and account status of a user.
+// The password is checked under the password policy, as for LDAP binds:
+// locked accounts are refused, failures count towards the lockout and
+// expired passwords use up grace logins.
+func (a *Auth) checkCredentials(ctx context.Context, uid, password, passwordHash, userDN string) error {
 	if passwordHash == "" || userDN == "" {
-		return "", fmt.Errorf("user not found: %s", uid)
+		return fmt.Errorf("%w: user not found: %s", errInvalidCredentials, uid)
+	}
+
+	_, err := a.policy.Authenticate(ctx, userDN, func() (bool, error) {
+		valid, err := a.hasher.Verify(password, passwordHash)
+		if errors.Is(err, crypto.ErrHashPoolBusy) {
+			return false, err
+		}
+		return err == nil && valid, nil
+	})
+	var policyErr *ppolicy.Error
+	switch {
+	case errors.Is(err, ppolicy.ErrInvalidCredentials):
+		return errInvalidCredentials
+	case errors.As(err, &policyErr) && policyErr.Code == ppolicy.PasswordExpired:
+		return fmt.Errorf("%w: %w", errPasswordExpired, err)
+	case errors.As(err, &policyErr):
+		return fmt.Errorf("%w: %w", errInvalidCredentials, err)
+	case err != nil:
+		return err
 	}
 
-	// Verify password
-	valid, err := a.hasher.Verify(password, passwordHash)
+	// Disabled and expired accounts cannot sign in
+	entry, err := a.store.GetEntryWithOptions(ctx, userDN, store.EntryOptions{IncludeMemberOf: false})
 	if err != nil {
-		return "", fmt.Errorf("password verification failed: %w", err)
+		return fmt.Errorf("failed to get account status: %w", err)
 	}
-	if !valid {
-		return "", fmt.Errorf("invalid credentials")
+	if entry != nil {
+		if err := entry.AccountStatus().Check(time.Now()); err != nil {
+			return fmt.Errorf("%w: %w", errInvalidCredentials, err)
+		}
 	}
-
-	// Return user DN
-	return userDN, nil
+	return nil
 }
 
 // requestAuth sends a 401 response requesting Basic Auth
@@ -9,10 +9,13 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/smarzola/ldaplite/internal/authz"
 	"github.com/smarzola/ldaplite/internal/models"
+	"github.com/smarzola/ldaplite/internal/ppolicy"
 	"github.com/smarzola/ldaplite/internal/store"
+	"github.com/smarzola/ldaplite/internal/throttle"
 	"github.com/smarzola/ldaplite/pkg/config"
 )
 
@@ -65,7 +68,7 @@ func setupTestAuth(t *testing.T) (*Auth, store.Store) {
 	}
 
 	// Create auth middleware
-	auth := NewAuth(st, cfg)
+	auth := NewAuth(st, cfg, nil)
 
 	return auth, st
 }
@@ -175,6 +178,102 @@ func TestRequireAuthInvalidPassword(t *testing.T) {
 	assertAuditLogNotContains(t, got, "WrongPassword")
 }
 
+func TestRequireAuthThrottlesRepeatedFailures(t *testing.T) {
+	auth, st := setupTestAuth(t)
+	defer st.Close()
+	auth.throttler = throttle.New(config.BindThrottleConfig{AccountFailures: 2, BlockDuration: 60})
+	logs := captureMiddlewareAuditLogs(t)
+
+	handler := auth.RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	request := func(password string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte("admin:"+password)))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for range 2 {
+		if rr := request("WrongPassword"); rr.Code != http.StatusUnauthorized {
+			t.Fatalf("Expected status %d, got %d", http.StatusUnauthorized, rr.Code)
+		}
+	}
+
+	// Once blocked, even the right password is rejected without a check.
+	rr := request("TestPassword123!")
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status %d, got %d", http.StatusTooManyRequests, rr.Code)
+	}
+	if got := rr.Header().Get("Retry-After"); got != "60" {
+		t.Errorf("Retry-After = %q, want 60", got)
+	}
+	got := logs.String()
+	assertAuditLogContains(t, got, `"event":"auth.blocked"`)
+	assertAuditLogContains(t, got, `"event":"auth.throttled"`)
+	assertAuditLogContains(t, got, `"status":429`)
+}
+
+func TestRequireAuthCountsFailuresTowardsLockout(t *testing.T) {
+	auth, st := setupTestAuth(t)
+	defer st.Close()
+	auth.policy = ppolicy.New(config.PasswordPolicyConfig{MaxFailures: 3}, st, auth.hasher)
+	adminDN := "uid=admin,ou=users,dc=test,dc=com"
+
+	handler := auth.RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	request := func(password string) int {
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte("admin:"+password)))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	for range 3 {
+		if code := request("WrongPassword"); code != http.StatusUnauthorized {
+			t.Fatalf("wrong password status = %d, want %d", code, http.StatusUnauthorized)
+		}
+	}
+	state, err := st.GetPasswordPolicyState(context.Background(), adminDN)
+	if err != nil {
+		t.Fatalf("GetPasswordPolicyState failed: %v", err)
+	}
+	if state == nil || state.AccountLockedTime.IsZero() {
+		t.Fatalf("password policy state = %+v, want the account locked", state)
+	}
+	if code := request("TestPassword123!"); code != http.StatusUnauthorized {
+		t.Fatalf("locked account status = %d, want %d", code, http.StatusUnauthorized)
+	}
+}
+
+func TestRequireAuthRejectsLockedAccounts(t *testing.T) {
+	auth, st := setupTestAuth(t)
+	defer st.Close()
+	auth.policy = ppolicy.New(config.PasswordPolicyConfig{MaxFailures: 1}, st, auth.hasher)
+	adminDN := "uid=admin,ou=users,dc=test,dc=com"
+
+	if _, err := st.RecordPasswordFailure(context.Background(), adminDN, time.Now(), time.Time{}, 1); err != nil {
+		t.Fatalf("RecordPasswordFailure failed: %v", err)
+	}
+
+	called := false
+	handler := auth.RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		w.WriteHeader(http.StatusOK)
+	}))
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte("admin:TestPassword123!")))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnauthorized || called {
+		t.Fatalf("locked account status = %d, called = %v; want %d without calling the handler", rr.Code, called, http.StatusUnauthorized)
+	}
+}
+
 func TestRequireAuthNonExistentUser(t *testing.T) {
 	auth, st := setupTestAuth(t)
 	defer st.Close()
@@ -204,6 +303,43 @@ func TestRequireAuthNonExistentUser(t *testing.T) {
 	}
 }
 
+func TestRequireAuthRejectsInactiveAccounts(t *testing.T) {
+	auth, st := setupTestAuth(t)
+	defer st.Close()
+	ctx := context.Background()
+	adminDN := "uid=admin,ou=users,dc=test,dc=com"
+
+	handler := auth.RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	request := func() int {
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte("admin:TestPassword123!")))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	for _, status := range []models.AccountStatus{
+		{Disabled: true},
+		{ExpirationTime: time.Now().Add(-time.Minute)},
+	} {
+		if err := st.SetAccountStatus(ctx, adminDN, status); err != nil {
+			t.Fatalf("SetAccountStatus failed: %v", err)
+		}
+		if code := request(); code != http.StatusUnauthorized {
+			t.Fatalf("status with account %+v = %d, want %d", status, code, http.StatusUnauthorized)
+		}
+	}
+
+	if err := st.SetAccountStatus(ctx, adminDN, models.AccountStatus{ExpirationTime: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatalf("SetAccountStatus failed: %v", err)
+	}
+	if code := request(); code != http.StatusOK {
+		t.Fatalf("status before expiry = %d, want %d", code, http.StatusOK)
+	}
+}
+
 func TestRequireAuthAllowsAuthenticatedNonAdminRead(t *testing.T) {
 	auth, st := setupTestAuth(t)
 	defer st.Close()
@@ -8,12 +8,14 @@ import (
 	"io/fs"
 	"log/slog"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/smarzola/ldaplite/internal/authz"
 	"github.com/smarzola/ldaplite/internal/scim"
 	"github.com/smarzola/ldaplite/internal/store"
+	"github.com/smarzola/ldaplite/internal/throttle"
 	"github.com/smarzola/ldaplite/internal/web/handlers"
 	"github.com/smarzola/ldaplite/internal/web/middleware"
 	"github.com/smarzola/ldaplite/pkg/config"
@@ -29,16 +31,19 @@ var staticFS embed.FS
 type Server struct {
 	cfg        *config.Config
 	store      store.Store
+	throttler  *throttle.Throttler
 	templates  *template.Template
 	mux        *http.ServeMux
 	httpServer *http.Server
 }
 
-// NewServer creates a new web UI server
-func NewServer(cfg *config.Config, st store.Store) (*Server, error) {
+// NewServer creates a new web UI server. Basic auth sign-ins go through
+// throttler, which may be nil.
+func NewServer(cfg *config.Config, st store.Store, throttler *throttle.Throttler) (*Server, error) {
 	s := &Server{
 		cfg:       cfg,
 		store:     st,
+		throttler: throttler,
 		templates: nil, // Templates are parsed per-request to avoid block name conflicts
 		mux:       http.NewServeMux(),
 	}
@@ -64,7 +69,7 @@ func (s *Server) GetTemplate(name string) (*template.Template, error) {
 // setupRoutes configures all HTTP routes
 func (s *Server) setupRoutes() {
 	// Create auth middleware
-	auth := middleware.NewAuth(s.store, s.cfg)
+	auth := middleware.NewAuth(s.store, s.cfg, s.throttler)
 
 	// Create handlers
 	userHandler := handlers.NewUserHandler(s.store, s.cfg, s.GetTemplate)
@@ -84,6 +89,19 @@ func (s *Server) setupRoutes() {
 	passwordResetProtected := func(handler http.HandlerFunc) http.Handler {
 		return auth.RequireCapability(authz.PasswordResetAny, middleware.RequireSameOrigin(handler))
 	}
+	// Creating users has its own capability, so provisioning roles do not
+	// need the rest of the admin UI.
+	usersProtected := func(handler http.HandlerFunc) http.Handler {
+		create := auth.RequireCapability(authz.UsersCreate, middleware.RequireSameOrigin(handler))
+		admin := adminProtected(handler)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodPost {
+				create.ServeHTTP(w, r)
+				return
+			}
+			admin.ServeHTTP(w, r)
+		})
+	}
 
 	// Serve static CSS (no auth required)
 	s.mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.FS(mustSubFS(staticFS, "static")))))
@@ -116,9 +134,15 @@ func (s *Server) setupRoutes() {
 	s.mux.Handle("/api/directory/search", auth.RequireCapability(authz.DirectoryRead, http.HandlerFunc(apiHandler.DirectorySearch)))
 	s.mux.Handle("/api/directory/entry", auth.RequireCapability(authz.DirectoryRead, http.HandlerFunc(apiHandler.DirectoryEntry)))
 	s.mux.Handle("/api/directory", auth.RequireCapability(authz.DirectoryRead, http.HandlerFunc(apiHandler.Directory)))
-	s.mux.Handle("/api/users", adminProtected(apiHandler.Users))
+	s.mux.Handle("/api/users", usersProtected(apiHandler.Users))
 	s.mux.Handle("/api/groups", adminProtected(apiHandler.Groups))
+	// Group owners need neither ui.admin nor directory.write; the handlers
+	// check ownership.
+	s.mux.Handle("/api/groups/owned", readProtected(apiHandler.OwnedGroups))
+	s.mux.Handle("/api/groups/members", auth.RequireCapability(authz.UIRead, middleware.RequireSameOrigin(http.HandlerFunc(apiHandler.GroupMembers))))
 	s.mux.Handle("/api/ous", adminProtected(apiHandler.OUs))
+	s.mux.Handle("/api/directory/subtree", adminProtected(apiHandler.Subtree))
+	s.mux.Handle("/api/access/explain", adminProtected(apiHandler.AccessExplain))
 	s.mux.Handle("/api/account/password", passwordSelfProtected(apiHandler.ChangeOwnPassword))
 	s.mux.Handle("/api/users/password", passwordResetProtected(apiHandler.ResetPassword))
 
@@ -126,10 +150,12 @@ func (s *Server) setupRoutes() {
 	s.mux.Handle("/scim/v2/ServiceProviderConfig", auth.RequireCapability(authz.DirectoryRead, http.HandlerFunc(scimHandler.ServiceProviderConfig)))
 	s.mux.Handle("/scim/v2/Schemas", auth.RequireCapability(authz.DirectoryRead, http.HandlerFunc(scimHandler.Schemas)))
 	s.mux.Handle("/scim/v2/ResourceTypes", auth.RequireCapability(authz.DirectoryRead, http.HandlerFunc(scimHandler.ResourceTypes)))
-	scimUsers := methodCapabilityHandler(auth, authz.DirectoryRead, authz.DirectoryWrite, http.HandlerFunc(scimHandler.Users))
+	scimUsers := methodCapabilityHandler(auth, authz.DirectoryRead, authz.SCIMWrite, http.HandlerFunc(scimHandler.Users))
 	s.mux.Handle("/scim/v2/Users", scimUsers)
 	s.mux.Handle("/scim/v2/Users/", scimUsers)
-	scimGroups := methodCapabilityHandler(auth, authz.DirectoryRead, authz.DirectoryWrite, http.HandlerFunc(scimHandler.Groups))
+	// PATCH only changes members, which group owners may do without
+	// scim.write; the handler checks ownership.
+	scimGroups := methodCapabilityHandler(auth, authz.DirectoryRead, authz.SCIMWrite, http.HandlerFunc(scimHandler.Groups), http.MethodPatch)
 	s.mux.Handle("/scim/v2/Groups", scimGroups)
 	s.mux.Handle("/scim/v2/Groups/", scimGroups)
 
@@ -152,9 +178,11 @@ func (s *Server) setupRoutes() {
 	s.mux.Handle("/ous/delete", adminProtected(ouHandler.Delete))
 }
 
-func methodCapabilityHandler(auth *middleware.Auth, readCapability, writeCapability authz.Capability, handler http.Handler) http.Handler {
+// methodCapabilityHandler requires readCapability for GET and readMethods,
+// and writeCapability for every other method.
+func methodCapabilityHandler(auth *middleware.Auth, readCapability, writeCapability authz.Capability, handler http.Handler, readMethods ...string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodGet {
+		if r.Method == http.MethodGet || slices.Contains(readMethods, r.Method) {
 			auth.RequireCapability(readCapability, handler).ServeHTTP(w, r)
 			return
 		}
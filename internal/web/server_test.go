@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
@@ -12,6 +13,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/smarzola/ldaplite/internal/models"
@@ -882,6 +884,19 @@ func TestPasswordAPIsAndDeniedDirectWrites(t *testing.T) {
 	}
 	assertPasswordValid(t, st, "regularuser", "ChangedPassword123!")
 
+	hashedChange := apiJSONRequest(t, http.MethodPost, "/api/account/password", "regularuser:ChangedPassword123!", map[string]any{
+		"password": "{ARGON2ID}$argon2id$v=19$m=65536,t=3,p=2$c2FsdA$aGFzaA",
+	})
+	hashedChange.Header.Set("Origin", "http://ldaplite.test")
+	hashedChangeRR := httptest.NewRecorder()
+
+	srv.mux.ServeHTTP(hashedChangeRR, hashedChange)
+
+	if hashedChangeRR.Code != http.StatusBadRequest {
+		t.Fatalf("pre-hashed self password status = %d, want %d; body=%s", hashedChangeRR.Code, http.StatusBadRequest, hashedChangeRR.Body.String())
+	}
+	assertPasswordValid(t, st, "regularuser", "ChangedPassword123!")
+
 	deniedReset := apiJSONRequest(t, http.MethodPost, "/api/users/password", "regularuser:ChangedPassword123!", map[string]any{
 		"dn":       "uid=targetuser,ou=users,dc=test,dc=com",
 		"password": "HackedPassword123!",
@@ -927,6 +942,394 @@ func TestPasswordAPIsAndDeniedDirectWrites(t *testing.T) {
 	}
 }
 
+func TestDelegatedAdminWritesOnlyInsideSubtree(t *testing.T) {
+	srv, st := setupTestServer(t)
+	defer st.Close()
+
+	srv.cfg.Authz.DelegatedAdmins = []config.DelegatedAdmin{{
+		GroupDN:  "cn=emea-admins,ou=groups,dc=test,dc=com",
+		Subtrees: []string{"ou=emea,dc=test,dc=com"},
+	}}
+	createTestOU(t, st, "emea", "")
+	createTestUser(t, st, "lead", "LeadPassword123!")
+	createTestGroup(t, st, "emea-admins", "uid=lead,ou=users,dc=test,dc=com")
+
+	sessionReq := httptest.NewRequest(http.MethodGet, "http://ldaplite.test/api/session", nil)
+	sessionReq.Header.Set("Authorization", basicAuth("lead:LeadPassword123!"))
+	sessionRR := httptest.NewRecorder()
+	srv.mux.ServeHTTP(sessionRR, sessionReq)
+	var session struct {
+		AdminScopes []string `json:"adminScopes"`
+	}
+	if err := json.Unmarshal(sessionRR.Body.Bytes(), &session); err != nil {
+		t.Fatalf("failed to decode session response: %v", err)
+	}
+	if len(session.AdminScopes) != 1 || session.AdminScopes[0] != "ou=emea,dc=test,dc=com" {
+		t.Fatalf("adminScopes = %v, want the EMEA subtree", session.AdminScopes)
+	}
+
+	for _, tt := range []struct {
+		parentDN string
+		want     int
+	}{
+		{parentDN: "ou=emea,dc=test,dc=com", want: http.StatusCreated},
+		{parentDN: "ou=users,dc=test,dc=com", want: http.StatusForbidden},
+	} {
+		req := apiJSONRequest(t, http.MethodPost, "/api/users", "lead:LeadPassword123!", map[string]any{
+			"parentDN": tt.parentDN,
+			"uid":      "newhire",
+			"cn":       "New Hire",
+			"sn":       "Hire",
+			"password": "NewHirePassword123!",
+		})
+		req.Header.Set("Origin", "http://ldaplite.test")
+		rr := httptest.NewRecorder()
+
+		srv.mux.ServeHTTP(rr, req)
+
+		if rr.Code != tt.want {
+			t.Fatalf("create under %s status = %d, want %d; body=%s", tt.parentDN, rr.Code, tt.want, rr.Body.String())
+		}
+	}
+}
+
+func TestUsersCreateRoleCanOnlyCreateUsers(t *testing.T) {
+	srv, st := setupTestServer(t)
+	defer st.Close()
+
+	srv.cfg.Authz.Roles = []config.Role{{
+		Name:         "onboarding",
+		Capabilities: []string{"users.create"},
+		Groups:       []string{"cn=onboarding,ou=groups,dc=test,dc=com"},
+	}}
+	createTestUser(t, st, "hr", "HRPassword123!")
+	createTestGroup(t, st, "onboarding", "uid=hr,ou=users,dc=test,dc=com")
+
+	for _, tt := range []struct {
+		method string
+		path   string
+		body   map[string]any
+		want   int
+	}{
+		{
+			method: http.MethodPost,
+			path:   "/api/users",
+			body: map[string]any{
+				"parentDN": "ou=users,dc=test,dc=com",
+				"uid":      "newhire",
+				"cn":       "New Hire",
+				"sn":       "Hire",
+				"password": "NewHirePassword123!",
+			},
+			want: http.StatusCreated,
+		},
+		{method: http.MethodDelete, path: "/api/users?dn=uid%3Dnewhire%2Cou%3Dusers%2Cdc%3Dtest%2Cdc%3Dcom", want: http.StatusForbidden},
+		{method: http.MethodPost, path: "/api/ous", body: map[string]any{"parentDN": "dc=test,dc=com", "ou": "hr"}, want: http.StatusForbidden},
+	} {
+		req := apiJSONRequest(t, tt.method, tt.path, "hr:HRPassword123!", tt.body)
+		req.Header.Set("Origin", "http://ldaplite.test")
+		rr := httptest.NewRecorder()
+
+		srv.mux.ServeHTTP(rr, req)
+
+		if rr.Code != tt.want {
+			t.Fatalf("%s %s status = %d, want %d; body=%s", tt.method, tt.path, rr.Code, tt.want, rr.Body.String())
+		}
+	}
+}
+
+func TestAccessExplainNamesDecidingRule(t *testing.T) {
+	srv, st := setupTestServer(t)
+	defer st.Close()
+
+	srv.cfg.Authz.AccessRules = []config.AccessRule{
+		{Name: "private-mail", Effect: "deny", Subject: "users", Attributes: []string{"mail"}, Permissions: []string{"read"}},
+	}
+	createTestUser(t, st, "sync", "SyncPassword123!")
+	createTestUser(t, st, "jane", "JanePassword123!")
+
+	query := url.Values{
+		"subject":   {"uid=sync,ou=users,dc=test,dc=com"},
+		"dn":        {"uid=jane,ou=users,dc=test,dc=com"},
+		"attribute": {"mail"},
+	}
+	req := httptest.NewRequest(http.MethodGet, "http://ldaplite.test/api/access/explain?"+query.Encode(), nil)
+	req.Header.Set("Authorization", basicAuth("admin:TestPassword123!"))
+	rr := httptest.NewRecorder()
+
+	srv.mux.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d; body=%s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	var got struct {
+		Entry []struct {
+			Permission string `json:"permission"`
+			Allowed    bool   `json:"allowed"`
+		} `json:"entry"`
+		Attributes []struct {
+			Name      string `json:"name"`
+			Decisions []struct {
+				Permission string `json:"permission"`
+				Allowed    bool   `json:"allowed"`
+				Reason     string `json:"reason"`
+				Rule       string `json:"rule"`
+			} `json:"decisions"`
+		} `json:"attributes"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("failed to decode explain response: %v", err)
+	}
+	if len(got.Entry) != 6 {
+		t.Fatalf("entry decisions = %+v, want one per permission", got.Entry)
+	}
+	if len(got.Attributes) != 1 || got.Attributes[0].Name != "mail" {
+		t.Fatalf("attributes = %+v, want only mail", got.Attributes)
+	}
+	read := got.Attributes[0].Decisions[0]
+	if read.Permission != "read" || read.Allowed || read.Reason != "rule" || read.Rule != "private-mail" {
+		t.Fatalf("mail read decision = %+v, want denied by private-mail", read)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "http://ldaplite.test/api/access/explain?"+query.Encode(), nil)
+	req.Header.Set("Authorization", basicAuth("sync:SyncPassword123!"))
+	rr = httptest.NewRecorder()
+	srv.mux.ServeHTTP(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("non-admin status = %d, want %d", rr.Code, http.StatusForbidden)
+	}
+}
+
+func TestAccessExplainStaysInsideDelegatedSubtree(t *testing.T) {
+	srv, st := setupTestServer(t)
+	defer st.Close()
+
+	srv.cfg.Authz.DelegatedAdmins = []config.DelegatedAdmin{{
+		GroupDN:  "cn=emea-admins,ou=groups,dc=test,dc=com",
+		Subtrees: []string{"ou=emea,dc=test,dc=com"},
+	}}
+	createTestOU(t, st, "emea", "")
+	createTestUser(t, st, "lead", "LeadPassword123!")
+	createTestUser(t, st, "jane", "JanePassword123!")
+	createTestGroup(t, st, "emea-admins", "uid=lead,ou=users,dc=test,dc=com")
+
+	for _, tt := range []struct {
+		dn   string
+		want int
+	}{
+		{dn: "ou=emea,dc=test,dc=com", want: http.StatusOK},
+		{dn: "uid=jane,ou=users,dc=test,dc=com", want: http.StatusNotFound},
+		{dn: "cn=admins,ou=groups,dc=test,dc=com", want: http.StatusNotFound},
+	} {
+		query := url.Values{"subject": {"uid=jane,ou=users,dc=test,dc=com"}, "dn": {tt.dn}}
+		req := httptest.NewRequest(http.MethodGet, "http://ldaplite.test/api/access/explain?"+query.Encode(), nil)
+		req.Header.Set("Authorization", basicAuth("lead:LeadPassword123!"))
+		rr := httptest.NewRecorder()
+
+		srv.mux.ServeHTTP(rr, req)
+
+		if rr.Code != tt.want {
+			t.Fatalf("explain %s status = %d, want %d; body=%s", tt.dn, rr.Code, tt.want, rr.Body.String())
+		}
+	}
+}
+
+func TestSubtreeDeletePreviewsAndDeletesForAdminsOnly(t *testing.T) {
+	srv, st := setupTestServer(t)
+	defer st.Close()
+
+	srv.cfg.Authz.DelegatedAdmins = []config.DelegatedAdmin{{
+		GroupDN:  "cn=emea-admins,ou=groups,dc=test,dc=com",
+		Subtrees: []string{"ou=emea,dc=test,dc=com"},
+	}}
+	createTestOU(t, st, "emea", "")
+	eve := models.NewUser("ou=emea,dc=test,dc=com", "eve", "Eve Adams", "Adams", "eve@test.com")
+	if err := st.CreateEntry(context.Background(), eve.Entry); err != nil {
+		t.Fatalf("CreateEntry(eve) failed: %v", err)
+	}
+	createTestUser(t, st, "lead", "LeadPassword123!")
+	createTestGroup(t, st, "emea-admins", "uid=lead,ou=users,dc=test,dc=com", eve.DN)
+
+	subtreeRequest := func(method, credentials string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "http://ldaplite.test/api/directory/subtree?dn=ou%3Demea%2Cdc%3Dtest%2Cdc%3Dcom", nil)
+		req.Header.Set("Authorization", basicAuth(credentials))
+		req.Header.Set("Origin", "http://ldaplite.test")
+		rr := httptest.NewRecorder()
+		srv.mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Delegated administrators may delete entries in their subtree one by
+	// one, but not the subtree itself.
+	if rr := subtreeRequest(http.MethodGet, "lead:LeadPassword123!"); rr.Code != http.StatusForbidden {
+		t.Fatalf("delegated admin preview status = %d, want %d; body=%s", rr.Code, http.StatusForbidden, rr.Body.String())
+	}
+
+	rr := subtreeRequest(http.MethodGet, "admin:TestPassword123!")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("preview status = %d, want %d; body=%s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	var preview struct {
+		Deleted bool     `json:"deleted"`
+		Entries []string `json:"entries"`
+		Groups  []string `json:"groups"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &preview); err != nil {
+		t.Fatalf("failed to decode preview response: %v", err)
+	}
+	if preview.Deleted || len(preview.Entries) != 2 || preview.Entries[0] != eve.DN ||
+		len(preview.Groups) != 1 || preview.Groups[0] != "cn=emea-admins,ou=groups,dc=test,dc=com" {
+		t.Fatalf("preview = %+v, want eve before the OU and emea-admins losing a member", preview)
+	}
+
+	deleteOU := apiJSONRequest(t, http.MethodDelete, "/api/ous?dn=ou%3Demea%2Cdc%3Dtest%2Cdc%3Dcom", "admin:TestPassword123!", nil)
+	deleteOU.Header.Set("Origin", "http://ldaplite.test")
+	deleteOURR := httptest.NewRecorder()
+	srv.mux.ServeHTTP(deleteOURR, deleteOU)
+	if deleteOURR.Code != http.StatusConflict {
+		t.Fatalf("delete non-leaf OU status = %d, want %d; body=%s", deleteOURR.Code, http.StatusConflict, deleteOURR.Body.String())
+	}
+
+	if rr := subtreeRequest(http.MethodDelete, "admin:TestPassword123!"); rr.Code != http.StatusOK {
+		t.Fatalf("delete status = %d, want %d; body=%s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	for _, dn := range []string{"ou=emea,dc=test,dc=com", eve.DN} {
+		if exists, err := st.EntryExists(context.Background(), dn); err != nil || exists {
+			t.Fatalf("EntryExists(%s) = %v, %v; want it deleted", dn, exists, err)
+		}
+	}
+	group, err := st.GetEntry(context.Background(), "cn=emea-admins,ou=groups,dc=test,dc=com")
+	if err != nil || group == nil {
+		t.Fatalf("GetEntry(emea-admins) = %v, %v", group, err)
+	}
+	if members := group.GetAttributes("member"); len(members) != 1 || members[0] != "uid=lead,ou=users,dc=test,dc=com" {
+		t.Fatalf("emea-admins members = %v, want lead only", members)
+	}
+}
+
+func TestGroupOwnerManagesMembersThroughAPI(t *testing.T) {
+	srv, st := setupTestServer(t)
+	defer st.Close()
+
+	createTestUser(t, st, "lead", "LeadPassword123!")
+	createTestUser(t, st, "jane", "JanePassword123!")
+	createTestGroup(t, st, "team", "uid=jane,ou=users,dc=test,dc=com")
+	team, err := st.GetEntry(context.Background(), "cn=team,ou=groups,dc=test,dc=com")
+	if err != nil {
+		t.Fatalf("GetEntry(team) failed: %v", err)
+	}
+	team.SetAttribute("owner", "uid=lead,ou=users,dc=test,dc=com")
+	if err := st.UpdateEntry(context.Background(), team); err != nil {
+		t.Fatalf("UpdateEntry(team) failed: %v", err)
+	}
+
+	ownedReq := httptest.NewRequest(http.MethodGet, "http://ldaplite.test/api/groups/owned", nil)
+	ownedReq.Header.Set("Authorization", basicAuth("lead:LeadPassword123!"))
+	ownedRR := httptest.NewRecorder()
+	srv.mux.ServeHTTP(ownedRR, ownedReq)
+	var owned struct {
+		Groups []struct {
+			DN string `json:"dn"`
+		} `json:"groups"`
+	}
+	if err := json.Unmarshal(ownedRR.Body.Bytes(), &owned); err != nil {
+		t.Fatalf("failed to decode owned groups: %v; body=%s", err, ownedRR.Body.String())
+	}
+	if len(owned.Groups) != 1 || owned.Groups[0].DN != team.DN {
+		t.Fatalf("owned groups = %+v, want only %s", owned.Groups, team.DN)
+	}
+
+	for _, tt := range []struct {
+		credentials string
+		method      string
+		path        string
+		body        map[string]any
+		want        int
+	}{
+		{
+			credentials: "lead:LeadPassword123!",
+			method:      http.MethodPatch,
+			path:        "/api/groups/members?dn=cn%3Dteam%2Cou%3Dgroups%2Cdc%3Dtest%2Cdc%3Dcom",
+			body:        map[string]any{"add": []string{"uid=lead,ou=users,dc=test,dc=com"}, "remove": []string{"uid=jane,ou=users,dc=test,dc=com"}},
+			want:        http.StatusOK,
+		},
+		{
+			credentials: "jane:JanePassword123!",
+			method:      http.MethodPatch,
+			path:        "/api/groups/members?dn=cn%3Dteam%2Cou%3Dgroups%2Cdc%3Dtest%2Cdc%3Dcom",
+			body:        map[string]any{"add": []string{"uid=jane,ou=users,dc=test,dc=com"}},
+			want:        http.StatusForbidden,
+		},
+		{
+			credentials: "lead:LeadPassword123!",
+			method:      http.MethodPut,
+			path:        "/api/groups?dn=cn%3Dteam%2Cou%3Dgroups%2Cdc%3Dtest%2Cdc%3Dcom",
+			body:        map[string]any{"description": "Renamed", "members": []string{"uid=lead,ou=users,dc=test,dc=com"}},
+			want:        http.StatusForbidden,
+		},
+	} {
+		req := apiJSONRequest(t, tt.method, tt.path, tt.credentials, tt.body)
+		req.Header.Set("Origin", "http://ldaplite.test")
+		rr := httptest.NewRecorder()
+
+		srv.mux.ServeHTTP(rr, req)
+
+		if rr.Code != tt.want {
+			t.Fatalf("%s %s as %s status = %d, want %d; body=%s", tt.method, tt.path, tt.credentials, rr.Code, tt.want, rr.Body.String())
+		}
+	}
+
+	updated, err := st.GetEntry(context.Background(), team.DN)
+	if err != nil {
+		t.Fatalf("GetEntry(team) failed: %v", err)
+	}
+	if members := updated.GetAttributes("member"); len(members) != 1 || members[0] != "uid=lead,ou=users,dc=test,dc=com" {
+		t.Fatalf("members = %v, want only the owner", members)
+	}
+}
+
+func TestConcurrentMemberChangesKeepEveryMember(t *testing.T) {
+	srv, st := setupTestServer(t)
+	defer st.Close()
+
+	createTestUser(t, st, "jane", "JanePassword123!")
+	createTestGroup(t, st, "team", "uid=jane,ou=users,dc=test,dc=com")
+	const joiners = 6
+	for i := range joiners {
+		createTestUser(t, st, fmt.Sprintf("joiner%d", i), "JoinerPassword123!")
+	}
+
+	var wg sync.WaitGroup
+	codes := make(chan int, joiners)
+	for i := range joiners {
+		member := fmt.Sprintf("uid=joiner%d,ou=users,dc=test,dc=com", i)
+		req := apiJSONRequest(t, http.MethodPatch, "/api/groups/members?dn=cn%3Dteam%2Cou%3Dgroups%2Cdc%3Dtest%2Cdc%3Dcom", "admin:TestPassword123!", map[string]any{"add": []string{member}})
+		req.Header.Set("Origin", "http://ldaplite.test")
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rr := httptest.NewRecorder()
+			srv.mux.ServeHTTP(rr, req)
+			codes <- rr.Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+	for code := range codes {
+		if code != http.StatusOK {
+			t.Fatalf("add member status = %d, want %d", code, http.StatusOK)
+		}
+	}
+
+	team, err := st.GetEntry(context.Background(), "cn=team,ou=groups,dc=test,dc=com")
+	if err != nil {
+		t.Fatalf("GetEntry(team) failed: %v", err)
+	}
+	if members := team.GetAttributes("member"); len(members) != joiners+1 {
+		t.Fatalf("members = %v, want jane and all %d joiners", members, joiners)
+	}
+}
+
 func TestWriteAPIRejectsProtectedAttributesAndDoesNotExposePasswords(t *testing.T) {
 	srv, st := setupTestServer(t)
 	defer st.Close()
@@ -1008,7 +1411,7 @@ func setupTestServer(t *testing.T) (*Server, store.Store) {
 		t.Fatalf("Initialize() failed: %v", err)
 	}
 
-	srv, err := NewServer(cfg, st)
+	srv, err := NewServer(cfg, st, nil)
 	if err != nil {
 		st.Close()
 		t.Fatalf("NewServer() failed: %v", err)
@@ -1,6 +1,8 @@
 package config
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"log/slog"
 	"os"
@@ -14,6 +16,8 @@ type Config struct {
 	Database  DatabaseConfig
 	Logging   LoggingConfig
 	Security  SecurityConfig
+	Limits    LimitsConfig
+	Authz     AuthzConfig
 	WebUI     WebUIConfig
 	Telemetry TelemetryConfig
 }
@@ -23,16 +27,40 @@ type ServerConfig struct {
 	BindAddress  string
 	ReadTimeout  int // seconds
 	WriteTimeout int // seconds
-	TLS          TLSConfig
+	IdleTimeout  int // seconds
+	// MaxMessageSize caps the size of a single LDAP request in bytes.
+	MaxMessageSize int
+	// MaxConnections and MaxConnectionsPerIP cap concurrent LDAP
+	// connections in total and per client IP address. Zero means no cap.
+	MaxConnections      int
+	MaxConnectionsPerIP int
+	TLS                 TLSConfig
 }
 
+// MaxMessageSizeLimit is the largest LDAP_MAX_MESSAGE_SIZE accepted. It
+// matches the BER decoder's own packet size limit.
+const MaxMessageSizeLimit = 16 << 20
+
 type TLSConfig struct {
 	Enabled         bool
 	StartTLSEnabled bool
 	CertFile        string
 	KeyFile         string
+	// ClientCAFile is a PEM bundle of CAs trusted to issue client
+	// certificates. ClientAuth says whether clients must present one.
+	ClientCAFile string
+	ClientAuth   string
 }
 
+// TLS client certificate modes for LDAP_TLS_CLIENT_AUTH. verify checks a
+// certificate when the client presents one; require rejects handshakes
+// without one.
+const (
+	TLSClientAuthNone    = "none"
+	TLSClientAuthVerify  = "verify"
+	TLSClientAuthRequire = "require"
+)
+
 type LDAPConfig struct {
 	BaseDN string
 }
@@ -53,6 +81,128 @@ type SecurityConfig struct {
 	PasswordAlgorithm  string // argon2id
 	AllowAnonymousBind bool   // allow anonymous binds (default: false)
 	Argon2Config       Argon2Config
+	// AllowLegacyPasswordHashes lets LDAP writes and LDIF import store
+	// pre-hashed passwords in legacy schemes such as {SSHA} and {CRYPT}.
+	// They are rehashed to Argon2id on the next successful bind.
+	AllowLegacyPasswordHashes bool
+	// PasswordHashWorkers caps concurrent Argon2id computations across LDAP
+	// binds, the Web UI and SCIM; each one allocates Argon2Config.Memory.
+	// Zero removes the cap.
+	PasswordHashWorkers int
+	// PasswordHashQueueTimeout is how long, in seconds, a password check waits
+	// for a free worker before failing as busy.
+	PasswordHashQueueTimeout int
+	// SASLExternalMapping is the DN template that maps a verified client
+	// certificate to a directory entry for SASL EXTERNAL binds.
+	SASLExternalMapping string
+	PasswordPolicy      PasswordPolicyConfig
+	BindThrottle        BindThrottleConfig
+}
+
+// PasswordPolicyConfig is the password policy applied to every user
+// (modeled on draft-behera-ldap-password-policy). Zero disables a rule.
+type PasswordPolicyConfig struct {
+	MinLength      int // characters
+	MinCharClasses int // of lowercase, uppercase, digits and other characters
+	HistoryCount   int // previous passwords that cannot be reused
+	MaxAge         int // seconds before a password expires
+	ExpireWarning  int // seconds before expiry that binds start warning
+	GraceLogins    int // binds allowed with an expired password
+	MaxFailures    int // consecutive bind failures that lock the account
+	// LockoutDuration is how long, in seconds, a locked account stays
+	// locked. Zero keeps it locked until an administrator resets the
+	// password.
+	LockoutDuration int
+	// FailureCountInterval forgets failures older than this many seconds.
+	// Zero keeps them until the next successful bind.
+	FailureCountInterval int
+}
+
+// BindThrottleConfig slows down and then temporarily rejects repeated failed
+// authentications, counted per account and per client address across LDAP
+// binds, the Web UI and SCIM. Zero disables a threshold.
+type BindThrottleConfig struct {
+	AccountFailures int // failures per account before it is blocked
+	AddressFailures int // failures per client address before it is blocked
+	// Delay is the wait, in milliseconds, before checking credentials after
+	// one recent failure. It doubles with every further failure up to
+	// MaxDelay.
+	Delay         int
+	MaxDelay      int // milliseconds
+	BlockDuration int // seconds a blocked account or address is rejected
+	// FailureWindow forgets failures after this many seconds without a new
+	// one.
+	FailureWindow int
+	// StateFile, when set, keeps the counters across restarts: it is read at
+	// startup and written at shutdown.
+	StateFile string
+}
+
+// DefaultSASLExternalMapping maps a client certificate's common name to a
+// user entry. Templates may use {cn}, {email}, {dns}, {subject} and {base}.
+const DefaultSASLExternalMapping = "uid={cn},ou=users,{base}"
+
+// LimitsConfig holds administrative search limits. They cap the sizeLimit and
+// timeLimit requested by clients bound as anything other than an admin. Zero
+// means unlimited.
+type LimitsConfig struct {
+	SearchSizeLimit int // entries
+	SearchTimeLimit int // seconds
+	IdentityLimits  []IdentityLimits
+}
+
+// IdentityLimits overrides the administrative search limits for one bound DN.
+type IdentityLimits struct {
+	DN              string
+	SearchSizeLimit int
+	SearchTimeLimit int
+}
+
+// AuthzConfig holds the roles, access rules and delegated administrators
+// that refine the built-in capability groups (see docs/authorization.md).
+type AuthzConfig struct {
+	// AccessRulesFile is a JSON file of access rules, read at startup.
+	AccessRulesFile string
+	AccessRules     []AccessRule
+	DelegatedAdmins []DelegatedAdmin
+	// RolesFile is a JSON file of roles, read at startup.
+	RolesFile string
+	Roles     []Role
+}
+
+// Role grants capabilities to the members of any of its groups, directly or
+// through nested groups.
+type Role struct {
+	Name         string   `json:"name"`
+	Capabilities []string `json:"capabilities"`
+	Groups       []string `json:"groups"`
+	// Subtrees limits the capabilities that write to the entries in these
+	// subtrees. Empty means the whole directory.
+	Subtrees []string `json:"subtrees,omitempty"`
+}
+
+// DelegatedAdmin lets the members of a group administer the entries in some
+// subtrees without belonging to the admin group.
+type DelegatedAdmin struct {
+	GroupDN  string
+	Subtrees []string
+}
+
+// AccessRule allows or denies permissions on part of the directory to a
+// subject. Rules are checked in order and the first one that matches an
+// access check decides it.
+type AccessRule struct {
+	Name   string `json:"name"`
+	Effect string `json:"effect"` // allow or deny
+	// Subject is "dn:<dn>", "group:<dn>", "self", "users" for any
+	// authenticated user, or "anyone".
+	Subject string `json:"subject"`
+	// Subtree, Filter and Attributes narrow the entries and attributes the
+	// rule covers. Empty values cover everything.
+	Subtree     string   `json:"subtree,omitempty"`
+	Filter      string   `json:"filter,omitempty"`
+	Attributes  []string `json:"attributes,omitempty"`
+	Permissions []string `json:"permissions"`
 }
 
 type Argon2Config struct {
@@ -82,15 +232,21 @@ type TelemetryConfig struct {
 func LoadFromEnv() (*Config, error) {
 	cfg := &Config{
 		Server: ServerConfig{
-			Port:         getEnvInt("LDAP_PORT", 3389),
-			BindAddress:  getEnvString("LDAP_BIND_ADDRESS", "0.0.0.0"),
-			ReadTimeout:  getEnvInt("LDAP_READ_TIMEOUT", 30),
-			WriteTimeout: getEnvInt("LDAP_WRITE_TIMEOUT", 30),
+			Port:                getEnvInt("LDAP_PORT", 3389),
+			BindAddress:         getEnvString("LDAP_BIND_ADDRESS", "0.0.0.0"),
+			ReadTimeout:         getEnvInt("LDAP_READ_TIMEOUT", 30),
+			WriteTimeout:        getEnvInt("LDAP_WRITE_TIMEOUT", 30),
+			IdleTimeout:         getEnvInt("LDAP_IDLE_TIMEOUT", 300),
+			MaxMessageSize:      getEnvInt("LDAP_MAX_MESSAGE_SIZE", 1<<20),
+			MaxConnections:      getEnvInt("LDAP_MAX_CONNECTIONS", 1024),
+			MaxConnectionsPerIP: getEnvInt("LDAP_MAX_CONNECTIONS_PER_IP", 0),
 			TLS: TLSConfig{
 				Enabled:         getEnvBool("LDAP_TLS_ENABLED", false),
 				StartTLSEnabled: getEnvBool("LDAP_STARTTLS_ENABLED", false),
 				CertFile:        getEnvString("LDAP_TLS_CERT_FILE", ""),
 				KeyFile:         getEnvString("LDAP_TLS_KEY_FILE", ""),
+				ClientCAFile:    getEnvString("LDAP_TLS_CLIENT_CA_FILE", ""),
+				ClientAuth:      getEnvString("LDAP_TLS_CLIENT_AUTH", TLSClientAuthNone),
 			},
 		},
 		LDAP: LDAPConfig{
@@ -116,6 +272,34 @@ func LoadFromEnv() (*Config, error) {
 				SaltLength:  uint32(getEnvInt("LDAP_ARGON2_SALT_LENGTH", 16)),
 				KeyLength:   uint32(getEnvInt("LDAP_ARGON2_KEY_LENGTH", 32)),
 			},
+			AllowLegacyPasswordHashes: getEnvBool("LDAP_ALLOW_LEGACY_PASSWORD_HASHES", false),
+			PasswordHashWorkers:       getEnvInt("LDAP_PASSWORD_HASH_WORKERS", 4),
+			PasswordHashQueueTimeout:  getEnvInt("LDAP_PASSWORD_HASH_QUEUE_TIMEOUT", 5),
+			SASLExternalMapping:       getEnvString("LDAP_SASL_EXTERNAL_MAPPING", DefaultSASLExternalMapping),
+			PasswordPolicy: PasswordPolicyConfig{
+				MinLength:            getEnvInt("LDAP_PPOLICY_MIN_LENGTH", 0),
+				MinCharClasses:       getEnvInt("LDAP_PPOLICY_MIN_CHAR_CLASSES", 0),
+				HistoryCount:         getEnvInt("LDAP_PPOLICY_HISTORY", 0),
+				MaxAge:               getEnvInt("LDAP_PPOLICY_MAX_AGE", 0),
+				ExpireWarning:        getEnvInt("LDAP_PPOLICY_EXPIRE_WARNING", 0),
+				GraceLogins:          getEnvInt("LDAP_PPOLICY_GRACE_LOGINS", 0),
+				MaxFailures:          getEnvInt("LDAP_PPOLICY_MAX_FAILURES", 0),
+				LockoutDuration:      getEnvInt("LDAP_PPOLICY_LOCKOUT_DURATION", 900),
+				FailureCountInterval: getEnvInt("LDAP_PPOLICY_FAILURE_COUNT_INTERVAL", 0),
+			},
+			BindThrottle: BindThrottleConfig{
+				AccountFailures: getEnvInt("LDAP_BIND_THROTTLE_ACCOUNT_FAILURES", 10),
+				AddressFailures: getEnvInt("LDAP_BIND_THROTTLE_ADDRESS_FAILURES", 50),
+				Delay:           getEnvInt("LDAP_BIND_THROTTLE_DELAY_MS", 100),
+				MaxDelay:        getEnvInt("LDAP_BIND_THROTTLE_MAX_DELAY_MS", 2000),
+				BlockDuration:   getEnvInt("LDAP_BIND_THROTTLE_BLOCK_DURATION", 300),
+				FailureWindow:   getEnvInt("LDAP_BIND_THROTTLE_WINDOW", 900),
+				StateFile:       getEnvString("LDAP_BIND_THROTTLE_STATE_FILE", ""),
+			},
+		},
+		Limits: LimitsConfig{
+			SearchSizeLimit: getEnvInt("LDAP_SEARCH_SIZE_LIMIT", 0),
+			SearchTimeLimit: getEnvInt("LDAP_SEARCH_TIME_LIMIT", 0),
 		},
 		WebUI: WebUIConfig{
 			Enabled:     getEnvBoolAny(false, "LDAP_WEB_UI_ENABLED", "LDAP_WEBUI_ENABLED"),
@@ -133,6 +317,36 @@ func LoadFromEnv() (*Config, error) {
 		},
 	}
 
+	identityLimits, err := ParseIdentityLimits(os.Getenv("LDAP_SEARCH_IDENTITY_LIMITS"))
+	if err != nil {
+		return cfg, fmt.Errorf("LDAP_SEARCH_IDENTITY_LIMITS: %w", err)
+	}
+	cfg.Limits.IdentityLimits = identityLimits
+
+	cfg.Authz.AccessRulesFile = getEnvString("LDAP_ACCESS_RULES_FILE", "")
+	if cfg.Authz.AccessRulesFile != "" {
+		rules, err := LoadAccessRules(cfg.Authz.AccessRulesFile)
+		if err != nil {
+			return cfg, fmt.Errorf("LDAP_ACCESS_RULES_FILE: %w", err)
+		}
+		cfg.Authz.AccessRules = rules
+	}
+
+	delegatedAdmins, err := ParseDelegatedAdmins(os.Getenv("LDAP_DELEGATED_ADMINS"))
+	if err != nil {
+		return cfg, fmt.Errorf("LDAP_DELEGATED_ADMINS: %w", err)
+	}
+	cfg.Authz.DelegatedAdmins = delegatedAdmins
+
+	cfg.Authz.RolesFile = getEnvString("LDAP_ROLES_FILE", "")
+	if cfg.Authz.RolesFile != "" {
+		roles, err := LoadRoles(cfg.Authz.RolesFile)
+		if err != nil {
+			return cfg, fmt.Errorf("LDAP_ROLES_FILE: %w", err)
+		}
+		cfg.Authz.Roles = roles
+	}
+
 	if err := cfg.Validate(); err != nil {
 		return cfg, err
 	}
@@ -159,9 +373,192 @@ func (c *Config) Validate() error {
 		(strings.TrimSpace(c.Server.TLS.CertFile) == "" || strings.TrimSpace(c.Server.TLS.KeyFile) == "") {
 		return fmt.Errorf("LDAP_TLS_CERT_FILE and LDAP_TLS_KEY_FILE are required when LDAP_TLS_ENABLED or LDAP_STARTTLS_ENABLED is true")
 	}
+	switch c.Server.TLS.ClientAuth {
+	case "", TLSClientAuthNone:
+	case TLSClientAuthVerify, TLSClientAuthRequire:
+		if strings.TrimSpace(c.Server.TLS.ClientCAFile) == "" {
+			return fmt.Errorf("LDAP_TLS_CLIENT_CA_FILE is required when LDAP_TLS_CLIENT_AUTH is %s", c.Server.TLS.ClientAuth)
+		}
+		if !c.Server.TLS.Enabled && !c.Server.TLS.StartTLSEnabled {
+			return fmt.Errorf("LDAP_TLS_CLIENT_AUTH requires LDAP_TLS_ENABLED or LDAP_STARTTLS_ENABLED")
+		}
+	default:
+		return fmt.Errorf("LDAP_TLS_CLIENT_AUTH must be %s, %s or %s", TLSClientAuthNone, TLSClientAuthVerify, TLSClientAuthRequire)
+	}
+	if c.Server.ReadTimeout < 0 || c.Server.WriteTimeout < 0 || c.Server.IdleTimeout < 0 {
+		return fmt.Errorf("LDAP_READ_TIMEOUT, LDAP_WRITE_TIMEOUT and LDAP_IDLE_TIMEOUT must not be negative")
+	}
+	if c.Server.MaxMessageSize < 0 || c.Server.MaxMessageSize > MaxMessageSizeLimit {
+		return fmt.Errorf("LDAP_MAX_MESSAGE_SIZE must be between 0 and %d", MaxMessageSizeLimit)
+	}
+	if c.Server.MaxConnections < 0 || c.Server.MaxConnectionsPerIP < 0 {
+		return fmt.Errorf("LDAP_MAX_CONNECTIONS and LDAP_MAX_CONNECTIONS_PER_IP must not be negative")
+	}
+	if c.Limits.SearchSizeLimit < 0 || c.Limits.SearchTimeLimit < 0 {
+		return fmt.Errorf("LDAP_SEARCH_SIZE_LIMIT and LDAP_SEARCH_TIME_LIMIT must not be negative")
+	}
+	if c.Security.PasswordHashWorkers < 0 || c.Security.PasswordHashQueueTimeout < 0 {
+		return fmt.Errorf("LDAP_PASSWORD_HASH_WORKERS and LDAP_PASSWORD_HASH_QUEUE_TIMEOUT must not be negative")
+	}
+	if err := c.Security.PasswordPolicy.Validate(); err != nil {
+		return err
+	}
+	if err := c.Security.BindThrottle.Validate(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Validate checks the bind throttle settings.
+func (t BindThrottleConfig) Validate() error {
+	for _, setting := range []struct {
+		env   string
+		value int
+	}{
+		{"LDAP_BIND_THROTTLE_ACCOUNT_FAILURES", t.AccountFailures},
+		{"LDAP_BIND_THROTTLE_ADDRESS_FAILURES", t.AddressFailures},
+		{"LDAP_BIND_THROTTLE_DELAY_MS", t.Delay},
+		{"LDAP_BIND_THROTTLE_MAX_DELAY_MS", t.MaxDelay},
+		{"LDAP_BIND_THROTTLE_BLOCK_DURATION", t.BlockDuration},
+		{"LDAP_BIND_THROTTLE_WINDOW", t.FailureWindow},
+	} {
+		if setting.value < 0 {
+			return fmt.Errorf("%s must not be negative", setting.env)
+		}
+	}
+	return nil
+}
+
+// Validate checks the password policy settings.
+func (p PasswordPolicyConfig) Validate() error {
+	for _, setting := range []struct {
+		env   string
+		value int
+	}{
+		{"LDAP_PPOLICY_MIN_LENGTH", p.MinLength},
+		{"LDAP_PPOLICY_HISTORY", p.HistoryCount},
+		{"LDAP_PPOLICY_MAX_AGE", p.MaxAge},
+		{"LDAP_PPOLICY_EXPIRE_WARNING", p.ExpireWarning},
+		{"LDAP_PPOLICY_GRACE_LOGINS", p.GraceLogins},
+		{"LDAP_PPOLICY_MAX_FAILURES", p.MaxFailures},
+		{"LDAP_PPOLICY_LOCKOUT_DURATION", p.LockoutDuration},
+		{"LDAP_PPOLICY_FAILURE_COUNT_INTERVAL", p.FailureCountInterval},
+	} {
+		if setting.value < 0 {
+			return fmt.Errorf("%s must not be negative", setting.env)
+		}
+	}
+	if p.MinCharClasses < 0 || p.MinCharClasses > 4 {
+		return fmt.Errorf("LDAP_PPOLICY_MIN_CHAR_CLASSES must be between 0 and 4")
+	}
 	return nil
 }
 
+// SearchLimitsFor returns the administrative size and time limits for a bound
+// DN. Identity overrides win over the global limits.
+func (c LimitsConfig) SearchLimitsFor(dn string) (sizeLimit, timeLimit int) {
+	for _, limits := range c.IdentityLimits {
+		if strings.EqualFold(strings.TrimSpace(limits.DN), strings.TrimSpace(dn)) {
+			return limits.SearchSizeLimit, limits.SearchTimeLimit
+		}
+	}
+	return c.SearchSizeLimit, c.SearchTimeLimit
+}
+
+// ParseIdentityLimits parses per-identity search limits written as
+// "<dn>|<size limit>|<time limit>" entries separated by semicolons, for example
+// "uid=sync,ou=users,dc=example,dc=com|5000|60".
+func ParseIdentityLimits(value string) ([]IdentityLimits, error) {
+	var limits []IdentityLimits
+	for _, item := range strings.Split(value, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := strings.Split(item, "|")
+		if len(parts) != 3 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("invalid entry %q, want <dn>|<size limit>|<time limit>", item)
+		}
+		sizeLimit, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil || sizeLimit < 0 {
+			return nil, fmt.Errorf("invalid size limit in %q", item)
+		}
+		timeLimit, err := strconv.Atoi(strings.TrimSpace(parts[2]))
+		if err != nil || timeLimit < 0 {
+			return nil, fmt.Errorf("invalid time limit in %q", item)
+		}
+		limits = append(limits, IdentityLimits{
+			DN:              strings.TrimSpace(parts[0]),
+			SearchSizeLimit: sizeLimit,
+			SearchTimeLimit: timeLimit,
+		})
+	}
+	return limits, nil
+}
+
+// ParseDelegatedAdmins parses delegated administrators written as
+// "<group dn>|<subtree>[|<subtree>...]" entries separated by semicolons, for
+// example "cn=emea-admins,ou=groups,dc=example,dc=com|ou=emea,dc=example,dc=com".
+func ParseDelegatedAdmins(value string) ([]DelegatedAdmin, error) {
+	var admins []DelegatedAdmin
+	for _, item := range strings.Split(value, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := strings.Split(item, "|")
+		if len(parts) < 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("invalid entry %q, want <group dn>|<subtree>[|<subtree>...]", item)
+		}
+		admin := DelegatedAdmin{GroupDN: strings.TrimSpace(parts[0])}
+		for _, subtree := range parts[1:] {
+			subtree = strings.TrimSpace(subtree)
+			if subtree == "" {
+				return nil, fmt.Errorf("empty subtree in %q", item)
+			}
+			admin.Subtrees = append(admin.Subtrees, subtree)
+		}
+		admins = append(admins, admin)
+	}
+	return admins, nil
+}
+
+// LoadAccessRules reads access rules from a JSON file of the form
+// {"rules": [...]}. Rule contents are checked by the authz package.
+func LoadAccessRules(path string) ([]AccessRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read access rules: %w", err)
+	}
+	var file struct {
+		Rules []AccessRule `json:"rules"`
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to parse access rules: %w", err)
+	}
+	return file.Rules, nil
+}
+
+// LoadRoles reads roles from a JSON file of the form {"roles": [...]}. Role
+// contents are checked by the authz package.
+func LoadRoles(path string) ([]Role, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read roles: %w", err)
+	}
+	var file struct {
+		Roles []Role `json:"roles"`
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to parse roles: %w", err)
+	}
+	return file.Roles, nil
+}
+
 func (c *Config) Print() {
 	slog.Info("Configuration loaded",
 		"port", c.Server.Port,
@@ -172,7 +569,25 @@ func (c *Config) Print() {
 		"log_format", c.Logging.Format,
 		"tls_enabled", c.Server.TLS.Enabled,
 		"starttls_enabled", c.Server.TLS.StartTLSEnabled,
+		"tls_client_auth", c.Server.TLS.ClientAuth,
+		"read_timeout", c.Server.ReadTimeout,
+		"write_timeout", c.Server.WriteTimeout,
+		"idle_timeout", c.Server.IdleTimeout,
+		"max_message_size", c.Server.MaxMessageSize,
+		"max_connections", c.Server.MaxConnections,
+		"max_connections_per_ip", c.Server.MaxConnectionsPerIP,
 		"allow_anonymous_bind", c.Security.AllowAnonymousBind,
+		"allow_legacy_password_hashes", c.Security.AllowLegacyPasswordHashes,
+		"password_hash_workers", c.Security.PasswordHashWorkers,
+		"ppolicy_max_failures", c.Security.PasswordPolicy.MaxFailures,
+		"bind_throttle_account_failures", c.Security.BindThrottle.AccountFailures,
+		"bind_throttle_address_failures", c.Security.BindThrottle.AddressFailures,
+		"ppolicy_max_age", c.Security.PasswordPolicy.MaxAge,
+		"search_size_limit", c.Limits.SearchSizeLimit,
+		"search_time_limit", c.Limits.SearchTimeLimit,
+		"access_rules", len(c.Authz.AccessRules),
+		"delegated_admins", len(c.Authz.DelegatedAdmins),
+		"roles", len(c.Authz.Roles),
 	)
 }
 
@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -34,6 +35,19 @@ func TestLoadDefaults(t *testing.T) {
 	assert.False(t, cfg.Server.TLS.StartTLSEnabled)
 	assert.Equal(t, "", cfg.Server.TLS.CertFile)
 	assert.Equal(t, "", cfg.Server.TLS.KeyFile)
+	assert.Equal(t, TLSClientAuthNone, cfg.Server.TLS.ClientAuth)
+	assert.Equal(t, DefaultSASLExternalMapping, cfg.Security.SASLExternalMapping)
+	assert.False(t, cfg.Security.AllowLegacyPasswordHashes)
+	assert.Equal(t, 4, cfg.Security.PasswordHashWorkers)
+	assert.Equal(t, 5, cfg.Security.PasswordHashQueueTimeout)
+	assert.Equal(t, BindThrottleConfig{
+		AccountFailures: 10,
+		AddressFailures: 50,
+		Delay:           100,
+		MaxDelay:        2000,
+		BlockDuration:   300,
+		FailureWindow:   900,
+	}, cfg.Security.BindThrottle)
 	assert.False(t, cfg.Telemetry.Enabled)
 	assert.False(t, cfg.Telemetry.MetricsEnabled)
 	assert.Equal(t, "ldaplite", cfg.Telemetry.OTelServiceName)
@@ -59,6 +73,51 @@ func TestLoadTLSConfig(t *testing.T) {
 	assert.Equal(t, "/certs/ldap.key", cfg.Server.TLS.KeyFile)
 }
 
+func TestLoadTLSClientAuthConfig(t *testing.T) {
+	t.Setenv("LDAP_BASE_DN", "dc=test,dc=com")
+	t.Setenv("LDAP_STARTTLS_ENABLED", "true")
+	t.Setenv("LDAP_TLS_CERT_FILE", "/certs/ldap.crt")
+	t.Setenv("LDAP_TLS_KEY_FILE", "/certs/ldap.key")
+	t.Setenv("LDAP_TLS_CLIENT_CA_FILE", "/certs/clients.pem")
+	t.Setenv("LDAP_TLS_CLIENT_AUTH", "require")
+	t.Setenv("LDAP_SASL_EXTERNAL_MAPPING", "mail={email}")
+
+	cfg, err := LoadFromEnv()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "/certs/clients.pem", cfg.Server.TLS.ClientCAFile)
+	assert.Equal(t, TLSClientAuthRequire, cfg.Server.TLS.ClientAuth)
+	assert.Equal(t, "mail={email}", cfg.Security.SASLExternalMapping)
+}
+
+func TestValidateRejectsInvalidTLSClientAuth(t *testing.T) {
+	tls := TLSConfig{StartTLSEnabled: true, CertFile: "/certs/ldap.crt", KeyFile: "/certs/ldap.key"}
+	tests := []struct {
+		name      string
+		clientCA  string
+		mode      string
+		enableTLS bool
+		wantErr   string
+	}{
+		{"unknown mode", "/certs/clients.pem", "optional", true, "LDAP_TLS_CLIENT_AUTH must be"},
+		{"verify without CA", "", TLSClientAuthVerify, true, "LDAP_TLS_CLIENT_CA_FILE is required"},
+		{"require without TLS", "/certs/clients.pem", TLSClientAuthRequire, false, "requires LDAP_TLS_ENABLED"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{LDAP: LDAPConfig{BaseDN: "dc=test,dc=com"}}
+			if tt.enableTLS {
+				cfg.Server.TLS = tls
+			}
+			cfg.Server.TLS.ClientCAFile = tt.clientCA
+			cfg.Server.TLS.ClientAuth = tt.mode
+
+			assert.ErrorContains(t, cfg.Validate(), tt.wantErr)
+		})
+	}
+}
+
 func TestValidateRequiresTLSCertificateFilesWhenTLSEnabled(t *testing.T) {
 	cfg := &Config{
 		LDAP: LDAPConfig{BaseDN: "dc=test,dc=com"},
@@ -250,6 +309,44 @@ func TestConfigTimeouts(t *testing.T) {
 
 	assert.Equal(t, 60, cfg.Server.ReadTimeout)
 	assert.Equal(t, 45, cfg.Server.WriteTimeout)
+	assert.Equal(t, 300, cfg.Server.IdleTimeout)
+}
+
+func TestConfigConnectionLimits(t *testing.T) {
+	t.Cleanup(func() {
+		os.Unsetenv("LDAP_BASE_DN")
+		os.Unsetenv("LDAP_IDLE_TIMEOUT")
+		os.Unsetenv("LDAP_MAX_MESSAGE_SIZE")
+		os.Unsetenv("LDAP_MAX_CONNECTIONS")
+		os.Unsetenv("LDAP_MAX_CONNECTIONS_PER_IP")
+	})
+
+	os.Setenv("LDAP_BASE_DN", "dc=test,dc=com")
+	cfg := Load()
+	assert.Equal(t, 1<<20, cfg.Server.MaxMessageSize)
+	assert.Equal(t, 1024, cfg.Server.MaxConnections)
+	assert.Equal(t, 0, cfg.Server.MaxConnectionsPerIP)
+
+	os.Setenv("LDAP_IDLE_TIMEOUT", "0")
+	os.Setenv("LDAP_MAX_MESSAGE_SIZE", "65536")
+	os.Setenv("LDAP_MAX_CONNECTIONS", "10")
+	os.Setenv("LDAP_MAX_CONNECTIONS_PER_IP", "2")
+	cfg = Load()
+	assert.Equal(t, 0, cfg.Server.IdleTimeout)
+	assert.Equal(t, 65536, cfg.Server.MaxMessageSize)
+	assert.Equal(t, 10, cfg.Server.MaxConnections)
+	assert.Equal(t, 2, cfg.Server.MaxConnectionsPerIP)
+}
+
+func TestValidateRejectsInvalidConnectionLimits(t *testing.T) {
+	for name, server := range map[string]ServerConfig{
+		"LDAP_IDLE_TIMEOUT":     {IdleTimeout: -1},
+		"LDAP_MAX_MESSAGE_SIZE": {MaxMessageSize: MaxMessageSizeLimit + 1},
+		"LDAP_MAX_CONNECTIONS":  {MaxConnections: -1},
+	} {
+		cfg := &Config{LDAP: LDAPConfig{BaseDN: "dc=test,dc=com"}, Server: server}
+		assert.ErrorContains(t, cfg.Validate(), name)
+	}
 }
 
 func TestConfigDatabase(t *testing.T) {
@@ -328,3 +425,183 @@ func TestConfigTelemetry(t *testing.T) {
 	assert.Equal(t, 19090, cfg.Telemetry.MetricsPort)
 	assert.Equal(t, "/custom-metrics", cfg.Telemetry.MetricsPath)
 }
+
+func TestLoadSearchLimits(t *testing.T) {
+	t.Setenv("LDAP_BASE_DN", "dc=test,dc=com")
+	t.Setenv("LDAP_SEARCH_SIZE_LIMIT", "500")
+	t.Setenv("LDAP_SEARCH_TIME_LIMIT", "30")
+	t.Setenv("LDAP_SEARCH_IDENTITY_LIMITS", "uid=sync,ou=users,dc=test,dc=com|5000|0; uid=app,ou=users,dc=test,dc=com|10|5")
+
+	cfg, err := LoadFromEnv()
+
+	assert.NoError(t, err)
+	assert.Equal(t, 500, cfg.Limits.SearchSizeLimit)
+	assert.Equal(t, 30, cfg.Limits.SearchTimeLimit)
+	assert.Len(t, cfg.Limits.IdentityLimits, 2)
+
+	sizeLimit, timeLimit := cfg.Limits.SearchLimitsFor("UID=SYNC,OU=USERS,DC=TEST,DC=COM")
+	assert.Equal(t, 5000, sizeLimit)
+	assert.Equal(t, 0, timeLimit)
+
+	sizeLimit, timeLimit = cfg.Limits.SearchLimitsFor("uid=other,ou=users,dc=test,dc=com")
+	assert.Equal(t, 500, sizeLimit)
+	assert.Equal(t, 30, timeLimit)
+}
+
+func TestParseIdentityLimitsRejectsMalformedEntries(t *testing.T) {
+	for _, value := range []string{
+		"uid=app,dc=test,dc=com",
+		"uid=app,dc=test,dc=com|10",
+		"|10|5",
+		"uid=app,dc=test,dc=com|-1|5",
+		"uid=app,dc=test,dc=com|10|soon",
+	} {
+		_, err := ParseIdentityLimits(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestLoadAccessRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	err := os.WriteFile(path, []byte(`{"rules": [
+		{"name": "hide-phones", "effect": "deny", "subject": "users",
+		 "attributes": ["mobile"], "permissions": ["read", "search", "compare"]}
+	]}`), 0o600)
+	assert.NoError(t, err)
+	t.Setenv("LDAP_BASE_DN", "dc=test,dc=com")
+	t.Setenv("LDAP_ACCESS_RULES_FILE", path)
+
+	cfg, err := LoadFromEnv()
+
+	assert.NoError(t, err)
+	assert.Equal(t, []AccessRule{{
+		Name:        "hide-phones",
+		Effect:      "deny",
+		Subject:     "users",
+		Attributes:  []string{"mobile"},
+		Permissions: []string{"read", "search", "compare"},
+	}}, cfg.Authz.AccessRules)
+}
+
+func TestLoadAccessRulesRejectsUnknownFields(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"rules": [{"subjects": "users"}]}`), 0o600))
+
+	_, err := LoadAccessRules(path)
+	assert.ErrorContains(t, err, "failed to parse access rules")
+
+	_, err = LoadAccessRules(filepath.Join(t.TempDir(), "missing.json"))
+	assert.ErrorContains(t, err, "failed to read access rules")
+}
+
+func TestLoadRoles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "roles.json")
+	err := os.WriteFile(path, []byte(`{"roles": [
+		{"name": "provisioning", "capabilities": ["directory.read", "scim.write"],
+		 "groups": ["cn=idp,ou=groups,dc=test,dc=com"], "subtrees": ["ou=people,dc=test,dc=com"]}
+	]}`), 0o600)
+	assert.NoError(t, err)
+	t.Setenv("LDAP_BASE_DN", "dc=test,dc=com")
+	t.Setenv("LDAP_ROLES_FILE", path)
+
+	cfg, err := LoadFromEnv()
+
+	assert.NoError(t, err)
+	assert.Equal(t, []Role{{
+		Name:         "provisioning",
+		Capabilities: []string{"directory.read", "scim.write"},
+		Groups:       []string{"cn=idp,ou=groups,dc=test,dc=com"},
+		Subtrees:     []string{"ou=people,dc=test,dc=com"},
+	}}, cfg.Authz.Roles)
+
+	assert.NoError(t, os.WriteFile(path, []byte(`{"roles": [{"group": "cn=idp"}]}`), 0o600))
+	_, err = LoadRoles(path)
+	assert.ErrorContains(t, err, "failed to parse roles")
+}
+
+func TestValidateRejectsNegativeSearchLimits(t *testing.T) {
+	cfg := &Config{
+		LDAP:   LDAPConfig{BaseDN: "dc=test,dc=com"},
+		Limits: LimitsConfig{SearchSizeLimit: -1},
+	}
+
+	assert.ErrorContains(t, cfg.Validate(), "LDAP_SEARCH_SIZE_LIMIT")
+}
+
+func TestLoadPasswordPolicy(t *testing.T) {
+	t.Setenv("LDAP_BASE_DN", "dc=test,dc=com")
+	t.Setenv("LDAP_PPOLICY_MIN_LENGTH", "12")
+	t.Setenv("LDAP_PPOLICY_MIN_CHAR_CLASSES", "3")
+	t.Setenv("LDAP_PPOLICY_HISTORY", "5")
+	t.Setenv("LDAP_PPOLICY_MAX_AGE", "7776000")
+	t.Setenv("LDAP_PPOLICY_MAX_FAILURES", "5")
+
+	cfg, err := LoadFromEnv()
+
+	assert.NoError(t, err)
+	policy := cfg.Security.PasswordPolicy
+	assert.Equal(t, 12, policy.MinLength)
+	assert.Equal(t, 3, policy.MinCharClasses)
+	assert.Equal(t, 5, policy.HistoryCount)
+	assert.Equal(t, 7776000, policy.MaxAge)
+	assert.Equal(t, 0, policy.GraceLogins)
+	assert.Equal(t, 5, policy.MaxFailures)
+	assert.Equal(t, 900, policy.LockoutDuration)
+}
+
+func TestValidateRejectsNegativePasswordHashPool(t *testing.T) {
+	for _, security := range []SecurityConfig{{PasswordHashWorkers: -1}, {PasswordHashQueueTimeout: -1}} {
+		cfg := &Config{LDAP: LDAPConfig{BaseDN: "dc=test,dc=com"}, Security: security}
+		assert.ErrorContains(t, cfg.Validate(), "LDAP_PASSWORD_HASH_WORKERS")
+	}
+}
+
+func TestValidateRejectsInvalidPasswordPolicy(t *testing.T) {
+	for name, policy := range map[string]PasswordPolicyConfig{
+		"LDAP_PPOLICY_MAX_FAILURES":     {MaxFailures: -1},
+		"LDAP_PPOLICY_MIN_CHAR_CLASSES": {MinCharClasses: 5},
+	} {
+		cfg := &Config{
+			LDAP:     LDAPConfig{BaseDN: "dc=test,dc=com"},
+			Security: SecurityConfig{PasswordPolicy: policy},
+		}
+		assert.ErrorContains(t, cfg.Validate(), name)
+	}
+}
+
+func TestValidateRejectsNegativeBindThrottle(t *testing.T) {
+	for name, throttle := range map[string]BindThrottleConfig{
+		"LDAP_BIND_THROTTLE_ADDRESS_FAILURES": {AddressFailures: -1},
+		"LDAP_BIND_THROTTLE_MAX_DELAY_MS":     {MaxDelay: -1},
+	} {
+		cfg := &Config{
+			LDAP:     LDAPConfig{BaseDN: "dc=test,dc=com"},
+			Security: SecurityConfig{BindThrottle: throttle},
+		}
+		assert.ErrorContains(t, cfg.Validate(), name)
+	}
+}
+
+func TestParseDelegatedAdmins(t *testing.T) {
+	t.Setenv("LDAP_BASE_DN", "dc=test,dc=com")
+	t.Setenv("LDAP_DELEGATED_ADMINS", "cn=emea-admins,ou=groups,dc=test,dc=com|ou=emea,dc=test,dc=com| ou=partners,dc=test,dc=com ;")
+
+	cfg, err := LoadFromEnv()
+
+	assert.NoError(t, err)
+	assert.Equal(t, []DelegatedAdmin{{
+		GroupDN:  "cn=emea-admins,ou=groups,dc=test,dc=com",
+		Subtrees: []string{"ou=emea,dc=test,dc=com", "ou=partners,dc=test,dc=com"},
+	}}, cfg.Authz.DelegatedAdmins)
+}
+
+func TestParseDelegatedAdminsRejectsMalformedEntries(t *testing.T) {
+	for _, value := range []string{
+		"cn=emea-admins,ou=groups,dc=test,dc=com",
+		"|ou=emea,dc=test,dc=com",
+		"cn=emea-admins,ou=groups,dc=test,dc=com||ou=emea,dc=test,dc=com",
+	} {
+		_, err := ParseDelegatedAdmins(value)
+		assert.Error(t, err, value)
+	}
+}
@@ -0,0 +1,178 @@
+package crypto
+
+import (
+	"crypto/pbkdf2"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// legacyScheme verifies password hashes imported from other directories,
+// mostly OpenLDAP. They are never produced, only checked until the next
+// successful bind rehashes them to Argon2id.
+type legacyScheme struct {
+	// validate checks the structure of the value after the scheme prefix.
+	validate func(value string) error
+	// verify checks a password against the value after the scheme prefix.
+	verify func(password, value string) (bool, error)
+}
+
+// legacySchemes maps upper-case scheme names to their verifiers.
+var legacySchemes = map[string]legacyScheme{
+	"SHA":           digestScheme(sha1.New, false),
+	"SSHA":          digestScheme(sha1.New, true),
+	"SHA256":        digestScheme(sha256.New, false),
+	"SSHA256":       digestScheme(sha256.New, true),
+	"SHA512":        digestScheme(sha512.New, false),
+	"SSHA512":       digestScheme(sha512.New, true),
+	"CRYPT":         {validate: validateCrypt, verify: verifyCrypt},
+	"PBKDF2":        pbkdf2Scheme(sha1.New),
+	"PBKDF2-SHA1":   pbkdf2Scheme(sha1.New),
+	"PBKDF2-SHA256": pbkdf2Scheme(sha256.New),
+	"PBKDF2-SHA512": pbkdf2Scheme(sha512.New),
+}
+
+// LegacySchemes lists the schemes verified besides {ARGON2ID}.
+func LegacySchemes() []string {
+	return []string{
+		"{SHA}", "{SSHA}", "{SHA256}", "{SSHA256}", "{SHA512}", "{SSHA512}",
+		"{CRYPT}", "{PBKDF2}", "{PBKDF2-SHA1}", "{PBKDF2-SHA256}", "{PBKDF2-SHA512}",
+	}
+}
+
+// lookupLegacyScheme splits a hashed password into its legacy scheme and the
+// value after the prefix. Scheme names are case-insensitive (RFC 3112).
+func lookupLegacyScheme(hashedPassword string) (legacyScheme, string, bool) {
+	scheme, err := extractScheme(hashedPassword)
+	if err != nil {
+		return legacyScheme{}, "", false
+	}
+	legacy, ok := legacySchemes[strings.ToUpper(scheme)]
+	if !ok {
+		return legacyScheme{}, "", false
+	}
+	return legacy, hashedPassword[len(scheme)+2:], true
+}
+
+// digestScheme handles {SHA} style values: base64 of the digest, followed by
+// the salt for the salted variants.
+func digestScheme(newHash func() hash.Hash, salted bool) legacyScheme {
+	size := newHash().Size()
+	parse := func(value string) (digest, salt []byte, err error) {
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to decode hash: %w", err)
+		}
+		if len(decoded) < size || (!salted && len(decoded) != size) {
+			return nil, nil, fmt.Errorf("invalid hash length %d", len(decoded))
+		}
+		return decoded[:size], decoded[size:], nil
+	}
+	return legacyScheme{
+		validate: func(value string) error {
+			_, _, err := parse(value)
+			return err
+		},
+		verify: func(password, value string) (bool, error) {
+			digest, salt, err := parse(value)
+			if err != nil {
+				return false, err
+			}
+			h := newHash()
+			h.Write([]byte(password))
+			h.Write(salt)
+			return constantTimeCompare(h.Sum(nil), digest), nil
+		},
+	}
+}
+
+// pbkdf2Scheme handles the OpenLDAP pw-pbkdf2 format:
+// iterations$salt$hash, with salt and hash in adapted base64 ('.' for '+',
+// no padding). The key length is the length of the stored hash.
+func pbkdf2Scheme(newHash func() hash.Hash) legacyScheme {
+	parse := func(value string) (iterations int, salt, key []byte, err error) {
+		parts := strings.Split(value, "$")
+		if len(parts) != 3 {
+			return 0, nil, nil, fmt.Errorf("invalid hash structure (expected 3 parts, got %d)", len(parts))
+		}
+		iterations, err = strconv.Atoi(parts[0])
+		if err != nil || iterations < 1 {
+			return 0, nil, nil, fmt.Errorf("invalid iteration count: %q", parts[0])
+		}
+		if salt, err = decodeAdaptedBase64(parts[1]); err != nil {
+			return 0, nil, nil, fmt.Errorf("failed to decode salt: %w", err)
+		}
+		if key, err = decodeAdaptedBase64(parts[2]); err != nil || len(key) == 0 {
+			return 0, nil, nil, fmt.Errorf("failed to decode hash: %w", err)
+		}
+		return iterations, salt, key, nil
+	}
+	return legacyScheme{
+		validate: func(value string) error {
+			_, _, _, err := parse(value)
+			return err
+		},
+		verify: func(password, value string) (bool, error) {
+			iterations, salt, key, err := parse(value)
+			if err != nil {
+				return false, err
+			}
+			computed, err := pbkdf2.Key(newHash, password, salt, iterations, len(key))
+			if err != nil {
+				return false, err
+			}
+			return constantTimeCompare(computed, key), nil
+		},
+	}
+}
+
+func decodeAdaptedBase64(value string) ([]byte, error) {
+	value = strings.TrimRight(strings.ReplaceAll(value, ".", "+"), "=")
+	return base64.RawStdEncoding.DecodeString(value)
+}
+
+// validateCrypt accepts the crypt(3) formats {CRYPT} values are verified
+// with: bcrypt ($2a$, $2b$, $2y$) and SHA-crypt ($5$, $6$).
+func validateCrypt(value string) error {
+	switch {
+	case isBcrypt(value):
+		_, err := bcrypt.Cost([]byte(value))
+		return err
+	case strings.HasPrefix(value, "$5$"), strings.HasPrefix(value, "$6$"):
+		_, err := parseSHACrypt(value)
+		return err
+	default:
+		return fmt.Errorf("unsupported crypt format")
+	}
+}
+
+func verifyCrypt(password, value string) (bool, error) {
+	if err := validateCrypt(value); err != nil {
+		return false, err
+	}
+	if isBcrypt(value) {
+		err := bcrypt.CompareHashAndPassword([]byte(value), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	params, err := parseSHACrypt(value)
+	if err != nil {
+		return false, err
+	}
+	computed := shaCrypt(password, params)
+	return constantTimeCompare([]byte(computed), []byte(value)), nil
+}
+
+func isBcrypt(value string) bool {
+	return strings.HasPrefix(value, "$2a$") || strings.HasPrefix(value, "$2b$") || strings.HasPrefix(value, "$2y$")
+}
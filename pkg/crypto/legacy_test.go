@@ -0,0 +1,112 @@
+package crypto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Hashes of "secret" in the formats OpenLDAP and crypt(3) produce.
+var legacyHashes = map[string]string{
+	"SHA":           "{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=",
+	"SSHA":          "{SSHA}1G904nLkTkGWjKNnQuB/hpWXC/hzYWx0c2FsdA==",
+	"SHA256":        "{SHA256}K7gNU3sdo+OL0wNhqoVWhr3g6s1xYv72ol/pe/Unols=",
+	"SSHA256":       "{SSHA256}oBmrdHcA6OZEkkCLeXh71YAerbvhXz1qqwjrPsXmEtNzYWx0c2FsdA==",
+	"SHA512":        "{SHA512}vSsar3708Jvp9Szi2NWZZ02Bqp1qRCFpbcTZPdBhnWgs5WtNZKnvCXdhztmeD2cmW192CF5bDufKRpayrW/isg==",
+	"SSHA512":       "{SSHA512}aCu7JRc+kLsuEmFs1zTY+AiP7DSGnjjG+dH28Dp+E5usqoAixeTPihKqZmkWal4mUfp63tqvCAkFV1LKTDFH6XNhbHRzYWx0",
+	"lowercase":     "{ssha}1G904nLkTkGWjKNnQuB/hpWXC/hzYWx0c2FsdA==",
+	"sha512-crypt":  "{CRYPT}$6$saltsalt$TVLlQcbpFVof5W3Yz4DTP6gRstiNuHwwTt6GLc1E5n0U0aDehy0S5knV8wiOQSpT0Y77vwPZN.Pq.H91p5hVO1",
+	"sha256-crypt":  "{CRYPT}$5$rounds=1000$saltsalt$eKLZU9t9OoPWrqOQsoTIKG0aYkZ5rGOoOQhiIvoSWX2",
+	"PBKDF2":        "{PBKDF2}1000$AAECAwQFBgcICQoLDA0ODw$sFSyXPFcXgkxACFLfL2dSbbhY6k",
+	"PBKDF2-SHA256": "{PBKDF2-SHA256}1000$AAECAwQFBgcICQoLDA0ODw$Tvsru20utY6o3q7VRBeuL9h/1QqKhWhwk2PaYNRWBgY",
+	"PBKDF2-SHA512": "{PBKDF2-SHA512}1000$AAECAwQFBgcICQoLDA0ODw$8ltlLe5cI6KNrWOmMFkTi2iE7fEXJKJJR9lhjd/xSn68.xOqzv2h20C4IDv/enqlx8f6AaRHE.6XnvHKXKhAjA",
+}
+
+func TestVerifyLegacySchemes(t *testing.T) {
+	hasher := NewPasswordHasher(testArgon2Config())
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	require.NoError(t, err)
+	hashes := map[string]string{"bcrypt": "{CRYPT}" + string(bcryptHash)}
+	for name, hash := range legacyHashes {
+		hashes[name] = hash
+	}
+
+	for name, hash := range hashes {
+		t.Run(name, func(t *testing.T) {
+			verified, err := hasher.Verify("secret", hash)
+			require.NoError(t, err)
+			assert.True(t, verified)
+
+			verified, err = hasher.Verify("wrong", hash)
+			require.NoError(t, err)
+			assert.False(t, verified)
+
+			assert.True(t, hasher.NeedsRehash(hash))
+		})
+	}
+}
+
+func TestSHACryptMatchesSpecificationVectors(t *testing.T) {
+	// From "Unix crypt using SHA-256 and SHA-512".
+	for _, want := range []string{
+		"$5$saltstring$5B8vYYiY.CVt1RlTTf8KbXBH3hsxY/GNooZaBBGWEc5",
+		"$5$rounds=10000$saltstringsaltst$3xv.VbSHBb41AL9AvLeujZkZRBAwqFMz2.opqey6IcA",
+		"$6$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1",
+		"$6$rounds=10000$saltstringsaltst$OW1/O6BYHV6BcXZu8QVeXbDWra3Oeqh0sbHbbMCVNSnCM/UrjmM0Dp8vOuZeHBy/YTBmSK6H9qs/y3RnOaw5v.",
+	} {
+		params, err := parseSHACrypt(want)
+		require.NoError(t, err)
+		assert.Equal(t, want, shaCrypt("Hello world!", params))
+	}
+}
+
+func TestVerifyLegacySchemesRejectsMalformedValues(t *testing.T) {
+	hasher := NewPasswordHasher(testArgon2Config())
+	for _, hash := range []string{
+		"{SSHA}not base64",
+		"{SHA}c2hvcnQ=",
+		"{CRYPT}$1$md5salt$checksum",
+		"{CRYPT}$6$saltonly",
+		"{PBKDF2-SHA256}notanumber$AAEC$AAEC",
+		"{PBKDF2-SHA256}1000$AAEC",
+	} {
+		_, err := hasher.Verify("secret", hash)
+		assert.Error(t, err, hash)
+	}
+}
+
+func TestProcessPasswordLegacySchemesRequireOptIn(t *testing.T) {
+	hasher := NewPasswordHasher(testArgon2Config())
+	legacy := hasher.WithLegacySchemes(true)
+
+	for name, hash := range legacyHashes {
+		_, err := hasher.ProcessPassword(hash)
+		assert.ErrorContains(t, err, "unsupported password scheme", name)
+
+		processed, err := legacy.ProcessPassword(hash)
+		require.NoError(t, err, name)
+		assert.Equal(t, hash, processed, name)
+	}
+
+	_, err := legacy.ProcessPassword("{SSHA}unsupported-hash-format")
+	assert.ErrorContains(t, err, "invalid hashed password format")
+	_, err = legacy.ProcessPassword("{MD5}X03MO1qnZdYdgyfeuILPmQ==")
+	assert.ErrorContains(t, err, "unsupported password scheme")
+}
+
+func TestVerifyUsesEncodedArgon2Parameters(t *testing.T) {
+	production := NewPasswordHasher(productionArgon2Config())
+	hash, err := NewPasswordHasher(testArgon2Config()).Hash("secret")
+	require.NoError(t, err)
+
+	verified, err := production.Verify("secret", hash)
+	require.NoError(t, err)
+	assert.True(t, verified)
+	assert.True(t, production.NeedsRehash(hash))
+
+	current, err := production.Hash("secret")
+	require.NoError(t, err)
+	assert.False(t, production.NeedsRehash(current))
+}
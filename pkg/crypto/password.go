@@ -21,7 +21,8 @@ const (
 
 // PasswordHasher handles password hashing and verification
 type PasswordHasher struct {
-	cfg config.Argon2Config
+	cfg         config.Argon2Config
+	allowLegacy bool
 }
 
 // NewPasswordHasher creates a new password hasher
@@ -29,20 +30,43 @@ func NewPasswordHasher(cfg config.Argon2Config) *PasswordHasher {
 	return &PasswordHasher{cfg: cfg}
 }
 
+// WithLegacySchemes returns a copy of the hasher whose ProcessPassword also
+// accepts pre-hashed passwords in the legacy schemes (see LegacySchemes).
+// Verify always accepts them.
+func (ph *PasswordHasher) WithLegacySchemes(allow bool) *PasswordHasher {
+	copied := *ph
+	copied.allowLegacy = allow
+	return &copied
+}
+
+// IsHashedPassword reports whether a userPassword value carries a scheme
+// prefix and is therefore stored as given instead of being hashed.
+func IsHashedPassword(password string) bool {
+	return strings.HasPrefix(password, "{")
+}
+
 // ProcessPassword is the main entry point for LDAP operations.
 // It accepts plain text passwords (hashes them) or pre-hashed passwords with scheme prefix.
 // Returns LDAP-compliant password string: {ARGON2ID}$argon2id$v=19$m=65536,t=3,p=2$salt$hash
 func (ph *PasswordHasher) ProcessPassword(password string) (string, error) {
 	// Check if already hashed with scheme prefix
-	if strings.HasPrefix(password, "{") {
+	if IsHashedPassword(password) {
 		scheme, err := extractScheme(password)
 		if err != nil {
 			return "", err
 		}
 
+		// Legacy schemes are only stored when explicitly allowed
+		if legacy, value, ok := lookupLegacyScheme(password); ok && ph.allowLegacy {
+			if err := legacy.validate(value); err != nil {
+				return "", fmt.Errorf("invalid hashed password format: %w", err)
+			}
+			return password, nil
+		}
+
 		// Only accept schemes we support (compare without braces)
 		if scheme != schemeArgon2IDName {
-			return "", fmt.Errorf("unsupported password scheme: {%s} (supported: %s)", scheme, SchemeArgon2ID)
+			return "", fmt.Errorf("unsupported password scheme: {%s} (supported: %s)", scheme, ph.supportedSchemes())
 		}
 
 		// Validate format of hashed password
@@ -59,7 +83,16 @@ func (ph *PasswordHasher) ProcessPassword(password string) (string, error) {
 
 // Hash creates a new password hash with LDAP scheme prefix
 // Format: {ARGON2ID}$argon2id$v=19$m=65536,t=3,p=2$salt$hash
-func (ph *PasswordHasher) Hash(password string) (string, error) {
+// It runs in the shared hash pool and fails with ErrHashPoolBusy when no
+// slot frees up in time.
+func (ph *PasswordHasher) Hash(password string) (hashed string, err error) {
+	if poolErr := sharedHashPool.Load().run(func() { hashed, err = ph.hash(password) }); poolErr != nil {
+		return "", poolErr
+	}
+	return hashed, err
+}
+
+func (ph *PasswordHasher) hash(password string) (string, error) {
 	// Generate random salt
 	salt := make([]byte, ph.cfg.SaltLength)
 	if _, err := rand.Read(salt); err != nil {
@@ -96,51 +129,126 @@ func (ph *PasswordHasher) Hash(password string) (string, error) {
 	return SchemeArgon2ID + inner, nil
 }
 
-// Verify verifies a password against its hash
+// Verify verifies a password against its hash. Argon2id hashes are checked
+// with the parameters encoded in them; legacy schemes are accepted too so
+// imported accounts can bind and be rehashed.
 // Expects hash format: {ARGON2ID}$argon2id$v=19$m=65536,t=3,p=2$salt$hash
-func (ph *PasswordHasher) Verify(password, hashedPassword string) (bool, error) {
+// Like Hash it runs in the shared hash pool and may fail with
+// ErrHashPoolBusy.
+func (ph *PasswordHasher) Verify(password, hashedPassword string) (valid bool, err error) {
+	if poolErr := sharedHashPool.Load().run(func() { valid, err = ph.verify(password, hashedPassword) }); poolErr != nil {
+		return false, poolErr
+	}
+	return valid, err
+}
+
+func (ph *PasswordHasher) verify(password, hashedPassword string) (bool, error) {
+	if legacy, value, ok := lookupLegacyScheme(hashedPassword); ok {
+		return legacy.verify(password, value)
+	}
+
 	// Must have scheme prefix
 	if !strings.HasPrefix(hashedPassword, SchemeArgon2ID) {
 		return false, fmt.Errorf("password hash missing scheme prefix")
 	}
 
+	params, err := parseArgon2Hash(hashedPassword)
+	if err != nil {
+		return false, err
+	}
+
+	// Compute hash of provided password
+	computedHash := argon2.IDKey(
+		[]byte(password),
+		params.salt,
+		params.iterations,
+		params.memory,
+		params.parallelism,
+		uint32(len(params.hash)),
+	)
+
+	// Compare hashes in constant time
+	return constantTimeCompare(computedHash, params.hash), nil
+}
+
+// NeedsRehash reports whether a stored hash should be replaced by a fresh
+// Argon2id hash: it uses a legacy scheme or Argon2id parameters other than
+// the configured ones.
+func (ph *PasswordHasher) NeedsRehash(hashedPassword string) bool {
+	params, err := parseArgon2Hash(hashedPassword)
+	if err != nil {
+		return true
+	}
+	return params.memory != ph.cfg.Memory ||
+		params.iterations != ph.cfg.Iterations ||
+		params.parallelism != ph.cfg.Parallelism ||
+		uint32(len(params.salt)) != ph.cfg.SaltLength ||
+		uint32(len(params.hash)) != ph.cfg.KeyLength
+}
+
+// argon2Params are the fields of an encoded Argon2id hash.
+type argon2Params struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	salt        []byte
+	hash        []byte
+}
+
+// parseArgon2Hash decodes {ARGON2ID}$argon2id$v=19$m=65536,t=3,p=2$salt$hash
+func parseArgon2Hash(hashedPassword string) (argon2Params, error) {
+	var params argon2Params
+	if !strings.HasPrefix(hashedPassword, SchemeArgon2ID) {
+		return params, fmt.Errorf("password hash missing scheme prefix")
+	}
+
 	// Strip scheme prefix to get inner hash
 	inner := strings.TrimPrefix(hashedPassword, SchemeArgon2ID)
 
 	// Parse the inner hash
 	parts := strings.Split(inner, "$")
 	if len(parts) != 6 {
-		return false, fmt.Errorf("invalid hash format")
+		return params, fmt.Errorf("invalid hash format")
 	}
 
 	// Verify algorithm
 	if parts[1] != argon2VersionString {
-		return false, fmt.Errorf("unsupported hash algorithm: %s", parts[1])
+		return params, fmt.Errorf("unsupported hash algorithm: %s", parts[1])
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2Version {
+		return params, fmt.Errorf("unsupported argon2 version: %s", parts[2])
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.iterations, &params.parallelism); err != nil {
+		return params, fmt.Errorf("invalid argon2 parameters: %w", err)
+	}
+	if params.iterations == 0 || params.parallelism == 0 {
+		return params, fmt.Errorf("invalid argon2 parameters: %s", parts[3])
 	}
 
 	// Decode salt and hash
-	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	var err error
+	params.salt, err = base64.RawStdEncoding.DecodeString(parts[4])
 	if err != nil {
-		return false, fmt.Errorf("failed to decode salt: %w", err)
+		return params, fmt.Errorf("failed to decode salt: %w", err)
 	}
 
-	expectedHash, err := base64.RawStdEncoding.DecodeString(parts[5])
+	params.hash, err = base64.RawStdEncoding.DecodeString(parts[5])
 	if err != nil {
-		return false, fmt.Errorf("failed to decode hash: %w", err)
+		return params, fmt.Errorf("failed to decode hash: %w", err)
 	}
+	if len(params.hash) == 0 {
+		return params, fmt.Errorf("empty hash")
+	}
+	return params, nil
+}
 
-	// Compute hash of provided password
-	computedHash := argon2.IDKey(
-		[]byte(password),
-		salt,
-		ph.cfg.Iterations,
-		ph.cfg.Memory,
-		ph.cfg.Parallelism,
-		ph.cfg.KeyLength,
-	)
-
-	// Compare hashes in constant time
-	return constantTimeCompare(computedHash, expectedHash), nil
+func (ph *PasswordHasher) supportedSchemes() string {
+	if !ph.allowLegacy {
+		return SchemeArgon2ID
+	}
+	return strings.Join(append([]string{SchemeArgon2ID}, LegacySchemes()...), ", ")
 }
 
 // extractScheme extracts the scheme identifier from a password hash
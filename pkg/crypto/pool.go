@@ -0,0 +1,107 @@
+package crypto
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// ErrHashPoolBusy reports that no password hashing slot became free within
+// the queue timeout. Callers should answer busy/unavailable, not treat it as
+// a wrong password.
+var ErrHashPoolBusy = errors.New("password hashing is busy")
+
+// HashPool bounds the number of concurrent Argon2id computations. Each one
+// allocates Argon2Config.Memory, so without a bound a burst of binds can
+// exhaust the process memory.
+type HashPool struct {
+	slots   chan struct{}
+	timeout time.Duration
+	waiting atomic.Int64
+	// observeWait is called after every acquisition attempt with the time
+	// spent queued and whether a slot was obtained.
+	observeWait func(wait time.Duration, acquired bool)
+}
+
+// HashPoolStats is a snapshot of a pool's occupancy.
+type HashPoolStats struct {
+	Workers int
+	InUse   int
+	Waiting int
+}
+
+var sharedHashPool atomic.Pointer[HashPool]
+
+// NewHashPool creates a pool running at most workers computations at once.
+// Callers wait up to timeout for a free slot.
+func NewHashPool(workers int, timeout time.Duration) *HashPool {
+	return &HashPool{
+		slots:   make(chan struct{}, max(workers, 1)),
+		timeout: timeout,
+	}
+}
+
+// SetHashPool installs the pool every PasswordHasher in the process runs
+// through. A nil pool removes the bound.
+func SetHashPool(pool *HashPool) {
+	sharedHashPool.Store(pool)
+}
+
+// SetWaitObserver registers a callback for queue wait times, used for
+// metrics. It must be called before the pool is used.
+func (p *HashPool) SetWaitObserver(observe func(wait time.Duration, acquired bool)) {
+	p.observeWait = observe
+}
+
+// Stats returns the current occupancy of the pool.
+func (p *HashPool) Stats() HashPoolStats {
+	return HashPoolStats{
+		Workers: cap(p.slots),
+		InUse:   len(p.slots),
+		Waiting: int(p.waiting.Load()),
+	}
+}
+
+// run calls fn once a slot is free, or returns ErrHashPoolBusy after the
+// queue timeout. A nil pool runs fn directly.
+func (p *HashPool) run(fn func()) error {
+	if p == nil {
+		fn()
+		return nil
+	}
+	if err := p.acquire(); err != nil {
+		return err
+	}
+	defer func() { <-p.slots }()
+	fn()
+	return nil
+}
+
+func (p *HashPool) acquire() error {
+	select {
+	case p.slots <- struct{}{}:
+		p.observe(0, true)
+		return nil
+	default:
+	}
+
+	start := time.Now()
+	p.waiting.Add(1)
+	defer p.waiting.Add(-1)
+	timer := time.NewTimer(p.timeout)
+	defer timer.Stop()
+	select {
+	case p.slots <- struct{}{}:
+		p.observe(time.Since(start), true)
+		return nil
+	case <-timer.C:
+		p.observe(time.Since(start), false)
+		return ErrHashPoolBusy
+	}
+}
+
+func (p *HashPool) observe(wait time.Duration, acquired bool) {
+	if p.observeWait != nil {
+		p.observeWait(wait, acquired)
+	}
+}
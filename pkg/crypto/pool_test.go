@@ -0,0 +1,84 @@
+package crypto
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashPoolBoundsConcurrency(t *testing.T) {
+	pool := NewHashPool(2, time.Second)
+	var mu sync.Mutex
+	running, peak := 0, 0
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := pool.run(func() {
+				mu.Lock()
+				running++
+				peak = max(peak, running)
+				mu.Unlock()
+				time.Sleep(10 * time.Millisecond)
+				mu.Lock()
+				running--
+				mu.Unlock()
+			})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 2, peak)
+	assert.Equal(t, HashPoolStats{Workers: 2}, pool.Stats())
+}
+
+func TestHashPoolTimesOutWhenFull(t *testing.T) {
+	pool := NewHashPool(1, 20*time.Millisecond)
+	var waits []bool
+	pool.SetWaitObserver(func(wait time.Duration, acquired bool) {
+		waits = append(waits, acquired)
+	})
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go func() {
+		_ = pool.run(func() {
+			close(started)
+			<-release
+		})
+	}()
+	<-started
+
+	ran := false
+	err := pool.run(func() { ran = true })
+	close(release)
+
+	assert.ErrorIs(t, err, ErrHashPoolBusy)
+	assert.False(t, ran)
+	assert.Equal(t, []bool{true, false}, waits)
+}
+
+func TestVerifyUsesSharedHashPool(t *testing.T) {
+	hasher := NewPasswordHasher(testArgon2Config())
+	hash, err := hasher.Hash("secret")
+	require.NoError(t, err)
+
+	pool := NewHashPool(1, 10*time.Millisecond)
+	SetHashPool(pool)
+	defer SetHashPool(nil)
+	require.NoError(t, pool.acquire())
+
+	_, err = hasher.Verify("secret", hash)
+	assert.ErrorIs(t, err, ErrHashPoolBusy)
+	_, err = hasher.Hash("secret")
+	assert.ErrorIs(t, err, ErrHashPoolBusy)
+
+	<-pool.slots
+	valid, err := hasher.Verify("secret", hash)
+	require.NoError(t, err)
+	assert.True(t, valid)
+}
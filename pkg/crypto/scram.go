@@ -0,0 +1,165 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// SchemeSCRAMSHA256 prefixes stored SCRAM-SHA-256 keys. The value after
+	// the prefix uses the RFC 5803 layout: iterations:salt$storedKey:serverKey
+	SchemeSCRAMSHA256 = "{SCRAM-SHA-256}"
+
+	// SCRAMIterations is the PBKDF2 iteration count for new SCRAM keys, the
+	// minimum RFC 7677 recommends.
+	SCRAMIterations = 4096
+
+	scramSaltLength = 16
+)
+
+// SCRAMCredentials are the salted keys a server keeps for SCRAM-SHA-256
+// (RFC 5802 section 3). They verify a client proof without the password.
+type SCRAMCredentials struct {
+	Iterations int
+	Salt       []byte
+	StoredKey  []byte
+	ServerKey  []byte
+}
+
+// HashValues hashes a plain text password into every stored form: the
+// Argon2id hash followed by the SCRAM-SHA-256 keys.
+func (ph *PasswordHasher) HashValues(password string) ([]string, error) {
+	hash, err := ph.Hash(password)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := ph.SCRAMSecret(password)
+	if err != nil {
+		return nil, err
+	}
+	return []string{hash, secret}, nil
+}
+
+// ProcessPasswordValues is ProcessPassword for values about to be stored. A
+// plain text password yields both stored forms (see HashValues); a pre-hashed
+// password cannot, so it is returned alone and SCRAM is unavailable for it.
+func (ph *PasswordHasher) ProcessPasswordValues(password string) ([]string, error) {
+	if IsHashedPassword(password) {
+		processed, err := ph.ProcessPassword(password)
+		if err != nil {
+			return nil, err
+		}
+		return []string{processed}, nil
+	}
+	return ph.HashValues(password)
+}
+
+// SCRAMSecret derives SCRAM-SHA-256 keys for a password with a fresh salt.
+// Format: {SCRAM-SHA-256}4096:salt$storedKey:serverKey
+func (ph *PasswordHasher) SCRAMSecret(password string) (string, error) {
+	salt := make([]byte, scramSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+	creds, err := DeriveSCRAMCredentials(password, salt, SCRAMIterations)
+	if err != nil {
+		return "", err
+	}
+	return creds.String(), nil
+}
+
+// DeriveSCRAMCredentials computes the SCRAM-SHA-256 keys for a password,
+// salt and iteration count.
+func DeriveSCRAMCredentials(password string, salt []byte, iterations int) (SCRAMCredentials, error) {
+	saltedPassword, err := pbkdf2.Key(sha256.New, password, salt, iterations, sha256.Size)
+	if err != nil {
+		return SCRAMCredentials{}, fmt.Errorf("failed to derive SCRAM keys: %w", err)
+	}
+	clientKey := hmacSHA256(saltedPassword, "Client Key")
+	storedKey := sha256.Sum256(clientKey)
+	return SCRAMCredentials{
+		Iterations: iterations,
+		Salt:       salt,
+		StoredKey:  storedKey[:],
+		ServerKey:  hmacSHA256(saltedPassword, "Server Key"),
+	}, nil
+}
+
+// ParseSCRAMSecret parses keys stored by SCRAMSecret.
+func ParseSCRAMSecret(secret string) (SCRAMCredentials, error) {
+	inner, ok := strings.CutPrefix(secret, SchemeSCRAMSHA256)
+	if !ok {
+		return SCRAMCredentials{}, fmt.Errorf("SCRAM secret missing scheme prefix")
+	}
+	params, keys, ok := strings.Cut(inner, "$")
+	if !ok {
+		return SCRAMCredentials{}, fmt.Errorf("invalid SCRAM secret format")
+	}
+	iterations, salt, ok := strings.Cut(params, ":")
+	if !ok {
+		return SCRAMCredentials{}, fmt.Errorf("invalid SCRAM secret format")
+	}
+	storedKey, serverKey, ok := strings.Cut(keys, ":")
+	if !ok {
+		return SCRAMCredentials{}, fmt.Errorf("invalid SCRAM secret format")
+	}
+
+	var creds SCRAMCredentials
+	var err error
+	if creds.Iterations, err = strconv.Atoi(iterations); err != nil || creds.Iterations <= 0 {
+		return SCRAMCredentials{}, fmt.Errorf("invalid SCRAM iteration count: %s", iterations)
+	}
+	if creds.Salt, err = base64.StdEncoding.DecodeString(salt); err != nil {
+		return SCRAMCredentials{}, fmt.Errorf("failed to decode salt: %w", err)
+	}
+	if creds.StoredKey, err = base64.StdEncoding.DecodeString(storedKey); err != nil {
+		return SCRAMCredentials{}, fmt.Errorf("failed to decode stored key: %w", err)
+	}
+	if creds.ServerKey, err = base64.StdEncoding.DecodeString(serverKey); err != nil {
+		return SCRAMCredentials{}, fmt.Errorf("failed to decode server key: %w", err)
+	}
+	return creds, nil
+}
+
+// String formats the keys as a stored SCRAM secret.
+func (c SCRAMCredentials) String() string {
+	return fmt.Sprintf("%s%d:%s$%s:%s",
+		SchemeSCRAMSHA256,
+		c.Iterations,
+		base64.StdEncoding.EncodeToString(c.Salt),
+		base64.StdEncoding.EncodeToString(c.StoredKey),
+		base64.StdEncoding.EncodeToString(c.ServerKey),
+	)
+}
+
+// VerifyClientProof checks a client proof over the SCRAM AuthMessage: the
+// proof XOR ClientSignature must recover a ClientKey that hashes to StoredKey.
+func (c SCRAMCredentials) VerifyClientProof(authMessage string, proof []byte) bool {
+	if len(proof) != sha256.Size || len(c.StoredKey) != sha256.Size {
+		return false
+	}
+	clientSignature := hmacSHA256(c.StoredKey, authMessage)
+	clientKey := make([]byte, len(proof))
+	for i := range proof {
+		clientKey[i] = proof[i] ^ clientSignature[i]
+	}
+	storedKey := sha256.Sum256(clientKey)
+	return hmac.Equal(storedKey[:], c.StoredKey)
+}
+
+// ServerSignature proves to the client that the server knows ServerKey.
+func (c SCRAMCredentials) ServerSignature(authMessage string) []byte {
+	return hmacSHA256(c.ServerKey, authMessage)
+}
+
+func hmacSHA256(key []byte, message string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(message))
+	return mac.Sum(nil)
+}
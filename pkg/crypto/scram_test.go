@@ -0,0 +1,81 @@
+package crypto
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// RFC 7677 section 3 example exchange for user "user" with password "pencil".
+const (
+	rfc7677Salt        = "W22ZaJ0SNY7soEsUEjb6gQ=="
+	rfc7677AuthMessage = "n=user,r=rOprNGfwEbeRWgbNEkqO," +
+		"r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096," +
+		"c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0"
+	rfc7677ClientProof     = "dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ="
+	rfc7677ServerSignature = "6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4="
+)
+
+func TestSCRAMCredentialsMatchRFC7677Example(t *testing.T) {
+	salt, err := base64.StdEncoding.DecodeString(rfc7677Salt)
+	require.NoError(t, err)
+	creds, err := DeriveSCRAMCredentials("pencil", salt, 4096)
+	require.NoError(t, err)
+
+	proof, err := base64.StdEncoding.DecodeString(rfc7677ClientProof)
+	require.NoError(t, err)
+	assert.True(t, creds.VerifyClientProof(rfc7677AuthMessage, proof))
+	assert.Equal(t, rfc7677ServerSignature, base64.StdEncoding.EncodeToString(creds.ServerSignature(rfc7677AuthMessage)))
+
+	proof[0] ^= 0xff
+	assert.False(t, creds.VerifyClientProof(rfc7677AuthMessage, proof))
+}
+
+func TestSCRAMSecretRoundTrip(t *testing.T) {
+	hasher := NewPasswordHasher(testArgon2Config())
+
+	secret, err := hasher.SCRAMSecret("pencil")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(secret, SchemeSCRAMSHA256+"4096:"))
+
+	creds, err := ParseSCRAMSecret(secret)
+	require.NoError(t, err)
+	derived, err := DeriveSCRAMCredentials("pencil", creds.Salt, creds.Iterations)
+	require.NoError(t, err)
+	assert.Equal(t, derived, creds)
+	assert.Equal(t, secret, creds.String())
+}
+
+func TestParseSCRAMSecretRejectsMalformedValues(t *testing.T) {
+	for _, secret := range []string{
+		"",
+		"{ARGON2ID}$argon2id$v=19$m=64,t=1,p=1$c2FsdA$aGFzaA",
+		"{SCRAM-SHA-256}4096:c2FsdA==",
+		"{SCRAM-SHA-256}0:c2FsdA==$a2V5:a2V5",
+		"{SCRAM-SHA-256}4096:c2FsdA==$not base64:a2V5",
+	} {
+		_, err := ParseSCRAMSecret(secret)
+		assert.Error(t, err, secret)
+	}
+}
+
+func TestProcessPasswordValues(t *testing.T) {
+	hasher := NewPasswordHasher(testArgon2Config())
+
+	values, err := hasher.ProcessPasswordValues("plain-text-password")
+	require.NoError(t, err)
+	require.Len(t, values, 2)
+	assert.True(t, strings.HasPrefix(values[0], SchemeArgon2ID))
+	assert.True(t, strings.HasPrefix(values[1], SchemeSCRAMSHA256))
+
+	// A pre-hashed password has no SCRAM keys to go with it.
+	values, err = hasher.ProcessPasswordValues(values[0])
+	require.NoError(t, err)
+	assert.Len(t, values, 1)
+
+	_, err = hasher.ProcessPasswordValues("{SCRAM-SHA-256}4096:c2FsdA==$a2V5:a2V5")
+	assert.Error(t, err)
+}
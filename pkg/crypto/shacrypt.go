@@ -0,0 +1,174 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+)
+
+// SHA-crypt ($5$ and $6$) as specified by Ulrich Drepper in "Unix crypt using
+// SHA-256 and SHA-512", the {CRYPT} default of most Linux systems.
+
+const (
+	shaCryptDefaultRounds = 5000
+	shaCryptMinRounds     = 1000
+	shaCryptMaxRounds     = 999999999
+	shaCryptMaxSalt       = 16
+	cryptAlphabet         = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+)
+
+type shaCryptParams struct {
+	prefix         string // "$5$" or "$6$"
+	newHash        func() hash.Hash
+	rounds         int
+	explicitRounds bool
+	salt           string
+}
+
+// parseSHACrypt reads the prefix, rounds and salt of a SHA-crypt value. The
+// checksum is not parsed: verification recomputes the whole string.
+func parseSHACrypt(value string) (shaCryptParams, error) {
+	var params shaCryptParams
+	switch {
+	case strings.HasPrefix(value, "$5$"):
+		params.prefix, params.newHash = "$5$", sha256.New
+	case strings.HasPrefix(value, "$6$"):
+		params.prefix, params.newHash = "$6$", sha512.New
+	default:
+		return params, fmt.Errorf("unsupported crypt format")
+	}
+	rest := strings.TrimPrefix(value, params.prefix)
+
+	params.rounds = shaCryptDefaultRounds
+	if strings.HasPrefix(rest, "rounds=") {
+		end := strings.Index(rest, "$")
+		if end == -1 {
+			return params, fmt.Errorf("invalid crypt rounds")
+		}
+		rounds, err := strconv.Atoi(rest[len("rounds="):end])
+		if err != nil {
+			return params, fmt.Errorf("invalid crypt rounds: %w", err)
+		}
+		params.rounds = min(max(rounds, shaCryptMinRounds), shaCryptMaxRounds)
+		params.explicitRounds = true
+		rest = rest[end+1:]
+	}
+
+	salt, checksum, found := strings.Cut(rest, "$")
+	if !found || checksum == "" {
+		return params, fmt.Errorf("missing crypt checksum")
+	}
+	if len(salt) > shaCryptMaxSalt {
+		salt = salt[:shaCryptMaxSalt]
+	}
+	params.salt = salt
+	return params, nil
+}
+
+// shaCrypt computes the full crypt string for a password.
+func shaCrypt(password string, params shaCryptParams) string {
+	key, salt := []byte(password), []byte(params.salt)
+
+	alternate := params.newHash()
+	alternate.Write(key)
+	alternate.Write(salt)
+	alternate.Write(key)
+	altSum := alternate.Sum(nil)
+	size := len(altSum)
+
+	digest := params.newHash()
+	digest.Write(key)
+	digest.Write(salt)
+	digest.Write(repeatBytes(altSum, len(key)))
+	for n := len(key); n > 0; n >>= 1 {
+		if n&1 != 0 {
+			digest.Write(altSum)
+		} else {
+			digest.Write(key)
+		}
+	}
+	sum := digest.Sum(nil)
+
+	pHash := params.newHash()
+	for range key {
+		pHash.Write(key)
+	}
+	pBytes := repeatBytes(pHash.Sum(nil), len(key))
+
+	sHash := params.newHash()
+	for i := 0; i < 16+int(sum[0]); i++ {
+		sHash.Write(salt)
+	}
+	sBytes := repeatBytes(sHash.Sum(nil), len(salt))
+
+	for i := 0; i < params.rounds; i++ {
+		round := params.newHash()
+		if i%2 != 0 {
+			round.Write(pBytes)
+		} else {
+			round.Write(sum)
+		}
+		if i%3 != 0 {
+			round.Write(sBytes)
+		}
+		if i%7 != 0 {
+			round.Write(pBytes)
+		}
+		if i%2 != 0 {
+			round.Write(sum)
+		} else {
+			round.Write(pBytes)
+		}
+		sum = round.Sum(sum[:0])
+	}
+
+	var b strings.Builder
+	b.WriteString(params.prefix)
+	if params.explicitRounds {
+		fmt.Fprintf(&b, "rounds=%d$", params.rounds)
+	}
+	b.WriteString(params.salt)
+	b.WriteByte('$')
+	if size == sha256.Size {
+		encodeSHACrypt(&b, sum, 10, 1)
+		writeCrypt24(&b, 0, sum[31], sum[30], 3)
+	} else {
+		encodeSHACrypt(&b, sum, 21, 2)
+		writeCrypt24(&b, 0, 0, sum[63], 2)
+	}
+	return b.String()
+}
+
+// encodeSHACrypt writes the digest in the byte order of the specification:
+// groups of three bytes taken stride apart, rotating within each group.
+func encodeSHACrypt(b *strings.Builder, sum []byte, stride int, rotate int) {
+	for i := 0; i < stride; i++ {
+		group := [3]byte{sum[i], sum[i+stride], sum[i+2*stride]}
+		// The first byte of group i sits at position (i*rotate)%3.
+		var ordered [3]byte
+		for j := range group {
+			ordered[(j+i*rotate)%3] = group[j]
+		}
+		writeCrypt24(b, ordered[0], ordered[1], ordered[2], 4)
+	}
+}
+
+func writeCrypt24(b *strings.Builder, b2, b1, b0 byte, n int) {
+	w := uint(b2)<<16 | uint(b1)<<8 | uint(b0)
+	for ; n > 0; n-- {
+		b.WriteByte(cryptAlphabet[w&0x3f])
+		w >>= 6
+	}
+}
+
+// repeatBytes repeats src until it is n bytes long.
+func repeatBytes(src []byte, n int) []byte {
+	out := make([]byte, 0, n)
+	for len(out) < n {
+		out = append(out, src[:min(len(src), n-len(out))]...)
+	}
+	return out
+}
@@ -0,0 +1,29 @@
+//go:build functional
+
+package functional
+
+import (
+	"context"
+	"testing"
+
+	ldap "github.com/go-ldap/ldap/v3"
+)
+
+func TestAbandonedSearchLeavesConnectionUsable(t *testing.T) {
+	srv := startTestServer(t)
+
+	conn := srv.dial(t)
+	bindAdmin(t, conn)
+	createMilestoneFixture(t, conn)
+
+	// Cancelling the context of an async search makes the client send an
+	// Abandon request for it.
+	ctx, cancel := context.WithCancel(context.Background())
+	response := conn.SearchAsync(ctx, ldap.NewSearchRequest(baseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		"(objectClass=*)", []string{"*"}, nil), 1)
+	cancel()
+	for response.Next() {
+	}
+
+	requireEntry(t, search(t, conn, "(uid=jane)", []string{"uid"}), janeDN)
+}
@@ -106,6 +106,26 @@ func TestADLikeCompatibilityMilestone(t *testing.T) {
 				filter:  "(member=uid=jane,ou=users,dc=example,dc=com)",
 				wantDNs: []string{groupDN},
 			},
+			{
+				name:    "memberOf in chain",
+				filter:  "(memberOf:1.2.840.113556.1.4.1941:=" + groupDN + ")",
+				wantDNs: []string{janeDN},
+			},
+			{
+				name:    "member in chain",
+				filter:  "(member:1.2.840.113556.1.4.1941:=" + janeDN + ")",
+				wantDNs: []string{groupDN},
+			},
+			{
+				name:    "caseExactMatch",
+				filter:  "(cn:caseExactMatch:=Jane Doe)",
+				wantDNs: []string{janeDN},
+			},
+			{
+				name:    "dnAttributes",
+				filter:  "(&(objectClass=inetOrgPerson)(ou:dn:=users))",
+				wantDNs: []string{adminDN, janeDN},
+			},
 		}
 
 		for _, tt := range tests {
@@ -0,0 +1,33 @@
+//go:build functional
+
+package functional
+
+import (
+	"testing"
+
+	ldap "github.com/go-ldap/ldap/v3"
+)
+
+func TestBindThrottleBlocksAccountAfterFailures(t *testing.T) {
+	srv := startTestServerWithEnv(t, map[string]string{
+		"LDAP_BIND_THROTTLE_ACCOUNT_FAILURES": "2",
+		"LDAP_BIND_THROTTLE_ADDRESS_FAILURES": "0",
+		"LDAP_BIND_THROTTLE_DELAY_MS":         "0",
+	}, "ldap")
+
+	admin := srv.dial(t)
+	bindAdmin(t, admin)
+	createMilestoneFixture(t, admin)
+
+	for i := 0; i < 2; i++ {
+		assertLDAPResultCode(t, bindErr(t, srv, janeDN, "wrong password"), ldap.LDAPResultInvalidCredentials)
+	}
+	// The block covers the right password and every bind mechanism.
+	assertLDAPResultCode(t, bindErr(t, srv, janeDN, "Password123!"), ldap.LDAPResultUnwillingToPerform)
+	if code := dialSASL(t, srv).scramBind("jane", "Password123!"); code != ldap.LDAPResultUnwillingToPerform {
+		t.Fatalf("SCRAM bind while blocked = %d, want unwillingToPerform", code)
+	}
+
+	// Other accounts from the same address are unaffected.
+	bindAdmin(t, srv.dial(t))
+}
@@ -0,0 +1,43 @@
+//go:build functional
+
+package functional
+
+import (
+	"testing"
+
+	ldap "github.com/go-ldap/ldap/v3"
+)
+
+func TestLegacyPasswordHashIsRehashedOnBind(t *testing.T) {
+	srv := startTestServerWithEnv(t, map[string]string{
+		"LDAP_ALLOW_LEGACY_PASSWORD_HASHES": "true",
+	}, "ldap")
+
+	admin := srv.dial(t)
+	bindAdmin(t, admin)
+	createMilestoneFixture(t, admin)
+
+	const migratedDN = "uid=migrated,ou=users,dc=example,dc=com"
+	user := ldap.NewAddRequest(migratedDN, nil)
+	user.Attribute("objectClass", []string{"inetOrgPerson"})
+	user.Attribute("uid", []string{"migrated"})
+	user.Attribute("cn", []string{"Migrated User"})
+	user.Attribute("sn", []string{"User"})
+	user.Attribute("userPassword", []string{
+		"{CRYPT}$6$migrated$OUnVWEGxwbGuxbMoGaLPVOgsLbgf9IAEwrnSLgK3rncFDZHK24Gdpxx6BKaFUQOrPe3rtE8FrZv0FilB1aVzR1",
+	})
+	if err := admin.Add(user); err != nil {
+		t.Fatalf("add user with sha512-crypt hash: %v", err)
+	}
+
+	// A pre-hashed password has no SCRAM keys until a bind rehashes it.
+	if code := dialSASL(t, srv).scramBind("migrated", "Migrated123!"); code != ldap.LDAPResultInvalidCredentials {
+		t.Fatalf("SCRAM bind before rehash = %d, want invalidCredentials", code)
+	}
+	assertLDAPResultCode(t, bindErr(t, srv, migratedDN, "wrong password"), ldap.LDAPResultInvalidCredentials)
+	assertBindSucceeds(t, srv, migratedDN, "Migrated123!")
+	if code := dialSASL(t, srv).scramBind("migrated", "Migrated123!"); code != ldap.LDAPResultSuccess {
+		t.Fatalf("SCRAM bind after rehash = %d, want success", code)
+	}
+	assertBindSucceeds(t, srv, migratedDN, "Migrated123!")
+}
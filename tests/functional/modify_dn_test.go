@@ -0,0 +1,56 @@
+//go:build functional
+
+package functional
+
+import (
+	"testing"
+
+	ldap "github.com/go-ldap/ldap/v3"
+)
+
+func TestModifyDNRenamesAndMovesEntries(t *testing.T) {
+	srv := startTestServer(t)
+
+	conn := srv.dial(t)
+	bindAdmin(t, conn)
+	createMilestoneFixture(t, conn)
+
+	before := requireEntry(t, search(t, conn, "(uid=jane)", []string{"entryUUID"}), janeDN)
+	entryUUID := assertEntryUUIDAttr(t, before)
+
+	renamedDN := "uid=janet," + usersOUDN
+	if err := conn.ModifyDN(ldap.NewModifyDNRequest(janeDN, "uid=janet", true, "")); err != nil {
+		t.Fatalf("rename jane: %v", err)
+	}
+
+	renamed := requireEntry(t, search(t, conn, "(uid=janet)", []string{"uid", "entryUUID", "memberOf"}), renamedDN)
+	assertAttrValues(t, renamed, "uid", []string{"janet"})
+	assertAttrValues(t, renamed, "entryUUID", []string{entryUUID})
+	assertAttrValues(t, renamed, "memberOf", []string{groupDN})
+
+	group := requireEntry(t, search(t, conn, "(cn=engineering)", []string{"member"}), groupDN)
+	assertAttrValues(t, group, "member", []string{renamedDN})
+	assertBindSucceeds(t, srv, renamedDN, "Password123!")
+
+	archive := ldap.NewAddRequest("ou=archive,"+baseDN, nil)
+	archive.Attribute("objectClass", []string{"organizationalUnit"})
+	archive.Attribute("ou", []string{"archive"})
+	if err := conn.Add(archive); err != nil {
+		t.Fatalf("add archive OU: %v", err)
+	}
+
+	movedDN := "uid=janet,ou=archive," + baseDN
+	if err := conn.ModifyDN(ldap.NewModifyDNRequest(renamedDN, "uid=janet", true, "ou=archive,"+baseDN)); err != nil {
+		t.Fatalf("move janet: %v", err)
+	}
+	requireEntry(t, search(t, conn, "(uid=janet)", []string{"uid"}), movedDN)
+
+	assertLDAPResultCode(t,
+		conn.ModifyDN(ldap.NewModifyDNRequest("uid=missing,"+usersOUDN, "uid=other", true, "")),
+		ldap.LDAPResultNoSuchObject,
+	)
+	assertLDAPResultCode(t,
+		conn.ModifyDN(ldap.NewModifyDNRequest(movedDN, "uid=admin", true, usersOUDN)),
+		ldap.LDAPResultEntryAlreadyExists,
+	)
+}
@@ -0,0 +1,59 @@
+//go:build functional
+
+package functional
+
+import (
+	"sync"
+	"testing"
+
+	ldap "github.com/go-ldap/ldap/v3"
+)
+
+func TestModifyIncrementAllocatesDistinctIDs(t *testing.T) {
+	srv := startTestServer(t)
+
+	conn := srv.dial(t)
+	bindAdmin(t, conn)
+
+	poolDN := "ou=idpool," + baseDN
+	pool := ldap.NewAddRequest(poolDN, nil)
+	pool.Attribute("objectClass", []string{"organizationalUnit"})
+	pool.Attribute("ou", []string{"idpool"})
+	pool.Attribute("uidNumber", []string{"10000"})
+	if err := conn.Add(pool); err != nil {
+		t.Fatalf("add id pool: %v", err)
+	}
+
+	// Provisioning jobs on separate connections race for the next ID.
+	const jobs = 5
+	var wg sync.WaitGroup
+	errs := make(chan error, jobs)
+	for range jobs {
+		jobConn := srv.dial(t)
+		bindAdmin(t, jobConn)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			increment := ldap.NewModifyRequest(poolDN, nil)
+			increment.Increment("uidNumber", "1")
+			errs <- jobConn.Modify(increment)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("increment uidNumber: %v", err)
+		}
+	}
+	entry := requireEntry(t, search(t, conn, "(ou=idpool)", []string{"uidNumber"}), poolDN)
+	assertAttrValues(t, entry, "uidNumber", []string{"10005"})
+
+	notInteger := ldap.NewModifyRequest(poolDN, nil)
+	notInteger.Increment("uidNumber", "one")
+	assertLDAPResultCode(t, conn.Modify(notInteger), ldap.LDAPResultInvalidAttributeSyntax)
+
+	missing := ldap.NewModifyRequest(poolDN, nil)
+	missing.Increment("gidNumber", "1")
+	assertLDAPResultCode(t, conn.Modify(missing), ldap.LDAPResultNoSuchAttribute)
+}
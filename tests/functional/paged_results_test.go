@@ -0,0 +1,79 @@
+//go:build functional
+
+package functional
+
+import (
+	"slices"
+	"testing"
+
+	ldap "github.com/go-ldap/ldap/v3"
+)
+
+func TestPagedResultsAndCriticalControls(t *testing.T) {
+	srv := startTestServer(t)
+
+	conn := srv.dial(t)
+	bindAdmin(t, conn)
+	createMilestoneFixture(t, conn)
+	createReadOnlyServiceAccountFixture(t, conn)
+
+	all := search(t, conn, "(objectClass=*)", []string{"1.1"})
+	if len(all.Entries) < 5 {
+		t.Fatalf("fixture has %d entries, want at least 5", len(all.Entries))
+	}
+
+	req := ldap.NewSearchRequest(baseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		"(objectClass=*)", []string{"1.1"}, nil)
+	paged, err := conn.SearchWithPaging(req, 2)
+	if err != nil {
+		t.Fatalf("paged search: %v", err)
+	}
+	want := make([]string, 0, len(all.Entries))
+	for _, entry := range all.Entries {
+		want = append(want, entry.DN)
+	}
+	assertDNs(t, paged, want)
+
+	first := ldap.NewSearchRequest(baseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		"(objectClass=*)", []string{"1.1"}, []ldap.Control{ldap.NewControlPaging(1)})
+	res, err := conn.Search(first)
+	if err != nil {
+		t.Fatalf("first page: %v", err)
+	}
+	if len(res.Entries) != 1 {
+		t.Fatalf("first page has %d entries, want 1", len(res.Entries))
+	}
+	pagingControl, ok := ldap.FindControl(res.Controls, ldap.ControlTypePaging).(*ldap.ControlPaging)
+	if !ok || len(pagingControl.Cookie) == 0 {
+		t.Fatalf("first page paging control = %v, want cookie", ldap.FindControl(res.Controls, ldap.ControlTypePaging))
+	}
+
+	other := ldap.NewControlPaging(1)
+	other.SetCookie(pagingControl.Cookie)
+	mismatched := ldap.NewSearchRequest(baseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		"(uid=jane)", []string{"1.1"}, []ldap.Control{other})
+	_, err = conn.Search(mismatched)
+	assertLDAPResultCode(t, err, ldap.LDAPResultUnwillingToPerform)
+
+	rootDSE, err := conn.Search(ldap.NewSearchRequest("", ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false,
+		"(objectClass=*)", []string{"supportedControl"}, nil))
+	if err != nil {
+		t.Fatalf("RootDSE search: %v", err)
+	}
+	if got := rootDSE.Entries[0].GetAttributeValues("supportedControl"); !slices.Contains(got, ldap.ControlTypePaging) {
+		t.Fatalf("supportedControl = %v, want %s", got, ldap.ControlTypePaging)
+	}
+
+	critical := []ldap.Control{ldap.NewControlString("1.2.3.4.5", true, "")}
+	assertLDAPResultCode(t,
+		conn.Del(ldap.NewDelRequest(janeDN, critical)),
+		ldap.LDAPResultUnavailableCriticalExtension,
+	)
+	requireEntry(t, search(t, conn, "(uid=jane)", []string{"uid"}), janeDN)
+
+	nonCritical := []ldap.Control{ldap.NewControlString("1.2.3.4.5", false, "")}
+	if _, err := conn.Search(ldap.NewSearchRequest(baseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		"(uid=jane)", []string{"uid"}, nonCritical)); err != nil {
+		t.Fatalf("search with non-critical unknown control: %v", err)
+	}
+}
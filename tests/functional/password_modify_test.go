@@ -0,0 +1,52 @@
+//go:build functional
+
+package functional
+
+import (
+	"testing"
+
+	ldap "github.com/go-ldap/ldap/v3"
+)
+
+func TestPasswordModifyChangesOwnPasswordAndResetsOthers(t *testing.T) {
+	srv := startTestServer(t)
+
+	admin := srv.dial(t)
+	bindAdmin(t, admin)
+	createMilestoneFixture(t, admin)
+	createReadOnlyServiceAccountFixture(t, admin)
+	appBindDN := "uid=appbind," + usersOUDN
+
+	jane := srv.dial(t)
+	if err := jane.Bind(janeDN, "Password123!"); err != nil {
+		t.Fatalf("jane bind: %v", err)
+	}
+
+	_, err := jane.PasswordModify(ldap.NewPasswordModifyRequest("", "", "NewPassword456!"))
+	assertLDAPResultCode(t, err, ldap.LDAPResultUnwillingToPerform)
+	_, err = jane.PasswordModify(ldap.NewPasswordModifyRequest("", "wrong", "NewPassword456!"))
+	assertLDAPResultCode(t, err, ldap.LDAPResultInvalidCredentials)
+	_, err = jane.PasswordModify(ldap.NewPasswordModifyRequest(appBindDN, "AppBindPassword123!", "Stolen789!"))
+	assertLDAPResultCode(t, err, ldap.LDAPResultInsufficientAccessRights)
+
+	if _, err := jane.PasswordModify(ldap.NewPasswordModifyRequest("", "Password123!", "NewPassword456!")); err != nil {
+		t.Fatalf("self-service password modify: %v", err)
+	}
+	if err := srv.dial(t).Bind(janeDN, "NewPassword456!"); err != nil {
+		t.Fatalf("bind with changed password: %v", err)
+	}
+
+	// An admin reset needs no old password; omitting the new one makes the
+	// server generate it.
+	result, err := admin.PasswordModify(ldap.NewPasswordModifyRequest("u:appbind", "", ""))
+	if err != nil {
+		t.Fatalf("admin password reset: %v", err)
+	}
+	if result.GeneratedPassword == "" {
+		t.Fatal("admin password reset returned no generated password")
+	}
+	if err := srv.dial(t).Bind(appBindDN, result.GeneratedPassword); err != nil {
+		t.Fatalf("bind with generated password: %v", err)
+	}
+	assertLDAPResultCode(t, srv.dial(t).Bind(appBindDN, "AppBindPassword123!"), ldap.LDAPResultInvalidCredentials)
+}
@@ -0,0 +1,62 @@
+//go:build functional
+
+package functional
+
+import (
+	"testing"
+
+	ldap "github.com/go-ldap/ldap/v3"
+)
+
+func TestPasswordPolicyQualityHistoryAndLockout(t *testing.T) {
+	srv := startTestServerWithEnv(t, map[string]string{
+		"LDAP_PPOLICY_MIN_LENGTH":   "10",
+		"LDAP_PPOLICY_HISTORY":      "2",
+		"LDAP_PPOLICY_MAX_FAILURES": "3",
+	}, "ldap")
+
+	admin := srv.dial(t)
+	bindAdmin(t, admin)
+	createMilestoneFixture(t, admin)
+
+	short := ldap.NewModifyRequest(janeDN, nil)
+	short.Replace("userPassword", []string{"Short1!"})
+	assertLDAPResultCode(t, admin.Modify(short), ldap.LDAPResultConstraintViolation)
+
+	jane := srv.dial(t)
+	if err := jane.Bind(janeDN, "Password123!"); err != nil {
+		t.Fatalf("jane bind: %v", err)
+	}
+	_, err := jane.PasswordModify(ldap.NewPasswordModifyRequest("", "Password123!", "Password123!"))
+	assertLDAPResultCode(t, err, ldap.LDAPResultConstraintViolation)
+
+	for i := 0; i < 3; i++ {
+		assertLDAPResultCode(t, bindErr(t, srv, janeDN, "wrong password"), ldap.LDAPResultInvalidCredentials)
+	}
+	result, err := srv.dial(t).SimpleBind(&ldap.SimpleBindRequest{
+		Username: janeDN,
+		Password: "Password123!",
+		Controls: []ldap.Control{ldap.NewControlBeheraPasswordPolicy()},
+	})
+	assertLDAPResultCode(t, err, ldap.LDAPResultInvalidCredentials)
+	if result == nil {
+		t.Fatal("locked bind returned no result")
+	}
+	policy, ok := ldap.FindControl(result.Controls, ldap.ControlTypeBeheraPasswordPolicy).(*ldap.ControlBeheraPasswordPolicy)
+	if !ok || policy.Error != 1 {
+		t.Fatalf("locked bind password policy control = %v, want accountLocked", result.Controls)
+	}
+
+	entry := requireEntry(t, search(t, admin, "(uid=jane)", []string{"pwdAccountLockedTime", "pwdFailureTime"}), janeDN)
+	if entry.GetAttributeValue("pwdAccountLockedTime") == "" || len(entry.GetAttributeValues("pwdFailureTime")) != 3 {
+		t.Fatalf("jane policy state = %v, want a lock and three failures", entry.Attributes)
+	}
+
+	// An administrator reset unlocks the account.
+	reset := ldap.NewModifyRequest(janeDN, nil)
+	reset.Replace("userPassword", []string{"ResetPassword456!"})
+	if err := admin.Modify(reset); err != nil {
+		t.Fatalf("admin password reset: %v", err)
+	}
+	assertBindSucceeds(t, srv, janeDN, "ResetPassword456!")
+}
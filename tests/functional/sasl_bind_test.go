@@ -0,0 +1,190 @@
+//go:build functional
+
+package functional
+
+import (
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"encoding/base64"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	ldap "github.com/go-ldap/ldap/v3"
+	"github.com/smarzola/ldaplite/internal/protocol/ber"
+)
+
+func TestSASLPlainAndSCRAMBind(t *testing.T) {
+	srv := startTestServer(t)
+
+	admin := srv.dial(t)
+	bindAdmin(t, admin)
+	createMilestoneFixture(t, admin)
+
+	rootDSE, err := admin.Search(ldap.NewSearchRequest("", ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false,
+		"(objectClass=*)", []string{"supportedSASLMechanisms"}, nil))
+	if err != nil {
+		t.Fatalf("RootDSE search: %v", err)
+	}
+	mechanisms := rootDSE.Entries[0].GetAttributeValues("supportedSASLMechanisms")
+	if !slices.Contains(mechanisms, "PLAIN") || !slices.Contains(mechanisms, "SCRAM-SHA-256") {
+		t.Fatalf("supportedSASLMechanisms = %v, want PLAIN and SCRAM-SHA-256", mechanisms)
+	}
+
+	plain := dialSASL(t, srv)
+	if code, _ := plain.bind("PLAIN", "\x00jane\x00wrong"); code != ldap.LDAPResultInvalidCredentials {
+		t.Fatalf("PLAIN bind with wrong password = %d, want invalidCredentials", code)
+	}
+	if code, _ := plain.bind("PLAIN", "dn:"+janeDN+"\x00jane\x00Password123!"); code != ldap.LDAPResultSuccess {
+		t.Fatalf("PLAIN bind = %d, want success", code)
+	}
+	if got := plain.whoAmI(); got != "dn:"+janeDN {
+		t.Fatalf("WhoAmI after PLAIN = %q, want %q", got, "dn:"+janeDN)
+	}
+
+	scram := dialSASL(t, srv)
+	if code := scram.scramBind("jane", "wrong"); code != ldap.LDAPResultInvalidCredentials {
+		t.Fatalf("SCRAM bind with wrong password = %d, want invalidCredentials", code)
+	}
+	if code := scram.scramBind("nobody", "Password123!"); code != ldap.LDAPResultInvalidCredentials {
+		t.Fatalf("SCRAM bind for unknown user = %d, want invalidCredentials", code)
+	}
+	if code := scram.scramBind("jane", "Password123!"); code != ldap.LDAPResultSuccess {
+		t.Fatalf("SCRAM bind = %d, want success", code)
+	}
+	if got := scram.whoAmI(); got != "dn:"+janeDN {
+		t.Fatalf("WhoAmI after SCRAM = %q, want %q", got, "dn:"+janeDN)
+	}
+}
+
+// saslClient speaks just enough raw LDAP for SASL binds, which the LDAP
+// client library only offers for a few mechanisms.
+type saslClient struct {
+	t      *testing.T
+	conn   net.Conn
+	nextID int
+	buf    []byte
+}
+
+func dialSASL(t *testing.T, srv *testServer) *saslClient {
+	t.Helper()
+	conn, err := net.DialTimeout("tcp", strings.TrimPrefix(srv.URL, "ldap://"), 2*time.Second)
+	if err != nil {
+		t.Fatalf("dial %s: %v", srv.URL, err)
+	}
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	return &saslClient{t: t, conn: conn}
+}
+
+// bind sends a SASL bind request and returns the result code and the
+// server's SASL credentials.
+func (c *saslClient) bind(mechanism, credentials string) (int, string) {
+	c.t.Helper()
+	auth := ber.TLV(0xa3, slices.Concat(ber.OctetString(mechanism), ber.OctetString(credentials)))
+	resp := c.roundTrip(ber.TLV(0x60, slices.Concat(ber.Integer(3), ber.OctetString(""), auth)), 0x61)
+	code, err := resp.Children[0].Int()
+	if err != nil {
+		c.t.Fatalf("bind result code: %v", err)
+	}
+	serverCreds := ""
+	if len(resp.Children) > 3 && resp.Children[3].Tag == 0x87 {
+		serverCreds = string(resp.Children[3].Value)
+	}
+	return code, serverCreds
+}
+
+// scramBind runs a SCRAM-SHA-256 exchange (RFC 5802) and checks the server
+// signature when the bind succeeds.
+func (c *saslClient) scramBind(username, password string) int {
+	c.t.Helper()
+	clientFirstBare := "n=" + username + ",r=fyko+d2lbbFgONRv9qkxdawL"
+	code, serverFirst := c.bind("SCRAM-SHA-256", "n,,"+clientFirstBare)
+	if code != 14 {
+		c.t.Fatalf("SCRAM client-first = %d, want saslBindInProgress", code)
+	}
+
+	fields := make(map[string]string)
+	for _, field := range strings.Split(serverFirst, ",") {
+		name, value, _ := strings.Cut(field, "=")
+		fields[name] = value
+	}
+	salt, err := base64.StdEncoding.DecodeString(fields["s"])
+	if err != nil {
+		c.t.Fatalf("SCRAM salt: %v", err)
+	}
+	iterations, err := strconv.Atoi(fields["i"])
+	if err != nil {
+		c.t.Fatalf("SCRAM iterations: %v", err)
+	}
+
+	saltedPassword, err := pbkdf2.Key(sha256.New, password, salt, iterations, sha256.Size)
+	if err != nil {
+		c.t.Fatalf("derive salted password: %v", err)
+	}
+	clientKey := hmacSHA256(saltedPassword, "Client Key")
+	storedKey := sha256.Sum256(clientKey)
+	withoutProof := "c=biws,r=" + fields["r"]
+	authMessage := clientFirstBare + "," + serverFirst + "," + withoutProof
+	proof := hmacSHA256(storedKey[:], authMessage)
+	for i := range proof {
+		proof[i] ^= clientKey[i]
+	}
+
+	code, serverFinal := c.bind("SCRAM-SHA-256", withoutProof+",p="+base64.StdEncoding.EncodeToString(proof))
+	if code == ldap.LDAPResultSuccess {
+		want := "v=" + base64.StdEncoding.EncodeToString(hmacSHA256(hmacSHA256(saltedPassword, "Server Key"), authMessage))
+		if serverFinal != want {
+			c.t.Fatalf("SCRAM server-final = %q, want %q", serverFinal, want)
+		}
+	}
+	return code
+}
+
+func (c *saslClient) whoAmI() string {
+	c.t.Helper()
+	resp := c.roundTrip(ber.TLV(0x77, ber.TLV(0x80, []byte("1.3.6.1.4.1.4203.1.11.3"))), 0x78)
+	for _, child := range resp.Children {
+		if child.Tag == 0x8b {
+			return string(child.Value)
+		}
+	}
+	return ""
+}
+
+func (c *saslClient) roundTrip(protocolOp []byte, responseTag byte) ber.Packet {
+	c.t.Helper()
+	c.nextID++
+	if _, err := c.conn.Write(ber.Sequence(ber.Integer(c.nextID), protocolOp)); err != nil {
+		c.t.Fatalf("write request: %v", err)
+	}
+	if err := c.conn.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		c.t.Fatalf("set read deadline: %v", err)
+	}
+	chunk := make([]byte, 4096)
+	for {
+		if msg, n, err := ber.ReadPacket(c.buf); err == nil {
+			c.buf = c.buf[n:]
+			if len(msg.Children) < 2 || msg.Children[1].Tag != responseTag {
+				c.t.Fatalf("unexpected response %x", msg.Value)
+			}
+			return msg.Children[1]
+		}
+		n, err := c.conn.Read(chunk)
+		if err != nil {
+			c.t.Fatalf("read response: %v", err)
+		}
+		c.buf = append(c.buf, chunk[:n]...)
+	}
+}
+
+func hmacSHA256(key []byte, message string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(message))
+	return mac.Sum(nil)
+}
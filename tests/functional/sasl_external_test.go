@@ -0,0 +1,111 @@
+//go:build functional
+
+package functional
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	ldap "github.com/go-ldap/ldap/v3"
+)
+
+func TestSASLExternalBindWithClientCertificate(t *testing.T) {
+	certFile, keyFile := writeTestTLSFiles(t)
+	caFile, clientCert := writeTestClientCA(t, "jane")
+	srv := startTestServerWithEnv(t, map[string]string{
+		"LDAP_STARTTLS_ENABLED":   "true",
+		"LDAP_TLS_CERT_FILE":      certFile,
+		"LDAP_TLS_KEY_FILE":       keyFile,
+		"LDAP_TLS_CLIENT_CA_FILE": caFile,
+		"LDAP_TLS_CLIENT_AUTH":    "verify",
+	}, "ldap")
+
+	admin := srv.dial(t)
+	bindAdmin(t, admin)
+	createMilestoneFixture(t, admin)
+
+	// Without TLS there is no certificate to authenticate.
+	assertLDAPResultCode(t, srv.dial(t).ExternalBind(), ldap.LDAPResultInappropriateAuthentication)
+
+	// verify mode still accepts TLS clients without a certificate.
+	anonymous := srv.dial(t)
+	if err := anonymous.StartTLS(&tls.Config{InsecureSkipVerify: true}); err != nil {
+		t.Fatalf("StartTLS without client certificate: %v", err)
+	}
+	assertLDAPResultCode(t, anonymous.ExternalBind(), ldap.LDAPResultInappropriateAuthentication)
+
+	conn := srv.dial(t)
+	if err := conn.StartTLS(&tls.Config{InsecureSkipVerify: true, Certificates: []tls.Certificate{clientCert}}); err != nil {
+		t.Fatalf("StartTLS with client certificate: %v", err)
+	}
+	if err := conn.ExternalBind(); err != nil {
+		t.Fatalf("SASL EXTERNAL bind: %v", err)
+	}
+	whoami, err := conn.WhoAmI(nil)
+	if err != nil {
+		t.Fatalf("WhoAmI: %v", err)
+	}
+	if whoami.AuthzID != "dn:"+janeDN {
+		t.Fatalf("WhoAmI = %q, want %q", whoami.AuthzID, "dn:"+janeDN)
+	}
+}
+
+// writeTestClientCA writes a CA bundle to a file and returns it with a client
+// certificate the CA issued for commonName.
+func writeTestClientCA(t *testing.T, commonName string) (string, tls.Certificate) {
+	t.Helper()
+
+	caKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate CA key: %v", err)
+	}
+	caTemplate := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ldaplite test client CA"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, &caTemplate, &caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("create CA certificate: %v", err)
+	}
+	caCert, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatalf("parse CA certificate: %v", err)
+	}
+
+	clientKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate client key: %v", err)
+	}
+	clientTemplate := x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	clientDER, err := x509.CreateCertificate(rand.Reader, &clientTemplate, caCert, &clientKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("create client certificate: %v", err)
+	}
+
+	caFile := filepath.Join(t.TempDir(), "clients-ca.crt")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}), 0600); err != nil {
+		t.Fatalf("write client CA: %v", err)
+	}
+	return caFile, tls.Certificate{Certificate: [][]byte{clientDER}, PrivateKey: clientKey}
+}
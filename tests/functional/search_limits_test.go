@@ -0,0 +1,57 @@
+//go:build functional
+
+package functional
+
+import (
+	"testing"
+
+	ldap "github.com/go-ldap/ldap/v3"
+)
+
+func TestSearchSizeLimitReturnsPartialResults(t *testing.T) {
+	srv := startTestServer(t)
+
+	conn := srv.dial(t)
+	bindAdmin(t, conn)
+	createMilestoneFixture(t, conn)
+
+	req := ldap.NewSearchRequest(baseDN, ldap.ScopeWholeSubtree, ldap.DerefAlways, 2, 0, false,
+		"(objectClass=*)", []string{"1.1"}, nil)
+	res, err := conn.Search(req)
+	assertLDAPResultCode(t, err, ldap.LDAPResultSizeLimitExceeded)
+	if res == nil || len(res.Entries) != 2 {
+		t.Fatalf("size-limited search returned %v, want 2 partial entries", res)
+	}
+}
+
+func TestPagedSearchSizeLimitCapsWholeSearch(t *testing.T) {
+	srv := startTestServer(t)
+
+	conn := srv.dial(t)
+	bindAdmin(t, conn)
+	createMilestoneFixture(t, conn)
+
+	first := ldap.NewSearchRequest(baseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 3, 0, false,
+		"(objectClass=*)", []string{"1.1"}, []ldap.Control{ldap.NewControlPaging(2)})
+	res, err := conn.Search(first)
+	if err != nil {
+		t.Fatalf("first page: %v", err)
+	}
+	if len(res.Entries) != 2 {
+		t.Fatalf("first page has %d entries, want 2", len(res.Entries))
+	}
+	pagingControl, ok := ldap.FindControl(res.Controls, ldap.ControlTypePaging).(*ldap.ControlPaging)
+	if !ok || len(pagingControl.Cookie) == 0 {
+		t.Fatalf("first page paging control = %v, want cookie", ldap.FindControl(res.Controls, ldap.ControlTypePaging))
+	}
+
+	next := ldap.NewControlPaging(2)
+	next.SetCookie(pagingControl.Cookie)
+	second := ldap.NewSearchRequest(baseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 3, 0, false,
+		"(objectClass=*)", []string{"1.1"}, []ldap.Control{next})
+	res, err = conn.Search(second)
+	assertLDAPResultCode(t, err, ldap.LDAPResultSizeLimitExceeded)
+	if res == nil || len(res.Entries) != 1 {
+		t.Fatalf("second page returned %v, want the 1 entry left under the size limit", res)
+	}
+}
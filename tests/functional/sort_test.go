@@ -0,0 +1,63 @@
+//go:build functional
+
+package functional
+
+import (
+	"slices"
+	"testing"
+
+	ldap "github.com/go-ldap/ldap/v3"
+)
+
+func TestServerSideSortOrdersResults(t *testing.T) {
+	srv := startTestServer(t)
+
+	conn := srv.dial(t)
+	bindAdmin(t, conn)
+	createMilestoneFixture(t, conn)
+	createReadOnlyServiceAccountFixture(t, conn)
+	appBindDN := "uid=appbind," + usersOUDN
+
+	sortedSearch := func(t *testing.T, keys ...*ldap.SortKey) *ldap.SearchResult {
+		t.Helper()
+		res, err := conn.Search(ldap.NewSearchRequest(baseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+			"(|(uid=jane)(uid=appbind))", []string{"sn"}, []ldap.Control{ldap.NewControlServerSideSorting(keys)}))
+		if err != nil {
+			t.Fatalf("sorted search: %v", err)
+		}
+		if ldap.FindControl(res.Controls, ldap.ControlTypeServerSideSortingResult) == nil {
+			t.Fatalf("sorted search controls = %v, want sort response control", res.Controls)
+		}
+		return res
+	}
+
+	if got := resultDNs(sortedSearch(t, &ldap.SortKey{AttributeType: "sn"})); !slices.Equal(got, []string{appBindDN, janeDN}) {
+		t.Fatalf("ascending sort = %v", got)
+	}
+	if got := resultDNs(sortedSearch(t, &ldap.SortKey{AttributeType: "sn", Reverse: true})); !slices.Equal(got, []string{janeDN, appBindDN}) {
+		t.Fatalf("reverse sort = %v", got)
+	}
+
+	// memberOf is computed and cannot be sorted; the control is not critical,
+	// so the search still returns every entry.
+	if got := resultDNs(sortedSearch(t, &ldap.SortKey{AttributeType: "memberOf"})); len(got) != 2 {
+		t.Fatalf("unsortable non-critical sort = %v, want both entries", got)
+	}
+
+	rootDSE, err := conn.Search(ldap.NewSearchRequest("", ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false,
+		"(objectClass=*)", []string{"supportedControl"}, nil))
+	if err != nil {
+		t.Fatalf("RootDSE search: %v", err)
+	}
+	if got := rootDSE.Entries[0].GetAttributeValues("supportedControl"); !slices.Contains(got, ldap.ControlTypeServerSideSorting) {
+		t.Fatalf("supportedControl = %v, want %s", got, ldap.ControlTypeServerSideSorting)
+	}
+}
+
+func resultDNs(res *ldap.SearchResult) []string {
+	dns := make([]string, 0, len(res.Entries))
+	for _, entry := range res.Entries {
+		dns = append(dns, entry.DN)
+	}
+	return dns
+}
@@ -0,0 +1,57 @@
+//go:build functional
+
+package functional
+
+import (
+	"testing"
+
+	ldap "github.com/go-ldap/ldap/v3"
+)
+
+const treeDeleteControlOID = "1.2.840.113556.1.4.805"
+
+func TestTreeDeleteRemovesSubtreeAndMemberships(t *testing.T) {
+	srv := startTestServer(t)
+
+	conn := srv.dial(t)
+	bindAdmin(t, conn)
+	createMilestoneFixture(t, conn)
+
+	contractorsDN := "ou=contractors," + baseDN
+	carolDN := "uid=carol," + contractorsDN
+	contractors := ldap.NewAddRequest(contractorsDN, nil)
+	contractors.Attribute("objectClass", []string{"organizationalUnit"})
+	contractors.Attribute("ou", []string{"contractors"})
+	if err := conn.Add(contractors); err != nil {
+		t.Fatalf("add contractors OU: %v", err)
+	}
+	carol := ldap.NewAddRequest(carolDN, nil)
+	carol.Attribute("objectClass", []string{"inetOrgPerson"})
+	carol.Attribute("uid", []string{"carol"})
+	carol.Attribute("cn", []string{"Carol King"})
+	carol.Attribute("sn", []string{"King"})
+	if err := conn.Add(carol); err != nil {
+		t.Fatalf("add carol: %v", err)
+	}
+	addCarol := ldap.NewModifyRequest(groupDN, nil)
+	addCarol.Add("member", []string{carolDN})
+	if err := conn.Modify(addCarol); err != nil {
+		t.Fatalf("add carol to group: %v", err)
+	}
+
+	assertLDAPResultCode(t, conn.Del(ldap.NewDelRequest(contractorsDN, nil)), ldap.LDAPResultNotAllowedOnNonLeaf)
+
+	treeDelete := []ldap.Control{ldap.NewControlString(treeDeleteControlOID, true, "")}
+	userConn := srv.dial(t)
+	if err := userConn.Bind(janeDN, "Password123!"); err != nil {
+		t.Fatalf("bind jane: %v", err)
+	}
+	assertLDAPResultCode(t, userConn.Del(ldap.NewDelRequest(contractorsDN, treeDelete)), ldap.LDAPResultInsufficientAccessRights)
+
+	if err := conn.Del(ldap.NewDelRequest(contractorsDN, treeDelete)); err != nil {
+		t.Fatalf("tree delete contractors: %v", err)
+	}
+	assertDNs(t, search(t, conn, "(|(ou=contractors)(uid=carol))", []string{"dn"}), nil)
+	group := requireEntry(t, search(t, conn, "(cn=engineering)", []string{"member"}), groupDN)
+	assertAttrValues(t, group, "member", []string{janeDN})
+}
@@ -0,0 +1,63 @@
+//go:build functional
+
+package functional
+
+import (
+	"slices"
+	"testing"
+
+	ldap "github.com/go-ldap/ldap/v3"
+)
+
+const (
+	vlvRequestOID  = "2.16.840.1.113730.3.4.9"
+	vlvResponseOID = "2.16.840.1.113730.3.4.10"
+)
+
+func TestVirtualListViewScrollsSortedUsers(t *testing.T) {
+	srv := startTestServer(t)
+
+	conn := srv.dial(t)
+	bindAdmin(t, conn)
+	createMilestoneFixture(t, conn)
+	createReadOnlyServiceAccountFixture(t, conn)
+	appBindDN := "uid=appbind," + usersOUDN
+	bySN := ldap.NewControlServerSideSorting([]*ldap.SortKey{{AttributeType: "sn"}})
+
+	vlvSearch := func(t *testing.T, vlvValue string, controls ...ldap.Control) (*ldap.SearchResult, error) {
+		t.Helper()
+		controls = append(controls, ldap.NewControlString(vlvRequestOID, true, vlvValue))
+		return conn.Search(ldap.NewSearchRequest(usersOUDN, ldap.ScopeSingleLevel, ldap.NeverDerefAliases, 0, 0, false,
+			"(objectClass=inetOrgPerson)", []string{"sn"}, controls))
+	}
+
+	// Users sorted by sn are admin (Administrator), appbind (Bind) and jane
+	// (Doe). beforeCount 0, afterCount 1, byOffset offset 2, contentCount 0.
+	res, err := vlvSearch(t, "\x30\x0e\x02\x01\x00\x02\x01\x01\xa0\x06\x02\x01\x02\x02\x01\x00", bySN)
+	if err != nil {
+		t.Fatalf("VLV search by offset: %v", err)
+	}
+	if got := resultDNs(res); !slices.Equal(got, []string{appBindDN, janeDN}) {
+		t.Fatalf("VLV window = %v, want appbind and jane", got)
+	}
+	response, ok := ldap.FindControl(res.Controls, vlvResponseOID).(*ldap.ControlString)
+	if !ok {
+		t.Fatalf("VLV controls = %v, want VLV response control", res.Controls)
+	}
+	// targetPosition 2, contentCount 3, virtualListViewResult success.
+	if want := "\x30\x09\x02\x01\x02\x02\x01\x03\x0a\x01\x00"; response.ControlValue != want {
+		t.Fatalf("VLV response value = %x, want %x", response.ControlValue, want)
+	}
+
+	// beforeCount 0, afterCount 0, greaterThanOrEqual "C".
+	res, err = vlvSearch(t, "\x30\x09\x02\x01\x00\x02\x01\x00\x81\x01C", bySN)
+	if err != nil {
+		t.Fatalf("VLV search by value: %v", err)
+	}
+	if got := resultDNs(res); !slices.Equal(got, []string{janeDN}) {
+		t.Fatalf("VLV jump to value = %v, want jane", got)
+	}
+
+	_, err = vlvSearch(t, "\x30\x0e\x02\x01\x00\x02\x01\x01\xa0\x06\x02\x01\x02\x02\x01\x00")
+	assertLDAPResultCode(t, err, 60)
+}